@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/auth"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// aclTokenHeader is the header, or the query parameter, of the ACL token.
+	aclTokenHeader = "X-Udup-Token"
+
+	errACLTokenNotFound   = "ACL token not found"
+	errPermissionDenied   = "Permission denied"
+	errNamespaceForbidden = "Permission denied for namespace %q"
+	errUserForbidden      = "Permission denied for user %v: no group allowed"
+)
+
+// aclToken is what a token of the ACL config is allowed to do.
+type aclToken struct {
+	management bool
+	namespaces map[string]bool
+	// accessor identifies the token in the audit log.
+	accessor string
+	// user is the user authenticated by LDAP or OIDC, e.g. "ldap:alice".
+	user string
+}
+
+// identity returns the user, or the kind of the token, "management" or
+// "namespace:" and the namespaces allowed.
+func (t *aclToken) identity() string {
+	if t.user != "" {
+		return t.user
+	}
+	if t.management {
+		return "management"
+	}
+	namespaces := make([]string, 0, len(t.namespaces))
+	for namespace := range t.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return "namespace:" + strings.Join(namespaces, ",")
+}
+
+// tokenAccessor returns the first 8 hex digits of the SHA-256 of the secret, which
+// tells the tokens apart without revealing them.
+func tokenAccessor(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// allowNamespace returns whether the token can manage the jobs of the namespace.
+func (t *aclToken) allowNamespace(namespace string) bool {
+	if t == nil || t.management {
+		return true
+	}
+	if namespace == "" {
+		namespace = models.DefaultNamespace
+	}
+	return t.namespaces[namespace]
+}
+
+// resolveToken returns what the secret is allowed to do, or nil if it is not a
+// token of the config.
+func (c *ACLConfig) resolveToken(secret string) *aclToken {
+	if secret == "" {
+		return nil
+	}
+	for _, management := range c.ManagementTokens {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(management)) == 1 {
+			return &aclToken{management: true, accessor: tokenAccessor(secret)}
+		}
+	}
+	var token *aclToken
+	for namespace, secrets := range c.NamespaceTokens {
+		for _, s := range secrets {
+			if subtle.ConstantTimeCompare([]byte(secret), []byte(s)) != 1 {
+				continue
+			}
+			if token == nil {
+				token = &aclToken{namespaces: make(map[string]bool), accessor: tokenAccessor(secret)}
+			}
+			token.namespaces[namespace] = true
+		}
+	}
+	return token
+}
+
+// resolveIdentity returns what the groups of the user are allowed to do, or nil
+// if none of its groups is allowed.
+func (c *ACLConfig) resolveIdentity(identity *auth.Identity) *aclToken {
+	groups := make(map[string]bool)
+	for _, group := range identity.Groups {
+		groups[group] = true
+	}
+	token := &aclToken{user: identity.Provider + ":" + identity.Name}
+	for _, group := range c.ManagementGroups {
+		if groups[group] {
+			token.management = true
+			return token
+		}
+	}
+	for namespace, namespaceGroups := range c.NamespaceGroups {
+		for _, group := range namespaceGroups {
+			if !groups[group] {
+				continue
+			}
+			if token.namespaces == nil {
+				token.namespaces = make(map[string]bool)
+			}
+			token.namespaces[namespace] = true
+		}
+	}
+	if token.namespaces == nil {
+		return nil
+	}
+	return token
+}
+
+// newAuthProviders returns the providers authenticating the users of the config.
+func newAuthProviders(c *ACLConfig) ([]auth.Provider, error) {
+	if c == nil || !c.Enabled {
+		return nil, nil
+	}
+	var providers []auth.Provider
+	if c.LDAP != nil {
+		p, err := auth.NewLDAPProvider(c.LDAP)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	if c.OIDC != nil {
+		p, err := auth.NewOIDCProvider(c.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// aclPublicPath returns whether the path is reached without credentials: the
+// login of the web UI.
+func aclPublicPath(path string) bool {
+	return path == "/v1/acl/oidc/login" || path == "/v1/acl/oidc/callback"
+}
+
+// aclJobPath returns whether the path is part of the job API, which the
+// namespace tokens are allowed to use.
+func aclJobPath(path string) bool {
+	switch {
+	case path == "/v1/job/renewal", path == "/v1/job/info":
+		// They reach the databases of any job.
+		return false
+	case path == "/v1/jobs", path == "/v1/validate/job", path == "/v1/acl/self":
+		return true
+	default:
+		return strings.HasPrefix(path, "/v1/jobs/") || strings.HasPrefix(path, "/v1/job/")
+	}
+}
+
+type aclContextKey struct{}
+
+// aclCheck resolves the token, or the user, of the request when ACLs are enabled.
+// It returns the request carrying the token for the job endpoints to check the
+// namespaces.
+func (s *HTTPServer) aclCheck(req *http.Request) (*http.Request, error) {
+	acl := s.agent.config.ACL
+	if acl == nil || !acl.Enabled || aclPublicPath(req.URL.Path) {
+		return req, nil
+	}
+
+	var token *aclToken
+	if secret := requestSecret(req); secret != "" {
+		token = acl.resolveToken(secret)
+	} else {
+		var err error
+		if token, err = s.authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	if token == nil {
+		return nil, CodedError(401, errACLTokenNotFound)
+	}
+	if !token.management && !aclJobPath(req.URL.Path) {
+		return nil, CodedError(403, errPermissionDenied)
+	}
+	return req.WithContext(context.WithValue(req.Context(), aclContextKey{}, token)), nil
+}
+
+// authenticate returns what the user of the request is allowed to do, or nil if
+// the request carries no credentials of the auth providers.
+func (s *HTTPServer) authenticate(req *http.Request) (*aclToken, error) {
+	for _, provider := range s.authProviders {
+		identity, err := provider.Authenticate(req)
+		if err == auth.ErrNoCredentials {
+			continue
+		}
+		if err != nil {
+			if _, ok := err.(*auth.CredentialsError); ok {
+				return nil, CodedError(401, err.Error())
+			}
+			s.logger.Errorf("http: Authentication by %v failed: %v", provider.Name(), err)
+			return nil, CodedErrorf(500, "authentication by %v failed", provider.Name())
+		}
+		token := s.agent.config.ACL.resolveIdentity(identity)
+		if token == nil {
+			return nil, CodedErrorf(403, errUserForbidden, identity.Provider+":"+identity.Name)
+		}
+		return token, nil
+	}
+	return nil, nil
+}
+
+// requestSecret returns the secret of the ACL token sent with the request.
+func requestSecret(req *http.Request) string {
+	secret := req.Header.Get(aclTokenHeader)
+	if secret == "" {
+		secret = req.URL.Query().Get(aclTokenHeader)
+	}
+	return secret
+}
+
+// requestToken returns the ACL token of the request, or nil if ACLs are disabled.
+func requestToken(req *http.Request) *aclToken {
+	token, _ := req.Context().Value(aclContextKey{}).(*aclToken)
+	return token
+}
+
+// checkNamespace returns an error if the token of the request is not allowed to
+// manage the jobs of the namespace.
+func checkNamespace(req *http.Request, namespace string) error {
+	if !requestToken(req).allowNamespace(namespace) {
+		return CodedErrorf(403, errNamespaceForbidden, namespace)
+	}
+	return nil
+}
+
+// checkJobNamespace returns an error if the token of the request is not allowed to
+// manage the job, and records the namespace of the job changed in the audit log.
+// A job that does not exist is left to the endpoint.
+func (s *HTTPServer) checkJobNamespace(req *http.Request, jobID string) error {
+	token := requestToken(req)
+	if (token == nil || token.management) && requestAuditEvent(req) == nil || jobID == "" {
+		return nil
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobID,
+	}
+	args.Region = s.agent.config.Region
+	s.parseRegion(req, &args.Region)
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return err
+	}
+	if out.Job == nil {
+		return nil
+	}
+	auditTargets(req, out.Job.Namespace)
+	return checkNamespace(req, out.Job.Namespace)
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/auth"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// oidcStateCookieName is the cookie of the state of a web UI login in progress,
+// which the callback checks against forged logins. oidcNonceCookieName is the
+// cookie of its nonce, which the ID token must carry, against replayed tokens.
+const (
+	oidcStateCookieName = "udup-oidc-state"
+	oidcNonceCookieName = "udup-oidc-nonce"
+)
+
+// ACLSelfRequest returns who the request is made by and what it is allowed to do.
+func (s *HTTPServer) ACLSelfRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	token := requestToken(req)
+	if token == nil {
+		return &api.ACLIdentity{Identity: "anonymous", Management: true}, nil
+	}
+	out := &api.ACLIdentity{
+		Identity:   token.identity(),
+		Management: token.management,
+	}
+	for namespace := range token.namespaces {
+		out.Namespaces = append(out.Namespaces, namespace)
+	}
+	sort.Strings(out.Namespaces)
+	return out, nil
+}
+
+// oidcProvider returns the OIDC provider, or nil if it is not configured.
+func (s *HTTPServer) oidcProvider() *auth.OIDCProvider {
+	for _, provider := range s.authProviders {
+		if p, ok := provider.(*auth.OIDCProvider); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// OIDCLoginRequest starts the login of a user of the web UI, redirecting it to
+// the OIDC provider.
+func (s *HTTPServer) OIDCLoginRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	provider := s.oidcProvider()
+	if provider == nil {
+		return nil, CodedError(404, "OIDC is not configured")
+	}
+
+	state := models.GenerateUUID()
+	nonce := models.GenerateUUID()
+	u, err := provider.AuthCodeURL(state, nonce)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range map[string]string{oidcStateCookieName: state, oidcNonceCookieName: nonce} {
+		http.SetCookie(resp, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     "/v1/acl/oidc/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   req.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	http.Redirect(resp, req, u, http.StatusFound)
+	return nil, nil
+}
+
+// OIDCCallbackRequest completes the login of a user of the web UI: the ID token
+// is kept in a cookie, authenticating the requests of the web UI.
+func (s *HTTPServer) OIDCCallbackRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	provider := s.oidcProvider()
+	if provider == nil {
+		return nil, CodedError(404, "OIDC is not configured")
+	}
+
+	query := req.URL.Query()
+	if e := query.Get("error"); e != "" {
+		return nil, CodedErrorf(401, "OIDC login failed: %v %v", e, query.Get("error_description"))
+	}
+	state, err := req.Cookie(oidcStateCookieName)
+	if err != nil || state.Value == "" || state.Value != query.Get("state") {
+		return nil, CodedError(400, "OIDC login state mismatch, try logging in again")
+	}
+	nonce, err := req.Cookie(oidcNonceCookieName)
+	if err != nil || nonce.Value == "" {
+		return nil, CodedError(400, "OIDC login nonce missing, try logging in again")
+	}
+	token, identity, err := provider.Exchange(query.Get("code"), nonce.Value)
+	if err != nil {
+		if _, ok := err.(*auth.CredentialsError); ok {
+			return nil, CodedError(401, err.Error())
+		}
+		return nil, err
+	}
+	if s.agent.config.ACL.resolveIdentity(identity) == nil {
+		return nil, CodedErrorf(403, errUserForbidden, identity.Provider+":"+identity.Name)
+	}
+
+	for _, name := range []string{oidcStateCookieName, oidcNonceCookieName} {
+		http.SetCookie(resp, &http.Cookie{
+			Name:   name,
+			Path:   "/v1/acl/oidc/",
+			MaxAge: -1,
+		})
+	}
+	http.SetCookie(resp, &http.Cookie{
+		Name:     auth.OIDCCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(resp, req, "/", http.StatusFound)
+	return nil, nil
+}
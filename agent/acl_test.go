@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/auth"
+)
+
+func TestACLCheck(t *testing.T) {
+	s := &HTTPServer{agent: &Agent{config: &Config{ACL: &ACLConfig{
+		Enabled:          true,
+		ManagementTokens: []string{"root"},
+		NamespaceTokens: map[string][]string{
+			"team-a":  {"a", "ab"},
+			"team-b":  {"ab"},
+			"default": {"d"},
+		},
+	}}}}
+
+	cases := []struct {
+		path  string
+		token string
+		code  int
+		allow []string
+		deny  []string
+	}{
+		{path: "/v1/jobs", code: 401},
+		{path: "/v1/jobs", token: "unknown", code: 401},
+		{path: "/v1/nodes", token: "root"},
+		{path: "/v1/nodes", token: "a", code: 403},
+		{path: "/v1/job/renewal", token: "a", code: 403},
+		{path: "/v1/jobs", token: "a", allow: []string{"team-a"}, deny: []string{"team-b", "default"}},
+		{path: "/v1/job/job1/pause", token: "ab", allow: []string{"team-a", "team-b"}, deny: []string{"default"}},
+		{path: "/v1/validate/job", token: "d", allow: []string{"default", ""}, deny: []string{"team-a"}},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.token != "" {
+			req.Header.Set(aclTokenHeader, c.token)
+		}
+		req, err = s.aclCheck(req)
+		if c.code != 0 {
+			if coded, ok := err.(HTTPCodedError); !ok || coded.Code() != c.code {
+				t.Errorf("%v %v: expect code %v, got %v", c.path, c.token, c.code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %v: unexpected error %v", c.path, c.token, err)
+			continue
+		}
+		for _, namespace := range c.allow {
+			if err := checkNamespace(req, namespace); err != nil {
+				t.Errorf("%v: expect namespace %q allowed, got %v", c.token, namespace, err)
+			}
+		}
+		for _, namespace := range c.deny {
+			if err := checkNamespace(req, namespace); err == nil {
+				t.Errorf("%v: expect namespace %q denied", c.token, namespace)
+			}
+		}
+	}
+}
+
+func TestACLDisabled(t *testing.T) {
+	s := &HTTPServer{agent: &Agent{config: DefaultConfig()}}
+	req, err := http.NewRequest("GET", "/v1/nodes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req, err = s.aclCheck(req); err != nil {
+		t.Fatalf("aclCheck() = %v", err)
+	}
+	if err := checkNamespace(req, "team-a"); err != nil {
+		t.Errorf("checkNamespace() = %v", err)
+	}
+}
+
+// stubAuthProvider authenticates the users of the header X-User, in the groups
+// of the header X-Groups.
+type stubAuthProvider struct{}
+
+func (stubAuthProvider) Name() string      { return "stub" }
+func (stubAuthProvider) Challenge() string { return "Stub" }
+
+func (stubAuthProvider) Authenticate(req *http.Request) (*auth.Identity, error) {
+	user := req.Header.Get("X-User")
+	switch user {
+	case "":
+		return nil, auth.ErrNoCredentials
+	case "mallory":
+		return nil, &auth.CredentialsError{Reason: "wrong password"}
+	}
+	return &auth.Identity{Provider: "stub", Name: user, Groups: req.Header["X-Groups"]}, nil
+}
+
+func TestACLUsers(t *testing.T) {
+	s := &HTTPServer{
+		agent: &Agent{config: &Config{ACL: &ACLConfig{
+			Enabled:          true,
+			ManagementTokens: []string{"root"},
+			ManagementGroups: []string{"admins"},
+			NamespaceGroups:  map[string][]string{"team-a": {"dev-a"}},
+		}}},
+		authProviders: []auth.Provider{stubAuthProvider{}},
+	}
+
+	cases := []struct {
+		user     string
+		groups   []string
+		path     string
+		code     int
+		identity string
+	}{
+		{path: "/v1/nodes", code: 401},
+		{user: "mallory", path: "/v1/nodes", code: 401},
+		{user: "bob", groups: []string{"other"}, path: "/v1/jobs", code: 403},
+		{user: "alice", groups: []string{"admins"}, path: "/v1/nodes", identity: "stub:alice"},
+		{user: "carol", groups: []string{"dev-a"}, path: "/v1/nodes", code: 403},
+		{user: "carol", groups: []string{"dev-a"}, path: "/v1/acl/self", identity: "stub:carol"},
+		{path: "/v1/acl/oidc/callback"},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.user != "" {
+			req.Header.Set("X-User", c.user)
+		}
+		for _, group := range c.groups {
+			req.Header.Add("X-Groups", group)
+		}
+		req, err = s.aclCheck(req)
+		if c.code != 0 {
+			if coded, ok := err.(HTTPCodedError); !ok || coded.Code() != c.code {
+				t.Errorf("%v %v: expect code %d, got %v", c.user, c.path, c.code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %v: unexpected error %v", c.user, c.path, err)
+			continue
+		}
+		if token := requestToken(req); c.identity != "" && (token == nil || token.identity() != c.identity) {
+			t.Errorf("%v %v: token %+v, expect %v", c.user, c.path, token, c.identity)
+		}
+	}
+
+	// A token takes precedence over the credentials of a user.
+	req, _ := http.NewRequest("GET", "/v1/nodes", nil)
+	req.Header.Set(aclTokenHeader, "root")
+	req.Header.Set("X-User", "mallory")
+	if req, err := s.aclCheck(req); err != nil || requestToken(req).identity() != "management" {
+		t.Errorf("aclCheck() with a token = %v", err)
+	}
+}
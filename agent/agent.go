@@ -18,10 +18,12 @@ import (
 	"github.com/hashicorp/memberlist"
 
 	ucli "github.com/actiontech/dtle/internal/client"
+	"github.com/actiontech/dtle/internal/client/driver"
 	uconf "github.com/actiontech/dtle/internal/config"
 	ulog "github.com/actiontech/dtle/internal/logger"
 	umodel "github.com/actiontech/dtle/internal/models"
 	usrv "github.com/actiontech/dtle/internal/server"
+	"github.com/actiontech/dtle/plugins/hooks"
 )
 
 // Agent is a long running daemon that is used to run both
@@ -51,6 +53,9 @@ func NewAgent(config *Config, logOutput io.Writer, log *ulog.Logger) (*Agent, er
 		logOutput:  logOutput,
 		shutdownCh: make(chan struct{}),
 	}
+	if err := a.setupPlugins(); err != nil {
+		return nil, err
+	}
 	if err := a.setupServer(); err != nil {
 		return nil, err
 	}
@@ -100,6 +105,17 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 	if len(agentConfig.Server.EnabledSchedulers) != 0 {
 		conf.EnabledSchedulers = agentConfig.Server.EnabledSchedulers
 	}
+	if len(agentConfig.Server.NamespaceQuotas) != 0 {
+		conf.NamespaceQuotas = make(map[string]*umodel.NamespaceQuota, len(agentConfig.Server.NamespaceQuotas))
+		for namespace, quota := range agentConfig.Server.NamespaceQuotas {
+			conf.NamespaceQuotas[namespace] = &umodel.NamespaceQuota{
+				MaxRunningJobs:   quota.MaxRunningJobs,
+				MaxDumpWorkers:   quota.MaxDumpWorkers,
+				MaxBandwidthMBps: quota.MaxBandwidthMBps,
+				Queue:            quota.Queue,
+			}
+		}
+	}
 
 	switch agentConfig.Profile {
 	case "wan":
@@ -121,6 +137,7 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 	}
 	conf.RPCAddr.Port = rpcAddr.Port
 	conf.RPCAddr.IP = rpcAddr.IP
+	conf.RPCAddr.Zone = rpcAddr.Zone
 	conf.SerfConfig.MemberlistConfig.BindPort = serfAddr.Port
 	conf.SerfConfig.MemberlistConfig.BindAddr = serfAddr.IP.String()
 
@@ -137,6 +154,15 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 	conf.SerfConfig.MemberlistConfig.AdvertiseAddr = serfAddr.IP.String()
 	conf.SerfConfig.MemberlistConfig.AdvertisePort = serfAddr.Port
 
+	if limits := agentConfig.Limits; limits != nil {
+		if limits.RPCHandshakeTimeout != nil {
+			conf.RPCHandshakeTimeout = *limits.RPCHandshakeTimeout
+		}
+		if limits.RPCMaxConnsPerClient != nil {
+			conf.RPCMaxConnsPerClient = *limits.RPCMaxConnsPerClient
+		}
+	}
+
 	if heartbeatGrace := agentConfig.Server.HeartbeatGrace; heartbeatGrace != "" {
 		dur, err := time.ParseDuration(heartbeatGrace)
 		if err != nil {
@@ -144,6 +170,34 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 		}
 		conf.HeartbeatGrace = dur
 	}
+	durations := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"eval_gc_threshold", agentConfig.Server.EvalGCThreshold, &conf.EvalGCThreshold},
+		{"job_gc_threshold", agentConfig.Server.JobGCThreshold, &conf.JobGCThreshold},
+		{"node_gc_threshold", agentConfig.Server.NodeGCThreshold, &conf.NodeGCThreshold},
+		{"gc_interval", agentConfig.Server.GCInterval, &conf.GCInterval},
+		{"audit_gc_threshold", agentConfig.Server.AuditGCThreshold, &conf.AuditGCThreshold},
+		{"reconnect_interval", agentConfig.Server.ReconnectInterval, &conf.SerfConfig.ReconnectInterval},
+		{"reconnect_timeout", agentConfig.Server.ReconnectTimeout, &conf.SerfConfig.ReconnectTimeout},
+		{"tombstone_timeout", agentConfig.Server.TombstoneTimeout, &conf.SerfConfig.TombstoneTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse %s %q: %v", d.name, d.value, err)
+		}
+		*d.dest = dur
+	}
+
+	if agentConfig.Server.RejoinAfterLeave != nil {
+		conf.SerfConfig.RejoinAfterLeave = *agentConfig.Server.RejoinAfterLeave
+	}
 
 	if *agentConfig.Consul.AutoAdvertise && agentConfig.Consul.ServerServiceName == "" {
 		return nil, fmt.Errorf("server_service_name must be set when auto_advertise is enabled")
@@ -152,6 +206,10 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 	// Add the Consul config
 	conf.ConsulConfig = agentConfig.Consul
 
+	if agentConfig.TLS != nil {
+		conf.TLSConfig = agentConfig.TLS
+	}
+
 	return conf, nil
 }
 
@@ -180,7 +238,15 @@ func (a *Agent) clientConfig() (*uconf.ClientConfig, error) {
 	}
 	if a.config.DataDir != "" {
 		conf.StateDir = filepath.Join(a.config.DataDir, "agent")
+		conf.AllocDir = filepath.Join(a.config.DataDir, "alloc")
 	}
+	if a.config.Client.AllocDir != "" {
+		conf.AllocDir = a.config.Client.AllocDir
+	}
+	conf.AllocDirSizeLimit = int64(a.config.Client.AllocDirSizeLimit) * 1024 * 1024
+	conf.CgroupParent = a.config.Client.CgroupParent
+	conf.DriverExecutor = a.config.Client.DriverExecutor
+	conf.PluginDir = a.pluginDir()
 	conf.Servers = a.config.Client.Servers
 
 	// Setup the node
@@ -189,8 +255,9 @@ func (a *Agent) clientConfig() (*uconf.ClientConfig, error) {
 	conf.Node.Name = a.config.NodeName
 
 	// Set up the HTTP advertise address
-	conf.Node.HTTPAddr = fmt.Sprintf("%s:%d", a.config.BindAddr, a.config.Ports.HTTP) //a.config.AdvertiseAddrs.HTTP
+	conf.Node.HTTPAddr = a.config.AdvertiseAddrs.HTTP
 	conf.Node.NatsAddr = a.config.AdvertiseAddrs.Nats
+	conf.Node.MaxAllocs = a.config.Client.MaxAllocs
 
 	conf.Version = a.config.Version
 
@@ -199,7 +266,26 @@ func (a *Agent) clientConfig() (*uconf.ClientConfig, error) {
 	}
 
 	conf.ConsulConfig = a.config.Consul
+	if a.config.TLS != nil {
+		conf.TLSConfig = a.config.TLS
+	}
 	conf.NatsAddr = a.config.AdvertiseAddrs.Nats
+	conf.NatsBindAddr = a.config.normalizedAddrs.Nats
+	conf.Nats = a.config.Nats
+	if err := conf.Nats.Validate(); err != nil {
+		return nil, fmt.Errorf("nats: %v", err)
+	}
+	conf.Vault = a.config.Vault
+	if conf.Storage = a.config.Storage; conf.Storage != nil {
+		if err := conf.Storage.Validate(); err != nil {
+			return nil, fmt.Errorf("storage: %v", err)
+		}
+	}
+	if conf.Nats.External() {
+		// the tasks of all the agents connect to the cluster
+		conf.NatsAddr = conf.Nats.Addr()
+		conf.Node.NatsAddr = conf.NatsAddr
+	}
 	conf.MaxPayload = a.config.Network.MaxPayload
 	conf.StatsCollectionInterval = a.config.Metric.collectionInterval
 	conf.PublishNodeMetrics = a.config.Metric.PublishNodeMetrics
@@ -210,6 +296,45 @@ func (a *Agent) clientConfig() (*uconf.ClientConfig, error) {
 	return conf, nil
 }
 
+// pluginDir returns the dir of the plugins, empty if none.
+func (a *Agent) pluginDir() string {
+	if a.config.PluginDir != "" {
+		return a.config.PluginDir
+	}
+	if a.config.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(a.config.DataDir, "plugins")
+}
+
+// setupPlugins discovers the driver plugins in the plugin dir, which are used
+// by both the server to validate jobs and the client to run them, and loads the
+// hook plugins there, which are called by the tasks of the client.
+func (a *Agent) setupPlugins() error {
+	dir := a.pluginDir()
+	if dir == "" {
+		return nil
+	}
+	names, err := driver.DiscoverPlugins(dir)
+	if err != nil {
+		return fmt.Errorf("plugin discovery failed: %v", err)
+	}
+	if len(names) > 0 {
+		a.logger.Printf("agent: Found driver plugins %v in %v", names, dir)
+	}
+	hookNames, err := hooks.LoadPlugins(dir)
+	if err != nil {
+		return fmt.Errorf("loading hook plugins failed: %v", err)
+	}
+	if len(hookNames) > 0 {
+		a.logger.Printf("agent: Loaded hook plugins %v from %v", hookNames, dir)
+	}
+	if names := hooks.Names(); len(names) > 0 {
+		a.logger.Printf("agent: Hooks registered: %v", names)
+	}
+	return nil
+}
+
 // setupServer is used to setup the server if enabled
 func (a *Agent) setupServer() error {
 	if !a.config.Server.Enabled {
@@ -270,6 +395,15 @@ func (a *Agent) Leave() error {
 	return nil
 }
 
+// Drain lets the tasks on the client finish the work in flight before the agent
+// is shutdown.
+func (a *Agent) Drain(timeout time.Duration) error {
+	if a.client == nil {
+		return nil
+	}
+	return a.client.Drain(timeout)
+}
+
 // Shutdown is used to terminate the agent.
 func (a *Agent) Shutdown() error {
 	a.shutdownLock.Lock()
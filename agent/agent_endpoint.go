@@ -7,6 +7,7 @@
 package agent
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 
@@ -169,6 +170,71 @@ func (s *HTTPServer) updateServers(resp http.ResponseWriter, req *http.Request)
 	return nil, nil
 }
 
+// AgentHealthRequest reports the health of the agent components. It responds
+// with 503 if any of them is unhealthy, to be used by load balancers and
+// liveness/readiness probes.
+func (s *HTTPServer) AgentHealthRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	health := &agentHealth{
+		Healthy:    true,
+		Components: make(map[string]*componentHealth),
+		Jobs:       make(map[string]*componentHealth),
+	}
+	add := func(m map[string]*componentHealth, name string, err error) {
+		ch := &componentHealth{Healthy: err == nil}
+		if err != nil {
+			ch.Message = err.Error()
+			health.Healthy = false
+		}
+		m[name] = ch
+	}
+
+	if srv := s.agent.Server(); srv != nil {
+		var err error
+		if status := srv.LocalMember().Status; status != serf.StatusAlive {
+			err = fmt.Errorf("local member is %v", status)
+		}
+		add(health.Components, "serf", err)
+		if s.agent.config.Consul.Addr != "" {
+			add(health.Components, "consul", srv.StoreHealth())
+		}
+	}
+
+	var leader string
+	err := s.agent.RPC("Status.Leader", &umodel.GenericRequest{}, &leader)
+	if err == nil && leader == "" {
+		err = fmt.Errorf("no cluster leader")
+	}
+	add(health.Components, "rpc", err)
+
+	if client := s.agent.Client(); client != nil {
+		add(health.Components, "nats", client.NatsHealth())
+		for name, err := range client.TaskHealth() {
+			add(health.Jobs, name, err)
+		}
+	}
+
+	if !health.Healthy {
+		resp.Header().Set("Content-Type", "application/json")
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return health, nil
+}
+
+type agentHealth struct {
+	Healthy    bool                        `json:"healthy"`
+	Components map[string]*componentHealth `json:"components"`
+	Jobs       map[string]*componentHealth `json:"jobs,omitempty"`
+}
+
+type componentHealth struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
 type agentSelf struct {
 	Config *Config                      `json:"config"`
 	Member Member                       `json:"member,omitempty"`
@@ -94,6 +94,8 @@ func (s *HTTPServer) ClientAllocRequest(resp http.ResponseWriter, req *http.Requ
 	switch tokens[1] {
 	case "stats":
 		return s.allocStats(allocID, resp, req)
+	case "resync":
+		return s.allocResync(allocID, resp, req)
 	}
 
 	return nil, CodedError(404, resourceNotFoundErr)
@@ -109,3 +111,21 @@ func (s *HTTPServer) allocStats(allocID string, resp http.ResponseWriter, req *h
 	task := req.URL.Query().Get("task")
 	return aStats.LatestAllocStats(task)
 }
+
+func (s *HTTPServer) allocResync(allocID string, resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if !(req.Method == "POST" || req.Method == "PUT") {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	schema, table, err := parseResyncTable(req.URL.Query().Get("table"))
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	task := req.URL.Query().Get("task")
+	if task == "" {
+		task = umodel.TaskTypeSrc
+	}
+	if err := s.agent.client.ResyncAlloc(allocID, task, schema, table); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
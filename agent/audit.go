@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+type auditContextKey struct{}
+
+// auditedRequest returns whether the request changes something, and is recorded
+// in the audit log.
+func auditedRequest(req *http.Request) bool {
+	switch req.Method {
+	case "PUT", "POST", "DELETE":
+	default:
+		return false
+	}
+	switch req.URL.Path {
+	case "/v1/login", "/v1/validate/job", "/v1/job/info":
+		// They change nothing.
+		return false
+	case "/v1/jobs":
+		// A simulation registers nothing.
+		simulate, _ := strconv.ParseBool(req.URL.Query().Get("simulate"))
+		return !simulate
+	}
+	return true
+}
+
+// auditEvent returns the audit event of the request, or nil if it is not audited.
+func (s *HTTPServer) auditEvent(req *http.Request) *models.AuditEvent {
+	if !auditedRequest(req) {
+		return nil
+	}
+	return &models.AuditEvent{
+		Time:       time.Now().UnixNano(),
+		RemoteAddr: req.RemoteAddr,
+		Agent:      s.agent.config.NodeName,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+	}
+}
+
+// withAuditEvent returns the request carrying the audit event, for the handlers
+// to record the targets changed.
+func withAuditEvent(req *http.Request, event *models.AuditEvent) *http.Request {
+	if event == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), auditContextKey{}, event))
+}
+
+// requestAuditEvent returns the audit event of the request, or nil if it is not
+// audited.
+func requestAuditEvent(req *http.Request) *models.AuditEvent {
+	event, _ := req.Context().Value(auditContextKey{}).(*models.AuditEvent)
+	return event
+}
+
+// auditTargets records the namespace and the IDs of the jobs, orders or nodes
+// changed by the request. The first namespace recorded is kept.
+func auditTargets(req *http.Request, namespace string, targets ...string) {
+	event := requestAuditEvent(req)
+	if event == nil {
+		return
+	}
+	if event.Namespace == "" {
+		event.Namespace = namespace
+	}
+NEXT:
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		for _, t := range event.Targets {
+			if t == target {
+				continue NEXT
+			}
+		}
+		event.Targets = append(event.Targets, target)
+	}
+}
+
+// recordAudit completes the audit event with who made the request and its result,
+// and records it. A failure is logged without failing the request, which is done.
+func (s *HTTPServer) recordAudit(req *http.Request, event *models.AuditEvent, err error) {
+	if token := requestToken(req); token != nil {
+		event.Identity = token.identity()
+		event.Accessor = token.accessor
+	} else if secret := requestSecret(req); secret != "" && s.agent.config.ACL != nil && s.agent.config.ACL.Enabled {
+		event.Identity = "unknown"
+		event.Accessor = tokenAccessor(secret)
+	} else if username, _, ok := req.BasicAuth(); ok && len(s.authProviders) != 0 {
+		// The user failed to log in.
+		event.Identity = "unknown:" + username
+	} else {
+		event.Identity = "anonymous"
+	}
+
+	event.Code = 200
+	if err != nil {
+		event.Code = 500
+		if coded, ok := err.(HTTPCodedError); ok {
+			event.Code = coded.Code()
+		}
+		event.Error = err.Error()
+	}
+
+	args := models.AuditEventUpsertRequest{
+		Events: []*models.AuditEvent{event},
+		WriteRequest: models.WriteRequest{
+			Region: s.agent.config.Region,
+		},
+	}
+	var out models.GenericResponse
+	if err := s.agent.RPC("Audit.Record", &args, &out); err != nil {
+		s.logger.Errorf("http: Failed to record the audit event of %v %v: %v", event.Method, event.Path, err)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func (s *HTTPServer) AuditRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	args := models.AuditEventListRequest{}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+	query := req.URL.Query()
+	args.Target = query.Get("target")
+	args.Namespace = query.Get("namespace")
+	var err error
+	if args.Since, err = parseAuditTime(query.Get("since")); err != nil {
+		return nil, CodedErrorf(400, "bad since: %v", err)
+	}
+	if args.Until, err = parseAuditTime(query.Get("until")); err != nil {
+		return nil, CodedErrorf(400, "bad until: %v", err)
+	}
+
+	var out models.AuditEventListResponse
+	if err := s.agent.RPC("Audit.List", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Events == nil {
+		out.Events = make([]*models.AuditEvent, 0)
+	}
+	return out.Events, nil
+}
+
+// parseAuditTime parses a time in RFC3339 or in UnixNano to UnixNano.
+func parseAuditTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UnixNano(), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
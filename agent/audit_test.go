@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAuditedRequest(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		audit  bool
+	}{
+		{"GET", "/v1/jobs", false},
+		{"POST", "/v1/jobs", true},
+		{"POST", "/v1/jobs?simulate=true", false},
+		{"PUT", "/v1/job/job1/pause", true},
+		{"DELETE", "/v1/job/job1", true},
+		{"POST", "/v1/node/node1/evaluate", true},
+		{"POST", "/v1/validate/job", false},
+		{"POST", "/v1/login", false},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if audit := auditedRequest(req); audit != c.audit {
+			t.Errorf("auditedRequest(%v %v) = %v", c.method, c.path, audit)
+		}
+	}
+}
+
+func TestAuditTargets(t *testing.T) {
+	s := &HTTPServer{agent: &Agent{config: &Config{NodeName: "agent1"}}}
+	req, err := http.NewRequest("POST", "/v1/jobs/pause", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := s.auditEvent(req)
+	if event == nil || event.Agent != "agent1" || event.Path != "/v1/jobs/pause" {
+		t.Fatalf("auditEvent() = %+v", event)
+	}
+
+	// Requests not audited are left alone.
+	auditTargets(req, "team-a", "job1")
+
+	req = withAuditEvent(req, event)
+	auditTargets(req, "", "job1")
+	auditTargets(req, "team-a", "job2", "job1")
+	auditTargets(req, "team-b", "job3")
+	if expect := []string{"job1", "job2", "job3"}; !reflect.DeepEqual(event.Targets, expect) {
+		t.Errorf("Targets = %v, expect %v", event.Targets, expect)
+	}
+	if event.Namespace != "team-a" {
+		t.Errorf("Namespace = %q, expect team-a", event.Namespace)
+	}
+}
+
+func TestAuditIdentity(t *testing.T) {
+	token := (&ACLConfig{
+		ManagementTokens: []string{"root"},
+		NamespaceTokens:  map[string][]string{"team-b": {"ab"}, "team-a": {"ab"}},
+	}).resolveToken("ab")
+	if identity := token.identity(); identity != "namespace:team-a,team-b" {
+		t.Errorf("identity() = %q", identity)
+	}
+	if token.accessor != tokenAccessor("ab") || len(token.accessor) != 8 || token.accessor == tokenAccessor("root") {
+		t.Errorf("accessor = %q", token.accessor)
+	}
+}
@@ -20,7 +20,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/actiontech/dtle/internal/crash"
+	"github.com/actiontech/dtle/internal/discover"
 	"github.com/actiontech/dtle/internal/g"
+	"github.com/actiontech/dtle/internal/i18n"
 
 	"github.com/armon/go-metrics"
 	"github.com/armon/go-metrics/prometheus"
@@ -33,6 +36,9 @@ import (
 // gracefulTimeout controls how long we wait before forcefully terminating
 const gracefulTimeout = 5 * time.Second
 
+// crashLogLines is the number of the last lines of the log in a crash report.
+const crashLogLines = 1000
+
 // Command is a Command implementation that runs a Udup agent.
 // The command will not end unless a shutdown message is sent on the
 // ShutdownCh. If two messages are sent on the ShutdownCh it will forcibly
@@ -49,6 +55,14 @@ type Command struct {
 	logger         *ulog.Logger
 	logOutput      io.Writer
 	retryJoinErrCh chan struct{}
+
+	// crashLogs keeps the last lines of the log for the crash reports.
+	crashLogs *crash.LogRing
+
+	// serviceStopCh is closed when the init system asks the server to stop.
+	serviceStopCh chan struct{}
+	// serviceDoneCh is closed when the server is stopping.
+	serviceDoneCh chan struct{}
 }
 
 func (c *Command) readConfig() *Config {
@@ -72,6 +86,7 @@ func (c *Command) readConfig() *Config {
 	// Server-only options
 	flags.IntVar(&cmdConfig.Server.BootstrapExpect, "bootstrap-expect", 0, "")
 	flags.Var((*StringFlag)(&cmdConfig.Server.StartJoin), "join", "")
+	flags.Var((*StringFlag)(&cmdConfig.Server.RetryJoin), "retry-join", "")
 	flags.IntVar(&cmdConfig.Server.RetryMaxAttempts, "retry-max", 0, "")
 	flags.StringVar(&cmdConfig.Server.RetryInterval, "retry-interval", "", "")
 
@@ -88,7 +103,10 @@ func (c *Command) readConfig() *Config {
 	flags.StringVar(&cmdConfig.PidFile, "pid-file", "", "")
 	flags.BoolVar(&cmdConfig.PprofSwitch, "pprof-switch", false, "")
 	flags.Int64Var(&cmdConfig.PprofTime, "pprof-time", 0, "")
+	flags.BoolVar(&cmdConfig.EnableDebug, "enable-debug", false, "")
 	flags.StringVar(&cmdConfig.NodeName, "node", "", "")
+	flags.BoolVar(&cmdConfig.LeaveOnInt, "leave-on-interrupt", false, "")
+	flags.BoolVar(&cmdConfig.LeaveOnTerm, "leave-on-terminate", false, "")
 
 	if err := flags.Parse(c.args); err != nil {
 		return nil
@@ -135,6 +153,12 @@ func (c *Command) readConfig() *Config {
 		config.Server = &ServerConfig{}
 	}
 
+	// Environment variables override config file options
+	if err := config.ApplyEnv(os.Environ()); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration from environment: %s", err))
+		return nil
+	}
+
 	// Merge any CLI options over config file options
 	config = config.Merge(cmdConfig)
 
@@ -156,6 +180,11 @@ func (c *Command) readConfig() *Config {
 	}
 	config.Server.retryInterval = dur
 
+	if err := i18n.ValidateLocale(config.Locale); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error in locale: %s", err))
+		return nil
+	}
+
 	// Check that the server is running in at least one mode.
 	if !(config.Server.Enabled || config.Client.Enabled) {
 		c.Ui.Error("Must specify either manager or agent mode for the server.")
@@ -184,6 +213,11 @@ func (c *Command) readConfig() *Config {
 		return nil
 	}
 
+	if err := config.Server.checkBootstrapExpect(); err != nil {
+		c.Ui.Error(err.Error())
+		return nil
+	}
+
 	return config
 }
 
@@ -223,10 +257,29 @@ func (c *Command) setupLoggers(config *Config) (io.Writer, error) {
 		oFile = os.Stderr
 	}
 
-	c.logOutput = oFile
-	c.logger = ulog.New(oFile, ulog.ParseLevel(config.LogLevel))
-	log.SetOutput(oFile)
-	return oFile, nil
+	c.crashLogs = crash.NewLogRing(crashLogLines)
+	logOutput := io.MultiWriter(oFile, c.crashLogs)
+	c.logOutput = logOutput
+	c.logger = ulog.New(logOutput, ulog.ParseLevel(config.LogLevel))
+	log.SetOutput(logOutput)
+	return logOutput, nil
+}
+
+// setupCrashReporter writes a crash report to the data dir when the server
+// panics, with the last lines of the log, the allocations of the agent, and the
+// config, whose secrets are redacted.
+func (c *Command) setupCrashReporter(config *Config) {
+	reporter := crash.NewReporter(crash.Dir(config.DataDir), c.Version, c.crashLogs)
+	reporter.AddSource("config", func() interface{} {
+		return config
+	})
+	reporter.AddSource("allocs", func() interface{} {
+		if c.agent == nil || c.agent.client == nil {
+			return nil
+		}
+		return c.agent.client.AllocStates()
+	})
+	crash.SetReporter(reporter)
 }
 
 // setupAgent is used to start the agent and various interfaces
@@ -265,6 +318,15 @@ func (c *Command) Run(args []string) int {
 		c.Ui.Error("Error setup logger: " + err.Error())
 		return 1
 	}
+	c.setupCrashReporter(config)
+	defer crash.HandlePanic()
+
+	// Connect to the init system supervising us, if any
+	if err := c.startService(); err != nil {
+		c.logger.Errorf("Error starting service: %v", err)
+		return 1
+	}
+	defer c.serviceStopping()
 
 	// Log config files
 	if len(config.Files) > 0 {
@@ -333,6 +395,7 @@ func (c *Command) Run(args []string) int {
 	}
 	// Output the header that the server has started
 	c.logger.Printf("Dtle server started! Log data will stream in below:\n")
+	c.serviceReady()
 
 	// Start retry join process
 	c.retryJoinErrCh = make(chan struct{})
@@ -355,6 +418,8 @@ WAIT:
 		sig = s
 	case <-c.ShutdownCh:
 		sig = os.Interrupt
+	case <-c.serviceStopCh:
+		sig = syscall.SIGTERM
 	case <-c.retryJoinErrCh:
 		return 1
 	}
@@ -373,6 +438,24 @@ WAIT:
 		goto WAIT
 	}
 
+	// Let the tasks finish the work in flight before leaving
+	if config.shutdownGracePeriod > 0 {
+		drainCh := make(chan struct{})
+		go func() {
+			if err := c.agent.Drain(config.shutdownGracePeriod); err != nil {
+				c.logger.Errorf("Error draining agent: %v", err)
+			}
+			close(drainCh)
+		}()
+
+		// Another signal to exit at once
+		select {
+		case <-signalCh:
+			return 1
+		case <-drainCh:
+		}
+	}
+
 	// Check if we should do a graceful leave
 	graceful := false
 	if sig == os.Interrupt && config.LeaveOnInt {
@@ -472,7 +555,7 @@ func (c *Command) startupJoin(config *Config) error {
 	}
 
 	c.logger.Printf("Joining cluster...")
-	n, err := c.agent.server.Join(config.Server.StartJoin)
+	n, err := c.agent.server.Join(joinAddrs(config.Server.StartJoin))
 	if err != nil {
 		return err
 	}
@@ -481,10 +564,10 @@ func (c *Command) startupJoin(config *Config) error {
 	return nil
 }
 
-// retryJoin is used to handle retrying a join until it succeeds or all retries
-// are exhausted.
+// retryJoin is used to handle retrying a join of the RetryJoin addresses until
+// it succeeds or all retries are exhausted.
 func (c *Command) retryJoin(config *Config) {
-	if len(config.Server.StartJoin) == 0 || !config.Server.Enabled {
+	if len(config.Server.RetryJoin) == 0 || !config.Server.Enabled {
 		return
 	}
 
@@ -492,7 +575,15 @@ func (c *Command) retryJoin(config *Config) {
 
 	attempt := 0
 	for {
-		n, err := c.agent.server.Join(config.Server.StartJoin)
+		// The cloud auto-join strings find the managers again on each attempt.
+		addrs, err := discover.Resolve(config.Server.RetryJoin, c.logger)
+		if err == nil && len(addrs) == 0 {
+			err = fmt.Errorf("no manager found")
+		}
+		var n int
+		if err == nil {
+			n, err = c.agent.server.Join(joinAddrs(addrs))
+		}
 		if err == nil {
 			c.logger.Printf("server: Join completed. Synced with %d initial agents", n)
 			return
@@ -551,6 +642,19 @@ General Options (agents and managers):
     The name of the datacenter this Dtle server is a member of. By
     default this is set to "dc1".
 
+  -enable-debug
+    Expose the pprof endpoints at /debug/pprof/ and the debug bundle API,
+    used by "dtle operator debug", to diagnose e.g. the memory growth of
+    the server.
+
+  -leave-on-interrupt
+    Gracefully leave the cluster on SIGINT: a manager leaves the gossip
+    pool and raft peers, an agent marks its node down. Otherwise the
+    server just exits, and is taken as failed by the others.
+
+  -leave-on-terminate
+    Like -leave-on-interrupt, but on SIGTERM.
+
   -log-level=<level>
     Specify the verbosity level of Dtle's logs. Valid values include
     DEBUG, INFO, and WARN, in decreasing order of verbosity. The
@@ -573,10 +677,26 @@ Manager Options:
     leader election, data replication, and scheduling work onto
     eligible agent nodes.
 
+  -bootstrap-expect=<num>
+    Number of managers of the cluster, to wait for before electing a
+    leader. Required with -join or -retry-join.
+
   -join=<address>
     Address of an server to join at start time. Can be specified
     multiple times.
 
+  -retry-join=<address>
+    Address of an server to join at start time with retries. Can be
+    specified multiple times. A cloud auto-join string such as
+    "provider=aws tag_key=dtle tag_value=manager" finds the servers by
+    the tags of the instances.
+
+  -retry-max=<num>
+    Maximum number of join attempts of -retry-join. Defaults to 3.
+
+  -retry-interval=<dur>
+    Time to wait between join attempts of -retry-join. Defaults to 15s.
+
 Agent Options:
 
   -agent
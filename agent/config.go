@@ -17,7 +17,11 @@ import (
 	"strings"
 	"time"
 
+	sockaddr "github.com/hashicorp/go-sockaddr"
+
+	"github.com/actiontech/dtle/internal"
 	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/i18n"
 )
 
 // This is the default addr to all interfaces.
@@ -39,6 +43,10 @@ type Config struct {
 	// DataDir is the directory to store our store in
 	DataDir string `mapstructure:"data_dir"`
 
+	// PluginDir is the directory of the driver plugins. Defaults to "plugins"
+	// under the data_dir.
+	PluginDir string `mapstructure:"plugin_dir"`
+
 	// PprofSwitch is the witch to open pprof
 	PprofSwitch bool `mapstructure:"pprof_switch"`
 
@@ -83,6 +91,27 @@ type Config struct {
 
 	Network *Network `mapstructure:"network"`
 
+	// HTTP tunes the HTTP API server.
+	HTTP *HTTPConfig `mapstructure:"http"`
+
+	// Limits protects the HTTP API and the RPC from misbehaving clients.
+	Limits *Limits `mapstructure:"limits"`
+
+	// ACL restricts the HTTP API to the configured tokens.
+	ACL *ACLConfig `mapstructure:"acl"`
+
+	// Nats configures an external NATS cluster instead of the embedded nats
+	// streaming server, and the connections of the tasks.
+	Nats *uconf.NatsConfig `mapstructure:"nats"`
+
+	// Vault is the Vault server the keys of the jobs encrypting their traffic
+	// are read from.
+	Vault *uconf.VaultConfig `mapstructure:"vault"`
+
+	// Storage is the object storage the dumps exported by the jobs and the
+	// snapshots of the managers are kept in.
+	Storage *uconf.StorageConfig `mapstructure:"storage"`
+
 	// Profile is used to select a timing profile for Serf. The supported choices
 	// are "wan", "lan", and "local". The default is "lan"
 	Profile string `mapstructure:"profile"`
@@ -93,11 +122,20 @@ type Config struct {
 	// LeaveOnTerm is used to gracefully leave on the terminate signal
 	LeaveOnTerm bool `mapstructure:"leave_on_terminate"`
 
+	// ShutdownGracePeriod is how long to wait for the tasks to finish the work
+	// in flight on the interrupt or terminate signal. "0s" to exit at once.
+	ShutdownGracePeriod string        `mapstructure:"shutdown_grace_period"`
+	shutdownGracePeriod time.Duration `mapstructure:"-"`
+
 	// Consul contains the configuration for the Consul Agent and
 	// parameters necessary to register services, their checks, and
 	// discover the current Udup servers.
 	Consul *uconf.ConsulConfig `mapstructure:"consul"`
 
+	// TLS secures the RPC between the agents with mutual TLS, whose
+	// certificates are issued by the CA built in the servers.
+	TLS *uconf.TLSConfig `mapstructure:"tls"`
+
 	// UdupConfig is used to override the default config.
 	// This is largly used for testing purposes.
 	UdupConfig *uconf.ServerConfig `mapstructure:"-" json:"-"`
@@ -121,6 +159,14 @@ type Config struct {
 	// serves them at the default /ui/ endpoint automatically.
 	EnableUi bool `mapstructure:"ui"`
 
+	// EnableDebug exposes the pprof endpoints at /debug/pprof/, and the
+	// debug bundle API, to the management tokens if ACLs are enabled.
+	EnableDebug bool `mapstructure:"enable_debug"`
+
+	// Locale is the locale of the errors of the HTTP API, en or zh, for the
+	// requests without an Accept-Language supported.
+	Locale string `mapstructure:"locale"`
+
 	// UiDir is the directory containing the Web UI resources.
 	// If provided, the UI endpoints will be enabled.
 	UiDir string `mapstructure:"ui_dir"`
@@ -144,6 +190,30 @@ type ClientConfig struct {
 	// NoHostUUID disables using the host's UUID and will force generation of a
 	// random UUID.
 	NoHostUUID bool `mapstructure:"no_host_uuid"`
+
+	// MaxAllocs limits the number of tasks running on this client. When it is
+	// reached, a higher priority job could preempt tasks of lower priority jobs.
+	// Zero means no limit.
+	MaxAllocs int `mapstructure:"max_allocs"`
+
+	// AllocDir is the directory of the working dirs of the allocations. It
+	// defaults to "alloc" under the data_dir.
+	AllocDir string `mapstructure:"alloc_dir"`
+
+	// AllocDirSizeLimit is the max size in MB of the working dir of an
+	// allocation. The tasks of an allocation exceeding it are failed. Zero
+	// means no limit.
+	AllocDirSizeLimit int `mapstructure:"alloc_dir_size_limit"`
+
+	// CgroupParent is the cgroup v2 dir under which the tasks with resources
+	// in their job get a cgroup limiting their processes. Linux only. Empty
+	// disables cgroups.
+	CgroupParent string `mapstructure:"cgroup_parent"`
+
+	// DriverExecutor runs each task of a builtin driver in a child process of
+	// the dtle binary, so that a panic or an OOM of a task does not take down
+	// the agent and the other tasks.
+	DriverExecutor bool `mapstructure:"driver_executor"`
 }
 
 // ServerConfig is configuration specific to the server mode
@@ -172,21 +242,68 @@ type ServerConfig struct {
 	// processing delays and clock skew before marking a node as "down".
 	HeartbeatGrace string `mapstructure:"heartbeat_grace"`
 
+	// EvalGCThreshold, JobGCThreshold and NodeGCThreshold are how long a
+	// terminal evaluation, a terminal job and a down node are kept before
+	// being garbage collected. GCInterval is the interval of the collection,
+	// "0" disables it.
+	EvalGCThreshold string `mapstructure:"eval_gc_threshold"`
+	JobGCThreshold  string `mapstructure:"job_gc_threshold"`
+	NodeGCThreshold string `mapstructure:"node_gc_threshold"`
+	GCInterval      string `mapstructure:"gc_interval"`
+
+	// AuditGCThreshold is how long the audit events are kept, "0" for ever.
+	AuditGCThreshold string `mapstructure:"audit_gc_threshold"`
+
 	// StartJoin is a list of addresses to attempt to join when the
 	// agent starts. If Serf is unable to communicate with any of these
 	// addresses, then the agent will error and exit.
 	StartJoin []string `mapstructure:"join"`
 
+	// RetryJoin is a list of addresses to join when the agent starts, like
+	// StartJoin, but the join is retried in the background until it succeeds
+	// or RetryMaxAttempts is exhausted.
+	RetryJoin []string `mapstructure:"retry_join"`
+
 	// RetryMaxAttempts specifies the maximum number of times to retry joining a
 	// host on startup. This is useful for cases where we know the node will be
 	// online eventually.
 	RetryMaxAttempts int `mapstructure:"retry_max"`
 
 	// RetryInterval specifies the amount of time to wait in between join
-	// attempts on agent start. The minimum allowed value is 1 second and
-	// the default is 30s.
+	// attempts on agent start. The default is 15s.
 	RetryInterval string        `mapstructure:"retry_interval"`
 	retryInterval time.Duration `mapstructure:"-"`
+
+	// ReconnectInterval is how often Serf tries to reconnect to a failed
+	// manager, and ReconnectTimeout is how long before giving up and reaping
+	// it. TombstoneTimeout is how long a manager which left is remembered.
+	ReconnectInterval string `mapstructure:"reconnect_interval"`
+	ReconnectTimeout  string `mapstructure:"reconnect_timeout"`
+	TombstoneTimeout  string `mapstructure:"tombstone_timeout"`
+
+	// RejoinAfterLeave rejoins the cluster known before a restart even if the
+	// manager left it gracefully. Defaults to true.
+	RejoinAfterLeave *bool `mapstructure:"rejoin_after_leave"`
+
+	// NamespaceQuotas limits the resources of the running jobs of each namespace.
+	NamespaceQuotas map[string]*NamespaceQuota `mapstructure:"namespace_quotas"`
+}
+
+// NamespaceQuota limits the resources of the running jobs of a namespace. A zero
+// limit means no limit.
+type NamespaceQuota struct {
+	// MaxRunningJobs is the max number of running jobs.
+	MaxRunningJobs int `mapstructure:"max_running_jobs"`
+
+	// MaxDumpWorkers is the max sum of the ParallelWorkers of the Dest tasks.
+	MaxDumpWorkers int `mapstructure:"max_dump_workers"`
+
+	// MaxBandwidthMBps is the max sum of the BandwidthLimitMBps of the Src tasks.
+	MaxBandwidthMBps int `mapstructure:"max_bandwidth_mbps"`
+
+	// Queue keeps the new jobs exceeding the quota pending, instead of
+	// rejecting them.
+	Queue bool `mapstructure:"queue"`
 }
 
 type Network struct {
@@ -195,6 +312,82 @@ type Network struct {
 	MaxPayload int `mapstructure:"max_payload"`
 }
 
+// HTTPConfig tunes the HTTP API server. A zero timeout means no timeout.
+type HTTPConfig struct {
+	// DisableCompression disables gzip compression of the responses.
+	DisableCompression bool `mapstructure:"disable_compression"`
+
+	// KeepAlivePeriod is the TCP keep-alive period of the connections.
+	KeepAlivePeriod time.Duration `mapstructure:"keep_alive_period"`
+
+	// ReadHeaderTimeout is the time allowed to read the request headers.
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+
+	// ReadTimeout is the time allowed to read the whole request.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+
+	// WriteTimeout is the time allowed to write the response. It should be
+	// longer than the wait time of the blocking queries.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+
+	// IdleTimeout is how long to keep an idle keep-alive connection.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+
+	// CORSAllowedOrigins are the origins allowed for cross-origin requests,
+	// e.g. the web UI served elsewhere. "*" allows any origin.
+	CORSAllowedOrigins []string `mapstructure:"cors_allowed_origins"`
+
+	// CORSAllowedHeaders are the extra request headers allowed for
+	// cross-origin requests.
+	CORSAllowedHeaders []string `mapstructure:"cors_allowed_headers"`
+}
+
+// Limits protects the HTTP API and the RPC of a manager from misbehaving
+// clients. A zero limit or timeout means none.
+type Limits struct {
+	// HTTPMaxConnsPerClient is the max number of concurrent HTTP connections
+	// of a client IP. The connections over it get a 429 response.
+	HTTPMaxConnsPerClient *int `mapstructure:"http_max_conns_per_client"`
+
+	// HTTPMaxRequestBodySize is the max size in bytes of the body of a HTTP
+	// request, e.g. a job spec. A larger request gets a 413 response.
+	HTTPMaxRequestBodySize *int64 `mapstructure:"http_max_request_body_size"`
+
+	// RPCHandshakeTimeout is the time allowed for a new RPC connection to
+	// send its type and complete its TLS handshake.
+	RPCHandshakeTimeout *time.Duration `mapstructure:"rpc_handshake_timeout"`
+
+	// RPCMaxConnsPerClient is the max number of concurrent RPC connections
+	// of a client IP. The agents multiplex their RPC over one connection.
+	RPCMaxConnsPerClient *int `mapstructure:"rpc_max_conns_per_client"`
+}
+
+// ACLConfig restricts the HTTP API to the requests with a known token, in the
+// X-Udup-Token header, or with the credentials of a user of LDAP or OIDC.
+type ACLConfig struct {
+	// Enabled turns on the token checks.
+	Enabled bool `mapstructure:"enabled"`
+
+	// ManagementTokens are allowed to use the whole API.
+	ManagementTokens []string `mapstructure:"management_tokens"`
+
+	// NamespaceTokens maps a namespace to the tokens allowed to manage its
+	// jobs. These tokens are only allowed to use the job API.
+	NamespaceTokens map[string][]string `mapstructure:"namespace_tokens"`
+
+	// ManagementGroups and NamespaceGroups grant the same to the users of the
+	// groups, authenticated by LDAP or OIDC.
+	ManagementGroups []string            `mapstructure:"management_groups"`
+	NamespaceGroups  map[string][]string `mapstructure:"namespace_groups"`
+
+	// LDAP authenticates the users by the HTTP basic auth.
+	LDAP *uconf.LDAPConfig `mapstructure:"ldap"`
+
+	// OIDC authenticates the users by the ID tokens of an OpenID Connect
+	// provider, and logs the users of the web UI in.
+	OIDC *uconf.OIDCConfig `mapstructure:"oidc"`
+}
+
 type Metric struct {
 	DisableHostname          bool          `mapstructure:"disable_hostname"`
 	UseNodeName              bool          `mapstructure:"use_node_name"`
@@ -247,6 +440,7 @@ type Node struct {
 func DefaultConfig() *Config {
 	return &Config{
 		LogLevel:    "INFO",
+		Locale:      i18n.English,
 		LogFile:     "/var/log/dtle/dtle.log",
 		LogToStdout: false,
 		PprofSwitch: false,
@@ -271,6 +465,7 @@ func DefaultConfig() *Config {
 			Nats: "",
 		},
 		Consul: uconf.DefaultConsulConfig(),
+		TLS:    uconf.DefaultTLSConfig(),
 		Client: &ClientConfig{
 			Enabled:    false,
 			NoHostUUID: true,
@@ -278,6 +473,7 @@ func DefaultConfig() *Config {
 		Server: &ServerConfig{
 			Enabled:          false,
 			StartJoin:        []string{},
+			RetryJoin:        []string{},
 			RetryInterval:    "15s",
 			HeartbeatGrace:   "30s",
 			RetryMaxAttempts: 3,
@@ -289,7 +485,22 @@ func DefaultConfig() *Config {
 		Network: &Network{
 			MaxPayload: DefaultMaxPayload,
 		},
-		DtleSchemaName: "dtle",
+		HTTP: &HTTPConfig{
+			KeepAlivePeriod:   30 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		},
+		Limits: &Limits{
+			HTTPMaxConnsPerClient:  internal.IntToPtr(100),
+			HTTPMaxRequestBodySize: internal.Int64ToPtr(10 * 1024 * 1024),
+			RPCHandshakeTimeout:    internal.TimeToPtr(5 * time.Second),
+			RPCMaxConnsPerClient:   internal.IntToPtr(100),
+		},
+		ACL:                 &ACLConfig{},
+		Nats:                &uconf.NatsConfig{},
+		DtleSchemaName:      "dtle",
+		ShutdownGracePeriod: "30s",
+		shutdownGracePeriod: 30 * time.Second,
 	}
 }
 
@@ -299,6 +510,7 @@ func (c *Config) Listener(proto, addr string, port int) (net.Listener, error) {
 	if addr == "" {
 		addr = c.BindAddr
 	}
+	addr = trimBrackets(addr)
 
 	// Do our own range check to avoid bugs in package net.
 	//
@@ -334,9 +546,18 @@ func (c *Config) Merge(b *Config) *Config {
 	if b.DataDir != "" {
 		result.DataDir = b.DataDir
 	}
+	if b.PluginDir != "" {
+		result.PluginDir = b.PluginDir
+	}
 	if b.EnableUi {
 		result.EnableUi = b.EnableUi
 	}
+	if b.EnableDebug {
+		result.EnableDebug = true
+	}
+	if b.Locale != "" {
+		result.Locale = b.Locale
+	}
 	if b.UiDir != "" {
 		result.UiDir = b.UiDir
 	}
@@ -370,6 +591,10 @@ func (c *Config) Merge(b *Config) *Config {
 	if b.LeaveOnTerm {
 		result.LeaveOnTerm = true
 	}
+	if b.ShutdownGracePeriod != "" {
+		result.ShutdownGracePeriod = b.ShutdownGracePeriod
+		result.shutdownGracePeriod = b.shutdownGracePeriod
+	}
 
 	// Apply the metric config
 	if result.Metric == nil && b.Metric != nil {
@@ -387,6 +612,54 @@ func (c *Config) Merge(b *Config) *Config {
 		result.Network = result.Network.Merge(b.Network)
 	}
 
+	// Apply the http config
+	if result.HTTP == nil && b.HTTP != nil {
+		httpConfig := *b.HTTP
+		result.HTTP = &httpConfig
+	} else if b.HTTP != nil {
+		result.HTTP = result.HTTP.Merge(b.HTTP)
+	}
+
+	// Apply the limits
+	if result.Limits == nil && b.Limits != nil {
+		limits := *b.Limits
+		result.Limits = &limits
+	} else if b.Limits != nil {
+		result.Limits = result.Limits.Merge(b.Limits)
+	}
+
+	// Apply the acl config
+	if result.ACL == nil && b.ACL != nil {
+		aclConfig := *b.ACL
+		result.ACL = &aclConfig
+	} else if b.ACL != nil {
+		result.ACL = result.ACL.Merge(b.ACL)
+	}
+
+	// Apply the nats config
+	if result.Nats == nil && b.Nats != nil {
+		natsConfig := *b.Nats
+		result.Nats = &natsConfig
+	} else if b.Nats != nil {
+		result.Nats = result.Nats.Merge(b.Nats)
+	}
+
+	// Apply the vault config
+	if result.Vault == nil && b.Vault != nil {
+		vaultConfig := *b.Vault
+		result.Vault = &vaultConfig
+	} else if b.Vault != nil {
+		result.Vault = result.Vault.Merge(b.Vault)
+	}
+
+	// Apply the storage config
+	if result.Storage == nil && b.Storage != nil {
+		storageConfig := *b.Storage
+		result.Storage = &storageConfig
+	} else if b.Storage != nil {
+		result.Storage = result.Storage.Merge(b.Storage)
+	}
+
 	// Apply the client config
 	if result.Client == nil && b.Client != nil {
 		client := *b.Client
@@ -434,12 +707,21 @@ func (c *Config) Merge(b *Config) *Config {
 		result.Consul = result.Consul.Merge(b.Consul)
 	}
 
+	// Apply the TLS Configuration
+	if result.TLS == nil && b.TLS != nil {
+		result.TLS = b.TLS.Copy()
+	} else if b.TLS != nil {
+		result.TLS = result.TLS.Merge(b.TLS)
+	}
+
 	// Merge config files lists
 	result.Files = append(result.Files, b.Files...)
 
-	// Add the http API response header map values
-	if result.HTTPAPIResponseHeaders == nil {
-		result.HTTPAPIResponseHeaders = make(map[string]string)
+	// Add the http API response header map values. The map is copied so the
+	// merged configs are left unchanged.
+	result.HTTPAPIResponseHeaders = make(map[string]string)
+	for k, v := range c.HTTPAPIResponseHeaders {
+		result.HTTPAPIResponseHeaders[k] = v
 	}
 	for k, v := range b.HTTPAPIResponseHeaders {
 		result.HTTPAPIResponseHeaders[k] = v
@@ -455,6 +737,11 @@ func (c *Config) Merge(b *Config) *Config {
 // normalizeAddrs normalizes Addresses and AdvertiseAddrs to always be
 // initialized and have sane defaults.
 func (c *Config) normalizeAddrs() error {
+	if err := c.parseAddrTemplates(); err != nil {
+		return err
+	}
+
+	c.BindAddr = trimBrackets(c.BindAddr)
 	c.Addresses.HTTP = normalizeBind(c.Addresses.HTTP, c.BindAddr)
 	c.Addresses.RPC = normalizeBind(c.Addresses.RPC, c.BindAddr)
 	c.Addresses.Serf = normalizeBind(c.Addresses.Serf, c.BindAddr)
@@ -498,23 +785,29 @@ func (c *Config) normalizeAddrs() error {
 
 // normalizeBind returns a normalized bind address.
 //
-// If addr is set it is used, if not the default bind address is used.
+// If addr is set it is used, if not the default bind address is used. An IPv6
+// literal is returned without brackets.
 func normalizeBind(addr, bind string) string {
 	if addr == "" {
 		return bind
 	}
-	return addr
+	return trimBrackets(addr)
 }
 
 // normalizeAdvertise returns a normalized advertise address.
 //
 // If addr is set, it is used and the default port is appended if no port is
-// set.
+// set. An IPv6 literal is given as "[::1]:8190", or "[::1]" or "::1" without a
+// port.
 //
 // If addr is not set and bind is a valid address, the returned string is the
 // bind+port.
 func normalizeAdvertise(addr string, bind string, defport int) (string, error) {
 	if addr != "" {
+		if host := trimBrackets(addr); isIPLiteral(host) {
+			return net.JoinHostPort(host, strconv.Itoa(defport)), nil
+		}
+
 		// Default to using manually configured address
 		_, _, err := net.SplitHostPort(addr)
 		if err != nil {
@@ -528,8 +821,21 @@ func normalizeAdvertise(addr string, bind string, defport int) (string, error) {
 		return addr, nil
 	}
 
-	if bind == "0.0.0.0" {
-		return "", fmt.Errorf("advertise addr is empty and bind addr is not suitable for advertise")
+	if isUnspecifiedAddr(bind) {
+		// Auto-detect the private address of the host
+		ip, err := sockaddr.GetPrivateIP()
+		if err != nil {
+			return "", fmt.Errorf("Error detecting private address to advertise: %v", err)
+		}
+		if ip == "" && bind == "::" {
+			if ip, err = getGlobalIPv6(); err != nil {
+				return "", fmt.Errorf("Error detecting IPv6 address to advertise: %v", err)
+			}
+		}
+		if ip == "" {
+			return "", fmt.Errorf("advertise addr is empty and bind addr is not suitable for advertise")
+		}
+		return net.JoinHostPort(ip, strconv.Itoa(defport)), nil
 	}
 
 	return net.JoinHostPort(bind, strconv.Itoa(defport)), nil
@@ -559,6 +865,21 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 	if b.HeartbeatGrace != "" {
 		result.HeartbeatGrace = b.HeartbeatGrace
 	}
+	if b.EvalGCThreshold != "" {
+		result.EvalGCThreshold = b.EvalGCThreshold
+	}
+	if b.JobGCThreshold != "" {
+		result.JobGCThreshold = b.JobGCThreshold
+	}
+	if b.NodeGCThreshold != "" {
+		result.NodeGCThreshold = b.NodeGCThreshold
+	}
+	if b.GCInterval != "" {
+		result.GCInterval = b.GCInterval
+	}
+	if b.AuditGCThreshold != "" {
+		result.AuditGCThreshold = b.AuditGCThreshold
+	}
 	if b.RetryMaxAttempts != 0 {
 		result.RetryMaxAttempts = b.RetryMaxAttempts
 	}
@@ -566,6 +887,21 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 		result.RetryInterval = b.RetryInterval
 		result.retryInterval = b.retryInterval
 	}
+	if b.ReconnectInterval != "" {
+		result.ReconnectInterval = b.ReconnectInterval
+	}
+	if b.ReconnectTimeout != "" {
+		result.ReconnectTimeout = b.ReconnectTimeout
+	}
+	if b.TombstoneTimeout != "" {
+		result.TombstoneTimeout = b.TombstoneTimeout
+	}
+	if b.RejoinAfterLeave != nil {
+		result.RejoinAfterLeave = b.RejoinAfterLeave
+	}
+	if len(b.NamespaceQuotas) != 0 {
+		result.NamespaceQuotas = b.NamespaceQuotas
+	}
 	// Add the schedulers
 	result.EnabledSchedulers = append(result.EnabledSchedulers, b.EnabledSchedulers...)
 
@@ -574,15 +910,32 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 	result.StartJoin = append(result.StartJoin, a.StartJoin...)
 	result.StartJoin = append(result.StartJoin, b.StartJoin...)
 
+	// Copy the retry join addresses
+	result.RetryJoin = make([]string, 0, len(a.RetryJoin)+len(b.RetryJoin))
+	result.RetryJoin = append(result.RetryJoin, a.RetryJoin...)
+	result.RetryJoin = append(result.RetryJoin, b.RetryJoin...)
+
 	if b.BootstrapExpect > 0 {
 		result.BootstrapExpect = b.BootstrapExpect
-	} else {
-		result.BootstrapExpect = len(result.StartJoin)
 	}
 
 	return &result
 }
 
+// checkBootstrapExpect returns an error if the manager joins others without
+// bootstrap_expect. It is not derived from join and retry_join, whose entries
+// are not one per manager, e.g. a cloud auto-join string, and a manager
+// expecting only itself would bootstrap a cluster of its own.
+func (s *ServerConfig) checkBootstrapExpect() error {
+	if !s.Enabled || s.BootstrapExpect > 0 {
+		return nil
+	}
+	if len(s.StartJoin) > 0 || len(s.RetryJoin) > 0 {
+		return fmt.Errorf("bootstrap_expect must be set with join or retry_join")
+	}
+	return nil
+}
+
 // Merge is used to merge two client configs together
 func (a *ClientConfig) Merge(b *ClientConfig) *ClientConfig {
 	result := *a
@@ -596,6 +949,21 @@ func (a *ClientConfig) Merge(b *ClientConfig) *ClientConfig {
 	if b.NoHostUUID {
 		result.NoHostUUID = b.NoHostUUID
 	}
+	if b.MaxAllocs != 0 {
+		result.MaxAllocs = b.MaxAllocs
+	}
+	if b.AllocDir != "" {
+		result.AllocDir = b.AllocDir
+	}
+	if b.AllocDirSizeLimit != 0 {
+		result.AllocDirSizeLimit = b.AllocDirSizeLimit
+	}
+	if b.CgroupParent != "" {
+		result.CgroupParent = b.CgroupParent
+	}
+	if b.DriverExecutor {
+		result.DriverExecutor = b.DriverExecutor
+	}
 
 	// Add the servers
 	result.Servers = append(result.Servers, b.Servers...)
@@ -612,6 +980,86 @@ func (a *Network) Merge(b *Network) *Network {
 	return &result
 }
 
+// Merge is used to merge two http configs together
+func (a *HTTPConfig) Merge(b *HTTPConfig) *HTTPConfig {
+	result := *a
+
+	if b.DisableCompression {
+		result.DisableCompression = true
+	}
+	if b.KeepAlivePeriod != 0 {
+		result.KeepAlivePeriod = b.KeepAlivePeriod
+	}
+	if b.ReadHeaderTimeout != 0 {
+		result.ReadHeaderTimeout = b.ReadHeaderTimeout
+	}
+	if b.ReadTimeout != 0 {
+		result.ReadTimeout = b.ReadTimeout
+	}
+	if b.WriteTimeout != 0 {
+		result.WriteTimeout = b.WriteTimeout
+	}
+	if b.IdleTimeout != 0 {
+		result.IdleTimeout = b.IdleTimeout
+	}
+	if len(b.CORSAllowedOrigins) != 0 {
+		result.CORSAllowedOrigins = b.CORSAllowedOrigins
+	}
+	if len(b.CORSAllowedHeaders) != 0 {
+		result.CORSAllowedHeaders = b.CORSAllowedHeaders
+	}
+	return &result
+}
+
+// Merge merges two limits, the ones set in b override a.
+func (a *Limits) Merge(b *Limits) *Limits {
+	result := *a
+
+	if b.HTTPMaxConnsPerClient != nil {
+		result.HTTPMaxConnsPerClient = b.HTTPMaxConnsPerClient
+	}
+	if b.HTTPMaxRequestBodySize != nil {
+		result.HTTPMaxRequestBodySize = b.HTTPMaxRequestBodySize
+	}
+	if b.RPCHandshakeTimeout != nil {
+		result.RPCHandshakeTimeout = b.RPCHandshakeTimeout
+	}
+	if b.RPCMaxConnsPerClient != nil {
+		result.RPCMaxConnsPerClient = b.RPCMaxConnsPerClient
+	}
+	return &result
+}
+
+// Merge is used to merge two acl configs together
+func (a *ACLConfig) Merge(b *ACLConfig) *ACLConfig {
+	result := *a
+
+	if b.Enabled {
+		result.Enabled = true
+	}
+	if len(b.ManagementTokens) != 0 {
+		result.ManagementTokens = b.ManagementTokens
+	}
+	if len(b.NamespaceTokens) != 0 {
+		result.NamespaceTokens = b.NamespaceTokens
+	}
+	if len(b.ManagementGroups) != 0 {
+		result.ManagementGroups = b.ManagementGroups
+	}
+	if len(b.NamespaceGroups) != 0 {
+		result.NamespaceGroups = b.NamespaceGroups
+	}
+	if b.LDAP != nil {
+		ldapConfig := *b.LDAP
+		result.LDAP = &ldapConfig
+	}
+	if b.OIDC != nil {
+		oidcConfig := *b.OIDC
+		result.OIDC = &oidcConfig
+	}
+	return &result
+}
+
 // Merge is used to merge two metric configs together
 func (a *Metric) Merge(b *Metric) *Metric {
 	result := *a
@@ -619,6 +1067,9 @@ func (a *Metric) Merge(b *Metric) *Metric {
 	if b.DisableHostname {
 		result.DisableHostname = true
 	}
+	if b.UseNodeName {
+		result.UseNodeName = true
+	}
 	if b.CollectionInterval != "" {
 		result.CollectionInterval = b.CollectionInterval
 	}
@@ -713,8 +1164,10 @@ func LoadConfig(path string) (*Config, error) {
 	return config, nil
 }
 
-// LoadConfigDir loads all the configurations in the given directory
-// in alphabetical order.
+// LoadConfigDir loads all the configurations (*.hcl, *.json and *.conf) in the
+// given directory, and merges them in lexical order of the file names. A later
+// file overrides the values set by earlier ones, while lists (e.g. managers and
+// join) are appended.
 func LoadConfigDir(dir string) (*Config, error) {
 	f, err := os.Open(dir)
 	if err != nil {
@@ -753,6 +1206,8 @@ func LoadConfigDir(dir string) (*Config, error) {
 				skip = false
 			} else if strings.HasSuffix(name, ".json") {
 				skip = false
+			} else if strings.HasSuffix(name, ".conf") {
+				skip = false
 			}
 			if skip || isTemporaryFile(name) {
 				continue
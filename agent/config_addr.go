@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	sockaddr "github.com/hashicorp/go-sockaddr"
+)
+
+// addrTemplateFuncs are the functions available in address templates, e.g.
+// `{{ GetPrivateIP }}` or `{{ GetInterfaceIP "eth0" }}`.
+var addrTemplateFuncs = template.FuncMap{
+	"GetPrivateIP":   sockaddr.GetPrivateIP,
+	"GetPublicIP":    sockaddr.GetPublicIP,
+	"GetInterfaceIP": sockaddr.GetInterfaceIP,
+}
+
+// parseAddrTemplate renders an address which may be a go-sockaddr style
+// template. An address without "{{" is returned as is.
+func parseAddrTemplate(addr string) (string, error) {
+	if !strings.Contains(addr, "{{") {
+		return addr, nil
+	}
+
+	tmpl, err := template.New("addr").Funcs(addrTemplateFuncs).Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing address template %q: %v", addr, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("Error executing address template %q: %v", addr, err)
+	}
+
+	result := strings.TrimSpace(buf.String())
+	if result == "" {
+		return "", fmt.Errorf("No address found by template %q", addr)
+	}
+	if strings.ContainsAny(result, " \t") {
+		return "", fmt.Errorf("Multiple addresses found by template %q: %v", addr, result)
+	}
+	return result, nil
+}
+
+// parseAddrTemplates renders the bind and advertise address templates.
+func (c *Config) parseAddrTemplates() error {
+	addrs := []*string{
+		&c.BindAddr,
+		&c.Addresses.HTTP, &c.Addresses.RPC, &c.Addresses.Serf, &c.Addresses.Nats,
+		&c.AdvertiseAddrs.HTTP, &c.AdvertiseAddrs.RPC, &c.AdvertiseAddrs.Serf, &c.AdvertiseAddrs.Nats,
+	}
+	for _, addr := range addrs {
+		result, err := parseAddrTemplate(*addr)
+		if err != nil {
+			return err
+		}
+		*addr = result
+	}
+	return nil
+}
+
+// isIPLiteral returns whether host is an IP address, an IPv6 one with a zone or
+// not, e.g. "fe80::1%eth0".
+func isIPLiteral(host string) bool {
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		if strings.ContainsAny(host[i:], "[]:") {
+			return false
+		}
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
+// trimBrackets returns the host of an IPv6 literal given in brackets, e.g.
+// "[::1]", without them, to be joined with a port by net.JoinHostPort.
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// isUnspecifiedAddr returns whether a bind address is on all the addresses of
+// the host, "0.0.0.0" or "::".
+func isUnspecifiedAddr(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// joinAddrs returns the addresses to join with the IPv6 literals in brackets.
+// serf takes what follows the last ":" of an address as its port, so "::1"
+// has to be given as "[::1]".
+func joinAddrs(addrs []string) []string {
+	result := make([]string, len(addrs))
+	for i, addr := range addrs {
+		if isIPLiteral(addr) && strings.Contains(addr, ":") {
+			addr = "[" + addr + "]"
+		}
+		result[i] = addr
+	}
+	return result
+}
+
+// getGlobalIPv6 returns a global unicast IPv6 address of the host, "" if there
+// is none. It is advertised with a bind address of "::" if the host has no
+// private IPv4 address, e.g. an IPv6 only host.
+func getGlobalIPv6() (string, error) {
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, ifAddr := range ifAddrs {
+		ipNet, ok := ifAddr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil {
+			continue
+		}
+		if ipNet.IP.IsGlobalUnicast() {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseAddrTemplate(t *testing.T) {
+	getPrivateIP, getPublicIP := addrTemplateFuncs["GetPrivateIP"], addrTemplateFuncs["GetPublicIP"]
+	defer func() {
+		addrTemplateFuncs["GetPrivateIP"] = getPrivateIP
+		addrTemplateFuncs["GetPublicIP"] = getPublicIP
+	}()
+	addrTemplateFuncs["GetPrivateIP"] = func() (string, error) {
+		return "10.1.2.3", nil
+	}
+	addrTemplateFuncs["GetPublicIP"] = func() (string, error) {
+		return "1.2.3.4 5.6.7.8", nil
+	}
+
+	tests := []struct {
+		name    string
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "10.0.0.1", "10.0.0.1", false},
+		{"empty", "", "", false},
+		{"private", `{{ GetPrivateIP }}`, "10.1.2.3", false},
+		{"multiple", `{{ GetPublicIP }}`, "", true},
+		{"no match", `{{ GetInterfaceIP "^no-such-if$" }}`, "", true},
+		{"bad template", `{{ GetPrivateIP `, "", true},
+		{"unknown func", `{{ GetNothing }}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAddrTemplate(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseAddrTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseAddrTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_normalizeAdvertiseIPv6(t *testing.T) {
+	tests := []struct {
+		addr    string
+		bind    string
+		want    string
+		wantErr bool
+	}{
+		{"::1", "", "[::1]:8190", false},
+		{"[::1]", "", "[::1]:8190", false},
+		{"[::1]:4646", "", "[::1]:4646", false},
+		{"fe80::1%eth0", "", "[fe80::1%eth0]:8190", false},
+		{"", "::1", "[::1]:8190", false},
+		{"", "fe80::1%eth0", "[fe80::1%eth0]:8190", false},
+		{"::1:4646:", "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeAdvertise(tt.addr, tt.bind, 8190)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q %q: error %v, wantErr %v", tt.addr, tt.bind, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q %q: got %v, want %v", tt.addr, tt.bind, got, tt.want)
+		}
+	}
+
+	if _, err := normalizeAdvertise("", "::", 8190); err != nil {
+		t.Logf("no address to advertise of \"::\" on this host: %v", err)
+	}
+}
+
+func Test_normalizeAddrsIPv6(t *testing.T) {
+	c := DefaultConfig()
+	c.BindAddr = "[::1]"
+	c.Addresses.RPC = "[::1]"
+	c.AdvertiseAddrs.Serf = "::1"
+	c.Server.Enabled = true
+	if err := c.normalizeAddrs(); err != nil {
+		t.Fatal(err)
+	}
+	if c.normalizedAddrs.HTTP != "[::1]:8190" || c.normalizedAddrs.RPC != "[::1]:8191" {
+		t.Errorf("got %+v", c.normalizedAddrs)
+	}
+	if c.AdvertiseAddrs.HTTP != "[::1]:8190" || c.AdvertiseAddrs.Serf != "[::1]:8192" {
+		t.Errorf("got %+v", c.AdvertiseAddrs)
+	}
+
+	ln, err := c.Listener("tcp", "[::1]", 0)
+	if err != nil {
+		t.Skipf("no IPv6 on this host: %v", err)
+	}
+	ln.Close()
+}
+
+func Test_joinAddrs(t *testing.T) {
+	got := joinAddrs([]string{"::1", "[::1]:8192", "10.0.0.1", "10.0.0.1:8192", "manager.local"})
+	want := []string{"[::1]", "[::1]:8192", "10.0.0.1", "10.0.0.1:8192", "manager.local"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
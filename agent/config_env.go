@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables to set the config.
+const EnvPrefix = "UDUP_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv sets the config fields from the environment variables, given as
+// "key=value" like os.Environ(). The variable of a field is EnvPrefix followed
+// by the upper-cased config keys joined with "_", e.g. UDUP_BIND_ADDR,
+// UDUP_PORTS_HTTP or UDUP_AGENT_ENABLED. Lists are separated by ",", and maps
+// are given as "k1=v1,k2=v2".
+func (c *Config) ApplyEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	if err := applyEnvStruct(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), env); err != nil {
+		return err
+	}
+
+	// Durations parsed along with the config file.
+	if _, ok := env[EnvPrefix+"SHUTDOWN_GRACE_PERIOD"]; ok {
+		dur, err := time.ParseDuration(c.ShutdownGracePeriod)
+		if err != nil {
+			return fmt.Errorf("Error parsing %sSHUTDOWN_GRACE_PERIOD: %v", EnvPrefix, err)
+		}
+		c.shutdownGracePeriod = dur
+	}
+	if _, ok := env[EnvPrefix+"METRIC_COLLECTION_INTERVAL"]; ok {
+		dur, err := time.ParseDuration(c.Metric.CollectionInterval)
+		if err != nil {
+			return fmt.Errorf("Error parsing %sMETRIC_COLLECTION_INTERVAL: %v", EnvPrefix, err)
+		}
+		c.Metric.collectionInterval = dur
+	}
+	return nil
+}
+
+func applyEnvStruct(v reflect.Value, prefix string, env map[string]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("mapstructure")
+		if f.PkgPath != "" || tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		// Nested blocks.
+		if f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+			if !hasEnvPrefix(env, name+"_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(f.Type.Elem()))
+			}
+			if err := applyEnvStruct(fv.Elem(), name, env); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := env[name]
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, value); err != nil {
+			return fmt.Errorf("Error parsing %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func hasEnvPrefix(env map[string]string, prefix string) bool {
+	for k := range env {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setEnvValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %v", v.Type())
+		}
+		var list []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %v", v.Type())
+		}
+		m := make(map[string]string)
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			kv := strings.SplitN(s, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("expect k=v, got %q", s)
+			}
+			m[kv[0]] = kv[1]
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
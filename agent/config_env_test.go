@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	c := DefaultConfig()
+	c.LeaveOnTerm = true
+	err := c.ApplyEnv([]string{
+		"PATH=/bin",
+		"UDUP_BIND_ADDR=127.0.0.1",
+		"UDUP_PORTS_HTTP=18190",
+		"UDUP_AGENT_ENABLED=true",
+		"UDUP_AGENT_MANAGERS=10.0.0.1:8191, 10.0.0.2:8191",
+		"UDUP_LEAVE_ON_TERMINATE=false",
+		"UDUP_CONSUL_ADDRESS=127.0.0.1:8500",
+		"UDUP_CONSUL_TIMEOUT=5s",
+		"UDUP_CONSUL_SSL=true",
+		"UDUP_SHUTDOWN_GRACE_PERIOD=1m",
+		"UDUP_HTTP_API_RESPONSE_HEADERS=X-A=1,X-B=2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.BindAddr != "127.0.0.1" {
+		t.Errorf("BindAddr = %v", c.BindAddr)
+	}
+	if c.Ports.HTTP != 18190 || c.Ports.RPC != 8191 {
+		t.Errorf("Ports = %+v", c.Ports)
+	}
+	if !c.Client.Enabled || !c.Client.NoHostUUID {
+		t.Errorf("Client = %+v", c.Client)
+	}
+	if !reflect.DeepEqual(c.Client.Servers, []string{"10.0.0.1:8191", "10.0.0.2:8191"}) {
+		t.Errorf("Client.Servers = %v", c.Client.Servers)
+	}
+	if c.LeaveOnTerm {
+		t.Errorf("LeaveOnTerm should be overridden")
+	}
+	if c.Consul.Addr != "127.0.0.1:8500" || c.Consul.Timeout != 5*time.Second ||
+		c.Consul.EnableSSL == nil || !*c.Consul.EnableSSL {
+		t.Errorf("Consul = %+v", c.Consul)
+	}
+	if c.shutdownGracePeriod != time.Minute {
+		t.Errorf("shutdownGracePeriod = %v", c.shutdownGracePeriod)
+	}
+	if !reflect.DeepEqual(c.HTTPAPIResponseHeaders, map[string]string{"X-A": "1", "X-B": "2"}) {
+		t.Errorf("HTTPAPIResponseHeaders = %v", c.HTTPAPIResponseHeaders)
+	}
+
+	if err := DefaultConfig().ApplyEnv([]string{"UDUP_PORTS_HTTP=abc"}); err == nil {
+		t.Errorf("ApplyEnv() should fail on a bad int")
+	}
+}
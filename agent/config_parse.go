@@ -8,6 +8,7 @@ package agent
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"github.com/hashicorp/hcl/hcl/ast"
 	"github.com/mitchellh/mapstructure"
 
+	"github.com/actiontech/dtle/internal"
 	"github.com/actiontech/dtle/internal/config"
 )
 
@@ -45,6 +47,9 @@ func ParseConfigFile(path string) (*Config, error) {
 
 // ParseConfig parses the config from the given io.Reader.
 //
+// The config is HCL version 1 or JSON, HCL2 is not supported. Unknown keys are
+// rejected by checkHCLKeys.
+//
 // Due to current internal limitations, the entire contents of the
 // io.Reader will be copied into memory first before parsing.
 func ParseConfig(r io.Reader) (*Config, error) {
@@ -82,6 +87,7 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		"datacenter",
 		"name",
 		"data_dir",
+		"plugin_dir",
 		"ui",
 		"ui_dir",
 		"log_level",
@@ -89,6 +95,8 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		"log_file",
 		"pprof_switch",
 		"pprof_time",
+		"enable_debug",
+		"locale",
 		"pid_file",
 		"bind_addr",
 		"profile",
@@ -99,9 +107,17 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		"manager",
 		"metric",
 		"network",
+		"http",
+		"limits",
+		"acl",
+		"nats",
+		"vault",
+		"storage",
 		"leave_on_interrupt",
 		"leave_on_terminate",
+		"shutdown_grace_period",
 		"consul",
+		"tls",
 		"http_api_response_headers",
 		"dtle_schema_name",
 	}
@@ -121,13 +137,27 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 	delete(m, "manager")
 	delete(m, "metric")
 	delete(m, "network")
+	delete(m, "http")
+	delete(m, "limits")
+	delete(m, "acl")
+	delete(m, "nats")
+	delete(m, "vault")
+	delete(m, "storage")
 	delete(m, "consul")
+	delete(m, "tls")
 	delete(m, "http_api_response_headers")
 
 	// Decode the rest
 	if err := mapstructure.WeakDecode(m, result); err != nil {
 		return err
 	}
+	if result.ShutdownGracePeriod != "" {
+		if dur, err := time.ParseDuration(result.ShutdownGracePeriod); err != nil {
+			return fmt.Errorf("error parsing value of %q: %v", "shutdown_grace_period", err)
+		} else {
+			result.shutdownGracePeriod = dur
+		}
+	}
 
 	// Parse ports
 	if o := list.Filter("ports"); len(o.Items) > 0 {
@@ -177,6 +207,42 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	if o := list.Filter("http"); len(o.Items) > 0 {
+		if err := parseHTTP(&result.HTTP, o); err != nil {
+			return multierror.Prefix(err, "http ->")
+		}
+	}
+
+	if o := list.Filter("limits"); len(o.Items) > 0 {
+		if err := parseLimits(&result.Limits, o); err != nil {
+			return multierror.Prefix(err, "limits ->")
+		}
+	}
+
+	if o := list.Filter("acl"); len(o.Items) > 0 {
+		if err := parseACL(&result.ACL, o); err != nil {
+			return multierror.Prefix(err, "acl ->")
+		}
+	}
+
+	if o := list.Filter("nats"); len(o.Items) > 0 {
+		if err := parseNats(&result.Nats, o); err != nil {
+			return multierror.Prefix(err, "nats ->")
+		}
+	}
+
+	if o := list.Filter("vault"); len(o.Items) > 0 {
+		if err := parseVault(&result.Vault, o); err != nil {
+			return multierror.Prefix(err, "vault ->")
+		}
+	}
+
+	if o := list.Filter("storage"); len(o.Items) > 0 {
+		if err := parseStorage(&result.Storage, o); err != nil {
+			return multierror.Prefix(err, "storage ->")
+		}
+	}
+
 	// Parse the consul config
 	if o := list.Filter("consul"); len(o.Items) > 0 {
 		if err := parseConsulConfig(&result.Consul, o); err != nil {
@@ -184,6 +250,13 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse the tls config
+	if o := list.Filter("tls"); len(o.Items) > 0 {
+		if err := parseTLSConfig(&result.TLS, o); err != nil {
+			return multierror.Prefix(err, "tls ->")
+		}
+	}
+
 	// Parse out http_api_response_headers fields. These are in HCL as a list so
 	// we need to iterate over them and merge them.
 	if headersO := list.Filter("http_api_response_headers"); len(headersO.Items) > 0 {
@@ -323,6 +396,11 @@ func parseClient(result **ClientConfig, list *ast.ObjectList) error {
 		"managers",
 		"stats",
 		"no_host_uuid",
+		"max_allocs",
+		"alloc_dir",
+		"alloc_dir_size_limit",
+		"cgroup_parent",
+		"driver_executor",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -379,9 +457,20 @@ func parseServer(result **ServerConfig, list *ast.ObjectList) error {
 		"num_schedulers",
 		"enabled_schedulers",
 		"heartbeat_grace",
+		"eval_gc_threshold",
+		"job_gc_threshold",
+		"node_gc_threshold",
+		"gc_interval",
+		"audit_gc_threshold",
 		"join",
+		"retry_join",
 		"retry_max",
 		"retry_interval",
+		"reconnect_interval",
+		"reconnect_timeout",
+		"tombstone_timeout",
+		"rejoin_after_leave",
+		"namespace_quotas",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -392,11 +481,19 @@ func parseServer(result **ServerConfig, list *ast.ObjectList) error {
 		return err
 	}
 
+	delete(m, "namespace_quotas")
+
 	var config ServerConfig
 	if err := mapstructure.WeakDecode(m, &config); err != nil {
 		return err
 	}
 
+	if o := listVal.Filter("namespace_quotas"); len(o.Items) > 0 {
+		if err := parseNamespaceQuotas(&config.NamespaceQuotas, o); err != nil {
+			return multierror.Prefix(err, "namespace_quotas ->")
+		}
+	}
+
 	*result = &config
 	return nil
 }
@@ -473,6 +570,373 @@ func parseNetwork(result **Network, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseHTTP(result **HTTPConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'http' block allowed")
+	}
+
+	// Get our http object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"disable_compression",
+		"keep_alive_period",
+		"read_header_timeout",
+		"read_timeout",
+		"write_timeout",
+		"idle_timeout",
+		"cors_allowed_origins",
+		"cors_allowed_headers",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var httpConfig HTTPConfig
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &httpConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	*result = &httpConfig
+	return nil
+}
+
+func parseLimits(result **Limits, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'limits' block allowed")
+	}
+
+	// Get our limits object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"http_max_conns_per_client",
+		"http_max_request_body_size",
+		"rpc_handshake_timeout",
+		"rpc_max_conns_per_client",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var limits Limits
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &limits,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	*result = &limits
+	return nil
+}
+
+func parseNamespaceQuotas(result *map[string]*NamespaceQuota, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'namespace_quotas' block allowed")
+	}
+
+	// Get our namespace_quotas object
+	listVal, ok := list.Items[0].Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("namespace_quotas value: should be an object")
+	}
+
+	quotas := make(map[string]*NamespaceQuota)
+	for _, item := range listVal.List.Items {
+		namespace := item.Keys[0].Token.Value().(string)
+
+		// Check for invalid keys
+		valid := []string{
+			"max_running_jobs",
+			"max_dump_workers",
+			"max_bandwidth_mbps",
+			"queue",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, namespace+" ->")
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		var quota NamespaceQuota
+		if err := mapstructure.WeakDecode(m, &quota); err != nil {
+			return err
+		}
+		quotas[namespace] = &quota
+	}
+	*result = quotas
+	return nil
+}
+
+func parseACL(result **ACLConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'acl' block allowed")
+	}
+
+	// Get our acl object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"enabled",
+		"management_tokens",
+		"namespace_tokens",
+		"management_groups",
+		"namespace_groups",
+		"ldap",
+		"oidc",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+	delete(m, "ldap")
+	delete(m, "oidc")
+
+	var aclConfig ACLConfig
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           &aclConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+
+	if ot, ok := listVal.(*ast.ObjectType); ok {
+		if o := ot.List.Filter("ldap"); len(o.Items) > 0 {
+			if err := parseLDAP(&aclConfig.LDAP, o); err != nil {
+				return multierror.Prefix(err, "ldap ->")
+			}
+		}
+		if o := ot.List.Filter("oidc"); len(o.Items) > 0 {
+			if err := parseOIDC(&aclConfig.OIDC, o); err != nil {
+				return multierror.Prefix(err, "oidc ->")
+			}
+		}
+	}
+	*result = &aclConfig
+	return nil
+}
+
+func parseLDAP(result **config.LDAPConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'ldap' block allowed")
+	}
+
+	// Get our ldap object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"address",
+		"tls",
+		"ca_file",
+		"insecure_skip_verify",
+		"user_dn",
+		"group_attribute",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var ldapConfig config.LDAPConfig
+	if err := mapstructure.WeakDecode(m, &ldapConfig); err != nil {
+		return err
+	}
+	*result = &ldapConfig
+	return nil
+}
+
+func parseOIDC(result **config.OIDCConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'oidc' block allowed")
+	}
+
+	// Get our oidc object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"issuer",
+		"client_id",
+		"client_secret",
+		"redirect_url",
+		"scopes",
+		"username_claim",
+		"groups_claim",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var oidcConfig config.OIDCConfig
+	if err := mapstructure.WeakDecode(m, &oidcConfig); err != nil {
+		return err
+	}
+	*result = &oidcConfig
+	return nil
+}
+
+func parseNats(result **config.NatsConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'nats' block allowed")
+	}
+
+	// Get our nats object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"servers",
+		"reconnect_wait",
+		"max_reconnects",
+		"ping_interval",
+		"max_pings_out",
+		"encrypt_key",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var natsConfig config.NatsConfig
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &natsConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	*result = &natsConfig
+	return nil
+}
+
+func parseVault(result **config.VaultConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'vault' block allowed")
+	}
+
+	// Get our vault object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"address",
+		"token",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var vaultConfig config.VaultConfig
+	if err := mapstructure.WeakDecode(m, &vaultConfig); err != nil {
+		return err
+	}
+	*result = &vaultConfig
+	return nil
+}
+
+func parseStorage(result **config.StorageConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'storage' block allowed")
+	}
+
+	// Get our storage object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"type",
+		"endpoint",
+		"region",
+		"bucket",
+		"prefix",
+		"access_key",
+		"secret_key",
+		"path",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var storageConfig config.StorageConfig
+	if err := mapstructure.WeakDecode(m, &storageConfig); err != nil {
+		return err
+	}
+	*result = &storageConfig
+	return nil
+}
+
 func parseConsulConfig(result **config.ConsulConfig, list *ast.ObjectList) error {
 	list = list.Elem()
 	if len(list.Items) > 1 {
@@ -527,6 +991,51 @@ func parseConsulConfig(result **config.ConsulConfig, list *ast.ObjectList) error
 	return nil
 }
 
+func parseTLSConfig(result **config.TLSConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'tls' block allowed")
+	}
+
+	// Get our TLS object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"rpc",
+		"verify_incoming",
+		"bootstrap_token",
+		"ca_fingerprint",
+		"cert_ttl",
+		"ca_key",
+	}
+
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	tlsConfig := config.DefaultTLSConfig()
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &tlsConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+
+	*result = tlsConfig
+	return nil
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
@@ -547,8 +1056,12 @@ func checkHCLKeys(node ast.Node, valid []string) error {
 	for _, item := range list.Items {
 		key := item.Keys[0].Token.Value().(string)
 		if _, ok := validMap[key]; !ok {
-			result = multierror.Append(result, fmt.Errorf(
-				"invalid key: %s", key))
+			msg := fmt.Sprintf("invalid key: %s (line %d, column %d)",
+				key, item.Keys[0].Pos().Line, item.Keys[0].Pos().Column)
+			if closest := internal.ClosestString(key, valid); closest != "" {
+				msg += fmt.Sprintf(", did you mean %q?", closest)
+			}
+			result = multierror.Append(result, errors.New(msg))
 		}
 	}
 
@@ -9,7 +9,9 @@ package agent
 import (
 	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 	"github.com/actiontech/dtle/internal/config"
 
 	"github.com/hashicorp/hcl/hcl/ast"
@@ -67,6 +69,102 @@ func TestParseConfig(t *testing.T) {
 	}
 }
 
+func TestParseConfig_InvalidKey(t *testing.T) {
+	_, err := ParseConfig(strings.NewReader("region = \"r1\"\nagent {\n  enabled = true\n  manager = [\"127.0.0.1:8191\"]\n}\n"))
+	if err == nil {
+		t.Fatal("ParseConfig() should fail on an invalid key")
+	}
+	for _, want := range []string{"invalid key: manager", "line 4, column 3", `did you mean "managers"?`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseConfig() error = %v, should contain %q", err, want)
+		}
+	}
+}
+
+func TestParseConfig_ServerJoin(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(`manager {
+  enabled            = true
+  join               = ["10.0.0.1"]
+  retry_join         = ["10.0.0.2", "10.0.0.3"]
+  retry_max          = 0
+  reconnect_interval = "10s"
+  reconnect_timeout  = "24h"
+  tombstone_timeout  = "1h"
+  rejoin_after_leave = false
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := DefaultConfig().Merge(config).Server
+	if !reflect.DeepEqual(s.StartJoin, []string{"10.0.0.1"}) ||
+		!reflect.DeepEqual(s.RetryJoin, []string{"10.0.0.2", "10.0.0.3"}) {
+		t.Errorf("join %v, retry_join %v", s.StartJoin, s.RetryJoin)
+	}
+	if s.BootstrapExpect != 0 {
+		t.Errorf("BootstrapExpect %v", s.BootstrapExpect)
+	}
+	if err := s.checkBootstrapExpect(); err == nil {
+		t.Errorf("expected an error of join without bootstrap_expect")
+	}
+	s.BootstrapExpect = 3
+	if err := s.checkBootstrapExpect(); err != nil {
+		t.Errorf("checkBootstrapExpect() = %v", err)
+	}
+	if s.ReconnectInterval != "10s" || s.ReconnectTimeout != "24h" || s.TombstoneTimeout != "1h" {
+		t.Errorf("got %+v", s)
+	}
+	if s.RejoinAfterLeave == nil || *s.RejoinAfterLeave {
+		t.Errorf("RejoinAfterLeave %v", s.RejoinAfterLeave)
+	}
+}
+
+func TestParseConfig_Limits(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(`limits {
+  http_max_conns_per_client = 0
+  rpc_handshake_timeout     = "10s"
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := DefaultConfig().Merge(config).Limits
+	if *l.HTTPMaxConnsPerClient != 0 || *l.RPCHandshakeTimeout != 10*time.Second {
+		t.Errorf("got %+v", l)
+	}
+	if *l.HTTPMaxRequestBodySize != 10*1024*1024 || *l.RPCMaxConnsPerClient != 100 {
+		t.Errorf("defaults not kept: %+v", l)
+	}
+
+	if _, err := ParseConfig(strings.NewReader(`limits { http_max_conns = 1 }`)); err == nil {
+		t.Errorf("expected an error of an invalid key")
+	}
+}
+
+func TestParseConfig_Storage(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(`storage {
+  type       = "minio"
+  endpoint   = "http://minio:9000"
+  bucket     = "dtle"
+  prefix     = "cluster1/"
+  access_key = "ak"
+  secret_key = "sk"
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := DefaultConfig().Merge(config).Storage
+	if s == nil || s.Type != "minio" || s.Endpoint != "http://minio:9000" || s.Bucket != "dtle" ||
+		s.Prefix != "cluster1/" || s.AccessKey != "ak" || s.SecretKey != "sk" {
+		t.Errorf("got %+v", s)
+	}
+
+	if _, err := ParseConfig(strings.NewReader(`storage { dir = "/data" }`)); err == nil {
+		t.Errorf("expected an error of an invalid key")
+	}
+}
+
 func Test_parseConfig(t *testing.T) {
 	type args struct {
 		result *Config
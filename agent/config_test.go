@@ -7,7 +7,10 @@
 package agent
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -615,6 +618,49 @@ func TestLoadConfigDir(t *testing.T) {
 	}
 }
 
+func TestLoadConfigDir_Merge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"00-base.hcl":   "region = \"r1\"\ndatacenter = \"dc1\"\nagent {\n  managers = [\"10.0.0.1:8191\"]\n}\n",
+		"10-env.json":   `{"datacenter": "dc2", "http_api_response_headers": {"X-A": "1"}}`,
+		"20-local.conf": "log_level = \"DEBUG\"\nagent {\n  managers = [\"10.0.0.2:8191\"]\n}\n",
+		"30-skip.txt":   "region = \"skipped\"\n",
+		"40-skip.hcl~":  "region = \"skipped\"\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := LoadConfigDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Region != "r1" || c.Datacenter != "dc2" || c.LogLevel != "DEBUG" {
+		t.Errorf("LoadConfigDir() = region %v, datacenter %v, log_level %v", c.Region, c.Datacenter, c.LogLevel)
+	}
+	if want := []string{"10.0.0.1:8191", "10.0.0.2:8191"}; !reflect.DeepEqual(c.Client.Servers, want) {
+		t.Errorf("LoadConfigDir() managers = %v, want %v", c.Client.Servers, want)
+	}
+	if c.HTTPAPIResponseHeaders["X-A"] != "1" {
+		t.Errorf("LoadConfigDir() headers = %v", c.HTTPAPIResponseHeaders)
+	}
+	wantFiles := []string{
+		filepath.Join(dir, "00-base.hcl"),
+		filepath.Join(dir, "10-env.json"),
+		filepath.Join(dir, "20-local.conf"),
+	}
+	if !reflect.DeepEqual(c.Files, wantFiles) {
+		t.Errorf("LoadConfigDir() files = %v, want %v", c.Files, wantFiles)
+	}
+}
+
 func Test_isTemporaryFile(t *testing.T) {
 	type args struct {
 		name string
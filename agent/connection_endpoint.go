@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// ConnectionsRequest is used to list the connection profiles, or to create or
+// replace one.
+func (s *HTTPServer) ConnectionsRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		return s.connectionList(resp, req)
+	case "PUT", "POST":
+		return s.connectionUpsert(resp, req, "")
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+// ConnectionSpecificRequest is used to get, create or replace, and delete the
+// connection profile of the path.
+func (s *HTTPServer) ConnectionSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/connection/")
+	if name == "" {
+		return nil, CodedError(400, "Connection profile name hasn't been provided")
+	}
+	switch req.Method {
+	case "GET":
+		return s.connectionQuery(resp, req, name)
+	case "PUT", "POST":
+		return s.connectionUpsert(resp, req, name)
+	case "DELETE":
+		return s.connectionDelete(resp, req, name)
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+func (s *HTTPServer) connectionList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := models.ConnectionProfileListRequest{}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.ConnectionProfileListResponse
+	if err := s.agent.RPC("ConnectionProfile.List", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	profiles := make([]*api.ConnectionProfile, 0, len(out.Profiles))
+	for _, profile := range out.Profiles {
+		profiles = append(profiles, structConnectionProfileToApi(profile))
+	}
+	return profiles, nil
+}
+
+func (s *HTTPServer) connectionQuery(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	args := models.ConnectionProfileSpecificRequest{
+		Name: name,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleConnectionProfileResponse
+	if err := s.agent.RPC("ConnectionProfile.GetProfile", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Profile == nil {
+		return nil, CodedError(404, "connection profile not found")
+	}
+	return structConnectionProfileToApi(out.Profile), nil
+}
+
+// connectionUpsert creates or replaces the profile of the body. The name of the
+// path, if any, overrides the one of the body.
+func (s *HTTPServer) connectionUpsert(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	var profile api.ConnectionProfile
+	if err := decodeBody(req, &profile); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if name != "" {
+		profile.Name = name
+	}
+	auditTargets(req, "", profile.Name)
+
+	args := models.ConnectionProfileUpsertRequest{
+		Profile: &models.ConnectionProfile{
+			Name:              profile.Name,
+			Namespace:         profile.Namespace,
+			Host:              profile.Host,
+			Port:              profile.Port,
+			User:              profile.User,
+			Password:          profile.Password,
+			PasswordVaultPath: profile.PasswordVaultPath,
+			Charset:           profile.Charset,
+		},
+	}
+	if err := args.Profile.Validate(); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out models.GenericResponse
+	if err := s.agent.RPC("ConnectionProfile.Upsert", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return nil, nil
+}
+
+func (s *HTTPServer) connectionDelete(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	auditTargets(req, "", name)
+	args := models.ConnectionProfileDeleteRequest{
+		Name: name,
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out models.GenericResponse
+	if err := s.agent.RPC("ConnectionProfile.Delete", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return nil, nil
+}
+
+// structConnectionProfileToApi returns the profile without its password.
+func structConnectionProfileToApi(profile *models.ConnectionProfile) *api.ConnectionProfile {
+	return &api.ConnectionProfile{
+		Name:              profile.Name,
+		Namespace:         profile.Namespace,
+		Host:              profile.Host,
+		Port:              profile.Port,
+		User:              profile.User,
+		PasswordVaultPath: profile.PasswordVaultPath,
+		Charset:           profile.Charset,
+		CreateIndex:       profile.CreateIndex,
+		ModifyIndex:       profile.ModifyIndex,
+	}
+}
+
+// resolveConnectionProfile sets the ConnectionConfig of the MySQL task from the
+// connection profile it references, if any, for the endpoints connecting to
+// the databases of a job of the namespace.
+func (s *HTTPServer) resolveConnectionProfile(namespace string, task *models.Task) error {
+	name := task.ConnectionProfileName()
+	if name == "" {
+		return nil
+	}
+	args := models.ConnectionProfileSpecificRequest{
+		Name:         name,
+		QueryOptions: models.QueryOptions{Region: s.agent.config.Region},
+	}
+	var out models.SingleConnectionProfileResponse
+	if err := s.agent.RPC("ConnectionProfile.GetProfile", &args, &out); err != nil {
+		return err
+	}
+	if out.Profile == nil {
+		return CodedErrorf(400, "connection profile %v not found", name)
+	}
+	if err := out.Profile.CheckNamespace(namespace); err != nil {
+		return CodedError(403, err.Error())
+	}
+	taskConfig, err := config.ResolveConnectionProfile(task.Config, out.Profile, s.agent.config.Vault)
+	if err != nil {
+		return err
+	}
+	task.Config = taskConfig
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/crash"
+)
+
+const (
+	// defaultDebugBundleDuration is how long the CPU profile and the execution
+	// trace of a debug bundle run, by default.
+	defaultDebugBundleDuration = 30 * time.Second
+
+	// maxDebugBundleDuration bounds the duration of a debug bundle.
+	maxDebugBundleDuration = 5 * time.Minute
+
+	errDebugDisabled = "Debugging is disabled (see enable_debug)"
+)
+
+// debugEnabled returns whether the pprof endpoints, the debug bundle and the
+// crash reports are exposed. They used to be by the DEBUG log level only, which
+// is kept.
+func (c *Config) debugEnabled() bool {
+	return c.EnableDebug || c.LogLevel == "DEBUG"
+}
+
+// DebugBundleRequest returns a tar.gz of the profiles of the agent over the
+// duration given by "seconds": the heap at the start and at the end, the CPU
+// profile, the execution trace, the goroutines and the memory stats.
+func (s *HTTPServer) DebugBundleRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if !s.agent.config.debugEnabled() {
+		return nil, CodedError(403, errDebugDisabled)
+	}
+
+	duration := defaultDebugBundleDuration
+	if v := req.URL.Query().Get("seconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxDebugBundleDuration {
+			return nil, CodedErrorf(400, "Invalid seconds %q, should be 1 to %v",
+				v, int(maxDebugBundleDuration.Seconds()))
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	s.logger.Printf("http: Collecting a debug bundle of %v", duration)
+	files, err := collectDebugBundle(req.Context(), duration)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header().Set("Content-Type", "application/gzip")
+	resp.Header().Set("Content-Disposition", `attachment; filename="dtle-debug.tar.gz"`)
+	if err := writeDebugBundle(resp, files); err != nil {
+		s.logger.Errorf("http: Failed to write the debug bundle: %v", err)
+	}
+	return nil, nil
+}
+
+// debugFile is a file of a debug bundle.
+type debugFile struct {
+	name string
+	data []byte
+}
+
+// collectDebugBundle profiles the process for the duration. A profile which
+// could not be taken, e.g. the CPU profile while another one runs, is reported
+// in errors.txt of the bundle instead.
+func collectDebugBundle(ctx context.Context, duration time.Duration) ([]debugFile, error) {
+	var files []debugFile
+	var errs []string
+	add := func(name string, write func(w io.Writer) error) {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", name, err))
+			return
+		}
+		files = append(files, debugFile{name: name, data: buf.Bytes()})
+	}
+	writeHeap := func(w io.Writer) error {
+		// The heap profile is as of the last GC.
+		runtime.GC()
+		return pprof.Lookup("heap").WriteTo(w, 0)
+	}
+
+	var memStats struct {
+		Start runtime.MemStats
+		End   runtime.MemStats
+	}
+	runtime.ReadMemStats(&memStats.Start)
+	add("heap-start.pprof", writeHeap)
+
+	var cpuProfile, executionTrace bytes.Buffer
+	cpuErr := pprof.StartCPUProfile(&cpuProfile)
+	traceErr := trace.Start(&executionTrace)
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+	}
+	if cpuErr == nil {
+		pprof.StopCPUProfile()
+	}
+	if traceErr == nil {
+		trace.Stop()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	add("cpu.pprof", func(w io.Writer) error {
+		if cpuErr != nil {
+			return cpuErr
+		}
+		_, err := w.Write(cpuProfile.Bytes())
+		return err
+	})
+	add("trace.out", func(w io.Writer) error {
+		if traceErr != nil {
+			return traceErr
+		}
+		_, err := w.Write(executionTrace.Bytes())
+		return err
+	})
+
+	add("heap.pprof", writeHeap)
+	add("goroutine.txt", func(w io.Writer) error {
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	runtime.ReadMemStats(&memStats.End)
+	add("memstats.json", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&memStats)
+	})
+
+	if len(errs) > 0 {
+		files = append(files, debugFile{name: "errors.txt", data: []byte(strings.Join(errs, "\n") + "\n")})
+	}
+	return files, nil
+}
+
+// writeDebugBundle writes the files as a tar.gz.
+func writeDebugBundle(w io.Writer, files []debugFile) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    "dtle-debug/" + f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// CrashReportsRequest lists the crash reports of the server in its data dir,
+// written when it panicked, or returns one of them as a tar.gz. As the profiles,
+// they are only exposed with debugging enabled.
+func (s *HTTPServer) CrashReportsRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if !s.agent.config.debugEnabled() {
+		return nil, CodedError(403, errDebugDisabled)
+	}
+	dir := crash.Dir(s.agent.config.DataDir)
+	name := strings.TrimPrefix(req.URL.Path, "/v1/agent/crash-reports")
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		reports, err := crash.ListReports(dir)
+		if err != nil {
+			return nil, err
+		}
+		if reports == nil {
+			reports = []*crash.ReportInfo{}
+		}
+		return reports, nil
+	}
+
+	path, err := crash.ReportPath(dir, name)
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, CodedErrorf(404, "Crash report %v not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	resp.Header().Set("Content-Type", "application/gzip")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if _, err := io.Copy(resp, f); err != nil {
+		s.logger.Errorf("http: Failed to write the crash report %v: %v", name, err)
+	}
+	return nil, nil
+}
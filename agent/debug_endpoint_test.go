@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/crash"
+)
+
+func readDebugBundle(t *testing.T, r io.Reader) map[string][]byte {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = buf.Bytes()
+	}
+	return files
+}
+
+func Test_collectDebugBundle(t *testing.T) {
+	files, err := collectDebugBundle(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeDebugBundle(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readDebugBundle(t, &buf)
+	for _, name := range []string{"heap-start.pprof", "cpu.pprof", "trace.out", "heap.pprof",
+		"goroutine.txt", "memstats.json"} {
+		if len(got["dtle-debug/"+name]) == 0 {
+			t.Errorf("no %v in the bundle", name)
+		}
+	}
+	if _, ok := got["dtle-debug/errors.txt"]; ok {
+		t.Errorf("errors: %s", got["dtle-debug/errors.txt"])
+	}
+}
+
+func Test_collectDebugBundle_CPUProfileRunning(t *testing.T) {
+	var other bytes.Buffer
+	if err := pprof.StartCPUProfile(&other); err != nil {
+		t.Skip(err)
+	}
+	defer pprof.StopCPUProfile()
+
+	files, err := collectDebugBundle(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	want := []string{"heap-start.pprof", "trace.out", "heap.pprof", "goroutine.txt", "memstats.json", "errors.txt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func Test_collectDebugBundle_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := collectDebugBundle(ctx, time.Minute); err == nil {
+		t.Errorf("expected an error of the request canceled")
+	}
+}
+
+func TestHTTPServer_CrashReportsRequest(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "dtle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+	config := DefaultConfig()
+	config.DataDir = dataDir
+	s := &HTTPServer{agent: &Agent{config: config}}
+
+	_, err = s.CrashReportsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/agent/crash-reports", nil))
+	if e, ok := err.(HTTPCodedError); !ok || e.Code() != 403 {
+		t.Fatalf("got %v, want 403 with debugging disabled", err)
+	}
+	config.EnableDebug = true
+
+	// No reports
+	out, err := s.CrashReportsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/agent/crash-reports", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports := out.([]*crash.ReportInfo); len(reports) != 0 {
+		t.Errorf("got %v reports", len(reports))
+	}
+
+	path, err := crash.NewReporter(crash.Dir(dataDir), "test", nil).Write("test panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Base(path)
+	out, err = s.CrashReportsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/agent/crash-reports", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports := out.([]*crash.ReportInfo); len(reports) != 1 || reports[0].Name != name {
+		t.Errorf("got %+v", reports)
+	}
+
+	resp := httptest.NewRecorder()
+	if _, err := s.CrashReportsRequest(resp, httptest.NewRequest("GET", "/v1/agent/crash-reports/"+name, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readDebugBundle(t, resp.Body); len(got[name[:len(name)-len(".tar.gz")]+"/panic.txt"]) == 0 {
+		t.Errorf("no panic.txt in the report")
+	}
+
+	for reqName, code := range map[string]int{
+		"crash-20180102T150405.000000000Z.tar.gz": 404,
+		"..%2Fdtle.hcl": 400,
+	} {
+		_, err := s.CrashReportsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/agent/crash-reports/"+reqName, nil))
+		if e, ok := err.(HTTPCodedError); !ok || e.Code() != code {
+			t.Errorf("%v: got %v, want code %v", reqName, err, code)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const eventStreamPath = "/v1/event/stream"
+
+const (
+	EventTopicJob        = "Job"
+	EventTopicNode       = "Node"
+	EventTopicAllocation = "Allocation"
+
+	// EventTypeError is sent before the stream is closed on an error.
+	EventTypeError = "Error"
+)
+
+const (
+	// eventQueryWait is the max time of the blocking queries of the stream.
+	// A goroutine of a closed stream lingers until its query returns.
+	eventQueryWait = 30 * time.Second
+
+	// eventHeartbeatInterval is the interval of the comments sent to keep
+	// the stream alive when there is no event.
+	eventHeartbeatInterval = 10 * time.Second
+)
+
+// Event is an event of the event stream.
+type Event struct {
+	Topic string
+	Type  string
+
+	// Key is the ID of the job, node or allocation.
+	Key string
+
+	// Index is the raft index at which the change was seen.
+	Index uint64
+
+	// Payload is the list stub of the object, or the error message.
+	Payload interface{}
+}
+
+// eventItem is an object watched by the event stream.
+type eventItem struct {
+	status  string
+	version uint64
+	failed  bool
+	payload interface{}
+}
+
+// eventWatcher lists the objects of a topic by a blocking query, and names the
+// events of their changes.
+type eventWatcher struct {
+	topic   string
+	created string
+	updated string
+	failed  string
+	deleted string
+	list    func(s *HTTPServer, q models.QueryOptions) (map[string]*eventItem, uint64, error)
+}
+
+var eventWatchers = map[string]*eventWatcher{
+	EventTopicJob: {
+		topic:   EventTopicJob,
+		created: "JobRegistered",
+		updated: "JobUpdated",
+		deleted: "JobDeregistered",
+		list: func(s *HTTPServer, q models.QueryOptions) (map[string]*eventItem, uint64, error) {
+			args := models.JobListRequest{QueryOptions: q}
+			var out models.JobListResponse
+			if err := s.agent.RPC("Job.List", &args, &out); err != nil {
+				return nil, 0, err
+			}
+			items := make(map[string]*eventItem, len(out.Jobs))
+			for _, j := range out.Jobs {
+				items[j.ID] = &eventItem{status: j.Status, version: j.JobModifyIndex, payload: j}
+			}
+			return items, out.Index, nil
+		},
+	},
+	EventTopicNode: {
+		topic:   EventTopicNode,
+		created: "NodeRegistered",
+		updated: "NodeUpdated",
+		deleted: "NodeDeregistered",
+		list: func(s *HTTPServer, q models.QueryOptions) (map[string]*eventItem, uint64, error) {
+			args := models.NodeListRequest{QueryOptions: q}
+			var out models.NodeListResponse
+			if err := s.agent.RPC("Node.List", &args, &out); err != nil {
+				return nil, 0, err
+			}
+			items := make(map[string]*eventItem, len(out.Nodes))
+			for _, n := range out.Nodes {
+				items[n.ID] = &eventItem{status: n.Status, payload: n}
+			}
+			return items, out.Index, nil
+		},
+	},
+	EventTopicAllocation: {
+		topic:   EventTopicAllocation,
+		created: "AllocationPlaced",
+		updated: "AllocationUpdated",
+		failed:  "AllocationFailed",
+		deleted: "AllocationRemoved",
+		list: func(s *HTTPServer, q models.QueryOptions) (map[string]*eventItem, uint64, error) {
+			args := models.AllocListRequest{QueryOptions: q}
+			var out models.AllocListResponse
+			if err := s.agent.RPC("Alloc.List", &args, &out); err != nil {
+				return nil, 0, err
+			}
+			items := make(map[string]*eventItem, len(out.Allocations))
+			for _, a := range out.Allocations {
+				items[a.ID] = &eventItem{
+					status:  a.DesiredStatus + "/" + a.ClientStatus,
+					failed:  a.ClientStatus == models.AllocClientStatusFailed,
+					payload: a,
+				}
+			}
+			return items, out.Index, nil
+		},
+	},
+}
+
+// diff returns the events of the changes from old to new, sorted by key.
+func (w *eventWatcher) diff(old, new map[string]*eventItem, index uint64) []*Event {
+	var events []*Event
+	for key, item := range new {
+		prev, ok := old[key]
+		var typ string
+		switch {
+		case !ok:
+			typ = w.created
+		case prev.status == item.status && prev.version == item.version:
+			continue
+		case item.failed && !prev.failed && w.failed != "":
+			typ = w.failed
+		default:
+			typ = w.updated
+		}
+		events = append(events, &Event{Topic: w.topic, Type: typ, Key: key, Index: index, Payload: item.payload})
+	}
+	for key, item := range old {
+		if _, ok := new[key]; !ok {
+			events = append(events, &Event{Topic: w.topic, Type: w.deleted, Key: key, Index: index, Payload: item.payload})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+// watch sends the events of the topic until ctx is done or a query fails.
+// The objects existing when the watch starts produce no event.
+func (w *eventWatcher) watch(ctx context.Context, s *HTTPServer, region string, events chan<- *Event) error {
+	var items map[string]*eventItem
+	var index uint64
+	for {
+		q := models.QueryOptions{Region: region, MinQueryIndex: index, MaxQueryTime: eventQueryWait}
+		newItems, newIndex, err := w.list(s, q)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if items != nil {
+			for _, e := range w.diff(items, newItems, newIndex) {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+		items = newItems
+		index = newIndex
+	}
+}
+
+// EventStreamRequest streams the events of jobs, nodes and allocations as
+// server-sent events. ?topic selects the topics, all by default.
+func (s *HTTPServer) EventStreamRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		return nil, CodedError(500, "Streaming is not supported")
+	}
+
+	var watchers []*eventWatcher
+	topics := req.URL.Query()["topic"]
+	if len(topics) == 0 {
+		topics = []string{EventTopicJob, EventTopicNode, EventTopicAllocation}
+	}
+	for _, topic := range topics {
+		w, ok := eventWatchers[topic]
+		if !ok {
+			return nil, CodedErrorf(400, "Invalid topic: %v", topic)
+		}
+		watchers = append(watchers, w)
+	}
+
+	var region string
+	s.parseRegion(req, &region)
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	events := make(chan *Event, 64)
+	errCh := make(chan error, len(watchers))
+	for _, w := range watchers {
+		go func(w *eventWatcher) {
+			if err := w.watch(ctx, s, region, events); err != nil {
+				errCh <- err
+			}
+		}(w)
+	}
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(200)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case err := <-errCh:
+			s.logger.Errorf("http: Event stream error: %v", err)
+			writeEvent(resp, &Event{Type: EventTypeError, Payload: err.Error()})
+			flusher.Flush()
+			return nil, nil
+		case e := <-events:
+			if err := writeEvent(resp, e); err != nil {
+				return nil, nil
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(resp, ":\n\n"); err != nil {
+				return nil, nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes the event as a server-sent event named by its type.
+func writeEvent(resp http.ResponseWriter, e *Event) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", e.Type, buf)
+	return err
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_eventWatcher_diff(t *testing.T) {
+	w := eventWatchers[EventTopicAllocation]
+	old := map[string]*eventItem{
+		"a": {status: "run/pending"},
+		"b": {status: "run/running"},
+		"c": {status: "run/running"},
+		"d": {status: "run/running"},
+	}
+	new := map[string]*eventItem{
+		"a": {status: "run/running"},
+		"b": {status: "run/failed", failed: true},
+		"c": {status: "run/running"},
+		"e": {status: "run/pending"},
+	}
+
+	var got []string
+	for _, e := range w.diff(old, new, 7) {
+		if e.Topic != EventTopicAllocation || e.Index != 7 {
+			t.Errorf("event = %+v", e)
+		}
+		got = append(got, e.Key+":"+e.Type)
+	}
+	want := []string{"a:AllocationUpdated", "b:AllocationFailed", "d:AllocationRemoved", "e:AllocationPlaced"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diff() = %v, want %v", got, want)
+	}
+
+	if events := eventWatchers[EventTopicJob].diff(
+		map[string]*eventItem{"j": {status: "running", version: 1}},
+		map[string]*eventItem{"j": {status: "running", version: 2}}, 8); len(events) != 1 || events[0].Type != "JobUpdated" {
+		t.Errorf("diff() of a job update = %v", events)
+	}
+}
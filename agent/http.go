@@ -22,6 +22,9 @@ import (
 	"github.com/ugorji/go/codec"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/actiontech/dtle/internal/auth"
+	"github.com/actiontech/dtle/internal/connlimit"
+	"github.com/actiontech/dtle/internal/i18n"
 	"strings"
 	log "github.com/actiontech/dtle/internal/logger"
 	umodel "github.com/actiontech/dtle/internal/models"
@@ -52,6 +55,9 @@ type HTTPServer struct {
 	logger   *log.Logger
 	uiDir    string
 	addr     string
+
+	// authProviders authenticate the users when ACLs are enabled.
+	authProviders []auth.Provider
 }
 
 // NewHTTPServer starts new HTTP server over the agent
@@ -66,6 +72,11 @@ func NewHTTPServer(agent *Agent, config *Config, logOutput io.Writer) (*HTTPServ
 		return nil, fmt.Errorf("failed to start HTTP listener: %v", err)
 	}
 
+	authProviders, err := newAuthProviders(config.ACL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup the auth providers: %v", err)
+	}
+
 	// Create the mux
 	mux := http.NewServeMux()
 
@@ -77,19 +88,70 @@ func NewHTTPServer(agent *Agent, config *Config, logOutput io.Writer) (*HTTPServ
 		logger:   agent.logger,
 		uiDir:    config.UiDir,
 		addr:     ln.Addr().String(),
+
+		authProviders: authProviders,
 	}
 	srv.registerHandlers()
 
 	// Start the server
-	go http.Serve(ln, gziphandler.GzipHandler(mux))
+	httpConfig := config.HTTP
+	if httpConfig == nil {
+		httpConfig = DefaultConfig().HTTP
+	}
+	limits := config.Limits
+	if limits == nil {
+		limits = DefaultConfig().Limits
+	}
+	var handler http.Handler = mux
+	if limits.HTTPMaxRequestBodySize != nil && *limits.HTTPMaxRequestBodySize > 0 {
+		handler = maxBodyHandler(handler, *limits.HTTPMaxRequestBodySize, config.Locale)
+	}
+	if !httpConfig.DisableCompression {
+		handler = gzipHandler(handler)
+	}
+	if len(httpConfig.CORSAllowedOrigins) > 0 {
+		handler = corsHandler(handler, httpConfig.CORSAllowedOrigins, httpConfig.CORSAllowedHeaders)
+	}
+	httpServer := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpConfig.ReadHeaderTimeout,
+		ReadTimeout:       httpConfig.ReadTimeout,
+		WriteTimeout:      httpConfig.WriteTimeout,
+		IdleTimeout:       httpConfig.IdleTimeout,
+	}
+	if tcpLn, ok := ln.(*net.TCPListener); ok && httpConfig.KeepAlivePeriod > 0 {
+		ln = tcpKeepAliveListener{TCPListener: tcpLn, period: httpConfig.KeepAlivePeriod}
+	}
+	if limits.HTTPMaxConnsPerClient != nil && *limits.HTTPMaxConnsPerClient > 0 {
+		ln = &connlimit.Listener{
+			Listener: ln,
+			Limiter:  connlimit.NewLimiter(*limits.HTTPMaxConnsPerClient),
+			Reject:   rejectHTTPConn,
+		}
+	}
+	go httpServer.Serve(ln)
 	return srv, nil
 }
 
+// gzipHandler compresses the responses, except the event stream, whose events
+// would be held until the compression buffer fills.
+func gzipHandler(h http.Handler) http.Handler {
+	gz := gziphandler.GzipHandler(h)
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == eventStreamPath {
+			h.ServeHTTP(resp, req)
+			return
+		}
+		gz.ServeHTTP(resp, req)
+	})
+}
+
 // tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
 // connections. It's used by NewHttpServer so
 // dead TCP connections eventually go away.
 type tcpKeepAliveListener struct {
 	*net.TCPListener
+	period time.Duration
 }
 
 func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
@@ -98,10 +160,73 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 		return
 	}
 	tc.SetKeepAlive(true)
-	tc.SetKeepAlivePeriod(30 * time.Second)
+	tc.SetKeepAlivePeriod(ln.period)
 	return tc, nil
 }
 
+// rejectHTTPConn answers a connection over http_max_conns_per_client with a 429
+// and closes it.
+func rejectHTTPConn(conn net.Conn) {
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("HTTP/1.1 429 Too Many Requests\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+	conn.Close()
+}
+
+// unlimitedBodyPaths are the paths whose request bodies are not capped by
+// http_max_request_body_size, e.g. a snapshot to restore, of the whole state.
+var unlimitedBodyPaths = map[string]bool{
+	"/v1/operator/snapshot": true,
+}
+
+// maxBodyHandler answers the requests with a body larger than max bytes with a
+// 413, in the locale of the request or else the locale, and caps the bodies of
+// unknown length, whose decoding then fails.
+func maxBodyHandler(h http.Handler, max int64, locale string) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if unlimitedBodyPaths[req.URL.Path] {
+			h.ServeHTTP(resp, req)
+			return
+		}
+		if req.ContentLength > max {
+			locale := i18n.AcceptLanguage(req.Header.Get("Accept-Language"), locale)
+			resp.Header().Set("Content-Language", locale)
+			resp.WriteHeader(http.StatusRequestEntityTooLarge)
+			resp.Write([]byte(i18n.Sprintf(locale, "request body larger than %v bytes (see http_max_request_body_size)", max)))
+			return
+		}
+		req.Body = http.MaxBytesReader(resp, req.Body, max)
+		h.ServeHTTP(resp, req)
+	})
+}
+
+// corsHandler allows cross-origin requests from the given origins, and answers
+// the preflight requests.
+func corsHandler(h http.Handler, origins, headers []string) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
+	}
+	allowHeaders := strings.Join(append([]string{"Content-Type"}, headers...), ", ")
+
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			h.ServeHTTP(resp, req)
+			return
+		}
+
+		resp.Header().Set("Access-Control-Allow-Origin", origin)
+		resp.Header().Add("Vary", "Origin")
+		if req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != "" {
+			resp.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE")
+			resp.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			resp.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(resp, req)
+	})
+}
+
 // Shutdown is used to shutdown the HTTP server
 func (s *HTTPServer) Shutdown() {
 	if s != nil {
@@ -149,6 +274,7 @@ func (s *HTTPServer) registerHandlers() {
 	s.mux.HandleFunc("/v1/cloud/order", s.wrap(s.OrderCloudRequest))
 
 	s.mux.HandleFunc("/v1/jobs", s.wrap(s.JobsRequest))
+	s.mux.HandleFunc("/v1/jobs/", s.wrap(s.JobsBulkRequest))
 	s.mux.HandleFunc("/v1/job/renewal", s.wrap(s.JobsRenewalRequest))
 	s.mux.HandleFunc("/v1/job/info", s.wrap(s.JobsInfoRequest))
 	s.mux.HandleFunc("/v1/validate/job", s.wrap(s.ValidateJobRequest))
@@ -164,8 +290,12 @@ func (s *HTTPServer) registerHandlers() {
 	s.mux.HandleFunc("/v1/evaluation/", s.wrap(s.EvalSpecificRequest))
 
 	s.mux.HandleFunc("/v1/agent/allocation/", s.wrap(s.ClientAllocRequest))
+	s.mux.HandleFunc("/v1/client/stats", s.wrap(s.ClientStatsRequest))
+
+	s.mux.HandleFunc(eventStreamPath, s.wrap(s.EventStreamRequest))
 
 	s.mux.HandleFunc("/v1/self", s.wrap(s.AgentSelfRequest))
+	s.mux.HandleFunc("/v1/agent/health", s.wrap(s.AgentHealthRequest))
 	s.mux.HandleFunc("/v1/join", s.wrap(s.AgentJoinRequest))
 	s.mux.HandleFunc("/v1/agent/force-leave", s.wrap(s.AgentForceLeaveRequest))
 	s.mux.HandleFunc("/v1/members", s.wrap(s.AgentMembersRequest))
@@ -178,11 +308,28 @@ func (s *HTTPServer) registerHandlers() {
 
 	s.mux.HandleFunc("/v1/operator/", s.wrap(s.OperatorRequest))
 
-	if s.agent.config.LogLevel == "DEBUG" {
-		s.mux.HandleFunc("/debug/pprof/", pprof.Index)
-		s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-		s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-		s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/v1/system/gc", s.wrap(s.GarbageCollectRequest))
+
+	s.mux.HandleFunc("/v1/audit", s.wrap(s.AuditRequest))
+
+	s.mux.HandleFunc("/v1/topology", s.wrap(s.TopologyRequest))
+
+	s.mux.HandleFunc("/v1/connections", s.wrap(s.ConnectionsRequest))
+	s.mux.HandleFunc("/v1/connection/", s.wrap(s.ConnectionSpecificRequest))
+
+	s.mux.HandleFunc("/v1/acl/self", s.wrap(s.ACLSelfRequest))
+	s.mux.HandleFunc("/v1/acl/oidc/login", s.wrap(s.OIDCLoginRequest))
+	s.mux.HandleFunc("/v1/acl/oidc/callback", s.wrap(s.OIDCCallbackRequest))
+
+	s.mux.HandleFunc("/v1/agent/debug/bundle", s.wrap(s.DebugBundleRequest))
+	s.mux.HandleFunc("/v1/agent/crash-reports", s.wrap(s.CrashReportsRequest))
+	s.mux.HandleFunc("/v1/agent/crash-reports/", s.wrap(s.CrashReportsRequest))
+	if s.agent.config.debugEnabled() {
+		s.mux.HandleFunc("/debug/pprof/", s.wrapDebug(pprof.Index))
+		s.mux.HandleFunc("/debug/pprof/cmdline", s.wrapDebug(pprof.Cmdline))
+		s.mux.HandleFunc("/debug/pprof/profile", s.wrapDebug(pprof.Profile))
+		s.mux.HandleFunc("/debug/pprof/symbol", s.wrapDebug(pprof.Symbol))
+		s.mux.HandleFunc("/debug/pprof/trace", s.wrapDebug(pprof.Trace))
 	}
 
 	// Use the custom UI dir if provided.
@@ -195,6 +342,23 @@ func (s *HTTPServer) registerHandlers() {
 	s.mux.Handle("/metrics", promhttp.Handler())
 }
 
+// wrapDebug allows a pprof handler to the management tokens only, if ACLs are
+// enabled.
+func (s *HTTPServer) wrapDebug(handler http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		aclReq, err := s.aclCheck(req)
+		if err != nil {
+			code := 500
+			if http, ok := err.(HTTPCodedError); ok {
+				code = http.Code()
+			}
+			s.writeError(resp, req, code, err)
+			return
+		}
+		handler(resp, aclReq)
+	}
+}
+
 // HTTPCodedError is used to provide the HTTP error code
 type HTTPCodedError interface {
 	error
@@ -202,12 +366,22 @@ type HTTPCodedError interface {
 }
 
 func CodedError(c int, s string) HTTPCodedError {
-	return &codedError{s, c}
+	return &codedError{s: s, code: c}
+}
+
+// CodedErrorf is used to provide an HTTP error code with a formatted message,
+// which is translated to the locale of the request.
+func CodedErrorf(c int, format string, args ...interface{}) HTTPCodedError {
+	return &codedError{s: fmt.Sprintf(format, args...), code: c, format: format, args: args}
 }
 
 type codedError struct {
 	s    string
 	code int
+
+	// The format and the arguments of the message, if formatted
+	format string
+	args   []interface{}
 }
 
 func (e *codedError) Error() string {
@@ -218,6 +392,27 @@ func (e *codedError) Code() int {
 	return e.code
 }
 
+func (e *codedError) Localize(locale string) string {
+	if e.format == "" {
+		return i18n.Translate(locale, e.s)
+	}
+	return i18n.Sprintf(locale, e.format, e.args...)
+}
+
+// requestLocale returns the locale of the errors of the request, by its
+// Accept-Language, or else the locale of the agent.
+func (s *HTTPServer) requestLocale(req *http.Request) string {
+	return i18n.AcceptLanguage(req.Header.Get("Accept-Language"), s.agent.config.Locale)
+}
+
+// writeError writes the error translated to the locale of the request.
+func (s *HTTPServer) writeError(resp http.ResponseWriter, req *http.Request, code int, err error) {
+	locale := s.requestLocale(req)
+	resp.Header().Set("Content-Language", locale)
+	resp.WriteHeader(code)
+	resp.Write([]byte(i18n.Localize(locale, err)))
+}
+
 // wrap is used to wrap functions to make them more convenient
 func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Request) (interface{}, error)) func(resp http.ResponseWriter, req *http.Request) {
 	f := func(resp http.ResponseWriter, req *http.Request) {
@@ -228,7 +423,16 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 		defer func() {
 			s.logger.Debugf("http: Request %v (%v)", reqURL, time.Now().Sub(start))
 		}()
-		obj, err := handler(resp, req)
+		var obj interface{}
+		event := s.auditEvent(req)
+		aclReq, err := s.aclCheck(req)
+		if err == nil {
+			req = withAuditEvent(aclReq, event)
+			obj, err = handler(resp, req)
+		}
+		if event != nil {
+			s.recordAudit(req, event, err)
+		}
 
 		// Check for an error
 	HAS_ERR:
@@ -238,8 +442,12 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 			if http, ok := err.(HTTPCodedError); ok {
 				code = http.Code()
 			}
-			resp.WriteHeader(code)
-			resp.Write([]byte(err.Error()))
+			if code == 401 {
+				for _, provider := range s.authProviders {
+					resp.Header().Add("WWW-Authenticate", provider.Challenge())
+				}
+			}
+			s.writeError(resp, req, code, err)
 			return
 		}
 
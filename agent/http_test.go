@@ -10,10 +10,12 @@ import (
 	"bytes"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	_ "net/http/pprof"
 	"reflect"
 	"testing"
 	"time"
+	"github.com/actiontech/dtle/internal/i18n"
 	log "github.com/actiontech/dtle/internal/logger"
 	umodel "github.com/actiontech/dtle/internal/models"
 )
@@ -480,3 +482,90 @@ func TestHTTPServer_parse(t *testing.T) {
 		})
 	}
 }
+
+func Test_corsHandler(t *testing.T) {
+	h := corsHandler(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(200)
+	}), []string{"http://ui.example.com"}, []string{"X-Token"})
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantCode   int
+		wantOrigin string
+	}{
+		{"no origin", "GET", "", 200, ""},
+		{"allowed", "GET", "http://ui.example.com", 200, "http://ui.example.com"},
+		{"not allowed", "GET", "http://other.example.com", 200, ""},
+		{"preflight", "OPTIONS", "http://ui.example.com", 204, "http://ui.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/jobs", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == "OPTIONS" {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != tt.wantCode {
+				t.Errorf("code = %v, want %v", resp.Code, tt.wantCode)
+			}
+			if got := resp.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %v, want %v", got, tt.wantOrigin)
+			}
+			if tt.method == "OPTIONS" && resp.Header().Get("Access-Control-Allow-Headers") != "Content-Type, X-Token" {
+				t.Errorf("Access-Control-Allow-Headers = %v", resp.Header().Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+func Test_maxBodyHandler(t *testing.T) {
+	h := maxBodyHandler(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var out map[string]interface{}
+		if err := decodeBody(req, &out); err != nil {
+			resp.WriteHeader(400)
+			return
+		}
+		resp.WriteHeader(200)
+	}), 16, i18n.English)
+
+	for body, want := range map[string]int{
+		`{"a": 1}`:                       200,
+		`{"a": "a body over the limit"}`: 413,
+	} {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("PUT", "/v1/jobs", bytes.NewBufferString(body)))
+		if resp.Code != want {
+			t.Errorf("%v: code = %v, want %v", body, resp.Code, want)
+		}
+	}
+
+	// Of unknown length
+	req := httptest.NewRequest("PUT", "/v1/jobs", bytes.NewBufferString(`{"a": "a body over the limit"}`))
+	req.ContentLength = -1
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != 400 {
+		t.Errorf("unknown length: code = %v, want 400", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("PUT", "/v1/operator/snapshot", bytes.NewBufferString(`{"a": "a body over the limit"}`)))
+	if resp.Code != 200 {
+		t.Errorf("snapshot: code = %v, want 200", resp.Code)
+	}
+
+	// In the locale of the request
+	req = httptest.NewRequest("PUT", "/v1/jobs", bytes.NewBufferString(`{"a": "a body over the limit"}`))
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if want := "请求体超过 16 字节（见 http_max_request_body_size）"; resp.Body.String() != want {
+		t.Errorf("zh: body = %q, want %q", resp.Body.String(), want)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobBench returns the throughput measured by the running tasks of a bench job.
+func (s *HTTPServer) jobBench(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+	if out.Job.Type != models.JobTypeBench {
+		return nil, CodedErrorf(400, "job %v is not a %v job", jobName, models.JobTypeBench)
+	}
+
+	allocArgs := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	allocArgs.Region = args.Region
+	allocArgs.AllowStale = args.AllowStale
+	var allocsOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", &allocArgs, &allocsOut); err != nil {
+		return nil, err
+	}
+
+	result := &api.BenchResult{
+		JobID:  out.Job.ID,
+		Status: out.Job.Status,
+	}
+	stats := map[string]*models.BenchStat{}
+	for _, task := range []string{models.TaskTypeSrc, models.TaskTypeDest} {
+		var alloc *models.AllocListStub
+		for _, a := range allocsOut.Allocations {
+			if a.Task == task && a.ClientStatus == models.AllocClientStatusRunning {
+				alloc = a
+				break
+			}
+		}
+		if alloc == nil {
+			result.StatsErrors = append(result.StatsErrors, fmt.Sprintf("no running alloc of task %v", task))
+			continue
+		}
+		taskStats, err := s.taskStats(req, args.Region, alloc, task)
+		if err != nil {
+			result.StatsErrors = append(result.StatsErrors, err.Error())
+			continue
+		}
+		stats[task] = taskStats.Bench
+	}
+	fillBenchResult(result, stats[models.TaskTypeSrc], stats[models.TaskTypeDest])
+	return result, nil
+}
+
+// fillBenchResult fills the result with the stats of the Src and the Dest
+// tasks, either of which may be nil.
+func fillBenchResult(result *api.BenchResult, src, dest *models.BenchStat) {
+	if src != nil {
+		result.Transactions = src.Transactions
+		result.GeneratedTransactions = src.GeneratedTransactions
+		result.GenerateSeconds = src.GenerateSeconds
+		result.GenerateTransactionsPerSecond = perSecond(src.GeneratedTransactions, src.GenerateSeconds)
+	}
+	if dest != nil {
+		result.DumpRows = dest.DumpRows
+		result.DumpSeconds = dest.DumpSeconds
+		result.DumpRowsPerSecond = perSecond(dest.DumpRows, dest.DumpSeconds)
+		result.AppliedTransactions = dest.AppliedTransactions
+		result.ApplySeconds = dest.ApplySeconds
+		result.ApplyTransactionsPerSecond = perSecond(dest.AppliedTransactions, dest.ApplySeconds)
+	}
+	result.Complete = src != nil && dest != nil && src.Transactions > 0 &&
+		src.GeneratedTransactions == src.Transactions && dest.AppliedTransactions >= src.Transactions
+}
+
+func perSecond(n int64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(n) / seconds
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestFillBenchResult(t *testing.T) {
+	src := &models.BenchStat{
+		Transactions:          1000,
+		GeneratedTransactions: 1000,
+		GenerateSeconds:       4,
+	}
+	dest := &models.BenchStat{
+		DumpRows:            400000,
+		DumpSeconds:         8,
+		AppliedTransactions: 1000,
+		ApplySeconds:        5,
+	}
+
+	result := &api.BenchResult{}
+	fillBenchResult(result, src, dest)
+	if !result.Complete {
+		t.Errorf("not complete")
+	}
+	if result.DumpRowsPerSecond != 50000 || result.GenerateTransactionsPerSecond != 250 ||
+		result.ApplyTransactionsPerSecond != 200 {
+		t.Errorf("result = %+v", result)
+	}
+
+	dest.AppliedTransactions = 999
+	result = &api.BenchResult{}
+	fillBenchResult(result, src, dest)
+	if result.Complete {
+		t.Errorf("complete with a transaction not applied")
+	}
+
+	result = &api.BenchResult{}
+	fillBenchResult(result, nil, &models.BenchStat{DumpRows: 10})
+	if result.Complete || result.DumpRows != 10 || result.DumpRowsPerSecond != 0 {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -31,6 +31,21 @@ func (s *HTTPServer) JobsRequest(resp http.ResponseWriter, req *http.Request) (i
 	}
 }
 
+func (s *HTTPServer) JobsBulkRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if !(req.Method == "POST" || req.Method == "PUT") {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	action := strings.TrimPrefix(req.URL.Path, "/v1/jobs/")
+	switch action {
+	case "register":
+		return s.jobsBulkRegister(resp, req)
+	case "pause", "resume", "delete":
+		return s.jobsBulkAction(resp, req, action)
+	default:
+		return nil, CodedError(404, ErrInvalidMethod)
+	}
+}
+
 func (s *HTTPServer) JobsRenewalRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	switch req.Method {
 	case "PUT":
@@ -71,6 +86,9 @@ func (s *HTTPServer) jobInfoRequest(resp http.ResponseWriter, req *http.Request)
 
 	for _, task := range sJob.Tasks {
 		if task.Driver == models.TaskDriverMySQL && task.Type == models.TaskTypeSrc {
+			if err := s.resolveConnectionProfile(sJob.Namespace, task); err != nil {
+				return nil, err
+			}
 			var driverConfig config.MySQLDriverConfig
 			if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
 				return nil, err
@@ -85,7 +103,7 @@ func (s *HTTPServer) jobInfoRequest(resp http.ResponseWriter, req *http.Request)
 			if err != nil {
 				return nil, err
 			}
-			dbs, err := sql.ShowDatabases(db)
+			dbs, err := sql.ShowDatabases(db, nil)
 			if err != nil {
 				s.logger.Errorf("jobInfoRequest err at connect/showdatabases: %v", err.Error())
 				return nil, err
@@ -128,36 +146,96 @@ func (s *HTTPServer) jobListRequest(resp http.ResponseWriter, req *http.Request)
 		return nil, nil
 	}
 
+	selector, err := parseLabelSelector(req)
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
 	var out models.JobListResponse
 	if err := s.agent.RPC("Job.List", &args, &out); err != nil {
 		return nil, err
 	}
 
 	setMeta(resp, &out.QueryMeta)
-	if out.Jobs == nil {
-		out.Jobs = make([]*models.JobListStub, 0)
+	namespace := req.URL.Query().Get("namespace")
+	token := requestToken(req)
+	jobs := make([]*models.JobListStub, 0, len(out.Jobs))
+	for _, job := range out.Jobs {
+		if namespace != "" && job.Namespace != namespace {
+			continue
+		}
+		if !token.allowNamespace(job.Namespace) {
+			continue
+		}
+		if (&models.Job{Labels: job.Labels}).MatchLabels(selector) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// parseLabelSelector parses query parameters `label=key=value`.
+func parseLabelSelector(req *http.Request) (map[string]string, error) {
+	selector := make(map[string]string)
+	for _, label := range req.URL.Query()["label"] {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bad label %q, should be key=value", label)
+		}
+		selector[kv[0]] = kv[1]
 	}
-	return out.Jobs, nil
+	return selector, nil
 }
 
 func (s *HTTPServer) JobSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	path := strings.TrimPrefix(req.URL.Path, "/v1/job/")
+	jobName := path
+	var handler func(http.ResponseWriter, *http.Request, string) (interface{}, error)
 	switch {
 	case strings.HasSuffix(path, "/resume"):
-		jobName := strings.TrimSuffix(path, "/resume")
-		return s.jobResumeRequest(resp, req, jobName)
+		jobName = strings.TrimSuffix(path, "/resume")
+		handler = s.jobResumeRequest
 	case strings.HasSuffix(path, "/pause"):
-		jobName := strings.TrimSuffix(path, "/pause")
-		return s.jobPauseRequest(resp, req, jobName)
+		jobName = strings.TrimSuffix(path, "/pause")
+		handler = s.jobPauseRequest
 	case strings.HasSuffix(path, "/allocations"):
-		jobName := strings.TrimSuffix(path, "/allocations")
-		return s.jobAllocations(resp, req, jobName)
+		jobName = strings.TrimSuffix(path, "/allocations")
+		handler = s.jobAllocations
 	case strings.HasSuffix(path, "/evaluations"):
-		jobName := strings.TrimSuffix(path, "/evaluations")
-		return s.jobEvaluations(resp, req, jobName)
+		jobName = strings.TrimSuffix(path, "/evaluations")
+		handler = s.jobEvaluations
+	case strings.HasSuffix(path, "/inspect"):
+		jobName = strings.TrimSuffix(path, "/inspect")
+		handler = s.jobInspect
+	case strings.HasSuffix(path, "/bench"):
+		jobName = strings.TrimSuffix(path, "/bench")
+		handler = s.jobBench
+	case strings.HasSuffix(path, "/verify"):
+		jobName = strings.TrimSuffix(path, "/verify")
+		handler = s.jobVerify
+	case strings.HasSuffix(path, "/progress"):
+		jobName = strings.TrimSuffix(path, "/progress")
+		handler = s.jobProgress
+	case strings.HasSuffix(path, "/resync"):
+		jobName = strings.TrimSuffix(path, "/resync")
+		handler = s.jobResync
+	case strings.HasSuffix(path, "/schema"):
+		jobName = strings.TrimSuffix(path, "/schema")
+		handler = s.jobSchema
+	case strings.HasSuffix(path, "/export"):
+		jobName = strings.TrimSuffix(path, "/export")
+		handler = s.jobExport
+	case strings.HasSuffix(path, "/clone"):
+		jobName = strings.TrimSuffix(path, "/clone")
+		handler = s.jobClone
 	default:
-		return s.jobCRUD(resp, req, path)
+		handler = s.jobCRUD
+	}
+	auditTargets(req, "", jobName)
+	if err := s.checkJobNamespace(req, jobName); err != nil {
+		return nil, err
 	}
+	return handler(resp, req, jobName)
 }
 
 func (s *HTTPServer) jobAllocations(resp http.ResponseWriter, req *http.Request,
@@ -261,11 +339,24 @@ func (s *HTTPServer) jobQuery(resp http.ResponseWriter, req *http.Request,
 func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	jobName string) (interface{}, error) {
 	var args *api.Job
-	var trafficLimit int
 	if err := decodeBody(req, &args); err != nil {
 		return nil, CodedError(400, err.Error())
 	}
+	if args == nil {
+		return nil, CodedError(400, "Job hasn't been provided")
+	}
+	if simulate, _ := strconv.ParseBool(req.URL.Query().Get("simulate")); simulate {
+		return s.jobSimulate(req, args)
+	}
+	return s.registerJob(resp, req, args)
+}
 
+func (s *HTTPServer) registerJob(resp http.ResponseWriter, req *http.Request,
+	args *api.Job) (interface{}, error) {
+	var trafficLimit int
+	if args == nil {
+		return nil, CodedError(400, "Job hasn't been provided")
+	}
 	if args.Name == nil {
 		return nil, CodedError(400, "Job Name hasn't been provided")
 	}
@@ -297,6 +388,14 @@ func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	}
 
 	sJob := ApiJobToStructJob(args, trafficLimit)
+	auditTargets(req, sJob.Namespace, sJob.ID)
+	if err := checkNamespace(req, sJob.Namespace); err != nil {
+		return nil, err
+	}
+	// The job might be moved from another namespace.
+	if err := s.checkJobNamespace(req, sJob.ID); err != nil {
+		return nil, err
+	}
 
 	regReq := models.JobRegisterRequest{
 		Job:            sJob,
@@ -315,6 +414,83 @@ func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	return out, nil
 }
 
+func (s *HTTPServer) jobsBulkRegister(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	var args api.BulkJobRegisterRequest
+	if err := decodeBody(req, &args); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	results := make([]*api.BulkJobResult, 0, len(args.Jobs))
+	for _, job := range args.Jobs {
+		result := &api.BulkJobResult{}
+		if job != nil && job.ID != nil {
+			result.JobID = *job.ID
+		}
+		if _, err := s.registerJob(resp, req, job); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// jobsBulkAction does pause/resume/delete on jobs selected by IDs or labels.
+func (s *HTTPServer) jobsBulkAction(resp http.ResponseWriter, req *http.Request,
+	action string) (interface{}, error) {
+	var args api.BulkJobRequest
+	if err := decodeBody(req, &args); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	jobIDs := args.JobIDs
+	if len(args.Labels) > 0 {
+		listArgs := models.JobListRequest{}
+		listArgs.Region = s.agent.config.Region
+		s.parseRegion(req, &listArgs.Region)
+		var out models.JobListResponse
+		if err := s.agent.RPC("Job.List", &listArgs, &out); err != nil {
+			return nil, err
+		}
+		token := requestToken(req)
+		for _, job := range out.Jobs {
+			if !token.allowNamespace(job.Namespace) {
+				continue
+			}
+			if (&models.Job{Labels: job.Labels}).MatchLabels(args.Labels) {
+				jobIDs = append(jobIDs, job.ID)
+			}
+		}
+	}
+
+	results := make([]*api.BulkJobResult, 0, len(jobIDs))
+	seen := make(map[string]struct{})
+	for _, jobID := range jobIDs {
+		if _, ok := seen[jobID]; ok {
+			continue
+		}
+		seen[jobID] = struct{}{}
+
+		auditTargets(req, "", jobID)
+		err := s.checkJobNamespace(req, jobID)
+		if err == nil {
+			switch action {
+			case "pause":
+				_, err = s.jobPauseRequest(resp, req, jobID)
+			case "resume":
+				_, err = s.jobResumeRequest(resp, req, jobID)
+			case "delete":
+				_, err = s.jobDelete(resp, req, jobID)
+			}
+		}
+		result := &api.BulkJobResult{JobID: jobID}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
 func (s *HTTPServer) jobRenewalRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	var args *api.RenewalJobRequest
 	if err := decodeBody(req, &args); err != nil {
@@ -415,6 +591,9 @@ func (s *HTTPServer) ValidateJobRequest(resp http.ResponseWriter, req *http.Requ
 	}
 
 	job := ApiJobToStructJob(validateRequest.Job, 0)
+	if err := checkNamespace(req, job.Namespace); err != nil {
+		return nil, err
+	}
 	args := models.JobValidateRequest{
 		Job: job,
 		WriteRequest: models.WriteRequest{
@@ -440,24 +619,36 @@ func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 		ID:                *job.ID,
 		Orders:            job.Orders,
 		Name:              *job.Name,
+		Namespace:         *job.Namespace,
 		Failover:          job.Failover,
 		Type:              *job.Type,
+		Priority:          *job.Priority,
 		Datacenters:       job.Datacenters,
+		Labels:            job.Labels,
+		DependsOn:         job.DependsOn,
 		Status:            *job.Status,
 		StatusDescription: *job.StatusDescription,
 		CreateIndex:       *job.CreateIndex,
 		ModifyIndex:       *job.ModifyIndex,
 		JobModifyIndex:    *job.JobModifyIndex,
+		RestartPolicy: &models.RestartPolicy{
+			Attempts: *job.RestartPolicy.Attempts,
+			Interval: *job.RestartPolicy.Interval,
+			Delay:    *job.RestartPolicy.Delay,
+			Mode:     *job.RestartPolicy.Mode,
+		},
 	}
 
 	j.Tasks = make([]*models.Task, len(job.Tasks))
 	cfg := ""
+	var fullCopyMethod interface{}
 	for _, task := range job.Tasks {
 		if task.Type == models.TaskTypeSrc {
 			task.Config["TrafficAgainstLimits"] = trafficLimit
 			if task.Config["Gtid"] != nil {
 				cfg = fmt.Sprintf("%s", task.Config["Gtid"])
 			}
+			fullCopyMethod = task.Config["FullCopyMethod"]
 		}
 
 		if task.Driver == "" {
@@ -468,6 +659,9 @@ func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 		if task.Type == models.TaskTypeDest {
 			task.Leader = true
 			task.Config["Gtid"] = cfg
+			if fullCopyMethod != nil {
+				task.Config["FullCopyMethod"] = fullCopyMethod
+			}
 		}
 		t := models.NewTask()
 		ApiTaskToStructsTask(task, t)
@@ -484,4 +678,10 @@ func ApiTaskToStructsTask(apiTask *api.Task, structsTask *models.Task) {
 	structsTask.Driver = apiTask.Driver
 	structsTask.Leader = apiTask.Leader
 	structsTask.Config = apiTask.Config
+	if apiTask.Resources != nil {
+		structsTask.Resources = &models.Resources{
+			CPU:      apiTask.Resources.CPU,
+			MemoryMB: apiTask.Resources.MemoryMB,
+		}
+	}
 }
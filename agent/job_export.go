@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal"
+	"github.com/actiontech/dtle/internal/crash"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// exportVarRegexp matches the template variables, escaped in the exported configs.
+var exportVarRegexp = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// jobExport returns the spec of the job to register on another cluster, with
+// the secrets replaced by template variables.
+func (s *HTTPServer) jobExport(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	job, err := s.getJob(resp, req, jobName)
+	if job == nil || err != nil {
+		return nil, err
+	}
+	return exportJob(job), nil
+}
+
+// jobClone registers a copy of the job, with the passwords, and with the
+// overrides of the body applied.
+func (s *HTTPServer) jobClone(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	var overrides api.JobOverrides
+	if err := decodeBody(req, &overrides); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if overrides.Name == "" {
+		return nil, CodedError(400, "Name of the clone hasn't been provided")
+	}
+	if overrides.Name == jobName {
+		return nil, CodedError(400, "Name of the clone is the name of the job")
+	}
+
+	job, err := s.getJob(resp, req, jobName)
+	if job == nil || err != nil {
+		return nil, err
+	}
+	clone := jobSpec(job)
+	if err := overrides.Apply(clone); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	return s.registerJob(resp, req, clone)
+}
+
+func (s *HTTPServer) getJob(resp http.ResponseWriter, req *http.Request,
+	jobName string) (*models.Job, error) {
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+	return out.Job, nil
+}
+
+// jobSpec returns the spec of the job as registered, without the state of the
+// cluster: the status, the raft indexes, the nodes of the tasks and the task
+// configs set while running.
+func jobSpec(job *models.Job) *api.Job {
+	spec := &api.Job{
+		ID:          internal.StringToPtr(job.ID),
+		Name:        internal.StringToPtr(job.Name),
+		Namespace:   internal.StringToPtr(job.Namespace),
+		Orders:      job.Orders,
+		Failover:    job.Failover,
+		Type:        internal.StringToPtr(job.Type),
+		Priority:    internal.IntToPtr(job.Priority),
+		Datacenters: job.Datacenters,
+		DependsOn:   job.DependsOn,
+	}
+	if len(job.Labels) > 0 {
+		spec.Labels = make(map[string]string, len(job.Labels))
+		for k, v := range job.Labels {
+			spec.Labels[k] = v
+		}
+	}
+	if rp := job.RestartPolicy; rp != nil {
+		spec.RestartPolicy = &api.RestartPolicy{
+			Attempts: internal.IntToPtr(rp.Attempts),
+			Interval: internal.TimeToPtr(rp.Interval),
+			Delay:    internal.TimeToPtr(rp.Delay),
+			Mode:     internal.StringToPtr(rp.Mode),
+		}
+	}
+	for _, task := range job.Tasks {
+		t := &api.Task{
+			Type:   task.Type,
+			Driver: task.Driver,
+			Config: make(map[string]interface{}, len(task.Config)),
+		}
+		for k, v := range task.Config {
+			if !models.IsJobRuntimeConfigKey(k) {
+				t.Config[k] = copyConfigValue(v)
+			}
+		}
+		if task.Resources != nil {
+			t.Resources = &api.Resources{
+				CPU:      task.Resources.CPU,
+				MemoryMB: task.Resources.MemoryMB,
+			}
+		}
+		spec.Tasks = append(spec.Tasks, t)
+	}
+	return spec
+}
+
+func copyConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k := range v {
+			result[k] = copyConfigValue(v[k])
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i := range v {
+			result[i] = copyConfigValue(v[i])
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i := range v {
+			result[i] = copyConfigValue(v[i])
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// exportJob returns the spec of the job without the region and the orders of
+// the cluster. The secrets redacted in the crash reports are replaced by
+// template variables named after their task and their path, to give when the
+// job is imported: the non-empty passwords, tokens and keys, e.g.
+// ${Src_ConnectionConfig_Password}, and the user info of the URIs, e.g.
+// ${Src_URI_Userinfo}. The other template variables of the strings are escaped
+// as `$${name}`.
+func exportJob(job *models.Job) *api.Job {
+	spec := jobSpec(job)
+	spec.Orders = nil
+	for _, task := range spec.Tasks {
+		task.Config = exportConfig(task.Config, task.Type).(map[string]interface{})
+	}
+	return spec
+}
+
+func exportConfig(v interface{}, path string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			if s, ok := v[k].(string); ok && s != "" && crash.IsSecretKey(k) {
+				v[k] = fmt.Sprintf("${%s_%s}", path, k)
+			} else {
+				v[k] = exportConfig(v[k], path+"_"+k)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = exportConfig(v[i], fmt.Sprintf("%s_%d", path, i))
+		}
+		return v
+	case string:
+		v = exportVarRegexp.ReplaceAllString(v, "$$${0}")
+		return crash.ReplaceURLUserinfo(v, fmt.Sprintf("${%s_Userinfo}", path))
+	default:
+		return v
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func (s *HTTPServer) jobInspect(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+	return inspectJob(out.Job)
+}
+
+// inspectJob returns the job spec with the configs the tasks run with.
+func inspectJob(job *models.Job) (*api.JobInspection, error) {
+	inspection := &api.JobInspection{
+		ID:        job.ID,
+		Name:      job.Name,
+		Namespace: job.Namespace,
+		Type:      job.Type,
+		Priority:  job.Priority,
+		Labels:    job.Labels,
+		DependsOn: job.DependsOn,
+		Status:    job.Status,
+	}
+	for _, task := range job.Tasks {
+		taskConfig, err := inspectTaskConfig(task)
+		if err != nil {
+			return nil, err
+		}
+		inspection.Tasks = append(inspection.Tasks, &api.TaskInspection{
+			Type:     task.Type,
+			Driver:   task.Driver,
+			NodeName: task.NodeName,
+			Config:   taskConfig,
+		})
+	}
+	return inspection, nil
+}
+
+// inspectTaskConfig decodes the config of a MySQL task as the driver does, with the
+// defaults and the filters normalized. The configs of the other drivers are
+// returned as is. The secrets are redacted.
+func inspectTaskConfig(task *models.Task) (map[string]interface{}, error) {
+	if task.Driver != models.TaskDriverMySQL {
+		return redactConfig(task.Config), nil
+	}
+
+	var driverConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig = *driverConfig.SetDefault()
+	normalizeDataSources(driverConfig.ReplicateDoDb)
+	normalizeDataSources(driverConfig.ReplicateIgnoreDb)
+
+	b, err := json.Marshal(&driverConfig)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return redactConfig(result), nil
+}
+
+// normalizeDataSources fills the schema and the `where` of the tables, as the
+// extractor does.
+func normalizeDataSources(dataSources []*config.DataSource) {
+	for _, ds := range dataSources {
+		for _, t := range ds.Tables {
+			if t.TableSchema == "" {
+				t.TableSchema = ds.TableSchema
+			}
+			if t.Where == "" {
+				t.Where = "true"
+			}
+		}
+	}
+}
+
+// redactConfig returns a copy of the config with the secrets redacted as in
+// the crash reports: the values of the passwords, tokens and keys, and the
+// user info of the URIs, at any depth.
+func redactConfig(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	result, _ := crash.Redact(m).(map[string]interface{})
+	return result
+}
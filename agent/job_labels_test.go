@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func Test_parseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", "/v1/jobs", map[string]string{}, false},
+		{"two", "/v1/jobs?label=env%3Dprod&label=tenant%3Da%3Db", map[string]string{"env": "prod", "tenant": "a=b"}, false},
+		{"bad", "/v1/jobs?label=env", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseLabelSelector(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLabelSelector() = %v, want %v", got, tt.want)
+			}
+			job := &models.Job{Labels: map[string]string{"env": "prod", "tenant": "a=b", "x": "y"}}
+			if got != nil && !job.MatchLabels(got) {
+				t.Errorf("MatchLabels(%v) = false", got)
+			}
+		})
+	}
+	if (&models.Job{}).MatchLabels(map[string]string{"env": "prod"}) {
+		t.Errorf("MatchLabels() of a job without labels should be false")
+	}
+}
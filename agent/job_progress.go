@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobProgress returns how far the incremental replication of a job is behind
+// the source, from the stats of its running tasks.
+func (s *HTTPServer) jobProgress(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+
+	var allocsOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", &args, &allocsOut); err != nil {
+		return nil, err
+	}
+
+	result := &api.JobProgress{
+		JobID:  out.Job.ID,
+		Status: out.Job.Status,
+	}
+	progress := map[string]*models.IncrProgress{}
+	for _, task := range []string{models.TaskTypeSrc, models.TaskTypeDest} {
+		var alloc *models.AllocListStub
+		for _, a := range allocsOut.Allocations {
+			if a.Task == task && a.ClientStatus == models.AllocClientStatusRunning {
+				alloc = a
+				break
+			}
+		}
+		if alloc == nil {
+			result.StatsErrors = append(result.StatsErrors, fmt.Sprintf("no running alloc of task %v", task))
+			continue
+		}
+		taskStats, err := s.taskStats(req, args.Region, alloc, task)
+		if err != nil {
+			result.StatsErrors = append(result.StatsErrors, err.Error())
+			continue
+		}
+		if taskStats.IncrProgress == nil {
+			result.StatsErrors = append(result.StatsErrors,
+				fmt.Sprintf("task %v has not started the incremental replication", task))
+			continue
+		}
+		progress[task] = taskStats.IncrProgress
+	}
+	if err := fillJobProgress(result, progress[models.TaskTypeSrc], progress[models.TaskTypeDest]); err != nil {
+		result.StatsErrors = append(result.StatsErrors, err.Error())
+	}
+	return result, nil
+}
+
+// fillJobProgress fills the result with the progress of the Src and the Dest
+// tasks, either of which may be nil.
+func fillJobProgress(result *api.JobProgress, src, dest *models.IncrProgress) error {
+	if src != nil {
+		result.SourceGtidSet = src.SourceGtidSet
+		result.ReadGtidSet = src.GtidSet
+		result.ReadTransactionsBehind = src.TransactionsBehind
+		result.ReadSecondsBehind = src.SecondsBehind
+	}
+	if dest != nil {
+		result.AppliedGtidSet = dest.GtidSet
+		result.SecondsBehind = dest.SecondsBehind
+	}
+	if src == nil || dest == nil {
+		return nil
+	}
+	behind, err := base.CountGtidSetDiff(src.SourceGtidSet, dest.GtidSet)
+	if err != nil {
+		return err
+	}
+	result.TransactionsBehind = &behind
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestFillJobProgress(t *testing.T) {
+	const sid = "96fda9dc-7cbf-11e7-9340-0242ac110002"
+	src := &models.IncrProgress{
+		SourceGtidSet:      sid + ":1-100",
+		GtidSet:            sid + ":1-90",
+		TransactionsBehind: 10,
+		SecondsBehind:      3,
+	}
+	dest := &models.IncrProgress{
+		GtidSet:       sid + ":1-75",
+		SecondsBehind: 7,
+	}
+
+	result := &api.JobProgress{}
+	if err := fillJobProgress(result, src, dest); err != nil {
+		t.Fatal(err)
+	}
+	if result.TransactionsBehind == nil || *result.TransactionsBehind != 25 {
+		t.Errorf("TransactionsBehind = %v, want 25", result.TransactionsBehind)
+	}
+	if result.ReadTransactionsBehind != 10 || result.ReadSecondsBehind != 3 || result.SecondsBehind != 7 ||
+		result.AppliedGtidSet != dest.GtidSet || result.ReadGtidSet != src.GtidSet {
+		t.Errorf("result = %+v", result)
+	}
+
+	result = &api.JobProgress{}
+	if err := fillJobProgress(result, nil, dest); err != nil {
+		t.Fatal(err)
+	}
+	if result.TransactionsBehind != nil || result.SecondsBehind != 7 {
+		t.Errorf("result = %+v", result)
+	}
+
+	result = &api.JobProgress{}
+	if err := fillJobProgress(result, src, &models.IncrProgress{GtidSet: "bad"}); err == nil {
+		t.Errorf("expected an error of a bad gtid set")
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobResync copies a table of a running job again, while the job replicates the
+// other tables, by the Src task of the job.
+func (s *HTTPServer) jobResync(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if !(req.Method == "POST" || req.Method == "PUT") {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	tableParam := req.URL.Query().Get("table")
+	schema, table, err := parseResyncTable(tableParam)
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+
+	var allocsOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", &args, &allocsOut); err != nil {
+		return nil, err
+	}
+	var alloc *models.AllocListStub
+	for _, a := range allocsOut.Allocations {
+		if a.Task == models.TaskTypeSrc && a.ClientStatus == models.AllocClientStatusRunning {
+			alloc = a
+			break
+		}
+	}
+	if alloc == nil {
+		return nil, CodedErrorf(409, "no running alloc of task %v", models.TaskTypeSrc)
+	}
+
+	if client := s.agent.client; client != nil && client.Node().ID == alloc.NodeID {
+		err = client.ResyncAlloc(alloc.ID, models.TaskTypeSrc, schema, table)
+	} else {
+		err = s.remoteAllocResync(req, args.Region, alloc, tableParam)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &api.JobResync{
+		JobID:   out.Job.ID,
+		AllocID: alloc.ID,
+		Table:   tableParam,
+	}, nil
+}
+
+// remoteAllocResync requests the resync to the agent of the node of the alloc,
+// with the credentials of the request.
+func (s *HTTPServer) remoteAllocResync(req *http.Request, region string, alloc *models.AllocListStub,
+	table string) error {
+	addr, err := s.allocNodeAddr(region, alloc)
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("http://%s/v1/agent/allocation/%s/resync?task=%s&table=%s",
+		addr, alloc.ID, models.TaskTypeSrc, url.QueryEscape(table))
+	resyncReq, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	forwardCredentials(req, resyncReq)
+
+	resyncResp, err := cleanhttp.DefaultClient().Do(resyncReq)
+	if err != nil {
+		return err
+	}
+	defer resyncResp.Body.Close()
+	if resyncResp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resyncResp.Body)
+		return fmt.Errorf("resync of alloc %v on %v: %v %s", alloc.ID, addr, resyncResp.Status, body)
+	}
+	return nil
+}
+
+// parseResyncTable parses the `db.tb` of a resync.
+func parseResyncTable(s string) (schema, table string, err error) {
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("bad table %q, should be db.tb", s)
+	}
+	return parts[0], parts[1], nil
+}
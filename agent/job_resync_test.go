@@ -0,0 +1,27 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+)
+
+func TestParseResyncTable(t *testing.T) {
+	schema, table, err := parseResyncTable("db1.tb.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema != "db1" || table != "tb.1" {
+		t.Errorf("got %v %v, want db1 tb.1", schema, table)
+	}
+
+	for _, s := range []string{"", "db1", "db1.", ".tb1"} {
+		if _, _, err := parseResyncTable(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
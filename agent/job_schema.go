@@ -0,0 +1,262 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobSchema lists the databases and the tables of the source of the job, with
+// what the job replicates of them, from information_schema of the source.
+func (s *HTTPServer) jobSchema(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Job == nil {
+		return nil, CodedError(404, "job not found")
+	}
+
+	for _, task := range out.Job.Tasks {
+		if task.Type != models.TaskTypeSrc {
+			continue
+		}
+		if task.Driver != models.TaskDriverMySQL {
+			return nil, CodedErrorf(400, "listing the schema of a %v Src task is not supported", task.Driver)
+		}
+		if err := s.resolveConnectionProfile(out.Job.Namespace, task); err != nil {
+			return nil, err
+		}
+		var driverConfig config.MySQLDriverConfig
+		if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+			return nil, err
+		}
+		if driverConfig.ConnectionConfig == nil {
+			return nil, CodedError(400, "ConnectionConfig of the Src task hasn't been provided")
+		}
+		driverConfig = *driverConfig.SetDefault()
+
+		tables, err := sourceTables(&driverConfig)
+		if err != nil {
+			s.logger.Errorf("jobSchema err at reading information_schema: %v", err)
+			return nil, err
+		}
+		return &api.SourceSchema{
+			JobID:     out.Job.ID,
+			Databases: groupSourceTables(&driverConfig, tables),
+		}, nil
+	}
+	return nil, CodedError(400, "the job has no Src task")
+}
+
+// sourceTable is a table of the source, and the features of it read from
+// information_schema.
+type sourceTable struct {
+	schema string
+	*api.TableSchema
+	foreignKeys      bool
+	triggers         bool
+	generatedColumns bool
+}
+
+// sourceTables reads the schemas and the tables of the source from
+// information_schema. Each schema is also returned as a table with an empty
+// name, to list the schemas without tables.
+func sourceTables(driverConfig *config.MySQLDriverConfig) ([]*sourceTable, error) {
+	db, err := sql.CreateDB(driverConfig.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var tables []*sourceTable
+	byName := make(map[string]*sourceTable)
+	key := func(schema, table string) string {
+		return fmt.Sprintf("%s.%s", schema, table)
+	}
+	err = queryEach(db, `SELECT SCHEMA_NAME FROM information_schema.SCHEMATA`, func(rows *gosql.Rows) error {
+		t := &sourceTable{TableSchema: &api.TableSchema{}}
+		if err := rows.Scan(&t.schema); err != nil {
+			return err
+		}
+		tables = append(tables, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryEach(db, `SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, IFNULL(ENGINE, ''),
+		IFNULL(TABLE_ROWS, 0), IFNULL(DATA_LENGTH, 0), IFNULL(INDEX_LENGTH, 0)
+		FROM information_schema.TABLES`, func(rows *gosql.Rows) error {
+		t := &sourceTable{TableSchema: &api.TableSchema{}}
+		if err := rows.Scan(&t.schema, &t.Name, &t.Type, &t.Engine, &t.Rows, &t.DataBytes, &t.IndexBytes); err != nil {
+			return err
+		}
+		tables = append(tables, t)
+		byName[key(t.schema, t.Name)] = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryEach(db, `SELECT TABLE_SCHEMA, TABLE_NAME, CONSTRAINT_TYPE
+		FROM information_schema.TABLE_CONSTRAINTS`, func(rows *gosql.Rows) error {
+		var schema, table, constraintType string
+		if err := rows.Scan(&schema, &table, &constraintType); err != nil {
+			return err
+		}
+		if t, ok := byName[key(schema, table)]; ok {
+			switch constraintType {
+			case "PRIMARY KEY":
+				t.HasPrimaryKey = true
+			case "UNIQUE":
+				t.HasUniqueKey = true
+			case "FOREIGN KEY":
+				t.foreignKeys = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryEach(db, `SELECT DISTINCT EVENT_OBJECT_SCHEMA, EVENT_OBJECT_TABLE
+		FROM information_schema.TRIGGERS`, func(rows *gosql.Rows) error {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return err
+		}
+		if t, ok := byName[key(schema, table)]; ok {
+			t.triggers = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// EXTRA is DEFAULT_GENERATED for the columns defaulting to an expression
+	// on MySQL 8.0, which are not generated.
+	err = queryEach(db, `SELECT DISTINCT TABLE_SCHEMA, TABLE_NAME FROM information_schema.COLUMNS
+		WHERE EXTRA IN ('VIRTUAL GENERATED', 'STORED GENERATED')`, func(rows *gosql.Rows) error {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return err
+		}
+		if t, ok := byName[key(schema, table)]; ok {
+			t.generatedColumns = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func queryEach(db *gosql.DB, query string, onRow func(rows *gosql.Rows) error) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := onRow(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// groupSourceTables groups the tables by database, both sorted by name, without
+// the system schemas, and tells what the job replicates of them.
+func groupSourceTables(driverConfig *config.MySQLDriverConfig, tables []*sourceTable) []*api.DatabaseSchema {
+	databases := make(map[string]*api.DatabaseSchema)
+	for _, t := range tables {
+		if driverConfig.IsSystemSchema(t.schema) {
+			continue
+		}
+		database, ok := databases[t.schema]
+		if !ok {
+			database = &api.DatabaseSchema{Name: t.schema, Tables: []*api.TableSchema{}}
+			databases[t.schema] = database
+		}
+		if t.Name == "" {
+			continue
+		}
+		t.Replicated = replicatesTable(driverConfig, t.schema, t.Name)
+		t.Unsupported = unsupportedFeatures(driverConfig, t)
+		database.Tables = append(database.Tables, t.TableSchema)
+	}
+
+	result := make([]*api.DatabaseSchema, 0, len(databases))
+	for _, database := range databases {
+		sort.Slice(database.Tables, func(i, j int) bool {
+			return database.Tables[i].Name < database.Tables[j].Name
+		})
+		result = append(result, database)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// unsupportedFeatures returns what of the table the job doesn't replicate, or
+// replicates poorly.
+func unsupportedFeatures(driverConfig *config.MySQLDriverConfig, t *sourceTable) []string {
+	unsupported := []string{}
+	if t.Type == "VIEW" {
+		if !driverConfig.ExpandSyntaxSupport {
+			unsupported = append(unsupported, "view: copied only with ExpandSyntaxSupport")
+		}
+		return unsupported
+	}
+	if !t.HasPrimaryKey && !t.HasUniqueKey {
+		unsupported = append(unsupported, "no primary key or unique key: the full copy and the apply of UPDATE and DELETE are slow")
+	}
+	if t.Engine != "" && !strings.EqualFold(t.Engine, "InnoDB") {
+		unsupported = append(unsupported, fmt.Sprintf("engine %v: not transactional, the full copy is not consistent", t.Engine))
+	}
+	if t.triggers {
+		unsupported = append(unsupported, "triggers: not supported")
+	}
+	if t.foreignKeys {
+		unsupported = append(unsupported, "foreign keys: the changes cascaded are not in the binlog")
+	}
+	if t.generatedColumns {
+		unsupported = append(unsupported, "generated columns")
+	}
+	return unsupported
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func TestGroupSourceTables(t *testing.T) {
+	driverConfig := (&config.MySQLDriverConfig{
+		ReplicateIgnoreDb: []*config.DataSource{
+			{TableSchema: "db1", Tables: []*config.Table{{TableName: "ignored"}}},
+		},
+	}).SetDefault()
+	table := func(schema, name, engine string, pk bool) *sourceTable {
+		return &sourceTable{schema: schema, TableSchema: &api.TableSchema{
+			Name: name, Type: "BASE TABLE", Engine: engine, HasPrimaryKey: pk,
+		}}
+	}
+	view := table("db1", "v", "", false)
+	view.Type = "VIEW"
+	triggered := table("db1", "triggered", "InnoDB", true)
+	triggered.triggers = true
+	tables := []*sourceTable{
+		{schema: "mysql", TableSchema: &api.TableSchema{}},
+		{schema: "empty", TableSchema: &api.TableSchema{}},
+		{schema: "db1", TableSchema: &api.TableSchema{}},
+		table("mysql", "user", "InnoDB", true),
+		table("db1", "t", "InnoDB", true),
+		table("db1", "ignored", "MyISAM", false),
+		view,
+		triggered,
+	}
+
+	databases := groupSourceTables(driverConfig, tables)
+	if len(databases) != 2 || databases[0].Name != "db1" || databases[1].Name != "empty" {
+		t.Fatalf("databases = %+v", databases)
+	}
+	if len(databases[1].Tables) != 0 || databases[1].Tables == nil {
+		t.Errorf("tables of empty = %v", databases[1].Tables)
+	}
+
+	db1 := databases[0].Tables
+	var names []string
+	for _, tb := range db1 {
+		names = append(names, tb.Name)
+	}
+	if len(db1) != 4 || names[0] != "ignored" || names[1] != "t" || names[2] != "triggered" || names[3] != "v" {
+		t.Fatalf("tables of db1 = %v", names)
+	}
+	// ignored is MyISAM, without a key.
+	if db1[0].Replicated || len(db1[0].Unsupported) != 2 {
+		t.Errorf("ignored = %+v", db1[0])
+	}
+	if !db1[1].Replicated || len(db1[1].Unsupported) != 0 {
+		t.Errorf("t = %+v", db1[1])
+	}
+	if !db1[2].Replicated || len(db1[2].Unsupported) != 1 {
+		t.Errorf("triggered = %+v", db1[2])
+	}
+	if len(db1[3].Unsupported) != 1 {
+		t.Errorf("v = %+v", db1[3])
+	}
+
+	driverConfig.ExpandSyntaxSupport = true
+	if unsupported := unsupportedFeatures(driverConfig, view); len(unsupported) != 0 {
+		t.Errorf("unsupported of the view with ExpandSyntaxSupport = %v", unsupported)
+	}
+}
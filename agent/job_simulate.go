@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// fullCopyQueueSize is how many chunks of the full copy the dumper of the Src
+// task, and the applier of the Dest task, each queue at most.
+const fullCopyQueueSize = 24
+
+// jobSimulate estimates what the full copy of the job moves, how long it takes
+// and the memory it needs, from information_schema of the source, without
+// registering the job.
+func (s *HTTPServer) jobSimulate(req *http.Request, args *api.Job) (interface{}, error) {
+	if args.Name == nil {
+		return nil, CodedError(400, "Job Name hasn't been provided")
+	}
+	if args.Region == nil {
+		args.Region = &s.agent.config.Region
+	}
+	sJob := ApiJobToStructJob(args, 0)
+	if err := checkNamespace(req, sJob.Namespace); err != nil {
+		return nil, err
+	}
+
+	for _, task := range sJob.Tasks {
+		if task.Type != models.TaskTypeSrc {
+			continue
+		}
+		if task.Driver != models.TaskDriverMySQL {
+			return nil, CodedErrorf(400, "simulating a job of a %v Src task is not supported", task.Driver)
+		}
+		if err := s.resolveConnectionProfile(sJob.Namespace, task); err != nil {
+			return nil, err
+		}
+		var driverConfig config.MySQLDriverConfig
+		if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		if driverConfig.ConnectionConfig == nil {
+			return nil, CodedError(400, "ConnectionConfig of the Src task hasn't been provided")
+		}
+		driverConfig = *driverConfig.SetDefault()
+
+		tables, err := sourceTableEstimates(&driverConfig)
+		if err != nil {
+			s.logger.Errorf("jobSimulate err at reading information_schema: %v", err)
+			return nil, err
+		}
+		return simulateJob(&driverConfig, tables), nil
+	}
+	return nil, CodedError(400, "Src task hasn't been provided")
+}
+
+// sourceTableEstimates reads the size of the tables the extractor would copy
+// from information_schema of the source. The sizes are estimates of InnoDB.
+func sourceTableEstimates(driverConfig *config.MySQLDriverConfig) ([]*api.TableEstimate, error) {
+	db, err := sql.CreateDB(driverConfig.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT TABLE_SCHEMA, TABLE_NAME, IFNULL(TABLE_ROWS, 0), IFNULL(AVG_ROW_LENGTH, 0),
+		IFNULL(DATA_LENGTH, 0), IFNULL(INDEX_LENGTH, 0)
+		FROM information_schema.TABLES WHERE TABLE_TYPE = 'BASE TABLE'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []*api.TableEstimate
+	for rows.Next() {
+		t := &api.TableEstimate{}
+		if err := rows.Scan(&t.TableSchema, &t.TableName, &t.Rows, &t.AvgRowBytes,
+			&t.DataBytes, &t.IndexBytes); err != nil {
+			return nil, err
+		}
+		if replicatesTable(driverConfig, t.TableSchema, t.TableName) {
+			tables = append(tables, t)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// simulateJob sums the tables, the largest first, and projects the full copy
+// with the config of the Src task.
+func simulateJob(driverConfig *config.MySQLDriverConfig, tables []*api.TableEstimate) *api.JobSimulation {
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].DataBytes > tables[j].DataBytes
+	})
+	simulation := &api.JobSimulation{
+		Tables:             make([]*api.TableEstimate, 0, len(tables)),
+		BandwidthLimitMBps: driverConfig.BandwidthLimitMBps,
+	}
+	var maxAvgRowBytes int64
+	for _, t := range tables {
+		simulation.Tables = append(simulation.Tables, t)
+		simulation.Rows += t.Rows
+		simulation.DataBytes += t.DataBytes
+		simulation.IndexBytes += t.IndexBytes
+		if t.AvgRowBytes > maxAvgRowBytes {
+			maxAvgRowBytes = t.AvgRowBytes
+		}
+	}
+
+	if driverConfig.BandwidthLimitMBps > 0 {
+		rate := int64(driverConfig.BandwidthLimitMBps) * 1024 * 1024
+		simulation.ProjectedSeconds = (simulation.DataBytes + rate - 1) / rate
+	} else {
+		simulation.Warnings = append(simulation.Warnings,
+			"no BandwidthLimitMBps: the duration depends on the network and the target")
+	}
+
+	if driverConfig.AdaptiveChunkSize {
+		simulation.ChunkBytes = driverConfig.ChunkTargetBytes
+	} else {
+		simulation.ChunkBytes = driverConfig.ChunkSize * maxAvgRowBytes
+	}
+	simulation.PeakMemoryBytes = fullCopyQueueSize * simulation.ChunkBytes
+
+	if driverConfig.GtidBackupFile != "" || driverConfig.Gtid != "" {
+		simulation.Warnings = append(simulation.Warnings,
+			"the job starts from a gtid, so there is no full copy: only the sizes of the tables are reported")
+	}
+	return simulation
+}
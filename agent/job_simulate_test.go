@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func TestSimulateJob(t *testing.T) {
+	driverConfig := (&config.MySQLDriverConfig{
+		ChunkSize:          1000,
+		BandwidthLimitMBps: 10,
+	}).SetDefault()
+	tables := []*api.TableEstimate{
+		{TableSchema: "db1", TableName: "small", Rows: 10, AvgRowBytes: 100, DataBytes: 16 * 1024, IndexBytes: 0},
+		{TableSchema: "db1", TableName: "large", Rows: 1000000, AvgRowBytes: 50, DataBytes: 50 * 1024 * 1024, IndexBytes: 1024},
+	}
+
+	simulation := simulateJob(driverConfig, tables)
+	if simulation.Tables[0].TableName != "large" {
+		t.Errorf("tables not sorted: %v", simulation.Tables[0].TableName)
+	}
+	if simulation.Rows != 1000010 || simulation.DataBytes != 50*1024*1024+16*1024 || simulation.IndexBytes != 1024 {
+		t.Errorf("simulation = %+v", simulation)
+	}
+	// 50MB and 16KB at 10MB/s, rounded up.
+	if simulation.ProjectedSeconds != 6 {
+		t.Errorf("ProjectedSeconds = %v", simulation.ProjectedSeconds)
+	}
+	if simulation.ChunkBytes != 1000*100 || simulation.PeakMemoryBytes != fullCopyQueueSize*1000*100 {
+		t.Errorf("ChunkBytes = %v, PeakMemoryBytes = %v", simulation.ChunkBytes, simulation.PeakMemoryBytes)
+	}
+	if len(simulation.Warnings) != 0 {
+		t.Errorf("Warnings = %v", simulation.Warnings)
+	}
+}
+
+func TestSimulateJob_NoLimit(t *testing.T) {
+	driverConfig := (&config.MySQLDriverConfig{
+		AdaptiveChunkSize: true,
+		Gtid:              "00000000-0000-0000-0000-000000000000:1-10",
+	}).SetDefault()
+
+	simulation := simulateJob(driverConfig, nil)
+	if simulation.ProjectedSeconds != 0 || simulation.Tables == nil {
+		t.Errorf("simulation = %+v", simulation)
+	}
+	if simulation.ChunkBytes != driverConfig.ChunkTargetBytes {
+		t.Errorf("ChunkBytes = %v", simulation.ChunkBytes)
+	}
+	if len(simulation.Warnings) != 2 {
+		t.Errorf("Warnings = %v", simulation.Warnings)
+	}
+}
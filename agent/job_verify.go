@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobVerify returns the checks of the last round of a verify job.
+func (s *HTTPServer) jobVerify(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	job, err := s.getJob(resp, req, jobName)
+	if job == nil || err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeVerify {
+		return nil, CodedErrorf(400, "job %v is not a %v job", jobName, models.JobTypeVerify)
+	}
+
+	allocArgs := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	s.parseRegion(req, &allocArgs.Region)
+	var allocsOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", &allocArgs, &allocsOut); err != nil {
+		return nil, err
+	}
+
+	result := &api.VerifyResult{
+		JobID:  job.ID,
+		Status: job.Status,
+	}
+	alloc := verifyAlloc(allocsOut.Allocations)
+	if alloc == nil {
+		result.Passed = verifyPassed(allocsOut.Allocations)
+		if !result.Passed {
+			result.StatsErrors = append(result.StatsErrors, "no running alloc of the job")
+		}
+		return result, nil
+	}
+	taskStats, err := s.taskStats(req, allocArgs.Region, alloc, alloc.Task)
+	if err != nil {
+		result.StatsErrors = append(result.StatsErrors, err.Error())
+		return result, nil
+	}
+	fillVerifyResult(result, taskStats.Verify)
+	return result, nil
+}
+
+// verifyAlloc returns the running alloc whose stats have the checks of the
+// last round, the one of the Dest task if any.
+func verifyAlloc(allocs []*models.AllocListStub) *models.AllocListStub {
+	var alloc *models.AllocListStub
+	for _, a := range allocs {
+		if a.ClientStatus != models.AllocClientStatusRunning {
+			continue
+		}
+		if a.Task == models.TaskTypeDest {
+			return a
+		}
+		if a.Task == models.TaskTypeSrc {
+			alloc = a
+		}
+	}
+	return alloc
+}
+
+// verifyPassed returns whether a round of the job has passed, its tasks
+// completing only then.
+func verifyPassed(allocs []*models.AllocListStub) bool {
+	for _, a := range allocs {
+		if a.ClientStatus == models.AllocClientStatusComplete {
+			return true
+		}
+	}
+	return false
+}
+
+func fillVerifyResult(result *api.VerifyResult, stat *models.VerifyStat) {
+	if stat == nil {
+		return
+	}
+	result.Passed = stat.Passed
+	result.Rounds = stat.Rounds
+	result.LastRoundTime = stat.LastRoundTime
+	result.Error = stat.Error
+	for _, c := range stat.Checks {
+		result.Checks = append(result.Checks, &api.VerifyCheck{
+			Name:   c.Name,
+			Passed: c.Passed,
+			Source: c.Source,
+			Target: c.Target,
+			Error:  c.Error,
+		})
+	}
+}
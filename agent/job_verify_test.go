@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestVerifyAlloc(t *testing.T) {
+	allocs := []*models.AllocListStub{
+		{ID: "1", Task: models.TaskTypeDest, ClientStatus: models.AllocClientStatusFailed},
+		{ID: "2", Task: models.TaskTypeSrc, ClientStatus: models.AllocClientStatusRunning},
+	}
+	if alloc := verifyAlloc(allocs); alloc == nil || alloc.ID != "2" {
+		t.Errorf("alloc = %+v", alloc)
+	}
+	allocs = append(allocs, &models.AllocListStub{
+		ID: "3", Task: models.TaskTypeDest, ClientStatus: models.AllocClientStatusRunning,
+	})
+	if alloc := verifyAlloc(allocs); alloc == nil || alloc.ID != "3" {
+		t.Errorf("alloc = %+v", alloc)
+	}
+
+	if verifyPassed(allocs) {
+		t.Errorf("passed while running")
+	}
+	allocs = []*models.AllocListStub{
+		{ID: "4", Task: models.TaskTypeSrc, ClientStatus: models.AllocClientStatusComplete},
+		{ID: "5", Task: models.TaskTypeDest, ClientStatus: models.AllocClientStatusComplete},
+	}
+	if verifyAlloc(allocs) != nil || !verifyPassed(allocs) {
+		t.Errorf("the complete job did not pass")
+	}
+}
@@ -58,6 +58,7 @@ func (s *HTTPServer) nodeForceEvaluate(resp http.ResponseWriter, req *http.Reque
 	if req.Method != "PUT" && req.Method != "POST" {
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
+	auditTargets(req, "", nodeID)
 	args := models.NodeEvaluateRequest{
 		NodeID: nodeID,
 	}
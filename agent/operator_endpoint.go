@@ -7,16 +7,32 @@
 package agent
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/raft"
 
+	"github.com/actiontech/dtle/api"
 	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/storage"
+	"github.com/actiontech/dtle/internal/tlsutil"
 )
 
 func (s *HTTPServer) OperatorRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.URL.Path {
+	case "/v1/operator/snapshot":
+		return s.OperatorSnapshot(resp, req)
+	case "/v1/operator/snapshot/storage":
+		return s.OperatorSnapshotStorage(resp, req)
+	case "/v1/operator/ca/roots":
+		return s.OperatorCARoots(resp, req)
+	case "/v1/operator/ca/rotate":
+		return s.OperatorCARotate(resp, req)
+	}
 	path := strings.TrimPrefix(req.URL.Path, "/v1/operator/raft/")
 	switch {
 	case strings.HasPrefix(path, "configuration"):
@@ -121,3 +137,167 @@ func (s *HTTPServer) OperatorRaftPeer(resp http.ResponseWriter, req *http.Reques
 
 	return nil, nil
 }
+
+// OperatorSnapshot is used to save the server state as an archive on GET, and
+// to restore it from the archive in the body on PUT.
+func (s *HTTPServer) OperatorSnapshot(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		var args models.GenericRequest
+		if done := s.parse(resp, req, &args.Region, &args.QueryOptions); done {
+			return nil, nil
+		}
+
+		var reply models.SnapshotResponse
+		if err := s.agent.RPC("Operator.SnapshotSave", &args, &reply); err != nil {
+			return nil, err
+		}
+		setIndex(resp, reply.Index)
+		resp.Header().Set("Content-Type", "application/octet-stream")
+		resp.Write(reply.Snapshot)
+		return nil, nil
+
+	case "PUT", "POST":
+		var args models.SnapshotRestoreRequest
+		s.parseRegion(req, &args.Region)
+		body := req.Body
+		if key := req.URL.Query().Get("key"); key != "" {
+			// Restored from the storage of the agent instead of the body.
+			store, err := storage.New(s.agent.config.Storage)
+			if err != nil {
+				return nil, CodedError(400, err.Error())
+			}
+			r, err := store.Get(key)
+			if err != nil {
+				return nil, CodedErrorf(400, "Failed to get snapshot %v: %v", key, err)
+			}
+			defer r.Close()
+			body = r
+		}
+		snapshot, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, CodedErrorf(400, "Failed to read snapshot: %v", err)
+		}
+		if len(snapshot) == 0 {
+			return nil, CodedError(400, "Snapshot hasn't been provided")
+		}
+		args.Snapshot = snapshot
+
+		var reply models.GenericResponse
+		if err := s.agent.RPC("Operator.SnapshotRestore", &args, &reply); err != nil {
+			return nil, err
+		}
+		setIndex(resp, reply.Index)
+		return nil, nil
+
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+// snapshotStoragePrefix is the prefix of the keys of the snapshots in the
+// storage of the agent.
+const snapshotStoragePrefix = "snapshots/"
+
+// OperatorSnapshotStorage is used to save the server state as an archive in the
+// storage of the agent on PUT, and to list the archives there on GET.
+func (s *HTTPServer) OperatorSnapshotStorage(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		store, err := storage.New(s.agent.config.Storage)
+		if err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		keys, err := store.List(snapshotStoragePrefix)
+		if err != nil {
+			return nil, err
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+		return keys, nil
+
+	case "PUT", "POST":
+		store, err := storage.New(s.agent.config.Storage)
+		if err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		var args models.GenericRequest
+		if done := s.parse(resp, req, &args.Region, &args.QueryOptions); done {
+			return nil, nil
+		}
+
+		var reply models.SnapshotResponse
+		if err := s.agent.RPC("Operator.SnapshotSave", &args, &reply); err != nil {
+			return nil, err
+		}
+		// The keys sort by the time the archives are saved.
+		key := fmt.Sprintf("%v%v-%v.snap", snapshotStoragePrefix, args.Region,
+			time.Now().UTC().Format("20060102T150405Z"))
+		if err := store.Put(key, bytes.NewReader(reply.Snapshot)); err != nil {
+			return nil, fmt.Errorf("failed to put snapshot %v: %v", key, err)
+		}
+		setIndex(resp, reply.Index)
+		return &api.StoredSnapshot{
+			Key:   key,
+			URL:   store.URL(key),
+			Bytes: int64(len(reply.Snapshot)),
+		}, nil
+
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+// OperatorCARoots is used to list the roots of the CA issuing the certificates
+// of the RPC, with their fingerprints.
+func (s *HTTPServer) OperatorCARoots(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var args models.CARootsRequest
+	if done := s.parse(resp, req, &args.Region, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply models.CARootsResponse
+	if err := s.agent.RPC("CA.Roots", &args, &reply); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &reply.QueryMeta)
+
+	out := make([]*api.CARoot, 0, len(reply.Roots))
+	for _, root := range reply.Roots {
+		cert, err := tlsutil.ParseCertificate(root.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("bad CA root %v: %v", root.ID, err)
+		}
+		out = append(out, &api.CARoot{
+			ID:          root.ID,
+			Fingerprint: tlsutil.Fingerprint(cert),
+			Certificate: root.Certificate,
+			Active:      root.Active,
+			CreateTime:  root.CreateTime,
+			RotateTime:  root.RotateTime,
+		})
+	}
+	return out, nil
+}
+
+// OperatorCARotate is used to replace the active root of the CA by a new one.
+func (s *HTTPServer) OperatorCARotate(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var args models.CARotateRequest
+	s.parseRegion(req, &args.Region)
+
+	var reply models.GenericResponse
+	if err := s.agent.RPC("CA.Rotate", &args, &reply); err != nil {
+		return nil, err
+	}
+	setIndex(resp, reply.Index)
+	return nil, nil
+}
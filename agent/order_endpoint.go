@@ -251,6 +251,7 @@ func (s *HTTPServer) orderUpdate(resp http.ResponseWriter, req *http.Request,
 	s.parseRegion(req, args.Region)
 
 	sOrder := ApiOrderToStructOrder(args)
+	auditTargets(req, "", sOrder.ID)
 
 	regReq := models.OrderRegisterRequest{
 		Order:            sOrder,
@@ -271,6 +272,7 @@ func (s *HTTPServer) orderUpdate(resp http.ResponseWriter, req *http.Request,
 
 func (s *HTTPServer) orderDelete(resp http.ResponseWriter, req *http.Request,
 	orderName string) (interface{}, error) {
+	auditTargets(req, "", orderName)
 	args := models.OrderDeregisterRequest{
 		OrderID: orderName,
 	}
@@ -0,0 +1,119 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// startService connects to the init system supervising the server. With systemd
+// there is nothing to do until the server is ready.
+func (c *Command) startService() error {
+	return nil
+}
+
+// serviceReady tells systemd (Type=notify) the server has started, and keeps its
+// watchdog (WatchdogSec=) fed.
+func (c *Command) serviceReady() {
+	ok, err := sdNotify(fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid()))
+	if err != nil {
+		c.logger.Warnf("Error notifying systemd: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	interval, err := sdWatchdogInterval()
+	if err != nil {
+		c.logger.Warnf("Error reading systemd watchdog interval: %v", err)
+		return
+	}
+	if interval > 0 {
+		c.serviceDoneCh = make(chan struct{})
+		go c.sdWatchdog(interval/2, c.serviceDoneCh)
+	}
+}
+
+// serviceStopping tells systemd the server is shutting down.
+func (c *Command) serviceStopping() {
+	if c.serviceDoneCh != nil {
+		close(c.serviceDoneCh)
+	}
+	if _, err := sdNotify("STOPPING=1"); err != nil {
+		c.logger.Warnf("Error notifying systemd: %v", err)
+	}
+}
+
+func (c *Command) sdWatchdog(interval time.Duration, doneCh chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := sdNotify("WATCHDOG=1"); err != nil {
+				c.logger.Warnf("Error notifying systemd watchdog: %v", err)
+			}
+		case <-doneCh:
+			return
+		}
+	}
+}
+
+// sdNotify sends a state to the socket given by systemd in NOTIFY_SOCKET.
+// It returns false if the server is not started by systemd.
+func sdNotify(state string) (bool, error) {
+	addr := &net.UnixAddr{
+		Name: os.Getenv("NOTIFY_SOCKET"),
+		Net:  "unixgram",
+	}
+	if addr.Name == "" {
+		return false, nil
+	}
+
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sdWatchdogInterval returns the watchdog interval set by systemd for this
+// process, or 0 if the watchdog is not enabled.
+func sdWatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("bad WATCHDOG_USEC %q", usecStr)
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("bad WATCHDOG_PID %q", pidStr)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
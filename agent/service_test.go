@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if ok, err := sdNotify("READY=1"); ok || err != nil {
+		t.Errorf("sdNotify() without systemd = %v, %v", ok, err)
+	}
+
+	dir, err := ioutil.TempDir("", "dtle-sdnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram(addr.Net, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	if ok, err := sdNotify("READY=1"); !ok || err != nil {
+		t.Fatalf("sdNotify() = %v, %v", ok, err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Setenv("WATCHDOG_USEC", "3000000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if d, err := sdWatchdogInterval(); err != nil || d != 3*time.Second {
+		t.Errorf("sdWatchdogInterval() = %v, %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d, err := sdWatchdogInterval(); err != nil || d != 0 {
+		t.Errorf("sdWatchdogInterval() for another pid = %v, %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "abc")
+	if _, err := sdWatchdogInterval(); err == nil {
+		t.Errorf("sdWatchdogInterval() should fail on bad WATCHDOG_USEC")
+	}
+}
@@ -0,0 +1,99 @@
+//go:build windows
+// +build windows
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"sync"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// serviceName is used if the server is not started by the service control
+// manager with a name.
+const serviceName = "dtle"
+
+// winService is set if the server runs as a windows service.
+var winService *windowsService
+
+// windowsService reports the state of the server to the service control manager,
+// and passes stop requests to the server.
+type windowsService struct {
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	readyCh  chan struct{}
+	doneCh   chan struct{}
+}
+
+func (w *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.StartPending}
+
+	readyCh := w.readyCh
+	for {
+		select {
+		case <-readyCh:
+			s <- svc.Status{State: svc.Running, Accepts: accepts}
+			readyCh = nil
+		case <-w.doneCh:
+			return false, 0
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Interrogate:
+				s <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				w.stopOnce.Do(func() {
+					close(w.stopCh)
+				})
+			}
+		}
+	}
+}
+
+// startService runs the server as a windows service, if it is started by the
+// service control manager.
+func (c *Command) startService() error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return err
+	}
+	if interactive {
+		return nil
+	}
+
+	w := &windowsService{
+		stopCh:  make(chan struct{}),
+		readyCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	winService = w
+	c.serviceStopCh = w.stopCh
+	go func() {
+		if err := svc.Run(serviceName, w); err != nil {
+			c.logger.Errorf("Error running as windows service: %v", err)
+		}
+	}()
+	return nil
+}
+
+// serviceReady reports the service is running.
+func (c *Command) serviceReady() {
+	if winService != nil {
+		close(winService.readyCh)
+	}
+}
+
+// serviceStopping reports the service is stopped. It is called at last, as the
+// process might be ended once the service is stopped.
+func (c *Command) serviceStopping() {
+	if winService != nil {
+		close(winService.doneCh)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// GarbageCollectRequest collects the terminal evaluations, allocations, jobs
+// and down nodes immediately.
+func (s *HTTPServer) GarbageCollectRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var args models.GenericRequest
+	s.parseRegion(req, &args.Region)
+
+	var out models.GarbageCollectResponse
+	if err := s.agent.RPC("System.GarbageCollect", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return out, nil
+}
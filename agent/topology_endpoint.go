@@ -0,0 +1,305 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// topologyStatsTimeout bounds the request of the stats of an alloc to the agent
+// running it.
+const topologyStatsTimeout = 5 * time.Second
+
+// TopologyRequest lists the jobs replicating the table of the query param
+// `table=schema.table`, with their targets, lag and last apply time.
+func (s *HTTPServer) TopologyRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	schema, table, err := parseSchemaTable(req.URL.Query().Get("table"))
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	args := models.JobListRequest{}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.JobListResponse
+	if err := s.agent.RPC("Job.List", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+
+	topology := &api.TableTopology{
+		TableSchema: schema,
+		TableName:   table,
+		Jobs:        make([]*api.TableReplication, 0),
+	}
+	for _, stub := range out.Jobs {
+		jobArgs := models.JobSpecificRequest{
+			JobID: stub.ID,
+		}
+		jobArgs.Region = args.Region
+		jobArgs.AllowStale = args.AllowStale
+		var jobOut models.SingleJobResponse
+		if err := s.agent.RPC("Job.GetJob", &jobArgs, &jobOut); err != nil {
+			return nil, err
+		}
+		if jobOut.Job == nil {
+			// Deleted since listed.
+			continue
+		}
+		replication, err := tableReplication(jobOut.Job, schema, table)
+		if err != nil {
+			return nil, err
+		}
+		if replication == nil {
+			continue
+		}
+		if err := s.replicationStats(req, &jobArgs, replication); err != nil {
+			return nil, err
+		}
+		topology.Jobs = append(topology.Jobs, replication)
+	}
+	return topology, nil
+}
+
+// parseSchemaTable parses `schema.table`.
+func parseSchemaTable(s string) (schema, table string, err error) {
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("bad table %q, should be schema.table", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// tableReplication returns the job with its Src and Dest, or nil if the MySQL Src
+// task of the job does not replicate the table.
+func tableReplication(job *models.Job, schema, table string) (*api.TableReplication, error) {
+	replication := &api.TableReplication{
+		JobID:     job.ID,
+		JobName:   job.Name,
+		Namespace: job.Namespace,
+		Status:    job.Status,
+	}
+	replicates := false
+	for _, task := range job.Tasks {
+		var driverConfig config.MySQLDriverConfig
+		if task.Driver == models.TaskDriverMySQL {
+			if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+				return nil, err
+			}
+		}
+		switch task.Type {
+		case models.TaskTypeSrc:
+			if task.Driver != models.TaskDriverMySQL {
+				return nil, nil
+			}
+			replicates = replicatesTable(&driverConfig, schema, table)
+			replication.Source = connectionAddress(&driverConfig)
+		case models.TaskTypeDest:
+			replication.TargetDriver = task.Driver
+			if task.Driver == models.TaskDriverMySQL {
+				replication.Target = connectionAddress(&driverConfig)
+			}
+		}
+	}
+	if !replicates {
+		return nil, nil
+	}
+	return replication, nil
+}
+
+// replicatesTable returns whether the extractor replicates the table, as
+// inspectTables selects the tables: those of ReplicateDoDb, or all the tables of
+// the non system schemas except those of ReplicateIgnoreDb.
+func replicatesTable(driverConfig *config.MySQLDriverConfig, schema, table string) bool {
+	if len(driverConfig.ReplicateDoDb) > 0 {
+		for _, doDb := range driverConfig.ReplicateDoDb {
+			if doDb.TableSchema != schema {
+				continue
+			}
+			if len(doDb.Tables) == 0 {
+				return true
+			}
+			for _, doTb := range doDb.Tables {
+				if doTb.TableName == table {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if driverConfig.IsSystemSchema(schema) {
+		return false
+	}
+	for _, ignoreDb := range driverConfig.ReplicateIgnoreDb {
+		if ignoreDb.TableSchema != schema {
+			continue
+		}
+		if len(ignoreDb.Tables) == 0 {
+			return false
+		}
+		for _, ignoreTb := range ignoreDb.Tables {
+			if ignoreTb.TableName == table {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func connectionAddress(driverConfig *config.MySQLDriverConfig) string {
+	if driverConfig.ConnectionConfig == nil || driverConfig.ConnectionConfig.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", driverConfig.ConnectionConfig.Host, driverConfig.ConnectionConfig.Port)
+}
+
+// replicationStats fills the lag and the last apply time of the Dest alloc
+// running. Failing to get the stats of the alloc is reported in StatsError.
+func (s *HTTPServer) replicationStats(req *http.Request, jobArgs *models.JobSpecificRequest,
+	replication *api.TableReplication) error {
+	var allocsOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", jobArgs, &allocsOut); err != nil {
+		return err
+	}
+	var alloc *models.AllocListStub
+	for _, a := range allocsOut.Allocations {
+		if a.Task == models.TaskTypeDest && a.ClientStatus == models.AllocClientStatusRunning {
+			alloc = a
+			break
+		}
+	}
+	if alloc == nil {
+		return nil
+	}
+	replication.AllocID = alloc.ID
+	replication.NodeID = alloc.NodeID
+
+	stats, err := s.taskStats(req, jobArgs.Region, alloc, models.TaskTypeDest)
+	if err != nil {
+		replication.StatsError = err.Error()
+		return nil
+	}
+	if stats.DelayCount != nil {
+		replication.LagSeconds = stats.DelayCount.Time
+	}
+	replication.LastApplyTime = stats.LastApplyTime
+	return nil
+}
+
+// taskStats returns the latest stats of the task of the alloc, from the client
+// of this agent if it runs the alloc, else from the agent of its node.
+func (s *HTTPServer) taskStats(req *http.Request, region string, alloc *models.AllocListStub,
+	task string) (*models.TaskStatistics, error) {
+	var allocStats *models.AllocStatistics
+	if client := s.agent.client; client != nil && client.Node().ID == alloc.NodeID {
+		aStats, err := client.StatsReporter().GetAllocStats(alloc.ID)
+		if err != nil {
+			return nil, err
+		}
+		if allocStats, err = aStats.LatestAllocStats(task); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if allocStats, err = s.remoteAllocStats(req, region, alloc, task); err != nil {
+			return nil, err
+		}
+	}
+	stats, ok := allocStats.Tasks[task]
+	if !ok || stats == nil {
+		return nil, fmt.Errorf("no stats of task %v of alloc %v yet", task, alloc.ID)
+	}
+	return stats, nil
+}
+
+// remoteAllocStats requests the stats of the alloc to the agent of its node, with
+// the credentials of the request.
+func (s *HTTPServer) remoteAllocStats(req *http.Request, region string, alloc *models.AllocListStub,
+	task string) (*models.AllocStatistics, error) {
+	addr, err := s.allocNodeAddr(region, alloc)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("http://%s/v1/agent/allocation/%s/stats?task=%s", addr, alloc.ID, task)
+	statsReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	forwardCredentials(req, statsReq)
+
+	httpClient := cleanhttp.DefaultClient()
+	httpClient.Timeout = topologyStatsTimeout
+	statsResp, err := httpClient.Do(statsReq)
+	if err != nil {
+		return nil, err
+	}
+	defer statsResp.Body.Close()
+	if statsResp.StatusCode != 200 {
+		return nil, fmt.Errorf("stats of alloc %v from %v: %v", alloc.ID, addr, statsResp.Status)
+	}
+	var allocStats models.AllocStatistics
+	if err := json.NewDecoder(statsResp.Body).Decode(&allocStats); err != nil {
+		return nil, err
+	}
+	return &allocStats, nil
+}
+
+// allocNodeAddr returns the http addr of the agent of the node of the alloc.
+func (s *HTTPServer) allocNodeAddr(region string, alloc *models.AllocListStub) (string, error) {
+	nodeArgs := models.NodeSpecificRequest{
+		NodeID: alloc.NodeID,
+	}
+	nodeArgs.Region = region
+	var nodeOut models.SingleNodeResponse
+	if err := s.agent.RPC("Node.GetNode", &nodeArgs, &nodeOut); err != nil {
+		return "", err
+	}
+	node := nodeOut.Node
+	if node == nil {
+		return "", fmt.Errorf("node %v not found", alloc.NodeID)
+	}
+	if node.Status == models.NodeStatusDown {
+		return "", fmt.Errorf("node %v is down", node.Name)
+	}
+	if node.HTTPAddr == "" {
+		return "", fmt.Errorf("http addr of node %v is not advertised", node.Name)
+	}
+	return node.HTTPAddr, nil
+}
+
+// forwardCredentials sets the credentials of req on a request to another agent.
+func forwardCredentials(req, forwarded *http.Request) {
+	for _, header := range []string{"Authorization", "Cookie"} {
+		if v := req.Header.Get(header); v != "" {
+			forwarded.Header.Set(header, v)
+		}
+	}
+	if secret := requestSecret(req); secret != "" {
+		forwarded.Header.Set(aclTokenHeader, secret)
+	}
+}
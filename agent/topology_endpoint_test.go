@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestParseSchemaTable(t *testing.T) {
+	schema, table, err := parseSchemaTable("db1.tb.1")
+	if err != nil || schema != "db1" || table != "tb.1" {
+		t.Errorf("got %q %q %v", schema, table, err)
+	}
+	for _, s := range []string{"", "db1", "db1.", ".tb1"} {
+		if _, _, err := parseSchemaTable(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestTableReplication(t *testing.T) {
+	job := &models.Job{
+		ID:   "job1",
+		Name: "job1",
+		Tasks: []*models.Task{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ReplicateDoDb": []map[string]interface{}{{
+					"TableSchema": "db1",
+					"Tables":      []map[string]interface{}{{"TableName": "tb1"}},
+				}, {
+					"TableSchema": "db2",
+				}},
+				"ConnectionConfig": map[string]interface{}{"Host": "10.0.0.1", "Port": 3306},
+			},
+		}, {
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ConnectionConfig": map[string]interface{}{"Host": "10.0.0.2", "Port": 3307},
+			},
+		}},
+	}
+
+	tests := []struct {
+		schema, table string
+		want          bool
+	}{
+		{"db1", "tb1", true},
+		{"db1", "tb2", false},
+		{"db2", "tb2", true},
+		{"db3", "tb1", false},
+	}
+	for _, tt := range tests {
+		replication, err := tableReplication(job, tt.schema, tt.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (replication != nil) != tt.want {
+			t.Errorf("%v.%v: got %v, want %v", tt.schema, tt.table, replication != nil, tt.want)
+		}
+	}
+
+	replication, _ := tableReplication(job, "db1", "tb1")
+	if replication.Source != "10.0.0.1:3306" || replication.Target != "10.0.0.2:3307" ||
+		replication.TargetDriver != models.TaskDriverMySQL {
+		t.Errorf("replication = %+v", replication)
+	}
+}
+
+func TestTableReplication_IgnoreDb(t *testing.T) {
+	job := &models.Job{
+		ID: "job1",
+		Tasks: []*models.Task{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ReplicateIgnoreDb": []map[string]interface{}{{
+					"TableSchema": "db1",
+					"Tables":      []map[string]interface{}{{"TableName": "tb1"}},
+				}, {
+					"TableSchema": "db2",
+				}},
+			},
+		}, {
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverKafka,
+			Config: map[string]interface{}{},
+		}},
+	}
+
+	tests := []struct {
+		schema, table string
+		want          bool
+	}{
+		{"db1", "tb1", false},
+		{"db1", "tb2", true},
+		{"db2", "tb2", false},
+		{"db3", "tb1", true},
+		{"mysql", "user", false},
+	}
+	for _, tt := range tests {
+		replication, err := tableReplication(job, tt.schema, tt.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (replication != nil) != tt.want {
+			t.Errorf("%v.%v: got %v, want %v", tt.schema, tt.table, replication != nil, tt.want)
+		}
+		if replication != nil && (replication.Target != "" || replication.TargetDriver != models.TaskDriverKafka) {
+			t.Errorf("replication = %+v", replication)
+		}
+	}
+}
+
+func TestTableReplication_SystemSchemas(t *testing.T) {
+	job := &models.Job{
+		ID: "job1",
+		Tasks: []*models.Task{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"SystemSchemas": []string{"mysql", "information_schema", "performance_schema"},
+			},
+		}, {
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{},
+		}},
+	}
+
+	tests := []struct {
+		schema, table string
+		want          bool
+	}{
+		{"sys", "sys_config", true},
+		{"mysql", "user", false},
+		{"db1", "tb1", true},
+	}
+	for _, tt := range tests {
+		replication, err := tableReplication(job, tt.schema, tt.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (replication != nil) != tt.want {
+			t.Errorf("%v.%v: got %v, want %v", tt.schema, tt.table, replication != nil, tt.want)
+		}
+	}
+}
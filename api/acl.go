@@ -0,0 +1,37 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// ACL is used to query the ACL endpoints.
+type ACL struct {
+	client *Client
+}
+
+// ACL returns a handle on the ACL endpoints.
+func (c *Client) ACL() *ACL {
+	return &ACL{client: c}
+}
+
+// Self returns who the client is authenticated as, and what it is allowed to do.
+func (a *ACL) Self(q *QueryOptions) (*ACLIdentity, error) {
+	var resp ACLIdentity
+	if _, err := a.client.query("/v1/acl/self", &resp, q); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ACLIdentity is who a request is made by: an ACL token, or a user of LDAP or
+// OIDC.
+type ACLIdentity struct {
+	// Identity is "management", "namespace:" and the namespaces of a token, the
+	// user, e.g. "ldap:alice", or "anonymous" without ACLs.
+	Identity   string
+	Management bool
+	// Namespaces are the namespaces whose jobs can be managed, if not Management.
+	Namespaces []string
+}
@@ -7,8 +7,13 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // Agent encapsulates an API client which talks to Udup's
@@ -123,6 +128,82 @@ func (a *Agent) Region() (string, error) {
 	return a.region, err
 }
 
+// Health queries the health of the agent components. A response with 503 is
+// decoded as well, with Healthy being false.
+func (a *Agent) Health() (*AgentHealth, error) {
+	r, err := a.client.newRequest("GET", "/v1/agent/health")
+	if err != nil {
+		return nil, err
+	}
+	_, resp, err := a.client.doRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		var buf bytes.Buffer
+		io.Copy(&buf, resp.Body)
+		return nil, fmt.Errorf("Unexpected response code: %d (%s)", resp.StatusCode, buf.Bytes())
+	}
+
+	var out AgentHealth
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClientStats queries the resource usage of the host of the agent, and of each
+// of its allocations.
+func (a *Agent) ClientStats() (*ClientStats, error) {
+	var out ClientStats
+	if _, err := a.client.query("/v1/client/stats", &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DebugBundle returns a tar.gz of the profiles of the agent over the duration:
+// the heap at the start and at the end, the CPU profile, the execution trace
+// and the goroutines. The agent should have enable_debug. The caller must
+// close the reader.
+func (a *Agent) DebugBundle(duration time.Duration) (io.ReadCloser, error) {
+	r, err := a.client.newRequest("GET", "/v1/agent/debug/bundle")
+	if err != nil {
+		return nil, err
+	}
+	r.params.Set("seconds", strconv.Itoa(int(duration/time.Second)))
+
+	_, resp, err := requireOK(a.client.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CrashReport is a crash report of the agent, written when it panicked.
+type CrashReport struct {
+	Name       string
+	Size       int64
+	ModifyTime time.Time
+}
+
+// CrashReports returns the crash reports of the agent, the oldest first.
+func (a *Agent) CrashReports() ([]*CrashReport, error) {
+	var resp []*CrashReport
+	_, err := a.client.query("/v1/agent/crash-reports", &resp, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CrashReport returns a crash report of the agent as a tar.gz. The caller must
+// close the reader.
+func (a *Agent) CrashReport(name string) (io.ReadCloser, error) {
+	return a.client.rawQuery("/v1/agent/crash-reports/"+name, nil)
+}
+
 // Members is used to query all of the known server members
 func (a *Agent) Members() (*ServerMembers, error) {
 	var resp *ServerMembers
@@ -144,7 +225,7 @@ func (a *Agent) ForceLeave(node string) error {
 // Servers is used to query the list of servers on a client node.
 func (a *Agent) Servers() ([]string, error) {
 	var resp []string
-	_, err := a.client.query("/v1/servers", &resp, nil)
+	_, err := a.client.query("/v1/managers", &resp, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -159,10 +240,24 @@ func (a *Agent) SetServers(addrs []string) error {
 		v.Add("address", addr)
 	}
 
-	_, err := a.client.write("/v1/servers?"+v.Encode(), nil, nil, nil)
+	_, err := a.client.write("/v1/managers?"+v.Encode(), nil, nil, nil)
 	return err
 }
 
+// AgentHealth is the health of the agent components and the running tasks.
+type AgentHealth struct {
+	Healthy    bool                        `json:"healthy"`
+	Components map[string]*ComponentHealth `json:"components"`
+	Jobs       map[string]*ComponentHealth `json:"jobs"`
+}
+
+// ComponentHealth is the health of a component. Message tells why it is
+// unhealthy.
+type ComponentHealth struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message"`
+}
+
 // joinResponse is used to decode the response we get while
 // sending a member join request.
 type joinResponse struct {
@@ -216,3 +311,65 @@ func (a AgentMembersNameSort) Less(i, j int) bool {
 	return a[i].Name < a[j].Name
 
 }
+
+// ClientStats is the resource usage of the host of an agent, and of each of its
+// allocations.
+type ClientStats struct {
+	NodeID string
+	Host   *HostStats
+	Allocs []*AllocResourceUsage
+}
+
+// HostStats is the resource usage of a host. The CPU usage is the one since the
+// previous query.
+type HostStats struct {
+	Timestamp int64
+	Uptime    uint64
+	CPU       *HostCPUStats
+	Memory    *HostMemoryStats
+	Disk      *HostDiskStats
+	Network   *HostNetworkStats
+}
+
+type HostCPUStats struct {
+	Cores   int
+	Percent float64
+}
+
+type HostMemoryStats struct {
+	Total     uint64
+	Available uint64
+	Used      uint64
+	Free      uint64
+}
+
+type HostDiskStats struct {
+	Path        string
+	Size        uint64
+	Used        uint64
+	Available   uint64
+	UsedPercent float64
+}
+
+type HostNetworkStats struct {
+	BytesSent   uint64
+	BytesRecv   uint64
+	PacketsSent uint64
+	PacketsRecv uint64
+}
+
+// AllocResourceUsage is the resource usage of the tasks of an allocation.
+type AllocResourceUsage struct {
+	AllocID      string
+	JobID        string
+	ClientStatus string
+	Tasks        map[string]*TaskResourceUsage
+}
+
+// TaskResourceUsage is the resource usage of the process of a task, with Pid 0
+// if it runs in the agent process.
+type TaskResourceUsage struct {
+	Pid        int
+	CPUPercent float64
+	MemoryRSS  uint64
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// Audit is used to query the audit log of the changes requested through the
+// HTTP API.
+type Audit struct {
+	client *Client
+}
+
+// Audit returns a handle on the audit log.
+func (c *Client) Audit() *Audit {
+	return &Audit{client: c}
+}
+
+// List is used to list the audit events, the oldest first. The events can be
+// filtered with the "target", "namespace", "since" and "until" params of the
+// query options.
+func (a *Audit) List(q *QueryOptions) ([]*AuditEvent, *QueryMeta, error) {
+	var resp []*AuditEvent
+	qm, err := a.client.query("/v1/audit", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// AuditEvent records a change requested through the HTTP API.
+type AuditEvent struct {
+	ID         string
+	Time       int64
+	Identity   string
+	Accessor   string
+	RemoteAddr string
+	Agent      string
+	Method     string
+	Path       string
+	Targets    []string
+	Namespace  string
+	Code       int
+	Error      string
+}
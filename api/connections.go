@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// Connections is used to manage the connection profiles, which the MySQL tasks
+// reference by name with ConnectionProfile instead of a ConnectionConfig.
+type Connections struct {
+	client *Client
+}
+
+// Connections returns a handle on the connection profiles.
+func (c *Client) Connections() *Connections {
+	return &Connections{client: c}
+}
+
+// ConnectionProfile is a named connection to a MySQL server.
+type ConnectionProfile struct {
+	Name string
+	// Namespace is the namespace of the jobs allowed to use the profile,
+	// "default" if empty.
+	Namespace string
+	Host      string
+	Port      int
+	User      string
+	// Password is never returned.
+	Password string
+	// PasswordVaultPath, exclusive with Password, is the path in Vault of the
+	// secret whose field "password" is the password, read by the agents
+	// running the tasks.
+	PasswordVaultPath string
+	Charset           string
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// List is used to list the profiles, without their passwords.
+func (c *Connections) List(q *QueryOptions) ([]*ConnectionProfile, *QueryMeta, error) {
+	var resp []*ConnectionProfile
+	qm, err := c.client.query("/v1/connections", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// Info is used to get a profile, without its password.
+func (c *Connections) Info(name string, q *QueryOptions) (*ConnectionProfile, *QueryMeta, error) {
+	var resp ConnectionProfile
+	qm, err := c.client.query("/v1/connection/"+name, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Upsert is used to create or replace a profile. The tasks referencing it get
+// the new connection when they restart.
+func (c *Connections) Upsert(profile *ConnectionProfile, q *WriteOptions) (*WriteMeta, error) {
+	return c.client.write("/v1/connection/"+profile.Name, profile, nil, q)
+}
+
+// Delete is used to delete a profile no job references.
+func (c *Connections) Delete(name string, q *WriteOptions) (*WriteMeta, error) {
+	return c.client.delete("/v1/connection/"+name, nil, q)
+}
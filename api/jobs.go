@@ -7,10 +7,13 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/actiontech/dtle/internal"
 	"github.com/actiontech/dtle/internal/models"
@@ -48,8 +51,7 @@ func (j *Jobs) Register(job *Job, q *WriteOptions) (string, *WriteMeta, error) {
 
 	var resp registerJobResponse
 
-	req := &RegisterJobRequest{Job: job}
-	wm, err := j.client.write("/v1/jobs", req, &resp, q)
+	wm, err := j.client.write("/v1/jobs", job, &resp, q)
 	if err != nil {
 		return "", nil, err
 	}
@@ -57,16 +59,16 @@ func (j *Jobs) Register(job *Job, q *WriteOptions) (string, *WriteMeta, error) {
 }
 
 // EnforceRegister is used to register a job enforcing its job modify index.
+// A modifyIndex of 0 only registers a new job. Registering the same spec as
+// the existing job is a no-op.
 func (j *Jobs) EnforceRegister(job *Job, modifyIndex uint64, q *WriteOptions) (string, *WriteMeta, error) {
 
 	var resp registerJobResponse
 
-	req := &RegisterJobRequest{
-		Job:            job,
-		EnforceIndex:   true,
-		JobModifyIndex: modifyIndex,
-	}
-	wm, err := j.client.write("/v1/jobs", req, &resp, q)
+	req := *job
+	req.EnforceIndex = true
+	req.JobModifyIndex = &modifyIndex
+	wm, err := j.client.write("/v1/jobs", &req, &resp, q)
 	if err != nil {
 		return "", nil, err
 	}
@@ -84,6 +86,57 @@ func (j *Jobs) List(q *QueryOptions) ([]*JobListStub, *QueryMeta, error) {
 	return resp, qm, nil
 }
 
+// ListByLabels is used to list the jobs having all the labels.
+func (j *Jobs) ListByLabels(labels map[string]string, q *QueryOptions) ([]*JobListStub, *QueryMeta, error) {
+	v := url.Values{}
+	for k, l := range labels {
+		v.Add("label", fmt.Sprintf("%s=%s", k, l))
+	}
+	var resp []*JobListStub
+	qm, err := j.client.query("/v1/jobs?"+v.Encode(), &resp, q)
+	if err != nil {
+		return nil, qm, err
+	}
+	sort.Sort(JobIDSort(resp))
+	return resp, qm, nil
+}
+
+// BulkRegister is used to register multiple jobs in one call.
+// An error of each job is returned in its BulkJobResult.
+func (j *Jobs) BulkRegister(jobs []*Job, q *WriteOptions) ([]*BulkJobResult, *WriteMeta, error) {
+	var resp []*BulkJobResult
+	req := &BulkJobRegisterRequest{Jobs: jobs}
+	wm, err := j.client.write("/v1/jobs/register", req, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, wm, nil
+}
+
+// BulkPause pauses the jobs selected by req.
+func (j *Jobs) BulkPause(req *BulkJobRequest, q *WriteOptions) ([]*BulkJobResult, *WriteMeta, error) {
+	return j.bulk("pause", req, q)
+}
+
+// BulkResume resumes the jobs selected by req.
+func (j *Jobs) BulkResume(req *BulkJobRequest, q *WriteOptions) ([]*BulkJobResult, *WriteMeta, error) {
+	return j.bulk("resume", req, q)
+}
+
+// BulkDeregister removes the jobs selected by req.
+func (j *Jobs) BulkDeregister(req *BulkJobRequest, q *WriteOptions) ([]*BulkJobResult, *WriteMeta, error) {
+	return j.bulk("delete", req, q)
+}
+
+func (j *Jobs) bulk(action string, req *BulkJobRequest, q *WriteOptions) ([]*BulkJobResult, *WriteMeta, error) {
+	var resp []*BulkJobResult
+	wm, err := j.client.write("/v1/jobs/"+action, req, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, wm, nil
+}
+
 // PrefixList is used to list all existing jobs that match the prefix.
 func (j *Jobs) PrefixList(prefix string) ([]*JobListStub, *QueryMeta, error) {
 	return j.List(&QueryOptions{Prefix: prefix})
@@ -100,6 +153,96 @@ func (j *Jobs) Info(jobID string, q *QueryOptions) (*Job, *QueryMeta, error) {
 	return &resp, qm, nil
 }
 
+// Inspect is used to retrieve the job spec with the effective task configs.
+func (j *Jobs) Inspect(jobID string, q *QueryOptions) (*JobInspection, *QueryMeta, error) {
+	var resp JobInspection
+	qm, err := j.client.query("/v1/job/"+jobID+"/inspect", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Bench is used to query the results of a bench job.
+func (j *Jobs) Bench(jobID string, q *QueryOptions) (*BenchResult, *QueryMeta, error) {
+	var resp BenchResult
+	qm, err := j.client.query("/v1/job/"+jobID+"/bench", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Verify is used to query the checks of a verify job.
+func (j *Jobs) Verify(jobID string, q *QueryOptions) (*VerifyResult, *QueryMeta, error) {
+	var resp VerifyResult
+	qm, err := j.client.query("/v1/job/"+jobID+"/verify", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Progress is used to query how far the incremental replication of a job is
+// behind the source.
+func (j *Jobs) Progress(jobID string, q *QueryOptions) (*JobProgress, *QueryMeta, error) {
+	var resp JobProgress
+	qm, err := j.client.query("/v1/job/"+jobID+"/progress", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Schema is used to list the databases and the tables of the source of a job.
+func (j *Jobs) Schema(jobID string, q *QueryOptions) (*SourceSchema, *QueryMeta, error) {
+	var resp SourceSchema
+	qm, err := j.client.query("/v1/job/"+jobID+"/schema", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Resync copies a table of the running job again, given as db.tb, while the job
+// replicates the other tables.
+func (j *Jobs) Resync(jobID, table string, q *WriteOptions) (*JobResync, *WriteMeta, error) {
+	var resp JobResync
+	wm, err := j.client.write("/v1/job/"+jobID+"/resync?table="+url.QueryEscape(table), nil, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, wm, nil
+}
+
+// Export is used to get the spec of a job to register on another cluster: without
+// the state of the cluster, and with the passwords replaced by template
+// variables.
+func (j *Jobs) Export(jobID string, q *QueryOptions) (*Job, *QueryMeta, error) {
+	var resp Job
+	qm, err := j.client.query("/v1/job/"+jobID+"/export", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Clone is used to register a copy of a job, with the overrides applied.
+func (j *Jobs) Clone(jobID string, overrides *JobOverrides, q *WriteOptions) (*WriteMeta, error) {
+	return j.client.write("/v1/job/"+jobID+"/clone", overrides, nil, q)
+}
+
+// Simulate is used to estimate the full copy of the job, from information_schema
+// of the source, without registering the job.
+func (j *Jobs) Simulate(job *Job, q *WriteOptions) (*JobSimulation, *WriteMeta, error) {
+	var resp JobSimulation
+	wm, err := j.client.write("/v1/jobs?simulate=true", job, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, wm, nil
+}
+
 // Allocations is used to return the allocs for a given job ID.
 func (j *Jobs) Allocations(jobID string, allAllocs bool, q *QueryOptions) ([]*AllocationListStub, *QueryMeta, error) {
 	var resp []*AllocationListStub
@@ -185,9 +328,14 @@ type Job struct {
 	ID                *string
 	Orders            []string
 	Name              *string
+	Namespace         *string
 	Failover          bool
 	Type              *string
+	Priority          *int
 	Datacenters       []string
+	Labels            map[string]string
+	DependsOn         []string
+	RestartPolicy     *RestartPolicy
 	Tasks             []*Task
 	Status            *string
 	StatusDescription *string
@@ -204,6 +352,9 @@ func (j *Job) Canonicalize() {
 	if j.Name == nil {
 		j.Name = internal.StringToPtr(*j.ID)
 	}
+	if j.Namespace == nil {
+		j.Namespace = internal.StringToPtr(models.DefaultNamespace)
+	}
 	if j.Region == nil {
 		j.Region = internal.StringToPtr("global")
 	}
@@ -213,6 +364,9 @@ func (j *Job) Canonicalize() {
 	if j.Type == nil {
 		j.Type = internal.StringToPtr(models.JobTypeSync)
 	}
+	if j.Priority == nil {
+		j.Priority = internal.IntToPtr(models.JobDefaultPriority)
+	}
 	if j.Status == nil {
 		j.Status = internal.StringToPtr("")
 	}
@@ -228,6 +382,37 @@ func (j *Job) Canonicalize() {
 	if j.JobModifyIndex == nil {
 		j.JobModifyIndex = internal.Uint64ToPtr(0)
 	}
+	if j.RestartPolicy == nil {
+		j.RestartPolicy = &RestartPolicy{}
+	}
+	j.RestartPolicy.Canonicalize()
+}
+
+// RestartPolicy is how the clients restart the failed tasks of a job. The
+// durations are in nanoseconds.
+type RestartPolicy struct {
+	Attempts *int
+	Interval *time.Duration
+	Delay    *time.Duration
+	// "delay" or "fail"
+	Mode *string
+}
+
+// Canonicalize sets the fields not set to the default restart policy.
+func (r *RestartPolicy) Canonicalize() {
+	defaultPolicy := models.DefaultRestartPolicy()
+	if r.Attempts == nil {
+		r.Attempts = internal.IntToPtr(defaultPolicy.Attempts)
+	}
+	if r.Interval == nil {
+		r.Interval = internal.TimeToPtr(defaultPolicy.Interval)
+	}
+	if r.Delay == nil {
+		r.Delay = internal.TimeToPtr(defaultPolicy.Delay)
+	}
+	if r.Mode == nil {
+		r.Mode = internal.StringToPtr(defaultPolicy.Mode)
+	}
 }
 
 // JobListStub is used to return a subset of information about
@@ -235,7 +420,10 @@ func (j *Job) Canonicalize() {
 type JobListStub struct {
 	ID                string
 	Name              string
+	Namespace         string
 	Type              string
+	Priority          int
+	Labels            map[string]string
 	Status            string
 	StatusDescription string
 	JobSummary        *Job
@@ -244,6 +432,171 @@ type JobListStub struct {
 	JobModifyIndex    uint64
 }
 
+// JobInspection is the job spec with the effective config of each task: the
+// defaults applied, the filters normalized and the passwords redacted.
+type JobInspection struct {
+	ID        string
+	Name      string
+	Namespace string
+	Type      string
+	Priority  int
+	Labels    map[string]string
+	DependsOn []string
+	Status    string
+	Tasks     []*TaskInspection
+}
+
+// TaskInspection is a task with its effective config.
+type TaskInspection struct {
+	Type     string
+	Driver   string
+	NodeName string
+	Config   map[string]interface{}
+}
+
+// JobSimulation estimates the full copy of a job: what it moves, how long it
+// takes at BandwidthLimitMBps and the memory it needs.
+type JobSimulation struct {
+	// The tables copied, the largest first.
+	Tables     []*TableEstimate
+	Rows       int64
+	DataBytes  int64
+	IndexBytes int64
+	// The duration at BandwidthLimitMBps, 0 without a limit.
+	BandwidthLimitMBps int
+	ProjectedSeconds   int64
+	// The bytes of a chunk of the largest rows, and the memory the chunks queued
+	// take at most, in each task.
+	ChunkBytes      int64
+	PeakMemoryBytes int64
+	Warnings        []string
+}
+
+// TableEstimate is the size of a table from information_schema.
+type TableEstimate struct {
+	TableSchema string
+	TableName   string
+	Rows        int64
+	AvgRowBytes int64
+	DataBytes   int64
+	IndexBytes  int64
+}
+
+// SourceSchema is the databases and the tables of the source of a job, from
+// information_schema, to choose what the job replicates.
+type SourceSchema struct {
+	JobID     string
+	Databases []*DatabaseSchema
+}
+
+// DatabaseSchema is a database of the source, with its tables sorted by name.
+type DatabaseSchema struct {
+	Name   string
+	Tables []*TableSchema
+}
+
+// TableSchema is a table of the source. Rows, DataBytes and IndexBytes are
+// estimates of InnoDB.
+type TableSchema struct {
+	Name string
+	// BASE TABLE or VIEW.
+	Type          string
+	Engine        string
+	Rows          int64
+	DataBytes     int64
+	IndexBytes    int64
+	HasPrimaryKey bool
+	HasUniqueKey  bool
+	// The job replicates the table with its current filters.
+	Replicated bool
+	// What of the table dtle doesn't replicate, or replicates poorly.
+	Unsupported []string
+}
+
+// BenchResult is the throughput measured by a bench job, so far if not
+// Complete.
+type BenchResult struct {
+	JobID  string
+	Status string
+	// All the transactions generated have been applied.
+	Complete bool
+	// The rows of the full copy applied to the target, and how fast.
+	DumpRows          int64
+	DumpSeconds       float64
+	DumpRowsPerSecond float64
+	// The transactions generated on the source after the full copy, and how
+	// fast.
+	Transactions                  int64
+	GeneratedTransactions         int64
+	GenerateSeconds               float64
+	GenerateTransactionsPerSecond float64
+	// The transactions applied to the target, and how fast.
+	AppliedTransactions        int64
+	ApplySeconds               float64
+	ApplyTransactionsPerSecond float64
+	// Why the stats of a task could not be read, if any.
+	StatsErrors []string
+}
+
+// VerifyResult is the last round of the checks of a verify job. The job
+// completes once they have all Passed.
+type VerifyResult struct {
+	JobID  string
+	Status string
+	Passed bool
+	// The rounds run so far, and when the last one ran, in unix nanoseconds.
+	Rounds        int64
+	LastRoundTime int64
+	// Why the last round could not compare the queries, if any.
+	Error  string
+	Checks []*VerifyCheck
+	// Why the stats of a task could not be read, if any.
+	StatsErrors []string
+}
+
+// VerifyCheck is the comparison of the rows of a query on the source and on
+// the target. The rows are JSON arrays of the columns, with NULL as null.
+type VerifyCheck struct {
+	Name   string
+	Passed bool
+	Source string
+	Target string
+	// Why the query failed on the source or on the target, if it did.
+	Error string
+}
+
+// JobProgress is how far the incremental replication of a job is behind the
+// source.
+type JobProgress struct {
+	JobID  string
+	Status string
+	// The gtid_executed of the source, refreshed every few seconds.
+	SourceGtidSet string
+	// The gtid set read from the binlog by the Src task, the transactions of
+	// SourceGtidSet not read yet, and the seconds since the last one read
+	// while behind.
+	ReadGtidSet            string
+	ReadTransactionsBehind int64
+	ReadSecondsBehind      int64
+	// The gtid set applied by the Dest task, and how many seconds the last
+	// transaction applied was behind the source.
+	AppliedGtidSet string
+	SecondsBehind  int64
+	// The transactions of SourceGtidSet not applied yet, nil unless the
+	// progress of both tasks is known.
+	TransactionsBehind *int64
+	// Why the progress of a task could not be read, if any.
+	StatsErrors []string
+}
+
+// JobResync is a table of a job being copied again.
+type JobResync struct {
+	JobID string
+	// The alloc of the Src task copying the table.
+	AllocID string
+	Table   string
+}
+
 // JobIDSort is used to sort jobs by their job ID's.
 type JobIDSort []*JobListStub
 
@@ -321,6 +674,24 @@ type JobUpdateResponse struct {
 	QueryMeta
 }
 
+// BulkJobRegisterRequest is used to register multiple jobs.
+type BulkJobRegisterRequest struct {
+	Jobs []*Job
+}
+
+// BulkJobRequest selects jobs for a bulk operation, by IDs and/or labels.
+// A job is selected if it is in JobIDs, or it has all the Labels.
+type BulkJobRequest struct {
+	JobIDs []string
+	Labels map[string]string
+}
+
+// BulkJobResult is the result of a bulk operation on a job.
+type BulkJobResult struct {
+	JobID string
+	Error string `json:",omitempty"`
+}
+
 // RegisterJobRequest is used to serialize a job registration
 type RegisterJobRequest struct {
 	Job            *Job
@@ -334,6 +705,99 @@ type RenewalJobRequest struct {
 	OrderID string
 }
 
+// JobOverrides are the changes to a job cloned or imported.
+type JobOverrides struct {
+	// Name replaces the ID and the name of the job.
+	Name string
+	// Namespace replaces the namespace of the job.
+	Namespace string
+	// Labels are merged into the labels of the job. An empty value removes the
+	// label.
+	Labels map[string]string
+	// Config sets values of the task configs by paths `<task type>.<key>...`,
+	// e.g. Dest.ConnectionConfig.Host. A value starting with `[` or `{` is
+	// decoded as JSON.
+	Config map[string]string
+}
+
+// Apply applies the overrides to the job.
+func (o *JobOverrides) Apply(job *Job) error {
+	if o == nil {
+		return nil
+	}
+	if o.Name != "" {
+		job.ID = internal.StringToPtr(o.Name)
+		job.Name = internal.StringToPtr(o.Name)
+	}
+	if o.Namespace != "" {
+		job.Namespace = internal.StringToPtr(o.Namespace)
+	}
+	for k, v := range o.Labels {
+		if v == "" {
+			delete(job.Labels, k)
+			continue
+		}
+		if job.Labels == nil {
+			job.Labels = make(map[string]string)
+		}
+		job.Labels[k] = v
+	}
+
+	paths := make([]string, 0, len(o.Config))
+	for path := range o.Config {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := setTaskConfig(job, path, o.Config[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTaskConfig sets the value of the config of the tasks of the type of path.
+func setTaskConfig(job *Job, path, value string) error {
+	keys := strings.Split(path, ".")
+	if len(keys) < 2 {
+		return fmt.Errorf("bad config path %q, should be <task type>.<key>", path)
+	}
+	var v interface{} = value
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return fmt.Errorf("bad JSON value of config %v: %v", path, err)
+		}
+	}
+
+	found := false
+	for _, task := range job.Tasks {
+		if task.Type != keys[0] {
+			continue
+		}
+		found = true
+		if task.Config == nil {
+			task.Config = make(map[string]interface{})
+		}
+		m := task.Config
+		for _, key := range keys[1 : len(keys)-1] {
+			child, ok := m[key].(map[string]interface{})
+			if !ok {
+				if m[key] != nil {
+					return fmt.Errorf("config %v of task %v is not an object", key, task.Type)
+				}
+				child = make(map[string]interface{})
+				m[key] = child
+			}
+			m = child
+		}
+		m[keys[len(keys)-1]] = v
+	}
+	if !found {
+		return fmt.Errorf("no task %v for config %v", keys[0], path)
+	}
+	return nil
+}
+
 // registerJobResponse is used to deserialize a job response
 type registerJobResponse struct {
 	EvalID string
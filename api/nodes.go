@@ -7,6 +7,7 @@
 package api
 
 import (
+	"fmt"
 	"sort"
 )
 
@@ -66,6 +67,32 @@ func (n *Nodes) ForceEvaluate(nodeID string, q *WriteOptions) (string, *WriteMet
 	return resp.EvalID, wm, nil
 }
 
+// Stats queries the agent of the node for the resource usage of its host and
+// allocations.
+func (n *Nodes) Stats(nodeID string, q *QueryOptions) (*ClientStats, error) {
+	client, err := n.NodeClient(nodeID, q)
+	if err != nil {
+		return nil, err
+	}
+	return client.Agent().ClientStats()
+}
+
+// NodeClient returns a client of the agent of the node, by its advertised
+// http address.
+func (n *Nodes) NodeClient(nodeID string, q *QueryOptions) (*Client, error) {
+	node, _, err := n.Info(nodeID, q)
+	if err != nil {
+		return nil, err
+	}
+	if node.Status == "down" {
+		return nil, NodeDownErr
+	}
+	if node.HTTPAddr == "" {
+		return nil, fmt.Errorf("http addr of node %q is not advertised", nodeID)
+	}
+	return NewClient(n.client.config.CopyConfig(node.HTTPAddr))
+}
+
 // Node is used to deserialize a node entry.
 type Node struct {
 	ID                string
@@ -6,6 +6,10 @@
 
 package api
 
+import (
+	"io"
+)
+
 // Operator can be used to perform low-level operator tasks for Nomad.
 type Operator struct {
 	c *Client
@@ -111,3 +115,129 @@ func (op *Operator) RaftRemovePeerByID(id string, q *WriteOptions) error {
 	resp.Body.Close()
 	return nil
 }
+
+// SnapshotSave returns an archive of the server state, to be restored by
+// SnapshotRestore. The caller must close the reader.
+func (op *Operator) SnapshotSave(q *QueryOptions) (io.ReadCloser, error) {
+	r, err := op.c.newRequest("GET", "/v1/operator/snapshot")
+	if err != nil {
+		return nil, err
+	}
+	r.setQueryOptions(q)
+
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SnapshotRestore replaces the server state with an archive saved by
+// SnapshotSave.
+func (op *Operator) SnapshotRestore(in io.Reader, q *WriteOptions) error {
+	r, err := op.c.newRequest("PUT", "/v1/operator/snapshot")
+	if err != nil {
+		return err
+	}
+	r.setWriteOptions(q)
+	r.body = in
+
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// StoredSnapshot is an archive of the server state in the storage of the
+// agent.
+type StoredSnapshot struct {
+	Key string
+	// URL tells where the archive is.
+	URL   string
+	Bytes int64
+}
+
+// SnapshotSaveToStorage saves an archive of the server state in the storage
+// of the agent, to be restored by SnapshotRestoreFromStorage.
+func (op *Operator) SnapshotSaveToStorage(q *QueryOptions) (*StoredSnapshot, error) {
+	r, err := op.c.newRequest("PUT", "/v1/operator/snapshot/storage")
+	if err != nil {
+		return nil, err
+	}
+	r.setQueryOptions(q)
+
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out StoredSnapshot
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SnapshotStorageList returns the keys of the archives in the storage of the
+// agent, sorted by the time they were saved.
+func (op *Operator) SnapshotStorageList(q *QueryOptions) ([]string, *QueryMeta, error) {
+	var resp []string
+	qm, err := op.c.query("/v1/operator/snapshot/storage", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// SnapshotRestoreFromStorage replaces the server state with the archive of
+// the key in the storage of the agent.
+func (op *Operator) SnapshotRestoreFromStorage(key string, q *WriteOptions) error {
+	r, err := op.c.newRequest("PUT", "/v1/operator/snapshot")
+	if err != nil {
+		return err
+	}
+	r.setWriteOptions(q)
+	r.params.Set("key", key)
+
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// CARoot is a root of the CA built in the managers, which issues the
+// certificates of the mutual TLS of the RPC.
+type CARoot struct {
+	ID string
+	// Fingerprint is the SHA-256 of the certificate, for the tls ca_fingerprint
+	// of the agents.
+	Fingerprint string
+	Certificate string
+	// Active is whether the root signs the new certificates.
+	Active bool
+	// CreateTime and RotateTime are in UnixNano.
+	CreateTime int64
+	RotateTime int64
+}
+
+// CARoots returns the roots of the CA.
+func (op *Operator) CARoots(q *QueryOptions) ([]*CARoot, *QueryMeta, error) {
+	var resp []*CARoot
+	qm, err := op.c.query("/v1/operator/ca/roots", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// CARotate replaces the active root of the CA by a new one.
+func (op *Operator) CARotate(q *WriteOptions) (*WriteMeta, error) {
+	return op.c.write("/v1/operator/ca/rotate", nil, nil, q)
+}
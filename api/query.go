@@ -47,6 +47,9 @@ type QueryOptions struct {
 	// which overrides the agent's default token.
 	Token string
 
+	// If set, only the jobs of the namespace are listed.
+	Namespace string
+
 	// Set HTTP parameters on the query.
 	Params map[string]string
 }
@@ -112,6 +115,14 @@ type Config struct {
 	// WaitTime limits how long a Watch will block. If not provided,
 	// the agent default values will be used.
 	WaitTime time.Duration
+
+	// Token is the ACL token sent with every request, if the agent has ACLs
+	// enabled.
+	Token string
+
+	// Language is the Accept-Language of every request, e.g. zh, in which the
+	// agent returns the errors. If not provided, the agent locale is used.
+	Language string
 }
 
 // CopyConfig copies the configuration with a new address
@@ -123,6 +134,8 @@ func (c *Config) CopyConfig(address string) *Config {
 		HttpClient: c.HttpClient,
 		HttpAuth:   c.HttpAuth,
 		WaitTime:   c.WaitTime,
+		Token:      c.Token,
+		Language:   c.Language,
 	}
 
 	return config
@@ -138,6 +151,12 @@ func DefaultConfig() *Config {
 	if addr := os.Getenv("UDUP_ADDR"); addr != "" {
 		config.Address = addr
 	}
+	if token := os.Getenv("UDUP_TOKEN"); token != "" {
+		config.Token = token
+	}
+	if lang := os.Getenv("UDUP_LANG"); lang != "" {
+		config.Language = lang
+	}
 	if auth := os.Getenv("UDUP_HTTP_AUTH"); auth != "" {
 		var username, password string
 		if strings.Contains(auth, ":") {
@@ -222,6 +241,9 @@ func (r *request) setQueryOptions(q *QueryOptions) {
 	if q.Token != "" {
 		r.params.Set("X-Udup-Token", q.Token)
 	}
+	if q.Namespace != "" {
+		r.params.Set("namespace", q.Namespace)
+	}
 	for k, v := range q.Params {
 		r.params.Set(k, v)
 	}
@@ -272,6 +294,12 @@ func (r *request) toHTTP() (*http.Request, error) {
 		req.SetBasicAuth(r.config.HttpAuth.Username, r.config.HttpAuth.Password)
 	}
 
+	if r.config.Token != "" {
+		req.Header.Set("X-Udup-Token", r.config.Token)
+	}
+	if r.config.Language != "" {
+		req.Header.Set("Accept-Language", r.config.Language)
+	}
 	req.Header.Add("Accept-Encoding", "gzip")
 	req.URL.Host = r.url.Host
 	req.URL.Scheme = r.url.Scheme
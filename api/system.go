@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// System is used to call the system-wide operations.
+type System struct {
+	c *Client
+}
+
+// System returns a handle to the system endpoints.
+func (c *Client) System() *System {
+	return &System{c}
+}
+
+// GarbageCollectResponse has the numbers of collected objects.
+type GarbageCollectResponse struct {
+	Evals       int
+	Allocations int
+	Jobs        int
+	Nodes       int
+}
+
+// GarbageCollect collects the terminal evaluations, allocations, jobs and down
+// nodes immediately, regardless of the GC thresholds.
+func (s *System) GarbageCollect(q *WriteOptions) (*GarbageCollectResponse, *WriteMeta, error) {
+	var resp GarbageCollectResponse
+	wm, err := s.c.write("/v1/system/gc", nil, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, wm, nil
+}
@@ -19,6 +19,17 @@ type Task struct {
 	Config   map[string]interface{}
 	Leader   bool
 	Status   string
+	// Resources limits the processes of the task, nil for no limit.
+	Resources *Resources
+}
+
+// Resources are the limits of the processes of a task, enforced with cgroups
+// on the clients configured with a cgroup_parent.
+type Resources struct {
+	// CPU is the number of cores, e.g. 0.5 for half a core.
+	CPU float64
+	// MemoryMB is the memory in MB.
+	MemoryMB int
 }
 
 // Configure is used to configure a single k/v pair on
@@ -58,6 +69,11 @@ const (
 	TaskSignaling        = "Signaling"
 	TaskRestartSignal    = "Restart Signaled"
 	TaskLeaderDead       = "Leader Task Dead"
+	TaskCopyProgress     = "Copy Progress"
+	TaskFullCopyComplete = "Full Copy Complete"
+	TaskWriteConflict    = "Write Conflict"
+
+	TaskTargetReadOnlyDisabled = "Target Read Only Disabled"
 )
 
 type TableStats struct {
@@ -76,6 +92,16 @@ type ThroughputStat struct {
 	Time uint64
 }
 
+type TableCopyProgress struct {
+	Table        string
+	ChunkIndex   int64
+	Rows         int64
+	RowsEstimate int64
+	Bytes        int64
+	Elapsed      time.Duration
+	Done         bool
+}
+
 type Stats struct {
 	TableStats     *TableStats
 	DelayCount     *DelayCount
@@ -115,4 +141,5 @@ type TaskEvent struct {
 	FailedSibling    string
 	TaskSignalReason string
 	TaskSignal       string
+	CopyProgress     *TableCopyProgress
 }
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// Topology is used to query where the tables are replicated.
+type Topology struct {
+	client *Client
+}
+
+// Topology returns a handle on the replication topology.
+func (c *Client) Topology() *Topology {
+	return &Topology{client: c}
+}
+
+// Table is used to list the jobs replicating the table, given as
+// "schema.table", with their targets and lag.
+func (t *Topology) Table(table string, q *QueryOptions) (*TableTopology, *QueryMeta, error) {
+	if q == nil {
+		q = &QueryOptions{}
+	}
+	if q.Params == nil {
+		q.Params = make(map[string]string)
+	}
+	q.Params["table"] = table
+
+	var resp TableTopology
+	qm, err := t.client.query("/v1/topology", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// TableTopology is the jobs replicating a table.
+type TableTopology struct {
+	TableSchema string
+	TableName   string
+	Jobs        []*TableReplication
+}
+
+// TableReplication is a job replicating a table, with the lag of its target.
+type TableReplication struct {
+	JobID     string
+	JobName   string
+	Namespace string
+	Status    string
+	// The MySQL servers, as host:port. Empty for the other drivers.
+	Source       string
+	TargetDriver string
+	Target       string
+	// The Dest alloc running, empty if none.
+	AllocID string
+	NodeID  string
+	// How many seconds the target was behind the source when the last
+	// transaction was applied, at LastApplyTime in unix nanoseconds.
+	LagSeconds    uint64
+	LastApplyTime int64
+	// Why the lag is unknown, e.g. the node of the alloc is down.
+	StatsError string
+}
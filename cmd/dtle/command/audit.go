@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type AuditCommand struct {
+	Meta
+}
+
+func (c *AuditCommand) Help() string {
+	helpText := `
+Usage: dtle audit [options]
+
+  Display the audit log of the changes requested through the HTTP API: who
+  submitted, paused, resumed or stopped which job, when, and the result.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Audit Options:
+
+  -target=<id>
+    Display only the changes of the job, order or node.
+
+  -namespace=<namespace>
+    Display only the changes of the jobs of the namespace.
+
+  -since=<time>
+    Display only the changes since the time, in RFC3339.
+
+  -until=<time>
+    Display only the changes before the time, in RFC3339.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AuditCommand) Synopsis() string {
+	return "Display the audit log of the API changes"
+}
+
+func (c *AuditCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-target":    complete.PredictAnything,
+			"-namespace": complete.PredictAnything,
+			"-since":     complete.PredictAnything,
+			"-until":     complete.PredictAnything,
+		})
+}
+
+func (c *AuditCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *AuditCommand) Run(args []string) int {
+	var target, namespace, since, until string
+
+	flags := c.Meta.FlagSet("audit", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&target, "target", "", "")
+	flags.StringVar(&namespace, "namespace", "", "")
+	flags.StringVar(&since, "since", "", "")
+	flags.StringVar(&until, "until", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	params := map[string]string{}
+	for k, v := range map[string]string{"target": target, "since": since, "until": until} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+	events, _, err := client.Audit().List(&api.QueryOptions{Namespace: namespace, Params: params})
+	if err != nil {
+		c.errorf("Error querying audit log: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(events)
+	}
+
+	out := make([]string, len(events)+1)
+	out[0] = "Time|Identity|Accessor|Remote Address|Request|Targets|Code"
+	for i, event := range events {
+		out[i+1] = fmt.Sprintf("%s|%s|%s|%s|%s %s|%s|%d",
+			formatUnixNanoTime(event.Time),
+			event.Identity,
+			event.Accessor,
+			event.RemoteAddr,
+			event.Method,
+			event.Path,
+			strings.Join(event.Targets, ","),
+			event.Code)
+	}
+	c.Ui.Output(formatList(out))
+	return 0
+}
@@ -76,7 +76,7 @@ func (c *ConfigCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
@@ -89,10 +89,10 @@ func (c *ConfigCommand) Run(args []string) int {
 
 		// Set the servers list
 		if err := client.Agent().SetServers(args); err != nil {
-			c.Ui.Error(fmt.Sprintf("Error updating server list: %s", err))
+			c.errorf("Error updating server list: %s", err)
 			return 1
 		}
-		c.Ui.Output(fmt.Sprint("Updated server list"))
+		c.outputMessage(fmt.Sprint("Updated server list"))
 		return 0
 	}
 
@@ -100,10 +100,14 @@ func (c *ConfigCommand) Run(args []string) int {
 		// Query the current server list
 		servers, err := client.Agent().Servers()
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error querying server list: %s", err))
+			c.errorf("Error querying server list: %s", err)
 			return 1
 		}
 
+		if c.structuredOutput() {
+			return c.outputData(servers)
+		}
+
 		// Print the results
 		for _, server := range servers {
 			c.Ui.Output(server)
@@ -0,0 +1,206 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/posener/complete"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type DebugCommand struct {
+	Meta
+}
+
+func (c *DebugCommand) Help() string {
+	helpText := `
+Usage: dtle debug [options] [<dir>]
+
+Collect the crash reports of the agents of the cluster into the dir, which
+defaults to the current one. An agent writes a crash report to the crash
+directory of its data_dir when it panics: the stack traces, the last lines of
+its log, the states of its jobs and its config, with the secrets redacted.
+
+The reports of each node are saved to <dir>/<node name>/. The reports already
+collected are skipped.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Debug Options:
+
+  -node=<node id>
+    Collect the crash reports of the node only, by a prefix of its ID.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugCommand) Synopsis() string {
+	return "Collect the crash reports of the agents"
+}
+
+func (c *DebugCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-node": c.predictNodes(),
+		})
+}
+
+func (c *DebugCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictDirs("*")
+}
+
+func (c *DebugCommand) Run(args []string) int {
+	var nodeID string
+
+	flags := c.Meta.FlagSet("debug", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&nodeID, "node", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) > 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	var nodes []*api.NodeListStub
+	if nodeID != "" {
+		nodes, _, err = client.Nodes().PrefixList(nodeID)
+	} else {
+		nodes, _, err = client.Nodes().List(nil)
+	}
+	if err != nil {
+		c.errorf("Error querying nodes: %s", err)
+		return 1
+	}
+	if len(nodes) == 0 {
+		c.errorf("No nodes found")
+		return 1
+	}
+
+	failed := false
+	saved := []*savedCrashReport{}
+	for _, node := range nodes {
+		nodeSaved, err := c.collectNode(client, node, dir)
+		saved = append(saved, nodeSaved...)
+		if err != nil {
+			c.errorf("Error collecting the crash reports of node %s (%s): %s",
+				node.Name, limit(node.ID, 8), err)
+			failed = true
+		}
+	}
+
+	if c.structuredOutput() {
+		if code := c.outputData(saved); code != 0 {
+			return code
+		}
+	} else {
+		c.Ui.Output(fmt.Sprintf("Collected %d crash report(s) of %d node(s) to %q", len(saved), len(nodes), dir))
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// savedCrashReport is a crash report collected, in the json and yaml outputs.
+type savedCrashReport struct {
+	NodeID string
+	Node   string
+	Name   string
+	File   string
+}
+
+// collectNode saves the crash reports of the node not yet in dir, and returns
+// those it saved.
+func (c *DebugCommand) collectNode(client *api.Client, node *api.NodeListStub, dir string) ([]*savedCrashReport, error) {
+	nodeClient, err := client.Nodes().NodeClient(node.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := nodeClient.Agent().CrashReports()
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+
+	nodeDir := filepath.Join(dir, debugNodeDirName(node))
+	if err := os.MkdirAll(nodeDir, 0700); err != nil {
+		return nil, err
+	}
+	var saved []*savedCrashReport
+	for _, report := range reports {
+		file := filepath.Join(nodeDir, report.Name)
+		if _, err := os.Stat(file); err == nil {
+			continue
+		}
+		if err := saveCrashReport(nodeClient, report.Name, file); err != nil {
+			return saved, err
+		}
+		c.outputMessage(fmt.Sprintf("Saved crash report %s of node %s", report.Name, node.Name))
+		saved = append(saved, &savedCrashReport{NodeID: node.ID, Node: node.Name, Name: report.Name, File: file})
+	}
+	return saved, nil
+}
+
+// debugNodeDirName returns the name of the dir of the reports of the node, its
+// name, or its ID if the name could not be a file name.
+func debugNodeDirName(node *api.NodeListStub) string {
+	name := node.Name
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		name = node.ID
+	}
+	return name
+}
+
+// saveCrashReport saves the crash report to the file, by a temp file so that a
+// failure doesn't leave a partial report behind.
+func saveCrashReport(client *api.Client, name, file string) error {
+	report, err := client.Agent().CrashReport(name)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, report); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
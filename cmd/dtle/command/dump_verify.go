@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+)
+
+type DumpVerifyCommand struct {
+	Meta
+}
+
+func (c *DumpVerifyCommand) Help() string {
+	helpText := `
+Usage: dtle dump-verify [options] <dir>
+
+  Verify the full copy exported to the directory by a job (the DumpExportDir
+  of its Dest task) against its manifest.json, before archiving it or loading
+  it with myloader: each file must have the size and the SHA-256 of the
+  manifest, and the directory must have no other file.
+
+Verify Options:
+
+  -sha256=<digest>
+    The SHA-256 of manifest.json, logged by the Dest task when the export
+    finished, to tell whether the manifest itself has been changed.
+
+  -output=<format>
+    The format of the output: table, json or yaml, for scripts. Defaults to
+    table.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DumpVerifyCommand) Synopsis() string {
+	return "Verify a full copy exported against its manifest"
+}
+
+func (c *DumpVerifyCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetNone),
+		complete.Flags{
+			"-sha256": complete.PredictAnything,
+		})
+}
+
+func (c *DumpVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictDirs("*")
+}
+
+func (c *DumpVerifyCommand) Run(args []string) int {
+	var digest string
+
+	flags := c.Meta.FlagSet("dump-verify", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&digest, "sha256", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	manifest, err := mysql.VerifyDumpManifest(args[0], strings.ToLower(digest))
+	if err != nil {
+		c.errorf("Error verifying dump: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(manifest)
+	}
+	var rows int64
+	for _, f := range manifest.Files {
+		rows += f.Rows
+	}
+	c.Ui.Output(fmt.Sprintf("Verified %d files, %d rows, of the full copy at GTID %s",
+		len(manifest.Files), rows, manifest.Gtid))
+	return 0
+}
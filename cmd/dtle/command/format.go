@@ -0,0 +1,200 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The formats of the output, by the -output flag.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// outputFormat is the value of the -output flag.
+type outputFormat string
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(v string) error {
+	switch v {
+	case OutputTable, OutputJSON, OutputYAML:
+		*f = outputFormat(v)
+		return nil
+	}
+	return fmt.Errorf("should be %v, %v or %v", OutputTable, OutputJSON, OutputYAML)
+}
+
+// formatData formats the data in json or yaml.
+func formatData(format string, data interface{}) (string, error) {
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case OutputJSON:
+		return string(out), nil
+	case OutputYAML:
+		return jsonToYAML(out)
+	}
+	return "", fmt.Errorf("unknown output format %q", format)
+}
+
+// yamlMapItem is a member of a JSON object, whose order is kept in the yaml.
+type yamlMapItem struct {
+	key   string
+	value interface{}
+}
+
+// yamlMap is a JSON object.
+type yamlMap []yamlMapItem
+
+// jsonToYAML converts the JSON to yaml, in block style, keeping the order of
+// the members of the objects.
+func jsonToYAML(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	writeYAML(&buf, v, 0)
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeJSONValue decodes a JSON value into a yamlMap, a []interface{} or a
+// scalar.
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlMapItem{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		l := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, value)
+		}
+		_, err := dec.Token()
+		return l, err
+	}
+	return tok, nil
+}
+
+// writeYAML writes the value as a block indented by indent spaces, or as a
+// scalar followed by a newline.
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) {
+	prefix := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			buf.WriteString("{}\n")
+			return
+		}
+		for _, item := range v {
+			buf.WriteString(prefix + yamlScalar(item.key) + ":")
+			if isYAMLBlock(item.value) {
+				buf.WriteString("\n")
+				// The items of a list are at the indentation of the key.
+				if _, ok := item.value.([]interface{}); ok {
+					writeYAML(buf, item.value, indent)
+				} else {
+					writeYAML(buf, item.value, indent+2)
+				}
+			} else {
+				buf.WriteString(" ")
+				writeYAML(buf, item.value, 0)
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]\n")
+			return
+		}
+		for _, item := range v {
+			if !isYAMLBlock(item) {
+				buf.WriteString(prefix + "- ")
+				writeYAML(buf, item, 0)
+				continue
+			}
+			// The first line of the block follows the "- ".
+			var block bytes.Buffer
+			writeYAML(&block, item, indent+2)
+			buf.WriteString(prefix + "- ")
+			buf.Write(block.Bytes()[indent+2:])
+		}
+	default:
+		buf.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+// isYAMLBlock returns whether the value is a non-empty object or array.
+func isYAMLBlock(v interface{}) bool {
+	switch v := v.(type) {
+	case yamlMap:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+var (
+	// yamlSpecialRegexp matches the strings which are read as another type,
+	// or not as a plain scalar.
+	yamlSpecialRegexp = regexp.MustCompile(`(?i)^(~|null|true|false|yes|no|on|off|y|n|[-+]?\.?[0-9].*|[-?:,\[\]{}#&*!|>'"%@` + "`" + `].*)$`)
+)
+
+// yamlScalar returns the scalar in yaml, a string quoted if it would be read
+// otherwise.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if v == "" || yamlSpecialRegexp.MatchString(v) || strings.TrimSpace(v) != v ||
+			strings.Contains(v, ": ") || strings.Contains(v, " #") || strings.HasSuffix(v, ":") ||
+			strings.IndexFunc(v, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
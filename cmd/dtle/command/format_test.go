@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func Test_formatData(t *testing.T) {
+	type task struct {
+		Type   string
+		Config map[string]interface{}
+	}
+	data := struct {
+		ID      string
+		Count   int
+		Enabled bool
+		Tags    []string
+		Tasks   []task
+		Meta    map[string]string
+		Empty   []string
+		Nil     *task
+	}{
+		ID:      "job-1",
+		Count:   2,
+		Enabled: true,
+		Tags:    []string{"a b", "true", "10", "", "x: y"},
+		Tasks: []task{
+			{Type: "Src", Config: map[string]interface{}{"Gtid": "uuid:1-10", "Tables": []string{"db1.t1"}}},
+		},
+		Meta:  map[string]string{},
+		Empty: []string{},
+	}
+
+	want := `ID: job-1
+Count: 2
+Enabled: true
+Tags:
+- a b
+- "true"
+- "10"
+- ""
+- "x: y"
+Tasks:
+- Type: Src
+  Config:
+    Gtid: uuid:1-10
+    Tables:
+    - db1.t1
+Meta: {}
+Empty: []
+Nil: null`
+	got, err := formatData(OutputYAML, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("yaml:\n%v\nwant:\n%v", got, want)
+	}
+
+	got, err = formatData(OutputJSON, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "{\n    \"ID\": \"job-1\",") {
+		t.Errorf("json:\n%v", got)
+	}
+}
+
+func TestMeta_outputFlag(t *testing.T) {
+	ui := new(cli.MockUi)
+	m := &Meta{Ui: ui}
+	flags := m.FlagSet("test", FlagSetClient)
+	if err := flags.Parse([]string{"-output=yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if !m.structuredOutput() {
+		t.Errorf("expected a structured output")
+	}
+	m.outputMessage("message")
+	if code := m.outputData([]string{"a"}); code != 0 {
+		t.Errorf("code = %v", code)
+	}
+	if got := ui.OutputWriter.String(); got != "- a\n" {
+		t.Errorf("output = %q", got)
+	}
+
+	flags = m.FlagSet("test", FlagSetClient)
+	if err := flags.Parse([]string{"-output=xml"}); err == nil {
+		t.Errorf("expected an error of the format")
+	}
+}
@@ -49,18 +49,18 @@ func (c *InitCommand) Run(args []string) int {
 	// Check if the file already exists
 	_, err := os.Stat(DefaultInitName)
 	if err != nil && !os.IsNotExist(err) {
-		c.Ui.Error(fmt.Sprintf("Failed to stat '%s': %v", DefaultInitName, err))
+		c.errorf("Failed to stat '%s': %v", DefaultInitName, err)
 		return 1
 	}
 	if !os.IsNotExist(err) {
-		c.Ui.Error(fmt.Sprintf("Job '%s' already exists", DefaultInitName))
+		c.errorf("Job '%s' already exists", DefaultInitName)
 		return 1
 	}
 
 	// Write out the example
 	err = ioutil.WriteFile(DefaultInitName, []byte(defaultJob), 0660)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to write '%s': %v", DefaultInitName, err))
+		c.errorf("Failed to write '%s': %v", DefaultInitName, err)
 		return 1
 	}
 
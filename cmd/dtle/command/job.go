@@ -8,11 +8,13 @@ package command
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	gg "github.com/hashicorp/go-getter"
 	"github.com/hashicorp/go-multierror"
@@ -25,6 +27,9 @@ import (
 )
 
 type JobGetter struct {
+	// Where to find values of `${name}` in the jobfile. See InterpolateVars.
+	varLookups []VarLookup
+
 	// The fields below can be overwritten for tests
 	testStdin io.Reader
 }
@@ -78,8 +83,18 @@ func (j *JobGetter) ApiJob(jpath string) (*api.Job, error) {
 		}
 	}
 
+	// Resolve the template variables
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, jobfile); err != nil {
+		return nil, err
+	}
+	src, err := InterpolateVars(buf.String(), j.varLookups...)
+	if err != nil {
+		return nil, fmt.Errorf("Error interpolating job file from %s: %v", jpath, err)
+	}
+
 	// Parse the JobFile
-	jobStruct, err := Parse(jobfile)
+	jobStruct, err := Parse(strings.NewReader(src))
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing job file from %s: %v", jpath, err)
 	}
@@ -189,7 +204,9 @@ func parseJob(result *api.Job, list *ast.ObjectList) error {
 	valid := []string{
 		"region",
 		"datacenters",
+		"labels",
 		"name",
+		"priority",
 		"task",
 		"type",
 	}
@@ -298,8 +315,12 @@ func checkHCLKeys(node ast.Node, valid []string) error {
 	for _, item := range list.Items {
 		key := item.Keys[0].Token.Value().(string)
 		if _, ok := validMap[key]; !ok {
-			result = multierror.Append(result, fmt.Errorf(
-				"invalid key: %s", key))
+			msg := fmt.Sprintf("invalid key: %s (line %d, column %d)",
+				key, item.Keys[0].Pos().Line, item.Keys[0].Pos().Column)
+			if closest := internal.ClosestString(key, valid); closest != "" {
+				msg += fmt.Sprintf(", did you mean %q?", closest)
+			}
+			result = multierror.Append(result, errors.New(msg))
 		}
 	}
 
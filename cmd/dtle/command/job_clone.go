@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type JobCloneCommand struct {
+	Meta
+}
+
+func (c *JobCloneCommand) Help() string {
+	helpText := `
+Usage: dtle job-clone -name <name> [options] <job>
+
+  Register a copy of a job on the same cluster, with the overrides of the
+  options applied. The passwords are copied. The status, the nodes of the
+  tasks and the task configs set while the job runs are not, so the copy
+  starts with the full copy unless -set Src.Gtid is given.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Clone Options:
+` + jobOverrideOptionsUsage + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobCloneCommand) Synopsis() string {
+	return "Register a copy of a job"
+}
+
+func (c *JobCloneCommand) AutocompleteFlags() complete.Flags {
+	var overrideFlags jobOverrideFlags
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		overrideFlags.autocompleteFlags())
+}
+
+func (c *JobCloneCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictJobs()
+}
+
+func (c *JobCloneCommand) Run(args []string) int {
+	var overrideFlags jobOverrideFlags
+
+	flags := c.Meta.FlagSet("job-clone", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	overrideFlags.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 || overrideFlags.name == "" {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	overrides, err := overrideFlags.overrides()
+	if err != nil {
+		c.errorf("Failed to parse args: %v", err)
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+	if _, err := client.Jobs().Clone(args[0], overrides, nil); err != nil {
+		c.errorf("Error cloning job: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(map[string]string{"JobID": overrides.Name})
+	}
+	c.Ui.Output(fmt.Sprintf("Job %q registered", overrides.Name))
+	return 0
+}
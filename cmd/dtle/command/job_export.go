@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type JobExportCommand struct {
+	Meta
+}
+
+func (c *JobExportCommand) Help() string {
+	helpText := `
+Usage: dtle job-export [options] <job>
+
+  Export the spec of a job in JSON, to register it on another cluster with
+  job-import. The status, the region, the orders, the nodes of the tasks and
+  the task configs set while the job runs are left out, so the job starts
+  again with the full copy. The passwords are replaced by template variables
+  named after their task and their path, e.g. ${Src_ConnectionConfig_Password}.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Export Options:
+
+  -o <path>
+    Write the job to the file instead of the standard output.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobExportCommand) Synopsis() string {
+	return "Export the spec of a job to register on another cluster"
+}
+
+func (c *JobExportCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-o": complete.PredictFiles("*.json"),
+		})
+}
+
+func (c *JobExportCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictJobs()
+}
+
+func (c *JobExportCommand) Run(args []string) int {
+	var path string
+
+	flags := c.Meta.FlagSet("job-export", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&path, "o", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	job, _, err := client.Jobs().Export(args[0], nil)
+	if err != nil {
+		c.errorf("Error exporting job: %s", err)
+		return 1
+	}
+	out, err := json.MarshalIndent(job, "", "    ")
+	if err != nil {
+		c.errorf("Error formatting job: %s", err)
+		return 1
+	}
+	if path == "" {
+		c.Ui.Output(string(out))
+		return 0
+	}
+	if err := ioutil.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		c.errorf("Failed to write '%s': %v", path, err)
+		return 1
+	}
+	return 0
+}
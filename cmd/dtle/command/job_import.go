@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/posener/complete"
+
+	"github.com/actiontech/dtle/agent"
+	"github.com/actiontech/dtle/api"
+)
+
+const jobOverrideOptionsUsage = `
+  -name <name>
+    Replace the ID and the name of the job.
+
+  -namespace <namespace>
+    Replace the namespace of the job.
+
+  -label key=value
+    Set a label of the job, or remove it with an empty value. Could be
+    specified multiple times.
+
+  -set <task type>.<key>=value
+    Set a value of the config of the tasks of the type, e.g.
+    -set Dest.ConnectionConfig.Host=10.0.0.1. A value starting with [ or { is
+    decoded as JSON. Could be specified multiple times.`
+
+// jobOverrideFlags are the flags of the changes to a job cloned or imported.
+type jobOverrideFlags struct {
+	name      string
+	namespace string
+	labels    agent.StringFlag
+	configs   agent.StringFlag
+}
+
+func (f *jobOverrideFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.name, "name", "", "")
+	flags.StringVar(&f.namespace, "namespace", "", "")
+	flags.Var(&f.labels, "label", "")
+	flags.Var(&f.configs, "set", "")
+}
+
+func (f *jobOverrideFlags) autocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-name":      complete.PredictAnything,
+		"-namespace": complete.PredictAnything,
+		"-label":     complete.PredictAnything,
+		"-set":       complete.PredictAnything,
+	}
+}
+
+func (f *jobOverrideFlags) overrides() (*api.JobOverrides, error) {
+	overrides := &api.JobOverrides{
+		Name:      f.name,
+		Namespace: f.namespace,
+		Labels:    make(map[string]string, len(f.labels)),
+		Config:    make(map[string]string, len(f.configs)),
+	}
+	for _, label := range f.labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("bad label %q, should be key=value", label)
+		}
+		overrides.Labels[kv[0]] = kv[1]
+	}
+	for _, config := range f.configs {
+		kv := strings.SplitN(config, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("bad config %q, should be <task type>.<key>=value", config)
+		}
+		overrides.Config[kv[0]] = kv[1]
+	}
+	return overrides, nil
+}
+
+type JobImportCommand struct {
+	Meta
+
+	// testStdin replaces the standard input in tests.
+	testStdin io.Reader
+}
+
+func (c *JobImportCommand) Help() string {
+	helpText := `
+Usage: dtle job-import [options] <path>
+
+  Register the job exported by job-export to the file at <path>, or read from
+  the standard input if <path> is "-", with the overrides of the options
+  applied.
+
+  The template variables of the file, such as the passwords exported, are
+  looked up from, in order, the -var flags, the DTLE_VAR_<name> environment
+  variable, and consul KV <prefix>/<name> if -var-consul-prefix is given.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Import Options:
+` + jobOverrideOptionsUsage + `
+
+  -var name=value
+    Set a template variable. Could be specified multiple times.
+
+  -var-consul-prefix=<prefix>
+    Look up template variables from consul KV under the prefix. The consul
+    agent is set by the CONSUL_HTTP_ADDR environment variable.
+
+  -print-job
+    Output the JSON of the job that would be registered without registering it.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobImportCommand) Synopsis() string {
+	return "Register a job exported by job-export"
+}
+
+func (c *JobImportCommand) AutocompleteFlags() complete.Flags {
+	var overrideFlags jobOverrideFlags
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		overrideFlags.autocompleteFlags(),
+		complete.Flags{
+			"-var":               complete.PredictAnything,
+			"-var-consul-prefix": complete.PredictAnything,
+			"-print-job":         complete.PredictNothing,
+		})
+}
+
+func (c *JobImportCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*.json")
+}
+
+func (c *JobImportCommand) Run(args []string) int {
+	var printJob bool
+	var varConsulPrefix string
+	var vars agent.StringFlag
+	var overrideFlags jobOverrideFlags
+
+	flags := c.Meta.FlagSet("job-import", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	overrideFlags.register(flags)
+	flags.Var(&vars, "var", "")
+	flags.StringVar(&varConsulPrefix, "var-consul-prefix", "", "")
+	flags.BoolVar(&printJob, "print-job", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	overrides, err := overrideFlags.overrides()
+	if err != nil {
+		c.errorf("Failed to parse args: %v", err)
+		return 1
+	}
+	flagLookup, err := FlagVarLookup(vars)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	varLookups := []VarLookup{flagLookup, EnvVarLookup}
+	if varConsulPrefix != "" {
+		consulLookup, err := ConsulVarLookup(varConsulPrefix)
+		if err != nil {
+			c.errorf("Error initializing consul client: %s", err)
+			return 1
+		}
+		varLookups = append(varLookups, consulLookup)
+	}
+
+	job, err := c.readJob(args[0], varLookups)
+	if err != nil {
+		c.errorf("Error getting job struct: %s", err)
+		return 1
+	}
+	if err := overrides.Apply(job); err != nil {
+		c.errorf("Failed to parse args: %v", err)
+		return 1
+	}
+
+	if printJob {
+		out, err := formatData(OutputJSON, job)
+		if err != nil {
+			c.errorf("Error converting job: %s", err)
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+	if _, _, err := client.Jobs().Register(job, nil); err != nil {
+		c.errorf("Error submitting job: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(map[string]string{"JobID": *job.ID})
+	}
+	c.Ui.Output(fmt.Sprintf("Job %q registered", *job.ID))
+	return 0
+}
+
+// readJob reads the exported job at path, with its template variables
+// interpolated.
+func (c *JobImportCommand) readJob(path string, varLookups []VarLookup) (*api.Job, error) {
+	var src []byte
+	var err error
+	if path == "-" {
+		var stdin io.Reader = os.Stdin
+		if c.testStdin != nil {
+			stdin = c.testStdin
+		}
+		src, err = ioutil.ReadAll(stdin)
+	} else {
+		src, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	escaped := make([]VarLookup, len(varLookups))
+	for i, lookup := range varLookups {
+		escaped[i] = jsonStringVarLookup(lookup)
+	}
+	interpolated, err := InterpolateVars(string(src), escaped...)
+	if err != nil {
+		return nil, fmt.Errorf("Error interpolating job file from %s: %v", path, err)
+	}
+	var job api.Job
+	dec := json.NewDecoder(bytes.NewReader([]byte(interpolated)))
+	dec.UseNumber()
+	if err := dec.Decode(&job); err != nil {
+		return nil, fmt.Errorf("Error parsing job file from %s: %v", path, err)
+	}
+	if job.ID == nil {
+		return nil, fmt.Errorf("Error parsing job file from %s: missing job ID", path)
+	}
+	return &job, nil
+}
+
+// jsonStringVarLookup escapes the values found by lookup, to be interpolated in
+// the strings of a JSON file.
+func jsonStringVarLookup(lookup VarLookup) VarLookup {
+	return func(name string) (string, bool, error) {
+		value, ok, err := lookup(name)
+		if !ok || err != nil {
+			return value, ok, err
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", false, err
+		}
+		return string(b[1 : len(b)-1]), true, nil
+	}
+}
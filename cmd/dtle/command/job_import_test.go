@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestJobImportCommand_readJob(t *testing.T) {
+	exported := `{
+    "ID": "job1",
+    "Name": "job1",
+    "Tasks": [{
+        "Type": "Src",
+        "Config": {
+            "ConnectionConfig": {"Host": "10.0.0.1", "Port": 3306, "Password": "${Src_ConnectionConfig_Password}"},
+            "ReplicateDoDb": [{"TableSchema": "db1", "Tables": [{"TableName": "tb1", "Where": "c = '$${x}'"}]}]
+        }
+    }]
+}`
+	c := &JobImportCommand{Meta: Meta{Ui: new(cli.MockUi)}, testStdin: strings.NewReader(exported)}
+	flagLookup, err := FlagVarLookup([]string{`Src_ConnectionConfig_Password=a"b\c`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := c.readJob("-", []VarLookup{flagLookup})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := job.Tasks[0].Config["ConnectionConfig"].(map[string]interface{})
+	if conn["Password"] != `a"b\c` || conn["Port"] != json.Number("3306") {
+		t.Errorf("ConnectionConfig = %v", conn)
+	}
+	table := job.Tasks[0].Config["ReplicateDoDb"].([]interface{})[0].(map[string]interface{})["Tables"].([]interface{})[0].(map[string]interface{})
+	if table["Where"] != "c = '${x}'" {
+		t.Errorf("Where = %v", table["Where"])
+	}
+
+	c.testStdin = strings.NewReader(exported)
+	if _, err := c.readJob("-", nil); err == nil || !strings.Contains(err.Error(), "Src_ConnectionConfig_Password") {
+		t.Errorf("readJob() without the password = %v", err)
+	}
+}
+
+func TestJobOverrideFlags(t *testing.T) {
+	f := jobOverrideFlags{
+		name:    "job2",
+		labels:  []string{"env=prod", "team="},
+		configs: []string{"Dest.ConnectionConfig.Host=10.0.0.2", "Src.Where=a=1"},
+	}
+	overrides, err := f.overrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overrides.Name != "job2" || overrides.Labels["env"] != "prod" || overrides.Labels["team"] != "" ||
+		overrides.Config["Dest.ConnectionConfig.Host"] != "10.0.0.2" || overrides.Config["Src.Where"] != "a=1" {
+		t.Errorf("overrides = %+v", overrides)
+	}
+
+	for _, f := range []jobOverrideFlags{
+		{labels: []string{"env"}},
+		{configs: []string{"=x"}},
+	} {
+		if _, err := f.overrides(); err == nil {
+			t.Errorf("overrides() of %+v succeeded", f)
+		}
+	}
+}
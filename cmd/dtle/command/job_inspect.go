@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type JobInspectCommand struct {
+	Meta
+}
+
+func (c *JobInspectCommand) Help() string {
+	helpText := `
+Usage: dtle job-inspect [options] <job>
+
+  Display the job spec with the effective config of each task, in JSON: the
+  defaults applied, the table filters normalized and the passwords redacted.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobInspectCommand) Synopsis() string {
+	return "Display the effective configuration of a job"
+}
+
+func (c *JobInspectCommand) AutocompleteFlags() complete.Flags {
+	return c.Meta.AutocompleteFlags(FlagSetClient)
+}
+
+func (c *JobInspectCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictJobs()
+}
+
+func (c *JobInspectCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("job-inspect", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	inspection, _, err := client.Jobs().Inspect(args[0], nil)
+	if err != nil {
+		c.errorf("Error inspecting job: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(inspection)
+	}
+	out, err := json.MarshalIndent(inspection, "", "    ")
+	if err != nil {
+		c.errorf("Error formatting job: %s", err)
+		return 1
+	}
+	c.Ui.Output(string(out))
+	return 0
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/posener/complete"
 	"github.com/ryanuber/columnize"
 
 	"github.com/actiontech/dtle/api"
@@ -46,6 +47,17 @@ func (c *ServerMembersCommand) Synopsis() string {
 	return "Display a list of known managers and their status"
 }
 
+func (c *ServerMembersCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-detailed": complete.PredictNothing,
+		})
+}
+
+func (c *ServerMembersCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
 func (c *ServerMembersCommand) Run(args []string) int {
 	var detailed bool
 
@@ -67,29 +79,32 @@ func (c *ServerMembersCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
 	// Query the members
 	srvMembers, err := client.Agent().Members()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying servers: %s", err))
+		c.errorf("Error querying servers: %s", err)
 		return 1
 	}
 
 	if srvMembers == nil {
-		c.Ui.Error("Agent doesn't know about server members")
+		c.errorf("Agent doesn't know about server members")
 		return 0
 	}
 
 	// Sort the members
 	sort.Sort(api.AgentMembersNameSort(srvMembers.Members))
+	if c.structuredOutput() {
+		return c.outputData(srvMembers.Members)
+	}
 
 	// Determine the leaders per region.
 	leaders, err := regionLeaders(client, srvMembers.Members)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error determining leaders: %s", err))
+		c.errorf("Error determining leaders: %s", err)
 		return 1
 	}
 
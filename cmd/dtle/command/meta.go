@@ -15,8 +15,10 @@ import (
 
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/colorstring"
+	"github.com/posener/complete"
 
 	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/i18n"
 )
 
 const (
@@ -51,6 +53,12 @@ type Meta struct {
 
 	// The region to send API requests
 	region string
+
+	// The ACL token of the API requests
+	token string
+
+	// The format of the output, table by default
+	output string
 }
 
 // FlagSet returns a FlagSet with the common flags that every
@@ -65,9 +73,11 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	if fs&FlagSetClient != 0 {
 		f.StringVar(&m.flagAddress, "address", "", "")
 		f.StringVar(&m.region, "region", "", "")
+		f.StringVar(&m.token, "token", "", "")
 		f.BoolVar(&m.noColor, "no-color", false, "")
 
 	}
+	m.outputFlag(f)
 
 	// Create an io.Writer that writes to our UI properly for errors.
 	// This is kind of a hack, but it does the job. Basically: create
@@ -101,10 +111,117 @@ func (m *Meta) Client() (*api.Client, error) {
 	if m.region != "" {
 		config.Region = m.region
 	}
+	if m.token != "" {
+		config.Token = m.token
+	}
+	if config.Language == "" {
+		config.Language = i18n.EnvLocale()
+	}
 
 	return api.NewClient(config)
 }
 
+// errorf outputs the error translated to the locale of the environment.
+func (m *Meta) errorf(format string, args ...interface{}) {
+	m.Ui.Error(i18n.Sprintf(i18n.EnvLocale(), format, args...))
+}
+
+// outputFlag adds the -output flag to the FlagSet.
+func (m *Meta) outputFlag(f *flag.FlagSet) {
+	m.output = OutputTable
+	f.Var((*outputFormat)(&m.output), "output", "")
+}
+
+// structuredOutput returns whether the output is in json or yaml, for scripts,
+// instead of tables and messages.
+func (m *Meta) structuredOutput() bool {
+	return m.output == OutputJSON || m.output == OutputYAML
+}
+
+// outputData outputs the data in json or yaml, and returns the exit code.
+func (m *Meta) outputData(data interface{}) int {
+	out, err := formatData(m.output, data)
+	if err != nil {
+		m.errorf("Error formatting output: %s", err)
+		return 1
+	}
+	m.Ui.Output(out)
+	return 0
+}
+
+// outputMessage outputs the message of a change made, unless the output is in
+// json or yaml, where the exit code tells whether it was made.
+func (m *Meta) outputMessage(msg string) {
+	if !m.structuredOutput() {
+		m.Ui.Output(msg)
+	}
+}
+
+// AutocompleteFlags returns the autocompletion of the common flags of the
+// FlagSet.
+func (m *Meta) AutocompleteFlags(fs FlagSetFlags) complete.Flags {
+	flags := complete.Flags{
+		"-output": complete.PredictSet(OutputTable, OutputJSON, OutputYAML),
+	}
+	if fs&FlagSetClient != 0 {
+		flags["-address"] = complete.PredictAnything
+		flags["-region"] = complete.PredictAnything
+		flags["-token"] = complete.PredictAnything
+		flags["-no-color"] = complete.PredictNothing
+	}
+	return flags
+}
+
+// predictJobs predicts the IDs of the jobs by the prefix typed. The agent is
+// given by UDUP_ADDR, as the flags are not parsed.
+func (m *Meta) predictJobs() complete.Predictor {
+	return complete.PredictFunc(func(a complete.Args) []string {
+		client, err := m.Client()
+		if err != nil {
+			return nil
+		}
+		jobs, _, err := client.Jobs().PrefixList(a.Last)
+		if err != nil {
+			return nil
+		}
+		ids := make([]string, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+		}
+		return ids
+	})
+}
+
+// predictNodes predicts the IDs of the nodes by the prefix typed.
+func (m *Meta) predictNodes() complete.Predictor {
+	return complete.PredictFunc(func(a complete.Args) []string {
+		client, err := m.Client()
+		if err != nil {
+			return nil
+		}
+		nodes, _, err := client.Nodes().PrefixList(a.Last)
+		if err != nil {
+			return nil
+		}
+		ids := make([]string, len(nodes))
+		for i, node := range nodes {
+			ids[i] = node.ID
+		}
+		return ids
+	})
+}
+
+// mergeAutocompleteFlags merges the autocompletion of flags.
+func mergeAutocompleteFlags(flags ...complete.Flags) complete.Flags {
+	merged := make(complete.Flags)
+	for _, f := range flags {
+		for k, v := range f {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
 func (m *Meta) Colorize() *colorstring.Colorize {
 	return &colorstring.Colorize{
 		Colors:  colorstring.DefaultColors,
@@ -125,9 +242,18 @@ func generalOptionsUsage() string {
     The region of the Dtle servers to forward commands to.
     Overrides the UDUP_REGION environment variable if set.
     Defaults to the Agent's local region.
+
+  -token=<token>
+    The ACL token of the requests, if the agent has ACLs enabled.
+    Overrides the UDUP_TOKEN environment variable if set.
   
   -no-color
     Disables colored command output.
+
+  -output=<format>
+    The format of the output: table, json or yaml, for scripts. Defaults to
+    table. The commands which only make a change output nothing in json or
+    yaml, their exit code tells whether it was made.
 `
 	return strings.TrimSpace(helpText)
 }
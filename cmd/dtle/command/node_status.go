@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/mitchellh/colorstring"
+	"github.com/posener/complete"
 
 	"github.com/actiontech/dtle/api"
 )
@@ -80,6 +81,20 @@ func (c *NodeStatusCommand) Synopsis() string {
 	return "Display status information about nodes"
 }
 
+func (c *NodeStatusCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-self":    complete.PredictNothing,
+			"-stats":   complete.PredictNothing,
+			"-allocs":  complete.PredictNothing,
+			"-verbose": complete.PredictNothing,
+		})
+}
+
+func (c *NodeStatusCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictNodes()
+}
+
 func (c *NodeStatusCommand) Run(args []string) int {
 
 	flags := c.Meta.FlagSet("node-status", FlagSetClient)
@@ -105,7 +120,7 @@ func (c *NodeStatusCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
@@ -114,10 +129,17 @@ func (c *NodeStatusCommand) Run(args []string) int {
 		// Query the node info
 		nodes, _, err := client.Nodes().List(nil)
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error querying node status: %s", err))
+			c.errorf("Error querying node status: %s", err)
 			return 1
 		}
 
+		if c.structuredOutput() {
+			if nodes == nil {
+				nodes = []*api.NodeListStub{}
+			}
+			return c.outputData(nodes)
+		}
+
 		// Return nothing if no nodes found
 		if len(nodes) == 0 {
 			return 0
@@ -135,7 +157,7 @@ func (c *NodeStatusCommand) Run(args []string) int {
 			if c.list_allocs {
 				numAllocs, err := getRunningAllocs(client, node.ID)
 				if err != nil {
-					c.Ui.Error(fmt.Sprintf("Error querying node allocations: %s", err))
+					c.errorf("Error querying node allocations: %s", err)
 					return 1
 				}
 				out[i+1] = fmt.Sprintf("%s|%s|%s|%s|%v",
@@ -170,7 +192,7 @@ func (c *NodeStatusCommand) Run(args []string) int {
 		}
 	}
 	if len(nodeID) == 1 {
-		c.Ui.Error(fmt.Sprintf("Identifier must contain at least two characters."))
+		c.errorf("Identifier must contain at least two characters.")
 		return 1
 	}
 	if len(nodeID)%2 == 1 {
@@ -181,15 +203,18 @@ func (c *NodeStatusCommand) Run(args []string) int {
 
 	nodes, _, err := client.Nodes().PrefixList(nodeID)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying node info: %s", err))
+		c.errorf("Error querying node info: %s", err)
 		return 1
 	}
 	// Return error if no nodes are found
 	if len(nodes) == 0 {
-		c.Ui.Error(fmt.Sprintf("No node(s) with prefix %q found", nodeID))
+		c.errorf("No node(s) with prefix %q found", nodeID)
 		return 1
 	}
 	if len(nodes) > 1 {
+		if c.structuredOutput() {
+			return c.outputData(nodes)
+		}
 		// Format the nodes list that matches the prefix so that the user
 		// can create a more specific request
 		out := make([]string, len(nodes)+1)
@@ -208,9 +233,12 @@ func (c *NodeStatusCommand) Run(args []string) int {
 	// Prefix lookup matched a single node
 	node, _, err := client.Nodes().Info(nodes[0].ID, nil)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying node info: %s", err))
+		c.errorf("Error querying node info: %s", err)
 		return 1
 	}
+	if c.structuredOutput() {
+		return c.outputData(node)
+	}
 
 	return c.formatNode(client, node)
 }
@@ -249,7 +277,7 @@ func (c *NodeStatusCommand) formatNode(client *api.Client, node *api.Node) int {
 
 	allocs, err := getAllocs(client, node, c.length)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying node allocations: %s", err))
+		c.errorf("Error querying node allocations: %s", err)
 		return 1
 	}
 
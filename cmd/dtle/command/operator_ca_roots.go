@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type OperatorCARootsCommand struct {
+	Meta
+}
+
+func (c *OperatorCARootsCommand) Help() string {
+	helpText := `
+Usage: dtle operator ca roots [options]
+
+Display the roots of the CA built in the managers, which issues the
+certificates of the mutual TLS of the RPC. The fingerprint of a root can be
+set as the tls ca_fingerprint of the agents.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorCARootsCommand) Synopsis() string {
+	return "Display the roots of the CA"
+}
+
+func (c *OperatorCARootsCommand) AutocompleteFlags() complete.Flags {
+	return c.Meta.AutocompleteFlags(FlagSetClient)
+}
+
+func (c *OperatorCARootsCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorCARootsCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("ca roots", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	roots, _, err := client.Operator().CARoots(nil)
+	if err != nil {
+		c.errorf("Error querying CA roots: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(roots)
+	}
+
+	out := make([]string, len(roots)+1)
+	out[0] = "ID|Active|Created|Rotated|Fingerprint"
+	for i, root := range roots {
+		rotated := "-"
+		if root.RotateTime != 0 {
+			rotated = formatUnixNanoTime(root.RotateTime)
+		}
+		out[i+1] = fmt.Sprintf("%s|%v|%s|%s|%s",
+			root.ID,
+			root.Active,
+			formatUnixNanoTime(root.CreateTime),
+			rotated,
+			root.Fingerprint)
+	}
+	c.Ui.Output(formatList(out))
+	return 0
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type OperatorCARotateCommand struct {
+	Meta
+}
+
+func (c *OperatorCARotateCommand) Help() string {
+	helpText := `
+Usage: dtle operator ca rotate [options]
+
+Replace the root of the CA built in the managers by a new one. The agents
+learn the new root at once, and get their certificates signed by it when they
+renew them. The previous root is trusted until the certificates it signed
+expire.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorCARotateCommand) Synopsis() string {
+	return "Rotate the root of the CA"
+}
+
+func (c *OperatorCARotateCommand) AutocompleteFlags() complete.Flags {
+	return c.Meta.AutocompleteFlags(FlagSetClient)
+}
+
+func (c *OperatorCARotateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorCARotateCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("ca rotate", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	if _, err := client.Operator().CARotate(nil); err != nil {
+		c.errorf("Error rotating the CA root: %s", err)
+		return 1
+	}
+
+	c.outputMessage("Rotated the CA root")
+	return 0
+}
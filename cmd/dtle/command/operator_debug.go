@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+)
+
+type OperatorDebugCommand struct {
+	Meta
+}
+
+func (c *OperatorDebugCommand) Help() string {
+	helpText := `
+Usage: dtle operator debug [options] <file>
+
+Profile the agent for a duration, and save the profiles to the file as a
+tar.gz: the heap at the start and at the end, the CPU profile, the execution
+trace, the goroutines and the memory stats. E.g. to diagnose the memory growth
+of an agent during a large dump. The agent should have enable_debug.
+
+The profiles are read by "go tool pprof" and "go tool trace".
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Debug Options:
+
+  -duration=<duration>
+    How long to profile the agent. Defaults to 30s, at most 5m.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorDebugCommand) Synopsis() string {
+	return "Save a bundle of the profiles of an agent"
+}
+
+func (c *OperatorDebugCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-duration": complete.PredictAnything,
+		})
+}
+
+func (c *OperatorDebugCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *OperatorDebugCommand) Run(args []string) int {
+	var duration time.Duration
+
+	flags := c.Meta.FlagSet("operator debug", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.DurationVar(&duration, "duration", 30*time.Second, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 || duration < time.Second {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	file := args[0]
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	c.outputMessage(fmt.Sprintf("Profiling the agent for %v...", duration))
+	bundle, err := client.Agent().DebugBundle(duration)
+	if err != nil {
+		c.errorf("Error profiling the agent: %s", err)
+		return 1
+	}
+	defer bundle.Close()
+
+	// Write to a temp file first, so that a failure doesn't leave a partial
+	// bundle behind.
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		c.errorf("Error creating file: %s", err)
+		return 1
+	}
+	if _, err := io.Copy(f, bundle); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		c.errorf("Error writing debug bundle: %s", err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		c.errorf("Error writing debug bundle: %s", err)
+		return 1
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		c.errorf("Error writing debug bundle: %s", err)
+		return 1
+	}
+
+	c.outputMessage(fmt.Sprintf("Saved debug bundle to %q", file))
+	return 0
+}
@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/posener/complete"
+
 	"github.com/actiontech/dtle/api"
 )
 
@@ -49,6 +51,18 @@ func (c *OperatorRaftRemoveCommand) Synopsis() string {
 	return "Remove a Dtle server from the Raft configuration"
 }
 
+func (c *OperatorRaftRemoveCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetNone),
+		complete.Flags{
+			"-address": complete.PredictAnything,
+			"-id":      complete.PredictAnything,
+		})
+}
+
+func (c *OperatorRaftRemoveCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
 func (c *OperatorRaftRemoveCommand) Run(args []string) int {
 	flags := flag.NewFlagSet("", flag.ContinueOnError)
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
@@ -58,31 +72,32 @@ func (c *OperatorRaftRemoveCommand) Run(args []string) int {
 		"The address to remove from the Raft configuration.")
 	flags.StringVar(&id, "id", "",
 		"The ID to remove from the Raft configuration.")
+	c.outputFlag(flags)
 
 	if err := flags.Parse(args); err != nil {
 		if err == flag.ErrHelp {
 			return 0
 		}
-		c.Ui.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		c.errorf("Failed to parse args: %v", err)
 		return 1
 	}
 
 	// Set up a client.
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
 	// Fetch the current configuration.
 	if err := raftRemovePeers(address, id, client.Operator()); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error removing peer: %v", err))
+		c.errorf("Error removing peer: %v", err)
 		return 1
 	}
 	if address != "" {
-		c.Ui.Output(fmt.Sprintf("Removed peer with address %q", address))
+		c.outputMessage(fmt.Sprintf("Removed peer with address %q", address))
 	} else {
-		c.Ui.Output(fmt.Sprintf("Removed peer with id %q", id))
+		c.outputMessage(fmt.Sprintf("Removed peer with id %q", id))
 	}
 
 	return 0
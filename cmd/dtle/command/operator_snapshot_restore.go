@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+type OperatorSnapshotRestoreCommand struct {
+	Meta
+}
+
+func (c *OperatorSnapshotRestoreCommand) Help() string {
+	helpText := `
+Usage: dtle operator snapshot restore [options] <file>
+       dtle operator snapshot restore [options] -storage <key>
+
+Replace the manager state with an archive saved by "dtle operator snapshot
+save". The Raft configuration of the cluster is kept, so the archive can be
+restored into a new cluster. This is meant for disaster recovery: the state
+written since the archive was saved is lost.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Snapshot Restore Options:
+
+  -storage
+    Restore the archive of the key in the storage configured by the storage
+    block of the agent, as printed by "dtle operator snapshot save -storage",
+    instead of a file.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorSnapshotRestoreCommand) Synopsis() string {
+	return "Restore the manager state from an archive"
+}
+
+func (c *OperatorSnapshotRestoreCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-storage": complete.PredictNothing,
+		})
+}
+
+func (c *OperatorSnapshotRestoreCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *OperatorSnapshotRestoreCommand) Run(args []string) int {
+	var fromStorage bool
+
+	flags := c.Meta.FlagSet("snapshot restore", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&fromStorage, "storage", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	file := args[0]
+
+	if fromStorage {
+		client, err := c.Meta.Client()
+		if err != nil {
+			c.errorf("Error initializing client: %s", err)
+			return 1
+		}
+		if err := client.Operator().SnapshotRestoreFromStorage(file, nil); err != nil {
+			c.errorf("Error restoring snapshot: %s", err)
+			return 1
+		}
+		c.outputMessage(fmt.Sprintf("Restored snapshot from storage key %q", file))
+		return 0
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		c.errorf("Error opening snapshot file: %s", err)
+		return 1
+	}
+	defer f.Close()
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	if err := client.Operator().SnapshotRestore(f, nil); err != nil {
+		c.errorf("Error restoring snapshot: %s", err)
+		return 1
+	}
+
+	c.outputMessage(fmt.Sprintf("Restored snapshot from %q", file))
+	return 0
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/posener/complete"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type OperatorSnapshotSaveCommand struct {
+	Meta
+}
+
+func (c *OperatorSnapshotSaveCommand) Help() string {
+	helpText := `
+Usage: dtle operator snapshot save [options] <file>
+       dtle operator snapshot save [options] -storage
+
+Save an archive of the manager state to the file, or to the storage of the
+agent. The archive holds the jobs, orders, nodes, evaluations and allocations,
+including the replication positions of the jobs, and can be restored by "dtle
+operator snapshot restore", e.g. into a new cluster.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Snapshot Save Options:
+
+  -stale
+    Allow a manager other than the leader to take the snapshot.
+
+  -storage
+    Save the archive in the storage configured by the storage block of the
+    agent, e.g. a bucket of S3, under the key snapshots/<region>-<time>.snap
+    printed.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorSnapshotSaveCommand) Synopsis() string {
+	return "Save an archive of the manager state"
+}
+
+func (c *OperatorSnapshotSaveCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-stale":   complete.PredictNothing,
+			"-storage": complete.PredictNothing,
+		})
+}
+
+func (c *OperatorSnapshotSaveCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *OperatorSnapshotSaveCommand) Run(args []string) int {
+	var stale, toStorage bool
+
+	flags := c.Meta.FlagSet("snapshot save", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&stale, "stale", false, "")
+	flags.BoolVar(&toStorage, "storage", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if toStorage && len(args) != 0 || !toStorage && len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	q := &api.QueryOptions{AllowStale: stale}
+	if toStorage {
+		stored, err := client.Operator().SnapshotSaveToStorage(q)
+		if err != nil {
+			c.errorf("Error saving snapshot: %s", err)
+			return 1
+		}
+		if c.structuredOutput() {
+			return c.outputData(stored)
+		}
+		c.Ui.Output(fmt.Sprintf("Saved snapshot to %s (%d bytes), key %q", stored.URL, stored.Bytes, stored.Key))
+		return 0
+	}
+
+	file := args[0]
+	snapshot, err := client.Operator().SnapshotSave(q)
+	if err != nil {
+		c.errorf("Error saving snapshot: %s", err)
+		return 1
+	}
+	defer snapshot.Close()
+
+	// Write to a temp file first, so that a failure doesn't leave a partial
+	// archive behind.
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		c.errorf("Error creating file: %s", err)
+		return 1
+	}
+	if _, err := io.Copy(f, snapshot); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		c.errorf("Error writing snapshot: %s", err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		c.errorf("Error writing snapshot: %s", err)
+		return 1
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		c.errorf("Error writing snapshot: %s", err)
+		return 1
+	}
+
+	c.outputMessage(fmt.Sprintf("Saved snapshot to %q", file))
+	return 0
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"os"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver"
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// PluginCommand serves a builtin driver as a driver plugin. It is run by the
+// agent, through a "dtle-driver-<name>" executable in the plugin_dir.
+type PluginCommand struct {
+	Meta
+}
+
+func (c *PluginCommand) Help() string {
+	helpText := `
+Usage: dtle plugin [options] <driver>
+
+  Serve the builtin driver as a driver plugin. It is not run directly, but
+  from an executable named "dtle-driver-<driver>" in the plugin_dir of the
+  agent, e.g.
+
+    #!/bin/sh
+    exec /usr/bin/dtle plugin MySQL
+
+Plugin Options:
+
+  -log-level=<level>
+    The level of the logs of the plugin, which are written to the log of the
+    agent. Defaults to INFO.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *PluginCommand) Synopsis() string {
+	return "Serve a builtin driver as a driver plugin"
+}
+
+func (c *PluginCommand) Run(args []string) int {
+	var logLevel string
+
+	flags := c.Meta.FlagSet("plugin", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&logLevel, "log-level", "INFO", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	// stdout is used by the handshake with the agent
+	logger := ulog.New(os.Stderr, ulog.ParseLevel(logLevel))
+	if err := driver.ServeBuiltin(args[0], logger); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	return 0
+}
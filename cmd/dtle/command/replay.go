@@ -0,0 +1,219 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+type ReplayCommand struct {
+	Meta
+}
+
+func (c *ReplayCommand) Help() string {
+	helpText := `
+Usage: dtle replay [options] <sql audit file>
+
+  Apply again the statements of the sql audit file of a job (the SqlAuditFile
+  of its Dest task) to a MySQL server, e.g. to rebuild a corrupted copy of the
+  target. The statements of each source transaction are applied in a
+  transaction, in the order they were applied on the target. The replay stops
+  at the first statement failed.
+
+  Select the statements with -gtid, or with -since and -until: the statements
+  of the full copy, which have no GTID, are replayed only without -gtid.
+
+Replay Options:
+
+  -job=<job>
+    Replay only the statements of the job, if the file has several jobs.
+
+  -gtid=<gtid set>
+    Replay only the source transactions of the GTID set, e.g.
+    3e11fa47-71ca-11e1-9e33-c80aa9429562:100-200.
+
+  -since=<time>
+    Replay only the transactions applied since the time, in RFC3339.
+
+  -until=<time>
+    Replay only the transactions applied before the time, in RFC3339.
+
+  -host=<host>, -port=<port>, -user=<user>
+    The MySQL server to apply the statements to, 127.0.0.1:3306 by default.
+    The password is given by the environment variable MYSQL_PWD.
+
+  -dry-run
+    List the transactions selected instead of applying them.
+
+  -output=<format>
+    The format of the output: table, json or yaml, for scripts. Defaults to
+    table.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ReplayCommand) Synopsis() string {
+	return "Apply again the statements of a sql audit file"
+}
+
+func (c *ReplayCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetNone),
+		complete.Flags{
+			"-job":     complete.PredictAnything,
+			"-gtid":    complete.PredictAnything,
+			"-since":   complete.PredictAnything,
+			"-until":   complete.PredictAnything,
+			"-host":    complete.PredictAnything,
+			"-port":    complete.PredictAnything,
+			"-user":    complete.PredictAnything,
+			"-dry-run": complete.PredictNothing,
+		})
+}
+
+func (c *ReplayCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+// replayResult is the output of the replay command.
+type replayResult struct {
+	Transactions int
+	Statements   int
+}
+
+func (c *ReplayCommand) Run(args []string) int {
+	var gtid, since, until string
+	var dryRun bool
+	filter := &mysql.SqlAuditFilter{}
+	conn := &umconf.ConnectionConfig{
+		Password: os.Getenv("MYSQL_PWD"),
+	}
+
+	flags := c.Meta.FlagSet("replay", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&filter.Job, "job", "", "")
+	flags.StringVar(&gtid, "gtid", "", "")
+	flags.StringVar(&since, "since", "", "")
+	flags.StringVar(&until, "until", "", "")
+	flags.StringVar(&conn.Host, "host", "127.0.0.1", "")
+	flags.IntVar(&conn.Port, "port", 3306, "")
+	flags.StringVar(&conn.User, "user", "root", "")
+	flags.BoolVar(&dryRun, "dry-run", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	var err error
+	if gtid != "" {
+		set, err := gomysql.ParseMysqlGTIDSet(gtid)
+		if err != nil {
+			c.errorf("Invalid -gtid: %s", err)
+			return 1
+		}
+		filter.GtidSet = set.(*gomysql.MysqlGTIDSet)
+	}
+	if since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			c.errorf("Invalid -since: %s", err)
+			return 1
+		}
+	}
+	if until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			c.errorf("Invalid -until: %s", err)
+			return 1
+		}
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.errorf("Error reading sql audit file: %s", err)
+		return 1
+	}
+	defer f.Close()
+
+	if dryRun {
+		var txs []*mysql.SqlAuditTx
+		err := mysql.ReadSqlAudit(f, filter, func(tx *mysql.SqlAuditTx) error {
+			txs = append(txs, tx)
+			return nil
+		})
+		if err != nil {
+			c.errorf("Error reading sql audit file: %s", err)
+			return 1
+		}
+		return c.outputTransactions(txs)
+	}
+
+	db, err := sql.CreateDB(conn.GetDBUri())
+	if err != nil {
+		c.errorf("Error connecting to the MySQL server: %s", err)
+		return 1
+	}
+	defer db.Close()
+	// A single connection keeps the current schema set by the statements.
+	dbConn, err := db.Conn(context.Background())
+	if err != nil {
+		c.errorf("Error connecting to the MySQL server: %s", err)
+		return 1
+	}
+	defer dbConn.Close()
+
+	result := &replayResult{}
+	err = mysql.ReadSqlAudit(f, filter, func(tx *mysql.SqlAuditTx) error {
+		if err := mysql.ApplySqlAuditTx(dbConn, tx); err != nil {
+			return err
+		}
+		result.Transactions++
+		result.Statements += len(tx.Records)
+		return nil
+	})
+	if err != nil {
+		// Tell where to resume.
+		c.Ui.Output(fmt.Sprintf("Replayed %d transactions, %d statements", result.Transactions, result.Statements))
+		c.errorf("Error replaying sql audit file: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(result)
+	}
+	c.Ui.Output(fmt.Sprintf("Replayed %d transactions, %d statements", result.Transactions, result.Statements))
+	return 0
+}
+
+func (c *ReplayCommand) outputTransactions(txs []*mysql.SqlAuditTx) int {
+	if c.structuredOutput() {
+		return c.outputData(txs)
+	}
+	out := make([]string, len(txs)+1)
+	out[0] = "Gtid|Time|Statements"
+	for i, tx := range txs {
+		gtid := tx.Gtid
+		if gtid == "" {
+			gtid = "<full copy>"
+		}
+		out[i+1] = fmt.Sprintf("%s|%s|%d", gtid, tx.Time.Format(time.RFC3339Nano), len(tx.Records))
+	}
+	c.Ui.Output(formatList(out))
+	return 0
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestReplayCommand_DryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	audit := `{"Time":"2026-01-01T10:00:00Z","Job":"job1","Query":"replace into a.b values (1)"}
+{"Time":"2026-01-01T10:01:00Z","Job":"job1","Gtid":"3e11fa47-71ca-11e1-9e33-c80aa9429562:1","Query":"insert into a.b values (?)","Args":[2]}
+{"Time":"2026-01-01T10:02:00Z","Job":"job1","Gtid":"3e11fa47-71ca-11e1-9e33-c80aa9429562:2","Query":"delete from a.b where id = ?","Args":[1]}
+`
+	if err := ioutil.WriteFile(path, []byte(audit), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ReplayCommand{Meta: Meta{Ui: ui}}
+	if code := c.Run([]string{"-dry-run", "-gtid", "3e11fa47-71ca-11e1-9e33-c80aa9429562:2-10", path}); code != 0 {
+		t.Fatalf("exit code %d: %s", code, ui.ErrorWriter.String())
+	}
+	out := ui.OutputWriter.String()
+	if !strings.Contains(out, "3e11fa47-71ca-11e1-9e33-c80aa9429562:2") || strings.Contains(out, ":1 ") ||
+		strings.Contains(out, "full copy") {
+		t.Errorf("output = %s", out)
+	}
+
+	ui = new(cli.MockUi)
+	c = &ReplayCommand{Meta: Meta{Ui: ui}}
+	if code := c.Run([]string{"-dry-run", "-since", "yesterday", path}); code != 1 {
+		t.Errorf("exit code %d with a bad -since", code)
+	}
+}
@@ -7,8 +7,9 @@
 package command
 
 import (
-	"fmt"
 	"strings"
+
+	"github.com/posener/complete"
 )
 
 type ServerForceLeaveCommand struct {
@@ -34,6 +35,14 @@ func (c *ServerForceLeaveCommand) Synopsis() string {
 	return "Force a server into the 'left' state"
 }
 
+func (c *ServerForceLeaveCommand) AutocompleteFlags() complete.Flags {
+	return c.Meta.AutocompleteFlags(FlagSetClient)
+}
+
+func (c *ServerForceLeaveCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictAnything
+}
+
 func (c *ServerForceLeaveCommand) Run(args []string) int {
 	flags := c.Meta.FlagSet("server-force-leave", FlagSetClient)
 	flags.Usage = func() { c.Ui.Output(c.Help()) }
@@ -52,13 +61,13 @@ func (c *ServerForceLeaveCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
 	// Call force-leave on the node
 	if err := client.Agent().ForceLeave(node); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error force-leaving server %s: %s", node, err))
+		c.errorf("Error force-leaving server %s: %s", node, err)
 		return 1
 	}
 
@@ -7,13 +7,14 @@
 package command
 
 import (
-	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/posener/complete"
 
 	"github.com/actiontech/dtle/agent"
 	"github.com/actiontech/dtle/api"
@@ -56,6 +57,11 @@ Usage: dtle start [options] <path>
   If the job has specified the region, the -region flag and UDUP_REGION
   environment variable are overridden and the job's region is used.
 
+  The jobfile could be a template with variables like ${source_host}.
+  The value of a variable is looked up from, in order, the -var flags, the
+  DTLE_VAR_<name> environment variable, and consul KV <prefix>/<name> if
+  -var-consul-prefix is given. Use $${name} for a literal ${name}.
+
 General Options:
 
   ` + generalOptionsUsage() + `
@@ -73,14 +79,27 @@ Run Options:
   -detach
     Return immediately instead of entering monitor mode. After job submission,
     the evaluation ID will be printed to the screen, which can be used to
-    examine the evaluation using the eval-status command.
+    examine the evaluation using the eval-status command. Implied by
+    -output=json and -output=yaml, which output the evaluation ID as EvalID.
 
   -verbose
     Display full information.
 
-  -output
+  -print-job
     Output the JSON that would be submitted to the HTTP API without submitting
-    the job.
+    the job, or the yaml of it with -output=yaml. Formerly -output.
+
+  -simulate
+    Estimate the full copy of the job without submitting it: the rows and bytes
+    of the tables, from information_schema of the source, the duration at
+    BandwidthLimitMBps and the peak memory. The data is not read.
+
+  -var name=value
+    Set a template variable. Could be specified multiple times.
+
+  -var-consul-prefix=<prefix>
+    Look up template variables from consul KV under the prefix. The consul
+    agent is set by the CONSUL_HTTP_ADDR environment variable.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -89,16 +108,37 @@ func (c *StartCommand) Synopsis() string {
 	return "Run a new job or update an existing job"
 }
 
+func (c *StartCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-check-index":       complete.PredictAnything,
+			"-detach":            complete.PredictNothing,
+			"-verbose":           complete.PredictNothing,
+			"-print-job":         complete.PredictNothing,
+			"-simulate":          complete.PredictNothing,
+			"-var":               complete.PredictAnything,
+			"-var-consul-prefix": complete.PredictAnything,
+		})
+}
+
+func (c *StartCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json"))
+}
+
 func (c *StartCommand) Run(args []string) int {
-	var detach, verbose, output bool
-	var checkIndexStr string
+	var detach, verbose, printJob, simulate bool
+	var checkIndexStr, varConsulPrefix string
+	var vars agent.StringFlag
 
 	flags := c.Meta.FlagSet("start", FlagSetClient)
 	flags.Usage = func() { c.Ui.Output(c.Help()) }
 	flags.BoolVar(&detach, "detach", false, "")
 	flags.BoolVar(&verbose, "verbose", false, "")
-	flags.BoolVar(&output, "output", false, "")
+	flags.BoolVar(&printJob, "print-job", false, "")
+	flags.BoolVar(&simulate, "simulate", false, "")
 	flags.StringVar(&checkIndexStr, "check-index", "", "")
+	flags.Var(&vars, "var", "")
+	flags.StringVar(&varConsulPrefix, "var-consul-prefix", "", "")
 
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -120,17 +160,32 @@ func (c *StartCommand) Run(args []string) int {
 		return 1
 	}
 
+	flagLookup, err := FlagVarLookup(vars)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	c.JobGetter.varLookups = []VarLookup{flagLookup, EnvVarLookup}
+	if varConsulPrefix != "" {
+		consulLookup, err := ConsulVarLookup(varConsulPrefix)
+		if err != nil {
+			c.errorf("Error initializing consul client: %s", err)
+			return 1
+		}
+		c.JobGetter.varLookups = append(c.JobGetter.varLookups, consulLookup)
+	}
+
 	// Get Job struct from Jobfile
 	job, err := c.JobGetter.ApiJob(args[0])
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error getting job struct: %s", err))
+		c.errorf("Error getting job struct: %s", err)
 		return 1
 	}
 
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
@@ -145,7 +200,7 @@ func (c *StartCommand) Run(args []string) int {
 		jr, err = c.validateLocal(job)
 	}
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error validating job: %s", err))
+		c.errorf("Error validating job: %s", err)
 		return 1
 	}
 
@@ -161,22 +216,29 @@ func (c *StartCommand) Run(args []string) int {
 		return 1
 	}*/
 
-	if output {
-		req := api.RegisterJobRequest{Job: job}
-		buf, err := json.MarshalIndent(req, "", "    ")
+	if printJob {
+		format := OutputJSON
+		if c.output == OutputYAML {
+			format = OutputYAML
+		}
+		out, err := formatData(format, api.RegisterJobRequest{Job: job})
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error converting job: %s", err))
+			c.errorf("Error converting job: %s", err)
 			return 1
 		}
 
-		c.Ui.Output(string(buf))
+		c.Ui.Output(out)
 		return 0
 	}
 
+	if simulate {
+		return c.simulate(client, job)
+	}
+
 	// Parse the check-index
 	checkIndex, enforce, err := parseCheckIndex(checkIndexStr)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error parsing check-index value %q: %v", checkIndexStr, err))
+		c.errorf("Error parsing check-index value %q: %v", checkIndexStr, err)
 		return 1
 	}
 
@@ -194,15 +256,19 @@ func (c *StartCommand) Run(args []string) int {
 			matches := enforceIndexRegex.FindStringSubmatch(err.Error())
 			if len(matches) == 2 {
 				c.Ui.Error(matches[1]) // The matched group
-				c.Ui.Error("Job not updated")
+				c.errorf("Job not updated")
 				return 1
 			}
 		}
 
-		c.Ui.Error(fmt.Sprintf("Error submitting job: %s", err))
+		c.errorf("Error submitting job: %s", err)
 		return 1
 	}
 
+	if c.structuredOutput() {
+		return c.outputData(map[string]string{"EvalID": evalID})
+	}
+
 	// Check if we should enter monitor mode
 	if detach {
 		c.Ui.Output("Job registration successful")
@@ -249,3 +315,45 @@ func (c *StartCommand) validateLocal(aj *api.Job) (*api.JobValidateResponse, err
 
 	return &out, nil
 }
+
+// simulate outputs the estimate of the full copy of the job.
+func (c *StartCommand) simulate(client *api.Client, job *api.Job) int {
+	simulation, _, err := client.Jobs().Simulate(job, nil)
+	if err != nil {
+		c.errorf("Error simulating job: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(simulation)
+	}
+
+	duration := "unknown"
+	if simulation.ProjectedSeconds > 0 {
+		duration = (time.Duration(simulation.ProjectedSeconds) * time.Second).String()
+	}
+	basic := []string{
+		fmt.Sprintf("Tables|%d", len(simulation.Tables)),
+		fmt.Sprintf("Rows|%d", simulation.Rows),
+		fmt.Sprintf("Data|%s", formatBytes(simulation.DataBytes)),
+		fmt.Sprintf("Indexes|%s", formatBytes(simulation.IndexBytes)),
+		fmt.Sprintf("Bandwidth Limit|%dMB/s", simulation.BandwidthLimitMBps),
+		fmt.Sprintf("Projected Duration|%s", duration),
+		fmt.Sprintf("Chunk Size|%s", formatBytes(simulation.ChunkBytes)),
+		fmt.Sprintf("Peak Memory per Task|%s", formatBytes(simulation.PeakMemoryBytes)),
+	}
+	c.Ui.Output(formatKV(basic))
+
+	out := make([]string, len(simulation.Tables)+1)
+	out[0] = "Table|Rows|Data|Indexes"
+	for i, t := range simulation.Tables {
+		out[i+1] = fmt.Sprintf("%s.%s|%d|%s|%s",
+			t.TableSchema, t.TableName, t.Rows, formatBytes(t.DataBytes), formatBytes(t.IndexBytes))
+	}
+	c.Ui.Output(c.Colorize().Color("\n[bold]Tables[reset]"))
+	c.Ui.Output(formatList(out))
+
+	for _, warning := range simulation.Warnings {
+		c.Ui.Warn(warning)
+	}
+	return 0
+}
@@ -11,6 +11,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/posener/complete"
+
 	"github.com/actiontech/dtle/api"
 )
 
@@ -26,6 +28,7 @@ type StatusCommand struct {
 	evals     bool
 	allAllocs bool
 	verbose   bool
+	namespace string
 }
 
 func (c *StatusCommand) Help() string {
@@ -50,6 +53,9 @@ Status Options:
 
   -verbose
     Display full information.
+
+  -namespace=<namespace>
+    List only the jobs of the namespace.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -58,6 +64,21 @@ func (c *StatusCommand) Synopsis() string {
 	return "Display status information about jobs"
 }
 
+func (c *StatusCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-short":      complete.PredictNothing,
+			"-evals":      complete.PredictNothing,
+			"-all-allocs": complete.PredictNothing,
+			"-verbose":    complete.PredictNothing,
+			"-namespace":  complete.PredictAnything,
+		})
+}
+
+func (c *StatusCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictJobs()
+}
+
 func (c *StatusCommand) Run(args []string) int {
 	var short bool
 
@@ -67,6 +88,7 @@ func (c *StatusCommand) Run(args []string) int {
 	flags.BoolVar(&c.evals, "evals", false, "")
 	flags.BoolVar(&c.allAllocs, "all-allocs", false, "")
 	flags.BoolVar(&c.verbose, "verbose", false, "")
+	flags.StringVar(&c.namespace, "namespace", "", "")
 
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -85,18 +107,24 @@ func (c *StatusCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
 	// Invoke list mode if no job ID.
 	if len(args) == 0 {
-		jobs, _, err := client.Jobs().List(nil)
+		jobs, _, err := client.Jobs().List(&api.QueryOptions{Namespace: c.namespace})
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error querying jobs: %s", err))
+			c.errorf("Error querying jobs: %s", err)
 			return 1
 		}
 
+		if c.structuredOutput() {
+			if jobs == nil {
+				jobs = []*api.JobListStub{}
+			}
+			return c.outputData(jobs)
+		}
 		if len(jobs) == 0 {
 			// No output if we have no jobs
 			c.Ui.Output("No running jobs")
@@ -110,23 +138,29 @@ func (c *StatusCommand) Run(args []string) int {
 	jobID := args[0]
 	jobs, _, err := client.Jobs().PrefixList(jobID)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+		c.errorf("Error querying job: %s", err)
 		return 1
 	}
 	if len(jobs) == 0 {
-		c.Ui.Error(fmt.Sprintf("No job(s) with prefix or id %q found", jobID))
+		c.errorf("No job(s) with prefix or id %q found", jobID)
 		return 1
 	}
 	if len(jobs) > 1 && strings.TrimSpace(jobID) != jobs[0].ID {
+		if c.structuredOutput() {
+			return c.outputData(jobs)
+		}
 		c.Ui.Output(fmt.Sprintf("Prefix matched multiple jobs\n\n%s", createStatusListOutput(jobs)))
 		return 0
 	}
 	// Prefix lookup matched a single job
 	job, _, err := client.Jobs().Info(jobs[0].ID, nil)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+		c.errorf("Error querying job: %s", err)
 		return 1
 	}
+	if c.structuredOutput() {
+		return c.outputJobStatus(client, job, short)
+	}
 
 	// Format the job info
 	basic := []string{
@@ -152,6 +186,33 @@ func (c *StatusCommand) Run(args []string) int {
 	return 0
 }
 
+// jobStatus is the status of a job in the json and yaml outputs.
+type jobStatus struct {
+	Job         *api.Job
+	Allocations []*api.AllocationListStub `json:",omitempty"`
+	Evaluations []*api.Evaluation         `json:",omitempty"`
+}
+
+// outputJobStatus outputs the job in json or yaml, with its allocations and
+// evaluations unless short.
+func (c *StatusCommand) outputJobStatus(client *api.Client, job *api.Job, short bool) int {
+	status := &jobStatus{Job: job}
+	if !short {
+		var err error
+		status.Allocations, _, err = client.Jobs().Allocations(*job.ID, c.allAllocs, nil)
+		if err != nil {
+			c.errorf("Error querying job allocations: %s", err)
+			return 1
+		}
+		status.Evaluations, _, err = client.Jobs().Evaluations(*job.ID, nil)
+		if err != nil {
+			c.errorf("Error querying job evaluations: %s", err)
+			return 1
+		}
+	}
+	return c.outputData(status)
+}
+
 // outputJobInfo prints information about the passed non-periodic job. If a
 // request fails, an error is returned.
 func (c *StatusCommand) outputJobInfo(client *api.Client, job *api.Job) error {
@@ -304,10 +365,11 @@ func sortedTaskFromMetrics(groups map[string]*api.AllocationMetric) []string {
 // list general information about a list of jobs
 func createStatusListOutput(jobs []*api.JobListStub) string {
 	out := make([]string, len(jobs)+1)
-	out[0] = "ID|Type|Status"
+	out[0] = "ID|Namespace|Type|Status"
 	for i, job := range jobs {
-		out[i+1] = fmt.Sprintf("%s|%s|%s",
+		out[i+1] = fmt.Sprintf("%s|%s|%s|%s",
 			job.ID,
+			job.Namespace,
 			job.Type,
 			job.Status)
 	}
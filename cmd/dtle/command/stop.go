@@ -9,6 +9,8 @@ package command
 import (
 	"fmt"
 	"strings"
+
+	"github.com/posener/complete"
 )
 
 type StopCommand struct {
@@ -35,7 +37,8 @@ Stop Options:
     Return immediately instead of entering monitor mode. After the
     deregister command is submitted, a new evaluation ID is printed to the
     screen, which can be used to examine the evaluation using the eval-status
-    command.
+    command. Implied by -output=json and -output=yaml, which output the
+    evaluation ID as EvalID.
 
   -yes
     Automatic yes to prompts.
@@ -50,6 +53,19 @@ func (c *StopCommand) Synopsis() string {
 	return "Stop a running job"
 }
 
+func (c *StopCommand) AutocompleteFlags() complete.Flags {
+	return mergeAutocompleteFlags(c.Meta.AutocompleteFlags(FlagSetClient),
+		complete.Flags{
+			"-detach":  complete.PredictNothing,
+			"-verbose": complete.PredictNothing,
+			"-yes":     complete.PredictNothing,
+		})
+}
+
+func (c *StopCommand) AutocompleteArgs() complete.Predictor {
+	return c.predictJobs()
+}
+
 func (c *StopCommand) Run(args []string) int {
 	var detach, verbose, autoYes bool
 
@@ -76,21 +92,24 @@ func (c *StopCommand) Run(args []string) int {
 	// Get the HTTP client
 	client, err := c.Meta.Client()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		c.errorf("Error initializing client: %s", err)
 		return 1
 	}
 
 	// Check if the job exists
 	jobs, _, err := client.Jobs().PrefixList(jobID)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error deregistering job: %s", err))
+		c.errorf("Error deregistering job: %s", err)
 		return 1
 	}
 	if len(jobs) == 0 {
-		c.Ui.Error(fmt.Sprintf("No job(s) with prefix or id %q found", jobID))
+		c.errorf("No job(s) with prefix or id %q found", jobID)
 		return 1
 	}
 	if len(jobs) > 1 && strings.TrimSpace(jobID) != jobs[0].ID {
+		if c.structuredOutput() {
+			return c.outputData(jobs)
+		}
 		out := make([]string, len(jobs)+1)
 		out[0] = "ID|Type|Status"
 		for i, job := range jobs {
@@ -105,7 +124,7 @@ func (c *StopCommand) Run(args []string) int {
 	// Prefix lookup matched a single job
 	job, _, err := client.Jobs().Info(jobs[0].ID, nil)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error deregistering job: %s", err))
+		c.errorf("Error deregistering job: %s", err)
 		return 1
 	}
 
@@ -114,7 +133,7 @@ func (c *StopCommand) Run(args []string) int {
 		question := fmt.Sprintf("Are you sure you want to stop job %q? [y/N]", *job.ID)
 		answer, err := c.Ui.Ask(question)
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Failed to parse answer: %v", err))
+			c.errorf("Failed to parse answer: %v", err)
 			return 1
 		}
 
@@ -135,7 +154,7 @@ func (c *StopCommand) Run(args []string) int {
 	// Invoke the stop
 	evalID, _, err := client.Jobs().Deregister(*job.ID, nil)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error deregistering job: %s", err))
+		c.errorf("Error deregistering job: %s", err)
 		return 1
 	}
 
@@ -144,6 +163,10 @@ func (c *StopCommand) Run(args []string) int {
 		return 0
 	}
 
+	if c.structuredOutput() {
+		return c.outputData(map[string]string{"EvalID": evalID})
+	}
+
 	if detach {
 		c.Ui.Output(evalID)
 		return 0
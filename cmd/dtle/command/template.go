@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+const (
+	// A template variable `name` could be given by environment variable DTLE_VAR_name.
+	envVarPrefix = "DTLE_VAR_"
+)
+
+// Matches `${name}`, and the escaped form `$${name}`.
+var templateVarRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// VarLookup returns the value of a template variable, and whether it is found.
+type VarLookup func(name string) (string, bool, error)
+
+// InterpolateVars replaces each `${name}` in src with the value from the first lookup
+// which has it. `$${name}` is kept as a literal `${name}`.
+func InterpolateVars(src string, lookups ...VarLookup) (string, error) {
+	var lookupErr error
+	missing := make(map[string]struct{})
+	result := templateVarRegexp.ReplaceAllStringFunc(src, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		name := m[2 : len(m)-1]
+		for _, lookup := range lookups {
+			value, ok, err := lookup(name)
+			if err != nil {
+				lookupErr = err
+				return m
+			}
+			if ok {
+				return value
+			}
+		}
+		missing[name] = struct{}{}
+		return m
+	})
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("undefined template variables: %s", strings.Join(names, ", "))
+	}
+	return result, nil
+}
+
+// FlagVarLookup looks up variables given as `name=value`, e.g. by `-var`.
+func FlagVarLookup(vars []string) (VarLookup, error) {
+	m := make(map[string]string, len(vars))
+	for _, v := range vars {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("bad variable %q, should be name=value", v)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return func(name string) (string, bool, error) {
+		value, ok := m[name]
+		return value, ok, nil
+	}, nil
+}
+
+// EnvVarLookup looks up variable `name` from environment variable DTLE_VAR_name.
+func EnvVarLookup(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(envVarPrefix + name)
+	return value, ok, nil
+}
+
+// ConsulVarLookup looks up variable `name` from consul KV `<prefix>/name`.
+// The consul agent is configured by the standard CONSUL_HTTP_* environment variables.
+func ConsulVarLookup(prefix string) (VarLookup, error) {
+	client, err := consul.NewClient(consul.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(name string) (string, bool, error) {
+		pair, _, err := client.KV().Get(fmt.Sprintf("%s/%s", prefix, name), nil)
+		if err != nil {
+			return "", false, fmt.Errorf("error reading template variable %v from consul: %v", name, err)
+		}
+		if pair == nil {
+			return "", false, nil
+		}
+		return string(pair.Value), true, nil
+	}, nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInterpolateVars(t *testing.T) {
+	flagLookup, err := FlagVarLookup([]string{"source_host=10.0.0.1", "db_list=a,b", "port=3306"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(envVarPrefix+"port", "3307")
+	os.Setenv(envVarPrefix+"user", "dtle")
+	defer os.Unsetenv(envVarPrefix + "port")
+	defer os.Unsetenv(envVarPrefix + "user")
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{"flag", `Host = "${source_host}"`, `Host = "10.0.0.1"`, false},
+		{"flag before env", `Port = ${port}`, `Port = 3306`, false},
+		{"env", `User = "${user}" DBs = "${db_list}"`, `User = "dtle" DBs = "a,b"`, false},
+		{"escaped", `Where = "$${source_host}"`, `Where = "${source_host}"`, false},
+		{"no var", `Where = "$x > 1"`, `Where = "$x > 1"`, false},
+		{"undefined", `Password = "${password}"`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InterpolateVars(tt.src, flagLookup, EnvVarLookup)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InterpolateVars() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("InterpolateVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagVarLookup(t *testing.T) {
+	if _, err := FlagVarLookup([]string{"novalue"}); err == nil {
+		t.Errorf("FlagVarLookup() should fail without '='")
+	}
+	lookup, err := FlagVarLookup([]string{"a=b=c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, _ := lookup("a"); !ok || v != "b=c" {
+		t.Errorf("lookup(a) = %v, %v", v, ok)
+	}
+}
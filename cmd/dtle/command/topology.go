@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+)
+
+type TopologyCommand struct {
+	Meta
+}
+
+func (c *TopologyCommand) Help() string {
+	helpText := `
+Usage: dtle topology [options] <schema.table>
+
+  Display the jobs replicating the table: their source and target, the alloc
+  of the Dest task, how many seconds the target was behind the source when the
+  last transaction was applied, and when.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TopologyCommand) Synopsis() string {
+	return "Display the jobs replicating a table"
+}
+
+func (c *TopologyCommand) AutocompleteFlags() complete.Flags {
+	return c.Meta.AutocompleteFlags(FlagSetClient)
+}
+
+func (c *TopologyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *TopologyCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("topology", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.errorf("Error initializing client: %s", err)
+		return 1
+	}
+
+	topology, _, err := client.Topology().Table(args[0], nil)
+	if err != nil {
+		c.errorf("Error querying topology: %s", err)
+		return 1
+	}
+	if c.structuredOutput() {
+		return c.outputData(topology)
+	}
+
+	out := make([]string, len(topology.Jobs)+1)
+	out[0] = "Job ID|Name|Status|Source|Target|Alloc ID|Lag|Last Apply"
+	for i, job := range topology.Jobs {
+		target := job.Target
+		if target == "" {
+			target = job.TargetDriver
+		}
+		lag, lastApply := "-", "-"
+		if job.StatsError != "" {
+			lag = "unknown"
+		} else if job.LastApplyTime != 0 {
+			lag = (time.Duration(job.LagSeconds) * time.Second).String()
+			lastApply = formatUnixNanoTime(job.LastApplyTime)
+		}
+		out[i+1] = fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%s",
+			job.JobID,
+			job.JobName,
+			job.Status,
+			job.Source,
+			target,
+			job.AllocID,
+			lag,
+			lastApply)
+	}
+	c.Ui.Output(formatList(out))
+	for _, job := range topology.Jobs {
+		if job.StatsError != "" {
+			c.Ui.Warn(fmt.Sprintf("No lag of job %s: %s", job.JobID, job.StatsError))
+		}
+	}
+	return 0
+}
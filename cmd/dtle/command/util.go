@@ -56,6 +56,20 @@ func formatUnixNanoTime(nano int64) string {
 	return formatTime(t)
 }
 
+// formatBytes formats a size in the largest binary unit it reaches, e.g. 1.5GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
 // formatTimeDifference takes two times and determines their duration difference
 // truncating to a passed unit.
 // E.g. formatTimeDifference(first=1m22s33ms, second=1m28s55ms, time.Second) -> 6s
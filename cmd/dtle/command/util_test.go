@@ -258,3 +258,21 @@ func TestLineLimitReader_Read(t *testing.T) {
 		})
 	}
 }
+
+func Test_formatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KiB"},
+		{10 * 1024 * 1024, "10.0MiB"},
+		{3 * 1024 * 1024 * 1024 * 1024, "3.0TiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
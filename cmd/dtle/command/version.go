@@ -8,9 +8,11 @@ package command
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 
 	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
 )
 
 // VersionCommand is a Command implementation prints the version.
@@ -25,7 +27,46 @@ func (c *VersionCommand) Help() string {
 	return ""
 }
 
-func (c *VersionCommand) Run(_ []string) int {
+func (c *VersionCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-output": complete.PredictSet(OutputTable, OutputJSON, OutputYAML),
+	}
+}
+
+func (c *VersionCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *VersionCommand) Run(args []string) int {
+	output := outputFormat(OutputTable)
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	flags.Usage = func() {}
+	flags.Var(&output, "output", "")
+	// "-v" and "--version" are passed on by the shortcut of the command.
+	var versionArgs []string
+	for _, arg := range args {
+		if arg != "-v" && arg != "--version" {
+			versionArgs = append(versionArgs, arg)
+		}
+	}
+	if err := flags.Parse(versionArgs); err != nil {
+		return 1
+	}
+
+	if output == OutputJSON || output == OutputYAML {
+		out, err := formatData(string(output), struct {
+			Version string
+			Branch  string
+			Commit  string
+		}{c.Version, c.Branch, c.Commit})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error formatting output: %s", err))
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
 	var versionString bytes.Buffer
 
 	fmt.Fprintf(&versionString, "Dtle %s (git: %s %s)", c.Version, c.Branch, c.Commit)
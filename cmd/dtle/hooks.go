@@ -0,0 +1,13 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package main
+
+// The packages of the hooks compiled in dtle, which register their hooks with
+// hooks.MustRegister in their init. See github.com/actiontech/dtle/plugins/hooks.
+import (
+// _ "example.com/mycompany/dtle-cache-hook"
+)
@@ -48,8 +48,11 @@ func realMain() int {
 	}
 
 	c := &cli.CLI{
-		Args:     args,
-		HelpFunc: cli.BasicHelpFunc("Dtle"),
+		Name:           "dtle",
+		Args:           args,
+		HelpFunc:       cli.BasicHelpFunc("Dtle"),
+		HiddenCommands: []string{"plugin"},
+		Autocomplete:   true,
 	}
 
 	meta := command.Meta{}
@@ -80,6 +83,11 @@ func realMain() int {
 				Meta: meta,
 			}, nil
 		},*/
+		"debug": func() (cli.Command, error) {
+			return &command.DebugCommand{
+				Meta: meta,
+			}, nil
+		},
 		"node-status": func() (cli.Command, error) {
 			return &command.NodeStatusCommand{
 				Meta: meta,
@@ -110,11 +118,81 @@ func realMain() int {
 				Meta: meta,
 			}, nil
 		},*/
+		"operator snapshot save": func() (cli.Command, error) {
+			return &command.OperatorSnapshotSaveCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator snapshot restore": func() (cli.Command, error) {
+			return &command.OperatorSnapshotRestoreCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator ca roots": func() (cli.Command, error) {
+			return &command.OperatorCARootsCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator ca rotate": func() (cli.Command, error) {
+			return &command.OperatorCARotateCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator debug": func() (cli.Command, error) {
+			return &command.OperatorDebugCommand{
+				Meta: meta,
+			}, nil
+		},
+		"plugin": func() (cli.Command, error) {
+			return &command.PluginCommand{
+				Meta: meta,
+			}, nil
+		},
 		"job-status": func() (cli.Command, error) {
 			return &command.StatusCommand{
 				Meta: meta,
 			}, nil
 		},
+		"job-inspect": func() (cli.Command, error) {
+			return &command.JobInspectCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job-export": func() (cli.Command, error) {
+			return &command.JobExportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job-import": func() (cli.Command, error) {
+			return &command.JobImportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job-clone": func() (cli.Command, error) {
+			return &command.JobCloneCommand{
+				Meta: meta,
+			}, nil
+		},
+		"audit": func() (cli.Command, error) {
+			return &command.AuditCommand{
+				Meta: meta,
+			}, nil
+		},
+		"dump-verify": func() (cli.Command, error) {
+			return &command.DumpVerifyCommand{
+				Meta: meta,
+			}, nil
+		},
+		"replay": func() (cli.Command, error) {
+			return &command.ReplayCommand{
+				Meta: meta,
+			}, nil
+		},
+		"topology": func() (cli.Command, error) {
+			return &command.TopologyCommand{
+				Meta: meta,
+			}, nil
+		},
 		"version": func() (cli.Command, error) {
 			return &command.VersionCommand{
 				Version: Version,
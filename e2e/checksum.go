@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package e2e
+
+import (
+	"crypto/sha256"
+	gosql "database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// TableChecksum returns the rows of the table and a checksum of their values
+// in the order of the primary key id. Unlike CHECKSUM TABLE, it does not depend
+// on the version or the storage format of the server.
+func TableChecksum(db *gosql.DB, schema, table string) (int64, string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM `%s`.`%s` ORDER BY id", schema, table))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, "", err
+	}
+
+	h := sha256.New()
+	values := make([]gosql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var n int64
+	var length [8]byte
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, "", err
+		}
+		for _, v := range values {
+			if v == nil {
+				// NULL, unlike any length prefixed value.
+				h.Write([]byte{0xff})
+				continue
+			}
+			binary.BigEndian.PutUint64(length[:], uint64(len(v)))
+			h.Write(length[:])
+			h.Write(v)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WaitForChecksums waits until the tables of the schema have the same checksums
+// on the target as on the source, or returns the first table differing after
+// the timeout.
+func WaitForChecksums(src, dest *MySQL, schema string, tables []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := compareChecksums(src, dest, schema, tables)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func compareChecksums(src, dest *MySQL, schema string, tables []string) error {
+	for _, table := range tables {
+		srcRows, srcSum, err := TableChecksum(src.DB, schema, table)
+		if err != nil {
+			return fmt.Errorf("source %v.%v: %v", schema, table, err)
+		}
+		destRows, destSum, err := TableChecksum(dest.DB, schema, table)
+		if err != nil {
+			return fmt.Errorf("target %v.%v: %v", schema, table, err)
+		}
+		if srcRows != destRows || srcSum != destSum {
+			return fmt.Errorf("%v.%v differs: %v rows %v on the source, %v rows %v on the target",
+				schema, table, srcRows, srcSum, destRows, destSum)
+		}
+	}
+	return nil
+}
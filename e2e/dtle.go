@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const dtleStartTimeout = time.Minute
+
+// Dtle is a dtle process running both a manager and an agent.
+type Dtle struct {
+	Client  *api.Client
+	cmd     *exec.Cmd
+	dataDir string
+}
+
+// freePort returns a port free on 127.0.0.1.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// StartDtle runs the binary as a single node cluster, with its logs written to
+// logOutput, and waits for the manager to be elected.
+func StartDtle(binary string, logOutput io.Writer) (*Dtle, error) {
+	dataDir, err := ioutil.TempDir("", "dtle-e2e")
+	if err != nil {
+		return nil, err
+	}
+	var ports [4]int
+	for i := range ports {
+		if ports[i], err = freePort(); err != nil {
+			os.RemoveAll(dataDir)
+			return nil, err
+		}
+	}
+	conf := fmt.Sprintf(`data_dir = %q
+bind_addr = "127.0.0.1"
+ports {
+  http = %d
+  rpc  = %d
+  serf = %d
+  nats = %d
+}
+manager {
+  enabled          = true
+  bootstrap_expect = 1
+}
+agent {
+  enabled  = true
+  managers = ["127.0.0.1:%d"]
+}
+`, dataDir, ports[0], ports[1], ports[2], ports[3], ports[1])
+	confFile := filepath.Join(dataDir, "dtle.conf")
+	if err := ioutil.WriteFile(confFile, []byte(conf), 0600); err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	d := &Dtle{dataDir: dataDir}
+	d.cmd = exec.Command(binary, "server", "-config", confFile)
+	d.cmd.Stdout = logOutput
+	d.cmd.Stderr = logOutput
+	if err := d.cmd.Start(); err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	config := api.DefaultConfig()
+	config.Address = fmt.Sprintf("http://127.0.0.1:%d", ports[0])
+	if d.Client, err = api.NewClient(config); err != nil {
+		d.Stop()
+		return nil, err
+	}
+	deadline := time.Now().Add(dtleStartTimeout)
+	for {
+		leader, err := d.Client.Status().Leader()
+		if err == nil && leader != "" {
+			return d, nil
+		}
+		if time.Now().After(deadline) {
+			d.Stop()
+			return nil, fmt.Errorf("no manager elected after %v: %v", dtleStartTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// RunJob registers a job replicating the schema from src to dest, and returns
+// its ID.
+func (d *Dtle) RunJob(name string, src, dest *MySQL, schema string) (string, error) {
+	jobID := models.GenerateUUID()
+	job := &api.Job{
+		ID:   internal.StringToPtr(jobID),
+		Name: internal.StringToPtr(name),
+		Tasks: []*api.Task{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ConnectionConfig": src.ConnectionConfig(),
+				"ReplicateDoDb":    []map[string]interface{}{{"TableSchema": schema}},
+			},
+		}, {
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ConnectionConfig": dest.ConnectionConfig(),
+			},
+		}},
+	}
+	if _, _, err := d.Client.Jobs().Register(job, nil); err != nil {
+		return "", err
+	}
+	return jobID, nil
+}
+
+// Stop stops the process and removes its data.
+func (d *Dtle) Stop() {
+	if d.cmd.Process != nil {
+		d.cmd.Process.Kill()
+		d.cmd.Wait()
+	}
+	os.RemoveAll(d.dataDir)
+}
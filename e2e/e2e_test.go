@@ -0,0 +1,143 @@
+//go:build e2e
+// +build e2e
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	defaultMatrix     = "mysql:5.6>mysql:5.6,mysql:5.7>mysql:5.7,mysql:8.0>mysql:8.0,mysql:5.7>mariadb:10.3"
+	defaultStatements = 2000
+	e2eSchema         = "dtle_e2e"
+	e2eTables         = 3
+	checksumTimeout   = 5 * time.Minute
+)
+
+var dtleBinary string
+
+func TestMain(m *testing.M) {
+	dtleBinary = os.Getenv("DTLE_E2E_BINARY")
+	if dtleBinary == "" {
+		dir, err := ioutil.TempDir("", "dtle-e2e-bin")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dtleBinary = filepath.Join(dir, "dtle")
+		cmd := exec.Command("go", "build", "-o", dtleBinary, "../cmd/dtle")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "building dtle: %v\n", err)
+			os.RemoveAll(dir)
+			os.Exit(1)
+		}
+		code := m.Run()
+		os.RemoveAll(dir)
+		os.Exit(code)
+	}
+	os.Exit(m.Run())
+}
+
+func envInt(t *testing.T, name string, def int64) int64 {
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatalf("bad %v %q: %v", name, s, err)
+	}
+	return n
+}
+
+// TestReplication replicates a random workload for each source>target pair of
+// the matrix, and compares the checksums of the tables.
+func TestReplication(t *testing.T) {
+	matrix := os.Getenv("DTLE_E2E_MATRIX")
+	if matrix == "" {
+		matrix = defaultMatrix
+	}
+	seed := envInt(t, "DTLE_E2E_SEED", time.Now().UnixNano())
+	statements := int(envInt(t, "DTLE_E2E_STATEMENTS", defaultStatements))
+	t.Logf("seed %v, %v statements", seed, statements)
+
+	logFile, err := ioutil.TempFile("", "dtle-e2e-*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	t.Logf("dtle logs in %v", logFile.Name())
+	d, err := StartDtle(dtleBinary, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	for i, pair := range strings.Split(matrix, ",") {
+		images := strings.Split(strings.TrimSpace(pair), ">")
+		if len(images) != 2 {
+			t.Fatalf("bad pair %q of DTLE_E2E_MATRIX, should be source>target", pair)
+		}
+		i, src, dest := i, images[0], images[1]
+		t.Run(src+">"+dest, func(t *testing.T) {
+			if IsMariaDB(src) {
+				t.Skip("MariaDB is not supported as a source, its gtid differs from MySQL")
+			}
+			testReplication(t, d, i, src, dest, seed, statements)
+		})
+	}
+}
+
+func testReplication(t *testing.T, d *Dtle, i int, srcImage, destImage string, seed int64, statements int) {
+	prefix := fmt.Sprintf("dtle-e2e-%d-%d", os.Getpid(), i)
+	src, err := StartMySQL(srcImage, prefix+"-src", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Stop()
+	dest, err := StartMySQL(destImage, prefix+"-dest", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Stop()
+
+	// Rows before the job are copied by the full copy, the others replicated
+	// from the binlog.
+	w := NewWorkload(e2eSchema, e2eTables, seed)
+	if err := Exec(src.DB, w.CreateStatements()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Run(src.DB, statements/2); err != nil {
+		t.Fatal(err)
+	}
+
+	jobID, err := d.RunJob(prefix, src, dest, e2eSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Client.Jobs().Deregister(jobID, nil)
+
+	if err := w.Run(src.DB, statements-statements/2); err != nil {
+		t.Fatal(err)
+	}
+	if err := WaitForChecksums(src, dest, e2eSchema, w.TableNames(), checksumTimeout); err != nil {
+		t.Fatalf("job %v: %v", jobID, err)
+	}
+}
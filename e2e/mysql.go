@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package e2e is the harness of the end-to-end tests, which replicate between
+// MySQL and MariaDB servers run in docker and compare the checksums of the
+// tables after a random workload. The tests have the build tag e2e:
+//
+//	make e2e
+//
+// See README.md for the matrix and the env vars.
+package e2e
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	// RootPassword is the password of root on the servers started.
+	RootPassword = "dtle-e2e"
+
+	mysqlStartTimeout = 3 * time.Minute
+)
+
+// MySQL is a MySQL or MariaDB server run in a docker container, with the
+// binlog and the gtid enabled.
+type MySQL struct {
+	Image     string
+	Container string
+	Host      string
+	Port      int
+	DB        *gosql.DB
+}
+
+// IsMariaDB returns whether the image is of MariaDB, by its name.
+func IsMariaDB(image string) bool {
+	return strings.Contains(image, "mariadb")
+}
+
+// mysqldArgs returns the options of mysqld for a replication source of the
+// image.
+func mysqldArgs(image string, serverID int) []string {
+	args := []string{
+		"--server-id=" + strconv.Itoa(serverID),
+		"--log-bin=mysql-bin",
+		"--binlog-format=ROW",
+		"--character-set-server=utf8mb4",
+	}
+	if IsMariaDB(image) {
+		return append(args, "--log-slave-updates=1")
+	}
+	args = append(args,
+		"--gtid-mode=ON",
+		"--enforce-gtid-consistency=ON",
+		"--log-slave-updates=1")
+	if strings.Contains(image, ":8") {
+		args = append(args, "--default-authentication-plugin=mysql_native_password")
+	}
+	return args
+}
+
+// StartMySQL runs the image in a container named name, with its port published
+// on 127.0.0.1, and waits for it to accept connections.
+func StartMySQL(image, name string, serverID int) (*MySQL, error) {
+	runArgs := []string{"run", "-d", "--rm", "--name", name,
+		"-e", "MYSQL_ROOT_PASSWORD=" + RootPassword,
+		"-p", "127.0.0.1::3306",
+		image}
+	runArgs = append(runArgs, mysqldArgs(image, serverID)...)
+	if out, err := exec.Command("docker", runArgs...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("docker run %v: %v: %s", image, err, out)
+	}
+	m := &MySQL{Image: image, Container: name, Host: "127.0.0.1"}
+
+	out, err := exec.Command("docker", "port", name, "3306/tcp").Output()
+	if err != nil {
+		m.Stop()
+		return nil, fmt.Errorf("docker port %v: %v", name, err)
+	}
+	// e.g. "127.0.0.1:32768", one line per address.
+	addr := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+	if m.Port, err = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:]); err != nil {
+		m.Stop()
+		return nil, fmt.Errorf("bad port of %v: %q", name, addr)
+	}
+
+	if m.DB, err = gosql.Open("mysql", m.DSN("")); err != nil {
+		m.Stop()
+		return nil, err
+	}
+	deadline := time.Now().Add(mysqlStartTimeout)
+	for {
+		err = m.DB.Ping()
+		if err == nil {
+			return m, nil
+		}
+		if time.Now().After(deadline) {
+			m.Stop()
+			return nil, fmt.Errorf("%v not ready after %v: %v", image, mysqlStartTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// DSN returns the data source name of root on the schema.
+func (m *MySQL) DSN(schema string) string {
+	return fmt.Sprintf("root:%s@tcp(%s:%d)/%s?charset=utf8mb4", RootPassword, m.Host, m.Port, schema)
+}
+
+// ConnectionConfig returns the ConnectionConfig of a task on the server.
+func (m *MySQL) ConnectionConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"Host":     m.Host,
+		"Port":     m.Port,
+		"User":     "root",
+		"Password": RootPassword,
+	}
+}
+
+// Stop removes the container.
+func (m *MySQL) Stop() {
+	if m.DB != nil {
+		m.DB.Close()
+	}
+	exec.Command("docker", "rm", "-f", m.Container).Run()
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package e2e
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Statement is a statement of a workload.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Workload generates random inserts, updates and deletes on the tables of a
+// schema. The same seed generates the same statements.
+type Workload struct {
+	Schema string
+	Tables int
+
+	rand *rand.Rand
+	// The ids of the rows of each table, and the next id to insert.
+	ids    [][]int64
+	nextID []int64
+}
+
+// NewWorkload returns the workload of the tables t1..t<tables> of the schema.
+func NewWorkload(schema string, tables int, seed int64) *Workload {
+	return &Workload{
+		Schema: schema,
+		Tables: tables,
+		rand:   rand.New(rand.NewSource(seed)),
+		ids:    make([][]int64, tables),
+		nextID: make([]int64, tables),
+	}
+}
+
+// TableNames returns the names of the tables.
+func (w *Workload) TableNames() []string {
+	names := make([]string, w.Tables)
+	for i := range names {
+		names[i] = fmt.Sprintf("t%d", i+1)
+	}
+	return names
+}
+
+// CreateStatements returns the statements creating the schema and its tables
+// again.
+func (w *Workload) CreateStatements() []Statement {
+	stmts := []Statement{
+		{Query: fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", w.Schema)},
+		{Query: fmt.Sprintf("CREATE DATABASE `%s` CHARACTER SET utf8mb4", w.Schema)},
+	}
+	for _, table := range w.TableNames() {
+		stmts = append(stmts, Statement{Query: fmt.Sprintf("CREATE TABLE `%s`.`%s` ("+
+			"id BIGINT NOT NULL, "+
+			"i INT, "+
+			"s VARCHAR(64) CHARACTER SET utf8mb4, "+
+			"d DECIMAL(20,6), "+
+			"dt DATETIME(6), "+
+			"b BLOB, "+
+			"PRIMARY KEY (id), KEY i (i)"+
+			") ENGINE=InnoDB", w.Schema, table)})
+	}
+	return stmts
+}
+
+// Next returns a random statement: an insert, or an update or a delete of a
+// random row.
+func (w *Workload) Next() Statement {
+	t := w.rand.Intn(w.Tables)
+	table := fmt.Sprintf("`%s`.`t%d`", w.Schema, t+1)
+	op := w.rand.Intn(10)
+	if len(w.ids[t]) == 0 {
+		op = 0
+	}
+	switch {
+	case op < 5:
+		w.nextID[t]++
+		id := w.nextID[t]
+		w.ids[t] = append(w.ids[t], id)
+		return Statement{
+			Query: fmt.Sprintf("INSERT INTO %s (id, i, s, d, dt, b) VALUES (?, ?, ?, ?, ?, ?)", table),
+			Args:  append([]interface{}{id}, w.values()...),
+		}
+	case op < 8:
+		id := w.ids[t][w.rand.Intn(len(w.ids[t]))]
+		return Statement{
+			Query: fmt.Sprintf("UPDATE %s SET i = ?, s = ?, d = ?, dt = ?, b = ? WHERE id = ?", table),
+			Args:  append(w.values(), id),
+		}
+	default:
+		n := w.rand.Intn(len(w.ids[t]))
+		id := w.ids[t][n]
+		w.ids[t] = append(w.ids[t][:n], w.ids[t][n+1:]...)
+		return Statement{
+			Query: fmt.Sprintf("DELETE FROM %s WHERE id = ?", table),
+			Args:  []interface{}{id},
+		}
+	}
+}
+
+// values returns random values of the columns i, s, d, dt and b, with NULLs,
+// boundaries and multi-byte characters.
+func (w *Workload) values() []interface{} {
+	var i, s, d, dt, b interface{}
+	switch w.rand.Intn(6) {
+	case 0:
+		i = nil
+	case 1:
+		i = math.MaxInt32
+	case 2:
+		i = math.MinInt32
+	default:
+		i = w.rand.Int31() - math.MaxInt32/2
+	}
+	if w.rand.Intn(8) != 0 {
+		s = w.randString(w.rand.Intn(64))
+	}
+	if w.rand.Intn(8) != 0 {
+		d = fmt.Sprintf("%d.%06d", w.rand.Int63n(1e14)-5e13, w.rand.Intn(1e6))
+	}
+	if w.rand.Intn(8) != 0 {
+		t := time.Date(1970+w.rand.Intn(100), time.January, 1, 0, 0, 0, 0, time.UTC).
+			Add(time.Duration(w.rand.Int63n(int64(365 * 24 * time.Hour))))
+		dt = t.Format("2006-01-02 15:04:05.000000")
+	}
+	if w.rand.Intn(8) != 0 {
+		buf := make([]byte, w.rand.Intn(256))
+		w.rand.Read(buf)
+		b = buf
+	}
+	return []interface{}{i, s, d, dt, b}
+}
+
+func (w *Workload) randString(n int) string {
+	chars := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 '\"\\%_中文字符éü😀")
+	rs := make([]rune, n)
+	for i := range rs {
+		rs[i] = chars[w.rand.Intn(len(chars))]
+	}
+	return string(rs)
+}
+
+// Exec executes the statements.
+func Exec(db *gosql.DB, stmts []Statement) error {
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.Query, stmt.Args...); err != nil {
+			return fmt.Errorf("%v: %v", stmt.Query, err)
+		}
+	}
+	return nil
+}
+
+// Run executes n statements of the workload, in transactions of 1 to 5
+// statements.
+func (w *Workload) Run(db *gosql.DB, n int) error {
+	for n > 0 {
+		size := w.rand.Intn(5) + 1
+		if size > n {
+			size = n
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			stmt := w.Next()
+			if _, err := tx.Exec(stmt.Query, stmt.Args...); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("%v: %v", stmt.Query, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
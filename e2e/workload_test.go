@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package e2e
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWorkload_Deterministic(t *testing.T) {
+	w1 := NewWorkload("s", 3, 42)
+	w2 := NewWorkload("s", 3, 42)
+	for i := 0; i < 1000; i++ {
+		s1, s2 := w1.Next(), w2.Next()
+		if !reflect.DeepEqual(s1, s2) {
+			t.Fatalf("statement %v differs: %+v, %+v", i, s1, s2)
+		}
+	}
+}
+
+func TestWorkload_Next(t *testing.T) {
+	w := NewWorkload("s", 2, 1)
+	rows := map[string]map[int64]bool{"`s`.`t1`": {}, "`s`.`t2`": {}}
+	for i := 0; i < 1000; i++ {
+		stmt := w.Next()
+		fields := strings.Fields(stmt.Query)
+		table := fields[2]
+		id, _ := stmt.Args[0].(int64)
+		if fields[0] == "UPDATE" {
+			table, id = fields[1], stmt.Args[len(stmt.Args)-1].(int64)
+		}
+		ids, ok := rows[table]
+		if !ok {
+			t.Fatalf("unexpected table of %v", stmt.Query)
+		}
+		switch fields[0] {
+		case "INSERT":
+			if ids[id] {
+				t.Fatalf("%v inserted again into %v", id, table)
+			}
+			ids[id] = true
+		case "UPDATE":
+			if !ids[id] {
+				t.Fatalf("%v updated but not in %v", id, table)
+			}
+		case "DELETE":
+			if !ids[id] {
+				t.Fatalf("%v deleted but not in %v", id, table)
+			}
+			delete(ids, id)
+		default:
+			t.Fatalf("unexpected statement %v", stmt.Query)
+		}
+	}
+}
+
+func TestWorkload_CreateStatements(t *testing.T) {
+	w := NewWorkload("s", 2, 1)
+	stmts := w.CreateStatements()
+	if len(stmts) != 4 {
+		t.Fatalf("%v statements, want 4", len(stmts))
+	}
+	if !reflect.DeepEqual(w.TableNames(), []string{"t1", "t2"}) {
+		t.Fatalf("unexpected tables %v", w.TableNames())
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package auth authenticates the users of the HTTP API and the web UI with
+// corporate identities, as an alternative to the static ACL tokens.
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNoCredentials is returned by a Provider when the request carries no
+// credentials for it.
+var ErrNoCredentials = errors.New("no credentials")
+
+// CredentialsError is returned by a Provider when the credentials of the request
+// are rejected.
+type CredentialsError struct {
+	Reason string
+}
+
+func (e *CredentialsError) Error() string {
+	return "invalid credentials: " + e.Reason
+}
+
+// Identity is a user authenticated by a Provider.
+type Identity struct {
+	// Provider is the name of the provider, e.g. "ldap".
+	Provider string
+	Name     string
+	// Groups are the groups of the user, which the ACLs are granted to.
+	Groups []string
+}
+
+// Provider authenticates the users by the credentials of the requests.
+type Provider interface {
+	// Name returns the name of the provider, which prefixes the user names.
+	Name() string
+
+	// Authenticate returns the identity of the user of the request. It returns
+	// ErrNoCredentials if the request carries no credentials for the provider,
+	// and a CredentialsError if they are rejected. Other errors are failures of
+	// the provider.
+	Authenticate(req *http.Request) (*Identity, error)
+
+	// Challenge returns the WWW-Authenticate header asking for the credentials.
+	Challenge() string
+}
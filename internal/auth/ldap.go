@@ -0,0 +1,211 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ldap.v2"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const (
+	ldapTimeout  = 10 * time.Second
+	ldapCacheTTL = time.Minute
+)
+
+type ldapCacheEntry struct {
+	identity *Identity
+	expires  time.Time
+}
+
+// LDAPProvider authenticates the users by binding to an LDAP server with the
+// credentials of the HTTP basic auth. The groups of a user are read from an
+// attribute of its entry. Logins are cached for a minute, so a request does not
+// cost a bind each.
+type LDAPProvider struct {
+	config    *config.LDAPConfig
+	tlsConfig *tls.Config
+
+	cacheLock sync.Mutex
+	cache     map[[sha256.Size]byte]*ldapCacheEntry
+}
+
+// NewLDAPProvider returns the LDAP provider of the config.
+func NewLDAPProvider(c *config.LDAPConfig) (*LDAPProvider, error) {
+	if c.Address == "" {
+		return nil, fmt.Errorf("ldap: address is required")
+	}
+	if strings.Count(c.UserDN, "%s") != 1 {
+		return nil, fmt.Errorf("ldap: user_dn should contain one %%s for the user name")
+	}
+	p := &LDAPProvider{
+		config: c,
+		cache:  make(map[[sha256.Size]byte]*ldapCacheEntry),
+	}
+	if c.TLS {
+		host, _, err := net.SplitHostPort(c.Address)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: bad address %q: %v", c.Address, err)
+		}
+		p.tlsConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+		}
+		if c.CAFile != "" {
+			pem, err := ioutil.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("ldap: %v", err)
+			}
+			p.tlsConfig.RootCAs = x509.NewCertPool()
+			if !p.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ldap: no certificate in %v", c.CAFile)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *LDAPProvider) Name() string {
+	return "ldap"
+}
+
+func (p *LDAPProvider) Challenge() string {
+	return `Basic realm="dtle"`
+}
+
+func (p *LDAPProvider) Authenticate(req *http.Request) (*Identity, error) {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	// A bind without a password is an anonymous bind, which succeeds.
+	if username == "" || password == "" {
+		return nil, &CredentialsError{Reason: "empty user name or password"}
+	}
+
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+	now := time.Now()
+	p.cacheLock.Lock()
+	entry := p.cache[key]
+	p.cacheLock.Unlock()
+	if entry != nil && now.Before(entry.expires) {
+		return entry.identity, nil
+	}
+
+	identity, err := p.login(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cacheLock.Lock()
+	for k, e := range p.cache {
+		if !now.Before(e.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = &ldapCacheEntry{identity: identity, expires: now.Add(ldapCacheTTL)}
+	p.cacheLock.Unlock()
+	return identity, nil
+}
+
+// login binds as the user and reads its groups.
+func (p *LDAPProvider) login(username, password string) (*Identity, error) {
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	var conn net.Conn
+	var err error
+	if p.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.config.Address, p.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", p.config.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %v", err)
+	}
+	l := ldap.NewConn(conn, p.tlsConfig != nil)
+	l.Start()
+	l.SetTimeout(ldapTimeout)
+	defer l.Close()
+
+	dn := fmt.Sprintf(p.config.UserDN, escapeDN(username))
+	if err := l.Bind(dn, password); ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return nil, &CredentialsError{Reason: "wrong user name or password"}
+	} else if err != nil {
+		return nil, fmt.Errorf("ldap: bind failed: %v", err)
+	}
+	groupAttribute := p.config.GroupAttribute
+	if groupAttribute == "" {
+		groupAttribute = "memberOf"
+	}
+	result, err := l.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		0, int(ldapTimeout/time.Second), false, "(objectClass=*)", []string{groupAttribute}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search of %v failed: %v", dn, err)
+	}
+
+	identity := &Identity{Provider: p.Name(), Name: username}
+	for _, entry := range result.Entries {
+		for _, attribute := range entry.Attributes {
+			if !strings.EqualFold(attribute.Name, groupAttribute) {
+				continue
+			}
+			for _, value := range attribute.Values {
+				identity.Groups = append(identity.Groups, normalizeDN(value))
+			}
+		}
+	}
+	return identity, nil
+}
+
+// escapeDN escapes the special characters of an attribute value of a DN, RFC 4514.
+func escapeDN(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, c),
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(s)-1):
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// normalizeDN returns the DN with the attribute types in lower case and no
+// spaces around the separators, e.g. "cn=admins,dc=example,dc=com" of
+// "CN=admins, DC=example, DC=com", for the groups to be granted by their DN as
+// written in the configuration. A value which is not a DN is returned as is.
+func normalizeDN(value string) string {
+	dn, err := ldap.ParseDN(value)
+	if err != nil || len(dn.RDNs) == 0 {
+		return value
+	}
+	rdns := make([]string, 0, len(dn.RDNs))
+	for _, rdn := range dn.RDNs {
+		attributes := make([]string, 0, len(rdn.Attributes))
+		for _, attribute := range rdn.Attributes {
+			attributes = append(attributes, strings.ToLower(attribute.Type)+"="+escapeDN(attribute.Value))
+		}
+		rdns = append(rdns, strings.Join(attributes, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
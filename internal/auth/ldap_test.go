@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package auth
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	ber "gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v2"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// fakeLDAPServer accepts the binds of the users of the passwords, and returns
+// the groups of the users in memberOf.
+func fakeLDAPServer(t *testing.T, passwords map[string]string, groups map[string][]string) (string, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var binds int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeLDAP(conn, passwords, groups, &binds)
+		}
+	}()
+	return ln.Addr().String(), &binds
+}
+
+func serveFakeLDAP(conn net.Conn, passwords map[string]string, groups map[string][]string, binds *int32) {
+	defer conn.Close()
+	reply := func(id interface{}, op *ber.Packet) {
+		message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+		message.AppendChild(op)
+		conn.Write(message.Bytes())
+	}
+	result := func(tag ber.Tag, code int64) *ber.Packet {
+		op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+		op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+		op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+		op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+		return op
+	}
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id, op := message.Children[0].Value, message.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			atomic.AddInt32(binds, 1)
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			if p, ok := passwords[dn]; ok && p == password {
+				code = ldap.LDAPResultSuccess
+			}
+			reply(id, result(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			dn := op.Children[0].Value.(string)
+			values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+			for _, group := range groups[dn] {
+				values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, group, ""))
+			}
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "memberOf", ""))
+			attribute.AppendChild(values)
+			attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			attributes.AppendChild(attribute)
+			entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+			entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+			entry.AppendChild(attributes)
+			reply(id, entry)
+			reply(id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func TestLDAPProvider(t *testing.T) {
+	alice := "uid=alice,ou=people,dc=example,dc=com"
+	addr, binds := fakeLDAPServer(t,
+		map[string]string{alice: "secret"},
+		map[string][]string{alice: {"CN=dtle-admins, OU=groups, DC=example, DC=com", "team-a"}})
+	p, err := NewLDAPProvider(&config.LDAPConfig{
+		Address: addr,
+		UserDN:  "uid=%s,ou=people,dc=example,dc=com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/jobs", nil)
+	if _, err := p.Authenticate(req); err != ErrNoCredentials {
+		t.Errorf("Authenticate() without credentials = %v", err)
+	}
+
+	req.SetBasicAuth("alice", "secret")
+	identity, err := p.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Identity{Provider: "ldap", Name: "alice",
+		Groups: []string{"cn=dtle-admins,ou=groups,dc=example,dc=com", "team-a"}}
+	if !reflect.DeepEqual(identity, expect) {
+		t.Errorf("Authenticate() = %+v, expect %+v", identity, expect)
+	}
+	// The login is cached.
+	if _, err := p.Authenticate(req); err != nil || atomic.LoadInt32(binds) != 1 {
+		t.Errorf("Authenticate() again = %v, binds %d", err, atomic.LoadInt32(binds))
+	}
+
+	for _, password := range []string{"wrong", ""} {
+		req.SetBasicAuth("alice", password)
+		if _, err := p.Authenticate(req); err == nil {
+			t.Errorf("Authenticate() with password %q succeeded", password)
+		} else if _, ok := err.(*CredentialsError); !ok {
+			t.Errorf("Authenticate() with password %q = %v", password, err)
+		}
+	}
+}
+
+func TestLDAPDN(t *testing.T) {
+	if s := escapeDN(`a,b=c+d "e" #f `); s != `a\,b\=c\+d \"e\" #f\ ` {
+		t.Errorf("escapeDN() = %s", s)
+	}
+	if s := escapeDN("#admin"); s != `\#admin` {
+		t.Errorf("escapeDN() = %s", s)
+	}
+	cases := map[string]string{
+		"cn=admins,dc=example,dc=com":    "cn=admins,dc=example,dc=com",
+		"CN=admins, DC=example,  DC=com": "cn=admins,dc=example,dc=com",
+		`cn=a\,b+uid=c,dc=example`:       `cn=a\,b+uid=c,dc=example`,
+		"admins":                         "admins",
+	}
+	for dn, expect := range cases {
+		if value := normalizeDN(dn); value != expect {
+			t.Errorf("normalizeDN(%q) = %q, expect %q", dn, value, expect)
+		}
+	}
+}
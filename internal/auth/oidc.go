@@ -0,0 +1,346 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const (
+	// OIDCCookieName is the cookie of the ID token of the web UI login.
+	OIDCCookieName = "udup-oidc-token"
+
+	oidcTimeout = 10 * time.Second
+	// oidcLeeway tolerates the clock skew with the provider.
+	oidcLeeway = time.Minute
+	// oidcKeysRefresh is the min interval of fetching the keys again, for a key
+	// not known yet.
+	oidcKeysRefresh = time.Minute
+)
+
+// oidcDiscovery is the part of the provider metadata used.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcAlgorithms are the signature algorithms of the ID tokens accepted. The HS
+// algorithms and "none" are refused, an ID token being signed by the provider
+// only.
+var oidcAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+}
+
+// oidcClaims are the claims of an ID token used, besides the registered ones.
+type oidcClaims struct {
+	Nonce string `json:"nonce"`
+}
+
+// OIDCProvider authenticates the users by the ID tokens of an OpenID Connect
+// provider, sent as a bearer token or in the cookie of the web UI login. The
+// provider metadata and keys are fetched on the first use, so the agent starts
+// while the provider is down.
+type OIDCProvider struct {
+	config *config.OIDCConfig
+	client *http.Client
+
+	lock        sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]jose.JSONWebKey
+	keysFetched time.Time
+}
+
+// NewOIDCProvider returns the OpenID Connect provider of the config.
+func NewOIDCProvider(c *config.OIDCConfig) (*OIDCProvider, error) {
+	if c.Issuer == "" || c.ClientID == "" {
+		return nil, fmt.Errorf("oidc: issuer and client_id are required")
+	}
+	return &OIDCProvider{
+		config: c,
+		client: &http.Client{Timeout: oidcTimeout},
+	}, nil
+}
+
+func (p *OIDCProvider) Name() string {
+	return "oidc"
+}
+
+func (p *OIDCProvider) Challenge() string {
+	return "Bearer"
+}
+
+func (p *OIDCProvider) Authenticate(req *http.Request) (*Identity, error) {
+	var token string
+	if authorization := req.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	} else if cookie, err := req.Cookie(OIDCCookieName); err == nil {
+		token = cookie.Value
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	return p.Verify(token)
+}
+
+// Verify returns the identity of the ID token, checking its signature, issuer,
+// audience and expiry.
+func (p *OIDCProvider) Verify(token string) (*Identity, error) {
+	identity, _, err := p.verify(token)
+	return identity, err
+}
+
+// verify returns the identity and the nonce of the ID token.
+func (p *OIDCProvider) verify(token string) (*Identity, string, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, "", &CredentialsError{Reason: fmt.Sprintf("malformed token: %v", err)}
+	}
+	if len(parsed.Headers) != 1 || !oidcAlgorithms[parsed.Headers[0].Algorithm] {
+		return nil, "", &CredentialsError{Reason: "unsupported token algorithm"}
+	}
+	key, err := p.key(parsed.Headers[0].KeyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var registered jwt.Claims
+	var extra oidcClaims
+	var claims map[string]interface{}
+	if err := parsed.Claims(key.Key, &registered, &extra, &claims); err != nil {
+		return nil, "", &CredentialsError{Reason: err.Error()}
+	}
+	if strings.TrimSuffix(registered.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, "", &CredentialsError{Reason: fmt.Sprintf("token issued by %q", registered.Issuer)}
+	}
+	if registered.Expiry == nil {
+		return nil, "", &CredentialsError{Reason: "token without expiry"}
+	}
+	expected := jwt.Expected{Audience: jwt.Audience{p.config.ClientID}, Time: time.Now()}
+	if err := registered.ValidateWithLeeway(expected, oidcLeeway); err != nil {
+		return nil, "", &CredentialsError{Reason: err.Error()}
+	}
+
+	identity := &Identity{Provider: p.Name()}
+	usernameClaim := p.config.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	identity.Name, _ = claims[usernameClaim].(string)
+	if identity.Name == "" {
+		identity.Name = registered.Subject
+	}
+	if identity.Name == "" {
+		return nil, "", &CredentialsError{Reason: "token without a user name"}
+	}
+	groupsClaim := p.config.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := claims[groupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if s, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+	return identity, extra.Nonce, nil
+}
+
+// key returns the key of the ID, fetching the keys again for a key not known.
+func (p *OIDCProvider) key(kid string) (jose.JSONWebKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) >= oidcKeysRefresh {
+		if err := p.fetchKeys(); err != nil {
+			return jose.JSONWebKey{}, err
+		}
+	}
+	key, ok := p.keys[kid]
+	if !ok && kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, nil
+		}
+	}
+	if !ok {
+		return jose.JSONWebKey{}, &CredentialsError{Reason: fmt.Sprintf("unknown signing key %q", kid)}
+	}
+	return key, nil
+}
+
+// getDiscovery returns the provider metadata. The lock must be held.
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var discovery oidcDiscovery
+	u := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(u, &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("oidc: provider metadata of issuer %q", discovery.Issuer)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// fetchKeys fetches the JWK set of the provider. The lock must be held.
+func (p *OIDCProvider) fetchKeys() error {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := p.getJSON(discovery.JWKSURI, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]jose.JSONWebKey)
+	for _, raw := range set.Keys {
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(raw); err != nil {
+			// A key of another type does not prevent using the others.
+			continue
+		}
+		if (jwk.Use != "" && jwk.Use != "sig") || !jwk.IsPublic() {
+			continue
+		}
+		keys[jwk.KeyID] = jwk
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+	return nil
+}
+
+func (p *OIDCProvider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return fmt.Errorf("oidc: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %v: %v", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("oidc: GET %v: %v", u, err)
+	}
+	return nil
+}
+
+// AuthCodeURL returns the URL of the provider logging the user of the web UI in,
+// which redirects back to RedirectURL with the code and the state. The nonce
+// is put in the ID token, which Exchange checks.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) (string, error) {
+	if p.config.RedirectURL == "" {
+		return "", fmt.Errorf("oidc: redirect_url is required for the web UI login")
+	}
+	p.lock.Lock()
+	discovery, err := p.getDiscovery()
+	p.lock.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile"}
+	}
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.config.ClientID},
+		"redirect_uri":  {p.config.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange exchanges the code of the web UI login for an ID token, returning the
+// token and its identity. The token must carry the nonce given to AuthCodeURL,
+// so that a token issued for another login is not accepted.
+func (p *OIDCProvider) Exchange(code, nonce string) (string, *Identity, error) {
+	if nonce == "" {
+		return "", nil, &CredentialsError{Reason: "no login nonce"}
+	}
+	p.lock.Lock()
+	discovery, err := p.getDiscovery()
+	p.lock.Unlock()
+	if err != nil {
+		return "", nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.config.RedirectURL},
+	}
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", nil, fmt.Errorf("oidc: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("oidc: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("oidc: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, &CredentialsError{Reason: fmt.Sprintf("code exchange failed: %v %s", resp.Status, body)}
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.IDToken == "" {
+		return "", nil, fmt.Errorf("oidc: no id_token in the token response")
+	}
+	identity, tokenNonce, err := p.verify(token.IDToken)
+	if err != nil {
+		return "", nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return "", nil, &CredentialsError{Reason: "token nonce mismatch"}
+	}
+	return token.IDToken, identity, nil
+}
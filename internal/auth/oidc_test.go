@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, alg, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// fakeOIDCServer serves the provider metadata, the key and a token endpoint
+// exchanging the code "good" for the ID token.
+func fakeOIDCServer(t *testing.T, key *rsa.PrivateKey, idToken *string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "dtle" || secret != "s3cret" || r.FormValue("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": *idToken})
+	})
+	return server
+}
+
+func TestOIDCProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idToken string
+	server := fakeOIDCServer(t, key, &idToken)
+	defer server.Close()
+
+	p, err := NewOIDCProvider(&config.OIDCConfig{
+		Issuer:       server.URL,
+		ClientID:     "dtle",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://dtle/v1/acl/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":                server.URL,
+			"aud":                []string{"dtle", "other"},
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"sub":                "u123",
+			"preferred_username": "alice",
+			"groups":             []string{"dtle-admins"},
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+	valid := signTestJWT(t, key, "RS256", "k1", claims(nil))
+
+	req, _ := http.NewRequest("GET", "/v1/jobs", nil)
+	if _, err := p.Authenticate(req); err != ErrNoCredentials {
+		t.Errorf("Authenticate() without credentials = %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+valid)
+	identity, err := p.Authenticate(req)
+	expect := &Identity{Provider: "oidc", Name: "alice", Groups: []string{"dtle-admins"}}
+	if err != nil || !reflect.DeepEqual(identity, expect) {
+		t.Errorf("Authenticate() = %+v, %v", identity, err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string]string{
+		"expired":         signTestJWT(t, key, "RS256", "k1", claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		"audience":        signTestJWT(t, key, "RS256", "k1", claims(func(c map[string]interface{}) { c["aud"] = "other" })),
+		"issuer":          signTestJWT(t, key, "RS256", "k1", claims(func(c map[string]interface{}) { c["iss"] = "https://evil" })),
+		"signature":       signTestJWT(t, other, "RS256", "k1", claims(nil)),
+		"unknown key":     signTestJWT(t, key, "RS256", "k2", claims(nil)),
+		"algorithm":       strings.Replace(valid, valid[:strings.Index(valid, ".")], base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`)), 1),
+		"malformed":       "a.b",
+		"empty signature": valid[:strings.LastIndex(valid, ".")] + ".",
+	}
+	for name, token := range invalid {
+		if _, err := p.Verify(token); err == nil {
+			t.Errorf("Verify() of the token of bad %v succeeded", name)
+		} else if _, ok := err.(*CredentialsError); !ok {
+			t.Errorf("Verify() of the token of bad %v = %v", name, err)
+		}
+	}
+
+	// The web UI login.
+	u, err := p.AuthCodeURL("st", "n1")
+	if err != nil || !strings.HasPrefix(u, server.URL+"/authorize?") ||
+		!strings.Contains(u, "state=st") || !strings.Contains(u, "nonce=n1") {
+		t.Errorf("AuthCodeURL() = %v, %v", u, err)
+	}
+	idToken = signTestJWT(t, key, "RS256", "k1", claims(func(c map[string]interface{}) { c["nonce"] = "n1" }))
+	token, identity, err := p.Exchange("good", "n1")
+	if err != nil || token != idToken || identity.Name != "alice" {
+		t.Errorf("Exchange() = %v, %+v, %v", token, identity, err)
+	}
+	if _, _, err := p.Exchange("bad", "n1"); err == nil {
+		t.Errorf("Exchange() of a bad code succeeded")
+	}
+	for _, nonce := range []string{"n2", ""} {
+		if _, _, err := p.Exchange("good", nonce); err == nil {
+			t.Errorf("Exchange() with nonce %q succeeded", nonce)
+		} else if _, ok := err.(*CredentialsError); !ok {
+			t.Errorf("Exchange() with nonce %q = %v", nonce, err)
+		}
+	}
+	idToken = valid
+	if _, _, err := p.Exchange("good", "n1"); err == nil {
+		t.Errorf("Exchange() of a token without a nonce succeeded")
+	}
+	cookieReq, _ := http.NewRequest("GET", "/v1/jobs", nil)
+	cookieReq.AddCookie(&http.Cookie{Name: OIDCCookieName, Value: token})
+	if identity, err := p.Authenticate(cookieReq); err != nil || identity.Name != "alice" {
+		t.Errorf("Authenticate() by the cookie = %+v, %v", identity, err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
 )
@@ -32,6 +33,8 @@ type Allocator struct {
 	config  *config.ClientConfig
 	updater AllocStateUpdater
 	logger  *log.Logger
+	// rpc is passed to the tasks to get their connection profiles.
+	rpc config.RPCHandler
 
 	alloc                  *models.Allocation
 	allocClientStatus      string // Explicit status of allocation. Set when there are failures
@@ -388,6 +391,7 @@ func (r *Allocator) appendTaskEvent(state *models.TaskState, event *models.TaskE
 
 // Run is a long running goroutine used to manage an allocation
 func (r *Allocator) Run() {
+	defer crash.HandlePanic()
 	defer close(r.waitCh)
 	go r.dirtySyncState()
 
@@ -417,13 +421,25 @@ func (r *Allocator) Run() {
 		return
 	}
 
+	if err := r.buildAllocDir(t.Type); err != nil {
+		r.taskLock.Unlock()
+		r.logger.Errorf("agent: Failed to build the working dir of alloc '%s': %v", alloc.ID, err)
+		r.setStatus(models.AllocClientStatusFailed, fmt.Sprintf("failed to build the working dir: %v", err))
+		r.handleDestroy()
+		return
+	}
+
 	tr := NewWorker(r.logger, r.config, r.setTaskState, r.Alloc(), t.Copy(), r.workUpdates)
+	tr.rpc = r.rpc
 	r.tasks[t.Type] = tr
 	tr.MarkReceived()
 
 	go tr.Run()
 	r.taskLock.Unlock()
 
+	stopWatchCh := make(chan struct{})
+	go r.watchAllocDir(stopWatchCh)
+
 	// taskDestroyEvent contains an event that caused the destroyment of a task
 	// in the allocation.
 	var taskDestroyEvent *models.TaskEvent
@@ -444,13 +460,20 @@ OUTER:
 				break OUTER
 			}
 
+			// Update the task runners
+			for _, tr := range r.getWorkers() {
+				tr.Update(update)
+			}
+
 		case <-r.destroyCh:
 			taskDestroyEvent = models.NewTaskEvent(models.TaskKilled)
 			break OUTER
 		}
 	}
 	// Kill the task runners
+	close(stopWatchCh)
 	r.destroyWorkers(taskDestroyEvent)
+	r.removeAllocDir()
 
 	// Block until we should destroy the store of the alloc
 	r.handleDestroy()
@@ -485,6 +508,7 @@ func (r *Allocator) handleDestroy() {
 				r.logger.Errorf("agent: Failed to destroy state for alloc '%s': %v",
 					r.alloc.ID, err)
 			}
+			r.removeAllocDir()
 			return
 		case <-r.updateCh:
 			r.logger.Errorf("agent: Dropping update to terminal alloc '%s'", r.alloc.ID)
@@ -563,6 +587,36 @@ func (r *Allocator) shouldUpdate(serverIndex uint64) bool {
 	return r.alloc.AllocModifyIndex < serverIndex
 }
 
+// Drain drains all the tasks of the allocation concurrently.
+func (r *Allocator) Drain(timeout time.Duration) error {
+	workers := r.getWorkers()
+	errCh := make(chan error, len(workers))
+	for _, tr := range workers {
+		go func(tr *Worker) {
+			errCh <- tr.Drain(timeout)
+		}(tr)
+	}
+
+	var mErr multierror.Error
+	for range workers {
+		if err := <-errCh; err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+// Resync copies a table of the task again.
+func (r *Allocator) Resync(task, schema, table string) error {
+	r.taskLock.RLock()
+	tr, ok := r.tasks[task]
+	r.taskLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("allocation %q has no task %q", r.alloc.ID, task)
+	}
+	return tr.Resync(schema, table)
+}
+
 // Destroy is used to indicate that the allocation context should be destroyed
 func (r *Allocator) Destroy() {
 	r.destroyLock.Lock()
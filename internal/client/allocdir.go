@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// allocDirCheckInterval is the interval of checking the size of the working
+// dir of an allocation against the limit.
+const allocDirCheckInterval = 30 * time.Second
+
+// allocDir returns the working dir of the allocation.
+func allocDir(conf *config.ClientConfig, allocID string) string {
+	return filepath.Join(conf.AllocDir, allocID)
+}
+
+// taskDir returns the working dir of the task of the allocation.
+func taskDir(conf *config.ClientConfig, allocID, taskType string) string {
+	return filepath.Join(allocDir(conf, allocID), taskType)
+}
+
+// dirSize returns the total size of the regular files under the dir. Files
+// removed during the walk are ignored.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// buildAllocDir creates the working dir of the task.
+func (r *Allocator) buildAllocDir(taskType string) error {
+	return os.MkdirAll(taskDir(r.config, r.alloc.ID, taskType), 0700)
+}
+
+// removeAllocDir removes the working dir of the allocation with all the files
+// of its tasks.
+func (r *Allocator) removeAllocDir() {
+	if err := os.RemoveAll(allocDir(r.config, r.alloc.ID)); err != nil {
+		r.logger.Errorf("agent: Failed to remove the working dir of alloc '%s': %v", r.alloc.ID, err)
+	}
+}
+
+// watchAllocDir fails the tasks of the allocation if its working dir exceeds
+// AllocDirSizeLimit, until stopCh is closed.
+func (r *Allocator) watchAllocDir(stopCh <-chan struct{}) {
+	limit := r.config.AllocDirSizeLimit
+	if limit <= 0 {
+		return
+	}
+	dir := allocDir(r.config, r.alloc.ID)
+
+	ticker := time.NewTicker(allocDirCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			size, err := dirSize(dir)
+			if err != nil {
+				r.logger.Warnf("agent: Failed to get the size of %v: %v", dir, err)
+				continue
+			}
+			if size > limit {
+				reason := fmt.Sprintf("working dir size %d bytes exceeds the limit %d bytes", size, limit)
+				r.logger.Errorf("agent: Alloc '%s': %s", r.alloc.ID, reason)
+				for _, tr := range r.getWorkers() {
+					tr.Kill("agent", reason, true)
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func Test_dirSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-allocdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := &config.ClientConfig{AllocDir: dir}
+	td := taskDir(conf, "alloc1", "Src")
+	if td != filepath.Join(dir, "alloc1", "Src") {
+		t.Errorf("taskDir() = %v", td)
+	}
+	if err := os.MkdirAll(filepath.Join(td, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "a"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "sub", "b"), make([]byte, 23), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := dirSize(allocDir(conf, "alloc1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 123 {
+		t.Errorf("dirSize() = %v, want 123", size)
+	}
+
+	if size, err := dirSize(allocDir(conf, "missing")); err != nil || size != 0 {
+		t.Errorf("dirSize() of a missing dir = %v, %v", size, err)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package cgroup limits the CPU and the memory of the processes of a task
+// with a cgroup v2, created under the cgroup_parent of the client.
+package cgroup
+
+// cpuPeriod is the period of cpu.max, in microseconds.
+const cpuPeriod = 100000
+
+// Limits are the limits of a cgroup. Zero means no limit.
+type Limits struct {
+	// CPU is the number of cores.
+	CPU float64
+	// MemoryMB is the memory in MB.
+	MemoryMB int
+}
+
+// Cgroup is a cgroup of the processes of a task.
+type Cgroup struct {
+	path string
+}
+
+// Path returns the dir of the cgroup.
+func (c *Cgroup) Path() string {
+	return c.path
+}
+
+// Name returns the name of the cgroup of a task of an allocation.
+func Name(allocID, task string) string {
+	return allocID + "-" + task
+}
@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Create creates the cgroup name under the dir parent, or updates its limits
+// if it exists. The cpu and memory controllers are enabled in parent, which
+// must be in a cgroup v2 hierarchy writable by the agent.
+func Create(parent, name string, limits Limits) (*Cgroup, error) {
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	// Fails if the controllers are already enabled by a parent with no
+	// process, or not available. The limits then fail below.
+	ioutil.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+
+	c := &Cgroup{path: filepath.Join(parent, name)}
+	if err := os.MkdirAll(c.path, 0755); err != nil {
+		return nil, err
+	}
+	cpuMax := "max"
+	if limits.CPU > 0 {
+		cpuMax = strconv.FormatInt(int64(limits.CPU*cpuPeriod), 10)
+	}
+	if err := c.write("cpu.max", fmt.Sprintf("%s %d", cpuMax, cpuPeriod)); err != nil {
+		c.Destroy()
+		return nil, err
+	}
+	memoryMax := "max"
+	if limits.MemoryMB > 0 {
+		memoryMax = strconv.FormatInt(int64(limits.MemoryMB)*1024*1024, 10)
+	}
+	if err := c.write("memory.max", memoryMax); err != nil {
+		c.Destroy()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cgroup) write(file, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(c.path, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("cgroup %v: %v", c.path, err)
+	}
+	return nil
+}
+
+// AddProcess moves the process into the cgroup. The processes it forks
+// afterwards are in the cgroup too.
+func (c *Cgroup) AddProcess(pid int) error {
+	return c.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// Destroy removes the cgroup, which must have no process left.
+func (c *Cgroup) Destroy() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		// A regular dir, e.g. not in a cgroup fs.
+		return os.RemoveAll(c.path)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The cgroup files are written to a regular dir, as the tests may not run
+// in a writable cgroup v2 hierarchy.
+func TestCreate(t *testing.T) {
+	parent, err := ioutil.TempDir("", "dtle-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+
+	tests := []struct {
+		limits         Limits
+		cpuMax, memMax string
+	}{
+		{Limits{CPU: 1.5, MemoryMB: 512}, "150000 100000", "536870912"},
+		{Limits{MemoryMB: 1}, "max 100000", "1048576"},
+		{Limits{CPU: 0.25}, "25000 100000", "max"},
+	}
+	for _, tt := range tests {
+		c, err := Create(parent, Name("alloc1", "Src"), tt.limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Path() != filepath.Join(parent, "alloc1-Src") {
+			t.Errorf("path %v", c.Path())
+		}
+		for file, want := range map[string]string{"cpu.max": tt.cpuMax, "memory.max": tt.memMax} {
+			got, err := ioutil.ReadFile(filepath.Join(c.Path(), file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("%+v: %v = %q, want %q", tt.limits, file, got, want)
+			}
+		}
+	}
+
+	c, err := Create(parent, "alloc2-Dest", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddProcess(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Path()); !os.IsNotExist(err) {
+		t.Errorf("%v not removed: %v", c.Path(), err)
+	}
+}
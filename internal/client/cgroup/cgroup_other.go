@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package cgroup
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Create fails as cgroups exist only on Linux.
+func Create(parent, name string, limits Limits) (*Cgroup, error) {
+	return nil, fmt.Errorf("cgroups are not supported on %v", runtime.GOOS)
+}
+
+func (c *Cgroup) AddProcess(pid int) error {
+	return fmt.Errorf("cgroups are not supported on %v", runtime.GOOS)
+}
+
+func (c *Cgroup) Destroy() error {
+	return nil
+}
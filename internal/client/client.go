@@ -30,10 +30,13 @@ import (
 
 	"github.com/actiontech/dtle/internal"
 	"github.com/actiontech/dtle/internal/client/driver"
+	"github.com/actiontech/dtle/internal/client/stats"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/discover"
 	ulog "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
 	"github.com/actiontech/dtle/internal/server"
+	"github.com/actiontech/dtle/internal/tlsutil"
 )
 
 const (
@@ -55,6 +58,10 @@ const (
 
 	getJobRetryIntv = 5 * time.Second
 
+	// natsHealthTimeout is the timeout to connect to the nats server when
+	// checking its health
+	natsHealthTimeout = 2 * time.Second
+
 	// stateSnapshotIntv is how often the client snapshots state
 	stateSnapshotIntv = 60 * time.Second
 
@@ -74,6 +81,10 @@ const (
 	// allocSyncRetryIntv is the interval on which we retry updating
 	// the status of the allocation
 	allocSyncRetryIntv = 5 * time.Second
+
+	// discoverServersIntv is the min interval of finding the servers of the
+	// cloud auto-join strings and the DNS names again.
+	discoverServersIntv = 30 * time.Second
 )
 
 // ClientStatsReporter exposes all the APIs related to resource usage of a Udup
@@ -99,6 +110,10 @@ type Client struct {
 
 	connPool *server.ConnPool
 
+	// tlsConfigurator holds the certificate of the mutual TLS of the RPC, nil
+	// if TLS is disabled
+	tlsConfigurator *tlsutil.Configurator
+
 	// servers is the (optionally prioritized) list of server servers
 	servers *serverlist
 
@@ -118,6 +133,9 @@ type Client struct {
 	allocs    map[string]*Allocator
 	allocLock sync.RWMutex
 
+	// hostStats collects the resource usage of the host and of the tasks
+	hostStats *stats.HostStatsCollector
+
 	// blockedAllocations are allocations which are blocked because their
 	// chained allocations haven't finished running
 	blockedAllocations map[string]*models.Allocation
@@ -134,6 +152,9 @@ type Client struct {
 
 	stand *stand.StanServer
 
+	// left is set after a graceful leave. Guarded by heartbeatLock.
+	left bool
+
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
@@ -197,6 +218,7 @@ func NewClient(cfg *config.ClientConfig, logger *ulog.Logger) (*Client, error) {
 	if err := c.init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize agent: %v", err)
 	}
+	c.hostStats = stats.NewHostStatsCollector(c.config.AllocDir)
 
 	// Setup the node
 	if err := c.setupNode(); err != nil {
@@ -227,9 +249,18 @@ func NewClient(cfg *config.ClientConfig, logger *ulog.Logger) (*Client, error) {
 	}
 	c.configLock.RUnlock()
 
+	// Get the certificate of the mutual TLS of the RPC
+	if err := c.setupTLS(); err != nil {
+		return nil, fmt.Errorf("TLS setup failed: %v", err)
+	}
+
 	// Register and then start heartbeating to the servers.
 	go c.registerAndHeartbeat()
 
+	// Find the servers of the cloud auto-join strings and the DNS names again
+	// when they could not be reached.
+	go c.discoverServers()
+
 	// Begin periodic snapshotting of state.
 	go c.periodicSnapshot()
 
@@ -268,15 +299,73 @@ func (c *Client) init() error {
 	}
 	c.logger.Printf("agent: Using state directory %v", c.config.StateDir)
 
+	// Ensure the alloc dir exists if we have one
+	if c.config.AllocDir != "" {
+		if err := os.MkdirAll(c.config.AllocDir, 0700); err != nil {
+			return fmt.Errorf("failed creating alloc dir: %s", err)
+		}
+	} else {
+		// Othewise make a temp directory to use.
+		p, err := ioutil.TempDir("", "UdupAlloc")
+		if err != nil {
+			return fmt.Errorf("failed creating temporary directory for the AllocDir: %v", err)
+		}
+
+		p, err = filepath.EvalSymlinks(p)
+		if err != nil {
+			return fmt.Errorf("failed to find temporary directory for the AllocDir: %v", err)
+		}
+
+		c.config.AllocDir = p
+	}
+	c.logger.Printf("agent: Using alloc directory %v", c.config.AllocDir)
+
 	return nil
 }
 
 // Leave is used to prepare the client to leave the cluster
 func (c *Client) Leave() error {
-	// TODO
+	c.heartbeatLock.Lock()
+	defer c.heartbeatLock.Unlock()
+
+	// Stop heartbeating, which would set the node ready again.
+	c.left = true
+
+	node := c.Node()
+	req := models.NodeUpdateStatusRequest{
+		NodeID:       node.ID,
+		Status:       models.NodeStatusDown,
+		WriteRequest: models.WriteRequest{Region: c.Region()},
+	}
+	var resp models.NodeUpdateResponse
+	if err := c.RPC("Node.UpdateStatus", &req, &resp); err != nil {
+		return fmt.Errorf("failed to leave: %v", err)
+	}
+	c.logger.Printf("agent: Left the cluster. State updated to %s", req.Status)
 	return nil
 }
 
+// Drain lets the running tasks finish the work in flight, e.g. the applied
+// transactions, before the agent exits. It waits at most timeout.
+func (c *Client) Drain(timeout time.Duration) error {
+	c.logger.Printf("agent: Draining tasks, grace period %v", timeout)
+	runners := c.getAllocRunners()
+	errCh := make(chan error, len(runners))
+	for _, ar := range runners {
+		go func(ar *Allocator) {
+			errCh <- ar.Drain(timeout)
+		}(ar)
+	}
+
+	var mErr multierror.Error
+	for range runners {
+		if err := <-errCh; err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
 // Datacenter returns the datacenter for the given client
 func (c *Client) Datacenter() string {
 	c.configLock.RLock()
@@ -300,7 +389,9 @@ func (c *Client) Shutdown() error {
 		return nil
 	}
 
-	c.stand.Shutdown()
+	if c.stand != nil {
+		c.stand.Shutdown()
+	}
 	c.shutdown = true
 	close(c.shutdownCh)
 	c.connPool.Shutdown()
@@ -381,6 +472,73 @@ func (c *Client) GetAllocStats(allocID string) (AllocStatsReporter, error) {
 	return ar.StatsReporter(), nil
 }
 
+// LatestClientStats returns the resource usage of the host, and of the tasks
+// of each allocation.
+func (c *Client) LatestClientStats() (*models.ClientStats, error) {
+	hs, err := c.hostStats.Collect()
+	if err != nil {
+		return nil, err
+	}
+	cs := &models.ClientStats{
+		NodeID: c.Node().ID,
+		Host:   hs,
+	}
+
+	runners := c.getAllocRunners()
+	var pids []int
+	for _, ar := range runners {
+		for _, tr := range ar.getWorkers() {
+			if pid := tr.pid(); pid != 0 {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	usages := c.hostStats.ProcessesUsage(pids)
+	for _, ar := range runners {
+		alloc := ar.Alloc()
+		au := &models.AllocResourceUsage{
+			AllocID:      alloc.ID,
+			JobID:        alloc.JobID,
+			ClientStatus: alloc.ClientStatus,
+			Tasks:        make(map[string]*models.TaskResourceUsage),
+		}
+		for _, tr := range ar.getWorkers() {
+			usage := &models.TaskResourceUsage{}
+			if u, ok := usages[tr.pid()]; ok {
+				usage = u
+			}
+			au.Tasks[tr.task.Type] = usage
+		}
+		cs.Allocs = append(cs.Allocs, au)
+	}
+	sort.Slice(cs.Allocs, func(i, j int) bool { return cs.Allocs[i].AllocID < cs.Allocs[j].AllocID })
+	return cs, nil
+}
+
+// AllocStates returns the allocations of the agent with their task states, but
+// without their jobs, e.g. for a crash report.
+func (c *Client) AllocStates() []*models.Allocation {
+	var allocs []*models.Allocation
+	for _, ar := range c.getAllocRunners() {
+		alloc := ar.Alloc()
+		alloc.Job = nil
+		allocs = append(allocs, alloc)
+	}
+	sort.Slice(allocs, func(i, j int) bool { return allocs[i].ID < allocs[j].ID })
+	return allocs
+}
+
+// ResyncAlloc copies a table of a task of the allocation again.
+func (c *Client) ResyncAlloc(allocID, task, schema, table string) error {
+	c.allocLock.RLock()
+	ar, ok := c.allocs[allocID]
+	c.allocLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown allocation ID %q", allocID)
+	}
+	return ar.Resync(task, schema, table)
+}
+
 // GetClientAlloc returns the allocation from the client
 func (c *Client) GetClientAlloc(allocID string) (*models.Allocation, error) {
 	all := c.allAllocs()
@@ -404,19 +562,27 @@ func (c *Client) GetServers() []string {
 // SetServers sets a new list of server servers to connect to. As long as one
 // server is resolvable no error is returned.
 func (c *Client) SetServers(servers []string) error {
+	// The cloud auto-join strings are replaced by the servers they find.
+	servers, err := discover.Resolve(servers, c.logger)
+	if err != nil {
+		return err
+	}
+
 	endpoints := make([]*endpoint, 0, len(servers))
 	var merr multierror.Error
 	for _, s := range servers {
-		addr, err := resolveServer(s)
+		addrs, err := resolveServers(s)
 		if err != nil {
 			c.logger.Debugf("agent: Ignoring server %s due to resolution error: %v", s, err)
 			merr.Errors = append(merr.Errors, err)
 			continue
 		}
 
-		// Valid endpoint, append it without a priority as this API
+		// Valid endpoints, append them without a priority as this API
 		// doesn't support different priorities for different servers
-		endpoints = append(endpoints, &endpoint{name: s, addr: addr})
+		for _, addr := range addrs {
+			endpoints = append(endpoints, &endpoint{name: s, addr: addr})
+		}
 	}
 
 	// Only return errors if no servers are valid
@@ -431,6 +597,47 @@ func (c *Client) SetServers(servers []string) error {
 	return nil
 }
 
+// NatsHealth checks the nats streaming server accepts connections. With an
+// external NATS cluster, it checks a server of the cluster can be connected.
+func (c *Client) NatsHealth() error {
+	if c.config.Nats.External() {
+		nc, err := config.NatsConnect(c.config.NatsAddr, c.config.Nats)
+		if err != nil {
+			return err
+		}
+		nc.Close()
+		return nil
+	}
+	if c.stand == nil {
+		return fmt.Errorf("nats streaming server is not started")
+	}
+	conn, err := net.DialTimeout("tcp", c.config.NatsAddr, natsHealthTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// TaskHealth returns the health of the tasks of the running allocations, keyed
+// by "<job name>/<task type>". A task is unhealthy if its driver is not running.
+func (c *Client) TaskHealth() map[string]error {
+	health := make(map[string]error)
+	for _, ar := range c.getAllocRunners() {
+		alloc := ar.Alloc()
+		if alloc.ClientStatus != models.AllocClientStatusRunning {
+			continue
+		}
+		for _, tr := range ar.getWorkers() {
+			var err error
+			if !tr.IsRunning() {
+				err = fmt.Errorf("task of allocation %v is not running", alloc.ID)
+			}
+			health[fmt.Sprintf("%s/%s", alloc.Job.Name, tr.task.Type)] = err
+		}
+	}
+	return health
+}
+
 // saveState is used to snapshot our state into the data dir
 func (c *Client) saveState() error {
 	var mErr multierror.Error
@@ -521,12 +728,20 @@ func (c *Client) setupNode() error {
 }
 
 func (c *Client) setupNatsServer() error {
-	natsAddr, err := net.ResolveTCPAddr("tcp", c.config.NatsAddr)
+	if c.config.Nats.External() {
+		c.logger.Printf("agent: Using external nats servers %v", c.config.Nats.Servers)
+		return nil
+	}
+	bindAddr := c.config.NatsBindAddr
+	if bindAddr == "" {
+		bindAddr = c.config.NatsAddr
+	}
+	natsAddr, err := net.ResolveTCPAddr("tcp", bindAddr)
 	if err != nil {
-		return fmt.Errorf("Failed to parse Nats address %q: %v", c.config.NatsAddr, err)
+		return fmt.Errorf("Failed to parse Nats address %q: %v", bindAddr, err)
 	}
 	nOpts := gnatsd.Options{
-		Host:       natsAddr.IP.String(),
+		Host:       (&net.IPAddr{IP: natsAddr.IP, Zone: natsAddr.Zone}).String(),
 		Port:       natsAddr.Port,
 		MaxPayload: c.config.MaxPayload,
 		//HTTPPort:   8199,
@@ -553,7 +768,7 @@ func (c *Client) setupNatsServer() error {
 func (c *Client) setupDrivers() error {
 	var avail []string
 	driverCtx := driver.NewDriverContext("", "", c.config, c.config.Node, c.logger)
-	for name := range driver.BuiltinDrivers {
+	for _, name := range driver.Drivers() {
 		_, err := driver.NewDriver(name, driverCtx)
 		if err != nil {
 			return err
@@ -736,6 +951,10 @@ func (c *Client) updateNodeStatus() error {
 	c.heartbeatLock.Lock()
 	defer c.heartbeatLock.Unlock()
 
+	if c.left {
+		return nil
+	}
+
 	node := c.Node()
 	req := models.NodeUpdateStatusRequest{
 		NodeID:       node.ID,
@@ -1425,6 +1644,7 @@ func (c *Client) addAlloc(alloc *models.Allocation) error {
 	c.configLock.RLock()
 	ar := NewAllocator(c.logger, c.configCopy, c.updateAllocStatus, alloc, c.workUpdates)
 	c.configLock.RUnlock()
+	ar.rpc = c
 	go ar.Run()
 
 	// Store the alloc runner.
@@ -1442,6 +1662,45 @@ func (c *Client) triggerDiscovery() {
 	}
 }
 
+// discoverServers finds the servers of the cloud auto-join strings and of the
+// DNS names of the configured servers again on triggerDiscovery, at most once
+// per discoverServersIntv.
+func (c *Client) discoverServers() {
+	c.configLock.RLock()
+	servers := c.configCopy.Servers
+	c.configLock.RUnlock()
+	dynamic := false
+	for _, s := range servers {
+		dynamic = dynamic || discover.IsAutoJoin(s) || isDNSServer(s)
+	}
+	if !dynamic {
+		return
+	}
+
+	for {
+		select {
+		case <-c.triggerDiscoveryCh:
+		case <-c.shutdownCh:
+			return
+		}
+
+		if err := c.SetServers(servers); err != nil {
+			c.logger.Warnf("agent: Discovering the servers failed: %v", err)
+		} else {
+			select {
+			case c.serversDiscoveredCh <- struct{}{}:
+			default:
+			}
+		}
+
+		select {
+		case <-time.After(discoverServersIntv):
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
+
 // emitClientMetrics emits lower volume client metrics
 func (c *Client) emitClientMetrics() {
 	nodeID := c.Node().ID
@@ -1495,20 +1754,90 @@ func (c *Client) allAllocs() map[string]*models.Allocation {
 	return allocs
 }
 
+// defaultClientPort is the default client RPC port
+const defaultClientPort = "8191"
+
 // resolveServer given a sever's address as a string, return it's resolved
 // net.Addr or an error.
 func resolveServer(s string) (net.Addr, error) {
-	const defaultClientPort = "8191" // default client RPC port
-	host, port, err := net.SplitHostPort(s)
+	host, port, err := splitServer(s)
 	if err != nil {
-		if strings.Contains(err.Error(), "missing port") {
-			host = s
-			port = defaultClientPort
-		} else {
+		return nil, err
+	}
+	return net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+}
+
+// resolveServers is like resolveServer, but returns an address of each of the
+// A and AAAA records of a DNS name, e.g. a Kubernetes headless service.
+func resolveServers(s string) ([]net.Addr, error) {
+	host, port, err := splitServer(s)
+	if err != nil {
+		return nil, err
+	}
+	if isIPLiteral(host) {
+		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+		if err != nil {
 			return nil, err
 		}
+		return []net.Addr{addr}, nil
 	}
-	return net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ips)
+	addrs := make([]net.Addr, 0, len(ips))
+	for _, ip := range ips {
+		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(ip, port))
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// splitServer splits a server's address into its host and port, the default
+// one if it has none. An IPv6 literal is given as "[::1]:8191", or "[::1]" or
+// "::1" without a port, and its host is returned without the brackets.
+func splitServer(s string) (host, port string, err error) {
+	host = s
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if isIPLiteral(host) {
+		return host, defaultClientPort, nil
+	}
+	host, port, err = net.SplitHostPort(s)
+	if err != nil {
+		if !strings.Contains(err.Error(), "missing port") {
+			return "", "", err
+		}
+		return s, defaultClientPort, nil
+	}
+	return host, port, nil
+}
+
+// isDNSServer returns whether the server is given by a DNS name, whose
+// addresses could change.
+func isDNSServer(s string) bool {
+	host, _, err := splitServer(s)
+	if err != nil {
+		return false
+	}
+	return !isIPLiteral(host)
+}
+
+// isIPLiteral returns whether host is an IP address, an IPv6 one with a zone or
+// not, e.g. "fe80::1%eth0".
+func isIPLiteral(host string) bool {
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		if strings.ContainsAny(host[i:], "[]:") {
+			return false
+		}
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
 }
 
 // serverlist is a prioritized randomized list of server servers. Users should
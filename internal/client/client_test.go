@@ -3314,3 +3314,53 @@ func Test_endpoint_equal(t *testing.T) {
 		})
 	}
 }
+
+func Test_resolveServers(t *testing.T) {
+	addrs, err := resolveServers("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "127.0.0.1:8191" {
+		t.Errorf("got %v", addrs)
+	}
+
+	addrs, err = resolveServers("localhost:4647")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) == 0 {
+		t.Fatalf("no address of localhost")
+	}
+	for _, addr := range addrs {
+		if !addr.(*net.TCPAddr).IP.IsLoopback() || addr.(*net.TCPAddr).Port != 4647 {
+			t.Errorf("got %v", addr)
+		}
+	}
+
+	if isDNSServer("127.0.0.1:8191") || isDNSServer("[::1]:8191") || !isDNSServer("dtle-manager.default.svc") {
+		t.Errorf("bad isDNSServer")
+	}
+}
+
+func Test_resolveServerIPv6(t *testing.T) {
+	for s, want := range map[string]string{
+		"::1":               "[::1]:8191",
+		"[::1]":             "[::1]:8191",
+		"[::1]:4647":        "[::1]:4647",
+		"fe80::1%lo":        "[fe80::1%lo]:8191",
+		"[fe80::1%lo]:4647": "[fe80::1%lo]:4647",
+		"127.0.0.1:4647":    "127.0.0.1:4647",
+	} {
+		addr, err := resolveServer(s)
+		if err != nil {
+			t.Errorf("%v: %v", s, err)
+			continue
+		}
+		if addr.String() != want {
+			t.Errorf("%v: got %v, want %v", s, addr, want)
+		}
+	}
+	if isDNSServer("::1") || isDNSServer("fe80::1%lo") {
+		t.Errorf("bad isDNSServer of IPv6 literals")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/clickhouse"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+type ClickHouseDriver struct {
+	DriverContext
+}
+
+func NewClickHouseDriver(ctx *DriverContext) Driver {
+	return &ClickHouseDriver{DriverContext: *ctx}
+}
+
+func (cd *ClickHouseDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig clickhouse.ClickHouseConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.Nats = ctx.Nats
+	key, err := trafficKey(ctx, driverConfig.TrafficKeyVaultPath)
+	if err != nil {
+		return nil, err
+	}
+	driverConfig.TrafficKey = key
+
+	switch task.Type {
+	case models.TaskTypeDest:
+		runner := clickhouse.NewClickHouseRunner(ctx.Subject, ctx.Tp, &driverConfig, cd.logger)
+		go runner.Run()
+		return runner, nil
+	case models.TaskTypeSrc:
+		return nil, fmt.Errorf("ClickHouse can only be used on 'Dest'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+// Validate checks the connection to ClickHouse.
+func (cd *ClickHouseDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig clickhouse.ClickHouseConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeDest {
+		return reply, fmt.Errorf("ClickHouse can only be used on 'Dest'")
+	}
+
+	if err := clickhouse.NewClient(&driverConfig).Exec("SELECT 1", nil); err != nil {
+		reply.Connection.Error = err.Error()
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
@@ -0,0 +1,581 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package clickhouse applies the replicated MySQL tables to ClickHouse, for
+// analytics. Each table is a ReplacingMergeTree versioned by the GTID of the
+// change, where an update is a newer row and a delete is a newer row marked
+// with _is_deleted.
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	gonats "github.com/nats-io/go-nats"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	TaskStateComplete int = iota
+	TaskStateRestart
+	TaskStateDead
+)
+
+const (
+	defaultBatchSize   = 10000
+	defaultHTTPTimeout = 5 * time.Minute
+)
+
+type ClickHouseConfig struct {
+	// Addr is the address of the HTTP interface of ClickHouse, e.g. "127.0.0.1:8123".
+	Addr     string
+	User     string
+	Password string
+	// Database is the database of all the tables. Defaults to the schema of the table.
+	Database string
+	// BatchSize is the max rows of an insert. Defaults to 10000.
+	BatchSize int
+	NatsAddr  string
+	Gtid      string
+	// TrafficKeyVaultPath is the key in Vault decrypting the data from the Src
+	// task, which must be the same as that of the Src task.
+	TrafficKeyVaultPath string
+	// Nats is set by the agent.
+	Nats *config.NatsConfig
+	// TrafficKey is set by the agent, from TrafficKeyVaultPath or the nats
+	// encrypt_key.
+	TrafficKey []byte `json:"-"`
+}
+
+// Client executes statements with the HTTP interface of ClickHouse.
+type Client struct {
+	addr       string
+	user       string
+	password   string
+	httpClient *http.Client
+}
+
+func NewClient(cfg *ClickHouseConfig) *Client {
+	addr := cfg.Addr
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		addr:       strings.TrimRight(addr, "/"),
+		user:       cfg.User,
+		password:   cfg.Password,
+		httpClient: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// Exec executes the query, with the data of an insert in body if not nil.
+func (c *Client) Exec(query string, body io.Reader) error {
+	params := url.Values{}
+	if c.user != "" {
+		params.Set("user", c.user)
+		params.Set("password", c.password)
+	}
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+	resp, err := c.httpClient.Post(c.addr+"/?"+params.Encode(), "text/plain", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("clickhouse: %v", strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// tableInfo is a replicated table and its columns as created in ClickHouse.
+type tableInfo struct {
+	table   *config.Table
+	columns string
+}
+
+type ClickHouseRunner struct {
+	logger   *log.Entry
+	subject  string
+	natsConn *gonats.Conn
+	cipher   *mysqlDriver.TrafficCipher
+	waitCh   chan *models.WaitResult
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+
+	cfg    *ClickHouseConfig
+	client *Client
+
+	tables  map[string]*tableInfo
+	batches map[string]*batch
+
+	// The gtid set applied, of all the SIDs, and the number of its
+	// transactions, which versions the rows of the last one. Read from
+	// cfg.Gtid at the first transaction.
+	gtidSet *gomysql.MysqlGTIDSet
+	nTxs    int64
+}
+
+func NewClickHouseRunner(subject, tp string, cfg *ClickHouseConfig, logger *log.Logger) *ClickHouseRunner {
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &ClickHouseRunner{
+		subject:    subject,
+		cfg:        cfg,
+		client:     NewClient(cfg),
+		logger:     entry,
+		waitCh:     make(chan *models.WaitResult, 1),
+		shutdownCh: make(chan struct{}),
+		tables:     make(map[string]*tableInfo),
+		batches:    make(map[string]*batch),
+	}
+}
+
+func (r *ClickHouseRunner) ID() string {
+	id := config.DriverCtx{
+		DriverConfig: &config.MySQLDriverConfig{
+			Gtid:     r.cfg.Gtid,
+			NatsAddr: r.cfg.NatsAddr,
+		},
+	}
+
+	data, err := json.Marshal(id)
+	if err != nil {
+		r.logger.Errorf("clickhouse: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (r *ClickHouseRunner) WaitCh() chan *models.WaitResult {
+	return r.waitCh
+}
+
+func (r *ClickHouseRunner) Shutdown() error {
+	r.shutdownLock.Lock()
+	defer r.shutdownLock.Unlock()
+	if r.shutdown {
+		return nil
+	}
+	if r.natsConn != nil {
+		r.natsConn.Close()
+	}
+	r.shutdown = true
+	close(r.shutdownCh)
+
+	r.logger.Printf("clickhouse: Shutting down")
+	return nil
+}
+
+func (r *ClickHouseRunner) Stats() (*models.TaskStatistics, error) {
+	taskResUsage := &models.TaskStatistics{}
+	return taskResUsage, nil
+}
+
+func (r *ClickHouseRunner) initNatSubClient() (err error) {
+	natsAddr := config.NatsURL(r.cfg.NatsAddr)
+	sc, err := config.NatsConnect(r.cfg.NatsAddr, r.cfg.Nats)
+	if err != nil {
+		r.logger.Errorf("clickhouse: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
+		return err
+	}
+	r.logger.Debugf("clickhouse: Connect nats server %v", natsAddr)
+	r.natsConn = sc
+	return nil
+}
+
+func (r *ClickHouseRunner) Run() {
+	r.logger.Printf("clickhouse: Apply to %v", r.cfg.Addr)
+
+	if err := r.client.Exec("SELECT 1", nil); err != nil {
+		r.onError(TaskStateDead, err)
+		return
+	}
+
+	if err := r.initNatSubClient(); err != nil {
+		r.logger.Errorf("initNatSubClient error: %v", err.Error())
+		r.onError(TaskStateDead, err)
+		return
+	}
+
+	if err := r.initiateStreaming(); err != nil {
+		r.onError(TaskStateDead, err)
+		return
+	}
+}
+
+func (r *ClickHouseRunner) database(table *config.Table) string {
+	if r.cfg.Database != "" {
+		return r.cfg.Database
+	}
+	return table.TableSchema
+}
+
+// getOrSetTable returns the table of the name, creating it in ClickHouse, or
+// adding the new columns, if table is a new structure of it.
+func (r *ClickHouseRunner) getOrSetTable(schemaName string, tableName string, table *config.Table) (*config.Table, error) {
+	key := fmt.Sprintf("%s.%s", schemaName, tableName)
+	info, ok := r.tables[key]
+	if table == nil {
+		if !ok {
+			return nil, fmt.Errorf("DTLE_BUG clickhouse: unknown table structure of %v", key)
+		}
+		return info.table, nil
+	}
+
+	cols := table.OriginalTableColumns.ColumnList()
+	var names []string
+	for i := range cols {
+		names = append(names, cols[i].Name+" "+cols[i].ColumnType)
+	}
+	columns := strings.Join(names, ",")
+	if ok && info.columns == columns {
+		info.table = table
+		return table, nil
+	}
+
+	// the rows of the old structure
+	if err := r.flush(key); err != nil {
+		return nil, err
+	}
+	database := r.database(table)
+	queries := []string{
+		fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteName(database)),
+		createTableQuery(database, table.TableName, cols),
+	}
+	if ok {
+		queries = []string{addColumnsQuery(database, table.TableName, cols)}
+	}
+	for _, query := range queries {
+		r.logger.Debugf("clickhouse: Exec [%s]", query)
+		if err := r.client.Exec(query, nil); err != nil {
+			return nil, err
+		}
+	}
+	r.tables[key] = &tableInfo{table: table, columns: columns}
+	return table, nil
+}
+
+// addRow adds a row to the batch of the table, which is inserted if full.
+func (r *ClickHouseRunner) addRow(table *config.Table, values []interface{}, version uint64, deleted bool) error {
+	key := fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)
+	cols := table.OriginalTableColumns.ColumnList()
+	b, ok := r.batches[key]
+	if !ok {
+		b = newBatch(r.database(table), table.TableName, cols)
+		r.batches[key] = b
+	}
+	b.add(cols, values, version, deleted)
+	if b.nRows >= r.cfg.BatchSize {
+		return r.flush(key)
+	}
+	return nil
+}
+
+// flush inserts the batch of the table.
+func (r *ClickHouseRunner) flush(key string) error {
+	b, ok := r.batches[key]
+	if !ok {
+		return nil
+	}
+	delete(r.batches, key)
+	r.logger.Debugf("clickhouse: insert %v rows into %v", b.nRows, key)
+	return r.client.Exec(b.query(), &b.buf)
+}
+
+// flushAll inserts the batches of all the tables.
+func (r *ClickHouseRunner) flushAll() error {
+	for key := range r.batches {
+		if err := r.flush(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribe is natsConn.Subscribe, with the messages decrypted if the job has
+// a traffic key.
+func (r *ClickHouseRunner) subscribe(subj string, cb gonats.MsgHandler) (*gonats.Subscription, error) {
+	return r.natsConn.Subscribe(subj, r.cipher.Handler(cb, r.logger, func(err error) {
+		r.onError(TaskStateDead, err)
+	}))
+}
+
+func (r *ClickHouseRunner) initiateStreaming() error {
+	var err error
+
+	r.cipher, err = mysqlDriver.NewTrafficCipher(r.cfg.TrafficKey)
+	if err != nil {
+		return err
+	}
+
+	if r.cfg.Gtid == "" {
+		_, err = r.subscribe(fmt.Sprintf("%s_full", r.subject), func(m *gonats.Msg) {
+			dumpData := &mysqlDriver.DumpEntry{}
+			if err := Decode(m.Data, dumpData); err != nil {
+				r.onError(TaskStateDead, err)
+				return
+			}
+			if err := r.applyDumpEntry(dumpData); err != nil {
+				r.onError(TaskStateDead, err)
+				return
+			}
+			if err := r.natsConn.Publish(m.Reply, nil); err != nil {
+				r.onError(TaskStateDead, err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = r.subscribe(fmt.Sprintf("%s_full_complete", r.subject), func(m *gonats.Msg) {
+			// the fields of dumpStatResult of the mysql driver
+			dumpStat := &struct{ Gtid string }{}
+			if err := Decode(m.Data, dumpStat); err != nil {
+				r.onError(TaskStateDead, err)
+				return
+			}
+			r.cfg.Gtid = dumpStat.Gtid
+			if err := r.natsConn.Publish(m.Reply, nil); err != nil {
+				r.onError(TaskStateDead, err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.subscribe(fmt.Sprintf("%s_incr_hete", r.subject), func(m *gonats.Msg) {
+		var binlogEntries binlog.BinlogEntries
+		if err := Decode(m.Data, &binlogEntries); err != nil {
+			r.onError(TaskStateDead, err)
+			return
+		}
+
+		for _, binlogEntry := range binlogEntries.Entries {
+			if err := r.applyBinlogEntry(binlogEntry); err != nil {
+				r.onError(TaskStateDead, err)
+				return
+			}
+		}
+		if err := r.flushAll(); err != nil {
+			r.onError(TaskStateDead, err)
+			return
+		}
+		if r.gtidSet != nil {
+			r.cfg.Gtid = r.gtidSet.String()
+		}
+
+		if err := r.natsConn.Publish(m.Reply, nil); err != nil {
+			r.onError(TaskStateDead, err)
+		}
+		r.logger.Debugf("clickhouse. incr. ack-recv. nEntries: %v", len(binlogEntries.Entries))
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// applyDumpEntry inserts the rows of the full copy, with version 0. The DDL
+// of MySQL is not applied, as the tables are created from their structure.
+func (r *ClickHouseRunner) applyDumpEntry(dumpData *mysqlDriver.DumpEntry) error {
+	if len(dumpData.ValuesX) == 0 {
+		return nil
+	}
+	table, err := r.getOrSetTable(dumpData.TableSchema, dumpData.TableName, dumpData.Table)
+	if err != nil {
+		return err
+	}
+	for _, row := range dumpData.ValuesX {
+		if err := r.addRow(table, derefValues(row), 0, false); err != nil {
+			return err
+		}
+	}
+	return r.flushAll()
+}
+
+// addGtid adds the gtid of the transaction to the gtid set applied, and returns
+// the number of the transactions of the set, which increases with each
+// transaction whatever its SID, or 0 for a resync entry, which has no gtid.
+// It returns false if the transaction is applied already.
+func (r *ClickHouseRunner) addGtid(binlogEntry *binlog.BinlogEntry) (int64, bool, error) {
+	if binlogEntry.Resync {
+		return 0, true, nil
+	}
+	if r.gtidSet == nil {
+		set, err := gomysql.ParseMysqlGTIDSet(r.cfg.Gtid)
+		if err != nil {
+			return 0, false, err
+		}
+		r.gtidSet = set.(*gomysql.MysqlGTIDSet)
+		r.nTxs = gtidSetCount(r.gtidSet)
+	}
+	gtid, err := gomysql.ParseMysqlGTIDSet(binlogEntry.Coordinates.GetGtidForThisTx())
+	if err != nil {
+		return 0, false, err
+	}
+	if r.gtidSet.Contain(gtid) {
+		return 0, false, nil
+	}
+	if err := r.gtidSet.Update(binlogEntry.Coordinates.GetGtidForThisTx()); err != nil {
+		return 0, false, err
+	}
+	r.nTxs++
+	return r.nTxs, true, nil
+}
+
+// gtidSetCount returns the number of the transactions of the gtid set.
+func gtidSetCount(set *gomysql.MysqlGTIDSet) int64 {
+	var n int64
+	for _, uuidSet := range set.Sets {
+		for _, interval := range uuidSet.Intervals {
+			n += interval.Stop - interval.Start
+		}
+	}
+	return n
+}
+
+// applyBinlogEntry adds the rows of the changes in the transaction. An insert
+// or update is a row of the new values, and a delete is a row of the old
+// values marked deleted. If the primary key is updated, the old key is also
+// marked deleted. A transaction applied already is skipped.
+func (r *ClickHouseRunner) applyBinlogEntry(binlogEntry *binlog.BinlogEntry) error {
+	nTx, ok, err := r.addGtid(binlogEntry)
+	if err != nil {
+		return err
+	} else if !ok {
+		r.logger.Debugf("clickhouse: skip the transaction applied %v", binlogEntry.Coordinates.GetGtidForThisTx())
+		return nil
+	}
+	nRow := 0
+	for i := range binlogEntry.Events {
+		dataEvent := &binlogEntry.Events[i]
+		// this must be executed before skipping DDL
+		table, err := r.getOrSetTable(dataEvent.DatabaseName, dataEvent.TableName, dataEvent.Table)
+		if err != nil {
+			return err
+		}
+		if dataEvent.DML == binlog.NotDML {
+			r.logger.Debugf("clickhouse: skip a query: %v", dataEvent.Query)
+			continue
+		}
+
+		cols := table.OriginalTableColumns.ColumnList()
+		var before, after []interface{}
+		if dataEvent.WhereColumnValues != nil {
+			before = derefValues(dataEvent.WhereColumnValues.GetAbstractValues())
+		}
+		if dataEvent.NewColumnValues != nil {
+			after = derefValues(dataEvent.NewColumnValues.GetAbstractValues())
+		}
+
+		switch dataEvent.DML {
+		case binlog.DeleteDML:
+			err = r.addRow(table, before, rowVersion(nTx, nRow), true)
+			nRow++
+		case binlog.UpdateDML:
+			if pkChanged(cols, before, after) {
+				if err := r.addRow(table, before, rowVersion(nTx, nRow), true); err != nil {
+					return err
+				}
+				nRow++
+			}
+			fallthrough
+		case binlog.InsertDML:
+			err = r.addRow(table, after, rowVersion(nTx, nRow), false)
+			nRow++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func derefValues(values []*interface{}) []interface{} {
+	result := make([]interface{}, len(values))
+	for i := range values {
+		result[i] = *values[i]
+	}
+	return result
+}
+
+// pkChanged tells if the primary key is changed by an update.
+func pkChanged(cols []mysql.Column, before, after []interface{}) bool {
+	for i := range cols {
+		if !cols[i].IsPk() {
+			continue
+		}
+		b, a := valueString(&cols[i], before[i]), valueString(&cols[i], after[i])
+		if (b == nil) != (a == nil) || (b != nil && *b != *a) {
+			return true
+		}
+	}
+	return false
+}
+
+// TODO move to one place
+func Decode(data []byte, vPtr interface{}) (err error) {
+	msg, err := snappy.Decode(nil, data)
+	if err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewBuffer(msg)).Decode(vPtr)
+}
+
+func (r *ClickHouseRunner) onError(state int, err error) {
+	if r.shutdown {
+		return
+	}
+	switch state {
+	case TaskStateComplete:
+		r.logger.Printf("clickhouse: Done migrating")
+	case TaskStateRestart:
+		if r.natsConn != nil {
+			if err := r.natsConn.Publish(fmt.Sprintf("%s_restart", r.subject), []byte(r.cfg.Gtid)); err != nil {
+				r.logger.Errorf("clickhouse: Trigger restart: %v", err)
+			}
+		}
+	default:
+		if r.natsConn != nil {
+			if err := r.natsConn.Publish(fmt.Sprintf("%s_error", r.subject), []byte(r.cfg.Gtid)); err != nil {
+				r.logger.Errorf("clickhouse: Trigger shutdown: %v", err)
+			}
+		}
+	}
+
+	r.waitCh <- models.NewWaitResult(state, err)
+	r.Shutdown()
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	natsd "github.com/nats-io/gnatsd/server"
+	gonats "github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestClickHouseRunner_TrafficKey(t *testing.T) {
+	s := natsd.New(&natsd.Options{Host: "127.0.0.1", Port: natsd.RANDOM_PORT, NoLog: true, NoSigs: true})
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not started")
+	}
+	natsAddr := s.Addr().String()
+
+	key := bytes.Repeat([]byte{1}, 32)
+	cfg := &ClickHouseConfig{Addr: "127.0.0.1:8123", NatsAddr: natsAddr, TrafficKey: key}
+	r := NewClickHouseRunner("job1", "", cfg, log.New(os.Stderr, log.InfoLevel))
+	if err := r.initNatSubClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+	if err := r.initiateStreaming(); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := gonats.Connect("nats://" + natsAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	srcCipher, _ := mysqlDriver.NewTrafficCipher(key)
+	gtid := "00000000-0000-0000-0000-000000000001:1-10"
+	data, err := mysqlDriver.Encode(&struct{ Gtid string }{gtid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srcCipher.Seal("job1_full_complete", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Request("job1_full_complete", msg, 5*time.Second); err != nil {
+		t.Fatalf("encrypted message not applied: %v", err)
+	}
+	if r.cfg.Gtid != gtid {
+		t.Fatalf("Gtid = %q, want %q", r.cfg.Gtid, gtid)
+	}
+	if _, err := src.Request("job1_full_complete", msg, 200*time.Millisecond); err != gonats.ErrTimeout {
+		t.Fatalf("replayed message applied: %v", err)
+	}
+
+	if err := src.Publish("job1_incr_hete", data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res := <-r.WaitCh():
+		if res.Err == nil || res.ExitCode != TaskStateDead {
+			t.Fatalf("plaintext message: %+v, want the task dead", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("plaintext message accepted")
+	}
+}
+
+func TestClickHouseRunner_addGtid(t *testing.T) {
+	sid1 := uuid.FromStringOrNil("00000000-0000-0000-0000-000000000001")
+	sid2 := uuid.FromStringOrNil("00000000-0000-0000-0000-000000000002")
+	r := NewClickHouseRunner("job1", "", &ClickHouseConfig{Gtid: sid1.String() + ":1-10"},
+		log.New(os.Stderr, log.InfoLevel))
+	for _, c := range []struct {
+		sid  uuid.UUID
+		gno  int64
+		want int64
+		ok   bool
+	}{
+		{sid1, 11, 11, true},
+		// After a failover: newer, whatever the GNO.
+		{sid2, 1, 12, true},
+		{sid1, 5, 0, false},
+		{sid2, 2, 13, true},
+	} {
+		entry := &binlog.BinlogEntry{Coordinates: base.BinlogCoordinateTx{SID: c.sid, GNO: c.gno}}
+		got, ok, err := r.addGtid(entry)
+		if err != nil || got != c.want || ok != c.ok {
+			t.Errorf("%v:%v: got %v %v %v, want %v %v", c.sid, c.gno, got, ok, err, c.want, c.ok)
+		}
+	}
+	if got, ok, _ := r.addGtid(&binlog.BinlogEntry{Resync: true}); got != 0 || !ok {
+		t.Errorf("resync entry: got %v %v", got, ok)
+	}
+	want := sid1.String() + ":1-11," + sid2.String() + ":1-2"
+	if got := r.gtidSet.String(); got != want {
+		t.Errorf("gtid set %v, want %v", got, want)
+	}
+}
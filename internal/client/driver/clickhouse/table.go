@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+const (
+	// versionColumn is the version of ReplacingMergeTree. The row with the
+	// largest version of a primary key is kept by the merges.
+	versionColumn = "_version"
+	// deletedColumn is 1 for the row of a deleted primary key.
+	deletedColumn = "_is_deleted"
+
+	// rowIndexBits is the bits of the index of a row in a transaction in the
+	// version, which is `nTx<<rowIndexBits | index`, nTx being the number of
+	// the transactions of the gtid set applied, up to the transaction.
+	rowIndexBits = 24
+)
+
+var decimalRegexp = regexp.MustCompile(`^(decimal|numeric)\((\d+),(\d+)\)`)
+
+// rowVersion returns the version of the row of the index in the transaction,
+// the nTx-th applied. Unlike the GNO, nTx increases across the SIDs, e.g. after
+// a failover of the source. The rows of the full copy have version 0, older
+// than any change.
+func rowVersion(nTx int64, index int) uint64 {
+	const maxIndex = 1<<rowIndexBits - 1
+	if index > maxIndex {
+		index = maxIndex
+	}
+	return uint64(nTx)<<rowIndexBits | uint64(index)
+}
+
+// quoteName quotes an identifier of ClickHouse.
+func quoteName(name string) string {
+	return "`" + strings.Replace(strings.Replace(name, `\`, `\\`, -1), "`", "\\`", -1) + "`"
+}
+
+// columnType maps the type of a MySQL column to ClickHouse. A nullable
+// column, other than of the primary key, is Nullable.
+func columnType(col *mysql.Column) string {
+	t := strings.ToLower(col.ColumnType)
+	unsigned := col.IsUnsigned || strings.Contains(t, "unsigned")
+	intType := func(bits int) string {
+		if unsigned {
+			return fmt.Sprintf("UInt%d", bits)
+		}
+		return fmt.Sprintf("Int%d", bits)
+	}
+
+	var ct string
+	switch {
+	case strings.HasPrefix(t, "tinyint"):
+		ct = intType(8)
+	case strings.HasPrefix(t, "smallint"):
+		ct = intType(16)
+	case strings.HasPrefix(t, "mediumint"), strings.HasPrefix(t, "int"):
+		ct = intType(32)
+	case strings.HasPrefix(t, "bigint"):
+		ct = intType(64)
+	case strings.HasPrefix(t, "float"):
+		ct = "Float32"
+	case strings.HasPrefix(t, "double"), strings.HasPrefix(t, "real"):
+		ct = "Float64"
+	case decimalRegexp.MatchString(t):
+		m := decimalRegexp.FindStringSubmatch(t)
+		ct = fmt.Sprintf("Decimal(%s,%s)", m[2], m[3])
+	case strings.HasPrefix(t, "datetime"), strings.HasPrefix(t, "timestamp"):
+		ct = "DateTime"
+	case strings.HasPrefix(t, "date"):
+		ct = "Date"
+	case strings.HasPrefix(t, "year"):
+		ct = "UInt16"
+	case strings.HasPrefix(t, "bit"):
+		ct = "UInt64"
+	default:
+		// char, text, blob, binary, enum, set, json, time, etc.
+		ct = "String"
+	}
+	if col.Nullable && !col.IsPk() {
+		ct = "Nullable(" + ct + ")"
+	}
+	return ct
+}
+
+// createTableQuery returns the statement to create the ClickHouse table of the
+// MySQL table, ordered by the primary key. A table without primary key is
+// kept as a log of the changes, with no row replaced.
+func createTableQuery(database, table string, cols []mysql.Column) string {
+	var defs, keys []string
+	for i := range cols {
+		defs = append(defs, fmt.Sprintf("%s %s", quoteName(cols[i].Name), columnType(&cols[i])))
+		if cols[i].IsPk() {
+			keys = append(keys, quoteName(cols[i].Name))
+		}
+	}
+	defs = append(defs, quoteName(versionColumn)+" UInt64", quoteName(deletedColumn)+" UInt8")
+
+	engine := fmt.Sprintf("ReplacingMergeTree(%s) ORDER BY (%s)", quoteName(versionColumn), strings.Join(keys, ","))
+	if len(keys) == 0 {
+		engine = "MergeTree ORDER BY tuple()"
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s) ENGINE = %s",
+		quoteName(database), quoteName(table), strings.Join(defs, ", "), engine)
+}
+
+// addColumnsQuery returns the statement to add the columns missing in the
+// ClickHouse table, after a column is added to the MySQL table.
+func addColumnsQuery(database, table string, cols []mysql.Column) string {
+	var adds []string
+	for i := range cols {
+		adds = append(adds, fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s %s", quoteName(cols[i].Name), columnType(&cols[i])))
+	}
+	return fmt.Sprintf("ALTER TABLE %s.%s %s", quoteName(database), quoteName(table), strings.Join(adds, ", "))
+}
+
+// parseValues parses the values of a column type like "enum('a','b')".
+func parseValues(columnType string) []string {
+	i := strings.IndexByte(columnType, '(')
+	j := strings.LastIndexByte(columnType, ')')
+	if i < 0 || j < i {
+		return nil
+	}
+	s := columnType[i+1 : j]
+
+	var values []string
+	var cur bytes.Buffer
+	inQuote := false
+	for k := 0; k < len(s); k++ {
+		c := s[k]
+		switch {
+		case c == '\'' && inQuote && k+1 < len(s) && s[k+1] == '\'':
+			cur.WriteByte('\'')
+			k++
+		case c == '\'':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			values = append(values, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(values, cur.String())
+}
+
+// valueString returns the text of a value of the column, or nil for NULL.
+// The values of the full copy are text, while those of the binlog are typed,
+// with the index of an enum and the bitmap of a set.
+func valueString(col *mysql.Column, value interface{}) *string {
+	if value == nil {
+		return nil
+	}
+	t := strings.ToLower(col.ColumnType)
+
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		if strings.HasPrefix(t, "bit") {
+			buf := make([]byte, 8)
+			if len(v) <= 8 {
+				copy(buf[8-len(v):], v)
+			}
+			s = fmt.Sprintf("%d", binary.BigEndian.Uint64(buf))
+		} else {
+			s = string(v)
+		}
+	case int64:
+		switch {
+		case strings.HasPrefix(t, "enum"):
+			values := parseValues(col.ColumnType)
+			if v >= 1 && int(v) <= len(values) {
+				s = values[v-1]
+			}
+		case strings.HasPrefix(t, "set"):
+			var members []string
+			for i, m := range parseValues(col.ColumnType) {
+				if v&(1<<uint(i)) != 0 {
+					members = append(members, m)
+				}
+			}
+			s = strings.Join(members, ",")
+		case strings.HasPrefix(t, "bit"):
+			s = fmt.Sprintf("%d", uint64(v))
+		default:
+			s = fmt.Sprintf("%d", v)
+		}
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	// DateTime of ClickHouse has no fractional seconds.
+	if (strings.HasPrefix(t, "datetime") || strings.HasPrefix(t, "timestamp")) && len(s) > 19 {
+		s = s[:19]
+	}
+	return &s
+}
+
+// writeTSV writes the value in the TabSeparated format of ClickHouse.
+func writeTSV(buf *bytes.Buffer, value *string) {
+	if value == nil {
+		buf.WriteString(`\N`)
+		return
+	}
+	s := *value
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case 0:
+			buf.WriteString(`\0`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// batch is the rows to be inserted into a table in one statement.
+type batch struct {
+	database string
+	table    string
+	columns  []string
+	buf      bytes.Buffer
+	nRows    int
+}
+
+func newBatch(database, table string, cols []mysql.Column) *batch {
+	b := &batch{database: database, table: table}
+	for i := range cols {
+		b.columns = append(b.columns, quoteName(cols[i].Name))
+	}
+	b.columns = append(b.columns, quoteName(versionColumn), quoteName(deletedColumn))
+	return b
+}
+
+// add adds a row with the values of the columns.
+func (b *batch) add(cols []mysql.Column, values []interface{}, version uint64, deleted bool) {
+	for i := range cols {
+		writeTSV(&b.buf, valueString(&cols[i], values[i]))
+		b.buf.WriteByte('\t')
+	}
+	isDeleted := 0
+	if deleted {
+		isDeleted = 1
+	}
+	fmt.Fprintf(&b.buf, "%d\t%d\n", version, isDeleted)
+	b.nRows++
+}
+
+func (b *batch) query() string {
+	return fmt.Sprintf("INSERT INTO %s.%s (%s) FORMAT TabSeparated",
+		quoteName(b.database), quoteName(b.table), strings.Join(b.columns, ","))
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func TestColumnType(t *testing.T) {
+	tests := []struct {
+		col  mysql.Column
+		want string
+	}{
+		{mysql.Column{ColumnType: "int(11)", Key: "PRI"}, "Int32"},
+		{mysql.Column{ColumnType: "int(10) unsigned", Nullable: true}, "Nullable(UInt32)"},
+		{mysql.Column{ColumnType: "bigint(20)", IsUnsigned: true}, "UInt64"},
+		{mysql.Column{ColumnType: "tinyint(1)"}, "Int8"},
+		{mysql.Column{ColumnType: "decimal(10,2)"}, "Decimal(10,2)"},
+		{mysql.Column{ColumnType: "datetime(6)"}, "DateTime"},
+		{mysql.Column{ColumnType: "date"}, "Date"},
+		{mysql.Column{ColumnType: "double"}, "Float64"},
+		{mysql.Column{ColumnType: "varchar(255)", Nullable: true}, "Nullable(String)"},
+		{mysql.Column{ColumnType: "enum('a','b')"}, "String"},
+	}
+	for _, tt := range tests {
+		if got := columnType(&tt.col); got != tt.want {
+			t.Errorf("columnType(%v) = %v, want %v", tt.col.ColumnType, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTableQuery(t *testing.T) {
+	cols := []mysql.Column{
+		{Name: "id", ColumnType: "int(11)", Key: "PRI"},
+		{Name: "name", ColumnType: "varchar(20)", Nullable: true},
+	}
+	want := "CREATE TABLE IF NOT EXISTS `db1`.`t1` (`id` Int32, `name` Nullable(String), `_version` UInt64, `_is_deleted` UInt8)" +
+		" ENGINE = ReplacingMergeTree(`_version`) ORDER BY (`id`)"
+	if got := createTableQuery("db1", "t1", cols); got != want {
+		t.Errorf("createTableQuery() = %v, want %v", got, want)
+	}
+
+	cols[0].Key = ""
+	want = "CREATE TABLE IF NOT EXISTS `db1`.`t1` (`id` Int32, `name` Nullable(String), `_version` UInt64, `_is_deleted` UInt8)" +
+		" ENGINE = MergeTree ORDER BY tuple()"
+	if got := createTableQuery("db1", "t1", cols); got != want {
+		t.Errorf("createTableQuery() = %v, want %v", got, want)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	got := parseValues("enum('a','b,c','it''s')")
+	want := []string{"a", "b,c", "it's"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseValues() = %v, want %v", got, want)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	cols := []mysql.Column{
+		{Name: "id", ColumnType: "int(11)", Key: "PRI"},
+		{Name: "e", ColumnType: "enum('x','y')"},
+		{Name: "s", ColumnType: "set('a','b','c')"},
+		{Name: "dt", ColumnType: "datetime(3)"},
+		{Name: "txt", ColumnType: "text", Nullable: true},
+	}
+	b := newBatch("db1", "t1", cols)
+	b.add(cols, []interface{}{[]byte("1"), []byte("y"), []byte("a,c"), []byte("2018-01-02 03:04:05.123"), []byte("a\tb\\")}, 0, false)
+	b.add(cols, []interface{}{int64(2), int64(1), int64(6), "2018-01-02 03:04:05.000", nil}, rowVersion(3, 1), true)
+
+	want := "1\ty\ta,c\t2018-01-02 03:04:05\ta\\tb\\\\\t0\t0\n" +
+		"2\tx\tb,c\t2018-01-02 03:04:05\t\\N\t50331649\t1\n"
+	if got := b.buf.String(); got != want {
+		t.Errorf("batch rows = %q, want %q", got, want)
+	}
+	if b.nRows != 2 {
+		t.Errorf("batch nRows = %v, want 2", b.nRows)
+	}
+	wantQuery := "INSERT INTO `db1`.`t1` (`id`,`e`,`s`,`dt`,`txt`,`_version`,`_is_deleted`) FORMAT TabSeparated"
+	if got := b.query(); got != wantQuery {
+		t.Errorf("batch query = %v, want %v", got, wantQuery)
+	}
+}
+
+func TestClient_Exec(t *testing.T) {
+	var gotQuery, gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotQuery, gotBody = r.URL.Query().Get("query"), string(body)
+		if gotBody == "bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Code: 62, e.displayText() = DB::Exception: Syntax error\n"))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(&ClickHouseConfig{Addr: ts.URL})
+	if err := c.Exec("SELECT 1", nil); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if gotQuery != "" || gotBody != "SELECT 1" {
+		t.Errorf("Exec() sent query %q and body %q", gotQuery, gotBody)
+	}
+
+	if err := c.Exec("INSERT INTO t FORMAT TabSeparated", bytes.NewBufferString("1\n")); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if gotQuery != "INSERT INTO t FORMAT TabSeparated" || gotBody != "1\n" {
+		t.Errorf("Exec() sent query %q and body %q", gotQuery, gotBody)
+	}
+
+	if err := c.Exec("bad", nil); err == nil {
+		t.Errorf("Exec() expected an error")
+	}
+}
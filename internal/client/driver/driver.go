@@ -9,6 +9,7 @@ package driver
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	uconf "github.com/actiontech/dtle/internal/config"
 	log "github.com/actiontech/dtle/internal/logger"
@@ -19,8 +20,11 @@ var (
 	// BuiltinDrivers contains the built in registered drivers
 	// which are available for allocation handling
 	BuiltinDrivers = map[string]Factory{
-		models.TaskDriverMySQL: NewMySQLDriver,
-		models.TaskDriverKafka: NewKafkaDriver,
+		models.TaskDriverMySQL:      NewMySQLDriver,
+		models.TaskDriverKafka:      NewKafkaDriver,
+		models.TaskDriverClickHouse: NewClickHouseDriver,
+		models.TaskDriverMongoDB:    NewMongoDriver,
+		models.TaskDriverSQLServer:  NewSQLServerDriver,
 		//"models.TaskDriverOracle:     NewOracleDriver,
 	}
 
@@ -34,6 +38,11 @@ var (
 func NewDriver(name string, ctx *DriverContext) (Driver, error) {
 	// Lookup the factory function
 	factory, ok := BuiltinDrivers[name]
+	if path, isPlugin := PluginDrivers[name]; isPlugin {
+		factory, ok = NewPluginDriver(name, path), true
+	} else if ok && ctx.config != nil && ctx.config.DriverExecutor {
+		factory = NewExecutorDriver(name)
+	}
 	if !ok {
 		return nil, fmt.Errorf("unknown driver '%s'", name)
 	}
@@ -104,10 +113,55 @@ type DriverHandle interface {
 	Stats() (*models.TaskStatistics, error)
 }
 
+// DrainableHandle is a DriverHandle which could stop taking new work and
+// finish the work in flight, before being shutdown with the agent.
+type DrainableHandle interface {
+	// Drain blocks until the work in flight is done, or the timeout.
+	Drain(timeout time.Duration) error
+}
+
+// ResyncableHandle is a DriverHandle which could copy a table again while
+// replicating the others.
+type ResyncableHandle interface {
+	// Resync starts copying the table again, and returns once it is started.
+	Resync(schema, table string) error
+}
+
+// UpdatableHandle is a DriverHandle which could change the tables of its task
+// while running.
+type UpdatableHandle interface {
+	// Update applies the tables of the task, or fails if they could not
+	// change while running.
+	Update(task *models.Task) error
+}
+
+// ProcessHandle is a DriverHandle whose task runs in a separate process, which
+// could be limited by a cgroup.
+type ProcessHandle interface {
+	// Pid returns the pid of the process of the task, 0 if it exited.
+	Pid() int
+}
+
 type ExecContext struct {
 	Subject    string
 	Tp         string
 	MaxPayload int
+
+	// TaskDir is the working dir of the task, for its temporary files. It is
+	// removed with the allocation.
+	TaskDir string
+
+	// Nats is the options of the connections to the NATS server(s).
+	Nats *uconf.NatsConfig
+
+	// ConsulAddr is the address of the Consul agent, empty if not configured.
+	ConsulAddr string
+
+	// Vault is the Vault server the keys of the jobs are read from.
+	Vault *uconf.VaultConfig
+
+	// Storage is the object storage of the agent, nil if not configured.
+	Storage *uconf.StorageConfig
 }
 
 // NewExecContext is used to create a new execution context
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+)
+
+// NewExecutorDriver returns the factory of the builtin driver name run in an
+// executor: a child process of the dtle binary serving the driver as a
+// plugin, launched for each task. A panic or an OOM of a task then fails only
+// the task, which is restarted with the restart policy of its job, not the
+// agent and its other tasks.
+func NewExecutorDriver(name string) Factory {
+	return func(ctx *DriverContext) Driver {
+		return &PluginDriver{DriverContext: *ctx, name: name, executor: true}
+	}
+}
+
+// builtinContext is the part of the ExecContext of a builtin driver not in the
+// plugin interface, passed to the executor in pdriver.ExecContext.Builtin.
+type builtinContext struct {
+	Nats       *uconf.NatsConfig
+	ConsulAddr string
+	Vault      *uconf.VaultConfig
+	Storage    *uconf.StorageConfig
+	// PluginDir is where the executor loads the hook plugins from.
+	PluginDir string
+}
+
+// executorCommand returns the command of the executor of the driver.
+func (d *PluginDriver) executorCommand() (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the dtle binary for the executor: %v", err)
+	}
+	logLevel := "INFO"
+	if d.config != nil && d.config.LogLevel != "" {
+		logLevel = d.config.LogLevel
+	}
+	return exec.Command(exe, "plugin", "-log-level", logLevel, d.name), nil
+}
+
+func (d *PluginDriver) builtinContext(ctx *ExecContext) (json.RawMessage, error) {
+	bctx := &builtinContext{
+		Nats:       ctx.Nats,
+		ConsulAddr: ctx.ConsulAddr,
+		Vault:      ctx.Vault,
+		Storage:    ctx.Storage,
+	}
+	if d.config != nil {
+		bctx.PluginDir = d.config.PluginDir
+	}
+	return json.Marshal(bctx)
+}
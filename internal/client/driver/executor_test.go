@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestNewDriver_Executor(t *testing.T) {
+	config := uconf.DefaultClientConfig()
+	drv, err := NewDriver(models.TaskDriverMySQL, NewDriverContext("Src", "alloc1", config, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := drv.(*MySQLDriver); !ok {
+		t.Errorf("driver %T, want the builtin driver", drv)
+	}
+
+	config.DriverExecutor = true
+	drv, err = NewDriver(models.TaskDriverMySQL, NewDriverContext("Src", "alloc1", config, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pd, ok := drv.(*PluginDriver)
+	if !ok || !pd.executor || pd.name != models.TaskDriverMySQL {
+		t.Fatalf("driver %#v, want an executor of %v", drv, models.TaskDriverMySQL)
+	}
+	cmd, err := pd.executorCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"plugin", "-log-level", config.LogLevel, models.TaskDriverMySQL}; !reflect.DeepEqual(cmd.Args[1:], want) {
+		t.Errorf("executor args %v, want %v", cmd.Args[1:], want)
+	}
+}
+
+func TestBuiltinContext(t *testing.T) {
+	config := uconf.DefaultClientConfig()
+	config.PluginDir = "/var/lib/dtle/plugins"
+	d := &PluginDriver{DriverContext: *NewDriverContext("Dest", "alloc1", config, nil, nil), executor: true}
+	ctx := &ExecContext{
+		Nats:       &uconf.NatsConfig{Servers: []string{"127.0.0.1:4222"}, EncryptKey: "a2V5"},
+		ConsulAddr: "127.0.0.1:8500",
+		Vault:      &uconf.VaultConfig{Addr: "https://vault:8200"},
+	}
+	raw, err := d.builtinContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got builtinContext
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := builtinContext{Nats: ctx.Nats, ConsulAddr: ctx.ConsulAddr, Vault: ctx.Vault, PluginDir: config.PluginDir}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
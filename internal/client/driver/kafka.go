@@ -22,6 +22,12 @@ func (kd *KafkaDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle,
 	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
 		return nil, err
 	}
+	driverConfig.Nats = ctx.Nats
+	key, err := trafficKey(ctx, driverConfig.TrafficKeyVaultPath)
+	if err != nil {
+		return nil, err
+	}
+	driverConfig.TrafficKey = key
 
 	switch task.Type {
 	case models.TaskTypeSrc:
@@ -37,6 +43,19 @@ func (kd *KafkaDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle,
 
 func (kd *KafkaDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
 	reply := &models.TaskValidateResponse{}
+	var driverConfig kafka3.KafkaConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	switch driverConfig.Converter {
+	case "", kafka3.CONVERTER_JSON:
+	case kafka3.CONVERTER_AVRO:
+		if driverConfig.SchemaRegistryURL == "" {
+			return reply, fmt.Errorf("SchemaRegistryURL is required by the avro converter")
+		}
+	default:
+		return reply, fmt.Errorf("unknown converter %v", driverConfig.Converter)
+	}
 
 	return reply, nil
 }
@@ -0,0 +1,299 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// The avro converter writes the messages as the AvroConverter of Kafka
+// Connect does: the schema is converted to an Avro schema and registered in
+// the Schema Registry, and the message is the magic byte 0, the id of the
+// schema as int32, and the payload in the binary encoding of Avro.
+
+const avroMagicByte = 0
+
+type AvroConverter struct {
+	registry *SchemaRegistry
+}
+
+func NewAvroConverter(registry *SchemaRegistry) *AvroConverter {
+	return &AvroConverter{registry: registry}
+}
+
+// Serialize returns the message of the payload, registering the schema under
+// the subject.
+func (c *AvroConverter) Serialize(subject string, schema *Schema, payload interface{}) ([]byte, error) {
+	avroSchema, err := AvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.registry.Register(subject, avroSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(avroMagicByte)
+	var idBytes [4]byte
+	binary.BigEndian.PutUint32(idBytes[:], uint32(id))
+	buf.Write(idBytes[:])
+	if err := avroEncode(buf, schema, payload); err != nil {
+		return nil, fmt.Errorf("kafka: avro serialization of %v error: %v", subject, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// AvroSchema returns the Avro schema, in JSON, of the schema of Kafka Connect.
+func AvroSchema(schema *Schema) (string, error) {
+	s, err := avroType(schema, make(map[string]bool))
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// avroName returns the full name of a record, of the names of Avro.
+func avroName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = avroFieldName(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// avroFieldName replaces the characters not allowed in a name of Avro.
+func avroFieldName(name string) string {
+	bs := []byte(name)
+	for i, b := range bs {
+		if !(b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || i > 0 && '0' <= b && b <= '9') {
+			bs[i] = '_'
+		}
+	}
+	if len(bs) == 0 {
+		return "_"
+	}
+	return string(bs)
+}
+
+// avroType returns the Avro type of the schema. A record defined is referred
+// to by the name after.
+func avroType(schema *Schema, defined map[string]bool) (interface{}, error) {
+	var t interface{}
+	switch schema.Type {
+	case SCHEMA_TYPE_STRUCT:
+		name := avroName(schema.Name)
+		if defined[name] {
+			t = name
+			break
+		}
+		defined[name] = true
+		fields := []map[string]interface{}{}
+		for _, f := range schema.Fields {
+			ft, err := avroType(f, defined)
+			if err != nil {
+				return nil, err
+			}
+			field := map[string]interface{}{
+				"name": avroFieldName(f.Field),
+				"type": ft,
+			}
+			if f.Optional {
+				field["default"] = nil
+			}
+			fields = append(fields, field)
+		}
+		t = map[string]interface{}{
+			"type":   "record",
+			"name":   name,
+			"fields": fields,
+		}
+	case SCHEMA_TYPE_INT8, SCHEMA_TYPE_INT16, SCHEMA_TYPE_INT32:
+		t = logicalType(schema, map[string]interface{}{"type": "int", "connect.type": string(schema.Type)})
+	case SCHEMA_TYPE_INT64:
+		t = logicalType(schema, map[string]interface{}{"type": "long"})
+	case SCHEMA_TYPE_FLOAT32:
+		t = logicalType(schema, map[string]interface{}{"type": "float"})
+	case SCHEMA_TYPE_FLOAT64:
+		t = logicalType(schema, map[string]interface{}{"type": "double"})
+	case SCHEMA_TYPE_BOOLEAN:
+		t = logicalType(schema, map[string]interface{}{"type": "boolean"})
+	case SCHEMA_TYPE_BYTES:
+		m := map[string]interface{}{"type": "bytes"}
+		if schema.Name == "org.apache.kafka.connect.data.Decimal" {
+			m["logicalType"] = "decimal"
+			for k, v := range map[string]string{"precision": "connect.decimal.precision", "scale": "scale"} {
+				var n int
+				if _, err := fmt.Sscan(fmt.Sprint(schema.Parameters[v]), &n); err != nil {
+					return nil, fmt.Errorf("invalid %v of decimal field %v", k, schema.Field)
+				}
+				m[k] = n
+			}
+		}
+		t = logicalType(schema, m)
+	default:
+		// string, and the columns of unknown types as string
+		t = logicalType(schema, map[string]interface{}{"type": "string"})
+	}
+
+	if schema.Optional {
+		return []interface{}{"null", t}, nil
+	}
+	return t, nil
+}
+
+// logicalType adds the name and parameters of Kafka Connect, or returns the
+// primitive type if none.
+func logicalType(schema *Schema, t map[string]interface{}) interface{} {
+	if schema.Name != "" {
+		t["connect.name"] = schema.Name
+	}
+	if schema.Version != 0 {
+		t["connect.version"] = schema.Version
+	}
+	if len(schema.Parameters) > 0 {
+		t["connect.parameters"] = schema.Parameters
+	}
+	if len(t) == 1 {
+		return t["type"]
+	}
+	return t
+}
+
+// recordFields returns the values of the fields of a payload, in the order
+// of the fields of the schema.
+func recordFields(schema *Schema, v interface{}) ([]interface{}, error) {
+	switch p := v.(type) {
+	case *Row:
+		if len(p.Values) != len(schema.Fields) {
+			return nil, fmt.Errorf("%v values of %v fields of %v", len(p.Values), len(schema.Fields), schema.Name)
+		}
+		return p.Values, nil
+	case *ValuePayload:
+		return []interface{}{p.Before, p.After, p.Source, p.Op, p.TsMs}, nil
+	case *SourcePayload:
+		return []interface{}{p.Version, p.Name, p.ServerID, p.TsSec, p.Gtid, p.File, p.Pos,
+			p.Row, p.Query, p.Snapshot, p.Thread, p.Db, p.Table}, nil
+	default:
+		return nil, fmt.Errorf("unexpected payload %T of %v", v, schema.Name)
+	}
+}
+
+// isNil tells if the value is nil, including a nil pointer.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+func writeLong(buf *bytes.Buffer, n int64) {
+	var bs [binary.MaxVarintLen64]byte
+	buf.Write(bs[:binary.PutVarint(bs[:], n)])
+}
+
+func writeBytes(buf *bytes.Buffer, bs []byte) {
+	writeLong(buf, int64(len(bs)))
+	buf.Write(bs)
+}
+
+// avroEncode writes the value of the schema in the binary encoding of Avro.
+func avroEncode(buf *bytes.Buffer, schema *Schema, v interface{}) error {
+	if schema.Optional {
+		if isNil(v) {
+			writeLong(buf, 0)
+			return nil
+		}
+		writeLong(buf, 1)
+	} else if isNil(v) {
+		return fmt.Errorf("null value of required field %v", schema.Field)
+	}
+
+	switch schema.Type {
+	case SCHEMA_TYPE_STRUCT:
+		values, err := recordFields(schema, v)
+		if err != nil {
+			return err
+		}
+		for i, f := range schema.Fields {
+			if err := avroEncode(buf, f, values[i]); err != nil {
+				return err
+			}
+		}
+	case SCHEMA_TYPE_INT8, SCHEMA_TYPE_INT16, SCHEMA_TYPE_INT32, SCHEMA_TYPE_INT64:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			writeLong(buf, rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			writeLong(buf, int64(rv.Uint()))
+		default:
+			return fmt.Errorf("value %v (%T) of integer field %v", v, v, schema.Field)
+		}
+	case SCHEMA_TYPE_FLOAT32, SCHEMA_TYPE_FLOAT64:
+		var f float64
+		switch n := v.(type) {
+		case float32:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return fmt.Errorf("value %v (%T) of float field %v", v, v, schema.Field)
+		}
+		if schema.Type == SCHEMA_TYPE_FLOAT32 {
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(f)))
+		} else {
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+		}
+	case SCHEMA_TYPE_BOOLEAN:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("value %v (%T) of boolean field %v", v, v, schema.Field)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case SCHEMA_TYPE_BYTES:
+		switch b := v.(type) {
+		case []byte:
+			writeBytes(buf, b)
+		case string:
+			// bytes are in base64 in the payload, as in JSON
+			bs, err := base64.StdEncoding.DecodeString(b)
+			if err != nil {
+				return fmt.Errorf("value of bytes field %v is not base64: %v", schema.Field, err)
+			}
+			writeBytes(buf, bs)
+		default:
+			return fmt.Errorf("value %v (%T) of bytes field %v", v, v, schema.Field)
+		}
+	default:
+		switch s := v.(type) {
+		case string:
+			writeBytes(buf, []byte(s))
+		case []byte:
+			writeBytes(buf, s)
+		default:
+			writeBytes(buf, []byte(fmt.Sprint(v)))
+		}
+	}
+	return nil
+}
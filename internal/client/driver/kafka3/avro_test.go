@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAvroSchema(t *testing.T) {
+	cols := ColDefs{
+		NewSimpleSchemaField(SCHEMA_TYPE_INT32, false, "id"),
+		NewSimpleSchemaField(SCHEMA_TYPE_STRING, true, "na-me"),
+		NewDecimalField(10, 2, true, "amount", nil),
+	}
+	s, err := AvroSchema(NewKeySchema("dtle.db1.t-1", cols[:1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fields":[{"name":"id","type":{"connect.type":"int32","type":"int"}}],"name":"dtle.db1.t_1.Key","type":"record"}`
+	if s != want {
+		t.Errorf("AvroSchema() of key = %v, want %v", s, want)
+	}
+
+	s, err = AvroSchema(NewEnvelopeSchema("dtle.db1.t", cols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope struct {
+		Fields []struct {
+			Name string
+			Type interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(s), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	// after refers to the record of before
+	if got := envelope.Fields[1].Type; !reflect.DeepEqual(got, []interface{}{"null", "dtle.db1.t.Value"}) {
+		t.Errorf("type of after = %v", got)
+	}
+	before := envelope.Fields[0].Type.([]interface{})[1].(map[string]interface{})
+	fields := before["fields"].([]interface{})
+	if got := fields[1].(map[string]interface{})["name"]; got != "na_me" {
+		t.Errorf("name of field na-me = %v", got)
+	}
+	amount := fields[2].(map[string]interface{})["type"].([]interface{})[1].(map[string]interface{})
+	if amount["logicalType"] != "decimal" || amount["precision"] != float64(10) || amount["scale"] != float64(2) {
+		t.Errorf("type of decimal = %v", amount)
+	}
+}
+
+func TestAvroEncode(t *testing.T) {
+	schema := NewKeySchema("t", ColDefs{
+		NewSimpleSchemaField(SCHEMA_TYPE_INT64, false, "id"),
+		NewSimpleSchemaField(SCHEMA_TYPE_STRING, true, "name"),
+		NewSimpleSchemaField(SCHEMA_TYPE_BYTES, true, "data"),
+		NewSimpleSchemaField(SCHEMA_TYPE_BOOLEAN, false, "flag"),
+	})
+	row := NewRow()
+	row.AddField("id", int64(-2))
+	row.AddField("name", "ab")
+	row.AddField("data", nil)
+	row.AddField("flag", true)
+
+	buf := bytes.NewBuffer(nil)
+	if err := avroEncode(buf, schema, row); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x03, 0x02, 0x04, 'a', 'b', 0x00, 0x01}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("avroEncode() = %x, want %x", buf.Bytes(), want)
+	}
+
+	row.Values[0] = nil
+	if err := avroEncode(bytes.NewBuffer(nil), schema, row); err == nil {
+		t.Errorf("avroEncode() of null required field expected an error")
+	}
+}
+
+func TestAvroConverter_Serialize(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.EscapedPath() != "/subjects/dtle.db1.t-key/versions" {
+			t.Errorf("path = %v", r.URL.EscapedPath())
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var req map[string]string
+		if err := json.Unmarshal(body, &req); err != nil || req["schema"] == "" {
+			t.Errorf("body = %s", body)
+		}
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer ts.Close()
+
+	c := NewAvroConverter(NewSchemaRegistry(ts.URL + "/"))
+	schema := NewKeySchema("dtle.db1.t", ColDefs{NewSimpleSchemaField(SCHEMA_TYPE_INT32, false, "id")})
+	row := NewRow()
+	row.AddField("id", int32(1))
+	for i := 0; i < 2; i++ {
+		bs, err := c.Serialize("dtle.db1.t-key", schema, row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte{0, 0, 0, 0, 7, 0x02}; !bytes.Equal(bs, want) {
+			t.Errorf("Serialize() = %x, want %x", bs, want)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("registered %v times, want once", requests)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"time"
 
 	"github.com/Shopify/sarama"
+
+	"github.com/actiontech/dtle/internal/config"
 )
 
 type SchemaType string
@@ -48,11 +50,27 @@ const (
 type ColDefs []*Schema
 
 type KafkaConfig struct {
-	Brokers   []string
-	Topic     string
+	Brokers []string
+	Topic   string
+	// Converter is the format of the messages, "json" (default) or "avro".
 	Converter string
-	NatsAddr  string
-	Gtid      string // TODO remove?
+	// SchemaRegistryURL is the Confluent Schema Registry of the avro converter,
+	// e.g. "http://127.0.0.1:8081".
+	SchemaRegistryURL string
+	// DebeziumCompatible makes the messages the same as those of the MySQL
+	// connector of Debezium: the rows of the full copy are reads ("r"), the
+	// tombstone is null, and an update of the key is a delete and a create.
+	DebeziumCompatible bool
+	NatsAddr           string
+	Gtid               string // TODO remove?
+	// TrafficKeyVaultPath is the key in Vault decrypting the data from the Src
+	// task, which must be the same as that of the Src task.
+	TrafficKeyVaultPath string
+	// Nats is set by the agent.
+	Nats *config.NatsConfig
+	// TrafficKey is set by the agent, from TrafficKeyVaultPath or the nats
+	// encrypt_key.
+	TrafficKey []byte `json:"-"`
 }
 
 type KafkaManager struct {
@@ -75,12 +93,15 @@ func NewKafkaManager(kcfg *KafkaConfig) (*KafkaManager, error) {
 	return k, nil
 }
 
+// Send sends a message to the topic. A nil value is a tombstone.
 func (k *KafkaManager) Send(topic string, key []byte, value []byte) error {
 	msg := &sarama.ProducerMessage{
 		Topic:     topic,
 		Partition: int32(-1),
 		Key:       sarama.ByteEncoder(key),
-		Value:     sarama.ByteEncoder(value),
+	}
+	if value != nil {
+		msg.Value = sarama.ByteEncoder(value)
 	}
 
 	_, _, err := k.producer.SendMessage(msg)
@@ -11,6 +11,7 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
 
 	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
@@ -23,6 +24,7 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"strings"
+	"sync"
 
 	"time"
 
@@ -44,13 +46,16 @@ type KafkaRunner struct {
 	subject     string
 	subjectUUID uuid.UUID
 	natsConn    *gonats.Conn
+	cipher      *mysqlDriver.TrafficCipher
 	waitCh      chan *models.WaitResult
 
-	shutdown   bool
-	shutdownCh chan struct{}
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
 
 	kafkaConfig *KafkaConfig
 	kafkaMgr    *KafkaManager
+	avro        *AvroConverter
 
 	tables map[string](map[string]*config.Table)
 }
@@ -93,6 +98,8 @@ func (kr *KafkaRunner) WaitCh() chan *models.WaitResult {
 }
 
 func (kr *KafkaRunner) Shutdown() error {
+	kr.shutdownLock.Lock()
+	defer kr.shutdownLock.Unlock()
 	if kr.shutdown {
 		return nil
 	}
@@ -111,8 +118,8 @@ func (kr *KafkaRunner) Stats() (*models.TaskStatistics, error) {
 	return taskResUsage, nil
 }
 func (kr *KafkaRunner) initNatSubClient() (err error) {
-	natsAddr := fmt.Sprintf("nats://%s", kr.kafkaConfig.NatsAddr)
-	sc, err := gonats.Connect(natsAddr)
+	natsAddr := config.NatsURL(kr.kafkaConfig.NatsAddr)
+	sc, err := config.NatsConnect(kr.kafkaConfig.NatsAddr, kr.kafkaConfig.Nats)
 	if err != nil {
 		kr.logger.Errorf("kafka: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
 		return err
@@ -132,6 +139,19 @@ func (kr *KafkaRunner) Run() {
 		return
 	}
 
+	switch kr.kafkaConfig.Converter {
+	case "", CONVERTER_JSON:
+	case CONVERTER_AVRO:
+		if kr.kafkaConfig.SchemaRegistryURL == "" {
+			kr.onError(TaskStateDead, fmt.Errorf("kafka: SchemaRegistryURL is required by the avro converter"))
+			return
+		}
+		kr.avro = NewAvroConverter(NewSchemaRegistry(kr.kafkaConfig.SchemaRegistryURL))
+	default:
+		kr.onError(TaskStateDead, fmt.Errorf("kafka: unknown converter %v", kr.kafkaConfig.Converter))
+		return
+	}
+
 	err = kr.initNatSubClient()
 	if err != nil {
 		kr.logger.Errorf("initNatSubClient error: %v", err.Error())
@@ -168,10 +188,23 @@ func (kr *KafkaRunner) getOrSetTable(schemaName string, tableName string, table
 	}
 }
 
+// subscribe is natsConn.Subscribe, with the messages decrypted if the job has
+// a traffic key.
+func (kr *KafkaRunner) subscribe(subj string, cb gonats.MsgHandler) (*gonats.Subscription, error) {
+	return kr.natsConn.Subscribe(subj, kr.cipher.Handler(cb, kr.logger, func(err error) {
+		kr.onError(TaskStateDead, err)
+	}))
+}
+
 func (kr *KafkaRunner) initiateStreaming() error {
 	var err error
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_full", kr.subject), func(m *gonats.Msg) {
+	kr.cipher, err = mysqlDriver.NewTrafficCipher(kr.kafkaConfig.TrafficKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = kr.subscribe(fmt.Sprintf("%s_full", kr.subject), func(m *gonats.Msg) {
 		kr.logger.Debugf("kafka: recv a msg")
 		dumpData := &mysqlDriver.DumpEntry{}
 		if err := Decode(m.Data, dumpData); err != nil {
@@ -206,13 +239,13 @@ func (kr *KafkaRunner) initiateStreaming() error {
 		return err
 	}
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_full_complete", kr.subject), func(m *gonats.Msg) {
+	_, err = kr.subscribe(fmt.Sprintf("%s_full_complete", kr.subject), func(m *gonats.Msg) {
 		if err := kr.natsConn.Publish(m.Reply, nil); err != nil {
 			kr.onError(TaskStateDead, err)
 		}
 	})
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_incr_hete", kr.subject), func(m *gonats.Msg) {
+	_, err = kr.subscribe(fmt.Sprintf("%s_incr_hete", kr.subject), func(m *gonats.Msg) {
 		var binlogEntries binlog.BinlogEntries
 		if err := Decode(m.Data, &binlogEntries); err != nil {
 			kr.onError(TaskStateDead, err)
@@ -269,6 +302,18 @@ func (kr *KafkaRunner) onError(state int, err error) {
 	kr.Shutdown()
 }
 
+// serialize returns the message of the payload, in the format of the
+// converter. The subject is that of the schema in the Schema Registry.
+func (kr *KafkaRunner) serialize(subject string, schema *Schema, payload interface{}) ([]byte, error) {
+	if kr.avro != nil {
+		return kr.avro.Serialize(subject, schema, payload)
+	}
+	return json.Marshal(DbzOutput{
+		Schema:  schema,
+		Payload: payload,
+	})
+}
+
 func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *mysqlDriver.DumpEntry) error {
 	var err error
 
@@ -290,6 +335,9 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 		valuePayload.Source.Db = table.TableSchema
 		valuePayload.Source.Table = table.TableName
 		valuePayload.Op = RECORD_OP_INSERT
+		if kr.kafkaConfig.DebeziumCompatible {
+			valuePayload.Op = RECORD_OP_READ
+		}
 		valuePayload.Source.Query = nil
 		valuePayload.TsMs = utils.CurrentTimeMillis()
 
@@ -381,20 +429,11 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 
 		valueSchema := NewEnvelopeSchema(tableIdent, valueColDef)
 
-		k := DbzOutput{
-			Schema:  keySchema,
-			Payload: keyPayload,
-		}
-		v := DbzOutput{
-			Schema:  valueSchema,
-			Payload: valuePayload,
-		}
-
-		kBs, err := json.Marshal(k)
+		kBs, err := kr.serialize(tableIdent+"-key", keySchema, keyPayload)
 		if err != nil {
 			return fmt.Errorf("kafka: serialization error: %v", err)
 		}
-		vBs, err := json.Marshal(v)
+		vBs, err := kr.serialize(tableIdent+"-value", valueSchema, valuePayload)
 		if err != nil {
 			return fmt.Errorf("kafka: serialization error: %v", err)
 		}
@@ -444,6 +483,7 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 		tableIdent := fmt.Sprintf("%v.%v.%v", kr.kafkaMgr.Cfg.Topic, table.TableSchema, table.TableName)
 
 		keyPayload := NewRow()
+		afterKeyPayload := NewRow()
 		colList := table.OriginalTableColumns.ColumnList()
 		colDefs, keyColDefs := kafkaColumnListToColDefs(table.OriginalTableColumns)
 
@@ -502,10 +542,10 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 				}
 			case mysql.VarbinaryColumnType:
 				if beforeValue != nil {
-					beforeValue = beforeValue.(string)
+					beforeValue = base64.StdEncoding.EncodeToString([]byte(beforeValue.(string)))
 				}
 				if afterValue != nil {
-					afterValue = afterValue.(string)
+					afterValue = base64.StdEncoding.EncodeToString([]byte(afterValue.(string)))
 				}
 			case mysql.BinaryColumnType:
 
@@ -568,6 +608,9 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 					// insert: use after
 					keyPayload.AddField(colName, afterValue)
 				}
+				if after != nil {
+					afterKeyPayload.AddField(colName, afterValue)
+				}
 			}
 
 			if before != nil {
@@ -603,52 +646,75 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 		valuePayload.TsMs = utils.CurrentTimeMillis()
 
 		valueSchema := NewEnvelopeSchema(tableIdent, colDefs)
-
 		keySchema := NewKeySchema(tableIdent, keyColDefs)
-		k := DbzOutput{
-			Schema:  keySchema,
-			Payload: keyPayload,
-		}
-		v := DbzOutput{
-			Schema:  valueSchema,
-			Payload: valuePayload,
+
+		if kr.kafkaConfig.DebeziumCompatible && op == RECORD_OP_UPDATE &&
+			!reflect.DeepEqual(keyPayload.Values, afterKeyPayload.Values) {
+			// Debezium sends a delete of the old key and a create of the new one.
+			deletePayload := *valuePayload
+			deletePayload.After = nil
+			deletePayload.Op = RECORD_OP_DELETE
+			err = kr.sendChange(tableIdent, keySchema, keyPayload, valueSchema, &deletePayload)
+			if err != nil {
+				return err
+			}
+			createPayload := *valuePayload
+			createPayload.Before = nil
+			createPayload.Op = RECORD_OP_INSERT
+			err = kr.sendChange(tableIdent, keySchema, afterKeyPayload, valueSchema, &createPayload)
+		} else {
+			err = kr.sendChange(tableIdent, keySchema, keyPayload, valueSchema, valuePayload)
 		}
-		kBs, err := json.Marshal(k)
 		if err != nil {
 			return err
 		}
-		vBs, err := json.Marshal(v)
+	}
+
+	return nil
+}
+
+// sendChange sends the message of a change, and the tombstone after a delete.
+func (kr *KafkaRunner) sendChange(tableIdent string, keySchema *Schema, key *Row, valueSchema *Schema, value *ValuePayload) error {
+	kBs, err := kr.serialize(tableIdent+"-key", keySchema, key)
+	if err != nil {
+		return err
+	}
+	vBs, err := kr.serialize(tableIdent+"-value", valueSchema, value)
+	if err != nil {
+		return err
+	}
+	err = kr.kafkaMgr.Send(tableIdent, kBs, vBs)
+	if err != nil {
+		return err
+	}
+	kr.logger.Debugf("kafka: sent one msg")
+
+	if value.Op == RECORD_OP_DELETE {
+		tombstone, err := kr.tombstone()
 		if err != nil {
 			return err
 		}
-		//	vBs = []byte(strings.Replace(string(vBs), "\"field\":\"snapshot\"", "\"default\":false,\"field\":\"snapshot\"", -1))
-		err = kr.kafkaMgr.Send(tableIdent, kBs, vBs)
+		err = kr.kafkaMgr.Send(tableIdent, kBs, tombstone)
 		if err != nil {
 			return err
 		}
 		kr.logger.Debugf("kafka: sent one msg")
-
-		// tombstone event for DELETE
-		if dataEvent.DML == binlog.DeleteDML {
-			v2 := DbzOutput{
-				Schema:  nil,
-				Payload: nil,
-			}
-			v2Bs, err := json.Marshal(v2)
-			if err != nil {
-				return err
-			}
-			err = kr.kafkaMgr.Send(tableIdent, kBs, v2Bs)
-			if err != nil {
-				return err
-			}
-			kr.logger.Debugf("kafka: sent one msg")
-		}
 	}
-
 	return nil
 }
 
+// tombstone returns the value of the tombstone after a delete, which is null
+// for Debezium and avro.
+func (kr *KafkaRunner) tombstone() ([]byte, error) {
+	if kr.kafkaConfig.DebeziumCompatible || kr.avro != nil {
+		return nil, nil
+	}
+	return json.Marshal(DbzOutput{
+		Schema:  nil,
+		Payload: nil,
+	})
+}
+
 func getSetValue(num int64, set string) string {
 	value := ""
 	sets := strings.Split(set[5:len(set)-1], ",")
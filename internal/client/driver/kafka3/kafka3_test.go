@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	natsd "github.com/nats-io/gnatsd/server"
+	gonats "github.com/nats-io/go-nats"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+type fakeProducer struct {
+	msgs []*sarama.ProducerMessage
+}
+
+func (p *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.msgs = append(p.msgs, msg)
+	return 0, int64(len(p.msgs)), nil
+}
+func (p *fakeProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+func (p *fakeProducer) Close() error {
+	return nil
+}
+
+// op returns the op of a message, or "" for a tombstone.
+func op(t *testing.T, msg *sarama.ProducerMessage) string {
+	if msg.Value == nil {
+		return ""
+	}
+	bs, _ := msg.Value.Encode()
+	var v struct {
+		Payload *struct {
+			Op string `json:"op"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(bs, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Payload == nil {
+		return "null"
+	}
+	return v.Payload.Op
+}
+
+func TestKafkaTransformDMLEventQuery_Debezium(t *testing.T) {
+	table := config.NewTable("db1", "t")
+	table.OriginalTableColumns = mysql.NewColumnList([]mysql.Column{
+		{Name: "id", Type: mysql.BigIntColumnType, ColumnType: "bigint", Key: "PRI"},
+		{Name: "v", Type: mysql.BigIntColumnType, ColumnType: "bigint", Nullable: true},
+	})
+	event := func(dml binlog.EventDML, where, values []interface{}) binlog.DataEvent {
+		e := binlog.NewDataEvent("db1", "t", dml, 2)
+		e.Table = table
+		if where != nil {
+			e.WhereColumnValues = mysql.ToColumnValues(where)
+		}
+		if values != nil {
+			e.NewColumnValues = mysql.ToColumnValues(values)
+		}
+		return e
+	}
+	entry := &binlog.BinlogEntry{Events: []binlog.DataEvent{
+		event(binlog.UpdateDML, []interface{}{int64(1), int64(1)}, []interface{}{int64(1), int64(2)}),
+		event(binlog.UpdateDML, []interface{}{int64(1), int64(2)}, []interface{}{int64(3), int64(2)}),
+		event(binlog.DeleteDML, []interface{}{int64(3), int64(2)}, nil),
+	}}
+
+	tests := []struct {
+		debezium bool
+		want     []string
+	}{
+		{false, []string{"u", "u", "d", "null"}},
+		{true, []string{"u", "d", "", "c", "d", ""}},
+	}
+	for _, tt := range tests {
+		producer := &fakeProducer{}
+		cfg := &KafkaConfig{Topic: "dtle", DebeziumCompatible: tt.debezium}
+		kr := NewKafkaRunner("job1", "", 0, cfg, log.New(os.Stderr, log.InfoLevel))
+		kr.kafkaMgr = &KafkaManager{Cfg: cfg, producer: producer}
+		if err := kr.kafkaTransformDMLEventQuery(entry); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, msg := range producer.msgs {
+			got = append(got, op(t, msg))
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("debezium %v: ops = %v, want %v", tt.debezium, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("debezium %v: ops = %v, want %v", tt.debezium, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestKafkaRunner_TrafficKey(t *testing.T) {
+	s := natsd.New(&natsd.Options{Host: "127.0.0.1", Port: natsd.RANDOM_PORT, NoLog: true, NoSigs: true})
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not started")
+	}
+	natsAddr := s.Addr().String()
+
+	key := bytes.Repeat([]byte{1}, 32)
+	cfg := &KafkaConfig{Topic: "dtle", NatsAddr: natsAddr, TrafficKey: key}
+	kr := NewKafkaRunner("job1", "", 0, cfg, log.New(os.Stderr, log.InfoLevel))
+	kr.kafkaMgr = &KafkaManager{Cfg: cfg, producer: &fakeProducer{}}
+	if err := kr.initNatSubClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer kr.Shutdown()
+	if err := kr.initiateStreaming(); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := gonats.Connect("nats://" + natsAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	srcCipher, _ := mysqlDriver.NewTrafficCipher(key)
+	data, err := mysqlDriver.Encode(&mysqlDriver.DumpEntry{DbSQL: "CREATE DATABASE IF NOT EXISTS db1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srcCipher.Seal("job1_full", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Request("job1_full", msg, 5*time.Second); err != nil {
+		t.Fatalf("encrypted message not applied: %v", err)
+	}
+	if _, err := src.Request("job1_full", msg, 200*time.Millisecond); err != gonats.ErrTimeout {
+		t.Fatalf("replayed message applied: %v", err)
+	}
+
+	if err := src.Publish("job1_full", data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-kr.WaitCh():
+		if r.Err == nil || r.ExitCode != TaskStateDead {
+			t.Fatalf("plaintext message: %+v, want the task dead", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("plaintext message accepted")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// SchemaRegistry registers the schemas in the Confluent Schema Registry,
+// caching the ids of the schemas registered.
+type SchemaRegistry struct {
+	url        string
+	httpClient *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+func NewSchemaRegistry(registryURL string) *SchemaRegistry {
+	return &SchemaRegistry{
+		url:        strings.TrimSuffix(registryURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		ids:        make(map[string]int),
+	}
+}
+
+// Register returns the id of the schema under the subject, registering it if
+// it is new. The registry returns the id of an existing schema, and rejects
+// a schema incompatible with the previous versions.
+func (r *SchemaRegistry) Register(subject string, schema string) (int, error) {
+	key := subject + "\x00" + schema
+	r.mu.Lock()
+	id, ok := r.ids[key]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.httpClient.Post(fmt.Sprintf("%s/subjects/%s/versions", r.url, url.PathEscape(subject)),
+		schemaRegistryContentType, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("kafka: register schema of %v: %v", subject, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("kafka: register schema of %v: %v %s", subject, resp.Status, respBody)
+	}
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return 0, fmt.Errorf("kafka: register schema of %v: %v", subject, err)
+	}
+
+	r.mu.Lock()
+	r.ids[key] = result.ID
+	r.mu.Unlock()
+	return result.ID, nil
+}
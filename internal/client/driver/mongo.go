@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/actiontech/dtle/internal/client/driver/mongo"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+type MongoDriver struct {
+	DriverContext
+}
+
+func NewMongoDriver(ctx *DriverContext) Driver {
+	return &MongoDriver{DriverContext: *ctx}
+}
+
+func (md *MongoDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig mongo.MongoDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.Nats = ctx.Nats
+	key, err := trafficKey(ctx, driverConfig.TrafficKeyVaultPath)
+	if err != nil {
+		return nil, err
+	}
+	driverConfig.TrafficKey = key
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		extractor := mongo.NewExtractor(ctx.Subject, ctx.MaxPayload, &driverConfig, md.logger)
+		go extractor.Run()
+		return extractor, nil
+	case models.TaskTypeDest:
+		return nil, fmt.Errorf("MongoDB can only be used on 'Src'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+// Validate checks that the source is a replica set or sharded cluster of
+// MongoDB 4.0 or later, which has change streams from a cluster time.
+func (md *MongoDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig mongo.MongoDriverConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeSrc {
+		return reply, fmt.Errorf("MongoDB can only be used on 'Src'")
+	}
+
+	client, err := mongo.Dial(driverConfig.URI)
+	if err != nil {
+		reply.Connection.Error = err.Error()
+		return reply, nil
+	}
+	defer client.Disconnect(context.Background())
+
+	var isMaster struct {
+		SetName        string `bson:"setName"`
+		Msg            string `bson:"msg"`
+		MaxWireVersion int    `bson:"maxWireVersion"`
+	}
+	err = client.Database("admin").RunCommand(context.Background(), bson.D{{Key: "isMaster", Value: 1}}).Decode(&isMaster)
+	if err != nil {
+		reply.Connection.Error = err.Error()
+	} else if isMaster.SetName == "" && isMaster.Msg != "isdbgrid" {
+		reply.Connection.Error = "change streams require a replica set or sharded cluster"
+	} else if isMaster.MaxWireVersion < 7 {
+		reply.Connection.Error = "change streams from a cluster time require MongoDB 4.0 or later"
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongo
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// systemDBs are not replicated, unless given explicitly.
+var systemDBs = []string{"admin", "config", "local"}
+
+// filter is the replicated collections. A database without collections is
+// replicated as a whole, and so are all the databases if none is given.
+type filter struct {
+	dbs map[string][]string
+}
+
+func newFilter(doDbs []*config.DataSource) *filter {
+	f := &filter{dbs: make(map[string][]string)}
+	for _, doDb := range doDbs {
+		colls := f.dbs[doDb.TableSchema]
+		for _, table := range doDb.Tables {
+			colls = append(colls, table.TableName)
+		}
+		f.dbs[doDb.TableSchema] = colls
+	}
+	return f
+}
+
+func isSystemDB(db string) bool {
+	for _, sys := range systemDBs {
+		if db == sys {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filter) match(db, coll string) bool {
+	if strings.HasPrefix(coll, "system.") {
+		return false
+	}
+	if len(f.dbs) == 0 {
+		return !isSystemDB(db)
+	}
+	colls, ok := f.dbs[db]
+	if !ok {
+		return false
+	}
+	if len(colls) == 0 {
+		return true
+	}
+	for _, c := range colls {
+		if c == coll {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStage returns the $match stage of the changes of the collections. A
+// dropDatabase has no collection, and a rename out of the collections is
+// matched by the old name.
+func (f *filter) matchStage() bson.M {
+	if len(f.dbs) == 0 {
+		return bson.M{"$match": bson.M{"ns.db": bson.M{"$nin": systemDBs}}}
+	}
+
+	var dbs []string
+	for db := range f.dbs {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+
+	var or []bson.M
+	for _, db := range dbs {
+		if colls := f.dbs[db]; len(colls) == 0 {
+			or = append(or, bson.M{"ns.db": db})
+		} else {
+			or = append(or,
+				bson.M{"ns.db": db, "ns.coll": bson.M{"$in": colls}},
+				bson.M{"ns.db": db, "operationType": "dropDatabase"})
+		}
+	}
+	return bson.M{"$match": bson.M{"$or": or}}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The position in a change stream is given to the applier as a gtid, so that
+// it is checkpointed and deduplicated the same as that of MySQL.
+//
+// The changes are numbered in an epoch, which begins at a cluster time, and
+// the SID of the epoch is made of the cluster time. The change of gno N in
+// the epoch is the Nth transaction of the change stream started at the
+// cluster time, so the stream is resumed from a gtid by starting at the
+// cluster time and skipping N transactions. A new epoch is begun each
+// epochDuration, so that the cluster time of a checkpoint is not older than
+// the oplog.
+
+// epochMarker is the second half of the SID of an epoch.
+var epochMarker = []byte("mongodb\x00")
+
+var epochDuration = time.Hour
+
+// epochSID returns the SID of the epoch beginning at the cluster time.
+func epochSID(ts primitive.Timestamp) uuid.UUID {
+	var sid uuid.UUID
+	binary.BigEndian.PutUint32(sid[:4], ts.T)
+	binary.BigEndian.PutUint32(sid[4:8], ts.I)
+	copy(sid[8:], epochMarker)
+	return sid
+}
+
+// checkpoint returns the gtid of the change of gno in the epoch. A gno of 0
+// is the beginning of the epoch, before any change.
+func checkpoint(sid uuid.UUID, gno int64) string {
+	if gno == 0 {
+		return sid.String()
+	}
+	return fmt.Sprintf("%s:1-%d", sid, gno)
+}
+
+// parseCheckpoint returns the cluster time of the epoch and the gno of the
+// gtid returned by checkpoint.
+func parseCheckpoint(gtid string) (primitive.Timestamp, int64, error) {
+	parts := strings.Split(strings.TrimSpace(gtid), ":")
+	sid, err := uuid.FromString(parts[0])
+	if err != nil {
+		return primitive.Timestamp{}, 0, fmt.Errorf("invalid MongoDB position %q: %v", gtid, err)
+	}
+	if string(sid[8:]) != string(epochMarker) {
+		return primitive.Timestamp{}, 0, fmt.Errorf("invalid MongoDB position %q: not a change stream epoch", gtid)
+	}
+	ts := primitive.Timestamp{T: binary.BigEndian.Uint32(sid[:4]), I: binary.BigEndian.Uint32(sid[4:8])}
+
+	var gno int64
+	switch len(parts) {
+	case 1:
+	case 2:
+		interval := parts[1]
+		if i := strings.LastIndexByte(interval, '-'); i >= 0 {
+			interval = interval[i+1:]
+		}
+		if gno, err = strconv.ParseInt(interval, 10, 64); err != nil {
+			return primitive.Timestamp{}, 0, fmt.Errorf("invalid MongoDB position %q: %v", gtid, err)
+		}
+	default:
+		return primitive.Timestamp{}, 0, fmt.Errorf("invalid MongoDB position %q", gtid)
+	}
+	return ts, gno, nil
+}
+
+// epochEnded tells if a new epoch is to be begun at the cluster time.
+func epochEnded(begin, ts primitive.Timestamp) bool {
+	return int64(ts.T)-int64(begin.T) >= int64(epochDuration/time.Second)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func timestamp(seconds, inc uint32) primitive.Timestamp {
+	return primitive.Timestamp{T: seconds, I: inc}
+}
+
+func TestCheckpoint(t *testing.T) {
+	ts := timestamp(1530000000, 7)
+	sid := epochSID(ts)
+
+	tests := []struct {
+		gno int64
+	}{
+		{0},
+		{1},
+		{123456},
+	}
+	for _, tt := range tests {
+		gtid := checkpoint(sid, tt.gno)
+		gotTs, gotGno, err := parseCheckpoint(gtid)
+		if err != nil {
+			t.Fatalf("parseCheckpoint(%v) error = %v", gtid, err)
+		}
+		if gotTs != ts || gotGno != tt.gno {
+			t.Errorf("parseCheckpoint(%v) = %v, %v, want %v, %v", gtid, gotTs, gotGno, ts, tt.gno)
+		}
+	}
+}
+
+func TestParseCheckpoint_Invalid(t *testing.T) {
+	for _, gtid := range []string{
+		"not-a-uuid",
+		"a6c63e32-f5a0-11e8-9f7d-0242ac110002:1-10", // a gtid of MySQL
+		checkpoint(epochSID(timestamp(1, 1)), 1) + ":2",
+	} {
+		if _, _, err := parseCheckpoint(gtid); err == nil {
+			t.Errorf("parseCheckpoint(%v) expected an error", gtid)
+		}
+	}
+}
+
+func TestEpochEnded(t *testing.T) {
+	begin := timestamp(1530000000, 3)
+	if epochEnded(begin, timestamp(1530000000+3599, 100)) {
+		t.Errorf("epochEnded() = true within an epoch")
+	}
+	if !epochEnded(begin, timestamp(1530000000+3600, 0)) {
+		t.Errorf("epochEnded() = false after an epoch")
+	}
+}
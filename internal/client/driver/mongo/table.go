@@ -0,0 +1,239 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// A collection is replicated as a table of the same name in the database of
+// the same name, with the _id as primary key and the document as JSON:
+//
+//	CREATE TABLE `db`.`coll` (`_id` varchar(255) NOT NULL, `doc` json, PRIMARY KEY (`_id`))
+const (
+	idColumn  = "_id"
+	docColumn = "doc"
+)
+
+// newTable returns the table of the collection.
+func newTable(db, coll string) *config.Table {
+	table := config.NewTable(db, coll)
+	table.OriginalTableColumns = mysql.NewColumnList([]mysql.Column{
+		{Name: idColumn, Type: mysql.VarcharColumnType, ColumnType: "varchar(255)", Key: "PRI"},
+		{Name: docColumn, Type: mysql.JSONColumnType, ColumnType: "json", Nullable: true},
+	})
+	return table
+}
+
+func createDatabaseQuery(db string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", usql.EscapeName(db))
+}
+
+func createTableQuery(db, coll string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s varchar(255) NOT NULL, %s json, PRIMARY KEY (%s))",
+		usql.EscapeName(db), usql.EscapeName(coll),
+		usql.EscapeName(idColumn), usql.EscapeName(docColumn), usql.EscapeName(idColumn))
+}
+
+// idString returns the text of the _id of a document. An ObjectId is in hex,
+// a string is itself, and others are in extended JSON.
+func idString(id bson.RawValue) (string, error) {
+	switch id.Type {
+	case bsontype.ObjectID:
+		return id.ObjectID().Hex(), nil
+	case bsontype.String:
+		return id.StringValue(), nil
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(id.Int32()), 10), nil
+	case bsontype.Int64:
+		return strconv.FormatInt(id.Int64(), 10), nil
+	case bsontype.Double:
+		return fmt.Sprintf("%v", id.Double()), nil
+	default:
+		// a value is not marshaled alone, but as the field of a document
+		text, err := docString(bson.D{{Key: idColumn, Value: id}})
+		if err != nil {
+			return "", err
+		}
+		prefix := fmt.Sprintf("{%q:", idColumn)
+		return strings.TrimSuffix(strings.TrimPrefix(text, prefix), "}"), nil
+	}
+}
+
+// docString returns a document in relaxed extended JSON, with its fields in
+// order.
+func docString(doc interface{}) (string, error) {
+	bs, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+type namespace struct {
+	DB   string `bson:"db"`
+	Coll string `bson:"coll"`
+}
+
+// changeEvent is a change in a change stream.
+type changeEvent struct {
+	ID            bson.Raw            `bson:"_id"`
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Ns            namespace           `bson:"ns"`
+	To            namespace           `bson:"to"`
+	DocumentKey   struct {
+		ID bson.RawValue `bson:"_id"`
+	} `bson:"documentKey"`
+	// FullDocument is empty if the document is deleted before looked up.
+	FullDocument bson.Raw `bson:"fullDocument"`
+}
+
+// schemaTracker converts the changes to the events of the tables, creating
+// the tables and databases not yet created in this run.
+type schemaTracker struct {
+	filter *filter
+	dbs    map[string]bool
+	tables map[string]*config.Table
+}
+
+func newSchemaTracker(filter *filter) *schemaTracker {
+	return &schemaTracker{
+		filter: filter,
+		dbs:    make(map[string]bool),
+		tables: make(map[string]*config.Table),
+	}
+}
+
+func queryEvent(db, coll, query string) binlog.DataEvent {
+	return binlog.NewQueryEventAffectTable("", query, binlog.NotDML, binlog.SchemaTable{Schema: db, Table: coll})
+}
+
+// table returns the table of the collection, with the events creating it if
+// not yet created.
+func (s *schemaTracker) table(db, coll string) (*config.Table, []binlog.DataEvent) {
+	key := db + "." + coll
+	if table, ok := s.tables[key]; ok {
+		return table, nil
+	}
+
+	var events []binlog.DataEvent
+	if !s.dbs[db] {
+		events = append(events, queryEvent(db, "", createDatabaseQuery(db)))
+		s.dbs[db] = true
+	}
+	table := newTable(db, coll)
+	event := queryEvent(db, coll, createTableQuery(db, coll))
+	event.Table = table
+	events = append(events, event)
+	s.tables[key] = table
+	return table, events
+}
+
+// dataEvents returns the events of a change. An insert, update or replace is
+// the full document, and a delete is the _id.
+func (s *schemaTracker) dataEvents(ev *changeEvent) ([]binlog.DataEvent, error) {
+	switch ev.OperationType {
+	case "insert", "update", "replace", "delete":
+		table, events := s.table(ev.Ns.DB, ev.Ns.Coll)
+		id, err := idString(ev.DocumentKey.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var event binlog.DataEvent
+		switch ev.OperationType {
+		case "delete":
+			event = binlog.NewDataEvent(ev.Ns.DB, ev.Ns.Coll, binlog.DeleteDML, 2)
+			event.WhereColumnValues = mysql.ToColumnValues([]interface{}{id, nil})
+		default:
+			if len(ev.FullDocument) == 0 {
+				// deleted before looked up, to be deleted by a later change
+				return events, nil
+			}
+			doc, err := docString(ev.FullDocument)
+			if err != nil {
+				return nil, err
+			}
+			if ev.OperationType == "insert" {
+				event = binlog.NewDataEvent(ev.Ns.DB, ev.Ns.Coll, binlog.InsertDML, 2)
+			} else {
+				event = binlog.NewDataEvent(ev.Ns.DB, ev.Ns.Coll, binlog.UpdateDML, 2)
+				event.WhereColumnValues = mysql.ToColumnValues([]interface{}{id, nil})
+			}
+			event.NewColumnValues = mysql.ToColumnValues([]interface{}{id, doc})
+		}
+		event.Table = table
+		return append(events, event), nil
+
+	case "drop":
+		delete(s.tables, ev.Ns.DB+"."+ev.Ns.Coll)
+		event := queryEvent(ev.Ns.DB, ev.Ns.Coll, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s",
+			usql.EscapeName(ev.Ns.DB), usql.EscapeName(ev.Ns.Coll)))
+		event.Table = newTable(ev.Ns.DB, ev.Ns.Coll)
+		return []binlog.DataEvent{event}, nil
+
+	case "rename":
+		if !s.filter.match(ev.To.DB, ev.To.Coll) {
+			// renamed out of the replicated collections
+			return s.dataEvents(&changeEvent{OperationType: "drop", Ns: ev.Ns})
+		}
+		if !s.filter.match(ev.Ns.DB, ev.Ns.Coll) {
+			return nil, fmt.Errorf("collection %v.%v is renamed into the replicated %v.%v, whose documents can not be copied",
+				ev.Ns.DB, ev.Ns.Coll, ev.To.DB, ev.To.Coll)
+		}
+		_, events := s.table(ev.Ns.DB, ev.Ns.Coll)
+		if !s.dbs[ev.To.DB] {
+			events = append(events, queryEvent(ev.To.DB, "", createDatabaseQuery(ev.To.DB)))
+			s.dbs[ev.To.DB] = true
+		}
+		table := newTable(ev.To.DB, ev.To.Coll)
+		drop := queryEvent(ev.To.DB, ev.To.Coll, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s",
+			usql.EscapeName(ev.To.DB), usql.EscapeName(ev.To.Coll)))
+		drop.Table = table
+		rename := queryEvent(ev.Ns.DB, ev.Ns.Coll, fmt.Sprintf("RENAME TABLE %s.%s TO %s.%s",
+			usql.EscapeName(ev.Ns.DB), usql.EscapeName(ev.Ns.Coll), usql.EscapeName(ev.To.DB), usql.EscapeName(ev.To.Coll)))
+		rename.Table = table
+		delete(s.tables, ev.Ns.DB+"."+ev.Ns.Coll)
+		s.tables[ev.To.DB+"."+ev.To.Coll] = table
+		return append(events, drop, rename), nil
+
+	case "dropDatabase":
+		for key, table := range s.tables {
+			if table.TableSchema == ev.Ns.DB {
+				delete(s.tables, key)
+			}
+		}
+		if colls := s.filter.dbs[ev.Ns.DB]; len(colls) > 0 {
+			// the other tables of the database are not ours to drop
+			var events []binlog.DataEvent
+			for _, coll := range colls {
+				dropped, _ := s.dataEvents(&changeEvent{OperationType: "drop", Ns: namespace{DB: ev.Ns.DB, Coll: coll}})
+				events = append(events, dropped...)
+			}
+			return events, nil
+		}
+		delete(s.dbs, ev.Ns.DB)
+		return []binlog.DataEvent{queryEvent(ev.Ns.DB, "", fmt.Sprintf("DROP DATABASE IF EXISTS %s", usql.EscapeName(ev.Ns.DB)))}, nil
+
+	case "invalidate":
+		return nil, fmt.Errorf("the change stream is invalidated")
+	default:
+		return nil, nil
+	}
+}
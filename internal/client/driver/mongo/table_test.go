@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func queries(events []binlog.DataEvent) []string {
+	var result []string
+	for _, event := range events {
+		if event.DML == binlog.NotDML {
+			result = append(result, event.Query)
+		} else {
+			result = append(result, string(event.DML))
+		}
+	}
+	return result
+}
+
+func values(cv interface{ GetAbstractValues() []*interface{} }) []interface{} {
+	var result []interface{}
+	for _, v := range cv.GetAbstractValues() {
+		result = append(result, *v)
+	}
+	return result
+}
+
+func rawDoc(t *testing.T, d bson.D) bson.Raw {
+	bs, err := bson.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+func TestSchemaTracker_dataEvents(t *testing.T) {
+	s := newSchemaTracker(newFilter(nil))
+	id, err := primitive.ObjectIDFromHex("5b3c6e1f8e9d2a0001a1b2c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := rawDoc(t, bson.D{{Key: "_id", Value: id}, {Key: "a", Value: 1}})
+
+	events, err := s.dataEvents(&changeEvent{
+		OperationType: "insert",
+		Ns:            namespace{DB: "db1", Coll: "c1"},
+		DocumentKey: struct {
+			ID bson.RawValue `bson:"_id"`
+		}{ID: doc.Lookup("_id")},
+		FullDocument: doc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE DATABASE IF NOT EXISTS `db1`",
+		"CREATE TABLE IF NOT EXISTS `db1`.`c1` (`_id` varchar(255) NOT NULL, `doc` json, PRIMARY KEY (`_id`))",
+		"Insert",
+	}
+	if got := queries(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("insert events = %v, want %v", got, want)
+	}
+	wantValues := []interface{}{"5b3c6e1f8e9d2a0001a1b2c3", `{"_id":{"$oid":"5b3c6e1f8e9d2a0001a1b2c3"},"a":1}`}
+	if got := values(events[2].NewColumnValues); !reflect.DeepEqual(got, wantValues) {
+		t.Errorf("insert values = %v, want %v", got, wantValues)
+	}
+
+	doc = rawDoc(t, bson.D{{Key: "_id", Value: "k"}})
+	events, err = s.dataEvents(&changeEvent{
+		OperationType: "update",
+		Ns:            namespace{DB: "db1", Coll: "c1"},
+		DocumentKey: struct {
+			ID bson.RawValue `bson:"_id"`
+		}{ID: doc.Lookup("_id")},
+		FullDocument: doc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := queries(events); !reflect.DeepEqual(got, []string{"Update"}) {
+		t.Fatalf("update events = %v", got)
+	}
+	if got := values(events[0].WhereColumnValues); !reflect.DeepEqual(got, []interface{}{"k", nil}) {
+		t.Errorf("update where values = %v", got)
+	}
+
+	events, err = s.dataEvents(&changeEvent{
+		OperationType: "delete",
+		Ns:            namespace{DB: "db1", Coll: "c2"},
+		DocumentKey: struct {
+			ID bson.RawValue `bson:"_id"`
+		}{ID: rawDoc(t, bson.D{{Key: "_id", Value: 3}}).Lookup("_id")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"CREATE TABLE IF NOT EXISTS `db1`.`c2` (`_id` varchar(255) NOT NULL, `doc` json, PRIMARY KEY (`_id`))",
+		"Delete",
+	}
+	if got := queries(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("delete events = %v, want %v", got, want)
+	}
+	if got := values(events[1].WhereColumnValues); !reflect.DeepEqual(got, []interface{}{"3", nil}) {
+		t.Errorf("delete where values = %v", got)
+	}
+
+	events, err = s.dataEvents(&changeEvent{
+		OperationType: "rename",
+		Ns:            namespace{DB: "db1", Coll: "c1"},
+		To:            namespace{DB: "db2", Coll: "c3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"CREATE DATABASE IF NOT EXISTS `db2`",
+		"DROP TABLE IF EXISTS `db2`.`c3`",
+		"RENAME TABLE `db1`.`c1` TO `db2`.`c3`",
+	}
+	if got := queries(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rename events = %v, want %v", got, want)
+	}
+	if _, events := s.table("db2", "c3"); len(events) != 0 {
+		t.Errorf("the renamed table is created again")
+	}
+}
+
+func TestIdString(t *testing.T) {
+	id, err := primitive.ObjectIDFromHex("5b3c6e1f8e9d2a0001a1b2c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id   interface{}
+		want string
+	}{
+		{id, "5b3c6e1f8e9d2a0001a1b2c3"},
+		{"k", "k"},
+		{int32(3), "3"},
+		{int64(1) << 40, "1099511627776"},
+		{1.5, "1.5"},
+		{bson.D{{Key: "a", Value: 1}, {Key: "b", Value: "x"}}, `{"a":1,"b":"x"}`},
+	}
+	for _, tt := range tests {
+		got, err := idString(rawDoc(t, bson.D{{Key: "_id", Value: tt.id}}).Lookup("_id"))
+		if err != nil {
+			t.Fatalf("idString(%v) error = %v", tt.id, err)
+		}
+		if got != tt.want {
+			t.Errorf("idString(%v) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaTracker_dropDatabase(t *testing.T) {
+	s := newSchemaTracker(newFilter([]*config.DataSource{
+		{TableSchema: "db1", Tables: []*config.Table{{TableName: "c1"}}},
+		{TableSchema: "db2"},
+	}))
+
+	events, err := s.dataEvents(&changeEvent{OperationType: "dropDatabase", Ns: namespace{DB: "db1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queries(events), []string{"DROP TABLE IF EXISTS `db1`.`c1`"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dropDatabase events = %v, want %v", got, want)
+	}
+
+	events, err = s.dataEvents(&changeEvent{OperationType: "dropDatabase", Ns: namespace{DB: "db2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queries(events), []string{"DROP DATABASE IF EXISTS `db2`"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dropDatabase events = %v, want %v", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	all := newFilter(nil)
+	some := newFilter([]*config.DataSource{
+		{TableSchema: "db1", Tables: []*config.Table{{TableName: "c1"}}},
+		{TableSchema: "db2"},
+	})
+	tests := []struct {
+		f        *filter
+		db, coll string
+		want     bool
+	}{
+		{all, "db1", "c1", true},
+		{all, "admin", "c1", false},
+		{all, "db1", "system.views", false},
+		{some, "db1", "c1", true},
+		{some, "db1", "c2", false},
+		{some, "db2", "c2", true},
+		{some, "db3", "c1", false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.match(tt.db, tt.coll); got != tt.want {
+			t.Errorf("match(%v, %v) = %v, want %v", tt.db, tt.coll, got, tt.want)
+		}
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/mitchellh/mapstructure"
-
 	"github.com/actiontech/dtle/internal/client/driver/mysql"
 	ubase "github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
@@ -34,7 +32,7 @@ func NewMySQLDriver(ctx *DriverContext) Driver {
 func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
 	var driverConfig config.MySQLDriverConfig
 	reply := &models.TaskValidateResponse{}
-	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+	if err := config.DecodeTaskConfig(task.Config, &driverConfig); err != nil {
 		return reply, err
 	}
 	uri := driverConfig.ConnectionConfig.GetDBUri()
@@ -224,9 +222,27 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 
 func (m *MySQLDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
 	var driverConfig config.MySQLDriverConfig
-	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+	if err := config.DecodeTaskConfig(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.WorkDir = ctx.TaskDir
+	driverConfig.Nats = ctx.Nats
+	driverConfig.ConsulAddr = ctx.ConsulAddr
+	driverConfig.Storage = ctx.Storage
+	key, err := trafficKey(ctx, driverConfig.TrafficKeyVaultPath)
+	if err != nil {
 		return nil, err
 	}
+	driverConfig.TrafficKey = key
+
+	if ctx.Tp == models.JobTypeVerify {
+		v, err := mysql.NewVerifier(ctx.Subject, task.Type, &driverConfig, m.logger)
+		if err != nil {
+			return nil, err
+		}
+		go v.Run()
+		return v, nil
+	}
 
 	switch task.Type {
 	case models.TaskTypeSrc:
@@ -258,3 +274,17 @@ func (m *MySQLDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle,
 
 	return nil, nil
 }
+
+// trafficKey returns the key encrypting the data of the job from the Src task to
+// the Dest task: the key in Vault at vaultPath if set, else the key derived from
+// the nats encrypt_key of the agent, or nil for no encryption.
+func trafficKey(ctx *ExecContext, vaultPath string) ([]byte, error) {
+	if vaultPath != "" {
+		key, err := ctx.Vault.ReadKey(vaultPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the traffic key: %v", err)
+		}
+		return key, nil
+	}
+	return ctx.Nats.TrafficKey(ctx.Subject)
+}
@@ -38,9 +38,11 @@ import (
 	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
 	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/plugins/hooks"
 	"github.com/actiontech/dtle/utils"
 
 	"github.com/satori/go.uuid"
@@ -212,11 +214,24 @@ type Applier struct {
 	dbs                []*sql.Conn
 	db                 *gosql.DB
 	gtidExecuted       base.GtidSet
+	gtidExecutedMutex  sync.Mutex // guards gtidExecuted read by Stats
 	currentCoordinates *models.CurrentCoordinates
 	tableItems         mapSchemaTableItems
 
 	rowCopyComplete     chan bool
 	rowCopyCompleteFlag int64
+	// Set when the full copy has been applied, or there is none.
+	fullCopyCompleteFlag int64
+	// The time the last binlog entry was applied, in unix nanoseconds, and how
+	// many seconds it was then behind the source.
+	lastApplyTime int64
+	applyLag      int64
+	// The times, in unix nanoseconds, the first chunk of the full copy was
+	// received, the full copy was applied and the first binlog entry was
+	// applied, 0 if not yet. For the stats of bench jobs.
+	fullCopyStartTime int64
+	fullCopyEndTime   int64
+	firstApplyTime    int64
 	// copyRowsQueue should not be buffered; if buffered some non-damaging but
 	//  excessive work happens at the end of the iteration as new copy-jobs arrive befroe realizing the copy is complete
 	copyRowsQueue           chan *DumpEntry
@@ -225,11 +240,17 @@ type Applier struct {
 	applyBinlogGroupTxQueue chan []*binlog.BinlogTx
 	// only TX can be executed should be put into this chan
 	applyBinlogMtsTxQueue chan *binlog.BinlogEntry
-	lastAppliedBinlogTx   *binlog.BinlogTx
-
-	natsConn *gonats.Conn
-	waitCh   chan *models.WaitResult
-	wg       sync.WaitGroup
+	// The entries of a partitioned stream, by worker. See applyPartitionedEntry.
+	partitionQueues     []chan *binlog.BinlogEntry
+	lastAppliedBinlogTx *binlog.BinlogTx
+
+	natsConn  *gonats.Conn
+	subs      []*gonats.Subscription
+	subsLock  sync.Mutex
+	transport *Transport
+	cipher    *TrafficCipher
+	waitCh    chan *models.WaitResult
+	wg        sync.WaitGroup
 
 	shutdown     bool
 	shutdownCh   chan struct{}
@@ -241,6 +262,32 @@ type Applier struct {
 	nDumpEntry     int64
 
 	stubFullApplyDelay bool
+	faults             *faultInjector
+
+	auditor      *SqlAuditor
+	dumpExporter *dumpExporter
+	xbstream     *xbstreamReceiver
+
+	// Statements of each table to add its secondary indexes after the full copy.
+	deferredSQL      [][]string
+	deferredSQLMutex sync.Mutex
+
+	// SET of the session variables for the full copy and the incremental changes.
+	fullCopySessionQuery string
+	incrSessionQuery     string
+
+	// The events applied of the transactions split and not completely applied,
+	// by txnProgressKey. See txn_progress.go.
+	txnProgress      map[string]int
+	txnProgressMutex sync.Mutex
+	// The write conflicts found with RowImageCheck. See row_image.go.
+	writeConflicts      models.WriteConflicts
+	writeConflictsMutex sync.Mutex
+	// The statements around the load of each table in the full copy, nil if
+	// none. See table_hooks.go.
+	tableHooks *tableLoadHooks
+	// Keeps the target read_only with TargetReadOnly, nil if off.
+	targetReadOnly *targetReadOnlyGuard
 }
 
 func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.Logger) (*Applier, error) {
@@ -254,6 +301,63 @@ func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.L
 		return nil, err
 	}
 
+	switch cfg.TargetType {
+	case config.TargetTypeMySQL, config.TargetTypeTiDB:
+	default:
+		return nil, fmt.Errorf("unknown TargetType %v", cfg.TargetType)
+	}
+	switch cfg.ColumnMismatch {
+	case config.ColumnMismatchError, config.ColumnMismatchIgnoreExtra, config.ColumnMismatchFillDefaults:
+	default:
+		return nil, fmt.Errorf("unknown ColumnMismatch %v", cfg.ColumnMismatch)
+	}
+	switch cfg.FullCopyConflict {
+	case config.FullCopyConflictReplace, config.FullCopyConflictIgnore, config.FullCopyConflictInsert:
+	default:
+		return nil, fmt.Errorf("unknown FullCopyConflict %v", cfg.FullCopyConflict)
+	}
+	switch cfg.RowImageCheck {
+	case config.RowImageCheckOff, config.RowImageCheckColumns, config.RowImageCheckChecksum:
+	default:
+		return nil, fmt.Errorf("unknown RowImageCheck %v", cfg.RowImageCheck)
+	}
+	switch cfg.RowImageConflict {
+	case config.RowImageConflictApply, config.RowImageConflictError:
+	default:
+		return nil, fmt.Errorf("unknown RowImageConflict %v", cfg.RowImageConflict)
+	}
+	switch cfg.TargetReadOnly {
+	case config.TargetReadOnlyOff, config.TargetReadOnlyAlert, config.TargetReadOnlyPause:
+	default:
+		return nil, fmt.Errorf("unknown TargetReadOnly %v", cfg.TargetReadOnly)
+	}
+	if cfg.TargetReadOnly != config.TargetReadOnlyOff && cfg.IsTiDB() {
+		return nil, fmt.Errorf("TargetReadOnly is not supported with TiDB")
+	}
+	tableHooks, err := newTableLoadHooks(cfg.FullCopyBeforeTableSQL, cfg.FullCopyAfterTableSQL, cfg.AnsiQuotes)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := NewTrafficCipher(cfg.TrafficKey)
+	if err != nil {
+		return nil, err
+	}
+	fullCopySessionQuery, err := buildSessionQuery(defaultFullCopySessionVariables, cfg.FullCopySessionVariables)
+	if err != nil {
+		return nil, fmt.Errorf("FullCopySessionVariables: %v", err)
+	}
+	incrSessionQuery, err := buildSessionQuery(defaultIncrSessionVariables, cfg.IncrSessionVariables)
+	if err != nil {
+		return nil, fmt.Errorf("IncrSessionVariables: %v", err)
+	}
+	faults, err := parseFaults(os.Getenv(g.ENV_FAULTS))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", g.ENV_FAULTS, err)
+	}
+	if faults != nil {
+		entry.Warnf("mysql.applier: Injecting faults: %v", faults)
+	}
+
 	a := &Applier{
 		logger:                  entry,
 		subject:                 subject,
@@ -262,6 +366,7 @@ func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.L
 		mysqlContext:            cfg,
 		currentCoordinates:      &models.CurrentCoordinates{},
 		tableItems:              make(mapSchemaTableItems),
+		txnProgress:             make(map[string]int),
 		rowCopyComplete:         make(chan bool, 1),
 		copyRowsQueue:           make(chan *DumpEntry, 24),
 		applyDataEntryQueue:     make(chan *binlog.BinlogEntry, cfg.ReplChanBufferSize*2),
@@ -269,9 +374,15 @@ func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.L
 		applyBinlogTxQueue:      make(chan *binlog.BinlogTx, cfg.ReplChanBufferSize*2),
 		applyBinlogGroupTxQueue: make(chan []*binlog.BinlogTx, cfg.ReplChanBufferSize*2),
 		waitCh:                  make(chan *models.WaitResult, 1),
+		transport:               NewTransport(),
+		cipher:                  cipher,
+		tableHooks:              tableHooks,
 		shutdownCh:              make(chan struct{}),
 		printTps:                os.Getenv(g.ENV_PRINT_TPS) != "",
 		stubFullApplyDelay:      os.Getenv(g.ENV_FULL_APPLY_DELAY) != "",
+		faults:                  faults,
+		fullCopySessionQuery:    fullCopySessionQuery,
+		incrSessionQuery:        incrSessionQuery,
 	}
 	a.mtsManager = NewMtsManager(a.shutdownCh)
 	go a.mtsManager.LcUpdater()
@@ -279,12 +390,26 @@ func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.L
 }
 
 func (a *Applier) MtsWorker(workerIndex int) {
+	defer crash.HandlePanic()
 	keepLoop := true
 
 	for keepLoop {
 		timer := time.NewTimer(pingInterval)
+		var tx *binlog.BinlogEntry
 		select {
-		case tx := <-a.applyBinlogMtsTxQueue:
+		case tx = <-a.applyBinlogMtsTxQueue:
+		case tx = <-a.partitionQueues[workerIndex]:
+		case <-a.shutdownCh:
+			keepLoop = false
+		case <-timer.C:
+			err := a.dbs[workerIndex].Db.PingContext(context.Background())
+			if err != nil {
+				a.logger.Errorf("mysql.applier. bad connection for mts worker. workerIndex: %v, err: %v",
+					workerIndex, err)
+			}
+		}
+		timer.Stop()
+		if tx != nil {
 			a.logger.Debugf("mysql.applier: a binlogEntry MTS dequeue, worker: %v. GNO: %v",
 				workerIndex, tx.Coordinates.GNO)
 			if err := a.ApplyBinlogEvent(workerIndex, tx); err != nil {
@@ -295,16 +420,7 @@ func (a *Applier) MtsWorker(workerIndex int) {
 			}
 			a.logger.Debugf("mysql.applier: worker: %v. after ApplyBinlogEvent. GNO: %v",
 				workerIndex, tx.Coordinates.GNO)
-		case <-a.shutdownCh:
-			keepLoop = false
-		case <-timer.C:
-			err := a.dbs[workerIndex].Db.PingContext(context.Background())
-			if err != nil {
-				a.logger.Errorf("mysql.applier. bad connection for mts worker. workerIndex: %v, err: %v",
-					workerIndex, err)
-			}
 		}
-		timer.Stop()
 	}
 }
 
@@ -322,20 +438,72 @@ func (a *Applier) Run() {
 
 	a.logger.Printf("mysql.applier: Apply binlog events to %s.%d", a.mysqlContext.ConnectionConfig.Host, a.mysqlContext.ConnectionConfig.Port)
 	a.mysqlContext.StartTime = time.Now()
+	if a.mysqlContext.SqlAuditFile != "" {
+		var err error
+		a.auditor, err = NewSqlAuditor(a.subject, a.mysqlContext.SqlAuditFile)
+		if err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+	}
 	if err := a.initDBConnections(); err != nil {
 		a.onError(TaskStateDead, err)
 		return
 	}
+	if a.mysqlContext.ApproveHeterogeneous {
+		if err := a.readCheckpoint(); err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+	}
+	if a.mysqlContext.DumpExportStorage && a.mysqlContext.DumpExportDir == "" {
+		a.mysqlContext.DumpExportDir = defaultDumpExportDir(a.mysqlContext.WorkDir)
+	}
+	if a.mysqlContext.DumpExportDir != "" && a.mysqlContext.Gtid == "" {
+		if err := checkDumpExportMethod(a.mysqlContext.FullCopyMethod); err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+		var err error
+		a.dumpExporter, err = newDumpExporter(a.mysqlContext.DumpExportDir)
+		if err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+		if a.mysqlContext.DumpExportStorage {
+			if err := a.dumpExporter.OpenStorage(a.mysqlContext.Storage); err != nil {
+				a.onError(TaskStateDead, err)
+				return
+			}
+		}
+	}
+	if a.mysqlContext.FullCopyMethod == config.FullCopyMethodXtrabackup && a.mysqlContext.Gtid == "" {
+		var err error
+		a.xbstream, err = newXbstreamReceiver(a.mysqlContext, a.mysqlContext.XtrabackupDir)
+		if err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+	}
 	if err := a.initNatSubClient(); err != nil {
 		a.onError(TaskStateDead, err)
 		return
 	}
+	if a.mysqlContext.ApproveHeterogeneous {
+		if err := a.serveCheckpoint(); err != nil {
+			a.onError(TaskStateDead, err)
+			return
+		}
+	}
 
 	if err := a.initiateStreaming(); err != nil {
 		a.onError(TaskStateDead, err)
 		return
 	}
 
+	if a.targetReadOnly != nil {
+		go a.targetReadOnly.Run(a.shutdownCh)
+	}
 	for i := 0; i < a.mysqlContext.ParallelWorkers; i++ {
 		go a.MtsWorker(i)
 	}
@@ -403,14 +571,34 @@ func (a *Applier) onApplyTxStructWithSuper(dbApplier *sql.Conn, binlogTx *binlog
 func (a *Applier) executeWriteFuncs() {
 	if a.mysqlContext.Gtid == "" {
 		go func() {
+			defer crash.HandlePanic()
 			var stopLoop = false
 			for !stopLoop {
 				select {
 				case copyRows := <-a.copyRowsQueue:
-					if nil != copyRows {
+					if nil != copyRows && copyRows.XbstreamData != nil {
+						if a.xbstream == nil {
+							a.onError(TaskStateDead, fmt.Errorf("got xbstream data but FullCopyMethod of Dest is not xtrabackup"))
+						} else if err := a.xbstream.Write(copyRows.XbstreamData); err != nil {
+							a.onError(TaskStateDead, err)
+						}
+						atomic.AddInt64(&a.mysqlContext.TotalRowsReplay, copyRows.RowsCount)
+					} else if nil != copyRows && copyRows.OutfilePath != "" && a.dumpExporter != nil {
+						// FullCopyMethod set on Src only. Fail before the file is loaded and removed.
+						a.onError(TaskStateDead, checkDumpExportMethod(config.FullCopyMethodOutfile))
+					} else if nil != copyRows {
 						//time.Sleep(20 * time.Second) // #348 stub
 						if err := a.ApplyEventQueries(a.db, copyRows); err != nil {
 							a.onError(TaskStateDead, err)
+						} else {
+							if copyRows.OutfilePath != "" {
+								a.removeOutfile(copyRows)
+							}
+							if a.dumpExporter != nil {
+								if err := a.dumpExporter.WriteEntry(copyRows); err != nil {
+									a.onError(TaskStateDead, err)
+								}
+							}
 						}
 					}
 					if atomic.LoadInt64(&a.nDumpEntry) < 0 {
@@ -436,6 +624,28 @@ func (a *Applier) executeWriteFuncs() {
 			if atomic.LoadInt64(&a.rowCopyCompleteFlag) == 1 && a.mysqlContext.TotalRowsCopied == a.mysqlContext.TotalRowsReplay {
 				a.rowCopyComplete <- true
 				a.logger.Printf("mysql.applier: Rows copy complete.number of rows:%d", a.mysqlContext.TotalRowsReplay)
+				if a.xbstream != nil {
+					if err := a.finishXtrabackup(); err != nil {
+						a.onError(TaskStateDead, err)
+						return
+					}
+				}
+				if a.tableHooks != nil {
+					if err := a.runTableHooks(a.tableHooks.onComplete()); err != nil {
+						a.onError(TaskStateDead, err)
+						return
+					}
+				}
+				if err := a.execDeferredSQL(); err != nil {
+					a.onError(TaskStateDead, err)
+					return
+				}
+				if a.mysqlContext.ApproveHeterogeneous {
+					if err := a.saveCheckpoint(a.currentCoordinates.RetrievedGtidSet); err != nil {
+						a.onError(TaskStateDead, err)
+						return
+					}
+				}
 				a.mysqlContext.Gtid = a.currentCoordinates.RetrievedGtidSet
 				break
 			}
@@ -445,6 +655,10 @@ func (a *Applier) executeWriteFuncs() {
 			time.Sleep(time.Second)
 		}
 	}
+	if atomic.LoadInt64(&a.fullCopyStartTime) != 0 {
+		atomic.StoreInt64(&a.fullCopyEndTime, time.Now().UnixNano())
+	}
+	atomic.StoreInt64(&a.fullCopyCompleteFlag, 1)
 
 	var dbApplier *sql.Conn
 
@@ -481,8 +695,8 @@ func (a *Applier) executeWriteFuncs() {
 }
 
 func (a *Applier) initNatSubClient() (err error) {
-	natsAddr := fmt.Sprintf("nats://%s", a.mysqlContext.NatsAddr)
-	sc, err := gonats.Connect(natsAddr)
+	natsAddr := config.NatsURL(a.mysqlContext.NatsAddr)
+	sc, err := config.NatsConnect(a.mysqlContext.NatsAddr, a.mysqlContext.Nats, a.transport.ErrorHandler())
 	if err != nil {
 		a.logger.Errorf("mysql.applier: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
 		return err
@@ -565,6 +779,7 @@ func (a *Applier) cleanGtidExecuted(sid uuid.UUID, intervalStr string) error {
 }
 
 func (a *Applier) heterogeneousReplay() {
+	defer crash.HandlePanic()
 	var err error
 	stopSomeLoop := false
 	prevDDL := false
@@ -586,12 +801,38 @@ func (a *Applier) heterogeneousReplay() {
 
 			// region TestIfExecuted
 			if a.gtidExecuted == nil {
+				// The checkpoint of the full copy is saved once it is applied.
+				for atomic.LoadInt64(&a.fullCopyCompleteFlag) == 0 {
+					if a.shutdown {
+						return
+					}
+					time.Sleep(100 * time.Millisecond)
+				}
 				// udup crash recovery or never executed
-				a.gtidExecuted, err = base.SelectAllGtidExecuted(a.db, a.subjectUUID)
+				gtidExecuted, err := base.SelectAllGtidExecuted(a.db, a.subjectUUID)
 				if err != nil {
 					a.onError(TaskStateDead, err)
 					return
 				}
+				a.gtidExecutedMutex.Lock()
+				a.gtidExecuted = gtidExecuted
+				a.gtidExecutedMutex.Unlock()
+			}
+
+			if binlogEntry.Resync {
+				// In order with the transactions, but without a gtid.
+				if !a.mtsManager.WaitForAllCommitted() {
+					return // shutdown
+				}
+				if err := a.setTableItemForBinlogEntry(binlogEntry); err != nil {
+					a.onError(TaskStateDead, err)
+					return
+				}
+				if err := a.ApplyBinlogEvent(0, binlogEntry); err != nil {
+					a.onError(TaskStateDead, err)
+					return
+				}
+				continue
 			}
 
 			txSid := binlogEntry.Coordinates.GetSid()
@@ -599,7 +840,9 @@ func (a *Applier) heterogeneousReplay() {
 			gtidSetItem, hasSid := a.gtidExecuted[binlogEntry.Coordinates.SID]
 			if !hasSid {
 				gtidSetItem = &base.GtidExecutedItem{}
+				a.gtidExecutedMutex.Lock()
 				a.gtidExecuted[binlogEntry.Coordinates.SID] = gtidSetItem
+				a.gtidExecutedMutex.Unlock()
 			}
 			if base.IntervalSlicesContainOne(gtidSetItem.Intervals, binlogEntry.Coordinates.GNO) {
 				// entry executed
@@ -633,9 +876,20 @@ func (a *Applier) heterogeneousReplay() {
 			// TODO normalize may affect oringinal intervals
 			newInterval := append(gtidSetItem.Intervals, thisInterval).Normalize()
 			// TODO this is assigned before real execution
+			a.gtidExecutedMutex.Lock()
 			gtidSetItem.Intervals = newInterval
+			a.gtidExecutedMutex.Unlock()
 
-			if binlogEntry.Coordinates.SeqenceNumber == 0 {
+			if binlogEntry.Partition != 0 {
+				if a.mysqlContext.StrictTransactionBoundaries {
+					// The partitions arrive interleaved, out of the order of the source.
+					a.onError(TaskStateDead, fmt.Errorf("StrictTransactionBoundaries is not supported with IncrSubjectPartitions of Src"))
+					return
+				}
+				if !a.applyPartitionedEntry(binlogEntry) {
+					return
+				}
+			} else if binlogEntry.Coordinates.SeqenceNumber == 0 {
 				// MySQL 5.6: non mts
 				err := a.setTableItemForBinlogEntry(binlogEntry)
 				if err != nil {
@@ -684,6 +938,11 @@ func (a *Applier) heterogeneousReplay() {
 					if !a.mtsManager.WaitForAllCommitted() {
 						return // shutdown
 					}
+				} else if a.mysqlContext.StrictTransactionBoundaries {
+					// Each transaction is committed before the next is applied.
+					if !a.mtsManager.WaitForAllCommitted() {
+						return // shutdown
+					}
 				}
 
 				if hasDDL {
@@ -716,6 +975,7 @@ func (a *Applier) heterogeneousReplay() {
 	}
 }
 func (a *Applier) homogeneousReplay() {
+	defer crash.HandlePanic()
 	var lastCommitted int64
 	var err error
 	//timeout := time.After(100 * time.Millisecond)
@@ -768,7 +1028,7 @@ func (a *Applier) initiateStreaming() error {
 	if a.mysqlContext.Gtid == "" {
 		a.mysqlContext.MarkRowCopyStartTime()
 		a.logger.Debugf("mysql.applier: nats subscribe")
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_full", a.subject), func(m *gonats.Msg) {
+		_, err := a.subscribe(fmt.Sprintf("%s_full", a.subject), func(m *gonats.Msg) {
 			a.logger.Debugf("mysql.applier: full. recv a msg. copyRowsQueue: %v", len(a.copyRowsQueue))
 
 			dumpData := &DumpEntry{}
@@ -776,6 +1036,7 @@ func (a *Applier) initiateStreaming() error {
 				a.onError(TaskStateDead, err)
 			}
 
+			atomic.CompareAndSwapInt64(&a.fullCopyStartTime, 0, time.Now().UnixNano())
 			timer := time.NewTimer(DefaultConnectWait / 2)
 			atomic.AddInt64(&a.nDumpEntry, 1) // this must be increased before enqueuing
 			select {
@@ -792,6 +1053,7 @@ func (a *Applier) initiateStreaming() error {
 				atomic.AddInt64(&a.nDumpEntry, -1)
 
 				a.logger.Debugf("mysql.applier. full. discarding entries")
+				a.transport.SlowConsumer()
 				a.mysqlContext.Stage = models.StageSlaveWaitingForWorkersToProcessQueue
 			}
 		})
@@ -799,7 +1061,7 @@ func (a *Applier) initiateStreaming() error {
 			return err
 		}*/
 
-		_, err = a.natsConn.Subscribe(fmt.Sprintf("%s_full_complete", a.subject), func(m *gonats.Msg) {
+		_, err = a.subscribe(fmt.Sprintf("%s_full_complete", a.subject), func(m *gonats.Msg) {
 			dumpData := &dumpStatResult{}
 			if err := Decode(m.Data, dumpData); err != nil {
 				a.onError(TaskStateDead, err)
@@ -815,6 +1077,21 @@ func (a *Applier) initiateStreaming() error {
 				}
 			}
 
+			if a.dumpExporter != nil {
+				if err := a.dumpExporter.WriteMetadata(dumpData.Gtid); err != nil {
+					a.onError(TaskStateDead, err)
+				}
+				if digest, err := a.dumpExporter.WriteManifest(dumpData.Gtid); err != nil {
+					a.onError(TaskStateDead, err)
+				} else {
+					a.logger.Printf("mysql.applier: Exported the full copy to %v, the SHA-256 of its %v is %v",
+						a.mysqlContext.DumpExportDir, DumpManifestFileName, digest)
+					if a.mysqlContext.DumpExportStorage {
+						go a.uploadDumpExport()
+					}
+				}
+			}
+
 			a.logger.Debugf("mysql.applier. ack full_complete")
 			if err := a.natsConn.Publish(m.Reply, nil); err != nil {
 				a.onError(TaskStateDead, err)
@@ -828,7 +1105,7 @@ func (a *Applier) initiateStreaming() error {
 	}
 
 	if a.mysqlContext.ApproveHeterogeneous {
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr_hete", a.subject), func(m *gonats.Msg) {
+		handler := func(m *gonats.Msg) {
 			var binlogEntries binlog.BinlogEntries
 			if err := Decode(m.Data, &binlogEntries); err != nil {
 				a.onError(TaskStateDead, err)
@@ -863,16 +1140,21 @@ func (a *Applier) initiateStreaming() error {
 			if !handled {
 				// discard these entries
 				a.logger.Debugf("applier. incr. discarding entries")
+				a.transport.SlowConsumer()
 				a.mysqlContext.Stage = models.StageWaitingForMasterToSendEvent
 			}
-		})
-		if err != nil {
+		}
+		if _, err := a.subscribe(fmt.Sprintf("%s_incr_hete", a.subject), handler); err != nil {
+			return err
+		}
+		// The partitions of the stream, with IncrSubjectPartitions of Src.
+		if _, err := a.subscribe(fmt.Sprintf("%s_incr_hete.*", a.subject), handler); err != nil {
 			return err
 		}
 
 		go a.heterogeneousReplay()
 	} else {
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr", a.subject), func(m *gonats.Msg) {
+		_, err := a.subscribe(fmt.Sprintf("%s_incr", a.subject), func(m *gonats.Msg) {
 			var binlogTx []*binlog.BinlogTx
 			if err := Decode(m.Data, &binlogTx); err != nil {
 				a.onError(TaskStateDead, err)
@@ -906,11 +1188,26 @@ func (a *Applier) initDBConnections() (err error) {
 	if a.dbs, err = sql.CreateConns(a.db, a.mysqlContext.ParallelWorkers); err != nil {
 		return err
 	}
+	if err := a.initIncrSessions(); err != nil {
+		return err
+	}
 
 	if err := a.validateConnection(a.db); err != nil {
 		return err
 	}
-	if err := a.validateServerUUID(); err != nil {
+	a.partitionQueues = make([]chan *binlog.BinlogEntry, a.mysqlContext.ParallelWorkers)
+	for i := range a.partitionQueues {
+		a.partitionQueues[i] = make(chan *binlog.BinlogEntry, a.mysqlContext.ReplChanBufferSize)
+	}
+	if a.mysqlContext.StrictTransactionBoundaries {
+		a.logger.Printf("mysql.applier: Strict transaction boundaries, the transactions are applied one at a time")
+	}
+	if a.mysqlContext.IsTiDB() {
+		// TiDB has no server uuid, nor gtid of its own.
+		if !a.mysqlContext.StrictTransactionBoundaries {
+			a.logger.Printf("mysql.applier: Target is TiDB, split transactions by %v rows", a.mysqlContext.TxnSplitSize)
+		}
+	} else if err := a.validateServerUUID(); err != nil {
 		return err
 	}
 	if err := a.validateGrants(); err != nil {
@@ -922,6 +1219,17 @@ func (a *Applier) initDBConnections() (err error) {
 		return err
 	}
 	a.logger.Debugf("mysql.applier. after validateAndReadTimeZone")
+	if a.mysqlContext.TargetReadOnly != config.TargetReadOnlyOff {
+		a.targetReadOnly = newTargetReadOnlyGuard(a.mysqlContext.TargetReadOnly == config.TargetReadOnlyPause,
+			func() error {
+				return setTargetReadOnly(a.db)
+			}, func() (bool, bool, error) {
+				return showTargetReadOnly(a.db)
+			}, a.logger)
+		if err := a.targetReadOnly.Enable(); err != nil {
+			return fmt.Errorf("TargetReadOnly: %v", err)
+		}
+	}
 
 	if a.mysqlContext.ApproveHeterogeneous {
 		if err := a.createTableGtidExecutedV3(); err != nil {
@@ -946,6 +1254,21 @@ func (a *Applier) initDBConnections() (err error) {
 
 		}
 		a.logger.Debugf("mysql.applier. after prepare stmt for gtid_executed table")
+		if err := a.createTableCopiedTables(); err != nil {
+			return err
+		}
+		if err := a.createTableOnlineSchemaChanges(); err != nil {
+			return err
+		}
+
+		if a.mysqlContext.IsTiDB() && !a.mysqlContext.StrictTransactionBoundaries {
+			if err := a.createTableTxnProgress(); err != nil {
+				return err
+			}
+			if err := a.readTxnProgress(); err != nil {
+				return err
+			}
+		}
 	}
 	/*if err := a.readCurrentBinlogCoordinates(); err != nil {
 		return err
@@ -1152,10 +1475,14 @@ func (a *Applier) getTableItem(schema string, table string) *applierTableItem {
 
 // buildDMLEventQuery creates a query to operate on the ghost table, based on an intercepted binlog
 // event entry on the original table.
-func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (query *gosql.Stmt, args []interface{}, rowsDelta int64, err error) {
+func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (stmt *gosql.Stmt, query string, args []interface{}, rowsDelta int64, err error) {
 	// Large piece of code deleted here. See git annotate.
 	tableItem := dmlEvent.TableItem.(*applierTableItem)
-	var tableColumns = tableItem.columns
+	// The columns of the target might be in another order, or differ.
+	tableColumns, err := dmlEvent.MapColumns(tableItem.columns, a.mysqlContext.ColumnMismatch)
+	if err != nil {
+		return nil, "", nil, -1, err
+	}
 
 	doPrepareIfNil := func(stmts []*gosql.Stmt, query string) (*gosql.Stmt, error) {
 		var err error
@@ -1172,77 +1499,114 @@ func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (
 	switch dmlEvent.DML {
 	case binlog.DeleteDML:
 		{
-			query, uniqueKeyArgs, err := sql.BuildDMLDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, dmlEvent.WhereColumnValues.GetAbstractValues())
+			query, uniqueKeyArgs, err := sql.BuildDMLDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, dmlEvent.WhereColumnValues.GetAbstractValues(), a.mysqlContext.AnsiQuotes)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
 			stmt, err := doPrepareIfNil(tableItem.psDelete, query)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
-			return stmt, uniqueKeyArgs, -1, err
+			return stmt, query, uniqueKeyArgs, -1, err
 		}
 	case binlog.InsertDML:
 		{
 			// TODO no need to generate query string every time
-			query, sharedArgs, err := sql.BuildDMLInsertQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, tableColumns, tableColumns, dmlEvent.NewColumnValues.GetAbstractValues())
+			query, sharedArgs, err := sql.BuildDMLInsertQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, tableColumns, tableColumns, dmlEvent.NewColumnValues.GetAbstractValues(), a.mysqlContext.AnsiQuotes)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
 			stmt, err := doPrepareIfNil(tableItem.psInsert, query)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
-			return stmt, sharedArgs, 1, err
+			return stmt, query, sharedArgs, 1, err
 		}
 	case binlog.UpdateDML:
 		{
-			query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, tableColumns, tableColumns, tableColumns, dmlEvent.NewColumnValues.GetAbstractValues(), dmlEvent.WhereColumnValues.GetAbstractValues())
+			query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, tableColumns, tableColumns, tableColumns, dmlEvent.NewColumnValues.GetAbstractValues(), dmlEvent.WhereColumnValues.GetAbstractValues(), a.mysqlContext.AnsiQuotes)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
 			args = append(args, sharedArgs...)
 			args = append(args, uniqueKeyArgs...)
 
 			stmt, err := doPrepareIfNil(tableItem.psUpdate, query)
 			if err != nil {
-				return nil, nil, -1, err
+				return nil, "", nil, -1, err
 			}
 
-			return stmt, args, 0, err
+			return stmt, query, args, 0, err
 		}
 	}
-	return nil, args, 0, fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)
+	return nil, "", args, 0, fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)
 }
 
 // ApplyEventQueries applies multiple DML queries onto the dest table
 func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntry) error {
+	if a.targetReadOnly != nil {
+		a.targetReadOnly.Wait(a.shutdownCh)
+	}
 	dbApplier := a.dbs[workerIdx]
 
+	dbApplier.DbMutex.Lock()
+	defer dbApplier.DbMutex.Unlock()
+
+	err := a.retryOnTiDBConflict(func() error {
+		return a.applyBinlogEntry(dbApplier, workerIdx, binlogEntry)
+	})
+	if a.printTps {
+		atomic.AddUint32(&a.txLastNSeconds, 1)
+	}
+	if err != nil {
+		return err
+	}
+	if binlogEntry.Resync {
+		return nil
+	}
+	a.mtsManager.Executed(binlogEntry)
+	a.recordApply(binlogEntry)
+	a.callHooks(binlogEntry)
+
+	// no error
+	a.mysqlContext.Stage = models.StageWaitingForGtidToBeCommitted
+	atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, 1)
+	return nil
+}
+
+// applyBinlogEntry applies the binlog entry in a transaction, which is rolled
+// back on error. For TiDB, it is split into transactions of TxnSplitSize row
+// events, the last of which records the gtid, unless StrictTransactionBoundaries.
+// The others record the progress, from which the entry resumes when applied
+// again.
+func (a *Applier) applyBinlogEntry(dbApplier *sql.Conn, workerIdx int, binlogEntry *binlog.BinlogEntry) (err error) {
 	var totalDelta int64
-	var err error
+	var nRows int
+	// recorded to the sql audit file once committed
+	var audits []auditedStatement
 
 	txSid := binlogEntry.Coordinates.GetSid()
 
-	dbApplier.DbMutex.Lock()
+	applied := a.appliedEvents(binlogEntry)
+	if applied > 0 {
+		a.logger.Printf("mysql.applier: Resuming gno %v from event %v of %v, applied by the parts committed",
+			binlogEntry.Coordinates.GNO, applied, len(binlogEntry.Events))
+	}
+
 	tx, err := dbApplier.Db.BeginTx(context.Background(), &gosql.TxOptions{})
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if err := tx.Commit(); err != nil {
-			a.onError(TaskStateDead, err)
-		} else {
-			a.mtsManager.Executed(binlogEntry)
-		}
-		if a.printTps {
-			atomic.AddUint32(&a.txLastNSeconds, 1)
+		if err != nil && tx != nil {
+			tx.Rollback()
 		}
-
-		dbApplier.DbMutex.Unlock()
 	}()
 
 	for i, event := range binlogEntry.Events {
+		if i < applied {
+			continue
+		}
 		a.logger.Debugf("mysql.applier: ApplyBinlogEvent. gno: %v, event: %v",
 			binlogEntry.Coordinates.GNO, i)
 		switch event.DML {
@@ -1251,8 +1615,7 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 			a.logger.Debugf("mysql.applier: ApplyBinlogEvent: not dml: %v", event.Query)
 
 			if event.CurrentSchema != "" {
-				// TODO escape schema name?
-				query := fmt.Sprintf("USE %s", event.CurrentSchema)
+				query := fmt.Sprintf("USE %s", sql.QuoteName(event.CurrentSchema, a.mysqlContext.AnsiQuotes))
 				a.logger.Debugf("mysql.applier: query: %v", query)
 				_, err = tx.Exec(query)
 				if err != nil {
@@ -1263,6 +1626,7 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 						a.logger.Warnf("mysql.applier: Ignore error: %v", err)
 					}
 				}
+				audits = append(audits, auditedStatement{query: query})
 			}
 
 			if event.TableName != "" {
@@ -1286,6 +1650,15 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 				}
 			}
 
+			if event.OnlineSchemaChange != "" {
+				statements, err := a.applyOnlineSchemaChange(tx, &binlogEntry.Events[i])
+				if err != nil {
+					return err
+				}
+				audits = append(audits, statements...)
+				break
+			}
+
 			_, err = tx.Exec(event.Query)
 			if err != nil {
 				if !sql.IgnoreError(err) {
@@ -1295,10 +1668,34 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 					a.logger.Warnf("mysql.applier: Ignore error: %v", err)
 				}
 			}
+			audits = append(audits, auditedStatement{query: event.Query})
 			a.logger.Debugf("mysql.applier: Exec [%s]", event.Query)
 		default:
 			a.logger.Debugf("mysql.applier: ApplyBinlogEvent: a dml event")
-			stmt, args, rowDelta, err := a.buildDMLEventQuery(event, workerIdx)
+			if a.splitsTransaction(nRows) {
+				a.logger.Debugf("mysql.applier: split the transaction of gno %v for TiDB", binlogEntry.Coordinates.GNO)
+				if err := a.saveTxnProgress(tx, dbApplier, binlogEntry, i); err != nil {
+					return err
+				}
+				if err := tx.Commit(); err != nil {
+					return err
+				}
+				a.auditSqls(binlogEntry.Coordinates.GetGtidForThisTx(), audits)
+				audits = nil
+				a.setAppliedEvents(binlogEntry, i)
+				if tx, err = dbApplier.Db.BeginTx(context.Background(), &gosql.TxOptions{}); err != nil {
+					return err
+				}
+				nRows = 0
+			}
+			nRows++
+			if a.mysqlContext.RowImageCheck != config.RowImageCheckOff &&
+				(event.DML == binlog.UpdateDML || event.DML == binlog.DeleteDML) {
+				if err := a.checkRowImage(tx, binlogEntry.Coordinates.GetGtidForThisTx(), event); err != nil {
+					return err
+				}
+			}
+			stmt, query, args, rowDelta, err := a.buildDMLEventQuery(event, workerIdx)
 			if err != nil {
 				a.logger.Errorf("mysql.applier: Build dml query error: %v", err)
 				return err
@@ -1312,6 +1709,7 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 				a.logger.Errorf("mysql.applier: gtid: %s:%d, error: %v", txSid, binlogEntry.Coordinates.GNO, err)
 				return err
 			}
+			audits = append(audits, auditedStatement{query: query, args: args})
 			nr, err := r.RowsAffected()
 			if err != nil {
 				a.logger.Debugf("ApplyBinlogEvent executed gno %v event %v rows_affected_err %v schema", binlogEntry.Coordinates.GNO, i, err)
@@ -1322,15 +1720,34 @@ func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntr
 		}
 	}
 
-	a.logger.Debugf("ApplyBinlogEvent. insert gno: %v", binlogEntry.Coordinates.GNO)
-	_, err = dbApplier.PsInsertExecutedGtid.Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO)
-	if err != nil {
-		return err
+	if a.faults.killApplier() {
+		return fmt.Errorf("fault injected: applier killed before the commit of gno %v", binlogEntry.Coordinates.GNO)
 	}
 
-	// no error
-	a.mysqlContext.Stage = models.StageWaitingForGtidToBeCommitted
-	atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, 1)
+	if !binlogEntry.Resync {
+		a.logger.Debugf("ApplyBinlogEvent. insert gno: %v", binlogEntry.Coordinates.GNO)
+		_, err = dbApplier.PsInsertExecutedGtid.Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO)
+		if err != nil {
+			return err
+		}
+	} else if binlogEntry.CopiedTables != nil {
+		if err = a.saveCopiedTables(tx, binlogEntry.CopiedTables); err != nil {
+			return err
+		}
+	}
+	split := a.appliedEvents(binlogEntry) > 0
+	if split {
+		if err = a.deleteTxnProgress(tx, dbApplier, binlogEntry); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	a.auditSqls(binlogEntry.Coordinates.GetGtidForThisTx(), audits)
+	if split {
+		a.setAppliedEvents(binlogEntry, 0)
+	}
 	return nil
 }
 
@@ -1340,30 +1757,64 @@ func (a *Applier) ApplyEventQueries(db *gosql.DB, entry *DumpEntry) error {
 		time.Sleep(20 * time.Second)
 		a.logger.Debugf("mysql.applier: stubFullApplyDelay end sleep")
 	}
+	a.faults.delayChunk(a.shutdownCh)
+	if a.targetReadOnly != nil {
+		a.targetReadOnly.Wait(a.shutdownCh)
+	}
+	if a.tableHooks != nil {
+		if err := a.runTableHooks(a.tableHooks.onEntry(entry)); err != nil {
+			return err
+		}
+	}
 
-	queries := []string{}
-	queries = append(queries, entry.SystemVariablesStatement, entry.SqlMode, entry.DbSQL)
-	queries = append(queries, entry.TbSQL...)
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+	defer atomic.AddInt64(&a.mysqlContext.TotalRowsReplay, entry.RowsCount)
+	a.addDeferredSQL(entry)
+	if a.mysqlContext.IsTiDB() && a.mysqlContext.TiDBBatchImport {
+		return a.retryOnTiDBConflict(func() error {
+			return a.batchImportEventQueries(db, entry)
+		})
 	}
-	defer func() {
-		if err := tx.Commit(); err != nil {
-			a.onError(TaskStateDead, err)
+	return a.retryOnTiDBConflict(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
 		}
-		atomic.AddInt64(&a.mysqlContext.TotalRowsReplay, entry.RowsCount)
-	}()
-	sessionQuery := `SET @@session.foreign_key_checks = 0`
-	if _, err := tx.Exec(sessionQuery); err != nil {
-		return err
+		if err := a.applyEventQueries(tx.Exec, entry); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// applyEventQueries executes the statements of the dump entry with exec, one
+// at a time. An error tells the table, the chunk and the rows of the entry, and
+// the head of the statement failed.
+func (a *Applier) applyEventQueries(exec func(query string, args ...interface{}) (gosql.Result, error), entry *DumpEntry) error {
+	queries := []string{}
+	queries = append(queries, entry.SystemVariablesStatement, entry.SqlMode)
+	if a.mysqlContext.AnsiQuotes {
+		// After the sql_mode of the source.
+		queries = append(queries, ansiQuotesSessionQuery)
 	}
-	execQuery := func(query string) error {
+	queries = append(queries, entry.DbSQL)
+	queries = append(queries, entry.TbSQL...)
+	if _, err := exec(a.fullCopySessionQuery); err != nil {
+		return fmt.Errorf("%v: exec [%s] error: %v", entry.describe(0, len(entry.ValuesX)),
+			statementHead(a.fullCopySessionQuery), err)
+	}
+	execQuery := func(query string, what string) error {
 		a.logger.Debugf("mysql.applier: Exec [%s]", utils.StrLim(query, 256))
-		_, err := tx.Exec(query)
+		a.auditSql("", query, nil)
+		_, err := exec(query)
 		if err != nil {
+			if entry.ExistingTarget == config.ExistingTargetError && sql.IsTableExistsError(err) {
+				return fmt.Errorf("table %s.%s exists on the target. ExistingTarget is %v",
+					entry.TableSchema, entry.TableName, entry.ExistingTarget)
+			}
 			if !sql.IgnoreError(err) {
-				a.logger.Errorf("mysql.applier: Exec [%s] error: %v", utils.StrLim(query, 10), err)
+				err = fmt.Errorf("%v: exec [%s] error: %v", what, statementHead(query), err)
+				a.logger.Errorf("mysql.applier: %v", err)
 				return err
 			}
 			if !sql.IgnoreExistsError(err) {
@@ -1377,55 +1828,158 @@ func (a *Applier) ApplyEventQueries(db *gosql.DB, entry *DumpEntry) error {
 		if query == "" {
 			continue
 		}
-		err := execQuery(query)
+		err := execQuery(query, entry.describe(0, len(entry.ValuesX)))
 		if err != nil {
 			return err
 		}
 	}
 
-	var buf bytes.Buffer
+	if entry.OutfilePath != "" {
+		query := a.buildLoadDataQuery(entry)
+		a.logger.Debugf("mysql.applier: Exec [%s]", query)
+		a.auditSql("", query, nil)
+		if _, err := exec(query); err != nil {
+			return fmt.Errorf("%v: exec [%s] error: %v", entry.describe(0, 0), statementHead(query), err)
+		}
+		return nil
+	}
+
 	BufSizeLimit := 1 * 1024 * 1024 // 1MB. TODO parameterize it
-	BufSizeLimitDelta := 1024
-	buf.Grow(BufSizeLimit + BufSizeLimitDelta)
-	for i, _ := range entry.ValuesX {
-		if buf.Len() == 0 {
-			buf.WriteString(fmt.Sprintf(`replace into %s.%s values (`, entry.TableSchema, entry.TableName))
-		} else {
-			buf.WriteString(",(")
+	inserts, firstRows := dumpInsertQueries(dumpInsertVerb(a.mysqlContext.FullCopyConflict), entry.TableSchema, entry.TableName,
+		entry.ValuesX, entry.HexColumns, BufSizeLimit, a.mysqlContext.AnsiQuotes)
+	for i, query := range inserts {
+		lastRow := len(entry.ValuesX)
+		if i+1 < len(firstRows) {
+			lastRow = firstRows[i+1]
+		}
+		if err := execQuery(query, entry.describe(firstRows[i], lastRow-firstRows[i])); err != nil {
+			return err
 		}
+	}
 
-		firstCol := true
-		for j := range entry.ValuesX[i] {
-			if firstCol {
-				firstCol = false
-			} else {
-				buf.WriteByte(',')
-			}
+	return nil
+}
 
-			colData := entry.ValuesX[i][j]
-			if *colData != nil {
-				buf.WriteByte('\'')
-				buf.WriteString(sql.EscapeValue(string((*colData).([]byte))))
-				buf.WriteByte('\'')
-			} else {
-				buf.WriteString("NULL")
-			}
-		}
-		buf.WriteByte(')')
+// statementHeadSize is how much of a statement failed is in the error.
+const statementHeadSize = 128
 
-		needInsert := (i == len(entry.ValuesX)-1) || (buf.Len() >= BufSizeLimit)
-		// last rows or sql too large
+// statementHead returns the start of the statement, to tell it in an error
+// without all its values.
+func statementHead(query string) string {
+	if len(query) <= statementHeadSize {
+		return query
+	}
+	return query[:statementHeadSize] + "..."
+}
 
-		if needInsert {
-			err := execQuery(buf.String())
-			buf.Reset()
-			if err != nil {
-				return err
+// uploadDumpExport puts the full copy exported in the storage, while the
+// incremental replication goes on. As the export, a failure stops the task.
+func (a *Applier) uploadDumpExport() {
+	prefix := fmt.Sprintf("dumps/%v/", a.subject)
+	if err := a.dumpExporter.Upload(prefix); err != nil {
+		a.onError(TaskStateDead, err)
+		return
+	}
+	a.logger.Printf("mysql.applier: Put the full copy exported in %v", a.dumpExporter.store.URL(prefix))
+}
+
+// auditSql records a statement to the sql audit file, if enabled.
+// An audit failure is logged but does not stop the replication.
+func (a *Applier) auditSql(gtid string, query string, args []interface{}) {
+	if a.auditor == nil {
+		return
+	}
+	if err := a.auditor.Record(gtid, query, args); err != nil {
+		a.logger.Errorf("mysql.applier: write sql audit file error: %v", err)
+	}
+}
+
+// auditedStatement is a statement of a transaction, recorded to the sql audit
+// file once the transaction is committed.
+type auditedStatement struct {
+	query string
+	args  []interface{}
+}
+
+// auditSqls records the statements of a committed transaction.
+func (a *Applier) auditSqls(gtid string, statements []auditedStatement) {
+	for _, st := range statements {
+		a.auditSql(gtid, st.query, st.args)
+	}
+}
+
+// recordApply records the time of the binlog entry applied and its lag behind
+// the source, for Stats. The lag is 0 for a source clock ahead of ours.
+func (a *Applier) recordApply(binlogEntry *binlog.BinlogEntry) {
+	now := time.Now()
+	atomic.CompareAndSwapInt64(&a.firstApplyTime, 0, now.UnixNano())
+	atomic.StoreInt64(&a.lastApplyTime, now.UnixNano())
+	if binlogEntry.Timestamp != 0 {
+		lag := now.Unix() - int64(binlogEntry.Timestamp)
+		if lag < 0 {
+			lag = 0
+		}
+		atomic.StoreInt64(&a.applyLag, lag)
+	}
+}
+
+// callHooks calls the row and DDL hooks with the events of the binlog entry
+// applied. Their errors are logged only.
+func (a *Applier) callHooks(binlogEntry *binlog.BinlogEntry) {
+	hasRowHooks := hooks.HasRowHooks()
+	gtid := binlogEntry.Coordinates.GetGtidForThisTx()
+	for _, event := range binlogEntry.Events {
+		var errs []error
+		if event.DML == binlog.NotDML {
+			if event.OnlineSchemaChange != "" &&
+				(event.OnlineSchemaChange != binlog.OnlineSchemaChangeSwap || event.Query == "") {
+				// Not applied to the table.
+				continue
 			}
+			schema := event.DatabaseName
+			if schema == "" {
+				schema = event.CurrentSchema
+			}
+			errs = hooks.OnDDL(&hooks.DDLEvent{
+				JobID:  a.subject,
+				Gtid:   gtid,
+				Schema: schema,
+				Table:  event.TableName,
+				Query:  event.Query,
+			})
+		} else if hasRowHooks {
+			rowEvent := &hooks.RowEvent{
+				JobID:   a.subject,
+				Gtid:    gtid,
+				Schema:  event.DatabaseName,
+				Table:   event.TableName,
+				Type:    string(event.DML),
+				Columns: event.ColumnNames,
+				Before:  hookRowValues(event.WhereColumnValues),
+				After:   hookRowValues(event.NewColumnValues),
+			}
+			if len(rowEvent.Columns) == 0 && event.Table != nil && event.Table.OriginalTableColumns != nil {
+				rowEvent.Columns = event.Table.OriginalTableColumns.Names()
+			}
+			errs = hooks.OnRow(rowEvent)
+		}
+		for _, err := range errs {
+			a.logger.Warnf("mysql.applier: gtid %v: %v", gtid, err)
 		}
 	}
+}
 
-	return nil
+func hookRowValues(values *umconf.ColumnValues) []interface{} {
+	if values == nil {
+		return nil
+	}
+	row := make([]interface{}, len(values.AbstractValues))
+	for i, v := range values.AbstractValues {
+		if v != nil {
+			row[i] = *v
+		}
+	}
+	return row
 }
 
 func (a *Applier) Stats() (*models.TaskStatistics, error) {
@@ -1483,15 +2037,45 @@ func (a *Applier) Stats() (*models.TaskStatistics, error) {
 			ApplierTxQueueSize:      len(a.applyBinlogTxQueue),
 			ApplierGroupTxQueueSize: len(a.applyBinlogGroupTxQueue),
 		},
-		Timestamp: time.Now().UTC().UnixNano(),
+		DelayCount: &models.DelayCount{
+			Time: uint64(atomic.LoadInt64(&a.applyLag)),
+		},
+		Timestamp:        time.Now().UTC().UnixNano(),
+		LastApplyTime:    atomic.LoadInt64(&a.lastApplyTime),
+		FullCopyComplete: atomic.LoadInt64(&a.fullCopyCompleteFlag) == 1,
+		IncrProgress:     a.incrProgress(),
+		WriteConflicts:   a.writeConflictsStat(),
+	}
+	if a.targetReadOnly != nil {
+		taskResUsage.TargetReadOnly = a.targetReadOnly.Stat()
+	}
+	if a.tp == models.JobTypeBench {
+		taskResUsage.Bench = a.benchStat()
 	}
 	if a.natsConn != nil {
 		taskResUsage.MsgStat = a.natsConn.Statistics
 	}
+	a.subsLock.Lock()
+	taskResUsage.TransportStat = a.transport.Stat(a.natsConn, a.subs)
+	a.subsLock.Unlock()
 
 	return &taskResUsage, nil
 }
 
+// incrProgress returns the gtid set applied and how far it was behind the
+// source, nil before the incremental replication starts.
+func (a *Applier) incrProgress() *models.IncrProgress {
+	a.gtidExecutedMutex.Lock()
+	defer a.gtidExecutedMutex.Unlock()
+	if a.gtidExecuted == nil {
+		return nil
+	}
+	return &models.IncrProgress{
+		GtidSet:       a.gtidExecuted.String(),
+		SecondsBehind: atomic.LoadInt64(&a.applyLag),
+	}
+}
+
 func (a *Applier) ID() string {
 	id := config.DriverCtx{
 		DriverConfig: &config.MySQLDriverConfig{
@@ -1560,6 +2144,11 @@ func (a *Applier) Shutdown() error {
 	if err := sql.CloseConns(a.dbs...); err != nil {
 		return err
 	}
+	if a.auditor != nil {
+		if err := a.auditor.Close(); err != nil {
+			return err
+		}
+	}
 
 	//close(a.applyBinlogTxQueue)
 	//close(a.applyBinlogGroupTxQueue)
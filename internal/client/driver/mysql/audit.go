@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SqlAuditRecord is a line in the sql audit file.
+type SqlAuditRecord struct {
+	Time  string
+	Job   string
+	Gtid  string `json:",omitempty"` // empty for full copy
+	Query string
+	Args  []interface{} `json:",omitempty"`
+}
+
+// sqlAuditArgTypeBytes is the type of a []byte arg, kept as hex in the sql
+// audit file: json would have it base64 encoded, and replayed as text.
+const sqlAuditArgTypeBytes = "bytes"
+
+// sqlAuditTypedArg is an arg of a SqlAuditRecord to be decoded before replayed.
+type sqlAuditTypedArg struct {
+	T string `json:"t"`
+	V string `json:"v"`
+}
+
+// sqlAuditArg returns the arg to be written in the sql audit file.
+func sqlAuditArg(arg interface{}) interface{} {
+	if bs, ok := arg.([]byte); ok {
+		return &sqlAuditTypedArg{T: sqlAuditArgTypeBytes, V: hex.EncodeToString(bs)}
+	}
+	return arg
+}
+
+// ExecArgs returns the args of the record to be executed again. The numbers
+// are kept as they were written, and the typed args are decoded.
+func (r *SqlAuditRecord) ExecArgs() ([]interface{}, error) {
+	args := make([]interface{}, len(r.Args))
+	for i, arg := range r.Args {
+		switch arg := arg.(type) {
+		case json.Number:
+			args[i] = arg.String()
+		case map[string]interface{}:
+			if arg["t"] != sqlAuditArgTypeBytes {
+				return nil, fmt.Errorf("unknown type of arg %v: %v", i, arg["t"])
+			}
+			v, _ := arg["v"].(string)
+			bs, err := hex.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("bad bytes of arg %v: %v", i, err)
+			}
+			args[i] = bs
+		default:
+			args[i] = arg
+		}
+	}
+	return args, nil
+}
+
+// SqlAuditor appends every statement applied on the target to a file,
+// one json object per line.
+type SqlAuditor struct {
+	job  string
+	file *os.File
+	enc  *json.Encoder
+	mu   sync.Mutex
+}
+
+func NewSqlAuditor(job string, path string) (*SqlAuditor, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &SqlAuditor{
+		job:  job,
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+func (s *SqlAuditor) Record(gtid string, query string, args []interface{}) error {
+	record := &SqlAuditRecord{
+		Time:  time.Now().Format(time.RFC3339Nano),
+		Job:   s.job,
+		Gtid:  gtid,
+		Query: query,
+	}
+	if len(args) > 0 {
+		record.Args = make([]interface{}, len(args))
+		for i := range args {
+			record.Args[i] = sqlAuditArg(args[i])
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record)
+}
+
+func (s *SqlAuditor) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
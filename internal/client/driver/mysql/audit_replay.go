@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// sqlAuditReplayWindow is how many transactions of a sql audit file are
+// gathered at most before the oldest is replayed. The statements of the
+// transactions applied by parallel workers are interleaved in the file.
+const sqlAuditReplayWindow = 256
+
+// SqlAuditFilter selects the transactions of a sql audit file to replay.
+type SqlAuditFilter struct {
+	// The job of the statements, all the jobs if empty.
+	Job string
+	// The source transactions, all if nil. The statements of the full copy,
+	// without GTID, are not selected then.
+	GtidSet *gomysql.MysqlGTIDSet
+	// The first statement of a transaction was applied in [Since, Until), if
+	// not zero.
+	Since time.Time
+	Until time.Time
+}
+
+// SqlAuditTx is a source transaction of a sql audit file, or a statement of
+// the full copy, without Gtid.
+type SqlAuditTx struct {
+	Gtid    string
+	Time    time.Time
+	Records []*SqlAuditRecord
+}
+
+type sqlAuditPendingTx struct {
+	tx       *SqlAuditTx
+	selected bool
+}
+
+// ReadSqlAudit reads the sql audit file, and calls fn with the transactions
+// selected by the filter, in the order they were applied on the target.
+//
+// A transaction applied again, after a failure or a restart of the task, is
+// recorded again from its first statement: only the last attempt is kept.
+func ReadSqlAudit(r io.Reader, filter *SqlAuditFilter, fn func(*SqlAuditTx) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var pending []*sqlAuditPendingTx
+	byGtid := map[string]*sqlAuditPendingTx{}
+	flush := func(n int) error {
+		for _, p := range pending[:n] {
+			delete(byGtid, p.tx.Gtid)
+			if p.selected {
+				if err := fn(p.tx); err != nil {
+					return err
+				}
+			}
+		}
+		pending = pending[n:]
+		return nil
+	}
+
+	for i := 1; ; i++ {
+		record := &SqlAuditRecord{}
+		if err := dec.Decode(record); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("record %v: %v", i, err)
+		}
+		if filter.Job != "" && record.Job != filter.Job {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, record.Time)
+		if err != nil {
+			return fmt.Errorf("record %v: bad Time: %v", i, err)
+		}
+
+		if record.Gtid == "" {
+			if err := flush(len(pending)); err != nil {
+				return err
+			}
+			if filter.GtidSet == nil && filter.selectsTime(t) {
+				tx := &SqlAuditTx{Time: t, Records: []*SqlAuditRecord{record}}
+				if err := fn(tx); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if p, ok := byGtid[record.Gtid]; ok {
+			if sameSqlAuditStatement(p.tx.Records[0], record) {
+				p.tx.Records = nil
+			}
+			p.tx.Records = append(p.tx.Records, record)
+			continue
+		}
+		selected, err := filter.selectsGtid(record.Gtid)
+		if err != nil {
+			return fmt.Errorf("record %v: %v", i, err)
+		}
+		p := &sqlAuditPendingTx{
+			tx:       &SqlAuditTx{Gtid: record.Gtid, Time: t, Records: []*SqlAuditRecord{record}},
+			selected: selected && filter.selectsTime(t),
+		}
+		pending = append(pending, p)
+		byGtid[record.Gtid] = p
+		if len(pending) > sqlAuditReplayWindow {
+			if err := flush(1); err != nil {
+				return err
+			}
+		}
+	}
+	return flush(len(pending))
+}
+
+// sameSqlAuditStatement returns whether the records are the same statement,
+// i.e. the transaction is applied again.
+func sameSqlAuditStatement(a, b *SqlAuditRecord) bool {
+	return a.Query == b.Query && reflect.DeepEqual(a.Args, b.Args)
+}
+
+func (f *SqlAuditFilter) selectsTime(t time.Time) bool {
+	return (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+func (f *SqlAuditFilter) selectsGtid(gtid string) (bool, error) {
+	if f.GtidSet == nil {
+		return true, nil
+	}
+	set, err := gomysql.ParseMysqlGTIDSet(gtid)
+	if err != nil {
+		return false, fmt.Errorf("bad Gtid %v: %v", gtid, err)
+	}
+	return f.GtidSet.Contain(set), nil
+}
+
+// ApplySqlAuditTx applies the statements of the transaction on the connection,
+// in a transaction.
+func ApplySqlAuditTx(conn *gosql.Conn, tx *SqlAuditTx) error {
+	what := "full copy"
+	if tx.Gtid != "" {
+		what = fmt.Sprintf("gtid %v", tx.Gtid)
+	}
+	dbTx, err := conn.BeginTx(context.Background(), &gosql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%v: %v", what, err)
+	}
+	for _, record := range tx.Records {
+		args, err := record.ExecArgs()
+		if err != nil {
+			dbTx.Rollback()
+			return fmt.Errorf("%v: [%s]: %v", what, statementHead(record.Query), err)
+		}
+		if _, err := dbTx.Exec(record.Query, args...); err != nil {
+			dbTx.Rollback()
+			return fmt.Errorf("%v: exec [%s] error: %v", what, statementHead(record.Query), err)
+		}
+	}
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("%v: commit error: %v", what, err)
+	}
+	return nil
+}
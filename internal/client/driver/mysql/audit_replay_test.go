@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+const testAuditSid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+func TestReadSqlAudit(t *testing.T) {
+	lines := []string{
+		`{"Time":"2026-01-01T10:00:00Z","Job":"job1","Query":"CREATE DATABASE IF NOT EXISTS a"}`,
+		`{"Time":"2026-01-01T10:00:01Z","Job":"job1","Query":"replace into a.b values (1)"}`,
+		`{"Time":"2026-01-01T10:01:00Z","Job":"job1","Gtid":"` + testAuditSid + `:1","Query":"insert into a.b values (?)","Args":[2]}`,
+		`{"Time":"2026-01-01T10:01:00Z","Job":"job2","Gtid":"` + testAuditSid + `:9","Query":"insert into c.d values (?)","Args":[1]}`,
+		// The statements of the parallel workers are interleaved.
+		`{"Time":"2026-01-01T10:02:00Z","Job":"job1","Gtid":"` + testAuditSid + `:2","Query":"insert into a.b values (?)","Args":[3]}`,
+		`{"Time":"2026-01-01T10:02:00Z","Job":"job1","Gtid":"` + testAuditSid + `:3","Query":"update a.b set id = ?","Args":["x"]}`,
+		`{"Time":"2026-01-01T10:02:01Z","Job":"job1","Gtid":"` + testAuditSid + `:2","Query":"insert into a.b values (?)","Args":[4]}`,
+		// The transaction 3 is applied again after a failure.
+		`{"Time":"2026-01-01T10:03:00Z","Job":"job1","Gtid":"` + testAuditSid + `:3","Query":"update a.b set id = ?","Args":["x"]}`,
+		`{"Time":"2026-01-01T10:03:00Z","Job":"job1","Gtid":"` + testAuditSid + `:3","Query":"delete from a.b where id = ?","Args":[null]}`,
+	}
+	read := func(filter *SqlAuditFilter) []*SqlAuditTx {
+		var txs []*SqlAuditTx
+		err := ReadSqlAudit(strings.NewReader(strings.Join(lines, "\n")), filter, func(tx *SqlAuditTx) error {
+			txs = append(txs, tx)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txs
+	}
+	gtids := func(txs []*SqlAuditTx) string {
+		var s []string
+		for _, tx := range txs {
+			s = append(s, tx.Gtid)
+		}
+		return strings.Replace(strings.Join(s, ","), testAuditSid, "sid", -1)
+	}
+
+	txs := read(&SqlAuditFilter{Job: "job1"})
+	if got := gtids(txs); got != ",,sid:1,sid:2,sid:3" {
+		t.Fatalf("transactions = %v", got)
+	}
+	if r := txs[3].Records; len(r) != 2 || r[1].Args[0] != json.Number("4") {
+		t.Errorf("records of 2 = %+v", r)
+	}
+	if r := txs[4].Records; len(r) != 2 || r[1].Query != "delete from a.b where id = ?" {
+		t.Errorf("records of 3 = %+v", r)
+	}
+
+	set, err := gomysql.ParseMysqlGTIDSet(testAuditSid + ":2-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gtids(read(&SqlAuditFilter{GtidSet: set.(*gomysql.MysqlGTIDSet)})); got != "sid:9,sid:2,sid:3" {
+		t.Errorf("transactions of the gtid set = %v", got)
+	}
+
+	since, _ := time.Parse(time.RFC3339, "2026-01-01T10:00:01Z")
+	until, _ := time.Parse(time.RFC3339, "2026-01-01T10:02:00Z")
+	if got := gtids(read(&SqlAuditFilter{Job: "job1", Since: since, Until: until})); got != ",sid:1" {
+		t.Errorf("transactions of the time range = %v", got)
+	}
+
+	if err := ReadSqlAudit(strings.NewReader(`{"Time":"x"}`), &SqlAuditFilter{}, nil); err == nil {
+		t.Errorf("read a bad record")
+	}
+}
+
+func TestSqlAuditRecord_ExecArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	binary := []byte{0, 0xff, 0xfe, 'x', '\n'}
+	s, err := NewSqlAuditor("job1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(testAuditSid+":1", "insert into a.b values (?, ?, ?, ?)",
+		[]interface{}{binary, int64(1), "text", nil}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var args []interface{}
+	err = ReadSqlAudit(f, &SqlAuditFilter{}, func(tx *SqlAuditTx) error {
+		args, err = tx.Records[0].ExecArgs()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 4 {
+		t.Fatalf("args = %v", args)
+	}
+	if bs, ok := args[0].([]byte); !ok || !bytes.Equal(bs, binary) {
+		t.Errorf("binary arg = %#v, want %#v", args[0], binary)
+	}
+	if args[1] != "1" || args[2] != "text" || args[3] != nil {
+		t.Errorf("args = %#v", args)
+	}
+
+	bad := &SqlAuditRecord{Args: []interface{}{map[string]interface{}{"t": "bytes", "v": "zz"}}}
+	if _, err := bad.ExecArgs(); err == nil {
+		t.Errorf("decoded bad bytes")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqlAuditor_Record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	tests := []struct {
+		name  string
+		gtid  string
+		query string
+		args  []interface{}
+	}{
+		{"ddl", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1", "create table a.b (id int)", nil},
+		{"dml", "3e11fa47-71ca-11e1-9e33-c80aa9429562:2", "Insert a.b", []interface{}{[]byte("x"), nil}},
+		{"full", "", "replace into a.b values (1)", nil},
+	}
+
+	// Records are appended across reopen.
+	for _, tt := range tests {
+		s, err := NewSqlAuditor("job1", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Record(tt.gtid, tt.query, tt.args); err != nil {
+			t.Errorf("SqlAuditor.Record() %v error = %v", tt.name, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		r := &SqlAuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			t.Fatalf("line %v: %v", i, err)
+		}
+		if i >= len(tests) {
+			continue
+		}
+		if r.Job != "job1" || r.Gtid != tests[i].gtid || r.Query != tests[i].query || r.Time == "" {
+			t.Errorf("line %v: got %+v", i, r)
+		}
+		if len(r.Args) != len(tests[i].args) {
+			t.Errorf("line %v: got args %v", i, r.Args)
+		} else if len(r.Args) > 0 {
+			if arg, ok := r.Args[0].(map[string]interface{}); !ok || arg["t"] != "bytes" || arg["v"] != "78" {
+				t.Errorf("line %v: got args %v", i, r.Args)
+			}
+		}
+	}
+	if i != len(tests) {
+		t.Errorf("got %v lines, want %v", i, len(tests))
+	}
+}
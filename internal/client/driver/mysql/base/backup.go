@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package base
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// The gtid info is in the head of a backup. No need to read a whole dump file.
+const backupHeadLimit = 1024 * 1024
+
+var (
+	// mysqldump: SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '<gtid set>';
+	mysqldumpGtidRegexp = regexp.MustCompile(`(?is)SET\s+@@GLOBAL\.GTID_PURGED\s*=\s*(?:/\*!\d+\s*'\+'\s*\*/\s*)?'([^']*)'`)
+	// mydumper metadata: \tGTID:<gtid set>
+	mydumperGtidRegexp = regexp.MustCompile(`(?s)(?:^|\n)\s*GTID:(.*?)(?:\n\s*\n|$)`)
+)
+
+// ParseBackupGtid gets the gtid set a backup is consistent with. content might be
+// an xtrabackup_binlog_info, a mydumper metadata or the head of a mysqldump file.
+func ParseBackupGtid(content string) (string, error) {
+	var gtid string
+	if m := mysqldumpGtidRegexp.FindStringSubmatch(content); m != nil {
+		gtid = m[1]
+	} else if m := mydumperGtidRegexp.FindStringSubmatch(content); m != nil {
+		gtid = m[1]
+	} else {
+		// xtrabackup_binlog_info: <binlog file>\t<pos>\t<gtid set>
+		// A long gtid set might be splitted into lines.
+		fields := strings.SplitN(strings.TrimSpace(content), "\t", 3)
+		if len(fields) == 3 {
+			gtid = fields[2]
+		}
+	}
+
+	gtid = strings.Join(strings.Fields(gtid), "")
+	if gtid == "" {
+		return "", fmt.Errorf("no gtid found in backup info. Is gtid_mode on for the backup source?")
+	}
+	gtidSet, err := gomysql.ParseMysqlGTIDSet(gtid)
+	if err != nil {
+		return "", fmt.Errorf("bad gtid in backup info %v: %v", gtid, err)
+	}
+	return gtidSet.String(), nil
+}
+
+// ReadBackupGtid is ParseBackupGtid on the head of a file.
+func ReadBackupGtid(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	bs, err := ioutil.ReadAll(io.LimitReader(f, backupHeadLimit))
+	if err != nil {
+		return "", err
+	}
+	return ParseBackupGtid(string(bs))
+}
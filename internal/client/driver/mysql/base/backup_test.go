@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package base
+
+import (
+	"testing"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+func TestParseBackupGtid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "xtrabackup",
+			content: "mysql-bin.000003\t1234\t3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5\n",
+			want:    "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+		},
+		{
+			name: "xtrabackup multiline",
+			content: "mysql-bin.000003\t1234\t3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,\n" +
+				"4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3\n",
+			want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3",
+		},
+		{
+			name:    "xtrabackup without gtid",
+			content: "mysql-bin.000003\t1234\n",
+			wantErr: true,
+		},
+		{
+			name: "mysqldump",
+			content: "-- MySQL dump 10.13\n" +
+				"SET @@SESSION.SQL_LOG_BIN= 0;\n\n" +
+				"--\n-- GTID state at the beginning of the backup \n--\n\n" +
+				"SET @@GLOBAL.GTID_PURGED='3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n",
+			want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+		},
+		{
+			name: "mysqldump 8.0",
+			content: "SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,\n" +
+				"4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3';\n",
+			want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3",
+		},
+		{
+			name: "mydumper",
+			content: "Started dump at: 2018-01-01 00:00:00\nSHOW MASTER STATUS:\n\tLog: mysql-bin.000003\n" +
+				"\tPos: 1234\n\tGTID:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5\n\nFinished dump at: 2018-01-01 00:00:01\n",
+			want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+		},
+		{
+			name:    "bad gtid",
+			content: "SET @@GLOBAL.GTID_PURGED='not-a-gtid';\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBackupGtid(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseBackupGtid() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			// The order of uuids in the result is not fixed.
+			gotSet, err := gomysql.ParseMysqlGTIDSet(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantSet, _ := gomysql.ParseMysqlGTIDSet(tt.want)
+			if !gotSet.Equal(wantSet) {
+				t.Errorf("ParseBackupGtid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package base
+
+import (
+	"testing"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+func TestGtidSet_String(t *testing.T) {
+	set, err := gomysql.ParseMysqlGTIDSet("96fda9dc-7cbf-11e7-9340-0242ac110002:1-100:102,0b2c3e47-7cbf-11e7-9340-0242ac110002:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gtidSet := make(GtidSet)
+	for _, uuidSet := range set.(*gomysql.MysqlGTIDSet).Sets {
+		gtidSet[uuidSet.SID] = &GtidExecutedItem{Intervals: uuidSet.Intervals}
+	}
+	want := "0b2c3e47-7cbf-11e7-9340-0242ac110002:5,96fda9dc-7cbf-11e7-9340-0242ac110002:1-100:102"
+	if got := gtidSet.String(); got != want {
+		t.Errorf("GtidSet.String() = %v, want %v", got, want)
+	}
+	if got := make(GtidSet).String(); got != "" {
+		t.Errorf("empty GtidSet.String() = %v", got)
+	}
+}
+
+func TestCountGtidSetDiff(t *testing.T) {
+	const (
+		sid1 = "96fda9dc-7cbf-11e7-9340-0242ac110002"
+		sid2 = "0b2c3e47-7cbf-11e7-9340-0242ac110002"
+	)
+	tests := []struct {
+		set1, set2 string
+		want       int64
+	}{
+		{"", "", 0},
+		{sid1 + ":1-100", "", 100},
+		{sid1 + ":1-100", sid1 + ":1-100", 0},
+		{sid1 + ":1-100", sid1 + ":1-40", 60},
+		{sid1 + ":1-100", sid1 + ":1-40:61-70", 50},
+		{sid1 + ":1-100:200", sid1 + ":1-99", 2},
+		{sid1 + ":1-10", sid1 + ":1-20", 0},
+		{sid1 + ":1-10," + sid2 + ":1-5", sid2 + ":1-5", 10},
+		{sid1 + ":1-10," + sid2 + ":1-5", sid1 + ":5", 14},
+	}
+	for _, tt := range tests {
+		got, err := CountGtidSetDiff(tt.set1, tt.set2)
+		if err != nil {
+			t.Fatalf("CountGtidSetDiff(%q, %q): %v", tt.set1, tt.set2, err)
+		}
+		if got != tt.want {
+			t.Errorf("CountGtidSetDiff(%q, %q) = %v, want %v", tt.set1, tt.set2, got, tt.want)
+		}
+	}
+	if _, err := CountGtidSetDiff("bad", ""); err == nil {
+		t.Errorf("expected an error of a bad gtid set")
+	}
+}
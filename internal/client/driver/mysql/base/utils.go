@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"github.com/actiontech/dtle/internal/g"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -116,23 +117,24 @@ func ShowCreateTable(db *gosql.DB, databaseName, tableName string, dropTableIfEx
 	var dummy, createTableStatement string
 	query := fmt.Sprintf(`show create table %s.%s`, usql.EscapeName(databaseName), usql.EscapeName(tableName))
 	err = db.QueryRow(query).Scan(&dummy, &createTableStatement)
-	statement = append(statement, fmt.Sprintf("USE %s", databaseName))
+	statement = append(statement, fmt.Sprintf("USE %s", usql.EscapeName(databaseName)))
 	if dropTableIfExists {
-		statement = append(statement, fmt.Sprintf("DROP TABLE IF EXISTS `%s`", tableName))
+		statement = append(statement, fmt.Sprintf("DROP TABLE IF EXISTS %s", usql.EscapeName(tableName)))
 	}
 	statement = append(statement, createTableStatement)
 	return statement, err
 }
 
-func ShowCreateView(db *gosql.DB, databaseName, tableName string, dropTableIfExists bool) (createTableStatement string, err error) {
-	var dummy, character_set_client, collation_connection string
+func ShowCreateView(db *gosql.DB, databaseName, tableName string, dropTableIfExists bool) (statement []string, err error) {
+	var dummy, createTableStatement, character_set_client, collation_connection string
 	query := fmt.Sprintf(`show create table %s.%s`, usql.EscapeName(databaseName), usql.EscapeName(tableName))
 	err = db.QueryRow(query).Scan(&dummy, &createTableStatement, &character_set_client, &collation_connection)
-	statement := fmt.Sprintf("USE %s", databaseName)
+	statement = append(statement, fmt.Sprintf("USE %s", usql.EscapeName(databaseName)))
 	if dropTableIfExists {
-		statement = fmt.Sprintf("%s;DROP TABLE IF EXISTS `%s`", statement, tableName)
+		statement = append(statement, fmt.Sprintf("DROP TABLE IF EXISTS %s", usql.EscapeName(tableName)))
 	}
-	return fmt.Sprintf("%s;%s", statement, createTableStatement), err
+	statement = append(statement, createTableStatement)
+	return statement, err
 }
 
 // Interval is [start, stop), but the GTID string's format is [n] or [n1-n2], closed interval
@@ -223,6 +225,41 @@ func StringInterval(intervals gomysql.IntervalSlice) string {
 	return hack.String(buf.Bytes())
 }
 
+// String returns the gtid set in the format of MySQL, sorted by the source uuid.
+func (s GtidSet) String() string {
+	sids := make([]string, 0, len(s))
+	for sid, item := range s {
+		if len(item.Intervals) == 0 {
+			continue
+		}
+		sids = append(sids, sid.String()+":"+StringInterval(item.Intervals))
+	}
+	sort.Strings(sids)
+	return strings.Join(sids, ",")
+}
+
+// ReplaceGtidExecuted replaces the gtid set executed of the job with gtidSet.
+func ReplaceGtidExecuted(db usql.QueryAble, jid uuid.UUID, gtidSet string) error {
+	set, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`DELETE FROM %v.%v WHERE job_uuid=?`,
+		g.DtleSchemaName, g.GtidExecutedTableV3), jid.Bytes())
+	if err != nil {
+		return err
+	}
+	for _, uuidSet := range set.(*gomysql.MysqlGTIDSet).Sets {
+		_, err = db.Exec(fmt.Sprintf(`INSERT INTO %v.%v (job_uuid,source_uuid,interval_gtid) VALUES (?,?,?)`,
+			g.DtleSchemaName, g.GtidExecutedTableV3),
+			jid.Bytes(), uuidSet.SID.Bytes(), StringInterval(uuidSet.Intervals))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // applyColumnTypes
 func ApplyColumnTypes(db usql.QueryAble, databaseName, tableName string, columnsLists ...*umconf.ColumnList) error {
 	query := `
@@ -328,6 +365,14 @@ func ApplyColumnTypes(db usql.QueryAble, databaseName, tableName string, columns
 				columnsList.GetColumn(columnName).Type = umconf.BlobColumnType
 			}
 		}
+		switch m.GetString("DATA_TYPE") {
+		case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring",
+			"multipolygon", "geometrycollection", "geomcollection":
+			for _, columnsList := range columnsLists {
+				columnsList.GetColumn(columnName).Type = umconf.GeometryColumnType
+				columnsList.GetColumn(columnName).ColumnType = columnType
+			}
+		}
 		if strings.HasPrefix(columnType, "bit") {
 			for _, columnsList := range columnsLists {
 				columnsList.GetColumn(columnName).Type = umconf.BitColumnType
@@ -450,3 +495,39 @@ func GtidSetDiff(set1 string, set2 string) (string, error) {
 
 	return gExecuted.String(), nil
 }
+
+// CountGtidSetDiff returns the number of the transactions of set1 not in set2.
+func CountGtidSetDiff(set1 string, set2 string) (int64, error) {
+	g1, err := gomysql.ParseMysqlGTIDSet(set1)
+	if err != nil {
+		return 0, err
+	}
+	g2, err := gomysql.ParseMysqlGTIDSet(set2)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for sid, uuidSet1 := range g1.(*gomysql.MysqlGTIDSet).Sets {
+		var intervals2 gomysql.IntervalSlice
+		if uuidSet2, ok := g2.(*gomysql.MysqlGTIDSet).Sets[sid]; ok {
+			intervals2 = uuidSet2.Intervals.Normalize()
+		}
+		for _, i1 := range uuidSet1.Intervals.Normalize() {
+			n += i1.Stop - i1.Start
+			for _, i2 := range intervals2 {
+				start, stop := i1.Start, i1.Stop
+				if i2.Start > start {
+					start = i2.Start
+				}
+				if i2.Stop < stop {
+					stop = i2.Stop
+				}
+				if stop > start {
+					n -= stop - start
+				}
+			}
+		}
+	}
+	return n, nil
+}
@@ -56,18 +56,19 @@ func TestGetSelfBinlogCoordinates(t *testing.T) {
 	type args struct {
 		db *gosql.DB
 	}
-	db, err := sql.CreateDB(fmt.Sprintf("root:rootroot@tcp(192.168.99.100:13307)/?timeout=5s&tls=false&autocommit=true&charset=utf8mb4,utf8,latin1&multiStatements=true"))
+	db, err := sql.CreateDB(fmt.Sprintf("root:rootroot@tcp(192.168.99.100:13307)/?timeout=5s&readTimeout=5s&tls=false&autocommit=true&charset=utf8mb4,utf8,latin1&multiStatements=true"))
+	if err == nil {
+		err = db.Ping()
+	}
 	if err != nil {
-		return
+		t.Skipf("no MySQL on 192.168.99.100:13307: %v", err)
 	}
 	tests := []struct {
-		name                      string
-		args                      args
-		wantSelfBinlogCoordinates *BinlogCoordinateTx
-		wantErr                   bool
+		name    string
+		args    args
+		wantErr bool
 	}{
-		// TODO: Add test cases.
-		{"T1", args{db}, nil, false},
+		{"T1", args{db}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -76,8 +77,8 @@ func TestGetSelfBinlogCoordinates(t *testing.T) {
 				t.Errorf("GetSelfBinlogCoordinates() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(gotSelfBinlogCoordinates, tt.wantSelfBinlogCoordinates) {
-				t.Errorf("GetSelfBinlogCoordinates() = %v, want %v", gotSelfBinlogCoordinates, tt.wantSelfBinlogCoordinates)
+			if gotSelfBinlogCoordinates == nil || gotSelfBinlogCoordinates.LogFile == "" {
+				t.Errorf("GetSelfBinlogCoordinates() = %v, want the binlog file", gotSelfBinlogCoordinates)
 			}
 		})
 	}
@@ -150,7 +151,7 @@ func TestShowCreateTable(t *testing.T) {
 	tests := []struct {
 		name                     string
 		args                     args
-		wantCreateTableStatement string
+		wantCreateTableStatement []string
 		wantErr                  bool
 	}{
 		// TODO: Add test cases.
@@ -162,7 +163,7 @@ func TestShowCreateTable(t *testing.T) {
 				t.Errorf("ShowCreateTable() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotCreateTableStatement != tt.wantCreateTableStatement {
+			if !reflect.DeepEqual(gotCreateTableStatement, tt.wantCreateTableStatement) {
 				t.Errorf("ShowCreateTable() = %v, want %v", gotCreateTableStatement, tt.wantCreateTableStatement)
 			}
 		})
@@ -179,8 +180,9 @@ func Test_parseInterval(t *testing.T) {
 		wantI   gomysql.Interval
 		wantErr bool
 	}{
-		// TODO: Add test cases.
-		{"t1", args{"36671-36677"}, gomysql.Interval{}, false},
+		{"t1", args{"36671-36677"}, gomysql.Interval{Start: 36671, Stop: 36678}, false},
+		{"t2", args{"5"}, gomysql.Interval{Start: 5, Stop: 6}, false},
+		{"t3", args{"7-6"}, gomysql.Interval{Start: 7, Stop: 7}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
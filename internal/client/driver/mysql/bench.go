@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// benchInsertBatch is how many rows a statement inserts when generating the
+// tables of a bench job.
+const benchInsertBatch = 1000
+
+// benchLoad is the workload generated by the Src task of a bench job.
+type benchLoad struct {
+	tables       int
+	rows         int64
+	transactions int64
+	rand         *rand.Rand
+
+	generated int64
+	// Unix nanoseconds, 0 if not yet.
+	startTime int64
+	endTime   int64
+}
+
+func (b *benchLoad) stat() *models.BenchStat {
+	stat := &models.BenchStat{
+		Transactions:          b.transactions,
+		GeneratedTransactions: atomic.LoadInt64(&b.generated),
+	}
+	stat.GenerateSeconds = elapsedSeconds(atomic.LoadInt64(&b.startTime), atomic.LoadInt64(&b.endTime))
+	return stat
+}
+
+// elapsedSeconds returns the seconds from start to end, or to now if end is 0,
+// in unix nanoseconds. It is 0 if start is 0.
+func elapsedSeconds(start, end int64) float64 {
+	if start == 0 {
+		return 0
+	}
+	if end == 0 {
+		end = time.Now().UnixNano()
+	}
+	return time.Duration(end - start).Seconds()
+}
+
+func benchTableName(i int) string {
+	return fmt.Sprintf("bench%d", i)
+}
+
+// benchString returns n random letters and digits, in groups of 11 separated by
+// '-' as the strings of sysbench.
+func (b *benchLoad) benchString(n int) string {
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+	buf := make([]byte, n)
+	for i := range buf {
+		if i%12 == 11 {
+			buf[i] = '-'
+		} else {
+			buf[i] = chars[b.rand.Intn(len(chars))]
+		}
+	}
+	return string(buf)
+}
+
+// prepareBench replicates only BenchSchemaName, with a full copy, and creates
+// it again with the tables and rows to copy.
+func (e *Extractor) prepareBench() error {
+	cfg := e.mysqlContext
+	cfg.ReplicateDoDb = []*config.DataSource{{TableSchema: config.BenchSchemaName}}
+	cfg.ReplicateIgnoreDb = nil
+	cfg.Gtid = ""
+	cfg.GtidStart = ""
+	cfg.AutoGtid = false
+	cfg.GtidBackupFile = ""
+	cfg.FullCopyMethod = config.FullCopyMethodDump
+	cfg.SkipCreateDbTable = false
+	cfg.SkipIncrementalCopy = false
+	cfg.ExistingTarget = config.ExistingTargetDropAndRecreate
+
+	e.bench = &benchLoad{
+		tables:       cfg.BenchTables,
+		rows:         cfg.BenchRows,
+		transactions: cfg.BenchTransactions,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	db, err := sql.CreateDB(cfg.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	e.logger.Printf("mysql.extractor: Generating %v tables of %v rows in %v for the bench",
+		e.bench.tables, e.bench.rows, config.BenchSchemaName)
+	schema := sql.EscapeName(config.BenchSchemaName)
+	for _, query := range []string{
+		fmt.Sprintf("DROP DATABASE IF EXISTS %s", schema),
+		fmt.Sprintf("CREATE DATABASE %s", schema),
+	} {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	for i := 1; i <= e.bench.tables; i++ {
+		table := fmt.Sprintf("%s.%s", schema, sql.EscapeName(benchTableName(i)))
+		if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %s (
+			id BIGINT NOT NULL AUTO_INCREMENT,
+			k INT NOT NULL DEFAULT 0,
+			c CHAR(120) NOT NULL DEFAULT '',
+			pad CHAR(60) NOT NULL DEFAULT '',
+			PRIMARY KEY (id),
+			KEY k (k)
+		) ENGINE=InnoDB`, table)); err != nil {
+			return err
+		}
+		if err := e.bench.insertRows(db, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRows inserts the rows of a bench table.
+func (b *benchLoad) insertRows(db *gosql.DB, table string) error {
+	for inserted := int64(0); inserted < b.rows; {
+		n := b.rows - inserted
+		if n > benchInsertBatch {
+			n = benchInsertBatch
+		}
+		placeholders := make([]string, n)
+		args := make([]interface{}, 0, 3*n)
+		for i := range placeholders {
+			placeholders[i] = "(?, ?, ?)"
+			args = append(args, b.rand.Int63n(b.rows)+1, b.benchString(120), b.benchString(60))
+		}
+		query := fmt.Sprintf("INSERT INTO %s (k, c, pad) VALUES %s", table, strings.Join(placeholders, ", "))
+		if _, err := db.Exec(query, args...); err != nil {
+			return err
+		}
+		inserted += n
+	}
+	return nil
+}
+
+// generateBenchLoad runs the transactions of the bench once the incremental
+// replication is started, until all are committed or the task is shut down.
+func (e *Extractor) generateBenchLoad() {
+	b := e.bench
+	e.logger.Printf("mysql.extractor: Generating %v transactions for the bench", b.transactions)
+	atomic.StoreInt64(&b.startTime, time.Now().UnixNano())
+	for atomic.LoadInt64(&b.generated) < b.transactions {
+		select {
+		case <-e.shutdownCh:
+			return
+		default:
+		}
+		if err := b.transaction(e.db); err != nil {
+			e.onError(TaskStateDead, fmt.Errorf("bench transaction: %v", err))
+			return
+		}
+		atomic.AddInt64(&b.generated, 1)
+	}
+	atomic.StoreInt64(&b.endTime, time.Now().UnixNano())
+	e.logger.Printf("mysql.extractor: Generated %v transactions for the bench", b.transactions)
+}
+
+// transaction updates a random row of a random bench table, then deletes it
+// and inserts it again, as the read-write transactions of sysbench.
+func (b *benchLoad) transaction(db *gosql.DB) error {
+	table := fmt.Sprintf("%s.%s", sql.EscapeName(config.BenchSchemaName),
+		sql.EscapeName(benchTableName(b.rand.Intn(b.tables)+1)))
+	id := b.rand.Int63n(b.rows) + 1
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{fmt.Sprintf("UPDATE %s SET k = k + 1 WHERE id = ?", table), []interface{}{id}},
+		{fmt.Sprintf("UPDATE %s SET c = ? WHERE id = ?", table), []interface{}{b.benchString(120), id}},
+		{fmt.Sprintf("DELETE FROM %s WHERE id = ?", table), []interface{}{id}},
+		{fmt.Sprintf("INSERT INTO %s (id, k, c, pad) VALUES (?, ?, ?, ?)", table),
+			[]interface{}{id, b.rand.Int63n(b.rows) + 1, b.benchString(120), b.benchString(60)}},
+	} {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// benchStat returns what the Dest task of a bench job has applied.
+func (a *Applier) benchStat() *models.BenchStat {
+	stat := &models.BenchStat{
+		DumpRows:            a.mysqlContext.GetTotalRowsReplay(),
+		DumpSeconds:         elapsedSeconds(atomic.LoadInt64(&a.fullCopyStartTime), atomic.LoadInt64(&a.fullCopyEndTime)),
+		AppliedTransactions: a.mysqlContext.GetTotalDeltaCopied(),
+	}
+	if first := atomic.LoadInt64(&a.firstApplyTime); first != 0 {
+		stat.ApplySeconds = elapsedSeconds(first, atomic.LoadInt64(&a.lastApplyTime))
+	}
+	return stat
+}
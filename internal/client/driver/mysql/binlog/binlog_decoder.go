@@ -0,0 +1,560 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/juju/errors"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// errSyncerResynced is returned for a stream the syncer has started again on
+// its own, e.g. after a read timeout, from the gtid set it started with. It does
+// not track the transactions read in raw mode.
+var errSyncerResynced = errors.New("the binlog syncer resynced from the start of the stream")
+
+// TABLE_MAP_EVENT optional metadata field type, see mysql libbinlogevents/include/rows_event.h
+const tableMapOptMetaColumnName byte = 4
+
+// see Log_event_type in mysql libbinlogevents/include/binlog_event.h
+const partialUpdateRowsEvent replication.EventType = 39
+
+// eventDecoder decodes the events of a binlog stream read in raw mode, with the
+// parser of go-mysql, and what the vendored version of it does not know about:
+//   - the optional metadata of a TABLE_MAP_EVENT, written by MySQL 8.0 with
+//     binlog_row_metadata=FULL. go-mysql rejects the event with it, so the
+//     metadata is cut off before parsing, and the column names in it are kept
+//     in a tableMapEvent.
+//   - a PARTIAL_UPDATE_ROWS_EVENT, written by MySQL 8.0 with
+//     binlog_row_value_options=PARTIAL_JSON, with the diffs of the JSON
+//     columns updated in part. go-mysql does not decode it, so it is rewritten
+//     to an UPDATE_ROWS_EVENTv2 before parsing, and the full documents are
+//     rebuilt from the before image.
+//
+// The events keep the raw data and the header read, e.g. for the binlog
+// replayed as is.
+type eventDecoder struct {
+	parser *replication.BinlogParser
+	format *replication.FormatDescriptionEvent
+	// The table maps of the statement being read, by table id.
+	tables map[uint64]*replication.TableMapEvent
+	// Whether the fake ROTATE_EVENT starting the stream was read.
+	started bool
+}
+
+func newEventDecoder(cfg replication.BinlogSyncerConfig) *eventDecoder {
+	parser := replication.NewBinlogParser()
+	parser.SetParseTime(cfg.ParseTime)
+	parser.SetTimestampStringLocation(cfg.TimestampStringLocation)
+	parser.SetUseDecimal(cfg.UseDecimal)
+	// Verified by the syncer, and the rewritten events have none.
+	parser.SetVerifyChecksum(false)
+	return &eventDecoder{parser: parser, tables: make(map[uint64]*replication.TableMapEvent)}
+}
+
+// tableMapEvent is a TABLE_MAP_EVENT, with the names of its columns.
+type tableMapEvent struct {
+	*replication.TableMapEvent
+	// From the optional metadata, with binlog_row_metadata=FULL (MySQL 8.0.1+).
+	// Empty without.
+	columnNames []string
+}
+
+// decode decodes ev, read in raw mode.
+func (d *eventDecoder) decode(ev *replication.BinlogEvent) (*replication.BinlogEvent, error) {
+	switch ev.Header.EventType {
+	case replication.FORMAT_DESCRIPTION_EVENT:
+		// Decoded in raw mode too.
+		d.format = ev.Event.(*replication.FormatDescriptionEvent)
+	case replication.ROTATE_EVENT:
+		// The source sends a fake one, with no timestamp, as it starts a stream.
+		if ev.Header.Timestamp == 0 {
+			if d.started {
+				return nil, errSyncerResynced
+			}
+			d.started = true
+		}
+		return ev, nil
+	case replication.HEARTBEAT_EVENT:
+		return ev, nil
+	}
+
+	data := ev.RawData
+	var columnNames []string
+	var partial *partialUpdate
+	var err error
+	switch ev.Header.EventType {
+	case replication.TABLE_MAP_EVENT:
+		if data, columnNames, err = d.cutTableMapMeta(data); err != nil {
+			return nil, fmt.Errorf("decoding the table map at %v: %v", ev.Header.LogPos, err)
+		}
+	case partialUpdateRowsEvent:
+		if data, partial, err = d.rewritePartialUpdate(data); err != nil {
+			return nil, fmt.Errorf("decoding the partial update at %v: %v", ev.Header.LogPos, err)
+		}
+	}
+
+	parsed, err := d.parser.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	switch e := parsed.Event.(type) {
+	case *replication.TableMapEvent:
+		d.tables[e.TableID] = e
+		parsed.Event = &tableMapEvent{TableMapEvent: e, columnNames: columnNames}
+	case *replication.RowsEvent:
+		if partial != nil {
+			if err := partial.apply(e); err != nil {
+				return nil, fmt.Errorf("update on %s.%s at %v: %v", e.Table.Schema, e.Table.Table, ev.Header.LogPos, err)
+			}
+		}
+		// as the parser does
+		if e.Flags&replication.RowsEventStmtEndFlag != 0 {
+			d.tables = make(map[uint64]*replication.TableMapEvent)
+		}
+	}
+	return &replication.BinlogEvent{RawData: ev.RawData, Header: ev.Header, Event: parsed.Event}, nil
+}
+
+// body returns the post-header and the body of the raw event data.
+func (d *eventDecoder) body(data []byte) ([]byte, error) {
+	if d.format == nil {
+		return nil, fmt.Errorf("no format description event before")
+	}
+	end := len(data)
+	if d.format.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= replication.BinlogChecksumLength
+	}
+	if end < replication.EventHeaderSize {
+		return nil, fmt.Errorf("event too short")
+	}
+	return data[replication.EventHeaderSize:end], nil
+}
+
+// withBody returns the raw event data of the header of data, with body.
+func (d *eventDecoder) withBody(data []byte, eventType replication.EventType, body []byte) []byte {
+	size := replication.EventHeaderSize + len(body)
+	if d.format.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		size += replication.BinlogChecksumLength
+	}
+	result := make([]byte, replication.EventHeaderSize, size)
+	copy(result, data[:replication.EventHeaderSize])
+	// see EventHeader.Decode()
+	result[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(result[9:], uint32(size))
+	result = append(result, body...)
+	// Not verified.
+	return result[:size]
+}
+
+// tableIDSize returns the size of the table id in the post-header of the event.
+func (d *eventDecoder) tableIDSize(eventType replication.EventType) (int, error) {
+	lengths := d.format.EventTypeHeaderLengths
+	if int(eventType) > len(lengths) {
+		return 0, fmt.Errorf("no post-header length of %v", eventType)
+	}
+	if lengths[eventType-1] == 6 {
+		return 4, nil
+	}
+	return 6, nil
+}
+
+// cutTableMapMeta returns the raw data of a TABLE_MAP_EVENT without the
+// optional metadata, and the column names in it.
+func (d *eventDecoder) cutTableMapMeta(data []byte) ([]byte, []string, error) {
+	body, err := d.body(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	tableIDSize, err := d.tableIDSize(replication.TABLE_MAP_EVENT)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// see TableMapEvent.Decode()
+	r := &byteReader{data: body}
+	r.skip(tableIDSize + 2)
+	r.skip(int(r.byte()) + 1) // schema
+	r.skip(int(r.byte()) + 1) // table
+	columnCount := int(r.lengthEncodedInt())
+	r.skip(columnCount)
+	r.skip(int(r.lengthEncodedInt())) // column meta
+	r.skip(bitmapByteSize(columnCount))
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if r.pos == len(body) {
+		return data, nil, nil
+	}
+
+	var columnNames []string
+	meta := &byteReader{data: body[r.pos:]}
+	for meta.err == nil && meta.pos < len(meta.data) {
+		t := meta.byte()
+		v := meta.next(int(meta.lengthEncodedInt()))
+		if t != tableMapOptMetaColumnName {
+			continue
+		}
+		names := &byteReader{data: v}
+		for names.err == nil && names.pos < len(v) {
+			columnNames = append(columnNames, string(names.next(int(names.byte()))))
+		}
+		if names.err != nil {
+			return nil, nil, names.err
+		}
+	}
+	if meta.err != nil {
+		return nil, nil, meta.err
+	}
+	if len(columnNames) != 0 && len(columnNames) != columnCount {
+		return nil, nil, fmt.Errorf("%v column names for %v columns", len(columnNames), columnCount)
+	}
+	return d.withBody(data, replication.TABLE_MAP_EVENT, body[:r.pos]), columnNames, nil
+}
+
+// partialUpdate is what is left to decode of a PARTIAL_UPDATE_ROWS_EVENT,
+// after parsing it as rewritten.
+type partialUpdate struct {
+	// The rows of the event, the ones after are the values of the diffs.
+	rowCount int
+	columns  []partialJson
+}
+
+// partialJson is a JSON column updated in part in a row.
+type partialJson struct {
+	// The after image in the rows.
+	row    int
+	column int
+	diffs  []jsonDiff
+}
+
+// rewritePartialUpdate returns the raw data of a PARTIAL_UPDATE_ROWS_EVENT as
+// an UPDATE_ROWS_EVENTv2, and what is left to decode after parsing it. The
+// value of each JSON column updated in part in the after image is replaced by
+// the one of the before image, which has to be there. As the binary JSON of
+// the values of the diffs can only be decoded by go-mysql in a row, a pair of
+// rows is added for each, with the value in the after image and NULL for the
+// rest.
+func (d *eventDecoder) rewritePartialUpdate(data []byte) ([]byte, *partialUpdate, error) {
+	body, err := d.body(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	tableIDSize, err := d.tableIDSize(partialUpdateRowsEvent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// see RowsEvent.Decode()
+	r := &byteReader{data: body}
+	tableID := gomysql.FixedLengthInt(r.next(tableIDSize))
+	r.skip(2) // flags
+	if extraData := r.next(2); extraData != nil {
+		// with its length
+		r.skip(int(binary.LittleEndian.Uint16(extraData)) - 2)
+	}
+	columnCount := int(r.lengthEncodedInt())
+	beforeBitmap := r.next(bitmapByteSize(columnCount))
+	afterBitmap := r.next(bitmapByteSize(columnCount))
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	table, ok := d.tables[tableID]
+	if !ok {
+		return nil, nil, fmt.Errorf("no table map of table id %v", tableID)
+	}
+	if int(table.ColumnCount) != columnCount {
+		return nil, nil, fmt.Errorf("%v columns for %v in the table map", columnCount, table.ColumnCount)
+	}
+	jsonColumnCount := 0
+	for _, tp := range table.ColumnType {
+		if tp == gomysql.MYSQL_TYPE_JSON {
+			jsonColumnCount++
+		}
+	}
+
+	rewritten := append([]byte(nil), body[:r.pos]...)
+	partial := &partialUpdate{}
+	for r.pos < len(body) {
+		start := r.pos
+		before := readRowImage(r, table, beforeBitmap)
+		rewritten = append(rewritten, body[start:r.pos]...)
+
+		// see Rows_log_event::print_verbose_one_row() in mysql sql/log_event.cc
+		var partialBitmap []byte
+		if r.lengthEncodedInt()&rowValueOptionPartialJson != 0 {
+			partialBitmap = r.next(bitmapByteSize(jsonColumnCount))
+		}
+		start = r.pos
+		after := readRowImage(r, table, afterBitmap)
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		partial.rowCount += 2
+
+		// The null bitmap, not changed.
+		rewritten = append(rewritten, body[start:start+bitmapByteSize(bitCount(afterBitmap, columnCount))]...)
+		// The partial bitmap has a bit for each JSON column, in the after image or not.
+		jsonColumnIndex := 0
+		for i := 0; i < columnCount; i++ {
+			isPartial := false
+			if table.ColumnType[i] == gomysql.MYSQL_TYPE_JSON {
+				isPartial = partialBitmap != nil && isBitSet(partialBitmap, jsonColumnIndex)
+				jsonColumnIndex++
+			}
+			value := after[i]
+			if !isPartial || value == nil {
+				rewritten = append(rewritten, value...)
+				continue
+			}
+			if before[i] == nil {
+				return nil, nil, fmt.Errorf("partial JSON update of column %v without the full before image."+
+					" binlog_row_image should be FULL", i)
+			}
+			diffs, err := readJsonDiffs(value[table.ColumnMeta[i]:])
+			if err != nil {
+				return nil, nil, err
+			}
+			partial.columns = append(partial.columns, partialJson{row: partial.rowCount - 1, column: i, diffs: diffs})
+			rewritten = append(rewritten, before[i]...)
+		}
+	}
+
+	beforeNulls := bytes.Repeat([]byte{0xff}, bitmapByteSize(bitCount(beforeBitmap, columnCount)))
+	for _, column := range partial.columns {
+		// The index of the column in the null bitmap.
+		nullIndex := bitCount(afterBitmap, column.column)
+		meta := int(table.ColumnMeta[column.column])
+		for _, diff := range column.diffs {
+			if diff.Op == jsonDiffOperationRemove {
+				continue
+			}
+			if uint64(len(diff.Value)) >= 1<<uint(8*meta) {
+				return nil, nil, fmt.Errorf("JSON diff value of %v bytes for column %v", len(diff.Value), column.column)
+			}
+			rewritten = append(rewritten, beforeNulls...)
+			afterNulls := bytes.Repeat([]byte{0xff}, bitmapByteSize(bitCount(afterBitmap, columnCount)))
+			afterNulls[nullIndex/8] &^= 1 << uint(nullIndex%8)
+			rewritten = append(rewritten, afterNulls...)
+			for k := 0; k < meta; k++ {
+				rewritten = append(rewritten, byte(len(diff.Value)>>uint(8*k)))
+			}
+			rewritten = append(rewritten, diff.Value...)
+		}
+	}
+	return d.withBody(data, replication.UPDATE_ROWS_EVENTv2, rewritten), partial, nil
+}
+
+// apply decodes the values of the diffs, and sets the full documents of the
+// JSON columns updated in part to e, as rewritten.
+func (p *partialUpdate) apply(e *replication.RowsEvent) error {
+	if len(e.Rows) < p.rowCount {
+		return fmt.Errorf("%v rows, expecting %v", len(e.Rows), p.rowCount)
+	}
+	diffValues := e.Rows[p.rowCount:]
+	e.Rows = e.Rows[:p.rowCount]
+
+	next := 1
+	for _, column := range p.columns {
+		for k := range column.diffs {
+			if column.diffs[k].Op == jsonDiffOperationRemove {
+				continue
+			}
+			if next >= len(diffValues) {
+				return fmt.Errorf("missing JSON diff values")
+			}
+			value, ok := diffValues[next][column.column].([]byte)
+			if !ok {
+				return fmt.Errorf("invalid JSON diff value of column %v", column.column)
+			}
+			column.diffs[k].Value = value
+			next += 2
+		}
+
+		// The document of the before image.
+		doc, ok := e.Rows[column.row][column.column].([]byte)
+		if !ok {
+			return fmt.Errorf("partial JSON update of column %v of a NULL document", column.column)
+		}
+		newDoc, err := applyJsonDiffs(doc, column.diffs)
+		if err != nil {
+			return fmt.Errorf("partial JSON update of column %v: %v", column.column, err)
+		}
+		e.Rows[column.row][column.column] = newDoc
+	}
+	return nil
+}
+
+// readRowImage reads a row image of the columns in bitmap, and returns the
+// value of each column as in the event, nil if NULL or not in bitmap. See
+// RowsEvent.decodeRows().
+func readRowImage(r *byteReader, table *replication.TableMapEvent, bitmap []byte) [][]byte {
+	columnCount := int(table.ColumnCount)
+	values := make([][]byte, columnCount)
+	nullBitmap := r.next(bitmapByteSize(bitCount(bitmap, columnCount)))
+	nullIndex := 0
+	for i := 0; i < columnCount && r.err == nil; i++ {
+		if !isBitSet(bitmap, i) {
+			continue
+		}
+		isNull := isBitSet(nullBitmap, nullIndex)
+		nullIndex++
+		if isNull {
+			continue
+		}
+		n, err := columnValueLength(r.data[r.pos:], table.ColumnType[i], table.ColumnMeta[i])
+		if err != nil {
+			r.err = fmt.Errorf("column %v: %v", i, err)
+			return nil
+		}
+		values[i] = r.next(n)
+	}
+	return values
+}
+
+var decimalCompressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// columnValueLength returns the length of the value of a column at the start
+// of data, see RowsEvent.decodeValue().
+func columnValueLength(data []byte, tp byte, meta uint16) (int, error) {
+	length := int(meta)
+	if tp == gomysql.MYSQL_TYPE_STRING && meta >= 256 {
+		b0, b1 := byte(meta>>8), byte(meta&0xff)
+		if b0&0x30 != 0x30 {
+			length = int(uint16(b1) | uint16((b0&0x30)^0x30)<<4)
+			tp = b0 | 0x30
+		} else {
+			length = int(b1)
+			tp = b0
+		}
+	}
+
+	// The length of the value is in its first prefixSize bytes.
+	prefixSize := 0
+	switch tp {
+	case gomysql.MYSQL_TYPE_NULL:
+		return 0, nil
+	case gomysql.MYSQL_TYPE_TINY, gomysql.MYSQL_TYPE_YEAR:
+		return 1, nil
+	case gomysql.MYSQL_TYPE_SHORT:
+		return 2, nil
+	case gomysql.MYSQL_TYPE_INT24, gomysql.MYSQL_TYPE_DATE, gomysql.MYSQL_TYPE_TIME:
+		return 3, nil
+	case gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_FLOAT, gomysql.MYSQL_TYPE_TIMESTAMP:
+		return 4, nil
+	case gomysql.MYSQL_TYPE_LONGLONG, gomysql.MYSQL_TYPE_DOUBLE, gomysql.MYSQL_TYPE_DATETIME:
+		return 8, nil
+	case gomysql.MYSQL_TYPE_NEWDECIMAL:
+		// see decodeDecimal()
+		precision, decimals := int(meta>>8), int(meta&0xff)
+		if decimals > precision {
+			return 0, fmt.Errorf("invalid decimal meta %v", meta)
+		}
+		integral := precision - decimals
+		return integral/9*4 + decimalCompressedBytes[integral%9] +
+			decimals/9*4 + decimalCompressedBytes[decimals%9], nil
+	case gomysql.MYSQL_TYPE_BIT:
+		nbits := int(meta>>8)*8 + int(meta&0xff)
+		return (nbits + 7) / 8, nil
+	case gomysql.MYSQL_TYPE_TIMESTAMP2:
+		return 4 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_DATETIME2:
+		return 5 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_TIME2:
+		return 3 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_ENUM, gomysql.MYSQL_TYPE_SET:
+		return int(meta & 0xff), nil
+	case gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VAR_STRING, gomysql.MYSQL_TYPE_STRING:
+		// see decodeString()
+		prefixSize = 1
+		if length >= 256 {
+			prefixSize = 2
+		}
+	case gomysql.MYSQL_TYPE_BLOB, gomysql.MYSQL_TYPE_GEOMETRY, gomysql.MYSQL_TYPE_JSON:
+		// see decodeBlob()
+		prefixSize = int(meta)
+		if prefixSize < 1 || prefixSize > 4 {
+			return 0, fmt.Errorf("invalid blob packlen = %d", meta)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported type %d", tp)
+	}
+	if len(data) < prefixSize {
+		return 0, fmt.Errorf("event data too short")
+	}
+	return prefixSize + int(gomysql.FixedLengthInt(data[:prefixSize])), nil
+}
+
+func isBitSet(bitmap []byte, i int) bool {
+	return bitmap[i>>3]&(1<<(uint(i)&7)) > 0
+}
+
+// bitCount returns the number of the first n bits set in bitmap.
+func bitCount(bitmap []byte, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if isBitSet(bitmap, i) {
+			count++
+		}
+	}
+	return count
+}
+
+func bitmapByteSize(columnCount int) int {
+	return (columnCount + 7) / 8
+}
+
+// byteReader reads the fields of an event. Reading past the end sets err, and
+// the reads after it return zero values.
+type byteReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *byteReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.err = fmt.Errorf("event data too short at %v", r.pos)
+		return nil
+	}
+	v := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return v
+}
+
+func (r *byteReader) skip(n int) {
+	r.next(n)
+}
+
+func (r *byteReader) byte() byte {
+	v := r.next(1)
+	if v == nil {
+		return 0
+	}
+	return v[0]
+}
+
+func (r *byteReader) lengthEncodedInt() uint64 {
+	// see gomysql.LengthEncodedInt()
+	switch b := r.byte(); b {
+	case 0xfc:
+		return gomysql.FixedLengthInt(r.next(2))
+	case 0xfd:
+		return gomysql.FixedLengthInt(r.next(3))
+	case 0xfe:
+		return gomysql.FixedLengthInt(r.next(8))
+	default:
+		return uint64(b)
+	}
+}
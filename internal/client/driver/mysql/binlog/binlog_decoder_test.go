@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+const testTableID = 108
+
+// testDecoder returns a decoder of a MySQL 8.0 stream without checksums, and
+// the parser reading the stream in raw mode, as the syncer does.
+func testDecoder(t *testing.T) (*eventDecoder, *replication.BinlogParser) {
+	syncer := replication.NewBinlogParser()
+	syncer.SetRawMode(true)
+	d := newEventDecoder(replication.BinlogSyncerConfig{UseDecimal: true})
+
+	body := make([]byte, 2+50+4)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "8.0.19")
+	body = append(body, replication.EventHeaderSize)
+	lengths := make([]byte, partialUpdateRowsEvent)
+	lengths[replication.TABLE_MAP_EVENT-1] = 8
+	lengths[replication.WRITE_ROWS_EVENTv2-1] = 10
+	lengths[replication.UPDATE_ROWS_EVENTv2-1] = 10
+	lengths[replication.DELETE_ROWS_EVENTv2-1] = 10
+	lengths[partialUpdateRowsEvent-1] = 10
+	body = append(body, lengths...)
+	body = append(body, replication.BINLOG_CHECKSUM_ALG_OFF, 0, 0, 0, 0)
+	testDecode(t, d, syncer, replication.FORMAT_DESCRIPTION_EVENT, body)
+	return d, syncer
+}
+
+func testRawEvent(eventType replication.EventType, body []byte) []byte {
+	data := make([]byte, replication.EventHeaderSize, replication.EventHeaderSize+len(body))
+	binary.LittleEndian.PutUint32(data, 1500000000)
+	data[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(data[9:], uint32(replication.EventHeaderSize+len(body)))
+	return append(data, body...)
+}
+
+func testDecode(t *testing.T, d *eventDecoder, syncer *replication.BinlogParser,
+	eventType replication.EventType, body []byte) *replication.BinlogEvent {
+
+	data := testRawEvent(eventType, body)
+	raw, err := syncer.Parse(data)
+	if err != nil {
+		t.Fatalf("parse %v: %v", eventType, err)
+	}
+	ev, err := d.decode(raw)
+	if err != nil {
+		t.Fatalf("decode %v: %v", eventType, err)
+	}
+	if !bytes.Equal(ev.RawData, data) || ev.Header.EventType != eventType {
+		t.Fatalf("decode %v: the raw data or the header is not kept", eventType)
+	}
+	return ev
+}
+
+// testTableMap returns a TABLE_MAP_EVENT of db.t (id INT, name VARCHAR(64),
+// doc JSON), with the optional metadata if given.
+func testTableMap(optionalMeta ...[]byte) []byte {
+	body := []byte{testTableID, 0, 0, 0, 0, 0, 1, 0}
+	body = append(body, 2, 'd', 'b', 0, 1, 't', 0)
+	body = append(body, 3, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_JSON)
+	body = append(body, 3, 64, 0, 4)
+	body = append(body, 0x06)
+	for _, meta := range optionalMeta {
+		body = append(body, meta...)
+	}
+	return body
+}
+
+// testColumnNames returns the optional metadata of the column names.
+func testColumnNames(names ...string) []byte {
+	var v []byte
+	for _, name := range names {
+		v = append(v, byte(len(name)))
+		v = append(v, name...)
+	}
+	return append([]byte{tableMapOptMetaColumnName, byte(len(v))}, v...)
+}
+
+func TestEventDecoderTableMap(t *testing.T) {
+	d, syncer := testDecoder(t)
+
+	ev := testDecode(t, d, syncer, replication.TABLE_MAP_EVENT, testTableMap())
+	table := ev.Event.(*tableMapEvent)
+	if string(table.Table) != "t" || table.ColumnCount != 3 || table.columnNames != nil {
+		t.Fatalf("unexpected table map %+v", table)
+	}
+
+	// binlog_row_metadata=FULL, with the signedness of the numeric columns before the names
+	ev = testDecode(t, d, syncer, replication.TABLE_MAP_EVENT,
+		testTableMap([]byte{1, 1, 0x00}, testColumnNames("id", "name", "doc")))
+	table = ev.Event.(*tableMapEvent)
+	if string(table.Schema) != "db" || table.TableID != testTableID ||
+		!reflect.DeepEqual(table.columnNames, []string{"id", "name", "doc"}) {
+		t.Fatalf("unexpected table map %+v", table)
+	}
+
+	// INSERT INTO t VALUES (1, 'a', NULL)
+	body := []byte{testTableID, 0, 0, 0, 0, 0, 1, 0, 2, 0, 3, 0x07}
+	body = append(body, 0x04, 1, 0, 0, 0, 1, 'a')
+	ev = testDecode(t, d, syncer, replication.WRITE_ROWS_EVENTv2, body)
+	rows := ev.Event.(*replication.RowsEvent)
+	if !reflect.DeepEqual(rows.Rows, [][]interface{}{{int32(1), "a", nil}}) {
+		t.Fatalf("unexpected rows %v", rows.Rows)
+	}
+
+	columnNames := testColumnNames("id")
+	if _, _, err := d.cutTableMapMeta(testRawEvent(replication.TABLE_MAP_EVENT, testTableMap(columnNames))); err == nil {
+		t.Fatalf("expect an error for the column names of another table")
+	}
+	columnNames = testColumnNames("id", "name", "doc")
+	truncated := testTableMap(columnNames[:len(columnNames)-2])
+	if _, _, err := d.cutTableMapMeta(testRawEvent(replication.TABLE_MAP_EVENT, truncated)); err == nil {
+		t.Fatalf("expect an error for truncated optional metadata")
+	}
+}
+
+func TestEventDecoderResynced(t *testing.T) {
+	d, syncer := testDecoder(t)
+	fakeRotate := func() (*replication.BinlogEvent, error) {
+		data := testRawEvent(replication.ROTATE_EVENT, append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, "mysql-bin.000002"...))
+		// no timestamp
+		binary.LittleEndian.PutUint32(data, 0)
+		raw, err := syncer.Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.decode(raw)
+	}
+
+	if _, err := fakeRotate(); err != nil {
+		t.Fatalf("the stream starts with a fake rotate: %v", err)
+	}
+	if _, err := fakeRotate(); err != errSyncerResynced {
+		t.Fatalf("expect errSyncerResynced, got %v", err)
+	}
+}
+
+func TestEventDecoderPartialUpdate(t *testing.T) {
+	d, syncer := testDecoder(t)
+	testDecode(t, d, syncer, replication.TABLE_MAP_EVENT, testTableMap())
+
+	// {"a": 1}, in binary JSON
+	doc := []byte{0x00, 1, 0, 12, 0, 11, 0, 1, 0, 0x05, 1, 0, 'a'}
+	row := func(id byte, name string, doc []byte) []byte {
+		v := []byte{0x00, id, 0, 0, 0, byte(len(name))}
+		v = append(v, name...)
+		v = append(v, byte(len(doc)), 0, 0, 0)
+		return append(v, doc...)
+	}
+	// JSON_SET(doc, '$.a', 2, '$.c', 'x'), JSON_REMOVE(doc, '$.a')
+	diffs := []byte{0, 3, '$', '.', 'a', 3, 0x05, 2, 0}
+	diffs = append(diffs, 1, 3, '$', '.', 'c', 3, 0x0c, 1, 'x')
+	removed := []byte{2, 3, '$', '.', 'a'}
+
+	body := []byte{testTableID, 0, 0, 0, 0, 0, 1, 0, 2, 0, 3, 0x07, 0x07}
+	body = append(body, row(1, "a", doc)...)
+	body = append(body, 1, 0x01)
+	body = append(body, row(1, "b", diffs)...)
+	// not in part
+	body = append(body, row(2, "c", doc)...)
+	body = append(body, 0)
+	body = append(body, row(2, "c", doc)...)
+	body = append(body, row(3, "d", doc)...)
+	body = append(body, 1, 0x01)
+	body = append(body, row(3, "d", removed)...)
+
+	ev := testDecode(t, d, syncer, partialUpdateRowsEvent, body)
+	rows := ev.Event.(*replication.RowsEvent)
+	if len(rows.Rows) != 6 {
+		t.Fatalf("unexpected rows %v", rows.Rows)
+	}
+	for i, want := range []string{`{"a":1}`, `{"a":2,"c":"x"}`, `{"a":1}`, `{"a":1}`, `{"a":1}`, `{}`} {
+		if got, _ := rows.Rows[i][2].([]byte); string(got) != want {
+			t.Fatalf("row %v: got %s, want %s", i, got, want)
+		}
+	}
+	if rows.Rows[1][0] != int32(1) || rows.Rows[1][1] != "b" || rows.Rows[5][1] != "d" {
+		t.Fatalf("unexpected rows %v", rows.Rows)
+	}
+
+	// binlog_row_image=MINIMAL
+	testDecode(t, d, syncer, replication.TABLE_MAP_EVENT, testTableMap())
+	body = []byte{testTableID, 0, 0, 0, 0, 0, 1, 0, 2, 0, 3, 0x01, 0x04}
+	body = append(body, 0x00, 1, 0, 0, 0)
+	body = append(body, 1, 0x01, 0x00, byte(len(diffs)), 0, 0, 0)
+	body = append(body, diffs...)
+	raw, err := syncer.Parse(testRawEvent(partialUpdateRowsEvent, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.decode(raw); err == nil {
+		t.Fatalf("expect an error without the before image")
+	}
+}
@@ -12,6 +12,10 @@ import (
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
 )
 
+// PartitionAll is the partition of an entry of a partitioned stream to be applied
+// after all the previous entries of every partition.
+const PartitionAll = -1
+
 type BinlogEntries struct {
 	Entries []*BinlogEntry
 }
@@ -23,6 +27,22 @@ type BinlogEntry struct {
 
 	Events       []DataEvent
 	OriginalSize int // size of binlog entry
+	// The partition (1..n) of the stream, or PartitionAll. 0 if not partitioned.
+	Partition int
+	// The time of the transaction on the source, in unix seconds, from the GTID event.
+	Timestamp uint32
+	// The rows of a table resynced by the Src task, or the statement emptying it,
+	// instead of a transaction of the source. It has no GTID.
+	Resync bool
+	// A Resync entry replacing the tables recorded as copied on the target,
+	// nil for the other entries.
+	CopiedTables *CopiedTables
+}
+
+// CopiedTables are the tables of a job copied to the target, by the full copy
+// or once added to the job.
+type CopiedTables struct {
+	Tables []SchemaTable
 }
 
 // NewBinlogEntry creates an empty, ready to go BinlogEntry object
@@ -8,6 +8,7 @@ package binlog
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/siddontang/go-mysql/replication"
 
@@ -29,7 +30,8 @@ func ToEventDML(eventType replication.EventType) EventDML {
 	switch eventType {
 	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
 		return InsertDML
-	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		partialUpdateRowsEvent:
 		return UpdateDML
 	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
 		return DeleteDML
@@ -87,6 +89,12 @@ type DataEvent struct {
 	Table             *config.Table // TODO tmp solution
 	LogPos            int64         // for kafka. The pos of WRITE_ROW_EVENT
 	TableItem         interface{}
+	// Names of the columns of the values, from the full row metadata
+	// (binlog_row_metadata=FULL) of MySQL 8.0. Empty if not available.
+	ColumnNames []string
+	// The kind of the event of an online schema change recorded on the target,
+	// see online_schema_change.go, if it is one.
+	OnlineSchemaChange string
 }
 
 func NewDataEvent(databaseName, tableName string, dml EventDML, columnCount int) DataEvent {
@@ -118,6 +126,83 @@ func NewQueryEventAffectTable(currentSchema, query string, dml EventDML, affecte
 	return event
 }
 
+// MapColumns maps the values to columns, the columns of the target table, by
+// the names of ColumnNames, or else by ordinal position. The columns of the
+// source and of the target that do not match are handled by columnMismatch.
+// It returns the columns the values are mapped to: with
+// ColumnMismatchFillDefaults, those missing in the source are left out to get
+// their defaults.
+func (b *DataEvent) MapColumns(columns *mysql.ColumnList, columnMismatch string) (*mysql.ColumnList, error) {
+	nValues := b.ColumnCount
+	if b.NewColumnValues != nil {
+		nValues = len(b.NewColumnValues.AbstractValues)
+	} else if b.WhereColumnValues != nil {
+		nValues = len(b.WhereColumnValues.AbstractValues)
+	}
+	if len(b.ColumnNames) == 0 && nValues == len(columns.Columns) {
+		return columns, nil
+	}
+
+	var ordinals map[string]int
+	if len(b.ColumnNames) > 0 {
+		if len(b.ColumnNames) != nValues {
+			return nil, fmt.Errorf("%v values for %v columns of %v.%v",
+				nValues, len(b.ColumnNames), b.DatabaseName, b.TableName)
+		}
+		ordinals = make(map[string]int, len(b.ColumnNames))
+		for i, name := range b.ColumnNames {
+			ordinals[strings.ToLower(name)] = i
+		}
+	}
+	var mapped []mysql.Column
+	var indexes []int
+	var missing []string
+	for i, column := range columns.Columns {
+		ordinal, ok := i, i < nValues
+		if ordinals != nil {
+			ordinal, ok = ordinals[strings.ToLower(column.Name)]
+		}
+		if !ok {
+			missing = append(missing, column.Name)
+			continue
+		}
+		mapped = append(mapped, column)
+		indexes = append(indexes, ordinal)
+	}
+	extra := nValues - len(indexes)
+
+	switch {
+	case extra == 0 && len(missing) == 0:
+	case columnMismatch == config.ColumnMismatchFillDefaults && len(indexes) > 0:
+	case columnMismatch == config.ColumnMismatchIgnoreExtra && len(missing) == 0:
+	default:
+		return nil, fmt.Errorf("columns of %v.%v differ: %v columns of the source are not on the target,"+
+			" columns %v of the target are not in the source. ColumnMismatch is %v",
+			b.DatabaseName, b.TableName, extra, missing, columnMismatch)
+	}
+
+	mapValues := func(values *mysql.ColumnValues) *mysql.ColumnValues {
+		if values == nil {
+			return nil
+		}
+		result := &mysql.ColumnValues{
+			AbstractValues: make([]*interface{}, len(indexes)),
+			ValuesPointers: make([]*interface{}, len(indexes)),
+		}
+		for i, ordinal := range indexes {
+			result.AbstractValues[i] = values.AbstractValues[ordinal]
+			result.ValuesPointers[i] = values.AbstractValues[ordinal]
+		}
+		return result
+	}
+	b.WhereColumnValues = mapValues(b.WhereColumnValues)
+	b.NewColumnValues = mapValues(b.NewColumnValues)
+	if len(missing) == 0 {
+		return columns, nil
+	}
+	return mysql.NewColumnList(mapped), nil
+}
+
 func (b *DataEvent) String() string {
 	return fmt.Sprintf("[%+v on %s:%s]", b.DML, b.DatabaseName, b.TableName)
 }
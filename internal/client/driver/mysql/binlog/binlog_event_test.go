@@ -0,0 +1,103 @@
+package binlog
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func checkValues(t *testing.T, values *mysql.ColumnValues, want ...interface{}) {
+	if len(values.AbstractValues) != len(want) {
+		t.Fatalf("values %v, want %v", values, want)
+	}
+	for i := range want {
+		if *values.AbstractValues[i] != want[i] || *values.ValuesPointers[i] != want[i] {
+			t.Fatalf("value %v is %v, want %v", i, *values.AbstractValues[i], want[i])
+		}
+	}
+}
+
+func TestDataEvent_MapColumns_ByName(t *testing.T) {
+	event := NewDataEvent("db1", "tb1", UpdateDML, 3)
+	event.ColumnNames = []string{"id", "name", "Age"}
+	event.WhereColumnValues = mysql.ToColumnValues([]interface{}{1, "a", 10})
+	event.NewColumnValues = mysql.ToColumnValues([]interface{}{1, "b", 11})
+
+	target := mysql.NewColumnList([]mysql.Column{{Name: "age"}, {Name: "id"}, {Name: "name"}})
+	columns, err := event.MapColumns(target, config.ColumnMismatchError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns != target {
+		t.Fatalf("unexpected columns %v", columns.Names())
+	}
+	checkValues(t, event.WhereColumnValues, 10, 1, "a")
+	checkValues(t, event.NewColumnValues, 11, 1, "b")
+}
+
+func TestDataEvent_MapColumns_ByPosition(t *testing.T) {
+	event := NewDataEvent("db1", "tb1", InsertDML, 2)
+	event.NewColumnValues = mysql.ToColumnValues([]interface{}{1, "a"})
+	target := mysql.NewColumnList([]mysql.Column{{Name: "name"}, {Name: "id"}})
+	columns, err := event.MapColumns(target, config.ColumnMismatchError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns != target {
+		t.Fatalf("unexpected columns %v", columns.Names())
+	}
+	checkValues(t, event.NewColumnValues, 1, "a")
+}
+
+func TestDataEvent_MapColumns_Mismatch(t *testing.T) {
+	newEvent := func(names ...string) *DataEvent {
+		event := NewDataEvent("db1", "tb1", InsertDML, 3)
+		event.ColumnNames = names
+		event.NewColumnValues = mysql.ToColumnValues([]interface{}{1, "a", 10})
+		return &event
+	}
+	// The target has no "age", and an extra "email".
+	target := mysql.NewColumnList([]mysql.Column{{Name: "id"}, {Name: "name"}, {Name: "email"}})
+	lessTarget := mysql.NewColumnList([]mysql.Column{{Name: "id"}, {Name: "name"}})
+
+	for _, names := range [][]string{{"id", "name", "age"}, nil} {
+		if _, err := newEvent(names...).MapColumns(lessTarget, config.ColumnMismatchError); err == nil {
+			t.Fatalf("expect an error for an extra column of the source")
+		}
+
+		event := newEvent(names...)
+		columns, err := event.MapColumns(lessTarget, config.ColumnMismatchIgnoreExtra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if columns != lessTarget {
+			t.Fatalf("unexpected columns %v", columns.Names())
+		}
+		checkValues(t, event.NewColumnValues, 1, "a")
+	}
+
+	if _, err := newEvent("id", "name", "age").MapColumns(target, config.ColumnMismatchIgnoreExtra); err == nil {
+		t.Fatalf("expect an error for a column of the target not in the source")
+	}
+	event := newEvent("id", "name", "age")
+	columns, err := event.MapColumns(target, config.ColumnMismatchFillDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := columns.Names(); len(names) != 2 || names[0] != "id" || names[1] != "name" {
+		t.Fatalf("unexpected columns %v", names)
+	}
+	checkValues(t, event.NewColumnValues, 1, "a")
+
+	// By position, the last columns of the target are missing.
+	positional := NewDataEvent("db1", "tb1", InsertDML, 1)
+	positional.NewColumnValues = mysql.ToColumnValues([]interface{}{1})
+	columns, err = positional.MapColumns(target, config.ColumnMismatchFillDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := columns.Names(); len(names) != 1 || names[0] != "id" {
+		t.Fatalf("unexpected columns %v", names)
+	}
+}
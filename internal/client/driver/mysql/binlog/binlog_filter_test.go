@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func testFilterReader(doDb ...*config.DataSource) *BinlogReader {
+	b := &BinlogReader{
+		mysqlContext: &config.MySQLDriverConfig{ReplicateDoDb: doDb},
+		ReMap:        make(map[string]*regexp.Regexp),
+	}
+	b.genRegexMap()
+	return b
+}
+
+func TestBinlogReader_matchTable_regex(t *testing.T) {
+	where := &config.Table{TableName: "~^log_", Where: "id > 10"}
+	b := testFilterReader(
+		&config.DataSource{TableSchema: "~^shard_[0-9]+$"},
+		&config.DataSource{TableSchema: "app", Tables: []*config.Table{where}},
+	)
+	for _, c := range []struct {
+		schema, table string
+		want          bool
+	}{
+		{"shard_1", "t1", true},
+		{"shard_12", "", true}, // create database
+		{"shard_x", "t1", false},
+		{"app", "log_2020", true},
+		{"app", "", true},
+		{"app", "user", false},
+		{"other", "log_2020", false},
+	} {
+		if got := b.matchTable(b.mysqlContext.ReplicateDoDb, c.schema, c.table); got != c.want {
+			t.Errorf("matchTable(%v, %v) = %v, want %v", c.schema, c.table, got, c.want)
+		}
+	}
+
+	if got := b.matchTablePattern("app", "log_2020"); got != where {
+		t.Errorf("matchTablePattern(app, log_2020) = %v, want %v", got, where)
+	}
+	if got := b.matchTablePattern("app", "user"); got != nil {
+		t.Errorf("matchTablePattern(app, user) = %v, want nil", got)
+	}
+}
@@ -27,7 +27,6 @@ import (
 	"github.com/satori/go.uuid"
 	gomysql "github.com/siddontang/go-mysql/mysql"
 	"github.com/siddontang/go-mysql/replication"
-	"golang.org/x/net/context"
 
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
@@ -45,8 +44,13 @@ type BinlogReader struct {
 	logger                   *log.Entry
 	connectionConfig         *mysql.ConnectionConfig
 	db                       *gosql.DB
+	binlogSyncerConfig       replication.BinlogSyncerConfig
 	binlogSyncer             *replication.BinlogSyncer
 	binlogStreamer           *replication.BinlogStreamer
+	eventDecoder             *eventDecoder
+	resumeGtidSet            *gomysql.MysqlGTIDSet // to resume the stream after a reconnection
+	txGtid                   *gomysql.UUIDSet
+	txRead                   bool
 	currentCoordinates       base.BinlogCoordinateTx
 	currentCoordinatesMutex  *sync.Mutex
 	LastAppliedRowsEventHint base.BinlogCoordinateTx
@@ -54,22 +58,58 @@ type BinlogReader struct {
 	mysqlContext *config.MySQLDriverConfig
 	// dynamic config, include all tables (implicitly assigned or dynamically created)
 	tables map[string](map[string]*config.TableContext)
+	// Guards the tables and mysqlContext.ReplicateDoDb, changed by UpdateTables
+	// while an event is not being handled.
+	tablesMutex sync.Mutex
 
 	currentTx          *BinlogTx
 	currentBinlogEntry *BinlogEntry
 	txCount            int
 	currentFde         string
 	currentQuery       *bytes.Buffer
+	// The column names of the tables mapped in the transaction being read,
+	// by table id, for the ones with them.
+	tableColumnNames map[uint64][]string
 	currentSqlB64      *bytes.Buffer
 	appendB64SqlBs     []byte
 	ReMap              map[string]*regexp.Regexp
 
+	// The gtid set read so far, from the one the stream started at, and the
+	// timestamp of the last transaction read. A transaction is added once its
+	// entry is sent. Guarded by currentCoordinatesMutex.
+	readGtidSet   *gomysql.MysqlGTIDSet
+	readTimestamp uint32
+
+	// The shared binlog stream read with SharedBinlogReader, instead of binlogStreamer.
+	sharedStream *binlogSubscription
+	// Allocates the server_id of the shared binlog stream started by the
+	// reader, if set, instead of registering with that of the reader.
+	SharedServerIDAllocator ServerIDAllocator
+
 	wg           sync.WaitGroup
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
 
 	sqlFilter    *SqlFilter
+
+	// The policy of each category of UnsupportedStatements, and how many
+	// statements of it were met.
+	unsupportedPolicies map[string]string
+	unsupportedCounts   map[string]int64
+	unsupportedMutex    sync.Mutex
+
+	// The ALTERs of the ghost tables of the online schema changes, by the
+	// schema.table they are swapped with.
+	oscAlters map[string][]string
+	// Whether the ALTERs of the online schema changes are recorded on the
+	// target instead of in oscAlters, see online_schema_change.go.
+	RecordOnlineSchemaChanges bool
+
+	// If set, called with a table created on the source which matches the
+	// filters, when its CREATE TABLE is read. The events of the table are read
+	// from then on.
+	NewTableHook func(table *config.Table)
 }
 
 type SqlFilter struct {
@@ -111,6 +151,10 @@ func NewMySQLReader(cfg *config.MySQLDriverConfig, logger *log.Entry, replicateD
 	if err != nil {
 		return nil, err
 	}
+	unsupportedPolicies, err := parseUnsupportedPolicies(cfg.UnsupportedStatements)
+	if err != nil {
+		return nil, err
+	}
 
 	binlogReader = &BinlogReader{
 		logger:                  logger,
@@ -122,6 +166,9 @@ func NewMySQLReader(cfg *config.MySQLDriverConfig, logger *log.Entry, replicateD
 		shutdownCh:              make(chan struct{}),
 		tables:                  make(map[string](map[string]*config.TableContext)),
 		sqlFilter:               sqlFilter,
+		unsupportedPolicies:     unsupportedPolicies,
+		unsupportedCounts:       make(map[string]int64),
+		oscAlters:               make(map[string][]string),
 	}
 
 	for _, db := range replicateDoDb {
@@ -138,34 +185,46 @@ func NewMySQLReader(cfg *config.MySQLDriverConfig, logger *log.Entry, replicateD
 		return nil, err
 	}
 
-	id, err := util.NewIdWorker(2, 3, util.SnsEpoch)
-	if err != nil {
-		return nil, err
-	}
-	sid, err := id.NextId()
-	if err != nil {
-		return nil, err
-	}
-	bid := []byte(strconv.FormatUint(uint64(sid), 10))
-	serverId, err := strconv.ParseUint(string(bid), 10, 32)
-	if err != nil {
-		return nil, err
+	serverId := uint64(cfg.ServerID)
+	if serverId == 0 {
+		id, err := util.NewIdWorker(2, 3, util.SnsEpoch)
+		if err != nil {
+			return nil, err
+		}
+		sid, err := id.NextId()
+		if err != nil {
+			return nil, err
+		}
+		bid := []byte(strconv.FormatUint(uint64(sid), 10))
+		serverId, err = strconv.ParseUint(string(bid), 10, 32)
+		if err != nil {
+			return nil, err
+		}
 	}
 	logger.Debug("job.start: debug server id is :", serverId)
 	// support regex
 	binlogReader.genRegexMap()
 
-	binlogSyncerConfig := replication.BinlogSyncerConfig{
+	binlogReader.binlogSyncerConfig = replication.BinlogSyncerConfig{
 		ServerID:       uint32(serverId),
 		Flavor:         "mysql",
 		Host:           cfg.ConnectionConfig.Host,
 		Port:           uint16(cfg.ConnectionConfig.Port),
 		User:           cfg.ConnectionConfig.User,
 		Password:       cfg.ConnectionConfig.Password,
-		RawModeEnabled: false,
+		// The events are decoded by eventDecoder.
+		RawModeEnabled: true,
 		UseDecimal:     true,
+		// The syncer acks each event once it is received.
+		SemiSyncEnabled: cfg.SemiSync,
+		// A corrupted event fails the task, instead of applying garbled rows.
+		VerifyChecksum: true,
+		// A broken stream is reconnected by getEvent, with a backoff.
+		HeartbeatPeriod:      binlogHeartbeatPeriod,
+		ReadTimeout:          binlogReadTimeout,
+		MaxReconnectAttempts: 1,
 	}
-	binlogReader.binlogSyncer = replication.NewBinlogSyncer(binlogSyncerConfig)
+	binlogReader.binlogSyncer = replication.NewBinlogSyncer(binlogReader.binlogSyncerConfig)
 	binlogReader.mysqlContext.Stage = models.StageRegisteringSlaveOnMaster
 
 	return binlogReader, err
@@ -194,6 +253,72 @@ func (b *BinlogReader) addTableToTableMap(tableMap map[string]*config.TableConte
 	return nil
 }
 
+// refreshTable reads the columns of the table from the source, after it is
+// created or altered, into its context, and returns whether it had one.
+func (b *BinlogReader) refreshTable(realSchema, tableName string) (table *config.Table, known bool, err error) {
+	columns, err := base.GetTableColumns(b.db, realSchema, tableName)
+	if err != nil {
+		b.logger.Warnf("error handle create table in binlog: GetTableColumns: %v", err.Error())
+	}
+	err = base.ApplyColumnTypes(b.db, realSchema, tableName, columns)
+	if err != nil {
+		b.logger.Warnf("error handle create table in binlog: ApplyColumnTypes: %v", err.Error())
+	}
+
+	for i := range b.mysqlContext.ReplicateDoDb {
+		// TODO escape name before comparing?
+		if b.mysqlContext.ReplicateDoDb[i].TableSchema == realSchema {
+			for j := range b.mysqlContext.ReplicateDoDb[i].Tables {
+				if b.mysqlContext.ReplicateDoDb[i].Tables[j].TableName == tableName {
+					table = b.mysqlContext.ReplicateDoDb[i].Tables[j]
+				}
+			}
+		}
+	}
+	if table == nil {
+		// all db copy, or a table matching a regex
+		table = config.NewTable(realSchema, tableName)
+		table.TableType = "BASE TABLE"
+		table.Where = "true"
+		if pattern := b.matchTablePattern(realSchema, tableName); pattern != nil && pattern.Where != "" {
+			table.Where = pattern.Where
+		}
+	}
+	table.OriginalTableColumns = columns
+	tableMap := b.getDbTableMap(realSchema)
+	_, known = tableMap[tableName]
+	err = b.addTableToTableMap(tableMap, table)
+	if err != nil {
+		b.logger.Errorf("failed to make table context: %v", err)
+		return nil, false, err
+	}
+	return table, known, nil
+}
+
+// UpdateTables changes the tables replicated to replicateDoDb while reading:
+// the events of the tables added are read from the next one on, and those of
+// the tables removed are skipped.
+func (b *BinlogReader) UpdateTables(replicateDoDb []*config.DataSource, added, removed []*config.Table) error {
+	b.tablesMutex.Lock()
+	defer b.tablesMutex.Unlock()
+
+	for _, table := range added {
+		if err := b.addTableToTableMap(b.getDbTableMap(table.TableSchema), table); err != nil {
+			return err
+		}
+	}
+	for _, table := range removed {
+		tableMap := b.tables[table.TableSchema]
+		delete(tableMap, table.TableName)
+		// An empty table map is a whole schema.
+		if len(tableMap) == 0 {
+			delete(b.tables, table.TableSchema)
+		}
+	}
+	b.mysqlContext.ReplicateDoDb = replicateDoDb
+	return nil
+}
+
 // ConnectBinlogStreamer
 func (b *BinlogReader) ConnectBinlogStreamer(coordinates base.BinlogCoordinatesX) (err error) {
 	if coordinates.IsEmpty() {
@@ -213,10 +338,32 @@ func (b *BinlogReader) ConnectBinlogStreamer(coordinates base.BinlogCoordinatesX
 	if err != nil {
 		b.logger.Errorf("mysql.reader: err: %v", err)
 	}
+	if resumeGtidSet, err := gomysql.ParseMysqlGTIDSet(coordinates.GtidSet); err == nil {
+		b.resumeGtidSet = resumeGtidSet.(*gomysql.MysqlGTIDSet)
+	}
+	if readGtidSet, err := gomysql.ParseMysqlGTIDSet(coordinates.GtidSet); err == nil {
+		b.currentCoordinatesMutex.Lock()
+		b.readGtidSet = readGtidSet.(*gomysql.MysqlGTIDSet)
+		b.currentCoordinatesMutex.Unlock()
+	}
+	if b.mysqlContext.SharedBinlogReader && b.resumeGtidSet != nil {
+		b.sharedStream, err = subscribeBinlogSource(b, b.resumeGtidSet)
+		if err != nil {
+			b.logger.Debugf("mysql.reader: err at subscribeBinlogSource: %v", err)
+			return err
+		}
+		if b.sharedStream != nil {
+			b.mysqlContext.Stage = models.StageRequestingBinlogDump
+			return nil
+		}
+		b.logger.Warnf("mysql.reader: the shared binlog stream has read past %v. streaming on its own",
+			coordinates.GtidSet)
+	}
 	b.binlogStreamer, err = b.binlogSyncer.StartSyncGTID(gtidSet)
 	if err != nil {
 		b.logger.Debugf("mysql.reader: err at StartSyncGTID: %v", err)
 	}
+	b.eventDecoder = newEventDecoder(b.binlogSyncerConfig)
 	b.mysqlContext.Stage = models.StageRequestingBinlogDump
 
 	return err
@@ -229,6 +376,17 @@ func (b *BinlogReader) GetCurrentBinlogCoordinates() *base.BinlogCoordinateTx {
 	return &returnCoordinates
 }
 
+// GetReadProgress returns the gtid set read so far and the timestamp of the last
+// transaction read, 0 if none.
+func (b *BinlogReader) GetReadProgress() (string, uint32) {
+	b.currentCoordinatesMutex.Lock()
+	defer b.currentCoordinatesMutex.Unlock()
+	if b.readGtidSet == nil {
+		return "", b.readTimestamp
+	}
+	return b.readGtidSet.String(), b.readTimestamp
+}
+
 func ToColumnValuesV2(abstractValues []interface{}, table *config.TableContext) *mysql.ColumnValues {
 	result := &mysql.ColumnValues{
 		AbstractValues: make([]*interface{}, len(abstractValues)),
@@ -306,6 +464,9 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 		b.currentCoordinates.LastCommitted = evt.LastCommitted
 		b.currentCoordinates.SeqenceNumber = evt.SequenceNumber
 		b.currentBinlogEntry = NewBinlogEntryAt(b.currentCoordinates)
+		b.currentBinlogEntry.Timestamp = ev.Header.Timestamp
+		b.readTimestamp = ev.Header.Timestamp
+		b.tableColumnNames = nil
 	case replication.QUERY_EVENT:
 		evt := ev.Event.(*replication.QueryEvent)
 		query := string(evt.Query)
@@ -315,6 +476,9 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 		if strings.ToUpper(query) == "BEGIN" {
 			b.currentBinlogEntry.hasBeginQuery = true
 		} else {
+			if category := unsupportedStatement(query, b.mysqlContext.ExpandSyntaxSupport); category != "" {
+				return b.handleUnsupported(category, query, string(evt.Schema))
+			}
 			if strings.ToUpper(query) == "COMMIT" || !b.currentBinlogEntry.hasBeginQuery {
 				currentSchema := string(evt.Schema)
 				if b.mysqlContext.SkipCreateDbTable {
@@ -324,11 +488,17 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 					}
 				}
 
-				if !b.mysqlContext.ExpandSyntaxSupport {
-					if skipQueryEvent(query) {
-						b.logger.Warnf("mysql.reader: skip query %s", query)
+				if events, handled, err := b.handleOnlineSchemaChange(query, currentSchema); err != nil {
+					return err
+				} else if handled {
+					if len(events) == 0 {
+						b.logger.Debugf("mysql.reader: skip a statement of an online schema change: %s", query)
 						return nil
 					}
+					b.currentBinlogEntry.Events = append(b.currentBinlogEntry.Events, events...)
+					entriesChannel <- b.currentBinlogEntry
+					b.LastAppliedRowsEventHint = b.currentCoordinates
+					return nil
 				}
 
 				ddlInfo, err := resolveDDLSQL(query)
@@ -372,39 +542,16 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 					case DDLCreateTable, DDLAlterTable:
 						// create table is not ignored
 						b.logger.Debugf("mysql.reader: ddl is create table")
-						columns, err := base.GetTableColumns(b.db, realSchema, tableName)
+						table, known, err := b.refreshTable(realSchema, tableName)
 						if err != nil {
-							b.logger.Warnf("error handle create table in binlog: GetTableColumns: %v", err.Error())
-						}
-						err = base.ApplyColumnTypes(b.db, realSchema, tableName, columns)
-						if err != nil {
-							b.logger.Warnf("error handle create table in binlog: ApplyColumnTypes: %v", err.Error())
+							return err
 						}
-
-						var table *config.Table
-						for i := range b.mysqlContext.ReplicateDoDb {
-							// TODO escape name before comparing?
-							if b.mysqlContext.ReplicateDoDb[i].TableSchema == realSchema {
-								for j := range b.mysqlContext.ReplicateDoDb[i].Tables {
-									if b.mysqlContext.ReplicateDoDb[i].Tables[j].TableName == tableName {
-										table = b.mysqlContext.ReplicateDoDb[i].Tables[j]
-									}
-								}
+						if ddlInfo.ddlType == DDLCreateTable && !known {
+							b.logger.Printf("mysql.reader: found the new table %v.%v", realSchema, tableName)
+							if b.NewTableHook != nil {
+								b.NewTableHook(table)
 							}
 						}
-						if table == nil {
-							// all db copy
-							table = config.NewTable(realSchema, tableName)
-							table.TableType = "BASE TABLE"
-							table.Where = "true"
-						}
-						table.OriginalTableColumns = columns
-						tableMap := b.getDbTableMap(realSchema)
-						err = b.addTableToTableMap(tableMap, table)
-						if err != nil {
-							b.logger.Error("failed to make table context: %v", err)
-							return err
-						}
 					}
 
 					event := NewQueryEventAffectTable(
@@ -419,9 +566,20 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 				b.LastAppliedRowsEventHint = b.currentCoordinates
 			}
 		}
+	case replication.TABLE_MAP_EVENT:
+		evt := ev.Event.(*tableMapEvent)
+		if len(evt.columnNames) != 0 {
+			if b.tableColumnNames == nil {
+				b.tableColumnNames = make(map[uint64][]string)
+			}
+			b.tableColumnNames[evt.TableID] = evt.columnNames
+		}
 	case replication.XID_EVENT:
 		entriesChannel <- b.currentBinlogEntry
 		b.LastAppliedRowsEventHint = b.currentCoordinates
+	case replication.EXECUTE_LOAD_QUERY_EVENT:
+		// The query of the event is not decoded.
+		return b.handleUnsupported(UnsupportedLoadData, "LOAD DATA", executeLoadQuerySchema(ev))
 	default:
 		if rowsEvent, ok := ev.Event.(*replication.RowsEvent); ok {
 			dml := ToEventDML(ev.Header.EventType)
@@ -453,6 +611,7 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 				int(rowsEvent.ColumnCount),
 			)
 			dmlEvent.LogPos = int64(ev.Header.LogPos - ev.Header.EventSize)
+			dmlEvent.ColumnNames = b.tableColumnNames[rowsEvent.TableID]
 
 			if table != nil && !table.DefChangedSent {
 				dmlEvent.Table = table.Table
@@ -546,7 +705,7 @@ func (b *BinlogReader) DataStreamEvents(entriesChannel chan<- *BinlogEntry) erro
 			break
 		}
 
-		ev, err := b.binlogStreamer.GetEvent(context.Background())
+		ev, err := b.getEvent()
 		if err != nil {
 			return err
 		}
@@ -574,9 +733,21 @@ func (b *BinlogReader) DataStreamEvents(entriesChannel chan<- *BinlogEntry) erro
 				b.logger.Warnf("mysql.reader: fake rotate_event.")
 			}
 		} else {
-			if err := b.handleEvent(ev, entriesChannel); err != nil {
+			b.tablesMutex.Lock()
+			err := b.handleEvent(ev, entriesChannel)
+			b.tablesMutex.Unlock()
+			if err != nil {
 				return err
 			}
+			if b.txRead {
+				// Its entry, if any, is in entriesChannel.
+				b.currentCoordinatesMutex.Lock()
+				if b.readGtidSet != nil {
+					// A copy, as resumeGtidSet may keep txGtid.
+					b.readGtidSet.AddSet(gomysql.NewUUIDSet(b.txGtid.SID, b.txGtid.Intervals...))
+				}
+				b.currentCoordinatesMutex.Unlock()
+			}
 		}
 	}
 
@@ -590,7 +761,7 @@ func (b *BinlogReader) BinlogStreamEvents(txChannel chan<- *BinlogTx) error {
 			break
 		}
 
-		ev, err := b.binlogStreamer.GetEvent(context.Background())
+		ev, err := b.getEvent()
 		if err != nil {
 			return err
 		}
@@ -783,7 +954,7 @@ func (b *BinlogReader) handleBinlogRowsEvent(ev *replication.BinlogEvent, txChan
 		}
 
 	case replication.TABLE_MAP_EVENT:
-		evt := ev.Event.(*replication.TableMapEvent)
+		evt := ev.Event.(*tableMapEvent)
 
 		if b.skipEvent(string(evt.Schema), string(evt.Table)) {
 			//b.logger.Debugf("mysql.reader: skip TableMapEvent at schema: %s,table: %s", fmt.Sprintf("%s", evt.Schema), fmt.Sprintf("%s", evt.Table))
@@ -814,7 +985,8 @@ func (b *BinlogReader) handleBinlogRowsEvent(ev *replication.BinlogEvent, txChan
 		})
 		//tb.addCount(Insert)
 
-	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		partialUpdateRowsEvent:
 		evt := ev.Event.(*replication.RowsEvent)
 		if b.skipEvent(string(evt.Table.Schema), string(evt.Table.Table)) {
 			//b.logger.Debugf("mysql.reader: skip RowsEvent at schema: %s,table: %s", fmt.Sprintf("%s", evt.Table.Schema), fmt.Sprintf("%s", evt.Table.Table))
@@ -1005,9 +1177,10 @@ func (b *BinlogReader) skipQueryDDL(sql string, schema string, tableName string)
 		} else {
 			return true
 		}
-	case "sys", "information_schema", "performance_schema", g.DtleSchemaName:
-		return true
 	default:
+		if b.mysqlContext.IsSystemSchema(schema) {
+			return true
+		}
 		if len(b.mysqlContext.ReplicateDoDb) > 0 {
 			return !b.matchTable(b.mysqlContext.ReplicateDoDb, schema, tableName)
 		}
@@ -1079,9 +1252,10 @@ func (b *BinlogReader) skipEvent(schema string, table string) bool {
 		} else {
 			return true
 		}
-	case "sys", "information_schema", "performance_schema", g.DtleSchemaName:
-		return true
 	default:
+		if b.mysqlContext.IsSystemSchema(schema) {
+			return true
+		}
 		if len(b.mysqlContext.ReplicateDoDb) > 0 {
 			table = strings.ToLower(table)
 			//if table in tartget Table, do this event
@@ -1165,9 +1339,13 @@ func (b *BinlogReader) skipRowEvent(rowsEvent *replication.RowsEvent, dml EventD
 		} else {
 			return true, nil
 		}
-	case "sys", "information_schema", "performance_schema":
-		return true, nil
 	default:
+		if b.mysqlContext.IsSystemSchema(string(rowsEvent.Table.Schema)) {
+			return true, nil
+		}
+		if _, ok := b.onlineSchemaChangeTable(string(rowsEvent.Table.Schema), string(rowsEvent.Table.Table)); ok {
+			return true, nil
+		}
 		if len(b.tables) > 0 {
 			//if table in tartget Table, do this event
 			for schemaName, tableMap := range b.tables {
@@ -1223,10 +1401,13 @@ func (b *BinlogReader) matchDB(patternDBS []*config.DataSource, a string) bool {
 
 func (b *BinlogReader) matchTable(patternTBS []*config.DataSource, schemaName string, tableName string) bool {
 	for _, pdb := range patternTBS {
-		if len(pdb.Tables) == 0 && schemaName == pdb.TableSchema {
-			return true
-		}
 		redb, okdb := b.ReMap[pdb.TableSchema]
+		if len(pdb.Tables) == 0 || tableName == "" {
+			// the whole schema, or create database or drop database
+			if schemaName == pdb.TableSchema || (okdb && redb.MatchString(schemaName)) {
+				return true
+			}
+		}
 		for _, ptb := range pdb.Tables {
 			retb, oktb := b.ReMap[ptb.TableName]
 			if oktb && okdb {
@@ -1245,13 +1426,6 @@ func (b *BinlogReader) matchTable(patternTBS []*config.DataSource, schemaName st
 				}
 			}
 
-			//create database or drop database
-			if tableName == "" {
-				if schemaName == pdb.TableSchema {
-					return true
-				}
-			}
-
 			if ptb.TableSchema == schemaName && ptb.TableName == tableName {
 				return true
 			}
@@ -1262,27 +1436,37 @@ func (b *BinlogReader) matchTable(patternTBS []*config.DataSource, schemaName st
 }
 
 func (b *BinlogReader) genRegexMap() {
-	for _, db := range b.mysqlContext.ReplicateDoDb {
-		if db.TableSchema[0] != '~' {
-			continue
+	add := func(name string) {
+		if strings.HasPrefix(name, "~") {
+			if _, ok := b.ReMap[name]; !ok {
+				b.ReMap[name] = regexp.MustCompile(name[1:])
+			}
 		}
-		if _, ok := b.ReMap[db.TableSchema]; !ok {
-			b.ReMap[db.TableSchema] = regexp.MustCompile(db.TableSchema[1:])
+	}
+	for _, db := range b.mysqlContext.ReplicateDoDb {
+		add(db.TableSchema)
+		// The tables of a schema by name could match a regex too.
+		for _, tb := range db.Tables {
+			add(tb.TableName)
+			add(tb.TableSchema)
 		}
+	}
+}
 
-		for _, tb := range db.Tables {
-			if tb.TableName[0] == '~' {
-				if _, ok := b.ReMap[tb.TableName]; !ok {
-					b.ReMap[tb.TableName] = regexp.MustCompile(tb.TableName[1:])
-				}
-			}
-			if tb.TableSchema[0] == '~' {
-				if _, ok := b.ReMap[tb.TableSchema]; !ok {
-					b.ReMap[tb.TableSchema] = regexp.MustCompile(tb.TableSchema[1:])
-				}
+// matchTablePattern returns the table of ReplicateDoDb the table matches, by
+// name or regex, nil if none.
+func (b *BinlogReader) matchTablePattern(schemaName string, tableName string) *config.Table {
+	for _, pdb := range b.mysqlContext.ReplicateDoDb {
+		if !b.matchString(pdb.TableSchema, schemaName) {
+			continue
+		}
+		for _, ptb := range pdb.Tables {
+			if b.matchString(ptb.TableName, tableName) {
+				return ptb
 			}
 		}
 	}
+	return nil
 }
 
 func (b *BinlogReader) Close() error {
@@ -1296,6 +1480,9 @@ func (b *BinlogReader) Close() error {
 	close(b.shutdownCh)
 
 	b.wg.Wait()
+	if b.sharedStream != nil {
+		b.sharedStream.close()
+	}
 	if err := sql.CloseDB(b.db); err != nil {
 		return err
 	}
@@ -0,0 +1,424 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+	"golang.org/x/net/context"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+// The shared binlog streams of this process, by binlogSourceKey.
+var (
+	binlogSources     = make(map[string]*binlogSource)
+	binlogSourcesLock sync.Mutex
+)
+
+// binlogSource is a binlog stream of a source shared by the readers of the jobs
+// with SharedBinlogReader, so that the source has a single replica connection
+// instead of one per job, and each event is read and decoded once. A reader gets
+// all the events but the ones of the transactions it has already, and filters
+// them by its tables as usual. A slow reader holds up the others.
+//
+// The stream registers on the source with its own server_id, allocated by the
+// SharedServerIDAllocator of the reader starting it, as the jobs reading it come
+// and go. The readers change the rows of the rows events they read, so each
+// gets its own copy of them; the other events are only read.
+type binlogSource struct {
+	key              string
+	logger           *log.Entry
+	syncerConfig     replication.BinlogSyncerConfig
+	reconnectTimeout int
+	// Releases the server_id of the stream, nil if none was allocated.
+	releaseServerID func()
+	releaseOnce     sync.Once
+
+	syncer   *replication.BinlogSyncer
+	streamer *replication.BinlogStreamer
+	decoder  *eventDecoder
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu sync.Mutex
+	// The transactions read, where the stream resumes after a reconnection.
+	readGtidSet *gomysql.MysqlGTIDSet
+	// The transaction being read, nil between transactions.
+	txGtid        *gomysql.UUIDSet
+	subscriptions map[*binlogSubscription]struct{}
+	closed        bool
+}
+
+// binlogSubscription is a reader of a binlogSource.
+type binlogSubscription struct {
+	source *binlogSource
+	// The transactions the reader has, not delivered to it.
+	skipGtidSet *gomysql.MysqlGTIDSet
+	// Whether the events of the current transaction are not delivered.
+	skipping bool
+	// A nil event means that the stream was reconnected, dropping the transaction
+	// being read. Closed on a failure of the stream, with err.
+	events chan *replication.BinlogEvent
+	err    error
+	done   chan struct{}
+}
+
+// ServerIDAllocator allocates a server_id to register on a source with, claimed
+// for the owner, and returns the function releasing it.
+type ServerIDAllocator func(owner string) (serverID uint32, release func(), err error)
+
+// binlogSourceKey identifies the source of a reader. The readers share a stream
+// only with the same credentials.
+func binlogSourceKey(cfg replication.BinlogSyncerConfig) string {
+	return fmt.Sprintf("%v:%v/%v/%v/%v", cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.SemiSyncEnabled)
+}
+
+// subscribeBinlogSource subscribes the reader to the shared stream of its
+// source from gtidSet, starting the stream if there is none. It returns nil if
+// the stream has already read past gtidSet, i.e. a transaction not in gtidSet.
+func subscribeBinlogSource(b *BinlogReader, gtidSet *gomysql.MysqlGTIDSet) (*binlogSubscription, error) {
+	binlogSourcesLock.Lock()
+	defer binlogSourcesLock.Unlock()
+
+	key := binlogSourceKey(b.binlogSyncerConfig)
+	if s, ok := binlogSources[key]; ok {
+		sub := s.subscribe(gtidSet)
+		if sub != nil {
+			b.logger.Printf("mysql.reader: sharing the binlog stream of %v:%v",
+				s.syncerConfig.Host, s.syncerConfig.Port)
+		}
+		return sub, nil
+	}
+
+	syncerConfig := b.binlogSyncerConfig
+	var release func()
+	if b.SharedServerIDAllocator != nil {
+		serverID, r, err := b.SharedServerIDAllocator(sharedServerIDOwner(key))
+		if err != nil {
+			return nil, err
+		}
+		syncerConfig.ServerID, release = serverID, r
+	}
+	s := newBinlogSource(key, b.logger, syncerConfig, b.mysqlContext.BinlogReconnectTimeoutSeconds, gtidSet)
+	s.releaseServerID = release
+	if err := s.start(); err != nil {
+		s.release()
+		return nil, err
+	}
+	binlogSources[key] = s
+	b.logger.Printf("mysql.reader: started the shared binlog stream of %v:%v, with server_id %v",
+		s.syncerConfig.Host, s.syncerConfig.Port, s.syncerConfig.ServerID)
+	return s.subscribe(gtidSet), nil
+}
+
+// sharedServerIDOwner returns the owner of the server_id of the shared stream
+// of the key on this host, without the credentials of the key.
+func sharedServerIDOwner(key string) string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("shared-binlog-stream/%s/%x", hostname, sha1.Sum([]byte(key)))
+}
+
+func newBinlogSource(key string, logger *log.Entry, syncerConfig replication.BinlogSyncerConfig,
+	reconnectTimeout int, gtidSet *gomysql.MysqlGTIDSet) *binlogSource {
+
+	return &binlogSource{
+		key:              key,
+		logger:           logger,
+		syncerConfig:     syncerConfig,
+		reconnectTimeout: reconnectTimeout,
+		readGtidSet:      gtidSet.Clone().(*gomysql.MysqlGTIDSet),
+		subscriptions:    make(map[*binlogSubscription]struct{}),
+	}
+}
+
+func (s *binlogSource) start() error {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if err := s.startSync(); err != nil {
+		s.cancel()
+		return err
+	}
+	go s.run()
+	return nil
+}
+
+// startSync replaces the syncer with a new one streaming from readGtidSet.
+func (s *binlogSource) startSync() error {
+	s.mu.Lock()
+	// The syncer keeps updating the gtid set given to it.
+	gtidSet := s.readGtidSet.Clone()
+	s.txGtid = nil
+	s.mu.Unlock()
+
+	if s.syncer != nil {
+		s.syncer.Close()
+	}
+	s.syncer = replication.NewBinlogSyncer(s.syncerConfig)
+	streamer, err := s.syncer.StartSyncGTID(gtidSet)
+	if err != nil {
+		return err
+	}
+	s.streamer = streamer
+	s.decoder = newEventDecoder(s.syncerConfig)
+	return nil
+}
+
+// subscribe returns a subscription from gtidSet, or nil if a transaction not in
+// gtidSet has been read.
+func (s *binlogSource) subscribe(gtidSet *gomysql.MysqlGTIDSet) *binlogSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || !gtidSet.Contain(s.readGtidSet) {
+		return nil
+	}
+	if s.txGtid != nil && !gtidSet.Contain(uuidGtidSet(s.txGtid)) {
+		return nil
+	}
+	sub := &binlogSubscription{
+		source:      s,
+		skipGtidSet: gtidSet.Clone().(*gomysql.MysqlGTIDSet),
+		// The rest of the transaction being read, which it has.
+		skipping: true,
+		events:   make(chan *replication.BinlogEvent),
+		done:     make(chan struct{}),
+	}
+	s.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes sub, and stops the stream after its last subscription.
+func (s *binlogSource) unsubscribe(sub *binlogSubscription) {
+	binlogSourcesLock.Lock()
+	s.mu.Lock()
+	delete(s.subscriptions, sub)
+	close(sub.done)
+	stop := len(s.subscriptions) == 0 && !s.closed
+	if stop {
+		s.closed = true
+		if binlogSources[s.key] == s {
+			delete(binlogSources, s.key)
+		}
+	}
+	s.mu.Unlock()
+	binlogSourcesLock.Unlock()
+
+	if stop {
+		s.logger.Printf("mysql.reader: stopping the shared binlog stream of %v:%v",
+			s.syncerConfig.Host, s.syncerConfig.Port)
+		s.cancel()
+		s.release()
+	}
+}
+
+// release releases the server_id of the stream, once it is stopped.
+func (s *binlogSource) release() {
+	s.releaseOnce.Do(func() {
+		if s.releaseServerID != nil {
+			s.releaseServerID()
+		}
+	})
+}
+
+func (s *binlogSource) run() {
+	defer s.syncer.Close()
+	for {
+		ev, err := s.streamer.GetEvent(s.ctx)
+		if err == nil {
+			ev, err = s.decoder.decode(ev)
+			if err != nil && err != errSyncerResynced {
+				s.fail(err)
+				return
+			}
+		}
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if errors.Cause(err) == replication.ErrChecksumMismatch {
+				s.fail(fmt.Errorf("binlog checksum mismatch, the binlog may be corrupted: %v", err))
+				return
+			}
+			if err := s.reconnect(err); err != nil {
+				s.fail(err)
+				return
+			}
+			// The readers drop the transaction being read.
+			ev = nil
+		} else if ev.Header.EventType == replication.HEARTBEAT_EVENT {
+			continue
+		}
+
+		for i, sub := range s.route(ev) {
+			subEv := ev
+			if i > 0 && ev != nil {
+				subEv = copyRowsEvent(ev)
+			}
+			select {
+			case sub.events <- subEv:
+			case <-sub.done:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// route updates the transaction being read with ev, and returns the
+// subscriptions ev is delivered to.
+func (s *binlogSource) route(ev *replication.BinlogEvent) []*binlogSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []*binlogSubscription
+	if ev == nil {
+		for sub := range s.subscriptions {
+			sub.skipping = false
+			subs = append(subs, sub)
+		}
+		return subs
+	}
+
+	switch ev.Header.EventType {
+	case replication.GTID_EVENT:
+		evt := ev.Event.(*replication.GTIDEvent)
+		u, _ := uuid.FromBytes(evt.SID)
+		s.txGtid = gomysql.NewUUIDSet(u, gomysql.Interval{Start: evt.GNO, Stop: evt.GNO + 1})
+		tx := uuidGtidSet(s.txGtid)
+		for sub := range s.subscriptions {
+			sub.skipping = sub.skipGtidSet.Contain(tx)
+		}
+	case replication.ROTATE_EVENT, replication.FORMAT_DESCRIPTION_EVENT:
+		// Not of a transaction, delivered to all.
+		for sub := range s.subscriptions {
+			subs = append(subs, sub)
+		}
+		return subs
+	}
+
+	for sub := range s.subscriptions {
+		if !sub.skipping {
+			subs = append(subs, sub)
+		}
+	}
+
+	committed := false
+	switch ev.Header.EventType {
+	case replication.XID_EVENT:
+		committed = true
+	case replication.QUERY_EVENT:
+		// a COMMIT, or a DDL without BEGIN
+		evt := ev.Event.(*replication.QueryEvent)
+		committed = !strings.EqualFold(string(evt.Query), "BEGIN")
+	}
+	if committed && s.txGtid != nil {
+		s.readGtidSet.AddSet(s.txGtid)
+		s.txGtid = nil
+	}
+	return subs
+}
+
+// reconnect reconnects the stream from readGtidSet, retrying with a backoff up
+// to reconnectTimeout seconds.
+func (s *binlogSource) reconnect(cause error) error {
+	s.logger.Warnf("mysql.reader: shared binlog stream broken: %v. reconnecting", cause)
+
+	var deadline time.Time
+	if s.reconnectTimeout > 0 {
+		deadline = time.Now().Add(time.Duration(s.reconnectTimeout) * time.Second)
+	}
+	backoff := binlogReconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return cause
+		}
+
+		err := s.startSync()
+		if err == nil {
+			s.logger.Printf("mysql.reader: shared binlog stream reconnected after %v attempts", attempt)
+			return nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("reconnecting shared binlog stream failed for %vs: %v. broken by: %v",
+				s.reconnectTimeout, err, cause)
+		}
+		s.logger.Warnf("mysql.reader: reconnecting shared binlog stream, attempt %v: %v", attempt, err)
+
+		backoff *= 2
+		if backoff > binlogReconnectMaxBackoff {
+			backoff = binlogReconnectMaxBackoff
+		}
+	}
+}
+
+// fail stops the stream, failing the readers with err.
+func (s *binlogSource) fail(err error) {
+	binlogSourcesLock.Lock()
+	s.mu.Lock()
+	s.closed = true
+	if binlogSources[s.key] == s {
+		delete(binlogSources, s.key)
+	}
+	for sub := range s.subscriptions {
+		sub.err = err
+		close(sub.events)
+	}
+	s.subscriptions = make(map[*binlogSubscription]struct{})
+	s.mu.Unlock()
+	binlogSourcesLock.Unlock()
+	s.cancel()
+	s.release()
+}
+
+// copyRowsEvent returns a copy of ev with its own rows if it is a rows event,
+// which the reader may change, e.g. the values of the unsigned columns, or else
+// ev.
+func copyRowsEvent(ev *replication.BinlogEvent) *replication.BinlogEvent {
+	rowsEvent, ok := ev.Event.(*replication.RowsEvent)
+	if !ok {
+		return ev
+	}
+	copied := *rowsEvent
+	copied.Rows = make([][]interface{}, len(rowsEvent.Rows))
+	for i, row := range rowsEvent.Rows {
+		copied.Rows[i] = append([]interface{}(nil), row...)
+	}
+	return &replication.BinlogEvent{RawData: ev.RawData, Header: ev.Header, Event: &copied}
+}
+
+// next returns the next event for the subscription. A nil event means that the
+// stream was reconnected.
+func (sub *binlogSubscription) next(shutdownCh <-chan struct{}) (*replication.BinlogEvent, error) {
+	select {
+	case ev, ok := <-sub.events:
+		if !ok {
+			return nil, sub.err
+		}
+		return ev, nil
+	case <-shutdownCh:
+		return nil, fmt.Errorf("binlog reader is closed")
+	}
+}
+
+func (sub *binlogSubscription) close() {
+	sub.source.unsubscribe(sub)
+}
+
+func uuidGtidSet(set *gomysql.UUIDSet) *gomysql.MysqlGTIDSet {
+	return &gomysql.MysqlGTIDSet{Sets: map[string]*gomysql.UUIDSet{set.SID.String(): set}}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+const testSourceSID = "de278ad0-2106-11e4-9f8e-6edd0ca20947"
+
+func testGtidSet(t *testing.T, s string) *gomysql.MysqlGTIDSet {
+	set, err := gomysql.ParseMysqlGTIDSet(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set.(*gomysql.MysqlGTIDSet)
+}
+
+func testEvent(eventType replication.EventType, event replication.Event) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: eventType},
+		Event:  event,
+	}
+}
+
+// testTx returns the events of the transaction gno of testSourceSID.
+func testTx(gno int64) []*replication.BinlogEvent {
+	sid := uuid.FromStringOrNil(testSourceSID)
+	return []*replication.BinlogEvent{
+		testEvent(replication.GTID_EVENT, &replication.GTIDEvent{SID: sid.Bytes(), GNO: gno}),
+		testEvent(replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")}),
+		testEvent(replication.WRITE_ROWS_EVENTv2, &replication.RowsEvent{}),
+		testEvent(replication.XID_EVENT, &replication.XIDEvent{}),
+	}
+}
+
+// routed returns how many of events are delivered to each of subs.
+func routed(s *binlogSource, events []*replication.BinlogEvent, subs ...*binlogSubscription) []int {
+	n := make([]int, len(subs))
+	for _, ev := range events {
+		for _, sub := range s.route(ev) {
+			for i := range subs {
+				if subs[i] == sub {
+					n[i]++
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestBinlogSource_Route(t *testing.T) {
+	s := newBinlogSource("k", log.New(os.Stderr, log.InfoLevel).WithField("test", t.Name()),
+		replication.BinlogSyncerConfig{}, 0, testGtidSet(t, testSourceSID+":1-5"))
+
+	sub1 := s.subscribe(testGtidSet(t, testSourceSID+":1-5"))
+	// Has the transaction 6 already.
+	sub2 := s.subscribe(testGtidSet(t, testSourceSID+":1-6"))
+	if sub1 == nil || sub2 == nil {
+		t.Fatalf("subscriptions %v %v, want both", sub1, sub2)
+	}
+
+	if n := routed(s, testTx(6), sub1, sub2); n[0] != 4 || n[1] != 0 {
+		t.Errorf("transaction 6 delivered %v times, want 4 and 0", n)
+	}
+	if n := routed(s, testTx(7), sub1, sub2); n[0] != 4 || n[1] != 4 {
+		t.Errorf("transaction 7 delivered %v times, want 4 and 4", n)
+	}
+	if got := s.readGtidSet.String(); got != testSourceSID+":1-7" {
+		t.Errorf("read %v", got)
+	}
+
+	// Behind the stream.
+	if sub := s.subscribe(testGtidSet(t, testSourceSID+":1-6")); sub != nil {
+		t.Errorf("subscribed behind the stream")
+	}
+
+	// In the middle of a transaction, the rest of it is not delivered.
+	tx := testTx(8)
+	routed(s, tx[:2])
+	if sub := s.subscribe(testGtidSet(t, testSourceSID+":1-7")); sub != nil {
+		t.Errorf("subscribed without the transaction being read")
+	}
+	sub3 := s.subscribe(testGtidSet(t, testSourceSID+":1-8"))
+	if sub3 == nil {
+		t.Fatalf("not subscribed with the transaction being read")
+	}
+	if n := routed(s, tx[2:], sub1, sub3); n[0] != 2 || n[1] != 0 {
+		t.Errorf("rest of transaction 8 delivered %v times, want 2 and 0", n)
+	}
+	if n := routed(s, testTx(9), sub3); n[0] != 4 {
+		t.Errorf("transaction 9 delivered %v times, want 4", n)
+	}
+}
+
+func TestBinlogSource_Unsubscribe(t *testing.T) {
+	s := newBinlogSource("k", log.New(os.Stderr, log.InfoLevel).WithField("test", t.Name()),
+		replication.BinlogSyncerConfig{}, 0, testGtidSet(t, testSourceSID+":1-5"))
+	canceled := false
+	s.cancel = func() { canceled = true }
+	released := 0
+	s.releaseServerID = func() { released++ }
+	binlogSourcesLock.Lock()
+	binlogSources[s.key] = s
+	binlogSourcesLock.Unlock()
+
+	sub1 := s.subscribe(testGtidSet(t, testSourceSID+":1-5"))
+	sub2 := s.subscribe(testGtidSet(t, testSourceSID+":1-5"))
+	sub1.close()
+	if canceled {
+		t.Fatalf("stopped with a subscription")
+	}
+	sub2.close()
+	if !canceled {
+		t.Fatalf("not stopped after the last subscription")
+	}
+	if s.fail(nil); released != 1 {
+		t.Errorf("server_id released %v times, want 1", released)
+	}
+	binlogSourcesLock.Lock()
+	defer binlogSourcesLock.Unlock()
+	if _, ok := binlogSources[s.key]; ok {
+		t.Errorf("still registered")
+	}
+}
+
+func TestCopyRowsEvent(t *testing.T) {
+	rows := &replication.RowsEvent{Rows: [][]interface{}{{int32(-1), []byte("a")}}}
+	ev := testEvent(replication.WRITE_ROWS_EVENTv2, rows)
+	copied := copyRowsEvent(ev)
+	copied.Event.(*replication.RowsEvent).Rows[0][0] = uint32(1)
+	if rows.Rows[0][0] != int32(-1) {
+		t.Errorf("the rows of the event changed by its copy: %v", rows.Rows)
+	}
+	if copied.Header != ev.Header {
+		t.Errorf("header not kept")
+	}
+
+	query := testEvent(replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")})
+	if copyRowsEvent(query) != query {
+		t.Errorf("an event other than rows copied")
+	}
+}
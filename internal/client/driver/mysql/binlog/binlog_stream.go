@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+	"golang.org/x/net/context"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// binlogHeartbeatPeriod is the heartbeat period asked to the source, so
+	// that a dead connection is detected by binlogReadTimeout.
+	binlogHeartbeatPeriod = 30 * time.Second
+	binlogReadTimeout     = 3 * binlogHeartbeatPeriod
+
+	binlogReconnectMinBackoff = time.Second
+	binlogReconnectMaxBackoff = 30 * time.Second
+)
+
+// getEvent returns the next event of the binlog stream. If the stream is
+// broken, e.g. the source restarts, the reader registers again as a replica
+// and resumes after the last transaction read, instead of failing.
+func (b *BinlogReader) getEvent() (*replication.BinlogEvent, error) {
+	// The previous event was handled.
+	if b.txRead {
+		b.resumeGtidSet.AddSet(b.txGtid)
+		b.txGtid = nil
+		b.txRead = false
+	}
+
+	for {
+		if b.sharedStream != nil {
+			ev, err := b.sharedStream.next(b.shutdownCh)
+			if err != nil {
+				return nil, err
+			}
+			if ev == nil {
+				b.logger.Warnf("mysql.reader: shared binlog stream reconnected. dropping the transaction being read")
+				b.resetStream()
+				continue
+			}
+			b.trackTx(ev)
+			return ev, nil
+		}
+
+		ev, err := b.binlogStreamer.GetEvent(context.Background())
+		if err == nil {
+			ev, err = b.eventDecoder.decode(ev)
+			// Reading the event again would not help.
+			if err != nil && err != errSyncerResynced {
+				return nil, err
+			}
+		}
+		if err != nil {
+			if errors.Cause(err) == replication.ErrChecksumMismatch {
+				// Reading it again would not help if the binlog file is corrupted.
+				b.currentCoordinatesMutex.Lock()
+				defer b.currentCoordinatesMutex.Unlock()
+				return nil, fmt.Errorf("binlog checksum mismatch for the event at %v:%v, the binlog may be corrupted",
+					b.currentCoordinates.LogFile, b.currentCoordinates.LogPos)
+			}
+			if b.shutdown || b.resumeGtidSet == nil {
+				return nil, err
+			}
+			if err := b.reconnect(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if ev.Header.EventType == replication.HEARTBEAT_EVENT {
+			continue
+		}
+		b.trackTx(ev)
+		return ev, nil
+	}
+}
+
+// trackTx tracks the transaction of ev, added to resumeGtidSet once handled.
+func (b *BinlogReader) trackTx(ev *replication.BinlogEvent) {
+	switch ev.Header.EventType {
+	case replication.GTID_EVENT:
+		evt := ev.Event.(*replication.GTIDEvent)
+		u, _ := uuid.FromBytes(evt.SID)
+		b.txGtid = gomysql.NewUUIDSet(u, gomysql.Interval{Start: evt.GNO, Stop: evt.GNO + 1})
+	case replication.XID_EVENT:
+		b.txRead = b.txGtid != nil
+	case replication.QUERY_EVENT:
+		// a COMMIT, or a DDL without BEGIN
+		evt := ev.Event.(*replication.QueryEvent)
+		if !strings.EqualFold(string(evt.Query), "BEGIN") {
+			b.txRead = b.txGtid != nil
+		}
+	}
+}
+
+// reconnect reconnects the binlog stream from resumeGtidSet, retrying with a
+// backoff up to BinlogReconnectTimeoutSeconds.
+func (b *BinlogReader) reconnect(cause error) error {
+	b.logger.Warnf("mysql.reader: binlog stream broken: %v. reconnecting from %v", cause, b.resumeGtidSet)
+	b.mysqlContext.Stage = models.StageRegisteringSlaveOnMaster
+
+	var deadline time.Time
+	if timeout := b.mysqlContext.BinlogReconnectTimeoutSeconds; timeout > 0 {
+		deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+	}
+	backoff := binlogReconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-b.shutdownCh:
+			return cause
+		}
+
+		err := b.restartSync()
+		if err == nil {
+			b.logger.Printf("mysql.reader: binlog stream reconnected after %v attempts", attempt)
+			return nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("reconnecting binlog stream failed for %vs: %v. broken by: %v",
+				b.mysqlContext.BinlogReconnectTimeoutSeconds, err, cause)
+		}
+		b.logger.Warnf("mysql.reader: reconnecting binlog stream, attempt %v: %v", attempt, err)
+
+		backoff *= 2
+		if backoff > binlogReconnectMaxBackoff {
+			backoff = binlogReconnectMaxBackoff
+		}
+	}
+}
+
+// restartSync replaces the syncer with a new one streaming from
+// resumeGtidSet, and drops the transaction being read.
+func (b *BinlogReader) restartSync() error {
+	b.shutdownLock.Lock()
+	defer b.shutdownLock.Unlock()
+	if b.shutdown {
+		return fmt.Errorf("binlog reader is closed")
+	}
+
+	// The syncer keeps updating the gtid set given to it.
+	gtidSet, err := gomysql.ParseMysqlGTIDSet(b.resumeGtidSet.String())
+	if err != nil {
+		return err
+	}
+	b.binlogSyncer.Close()
+	b.binlogSyncer = replication.NewBinlogSyncer(b.binlogSyncerConfig)
+	streamer, err := b.binlogSyncer.StartSyncGTID(gtidSet)
+	if err != nil {
+		return err
+	}
+	b.binlogStreamer = streamer
+	b.eventDecoder = newEventDecoder(b.binlogSyncerConfig)
+	b.mysqlContext.Stage = models.StageRequestingBinlogDump
+	b.resetStream()
+	return nil
+}
+
+// resetStream drops the transaction being read, after the stream is restarted.
+func (b *BinlogReader) resetStream() {
+	b.txGtid = nil
+	b.txRead = false
+	b.currentTx = nil
+	b.currentBinlogEntry = nil
+	b.currentQuery = nil
+	b.clearB64Sql()
+	// The positions are compared only in the same stream.
+	b.LastAppliedRowsEventHint = base.BinlogCoordinateTx{}
+}
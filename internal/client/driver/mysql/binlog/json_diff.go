@@ -0,0 +1,226 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// binlog_row_value_options, see mysql libbinlogevents/include/rows_event.h
+const rowValueOptionPartialJson = 1
+
+type jsonDiffOperation byte
+
+// see enum_json_diff_operation in mysql sql/json_diff.h
+const (
+	// jsonDiffOperationReplace replaces the value at Path with Value.
+	jsonDiffOperationReplace jsonDiffOperation = iota
+	// jsonDiffOperationInsert inserts Value at Path, into an array or an object.
+	jsonDiffOperationInsert
+	// jsonDiffOperationRemove removes the value at Path.
+	jsonDiffOperationRemove
+)
+
+// jsonDiff is an operation of a partial update of a JSON column.
+type jsonDiff struct {
+	Op jsonDiffOperation
+	// Path is a JSON path, e.g. `$.a[1]`.
+	Path string
+	// Value is the JSON text of the value, empty for jsonDiffOperationRemove.
+	// The binary JSON in the event, until decoded.
+	Value []byte
+}
+
+// readJsonDiffs reads the value of a JSON column updated in part, without its
+// length, which is the list of its diffs, see Json_diff_vector::read_binary()
+// in mysql sql/json_diff.cc.
+func readJsonDiffs(data []byte) ([]jsonDiff, error) {
+	r := &byteReader{data: data}
+	var diffs []jsonDiff
+	for r.err == nil && r.pos < len(data) {
+		diff := jsonDiff{Op: jsonDiffOperation(r.byte())}
+		if diff.Op > jsonDiffOperationRemove {
+			return nil, fmt.Errorf("invalid JSON diff operation %v", diff.Op)
+		}
+		diff.Path = string(r.next(int(r.lengthEncodedInt())))
+		if diff.Op != jsonDiffOperationRemove {
+			diff.Value = r.next(int(r.lengthEncodedInt()))
+		}
+		diffs = append(diffs, diff)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("corrupted JSON diff: %v", r.err)
+	}
+	return diffs, nil
+}
+
+// applyJsonDiffs returns the JSON document doc after the diffs.
+func applyJsonDiffs(doc []byte, diffs []jsonDiff) ([]byte, error) {
+	root, err := decodeJson(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, diff := range diffs {
+		legs, err := parseJsonPath(diff.Path)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if diff.Op != jsonDiffOperationRemove {
+			if value, err = decodeJson(diff.Value); err != nil {
+				return nil, err
+			}
+		}
+		if root, err = applyJsonDiff(root, legs, diff.Op, value); err != nil {
+			return nil, fmt.Errorf("%v at %v", err, diff.Path)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func decodeJson(data []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep the numbers as they are, e.g. a big integer or a decimal.
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonPathLeg is a member (key) or an array cell (index) of a JSON path.
+type jsonPathLeg struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJsonPath parses the path of a JSON diff, such as `$.a."b c"[1]`, which
+// has no wildcard.
+func parseJsonPath(path string) ([]jsonPathLeg, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSON path %v", path)
+	}
+	var legs []jsonPathLeg
+	for rest := path[1:]; rest != ""; {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			var key string
+			if strings.HasPrefix(rest, `"`) {
+				end := 1
+				for ; end < len(rest) && rest[end] != '"'; end++ {
+					if rest[end] == '\\' {
+						end++
+					}
+				}
+				if end >= len(rest) {
+					return nil, fmt.Errorf("invalid JSON path %v", path)
+				}
+				if err := json.Unmarshal([]byte(rest[:end+1]), &key); err != nil {
+					return nil, fmt.Errorf("invalid JSON path %v: %v", path, err)
+				}
+				rest = rest[end+1:]
+			} else {
+				end := strings.IndexAny(rest, ".[")
+				if end < 0 {
+					end = len(rest)
+				}
+				key, rest = rest[:end], rest[end:]
+			}
+			legs = append(legs, jsonPathLeg{key: key})
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %v", path)
+			}
+			index, err := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSON path %v", path)
+			}
+			legs = append(legs, jsonPathLeg{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path %v", path)
+		}
+	}
+	return legs, nil
+}
+
+// applyJsonDiff applies a diff to node at the path legs, and returns the node.
+func applyJsonDiff(node interface{}, legs []jsonPathLeg, op jsonDiffOperation, value interface{}) (interface{}, error) {
+	if len(legs) == 0 {
+		if op != jsonDiffOperationReplace {
+			return nil, fmt.Errorf("cannot insert or remove the document")
+		}
+		return value, nil
+	}
+	leg, last := legs[0], len(legs) == 1
+
+	if leg.isIndex {
+		array, ok := node.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("not an array")
+		}
+		if last && op == jsonDiffOperationInsert {
+			if leg.index >= len(array) {
+				return append(array, value), nil
+			}
+			array = append(array, nil)
+			copy(array[leg.index+1:], array[leg.index:])
+			array[leg.index] = value
+			return array, nil
+		}
+		if leg.index >= len(array) {
+			return nil, fmt.Errorf("no cell %v", leg.index)
+		}
+		if last && op == jsonDiffOperationRemove {
+			return append(array[:leg.index], array[leg.index+1:]...), nil
+		}
+		child, err := applyJsonDiff(array[leg.index], legs[1:], op, value)
+		if err != nil {
+			return nil, err
+		}
+		array[leg.index] = child
+		return array, nil
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an object")
+	}
+	if last && op == jsonDiffOperationInsert {
+		object[leg.key] = value
+		return object, nil
+	}
+	child, ok := object[leg.key]
+	if !ok {
+		return nil, fmt.Errorf("no member %v", leg.key)
+	}
+	if last && op == jsonDiffOperationRemove {
+		delete(object, leg.key)
+		return object, nil
+	}
+	child, err := applyJsonDiff(child, legs[1:], op, value)
+	if err != nil {
+		return nil, err
+	}
+	object[leg.key] = child
+	return object, nil
+}
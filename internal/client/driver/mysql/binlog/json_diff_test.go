@@ -0,0 +1,38 @@
+package binlog
+
+import (
+	"testing"
+)
+
+func TestApplyJsonDiffs(t *testing.T) {
+	doc := []byte(`{"a": {"b c": [1, 2, 3]}, "n": 12345678901234567890, "s": "<x>"}`)
+	diffs := []jsonDiff{
+		{Op: jsonDiffOperationReplace, Path: `$.a."b c"[0]`, Value: []byte(`"one"`)},
+		{Op: jsonDiffOperationInsert, Path: `$.a."b c"[1]`, Value: []byte(`1.5`)},
+		{Op: jsonDiffOperationRemove, Path: `$.a."b c"[3]`},
+		{Op: jsonDiffOperationInsert, Path: `$.d`, Value: []byte(`{"e": null}`)},
+		{Op: jsonDiffOperationInsert, Path: `$.d.f`, Value: []byte(`[]`)},
+		{Op: jsonDiffOperationInsert, Path: `$.d.f[5]`, Value: []byte(`true`)},
+	}
+	got, err := applyJsonDiffs(doc, diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":{"b c":["one",1.5,2]},"d":{"e":null,"f":[true]},"n":12345678901234567890,"s":"<x>"}`
+	if string(got) != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	if _, err := applyJsonDiffs(doc, []jsonDiff{
+		{Op: jsonDiffOperationReplace, Path: `$.x.y`, Value: []byte(`1`)},
+	}); err == nil {
+		t.Fatalf("expect an error for a missing member")
+	}
+
+	got, err = applyJsonDiffs(doc, []jsonDiff{
+		{Op: jsonDiffOperationReplace, Path: `$`, Value: []byte(`[1]`)},
+	})
+	if err != nil || string(got) != `[1]` {
+		t.Fatalf("replace the document: %s, %v", got, err)
+	}
+}
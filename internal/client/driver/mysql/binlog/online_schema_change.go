@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/parser"
+
+	"github.com/actiontech/dtle/utils"
+)
+
+// An online schema change (OSC) by gh-ost or pt-online-schema-change alters a
+// copy of a table, the ghost table, fills it with the rows of the table, and
+// swaps it with the table by RENAME TABLE. The ghost table and the others of
+// the tool are not replicated: the ALTERs of the ghost table are read, and
+// applied to the table on the target when the ghost table is swapped in.
+//
+// The ALTERs read are lost if the task restarts before the swap. If the Dest
+// task answers the checkpoint, they are thus recorded on the target, in the
+// online_schema_changes table of the dtle schema, by events applied in order
+// with the transactions, and applied there on the swap.
+var (
+	// gh-ost: _t_gho. pt-online-schema-change: _t_new, with more leading
+	// underscores if the name is taken.
+	oscGhostTableRegexp = regexp.MustCompile(`^(_+.+)_(gho|new)$`)
+	// gh-ost: _t_ghc (changelog), _t_del or _t_20180102150405_del (the table
+	// swapped out). pt-online-schema-change: _t_old.
+	oscOtherTableRegexp = regexp.MustCompile(`^(_+.+?)(_[0-9]+)?_(ghc|del|old)$`)
+	// The triggers of pt-online-schema-change on the table.
+	oscTriggerRegexp = regexp.MustCompile("(?i)^\\s*(create|drop)\\s+(definer\\s*=\\s*\\S+\\s+)?trigger\\s+(if\\s+exists\\s+)?(`?[^`.\\s]+`?\\.)?`?pt_osc_")
+)
+
+// The OnlineSchemaChange of the events of an OSC recorded on the target.
+const (
+	// The ALTER of the ghost table, as of the table, is recorded.
+	OnlineSchemaChangeAlter = "alter"
+	// The ALTERs recorded are applied to the table, swapped with the ghost
+	// table.
+	OnlineSchemaChangeSwap = "swap"
+	// The ALTERs recorded are dropped, as the ghost table is.
+	OnlineSchemaChangeCancel = "cancel"
+)
+
+// oscTable is a table of an OSC of a table replicated.
+type oscTable struct {
+	// The table replicated.
+	table string
+	// Whether it is the ghost table.
+	ghost bool
+}
+
+// onlineSchemaChangeTable returns the OSC table of a table replicated the table
+// is, if it is.
+func (b *BinlogReader) onlineSchemaChangeTable(schema, table string) (oscTable, bool) {
+	if b.mysqlContext.SkipOnlineSchemaChangeDetection || !strings.HasPrefix(table, "_") {
+		return oscTable{}, false
+	}
+	if m := oscGhostTableRegexp.FindStringSubmatch(table); m != nil {
+		if t := b.oscOriginalTable(schema, m[1]); t != "" {
+			return oscTable{table: t, ghost: true}, true
+		}
+	}
+	if m := oscOtherTableRegexp.FindStringSubmatch(table); m != nil {
+		if t := b.oscOriginalTable(schema, m[1]); t != "" {
+			return oscTable{table: t}, true
+		}
+	}
+	return oscTable{}, false
+}
+
+// oscOriginalTable returns the table replicated an OSC table is of, given the
+// name of the OSC table without its suffix, e.g. _t1 for _t1_gho, or "" if none.
+// It is the name without one or more leading underscores of a table replicated
+// which exists, so that a table named like an OSC table is not taken for one.
+func (b *BinlogReader) oscOriginalTable(schema, name string) string {
+	for i := 0; i+1 < len(name) && name[i] == '_'; i++ {
+		table := name[i+1:]
+		if _, ok := b.tables[schema][table]; ok && !b.skipEvent(schema, table) {
+			return table
+		}
+	}
+	return ""
+}
+
+// newOnlineSchemaChangeEvent returns an event of an OSC of the table recorded on
+// the target.
+func newOnlineSchemaChangeEvent(kind, query string, table SchemaTable) DataEvent {
+	event := NewQueryEventAffectTable("", query, NotDML, table)
+	event.OnlineSchemaChange = kind
+	return event
+}
+
+// handleOnlineSchemaChange handles the query if it is a statement of an OSC of a
+// table replicated. It returns the events replicating it, none if it is only
+// dropped.
+func (b *BinlogReader) handleOnlineSchemaChange(query, currentSchema string) (events []DataEvent, handled bool, err error) {
+	if b.mysqlContext.SkipOnlineSchemaChangeDetection {
+		return nil, false, nil
+	}
+	if oscTriggerRegexp.MatchString(query) {
+		return nil, true, nil
+	}
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return nil, false, nil
+	}
+
+	switch v := stmt.(type) {
+	case *ast.CreateTableStmt:
+		// The ghost table starts as a copy of the table.
+		_, ok := b.onlineSchemaChangeTable(utils.StringElse(v.Table.Schema.O, currentSchema), v.Table.Name.O)
+		return nil, ok, nil
+	case *ast.AlterTableStmt:
+		schema := utils.StringElse(v.Table.Schema.O, currentSchema)
+		osc, ok := b.onlineSchemaChangeTable(schema, v.Table.Name.O)
+		if ok && osc.ghost {
+			key := fmt.Sprintf("%s.%s", schema, osc.table)
+			alter := renameTableInQuery(query, v.Table.Name.O, osc.table)
+			b.logger.Printf("mysql.reader: read the alter of the online schema change of %v: %v", key, query)
+			if !b.RecordOnlineSchemaChanges {
+				b.oscAlters[key] = append(b.oscAlters[key], alter)
+			} else if !b.sqlFilter.NoDDL {
+				events = append(events, newOnlineSchemaChangeEvent(OnlineSchemaChangeAlter, alter,
+					SchemaTable{Schema: schema, Table: osc.table}))
+			}
+		}
+		return events, ok, nil
+	case *ast.DropTableStmt:
+		for _, t := range v.Tables {
+			schema := utils.StringElse(t.Schema.O, currentSchema)
+			osc, ok := b.onlineSchemaChangeTable(schema, t.Name.O)
+			if !ok {
+				return nil, false, nil
+			}
+			if osc.ghost {
+				// The OSC is cancelled.
+				if !b.RecordOnlineSchemaChanges {
+					delete(b.oscAlters, fmt.Sprintf("%s.%s", schema, osc.table))
+				} else if !b.sqlFilter.NoDDL {
+					events = append(events, newOnlineSchemaChangeEvent(OnlineSchemaChangeCancel, "",
+						SchemaTable{Schema: schema, Table: osc.table}))
+				}
+			}
+		}
+		return events, true, nil
+	case *ast.RenameTableStmt:
+		return b.handleOnlineSchemaChangeSwap(v, currentSchema)
+	}
+	return nil, false, nil
+}
+
+// handleOnlineSchemaChangeSwap handles a RENAME TABLE of an OSC: the table to
+// another table of the OSC, and the ghost table to the table, which is then
+// altered on the target as the ghost table was.
+func (b *BinlogReader) handleOnlineSchemaChangeSwap(stmt *ast.RenameTableStmt,
+	currentSchema string) (events []DataEvent, handled bool, err error) {
+	pairs := stmt.TableToTables
+	if len(pairs) == 0 {
+		pairs = []*ast.TableToTable{{OldTable: stmt.OldTable, NewTable: stmt.NewTable}}
+	}
+
+	var swapped []SchemaTable
+	for _, pair := range pairs {
+		oldSchema := utils.StringElse(pair.OldTable.Schema.O, currentSchema)
+		newSchema := utils.StringElse(pair.NewTable.Schema.O, currentSchema)
+		if oldSchema != newSchema {
+			return nil, false, nil
+		}
+		if osc, ok := b.onlineSchemaChangeTable(newSchema, pair.NewTable.Name.O); ok && !osc.ghost &&
+			osc.table == pair.OldTable.Name.O {
+			// The table swapped out.
+			continue
+		}
+		if osc, ok := b.onlineSchemaChangeTable(oldSchema, pair.OldTable.Name.O); ok && osc.ghost &&
+			osc.table == pair.NewTable.Name.O {
+			swapped = append(swapped, SchemaTable{Schema: newSchema, Table: osc.table})
+			continue
+		}
+		return nil, false, nil
+	}
+
+	for _, t := range swapped {
+		key := fmt.Sprintf("%s.%s", t.Schema, t.Table)
+		if b.RecordOnlineSchemaChanges {
+			b.logger.Printf("mysql.reader: the online schema change of %v is swapped in", key)
+			if !b.sqlFilter.NoDDL {
+				events = append(events, newOnlineSchemaChangeEvent(OnlineSchemaChangeSwap, "", t))
+			}
+			if _, _, err := b.refreshTable(t.Schema, t.Table); err != nil {
+				return nil, true, err
+			}
+			continue
+		}
+		alters := b.oscAlters[key]
+		delete(b.oscAlters, key)
+		if len(alters) == 0 {
+			b.logger.Warnf("mysql.reader: the alter of the online schema change of %v was not read, "+
+				"e.g. the task restarted during it. alter the table on the target the same way", key)
+		} else {
+			b.logger.Printf("mysql.reader: the online schema change of %v is swapped in", key)
+		}
+		if !b.sqlFilter.NoDDL {
+			for _, alter := range alters {
+				events = append(events, NewQueryEventAffectTable(t.Schema, alter, NotDML, t))
+			}
+		}
+		if _, _, err := b.refreshTable(t.Schema, t.Table); err != nil {
+			return nil, true, err
+		}
+	}
+	return events, true, nil
+}
+
+// renameTableInQuery replaces the name of the table in the query, quoted or not,
+// with the quoted newName.
+func renameTableInQuery(query, oldName, newName string) string {
+	re := regexp.MustCompile("`" + regexp.QuoteMeta(oldName) + "`|\\b" + regexp.QuoteMeta(oldName) + "\\b")
+	return re.ReplaceAllLiteralString(query, "`"+newName+"`")
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func testOSCReader(t *testing.T) *BinlogReader {
+	b := testFilterReader(&config.DataSource{TableSchema: "db1", Tables: []*config.Table{{TableName: "t1"}}})
+	b.logger = log.New(os.Stderr, log.InfoLevel).WithField("test", t.Name())
+	b.sqlFilter = &SqlFilter{}
+	b.oscAlters = make(map[string][]string)
+	b.tables = map[string]map[string]*config.TableContext{"db1": {"t1": {}}}
+	return b
+}
+
+func TestBinlogReader_onlineSchemaChangeTable(t *testing.T) {
+	b := testOSCReader(t)
+	for _, c := range []struct {
+		table string
+		want  oscTable
+		ok    bool
+	}{
+		{"_t1_gho", oscTable{table: "t1", ghost: true}, true},
+		{"__t1_new", oscTable{table: "t1", ghost: true}, true},
+		{"_t1_ghc", oscTable{table: "t1"}, true},
+		{"_t1_20180102150405_del", oscTable{table: "t1"}, true},
+		{"_t1_old", oscTable{table: "t1"}, true},
+		{"_t2_gho", oscTable{}, false},
+		{"t1", oscTable{}, false},
+	} {
+		got, ok := b.onlineSchemaChangeTable("db1", c.table)
+		if ok != c.ok || got != c.want {
+			t.Errorf("%v: got %+v %v, want %+v %v", c.table, got, ok, c.want, c.ok)
+		}
+	}
+
+	b.mysqlContext.SkipOnlineSchemaChangeDetection = true
+	if _, ok := b.onlineSchemaChangeTable("db1", "_t1_gho"); ok {
+		t.Errorf("detected with SkipOnlineSchemaChangeDetection")
+	}
+}
+
+func TestBinlogReader_onlineSchemaChangeTable_exists(t *testing.T) {
+	b := testOSCReader(t)
+	b.mysqlContext.ReplicateDoDb = append(b.mysqlContext.ReplicateDoDb, &config.DataSource{TableSchema: "db2"})
+	b.genRegexMap()
+	b.tables["db2"] = map[string]*config.TableContext{"t5": {}, "_t5": {}}
+	for _, c := range []struct {
+		table string
+		want  oscTable
+		ok    bool
+	}{
+		{"_t5_gho", oscTable{table: "t5", ghost: true}, true},
+		{"__t5_gho", oscTable{table: "_t5", ghost: true}, true},
+		{"___t5_new", oscTable{table: "_t5", ghost: true}, true},
+		{"_t5_del", oscTable{table: "t5"}, true},
+		// Named like OSC tables, of no table.
+		{"_t4_new", oscTable{}, false},
+		{"_t4_old", oscTable{}, false},
+	} {
+		got, ok := b.onlineSchemaChangeTable("db2", c.table)
+		if ok != c.ok || got != c.want {
+			t.Errorf("%v: got %+v %v, want %+v %v", c.table, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestBinlogReader_handleOnlineSchemaChange(t *testing.T) {
+	b := testOSCReader(t)
+	for _, query := range []string{
+		"create /* gh-ost */ table `db1`.`_t1_gho` like `db1`.`t1`",
+		"alter /* gh-ost */ table `db1`.`_t1_gho` add column c2 int",
+		"CREATE TRIGGER `pt_osc_db1_t1_ins` AFTER INSERT ON `db1`.`t1` FOR EACH ROW REPLACE INTO `db1`.`_t1_new` (`id`) VALUES (NEW.`id`)",
+		"rename /* gh-ost */ table `db1`.`t1` to `db1`.`_t1_del`",
+	} {
+		events, handled, err := b.handleOnlineSchemaChange(query, "db1")
+		if err != nil || !handled || len(events) != 0 {
+			t.Errorf("%v: got %v %v %v", query, events, handled, err)
+		}
+	}
+	want := []string{"alter /* gh-ost */ table `db1`.`t1` add column c2 int"}
+	if got := b.oscAlters["db1.t1"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("alters %v, want %v", got, want)
+	}
+
+	for _, query := range []string{
+		"alter table t1 add column c3 int",
+		"rename table t1 to t3",
+		"create table _t2_gho (id int)",
+	} {
+		if _, handled, _ := b.handleOnlineSchemaChange(query, "db1"); handled {
+			t.Errorf("%v: handled", query)
+		}
+	}
+
+	if _, handled, _ := b.handleOnlineSchemaChange("drop table if exists `_t1_gho`", "db1"); !handled {
+		t.Errorf("drop not handled")
+	}
+	if len(b.oscAlters) != 0 {
+		t.Errorf("alters %v after the ghost table dropped", b.oscAlters)
+	}
+}
+
+func TestBinlogReader_handleOnlineSchemaChange_record(t *testing.T) {
+	b := testOSCReader(t)
+	b.RecordOnlineSchemaChanges = true
+	t1 := SchemaTable{Schema: "db1", Table: "t1"}
+	for _, c := range []struct {
+		query string
+		want  []DataEvent
+	}{
+		{"drop /* gh-ost */ table if exists `db1`.`_t1_gho`",
+			[]DataEvent{newOnlineSchemaChangeEvent(OnlineSchemaChangeCancel, "", t1)}},
+		{"create /* gh-ost */ table `db1`.`_t1_gho` like `db1`.`t1`", nil},
+		{"alter /* gh-ost */ table `db1`.`_t1_gho` add column c2 int",
+			[]DataEvent{newOnlineSchemaChangeEvent(OnlineSchemaChangeAlter,
+				"alter /* gh-ost */ table `db1`.`t1` add column c2 int", t1)}},
+		{"rename /* gh-ost */ table `db1`.`t1` to `db1`.`_t1_del`", nil},
+	} {
+		events, handled, err := b.handleOnlineSchemaChange(c.query, "db1")
+		if err != nil || !handled || !reflect.DeepEqual(events, c.want) {
+			t.Errorf("%v: got %+v %v %v, want %+v", c.query, events, handled, err, c.want)
+		}
+	}
+	if len(b.oscAlters) != 0 {
+		t.Errorf("alters %v kept in memory", b.oscAlters)
+	}
+
+	b.sqlFilter.NoDDL = true
+	if events, handled, _ := b.handleOnlineSchemaChange("alter table `_t1_gho` add column c3 int", "db1"); !handled || len(events) != 0 {
+		t.Errorf("got %+v %v with NoDDL", events, handled)
+	}
+}
+
+func Test_renameTableInQuery(t *testing.T) {
+	got := renameTableInQuery("ALTER TABLE `db1`.`_t1_gho` ADD COLUMN c2 int", "_t1_gho", "t1")
+	if want := "ALTER TABLE `db1`.`t1` ADD COLUMN c2 int"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got = renameTableInQuery("alter table _t1_new add column _t1_new_c int", "_t1_new", "t1")
+	if want := "alter table `t1` add column _t1_new_c int"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
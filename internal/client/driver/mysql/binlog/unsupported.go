@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/parser"
+	"github.com/siddontang/go-mysql/replication"
+
+	"github.com/actiontech/dtle/utils"
+)
+
+// The categories of the statements of the source which are not replicated
+// faithfully, the keys of UnsupportedStatements.
+const (
+	// CREATE/DROP TEMPORARY TABLE, logged with a statement based binlog_format
+	// of the session.
+	UnsupportedTemporaryTable = "temporary-table"
+	// INSERT/REPLACE/UPDATE/DELETE logged as statements, instead of rows.
+	UnsupportedStatementDML = "statement-dml"
+	// LOAD DATA logged as a statement, whose file is not on the target.
+	UnsupportedLoadData = "load-data"
+	// The statements of users and privileges, without ExpandSyntaxSupport.
+	UnsupportedAccount = "account"
+	// CREATE FUNCTION/PROCEDURE, without ExpandSyntaxSupport.
+	UnsupportedRoutine = "routine"
+)
+
+// The policies of UnsupportedStatements.
+const (
+	// The statement is not replicated.
+	UnsupportedPolicySkip = "skip"
+	// The statement is not replicated, with a warning in the log.
+	UnsupportedPolicyLog = "log"
+	// The task fails.
+	UnsupportedPolicyError = "error"
+)
+
+// The policies of the categories not in UnsupportedStatements. Losing rows of
+// the tables replicated fails the task.
+var defaultUnsupportedPolicies = map[string]string{
+	UnsupportedTemporaryTable: UnsupportedPolicySkip,
+	UnsupportedStatementDML:   UnsupportedPolicyError,
+	UnsupportedLoadData:       UnsupportedPolicyError,
+	UnsupportedAccount:        UnsupportedPolicyLog,
+	UnsupportedRoutine:        UnsupportedPolicyLog,
+}
+
+// parseUnsupportedPolicies returns the policy of each category, of
+// UnsupportedStatements or the default one.
+func parseUnsupportedPolicies(m map[string]string) (map[string]string, error) {
+	policies := make(map[string]string, len(defaultUnsupportedPolicies))
+	for category, policy := range defaultUnsupportedPolicies {
+		policies[category] = policy
+	}
+	for category, policy := range m {
+		if _, ok := defaultUnsupportedPolicies[category]; !ok {
+			return nil, fmt.Errorf("unknown category of UnsupportedStatements: %v", category)
+		}
+		switch policy {
+		case UnsupportedPolicySkip, UnsupportedPolicyLog, UnsupportedPolicyError:
+			policies[category] = policy
+		default:
+			return nil, fmt.Errorf("bad policy %v of UnsupportedStatements %v, should be %v, %v or %v",
+				policy, category, UnsupportedPolicySkip, UnsupportedPolicyLog, UnsupportedPolicyError)
+		}
+	}
+	return policies, nil
+}
+
+// unsupportedStatement returns the category of the statement of a query event,
+// "" if it is replicated.
+func unsupportedStatement(query string, expandSyntaxSupport bool) string {
+	sql := strings.ToLower(strings.TrimSpace(query))
+	switch {
+	case strings.HasPrefix(sql, "create function"), strings.HasPrefix(sql, "create procedure"):
+		if expandSyntaxSupport {
+			return ""
+		}
+		return UnsupportedRoutine
+	case skipQueryEvent(sql):
+		if expandSyntaxSupport {
+			return ""
+		}
+		return UnsupportedAccount
+	case strings.HasPrefix(sql, "create temporary table"), strings.HasPrefix(sql, "drop temporary table"):
+		return UnsupportedTemporaryTable
+	case strings.HasPrefix(sql, "load data"):
+		return UnsupportedLoadData
+	case strings.HasPrefix(sql, "insert"), strings.HasPrefix(sql, "replace"),
+		strings.HasPrefix(sql, "update"), strings.HasPrefix(sql, "delete"):
+		return UnsupportedStatementDML
+	}
+	return ""
+}
+
+// tableNameCollector collects the tables of a statement.
+type tableNameCollector struct {
+	tables []SchemaTable
+}
+
+func (v *tableNameCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if t, ok := n.(*ast.TableName); ok {
+		v.tables = append(v.tables, SchemaTable{Schema: t.Schema.O, Table: t.Name.O})
+	}
+	return n, false
+}
+
+func (v *tableNameCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// statementTables returns the tables of the statement, nil if it could not be
+// parsed.
+func statementTables(query string) []SchemaTable {
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return nil
+	}
+	v := &tableNameCollector{}
+	stmt.Accept(v)
+	return v.tables
+}
+
+// unsupportedAffectsTables returns whether the statement of the category changes
+// a table replicated. The statements of accounts and routines affect the whole
+// instance.
+func (b *BinlogReader) unsupportedAffectsTables(category, query, currentSchema string) bool {
+	switch category {
+	case UnsupportedAccount, UnsupportedRoutine:
+		return true
+	}
+	tables := statementTables(query)
+	if len(tables) == 0 {
+		// By the default database only.
+		return !b.skipQueryDDL(query, currentSchema, "")
+	}
+	for _, t := range tables {
+		if !b.skipEvent(utils.StringElse(t.Schema, currentSchema), t.Table) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleUnsupported handles the statement of the category by its policy, and
+// counts it. It returns an error for UnsupportedPolicyError.
+func (b *BinlogReader) handleUnsupported(category, query, currentSchema string) error {
+	if !b.unsupportedAffectsTables(category, query, currentSchema) {
+		b.logger.Debugf("mysql.reader: skipped %v of tables not replicated: %v", category, query)
+		return nil
+	}
+
+	b.unsupportedMutex.Lock()
+	b.unsupportedCounts[category]++
+	b.unsupportedMutex.Unlock()
+
+	switch b.unsupportedPolicies[category] {
+	case UnsupportedPolicyError:
+		return fmt.Errorf("%v statement could not be replicated (see UnsupportedStatements) at %v:%v: %v",
+			category, b.currentCoordinates.SID, b.currentCoordinates.GNO, query)
+	case UnsupportedPolicyLog:
+		b.logger.Warnf("mysql.reader: skipped %v statement: %v", category, query)
+	default:
+		b.logger.Debugf("mysql.reader: skipped %v statement: %v", category, query)
+	}
+	return nil
+}
+
+// UnsupportedStatements returns how many statements of each category were not
+// replicated, or failed the task.
+func (b *BinlogReader) UnsupportedStatements() map[string]int64 {
+	b.unsupportedMutex.Lock()
+	defer b.unsupportedMutex.Unlock()
+	if len(b.unsupportedCounts) == 0 {
+		return nil
+	}
+	counts := make(map[string]int64, len(b.unsupportedCounts))
+	for category, n := range b.unsupportedCounts {
+		counts[category] = n
+	}
+	return counts
+}
+
+// executeLoadQuerySchema returns the default database of an
+// EXECUTE_LOAD_QUERY_EVENT, whose query is not decoded.
+func executeLoadQuerySchema(ev *replication.BinlogEvent) string {
+	evt := ev.Event.(*replication.ExecuteLoadQueryEvent)
+	// The fixed part of the event: the one of a query event, then the file id,
+	// the start and end positions and the duplicate handling.
+	pos := replication.EventHeaderSize + 13 + 13 + int(evt.StatusVars)
+	if pos+int(evt.SchemaLength) > len(ev.RawData) {
+		return ""
+	}
+	return string(ev.RawData[pos : pos+int(evt.SchemaLength)])
+}
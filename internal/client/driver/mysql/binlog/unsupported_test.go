@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func Test_unsupportedStatement(t *testing.T) {
+	for query, want := range map[string]string{
+		"CREATE TEMPORARY TABLE t1 (id int)":       UnsupportedTemporaryTable,
+		"drop temporary table if exists t1":        UnsupportedTemporaryTable,
+		"insert into t1 values (1)":                UnsupportedStatementDML,
+		" UPDATE t1 SET a = 1":                     UnsupportedStatementDML,
+		"LOAD DATA INFILE 'x' INTO TABLE t1":       UnsupportedLoadData,
+		"GRANT ALL ON *.* TO 'u'@'%'":              UnsupportedAccount,
+		"create procedure p() begin select 1; end": UnsupportedRoutine,
+		"create table t1 (id int)":                 "",
+		"COMMIT":                                   "",
+	} {
+		if got := unsupportedStatement(query, false); got != want {
+			t.Errorf("%q: got %q, want %q", query, got, want)
+		}
+	}
+	if got := unsupportedStatement("GRANT ALL ON *.* TO 'u'@'%'", true); got != "" {
+		t.Errorf("got %q with ExpandSyntaxSupport", got)
+	}
+}
+
+func Test_parseUnsupportedPolicies(t *testing.T) {
+	policies, err := parseUnsupportedPolicies(map[string]string{UnsupportedLoadData: UnsupportedPolicyLog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies[UnsupportedLoadData] != UnsupportedPolicyLog || policies[UnsupportedStatementDML] != UnsupportedPolicyError {
+		t.Errorf("got %v", policies)
+	}
+	for _, m := range []map[string]string{
+		{"foo": UnsupportedPolicySkip},
+		{UnsupportedLoadData: "apply"},
+	} {
+		if _, err := parseUnsupportedPolicies(m); err == nil {
+			t.Errorf("%v: expected an error", m)
+		}
+	}
+}
+
+func TestBinlogReader_handleUnsupported(t *testing.T) {
+	b := testFilterReader(&config.DataSource{TableSchema: "db1"})
+	b.logger = log.New(os.Stderr, log.InfoLevel).WithField("test", t.Name())
+	b.unsupportedPolicies, _ = parseUnsupportedPolicies(nil)
+	b.unsupportedCounts = make(map[string]int64)
+
+	if err := b.handleUnsupported(UnsupportedStatementDML, "insert into db2.t1 values (1)", "db1"); err != nil {
+		t.Errorf("a table not replicated: %v", err)
+	}
+	if err := b.handleUnsupported(UnsupportedStatementDML, "insert into t1 values (1)", "db1"); err == nil {
+		t.Errorf("expected an error")
+	}
+	if err := b.handleUnsupported(UnsupportedAccount, "grant all on *.* to 'u'@'%'", ""); err != nil {
+		t.Error(err)
+	}
+	if err := b.handleUnsupported(UnsupportedLoadData, "LOAD DATA", "db2"); err != nil {
+		t.Errorf("a schema not replicated: %v", err)
+	}
+	want := map[string]int64{UnsupportedStatementDML: 1, UnsupportedAccount: 1}
+	got := b.UnsupportedStatements()
+	if len(got) != len(want) || got[UnsupportedStatementDML] != 1 || got[UnsupportedAccount] != 1 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+)
+
+// checkpointRequestAttempts is how many times the Src task asks the Dest task
+// for the checkpoint, each waiting DefaultConnectWait, before resuming from the
+// Gtid of the job config.
+const checkpointRequestAttempts = 3
+
+// The checkpoint of a job is the gtid set executed on the target, in the
+// gtid_executed table of the dtle schema. It is saved in the same transaction
+// as the changes applied, and after the full copy, so it is not lost on a crash,
+// unlike the Gtid of the job config which is reported to the managers
+// asynchronously. Both tasks resume from it.
+
+// checkpointReply is the answer of the Dest task asking for the checkpoint.
+type checkpointReply struct {
+	// The checkpoint, empty if none.
+	Gtid string
+	// The tables recorded as copied as of the checkpoint, see copied_tables.go.
+	CopiedTables []binlog.SchemaTable
+}
+
+// readCheckpoint sets the Gtid the job resumes from to the checkpoint on the
+// target. The Gtid of the job config is only used for a job without a
+// checkpoint yet, e.g. started from a given gtid, and saved as its first one.
+func (a *Applier) readCheckpoint() error {
+	gtidSet, err := base.SelectAllGtidExecuted(a.db, a.subjectUUID)
+	if err != nil {
+		return err
+	}
+	checkpoint := gtidSet.String()
+	if checkpoint == "" {
+		if a.mysqlContext.Gtid != "" {
+			return a.saveCheckpoint(a.mysqlContext.Gtid)
+		}
+		return nil
+	}
+	if checkpoint != a.mysqlContext.Gtid {
+		a.logger.Printf("mysql.applier: Resuming from the checkpoint on the target %v, instead of %v",
+			checkpoint, a.mysqlContext.Gtid)
+	}
+	a.mysqlContext.Gtid = checkpoint
+	return nil
+}
+
+// saveCheckpoint replaces the checkpoint on the target with the gtid set.
+func (a *Applier) saveCheckpoint(gtidSet string) (err error) {
+	tx, err := a.db.BeginTx(context.Background(), &gosql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	return base.ReplaceGtidExecuted(tx, a.subjectUUID, gtidSet)
+}
+
+// serveCheckpoint answers the Src task asking for the checkpoint on the target,
+// empty if there is none, e.g. during the full copy, and the tables copied.
+func (a *Applier) serveCheckpoint() error {
+	subject := fmt.Sprintf("%s_checkpoint", a.subject)
+	_, err := a.subscribe(subject, func(m *gonats.Msg) {
+		gtidSet, err := base.SelectAllGtidExecuted(a.db, a.subjectUUID)
+		if err != nil {
+			a.logger.Errorf("mysql.applier: failed to read the checkpoint: %v", err)
+			return
+		}
+		reply := &checkpointReply{Gtid: gtidSet.String()}
+		if reply.CopiedTables, err = a.selectCopiedTables(); err != nil {
+			a.logger.Errorf("mysql.applier: failed to read the tables copied: %v", err)
+			return
+		}
+		data, err := Encode(reply)
+		if err != nil {
+			a.logger.Errorf("mysql.applier: failed to reply the checkpoint: %v", err)
+			return
+		}
+		msg, err := a.cipher.Seal(subject+"_reply", data)
+		if err == nil {
+			err = a.natsConn.Publish(m.Reply, msg)
+		}
+		if err != nil {
+			a.logger.Errorf("mysql.applier: failed to reply the checkpoint: %v", err)
+		}
+	})
+	return err
+}
+
+// requestCheckpoint asks the Dest task for the checkpoint on the target, and
+// resumes from it if there is one. It returns the answer, nil if the Dest task
+// did not answer, e.g. of another driver, which leaves the Gtid of the job
+// config.
+func (e *Extractor) requestCheckpoint() *checkpointReply {
+	subject := fmt.Sprintf("%s_checkpoint", e.subject)
+	for i := 0; i < checkpointRequestAttempts; i++ {
+		var data []byte
+		req, err := e.cipher.Seal(subject, nil)
+		if err != nil {
+			e.logger.Errorf("mysql.extractor: failed to request the checkpoint: %v", err)
+			return nil
+		}
+		msg, err := e.natsConn.Request(subject, req, DefaultConnectWait)
+		if err == nil {
+			data, err = e.cipher.Open(subject+"_reply", msg.Data)
+		}
+		reply := &checkpointReply{}
+		if err == nil {
+			err = Decode(data, reply)
+		}
+		if err == nil {
+			if checkpoint := reply.Gtid; checkpoint != "" {
+				if checkpoint != e.mysqlContext.Gtid {
+					e.logger.Printf("mysql.extractor: Resuming from the checkpoint on the target %v, instead of %v",
+						checkpoint, e.mysqlContext.Gtid)
+				}
+				e.mysqlContext.Gtid = checkpoint
+			}
+			return reply
+		}
+		e.logger.Debugf("mysql.extractor: no checkpoint from the Dest task: %v", err)
+		if err != gonats.ErrTimeout {
+			select {
+			case <-time.After(time.Second):
+			case <-e.shutdownCh:
+				return nil
+			}
+		}
+	}
+	e.logger.Warnf("mysql.extractor: got no checkpoint from the Dest task, resuming from %q", e.mysqlContext.Gtid)
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"time"
+)
+
+const (
+	minAdaptiveChunkSize = 10
+	maxAdaptiveChunkSize = 1000000
+	// The chunk size changes by at most this factor at a time, so that a chunk of
+	// unusual rows does not throw it off.
+	maxChunkSizeFactor = 2
+)
+
+// chunkSizer adapts the rows of a chunk of the dumper, from the size and the fetch
+// time of the previous chunk, to get chunks of about targetBytes or targetDuration.
+type chunkSizer struct {
+	targetBytes    int64
+	targetDuration time.Duration
+}
+
+func newChunkSizer(targetBytes int64, targetDuration time.Duration) *chunkSizer {
+	return &chunkSizer{
+		targetBytes:    targetBytes,
+		targetDuration: targetDuration,
+	}
+}
+
+// Next returns the size of the next chunk, after a chunk of size rows has got nRows
+// of nBytes in elapsed.
+func (s *chunkSizer) Next(size int64, nRows int64, nBytes int64, elapsed time.Duration) int64 {
+	if nRows == 0 {
+		return size
+	}
+
+	next := int64(maxAdaptiveChunkSize)
+	if nBytes > 0 {
+		rowBytes := float64(nBytes) / float64(nRows)
+		if bySize := int64(float64(s.targetBytes) / rowBytes); bySize < next {
+			next = bySize
+		}
+	}
+	if elapsed > 0 {
+		rowDuration := float64(elapsed) / float64(nRows)
+		if byTime := int64(float64(s.targetDuration) / rowDuration); byTime < next {
+			next = byTime
+		}
+	}
+
+	if next > size*maxChunkSizeFactor {
+		next = size * maxChunkSizeFactor
+	} else if next < size/maxChunkSizeFactor {
+		next = size / maxChunkSizeFactor
+	}
+	if next < minAdaptiveChunkSize {
+		next = minAdaptiveChunkSize
+	} else if next > maxAdaptiveChunkSize {
+		next = maxAdaptiveChunkSize
+	}
+	return next
+}
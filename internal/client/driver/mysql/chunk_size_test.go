@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkSizer_Next(t *testing.T) {
+	s := newChunkSizer(1024*1024, time.Second)
+	tests := []struct {
+		name    string
+		size    int64
+		nRows   int64
+		nBytes  int64
+		elapsed time.Duration
+		want    int64
+	}{
+		// 100 bytes per row: 10485 rows per chunk. By at most 2x at a time.
+		{"grow", 2000, 2000, 200000, 10 * time.Millisecond, 4000},
+		{"by bytes", 8000, 8000, 800000, 80 * time.Millisecond, 10485},
+		// 1ms per row: 1000 rows per second.
+		{"by time", 2000, 2000, 2000, 2 * time.Second, 1000},
+		{"shrink", 2000, 2000, 20 * 1024 * 1024, 100 * time.Millisecond, 1000},
+		{"min", 10, 10, 10 * 1024 * 1024, time.Second, minAdaptiveChunkSize},
+		{"empty", 2000, 0, 0, time.Second, 2000},
+	}
+	for _, tt := range tests {
+		if got := s.Next(tt.size, tt.nRows, tt.nBytes, tt.elapsed); got != tt.want {
+			t.Errorf("%v: Next() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
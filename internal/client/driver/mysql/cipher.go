@@ -0,0 +1,228 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+const (
+	// trafficCipherVersion is the first byte of an encrypted message, for the
+	// format to be changed later.
+	trafficCipherVersion = 3
+	// trafficNonceSize is the size of the random nonce following the version.
+	trafficNonceSize = 12
+	// trafficSeqSize is the size of the session and the sequence number of the
+	// sender, and of the time the message is sealed, encrypted before the data.
+	trafficSeqSize = 8 + 8 + 8
+	// trafficOverhead is the bytes an encrypted message has more than the data:
+	// the version, the nonce, the sequence and the GCM tag.
+	trafficOverhead = 1 + trafficNonceSize + trafficSeqSize + 16
+	// trafficReplayWindow is how far behind the newest sequence number a message
+	// may arrive on a subject, e.g. sent by concurrent goroutines.
+	trafficReplayWindow = 64
+	// trafficMaxAge is how long after it is sealed, by the clock of the
+	// receiver, a message is accepted. The clocks of the agents must agree
+	// within it.
+	trafficMaxAge = 10 * time.Minute
+)
+
+var (
+	// ErrReplayedMessage is returned by Open for a message already received, or
+	// of a session older than the last one received on the subject.
+	ErrReplayedMessage = errors.New("received a replayed message")
+	// ErrStaleMessage is returned by Open for a message sealed more than
+	// trafficMaxAge ago.
+	ErrStaleMessage = errors.New("received a stale message")
+)
+
+// TrafficCipher encrypts the messages of the Src task to the Dest task with
+// AES-GCM, so that the data is not readable or altered on the NATS link, even
+// without TLS. The subject of a message is authenticated with it, so that a
+// message is not accepted on another subject of the job.
+//
+// Each message carries the session of the sender, which is the time it started,
+// a sequence number, and the time it is sealed. A message is accepted once per
+// subject, only from the newest session of the job received on the subject, so
+// that a captured message can't be played again to the Dest task after the Src
+// task restarts either. A message sealed more than trafficMaxAge ago is
+// refused, so that it can't be played again after the Dest task restarts and
+// forgets the messages received.
+//
+// A nil *TrafficCipher leaves the messages as they are.
+type TrafficCipher struct {
+	aead    cipher.AEAD
+	session uint64
+	seq     uint64
+	now     func() time.Time
+
+	windowsLock sync.Mutex
+	windows     map[string]*replayWindow
+}
+
+// replayWindow is the sequence numbers received of the newest session on a
+// subject: the newest, and a bitmap of the trafficReplayWindow ones before it.
+type replayWindow struct {
+	session uint64
+	max     uint64
+	bitmap  uint64
+	// sealed is the time the newest message was sealed, in UnixNano.
+	sealed int64
+}
+
+// accept tells whether seq was not received yet, and marks it received.
+func (w *replayWindow) accept(seq uint64) bool {
+	switch {
+	case seq > w.max:
+		if shift := seq - w.max; shift < trafficReplayWindow {
+			w.bitmap = w.bitmap<<shift | 1
+		} else {
+			w.bitmap = 1
+		}
+		w.max = seq
+		return true
+	case w.max-seq >= trafficReplayWindow:
+		return false
+	default:
+		bit := uint64(1) << (w.max - seq)
+		if w.bitmap&bit != 0 {
+			return false
+		}
+		w.bitmap |= bit
+		return true
+	}
+}
+
+// NewTrafficCipher returns the cipher of the key of the job, nil if it has no key.
+func NewTrafficCipher(key []byte) (*TrafficCipher, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid traffic key: %v", err)
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, trafficNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	return &TrafficCipher{
+		aead:    aead,
+		session: uint64(time.Now().UnixNano()),
+		now:     time.Now,
+		windows: make(map[string]*replayWindow),
+	}, nil
+}
+
+// Seal encrypts the message to be published on the subject. A message sent
+// again, e.g. after a timeout, must be sealed again.
+func (c *TrafficCipher) Seal(subject string, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	plain := make([]byte, trafficSeqSize, trafficSeqSize+len(data))
+	binary.BigEndian.PutUint64(plain, c.session)
+	binary.BigEndian.PutUint64(plain[8:], atomic.AddUint64(&c.seq, 1))
+	binary.BigEndian.PutUint64(plain[16:], uint64(c.now().UnixNano()))
+	plain = append(plain, data...)
+
+	msg := make([]byte, 1+trafficNonceSize, trafficOverhead+len(data))
+	msg[0] = trafficCipherVersion
+	if _, err := io.ReadFull(rand.Reader, msg[1:]); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(msg, msg[1:], plain, []byte(subject)), nil
+}
+
+// Open decrypts a message received on the subject. It returns
+// ErrReplayedMessage for a message received before, and ErrStaleMessage for a
+// message sealed too long ago.
+func (c *TrafficCipher) Open(subject string, msg []byte) ([]byte, error) {
+	if c == nil {
+		return msg, nil
+	}
+	if len(msg) < trafficOverhead || msg[0] != trafficCipherVersion {
+		return nil, fmt.Errorf("received a message not encrypted with the traffic key on %v", subject)
+	}
+	plain, err := c.aead.Open(nil, msg[1:1+trafficNonceSize], msg[1+trafficNonceSize:], []byte(subject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt a message on %v, the traffic keys of the tasks may differ: %v", subject, err)
+	}
+
+	session := binary.BigEndian.Uint64(plain)
+	seq := binary.BigEndian.Uint64(plain[8:])
+	sealed := int64(binary.BigEndian.Uint64(plain[16:]))
+	oldest := c.now().Add(-trafficMaxAge).UnixNano()
+	if sealed < oldest {
+		return nil, ErrStaleMessage
+	}
+
+	c.windowsLock.Lock()
+	defer c.windowsLock.Unlock()
+	w, ok := c.windows[subject]
+	if !ok {
+		// The windows of the subjects idle for trafficMaxAge are evicted: their
+		// messages are refused as stale anyway.
+		for s, idle := range c.windows {
+			if idle.sealed < oldest {
+				delete(c.windows, s)
+			}
+		}
+		w = &replayWindow{}
+		c.windows[subject] = w
+	}
+	switch {
+	case session < w.session:
+		return nil, ErrReplayedMessage
+	case session > w.session:
+		*w = replayWindow{session: session}
+	}
+	if !w.accept(seq) {
+		return nil, ErrReplayedMessage
+	}
+	if sealed > w.sealed {
+		w.sealed = sealed
+	}
+	return plain[trafficSeqSize:], nil
+}
+
+// Handler returns cb receiving the messages decrypted. A message failing to be
+// decrypted is passed to onError, and a replayed message is dropped.
+func (c *TrafficCipher) Handler(cb gonats.MsgHandler, logger *log.Entry, onError func(error)) gonats.MsgHandler {
+	if c == nil {
+		return cb
+	}
+	return func(m *gonats.Msg) {
+		data, err := c.Open(m.Subject, m.Data)
+		if err == ErrReplayedMessage {
+			logger.Warnf("dropped a replayed message on %v", m.Subject)
+			return
+		} else if err == ErrStaleMessage {
+			logger.Warnf("dropped a message sealed more than %v ago on %v, are the clocks of the agents in sync?",
+				trafficMaxAge, m.Subject)
+			return
+		} else if err != nil {
+			onError(err)
+			return
+		}
+		m.Data = data
+		cb(m)
+	}
+}
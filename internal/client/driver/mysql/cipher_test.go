@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTrafficCipher(t *testing.T) {
+	var none *TrafficCipher
+	if msg, err := none.Seal("job_full", []byte("data")); err != nil || string(msg) != "data" {
+		t.Fatalf("nil cipher changed the message: %q, %v", msg, err)
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+	c, err := NewTrafficCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("INSERT INTO t VALUES (1)")
+	msg, err := c.Seal("job_full", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) != len(data)+trafficOverhead || bytes.Contains(msg, data) {
+		t.Fatalf("unexpected encrypted message %q", msg)
+	}
+	if got, err := c.Open("job_full", msg); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("open() = %q, %v", got, err)
+	}
+
+	if _, err := c.Open("job_full", msg); err != ErrReplayedMessage {
+		t.Errorf("opened a replayed message: %v", err)
+	}
+	again, _ := c.Seal("job_full", data)
+	if got, err := c.Open("job_full", again); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("open() the message sealed again = %q, %v", got, err)
+	}
+
+	if _, err := c.Open("job_incr_hete", msg); err == nil {
+		t.Errorf("opened a message on another subject")
+	}
+	other, _ := NewTrafficCipher(bytes.Repeat([]byte{2}, 32))
+	if _, err := other.Open("job_full", msg); err == nil {
+		t.Errorf("opened a message with another key")
+	}
+	if _, err := c.Open("job_full", data); err == nil {
+		t.Errorf("opened a message not encrypted")
+	}
+	msg[len(msg)-1] ^= 1
+	if _, err := c.Open("job_full", msg); err == nil {
+		t.Errorf("opened an altered message")
+	}
+
+	if _, err := NewTrafficCipher([]byte("short")); err == nil {
+		t.Errorf("NewTrafficCipher() accepted a bad key size")
+	}
+}
+
+func TestTrafficCipherSessions(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+	src, _ := NewTrafficCipher(key)
+	dest, _ := NewTrafficCipher(key)
+	src.now, dest.now = clock, clock
+
+	old, _ := src.Seal("job_full", []byte("1"))
+	if _, err := dest.Open("job_full", old); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Src task restarts: the messages of the session before are refused.
+	restarted, _ := NewTrafficCipher(key)
+	restarted.now = clock
+	restarted.session = src.session + 1
+	older, _ := src.Seal("job_full", []byte("2"))
+	msg, _ := restarted.Seal("job_full", []byte("3"))
+	if _, err := dest.Open("job_full", msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dest.Open("job_full", older); err != ErrReplayedMessage {
+		t.Errorf("opened a message of an older session: %v", err)
+	}
+
+	// The Dest task restarts: the messages sealed too long ago are refused.
+	now = now.Add(trafficMaxAge + time.Second)
+	fresh, _ := NewTrafficCipher(key)
+	fresh.now = clock
+	if _, err := fresh.Open("job_full", msg); err != ErrStaleMessage {
+		t.Errorf("opened a stale message: %v", err)
+	}
+	if _, err := dest.Open("job_full", msg); err != ErrStaleMessage {
+		t.Errorf("opened a stale message: %v", err)
+	}
+
+	// The idle subjects are evicted when a new one is received.
+	other, _ := restarted.Seal("job_incr_hete", []byte("4"))
+	if _, err := dest.Open("job_incr_hete", other); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dest.windows["job_full"]; ok || len(dest.windows) != 1 {
+		t.Errorf("the idle subject was not evicted: %v", dest.windows)
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	var w replayWindow
+	for _, tt := range []struct {
+		seq  uint64
+		want bool
+	}{
+		{1, true},
+		{3, true},
+		{2, true},
+		{3, false},
+		{2, false},
+		{100, true},
+		{100 - trafficReplayWindow + 1, true},
+		{100 - trafficReplayWindow, false},
+		{99, true},
+		{99, false},
+		{1000, true},
+		{100, false},
+	} {
+		if got := w.accept(tt.seq); got != tt.want {
+			t.Errorf("accept(%v) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
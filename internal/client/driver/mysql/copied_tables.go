@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/g"
+)
+
+// The tables added to a running job are copied one after another while the
+// others replicate, see Update. The copies pending are kept in memory, so the
+// Src task records the tables copied in the copied_tables table of the dtle
+// schema on the target, by entries of the stream applied in order with the
+// transactions. The set recorded is thus the one as of the checkpoint. A task
+// resuming from the checkpoint copies again the tables of the job not in it,
+// e.g. added and not completely copied before a restart. A job without a set
+// recorded yet, e.g. before the first entry after its full copy, copies none.
+
+// createTableCopiedTables creates the copied_tables table.
+func (a *Applier) createTableCopiedTables() error {
+	query := fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %v.%v (
+				job_uuid binary(16) NOT NULL COMMENT 'unique identifier of job',
+				table_schema varchar(64) NOT NULL COMMENT 'schema of the table on the source.',
+				table_name varchar(64) NOT NULL COMMENT 'name of the table on the source.',
+				PRIMARY KEY (job_uuid, table_schema, table_name)
+			);
+		`, g.DtleSchemaName, g.CopiedTablesTable)
+	_, err := a.db.Exec(query)
+	return err
+}
+
+// selectCopiedTables returns the tables recorded as copied for the job.
+func (a *Applier) selectCopiedTables() ([]binlog.SchemaTable, error) {
+	query := fmt.Sprintf("select table_schema, table_name from %v.%v where job_uuid = unhex('%s')",
+		g.DtleSchemaName, g.CopiedTablesTable, hex.EncodeToString(a.subjectUUID.Bytes()))
+	var tables []binlog.SchemaTable
+	err := sql.QueryRowsMap(a.db, query, func(m sql.RowMap) error {
+		tables = append(tables, binlog.SchemaTable{Schema: m.GetString("table_schema"), Table: m.GetString("table_name")})
+		return nil
+	})
+	return tables, err
+}
+
+// saveCopiedTables replaces in the transaction the tables recorded as copied.
+func (a *Applier) saveCopiedTables(tx *gosql.Tx, copied *binlog.CopiedTables) error {
+	jobUUID := hex.EncodeToString(a.subjectUUID.Bytes())
+	if _, err := tx.Exec(fmt.Sprintf("delete from %v.%v where job_uuid = unhex('%s')",
+		g.DtleSchemaName, g.CopiedTablesTable, jobUUID)); err != nil {
+		return err
+	}
+	if len(copied.Tables) == 0 {
+		return nil
+	}
+	values := make([]string, len(copied.Tables))
+	args := make([]interface{}, 0, 2*len(copied.Tables))
+	for i, t := range copied.Tables {
+		values[i] = fmt.Sprintf("(unhex('%s'), ?, ?)", jobUUID)
+		args = append(args, t.Schema, t.Table)
+	}
+	_, err := tx.Exec(fmt.Sprintf("insert into %v.%v (job_uuid,table_schema,table_name) values %s",
+		g.DtleSchemaName, g.CopiedTablesTable, strings.Join(values, ",")), args...)
+	return err
+}
+
+// initCopiedTables starts recording the tables copied on the target, given the
+// set recorded as of the checkpoint, and queues the copies of the tables of the
+// job not in it. It is called once the binlog reader is initiated.
+func (e *Extractor) initCopiedTables(copied []binlog.SchemaTable) {
+	if err := e.checkResync(); err != nil {
+		return
+	}
+	e.resyncLock.Lock()
+	defer e.resyncLock.Unlock()
+	e.recordsCopiedTables = true
+	e.copiedTablesChanged = true
+	if len(copied) == 0 {
+		return
+	}
+	uncopied := uncopiedTables(e.replicateDoDb, copied)
+	if len(uncopied) > 0 {
+		e.logger.Printf("mysql.extractor: copying the tables %v, added to the job and not copied before it restarted",
+			tableNames(uncopied))
+		e.pendingResyncs = append(e.pendingResyncs, uncopied...)
+	}
+}
+
+// copiedTablesChange records that the tables copied changed, to be sent to the
+// Dest task. resyncLock is held.
+func (e *Extractor) copiedTablesChange() {
+	if e.recordsCopiedTables {
+		e.copiedTablesChanged = true
+	}
+}
+
+// recordCopiedTables sends, with send, the tables copied to be recorded on the
+// target, if they changed since they were last sent.
+func (e *Extractor) recordCopiedTables(send func(*binlog.BinlogEntry) error) error {
+	e.resyncLock.Lock()
+	if !e.copiedTablesChanged {
+		e.resyncLock.Unlock()
+		return nil
+	}
+	e.copiedTablesChanged = false
+	copied := &binlog.CopiedTables{}
+	for _, db := range e.replicateDoDb {
+		for _, t := range db.Tables {
+			if !e.copyPending(db.TableSchema, t.TableName) {
+				copied.Tables = append(copied.Tables, binlog.SchemaTable{Schema: db.TableSchema, Table: t.TableName})
+			}
+		}
+	}
+	e.resyncLock.Unlock()
+	return send(&binlog.BinlogEntry{Resync: true, CopiedTables: copied})
+}
+
+// copyPending returns whether the table is added to the job and not copied
+// yet. resyncLock is held.
+func (e *Extractor) copyPending(schema, table string) bool {
+	if r := e.resync; r != nil && r.added && r.table.TableSchema == schema && r.table.TableName == table {
+		return true
+	}
+	for _, t := range e.pendingResyncs {
+		if t.TableSchema == schema && t.TableName == table {
+			return true
+		}
+	}
+	return false
+}
+
+// uncopiedTables returns the tables of dbs not in copied.
+func uncopiedTables(dbs []*config.DataSource, copied []binlog.SchemaTable) []*config.Table {
+	set := make(map[binlog.SchemaTable]bool, len(copied))
+	for _, t := range copied {
+		set[t] = true
+	}
+	var tables []*config.Table
+	for _, db := range dbs {
+		for _, t := range db.Tables {
+			if !set[binlog.SchemaTable{Schema: db.TableSchema, Table: t.TableName}] {
+				tables = append(tables, t)
+			}
+		}
+	}
+	return tables
+}
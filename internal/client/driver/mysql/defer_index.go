@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// splitSecondaryIndexes strips the non-unique secondary indexes and the foreign keys
+// from the output of `show create table`, and returns them as `ADD ...` clauses of
+// ALTER TABLE, to be added after the rows are loaded.
+// The primary and unique keys are kept, as the rows are loaded with REPLACE.
+func splitSecondaryIndexes(createTable string) (string, []string) {
+	lines := strings.Split(createTable, "\n")
+	// The definitions are on their own lines, up to the line of `) ENGINE=...`,
+	// which might be followed by the partitions.
+	end := 1
+	for end < len(lines) && !strings.HasPrefix(lines[end], ")") {
+		end++
+	}
+	if end == len(lines) {
+		return createTable, nil
+	}
+
+	kept := []string{lines[0]}
+	var clauses []string
+	for _, line := range lines[1:end] {
+		def := strings.TrimSuffix(strings.TrimSpace(line), ",")
+		upper := strings.ToUpper(def)
+		deferred := strings.HasPrefix(upper, "KEY ") || strings.HasPrefix(upper, "FULLTEXT KEY ") ||
+			strings.HasPrefix(upper, "SPATIAL KEY ") ||
+			(strings.HasPrefix(upper, "CONSTRAINT ") && strings.Contains(upper, " FOREIGN KEY "))
+		if deferred {
+			clauses = append(clauses, "ADD "+def)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	if len(clauses) == 0 {
+		return createTable, nil
+	}
+	// The last definition has no comma.
+	kept[len(kept)-1] = strings.TrimSuffix(kept[len(kept)-1], ",")
+	kept = append(kept, lines[end:]...)
+	return strings.Join(kept, "\n"), clauses
+}
+
+// deferSecondaryIndexes strips the secondary indexes from the CREATE TABLE in tbSQL,
+// and returns the statements to add them to the table, one ALTER TABLE each, so
+// that an index existing on the target doesn't keep the others from being added.
+func deferSecondaryIndexes(tableSchema, tableName string, tbSQL []string) ([]string, []string) {
+	for i, query := range tbSQL {
+		if !strings.HasPrefix(query, "CREATE TABLE ") {
+			continue
+		}
+		createTable, clauses := splitSecondaryIndexes(query)
+		if len(clauses) == 0 {
+			return tbSQL, nil
+		}
+		tbSQL[i] = createTable
+		// A foreign key refers to the table of the same schema without the schema name.
+		deferred := []string{fmt.Sprintf("USE %s", sql.EscapeName(tableSchema))}
+		for _, clause := range clauses {
+			deferred = append(deferred, fmt.Sprintf("ALTER TABLE %s %s", sql.EscapeName(tableName), clause))
+		}
+		return tbSQL, deferred
+	}
+	return tbSQL, nil
+}
+
+// addDeferredSQL saves the statements deferred by the entry, to run after the full copy.
+func (a *Applier) addDeferredSQL(entry *DumpEntry) {
+	if len(entry.DeferredSQL) == 0 {
+		return
+	}
+	a.deferredSQLMutex.Lock()
+	a.deferredSQL = append(a.deferredSQL, entry.DeferredSQL)
+	a.deferredSQLMutex.Unlock()
+}
+
+// execDeferredSQL adds the secondary indexes and foreign keys after the full copy.
+func (a *Applier) execDeferredSQL() error {
+	a.deferredSQLMutex.Lock()
+	deferredSQL := a.deferredSQL
+	a.deferredSQL = nil
+	a.deferredSQLMutex.Unlock()
+	if len(deferredSQL) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET @@session.foreign_key_checks = 0"); err != nil {
+		return err
+	}
+	a.logger.Printf("mysql.applier: adding secondary indexes of %d tables", len(deferredSQL))
+	exec := func(query string) error {
+		_, err := conn.ExecContext(ctx, query)
+		return err
+	}
+	for _, queries := range deferredSQL {
+		if err := a.execDeferredQueries(exec, queries); err != nil {
+			return err
+		}
+	}
+	a.logger.Printf("mysql.applier: secondary indexes added")
+	return nil
+}
+
+// execDeferredQueries executes the deferred statements of a table with exec. An
+// index or a foreign key existing on the target, e.g. as the table existed with
+// it, is skipped.
+func (a *Applier) execDeferredQueries(exec func(query string) error, queries []string) error {
+	for _, query := range queries {
+		a.logger.Debugf("mysql.applier: Exec [%s]", query)
+		a.auditSql("", query, nil)
+		if err := exec(query); err != nil {
+			if mysqlErr, ok := err.(*gomysql.MySQLError); !sql.IgnoreError(err) &&
+				!(ok && mysqlErr.Number == sql.ErrFkDupName) {
+				return fmt.Errorf("exec [%s] error: %v", query, err)
+			}
+			a.logger.Warnf("mysql.applier: Ignore error: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestDeferSecondaryIndexes(t *testing.T) {
+	createTable := "CREATE TABLE `t1` (\n" +
+		"  `id` int(11) NOT NULL,\n" +
+		"  `pid` int(11) DEFAULT NULL,\n" +
+		"  `name` varchar(20) DEFAULT NULL,\n" +
+		"  `doc` text,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uk_name` (`name`),\n" +
+		"  KEY `idx_pid` (`pid`),\n" +
+		"  FULLTEXT KEY `ft_doc` (`doc`),\n" +
+		"  CONSTRAINT `fk_pid` FOREIGN KEY (`pid`) REFERENCES `p1` (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	tbSQL, deferredSQL := deferSecondaryIndexes("db1", "t1", []string{"USE db1", createTable})
+
+	wantCreateTable := "CREATE TABLE `t1` (\n" +
+		"  `id` int(11) NOT NULL,\n" +
+		"  `pid` int(11) DEFAULT NULL,\n" +
+		"  `name` varchar(20) DEFAULT NULL,\n" +
+		"  `doc` text,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uk_name` (`name`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	if !reflect.DeepEqual(tbSQL, []string{"USE db1", wantCreateTable}) {
+		t.Fatalf("unexpected tbSQL %q", tbSQL)
+	}
+	wantDeferredSQL := []string{
+		"USE `db1`",
+		"ALTER TABLE `t1` ADD KEY `idx_pid` (`pid`)",
+		"ALTER TABLE `t1` ADD FULLTEXT KEY `ft_doc` (`doc`)",
+		"ALTER TABLE `t1` ADD CONSTRAINT `fk_pid` FOREIGN KEY (`pid`) REFERENCES `p1` (`id`)",
+	}
+	if !reflect.DeepEqual(deferredSQL, wantDeferredSQL) {
+		t.Fatalf("unexpected deferredSQL %q", deferredSQL)
+	}
+
+	// Nothing to defer.
+	noIndex := "CREATE TABLE `t2` (\n  `id` int(11) NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB\n" +
+		"/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */"
+	tbSQL, deferredSQL = deferSecondaryIndexes("db1", "t2", []string{"USE db1", noIndex})
+	if tbSQL[1] != noIndex || deferredSQL != nil {
+		t.Fatalf("unexpected %q %q", tbSQL, deferredSQL)
+	}
+}
+
+func TestExecDeferredQueries_Exists(t *testing.T) {
+	a := &Applier{logger: log.NewEntry(log.New(os.Stderr, log.InfoLevel))}
+	queries := []string{
+		"USE `db1`",
+		"ALTER TABLE `t1` ADD KEY `idx_pid` (`pid`)",
+		"ALTER TABLE `t1` ADD KEY `idx_name` (`name`)",
+		"ALTER TABLE `t1` ADD CONSTRAINT `fk_pid` FOREIGN KEY (`pid`) REFERENCES `p1` (`id`)",
+	}
+	var executed []string
+	exec := func(query string) error {
+		switch query {
+		case queries[1]:
+			// The table existed on the target with the index.
+			return &gomysql.MySQLError{Number: sql.ErrDupKeyName, Message: "Duplicate key name 'idx_pid'"}
+		case queries[3]:
+			return &gomysql.MySQLError{Number: sql.ErrFkDupName, Message: "Duplicate foreign key constraint name 'fk_pid'"}
+		}
+		executed = append(executed, query)
+		return nil
+	}
+	if err := a.execDeferredQueries(exec, queries); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{queries[0], queries[2]}; !reflect.DeepEqual(executed, want) {
+		t.Errorf("executed %q, want %q", executed, want)
+	}
+
+	failing := func(query string) error {
+		return &gomysql.MySQLError{Number: 1072, Message: "Key column 'pid' doesn't exist in table"}
+	}
+	if err := a.execDeferredQueries(failing, queries[1:2]); err == nil {
+		t.Errorf("no error for a missing column")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+)
+
+const drainCheckInterval = 100 * time.Millisecond
+
+// subscribe is natsConn.Subscribe, with the subscription kept to be unsubscribed
+// when draining. The messages are decrypted before cb if the job has a traffic
+// key, and dropped at random with the nats_drop fault injected.
+func (a *Applier) subscribe(subj string, cb gonats.MsgHandler) (*gonats.Subscription, error) {
+	if a.faults != nil {
+		handler := cb
+		cb = func(m *gonats.Msg) {
+			if a.faults.dropMessage() {
+				a.logger.Warnf("mysql.applier: fault injected: dropped a message of %v", m.Subject)
+				return
+			}
+			handler(m)
+		}
+	}
+	cb = a.cipher.Handler(cb, a.logger, func(err error) {
+		a.onError(TaskStateDead, err)
+	})
+	sub, err := a.natsConn.Subscribe(subj, cb)
+	if err != nil {
+		return nil, err
+	}
+	a.subsLock.Lock()
+	a.subs = append(a.subs, sub)
+	a.subsLock.Unlock()
+	return sub, nil
+}
+
+// queueDrained returns whether all the received data has been applied.
+// The executed gtid is saved with each transaction, so there is nothing to be
+// lost after this.
+func (a *Applier) queueDrained() bool {
+	if len(a.copyRowsQueue) != 0 || atomic.LoadInt64(&a.nDumpEntry) != 0 ||
+		len(a.applyDataEntryQueue) != 0 || len(a.applyBinlogMtsTxQueue) != 0 ||
+		len(a.applyBinlogTxQueue) != 0 || len(a.applyBinlogGroupTxQueue) != 0 {
+		return false
+	}
+	return atomic.LoadInt64(&a.mtsManager.lastCommitted) == a.mtsManager.lastEnqueue
+}
+
+// Drain stops receiving data from the extractor, and waits for the received
+// transactions to be applied.
+func (a *Applier) Drain(timeout time.Duration) error {
+	a.logger.Printf("mysql.applier: draining")
+	a.subsLock.Lock()
+	for _, sub := range a.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			a.logger.Warnf("mysql.applier: unsubscribe %v error: %v", sub.Subject, err)
+		}
+	}
+	a.subs = nil
+	a.subsLock.Unlock()
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for !a.queueDrained() {
+		select {
+		case <-ticker.C:
+		case <-a.shutdownCh:
+			return nil
+		case <-deadline:
+			return fmt.Errorf("mysql.applier: drain timeout after %v. gtid: %v", timeout, a.mysqlContext.Gtid)
+		}
+	}
+
+	// Wait for the group of transactions being executed. Nothing will be added to wg
+	// as the queues are empty.
+	groupDone := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(groupDone)
+	}()
+	select {
+	case <-groupDone:
+	case <-a.shutdownCh:
+	case <-deadline:
+		return fmt.Errorf("mysql.applier: drain timeout after %v. gtid: %v", timeout, a.mysqlContext.Gtid)
+	}
+	a.logger.Printf("mysql.applier: drained. gtid: %v", a.mysqlContext.Gtid)
+	return nil
+}
+
+// Drain makes sure the published data is sent out to the applier.
+func (e *Extractor) Drain(timeout time.Duration) error {
+	if e.natsConn == nil {
+		return nil
+	}
+	e.logger.Printf("mysql.extractor: draining")
+	if err := e.natsConn.FlushTimeout(timeout); err != nil {
+		return fmt.Errorf("mysql.extractor: drain error: %v", err)
+	}
+	return nil
+}
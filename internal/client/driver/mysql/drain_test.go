@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestApplier_Drain(t *testing.T) {
+	cfg := &config.MySQLDriverConfig{
+		ConnectionConfig: &umconf.ConnectionConfig{},
+	}
+	a, err := NewApplier("8b4e4d7c-0b7e-4bd8-a0a4-2b5d4f1d6e0a", "", cfg, log.New(os.Stderr, log.InfoLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Drain(time.Second); err != nil {
+		t.Errorf("Drain() of an idle applier error = %v", err)
+	}
+
+	a.applyBinlogTxQueue <- &binlog.BinlogTx{}
+	if err := a.Drain(200 * time.Millisecond); err == nil {
+		t.Errorf("Drain() should time out with a queued transaction")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-a.applyBinlogTxQueue
+	}()
+	if err := a.Drain(time.Second); err != nil {
+		t.Errorf("Drain() error = %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/storage"
+)
+
+const dumpExportTimeLayout = "2006-01-02 15:04:05"
+
+// dumpExporter writes the full copy data in the mydumper directory layout,
+// so it could be archived or loaded with myloader:
+//
+//	metadata
+//	<db>-schema-create.sql
+//	<db>.<table>-schema.sql
+//	<db>.<table>.<nnnnn>.sql
+//
+// and the manifest of these files, see DumpManifest.
+//
+// It is not goroutine-safe.
+type dumpExporter struct {
+	dir       string
+	startTime time.Time
+	// "db.table" -> number of the next chunk file
+	chunks map[string]int
+	// file name -> the file in the manifest
+	files map[string]*DumpManifestFile
+	// The storage the dump is put in by Upload, nil if none.
+	store storage.Store
+}
+
+// defaultDumpExportDir is the DumpExportDir of a task putting the dump in the
+// storage without one.
+func defaultDumpExportDir(workDir string) string {
+	return filepath.Join(workDir, "dump-export")
+}
+
+// checkDumpExportMethod tells whether the full copy of the FullCopyMethod can
+// be exported. Only the rows of FullCopyMethodDump are: the table files of
+// FullCopyMethodOutfile are removed once loaded, and the backup of
+// FullCopyMethodXtrabackup has no rows.
+func checkDumpExportMethod(method string) error {
+	if method != config.FullCopyMethodDump {
+		return fmt.Errorf("DumpExportDir and DumpExportStorage require FullCopyMethod %v, not %v",
+			config.FullCopyMethodDump, method)
+	}
+	return nil
+}
+
+func newDumpExporter(dir string) (*dumpExporter, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &dumpExporter{
+		dir:       dir,
+		startTime: time.Now(),
+		chunks:    make(map[string]int),
+		files:     make(map[string]*DumpManifestFile),
+	}, nil
+}
+
+// writeFile writes the file, and adds it to the manifest with the rows it has.
+func (d *dumpExporter) writeFile(name string, content []byte, rows int64) error {
+	if err := ioutil.WriteFile(filepath.Join(d.dir, name), content, 0640); err != nil {
+		return err
+	}
+	d.files[name] = newDumpManifestFile(name, content, rows)
+	return nil
+}
+
+// WriteEntry writes the schema and rows of a DumpEntry to files.
+func (d *dumpExporter) WriteEntry(entry *DumpEntry) error {
+	if entry.TableSchema == "" {
+		return nil
+	}
+
+	if entry.DbSQL != "" {
+		err := d.writeFile(fmt.Sprintf("%s-schema-create.sql", entry.TableSchema),
+			[]byte(entry.DbSQL+";\n"), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if entry.TableName == "" {
+		return nil
+	}
+	tableKey := fmt.Sprintf("%s.%s", entry.TableSchema, entry.TableName)
+
+	var tbSQL []string
+	for _, query := range entry.TbSQL {
+		// The file is bound to the schema by name. Omit `USE db`.
+		if strings.HasPrefix(strings.ToUpper(query), "USE ") {
+			continue
+		}
+		tbSQL = append(tbSQL, query+";\n")
+	}
+	if len(tbSQL) > 0 {
+		err := d.writeFile(fmt.Sprintf("%s-schema.sql", tableKey), []byte(strings.Join(tbSQL, "")), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(entry.ValuesX) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("INSERT INTO %s VALUES\n", sql.EscapeName(entry.TableName)))
+	for i := range entry.ValuesX {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.WriteByte('(')
+		writeDumpRowValues(&buf, entry.ValuesX[i], entry.HexColumns)
+		buf.WriteByte(')')
+	}
+	buf.WriteString(";\n")
+
+	n := d.chunks[tableKey]
+	d.chunks[tableKey] = n + 1
+	return d.writeFile(fmt.Sprintf("%s.%05d.sql", tableKey, n), buf.Bytes(), int64(len(entry.ValuesX)))
+}
+
+// WriteMetadata writes the `metadata` file, with the gtid set of the full copy.
+func (d *dumpExporter) WriteMetadata(gtid string) error {
+	content := fmt.Sprintf("Started dump at: %s\nSHOW MASTER STATUS:\n\tLog: \n\tPos: \n\tGTID:%s\n\nFinished dump at: %s\n",
+		d.startTime.Format(dumpExportTimeLayout), gtid, time.Now().Format(dumpExportTimeLayout))
+	return d.writeFile("metadata", []byte(content), 0)
+}
+
+// OpenStorage opens the storage the dump is put in by Upload.
+func (d *dumpExporter) OpenStorage(cfg *config.StorageConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("DumpExportStorage is set, but the agent has no storage configured")
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	d.store = store
+	return nil
+}
+
+// Upload puts the files of the dump in the storage under the prefix, the
+// manifest last, so that a dump with a manifest in the storage is complete.
+func (d *dumpExporter) Upload(prefix string) error {
+	names := make([]string, 0, len(d.files)+1)
+	for name := range d.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, DumpManifestFileName)
+	for _, name := range names {
+		if err := storage.PutFile(d.store, prefix+name, filepath.Join(d.dir, name)); err != nil {
+			return fmt.Errorf("failed to put %v in %v: %v", name, d.store.URL(prefix+name), err)
+		}
+	}
+	return nil
+}
+
+// WriteManifest writes the manifest of the files written, and returns its
+// SHA-256, to keep apart from the dump to tell whether the manifest has been
+// changed.
+func (d *dumpExporter) WriteManifest(gtid string) (string, error) {
+	manifest := &DumpManifest{Gtid: gtid}
+	for _, f := range d.files {
+		manifest.Files = append(manifest.Files, f)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.dir, DumpManifestFileName), content, 0640); err != nil {
+		return "", err
+	}
+	return sha256Hex(content), nil
+}
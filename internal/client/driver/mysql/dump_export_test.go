@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func TestDumpExporter_WriteEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtle-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d, err := newDumpExporter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v1, v2 interface{} = []byte("1"), []byte("it's")
+	var null interface{}
+	entries := []*DumpEntry{
+		{TableSchema: "db1", DbSQL: "CREATE DATABASE IF NOT EXISTS db1"},
+		{TableSchema: "db1", TableName: "t1", DbSQL: "CREATE DATABASE IF NOT EXISTS db1",
+			TbSQL: []string{"USE db1", "CREATE TABLE `t1` (`id` int, `v` text)"}},
+		{TableSchema: "db1", TableName: "t1", ValuesX: [][]*interface{}{{&v1, &v2}, {&v1, &null}}},
+		{TableSchema: "db1", TableName: "t1", ValuesX: [][]*interface{}{{&v1, &null}}},
+	}
+	for _, entry := range entries {
+		if err := d.WriteEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.WriteMetadata("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"db1-schema-create.sql", "CREATE DATABASE IF NOT EXISTS db1;\n"},
+		{"db1.t1-schema.sql", "CREATE TABLE `t1` (`id` int, `v` text);\n"},
+		{"db1.t1.00000.sql", "INSERT INTO `t1` VALUES\n('1','it\\'s'),\n('1',NULL);\n"},
+		{"db1.t1.00001.sql", "INSERT INTO `t1` VALUES\n('1',NULL);\n"},
+	}
+	for _, tt := range tests {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Errorf("read %v: %v", tt.file, err)
+			continue
+		}
+		if string(bs) != tt.want {
+			t.Errorf("%v = %q, want %q", tt.file, string(bs), tt.want)
+		}
+	}
+
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "metadata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "\tGTID:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5\n") {
+		t.Errorf("metadata = %q", string(bs))
+	}
+
+	digest, err := d.WriteManifest("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := VerifyDumpManifest(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 5 || manifest.Files[2].Name != "db1.t1.00000.sql" || manifest.Files[2].Rows != 2 ||
+		manifest.Files[4].Name != "metadata" {
+		t.Errorf("manifest = %+v", manifest.Files)
+	}
+
+	if _, err := VerifyDumpManifest(dir, strings.Repeat("0", 64)); err == nil {
+		t.Errorf("verified with another digest")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "db1.t1.00001.sql"), []byte("INSERT INTO `t1` VALUES\n('2',NULL);\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "db1.t1-schema.sql")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "db1.t2.00000.sql"), nil, 0640); err != nil {
+		t.Fatal(err)
+	}
+	_, err = VerifyDumpManifest(dir, "")
+	for _, want := range []string{"db1.t1.00001.sql has been changed", "db1.t1-schema.sql is missing", "db1.t2.00000.sql is not in the manifest"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("VerifyDumpManifest() = %v, want %v", err, want)
+		}
+	}
+}
+
+func TestWriteDumpRowValues_Geometry(t *testing.T) {
+	// POINT(1 2) with SRID 4326, in the internal format: SRID + WKB.
+	point := []byte{
+		0xe6, 0x10, 0x00, 0x00,
+		0x01, 0x01, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+	}
+	var id, p, null interface{} = []byte("1"), point, nil
+	var buf bytes.Buffer
+	writeDumpRowValues(&buf, []*interface{}{&id, &p, &null}, []int{1, 2})
+	want := "'1',X'e61000000101000000000000000000f03f0000000000000040',NULL"
+	if buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+}
+
+func TestCheckDumpExportMethod(t *testing.T) {
+	if err := checkDumpExportMethod(config.FullCopyMethodDump); err != nil {
+		t.Errorf("dump: %v", err)
+	}
+	for _, method := range []string{config.FullCopyMethodOutfile, config.FullCopyMethodXtrabackup} {
+		if err := checkDumpExportMethod(method); err == nil {
+			t.Errorf("%v: exported", method)
+		}
+	}
+}
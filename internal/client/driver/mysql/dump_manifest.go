@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// DumpManifestFileName is the manifest of the files of an exported dump.
+const DumpManifestFileName = "manifest.json"
+
+// DumpManifest lists the files of an exported dump, to tell whether they have
+// been changed before they are loaded.
+type DumpManifest struct {
+	// The gtid set of the full copy.
+	Gtid  string
+	Files []*DumpManifestFile
+}
+
+type DumpManifestFile struct {
+	Name   string
+	Bytes  int64
+	SHA256 string
+	// The rows of a data file.
+	Rows int64 `json:",omitempty"`
+}
+
+func newDumpManifestFile(name string, content []byte, rows int64) *DumpManifestFile {
+	return &DumpManifestFile{
+		Name:   name,
+		Bytes:  int64(len(content)),
+		SHA256: sha256Hex(content),
+		Rows:   rows,
+	}
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyDumpManifest checks the files of the dump in dir against its manifest:
+// each file must have its size and SHA-256, and the dump must have no other
+// file. If digest is not empty, it is the SHA-256 of the manifest, as returned
+// when the manifest was written. The errors tell all the files changed.
+func VerifyDumpManifest(dir string, digest string) (*DumpManifest, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, DumpManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" && sha256Hex(content) != digest {
+		return nil, fmt.Errorf("the SHA-256 of %v is not %v, the manifest has been changed", DumpManifestFileName, digest)
+	}
+	manifest := &DumpManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("bad %v: %v", DumpManifestFileName, err)
+	}
+
+	var mErr multierror.Error
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		listed[f.Name] = true
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(f.Name)))
+		if os.IsNotExist(err) {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("%v is missing", f.Name))
+			continue
+		} else if err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+			continue
+		}
+		if int64(len(content)) != f.Bytes || sha256Hex(content) != f.SHA256 {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("%v has been changed", f.Name))
+		}
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() && info.Name() != DumpManifestFileName && !listed[info.Name()] {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("%v is not in the manifest", info.Name()))
+		}
+	}
+	return manifest, mErr.ErrorOrNil()
+}
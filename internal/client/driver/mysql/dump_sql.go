@@ -0,0 +1,194 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// dumpSQLBuilder builds the statements of the full copy of a table. It depends
+// only on the names and the columns of the table, not on a connection, so the
+// statements are deterministic and tested against golden files.
+type dumpSQLBuilder struct {
+	schema string
+	table  string
+	// The select list of the chunks, "*" unless a column is converted.
+	columns string
+	// Indexes of the columns dumped as hex literals.
+	hexColumns []int
+}
+
+func newDumpSQLBuilder(schema, table string, columns *umconf.ColumnList) *dumpSQLBuilder {
+	b := &dumpSQLBuilder{
+		schema:  schema,
+		table:   table,
+		columns: "*",
+	}
+
+	needPm := false
+	selected := make([]string, 0, columns.Len())
+	for i, col := range columns.Columns {
+		name := usql.EscapeName(col.Name)
+		switch col.Type {
+		case umconf.GeometryColumnType:
+			b.hexColumns = append(b.hexColumns, i)
+			selected = append(selected, name)
+		case umconf.FloatColumnType, umconf.DoubleColumnType,
+			umconf.MediumIntColumnType, umconf.BigIntColumnType,
+			umconf.DecimalColumnType:
+			// `+0` gets the numbers without the padding of ZEROFILL.
+			selected = append(selected, name+"+0")
+			needPm = true
+		default:
+			selected = append(selected, name)
+		}
+	}
+	if needPm {
+		b.columns = strings.Join(selected, ", ")
+	}
+	return b
+}
+
+func (b *dumpSQLBuilder) tableName() string {
+	return fmt.Sprintf("%s.%s", usql.EscapeName(b.schema), usql.EscapeName(b.table))
+}
+
+// chunkQueryOldWay returns the query of a chunk of the rows matching where,
+// paged by offset.
+func (b *dumpSQLBuilder) chunkQueryOldWay(where string, chunkSize, offset int64) string {
+	return fmt.Sprintf(`SELECT %s FROM %s where (%s) LIMIT %d OFFSET %d`,
+		b.columns, b.tableName(), where, chunkSize, offset)
+}
+
+// chunkQueryOnUniqueKey returns the query of a chunk of the rows matching
+// where, paged by the unique key. The first chunk starts from the beginning,
+// the others after uniqueKey.LastMaxVals.
+func (b *dumpSQLBuilder) chunkQueryOnUniqueKey(uniqueKey *umconf.UniqueKey, first bool,
+	where string, chunkSize int64) string {
+
+	nCol := len(uniqueKey.Columns.Columns)
+	uniqueKeyColumnAscending := make([]string, nCol)
+	for i, col := range uniqueKey.Columns.Columns {
+		colName := usql.EscapeName(col.Name)
+		switch col.Type {
+		case umconf.EnumColumnType:
+			// TODO try mysql enum type
+			uniqueKeyColumnAscending[i] = fmt.Sprintf("concat(%s) asc", colName)
+		default:
+			uniqueKeyColumnAscending[i] = fmt.Sprintf("%s asc", colName)
+		}
+	}
+
+	rangeStr := "true"
+	if !first {
+		rangeItems := make([]string, nCol)
+
+		// The form like: (A > a) or (A = a and B > b) or (A = a and B = b and C > c) or ...
+		for x := 0; x < nCol; x++ {
+			innerItems := make([]string, x+1)
+
+			for y := 0; y < x; y++ {
+				colName := usql.EscapeName(uniqueKey.Columns.Columns[y].Name)
+				innerItems[y] = fmt.Sprintf("(%s = %s)", colName, uniqueKey.LastMaxVals[y])
+			}
+
+			colName := usql.EscapeName(uniqueKey.Columns.Columns[x].Name)
+			innerItems[x] = fmt.Sprintf("(%s > %s)", colName, uniqueKey.LastMaxVals[x])
+
+			rangeItems[x] = fmt.Sprintf("(%s)", strings.Join(innerItems, " and "))
+		}
+
+		rangeStr = strings.Join(rangeItems, " or ")
+	}
+
+	return fmt.Sprintf(`SELECT %s FROM %s where (%s) and (%s) order by %s LIMIT %d`,
+		b.columns, b.tableName(),
+		// where
+		rangeStr, where,
+		// order by
+		strings.Join(uniqueKeyColumnAscending, ", "),
+		// limit
+		chunkSize,
+	)
+}
+
+// checksumQuery returns the CHECKSUM TABLE of the table.
+func (b *dumpSQLBuilder) checksumQuery() string {
+	return fmt.Sprintf("checksum table %s", b.tableName())
+}
+
+// dumpInsertVerb returns the statement loading the rows of the full copy with
+// the FullCopyConflict.
+func dumpInsertVerb(conflict string) string {
+	switch conflict {
+	case config.FullCopyConflictIgnore:
+		return "insert ignore into"
+	case config.FullCopyConflictInsert:
+		return "insert into"
+	default:
+		return "replace into"
+	}
+}
+
+// dumpInsertQueries returns the statements of verb, e.g. `replace into`, of the
+// rows of a dump entry, each of them at most sizeLimit bytes unless a single
+// row is larger, and the index of the first row of each. The names are quoted
+// with double quotes if ansiQuotes.
+func dumpInsertQueries(verb string, schema, table string, rows [][]*interface{}, hexColumns []int,
+	sizeLimit int, ansiQuotes bool) (queries []string, firstRows []int) {
+	var buf bytes.Buffer
+	for i := range rows {
+		if buf.Len() == 0 {
+			buf.WriteString(fmt.Sprintf(`%s %s.%s values (`, verb,
+				usql.QuoteName(schema, ansiQuotes), usql.QuoteName(table, ansiQuotes)))
+			firstRows = append(firstRows, i)
+		} else {
+			buf.WriteString(",(")
+		}
+		writeDumpRowValues(&buf, rows[i], hexColumns)
+		buf.WriteByte(')')
+
+		// last rows or sql too large
+		if i == len(rows)-1 || buf.Len() >= sizeLimit {
+			queries = append(queries, buf.String())
+			buf.Reset()
+		}
+	}
+	return queries, firstRows
+}
+
+// writeDumpRowValues writes the escaped, comma-separated values of a dumped row,
+// with the ones of hexColumns as hex literals.
+func writeDumpRowValues(buf *bytes.Buffer, row []*interface{}, hexColumns []int) {
+	for j, colData := range row {
+		if j > 0 {
+			buf.WriteByte(',')
+		}
+		isHex := len(hexColumns) > 0 && hexColumns[0] == j
+		if isHex {
+			hexColumns = hexColumns[1:]
+		}
+		if *colData == nil {
+			buf.WriteString("NULL")
+		} else if isHex {
+			buf.WriteString("X'")
+			buf.WriteString(hex.EncodeToString((*colData).([]byte)))
+			buf.WriteByte('\'')
+		} else {
+			buf.WriteByte('\'')
+			buf.WriteString(usql.EscapeValue(string((*colData).([]byte))))
+			buf.WriteByte('\'')
+		}
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// dumpSQLCase is a table dumped in the golden tests.
+type dumpSQLCase struct {
+	schema  string
+	table   string
+	columns []umconf.Column
+	// Indexes of the columns of the unique key.
+	uniqueKey []int
+	rows      [][]interface{}
+	sizeLimit int
+}
+
+func dumpSQLValue(v interface{}) *interface{} {
+	switch v := v.(type) {
+	case nil:
+		return new(interface{})
+	case string:
+		var x interface{} = []byte(v)
+		return &x
+	default:
+		var x interface{} = v
+		return &x
+	}
+}
+
+// render returns the statements of the case, in the format of the golden
+// files.
+func (c *dumpSQLCase) render() string {
+	columns := umconf.NewColumnList(c.columns)
+	b := newDumpSQLBuilder(c.schema, c.table, columns)
+
+	rows := make([][]*interface{}, len(c.rows))
+	for i, row := range c.rows {
+		rows[i] = make([]*interface{}, len(row))
+		for j, v := range row {
+			rows[i][j] = dumpSQLValue(v)
+		}
+	}
+
+	uniqueKey := &umconf.UniqueKey{Name: "PRIMARY"}
+	for _, i := range c.uniqueKey {
+		uniqueKey.Columns.Columns = append(uniqueKey.Columns.Columns, c.columns[i])
+		// The values after the first chunk, from its last row.
+		uniqueKey.LastMaxVals = append(uniqueKey.LastMaxVals,
+			usql.EscapeColRawToString(rows[len(rows)-1][i]))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- first chunk\n%s\n", b.chunkQueryOnUniqueKey(uniqueKey, true, "true", 100))
+	fmt.Fprintf(&buf, "-- next chunk\n%s\n", b.chunkQueryOnUniqueKey(uniqueKey, false, "true", 100))
+	fmt.Fprintf(&buf, "-- old way\n%s\n", b.chunkQueryOldWay("id > 10", 100, 200))
+	fmt.Fprintf(&buf, "-- checksum\n%s\n", b.checksumQuery())
+	fmt.Fprintf(&buf, "-- hex columns\n%v\n", b.hexColumns)
+	sizeLimit := c.sizeLimit
+	if sizeLimit == 0 {
+		sizeLimit = 1024 * 1024
+	}
+	queries, _ := dumpInsertQueries(dumpInsertVerb(""), c.schema, c.table, rows, b.hexColumns, sizeLimit, false)
+	for _, query := range queries {
+		fmt.Fprintf(&buf, "-- rows\n%s\n", query)
+	}
+	return buf.String()
+}
+
+func TestDumpSQLBuilder_Golden(t *testing.T) {
+	cases := map[string]*dumpSQLCase{
+		"reserved_words": {
+			schema: "order",
+			table:  "group",
+			columns: []umconf.Column{
+				{Name: "select", Type: umconf.IntColumnType},
+				{Name: "key", Type: umconf.VarcharColumnType},
+				{Name: "from", Type: umconf.EnumColumnType},
+			},
+			uniqueKey: []int{0, 2},
+			rows: [][]interface{}{
+				{"1", "where", "a"},
+				{"2", "limit", "b"},
+			},
+		},
+		"unicode_identifiers": {
+			schema: "数据库",
+			table:  "表_ü",
+			columns: []umconf.Column{
+				{Name: "编号", Type: umconf.IntColumnType},
+				{Name: "名字😀", Type: umconf.VarcharColumnType},
+			},
+			uniqueKey: []int{0},
+			rows: [][]interface{}{
+				{"1", "中文"},
+				{"2", "émoji 😀"},
+			},
+		},
+		"backticks": {
+			schema: "db`1",
+			table:  "t`",
+			columns: []umconf.Column{
+				{Name: "i`d", Type: umconf.IntColumnType},
+				{Name: "v``", Type: umconf.TextColumnType},
+			},
+			uniqueKey: []int{0},
+			rows: [][]interface{}{
+				{"1", "`"},
+			},
+		},
+		// Names that start and end with quotes are kept as they are.
+		"quoted_names": {
+			schema: "`a`",
+			table:  `"x"`,
+			columns: []umconf.Column{
+				{Name: `'y'`, Type: umconf.IntColumnType},
+				{Name: "`b`", Type: umconf.VarcharColumnType},
+				{Name: `"c"`, Type: umconf.VarcharColumnType},
+			},
+			uniqueKey: []int{0, 1},
+			rows: [][]interface{}{
+				{"1", "`", `"`},
+			},
+		},
+		"nulls_and_binary": {
+			schema: "db1",
+			table:  "t1",
+			columns: []umconf.Column{
+				{Name: "id", Type: umconf.IntColumnType},
+				{Name: "v", Type: umconf.VarcharColumnType},
+				{Name: "b", Type: umconf.BlobColumnType},
+				{Name: "g", Type: umconf.GeometryColumnType},
+			},
+			uniqueKey: []int{0},
+			rows: [][]interface{}{
+				{"1", nil, nil, nil},
+				{"2", "it's \"quoted\" \\ back", "\x00\n\r\x1a\xff", "\x00\x00\x00\x00\x01\x01"},
+				{"3", "", "", ""},
+				{"4", "NULL", "'", nil},
+			},
+			sizeLimit: 40,
+		},
+		"converted_columns": {
+			schema: "db1",
+			table:  "t2",
+			columns: []umconf.Column{
+				{Name: "big", Type: umconf.BigIntColumnType},
+				{Name: "medium", Type: umconf.MediumIntColumnType},
+				{Name: "f", Type: umconf.FloatColumnType},
+				{Name: "d", Type: umconf.DoubleColumnType},
+				{Name: "dec", Type: umconf.DecimalColumnType},
+				{Name: "dt", Type: umconf.DateTimeColumnType},
+			},
+			uniqueKey: []int{0, 1},
+			rows: [][]interface{}{
+				{"18446744073709551615", "-8388608", "1.5", "-2.25e-10", "0.000001", "2019-01-02 03:04:05.000006"},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := c.render()
+			golden := filepath.Join("testdata", "dump_sql", name+".golden")
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v. run with -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("%v differs. run with -update if the change is expected.\ngot:\n%s\nwant:\n%s",
+					golden, got, want)
+			}
+		})
+	}
+}
+
+func TestDumpSQLBuilder_Deterministic(t *testing.T) {
+	c := &dumpSQLCase{
+		schema: "db1",
+		table:  "t1",
+		columns: []umconf.Column{
+			{Name: "id", Type: umconf.IntColumnType},
+			{Name: "dec", Type: umconf.DecimalColumnType},
+			{Name: "g", Type: umconf.GeometryColumnType},
+		},
+		uniqueKey: []int{0},
+		rows:      [][]interface{}{{"1", "1.5", "\x01"}},
+	}
+	first := c.render()
+	for i := 0; i < 10; i++ {
+		if got := c.render(); got != first {
+			t.Fatalf("render %v differs:\n%s\n%s", i, got, first)
+		}
+	}
+}
+
+func TestDumpInsertQueries_AnsiQuotes(t *testing.T) {
+	rows := [][]*interface{}{{dumpSQLValue("1"), dumpSQLValue(nil)}}
+	got, _ := dumpInsertQueries("replace into", "order", `t"1`, rows, nil, 1024, true)
+	want := []string{`replace into "order"."t""1" values ('1',NULL)`}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, _ = dumpInsertQueries("replace into", "`a`", `"x"`, rows, nil, 1024, true)
+	want = []string{`replace into "` + "`a`" + `"."""x""" values ('1',NULL)`}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDumpInsertQueries_FirstRows(t *testing.T) {
+	rows := [][]*interface{}{
+		{dumpSQLValue("1")}, {dumpSQLValue("2")}, {dumpSQLValue("3")}, {dumpSQLValue("4")}, {dumpSQLValue("5")},
+	}
+	// Two rows a statement.
+	queries, firstRows := dumpInsertQueries("replace into", "s", "t", rows, nil, 35, false)
+	wantQueries := []string{
+		"replace into `s`.`t` values ('1'),('2')",
+		"replace into `s`.`t` values ('3'),('4')",
+		"replace into `s`.`t` values ('5')",
+	}
+	if !reflect.DeepEqual(queries, wantQueries) || !reflect.DeepEqual(firstRows, []int{0, 2, 4}) {
+		t.Fatalf("got %q, %v", queries, firstRows)
+	}
+
+	entry := &DumpEntry{TableSchema: "s", TableName: "t", ValuesX: rows, ChunkIndex: 3, RowsOffset: 100}
+	if got, want := entry.describe(2, 2), "chunk 3 of `s`.`t`, rows 103-104"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	entry = &DumpEntry{TableSchema: "s", TableName: "t", TbSQL: []string{"CREATE TABLE t (id int)"}}
+	if got, want := entry.describe(0, 0), "schema of `s`.`t`"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDumpInsertVerb(t *testing.T) {
+	tests := map[string]string{
+		"":                             "replace into",
+		config.FullCopyConflictReplace: "replace into",
+		config.FullCopyConflictIgnore:  "insert ignore into",
+		config.FullCopyConflictInsert:  "insert into",
+	}
+	for conflict, want := range tests {
+		if got := dumpInsertVerb(conflict); got != want {
+			t.Errorf("%q: got %v, want %v", conflict, got, want)
+		}
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 
 	"github.com/actiontech/dtle/internal/g"
@@ -20,7 +19,6 @@ import (
 	ubase "github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
 	"github.com/actiontech/dtle/internal/config"
-	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	log "github.com/actiontech/dtle/internal/logger"
 )
 
@@ -30,7 +28,6 @@ type dumper struct {
 	TableSchema    string
 	TableName      string
 	table          *config.Table
-	columns        string
 	resultsChannel chan *DumpEntry
 	shutdown       bool
 	shutdownCh     chan struct{}
@@ -43,6 +40,15 @@ type dumper struct {
 	// 0: don't checksum; 1: checksum once; 2: checksum every time
 	doChecksum int
 	oldWayDump bool
+
+	// Builds the queries of the chunks, set by prepareForDumping.
+	sqlBuilder *dumpSQLBuilder
+	// Adapts chunkSize after each chunk, if not nil.
+	chunkSizer *chunkSizer
+	// Pauses before each chunk while the source lags, if not nil.
+	throttler *replicaLagThrottler
+	// Rows dumped so far, as the offset of the next chunk in the old way.
+	offset int64
 }
 
 func NewDumper(db usql.QueryAble, table *config.Table, chunkSize int64,
@@ -93,7 +99,40 @@ type DumpEntry struct {
 	RowsCount  int64
 	colBuffer  bytes.Buffer
 	err        error
+	nBytes     int64
 	Table      *config.Table
+	// Indexes of the columns written as hex literals, as their values are
+	// binary, e.g. GEOMETRY.
+	HexColumns []int
+	// A chunk of the xbstream, if FullCopyMethod is xtrabackup.
+	XbstreamData []byte
+	// Path of the table file on the source, if FullCopyMethod is outfile.
+	OutfilePath string
+	// ExistingTarget of the job, with TbSQL.
+	ExistingTarget string
+	// Statements to add the secondary indexes stripped from TbSQL, after the full copy.
+	DeferredSQL []string
+	// The chunk of the table of ValuesX, from 1, and the rows of the table before it.
+	ChunkIndex int64
+	RowsOffset int64
+}
+
+// describe describes the entry in the errors of the applier: the table, and
+// the chunk and its nRows rows from firstRow, if the entry carries rows.
+func (e *DumpEntry) describe(firstRow, nRows int) string {
+	table := usql.EscapeName(e.TableSchema)
+	if e.TableName != "" {
+		table = fmt.Sprintf("%s.%s", table, usql.EscapeName(e.TableName))
+	}
+	switch {
+	case e.OutfilePath != "":
+		return fmt.Sprintf("rows of %s in %s", table, e.OutfilePath)
+	case len(e.ValuesX) > 0:
+		return fmt.Sprintf("chunk %d of %s, rows %d-%d", e.ChunkIndex, table,
+			e.RowsOffset+int64(firstRow)+1, e.RowsOffset+int64(firstRow+nRows))
+	default:
+		return fmt.Sprintf("schema of %s", table)
+	}
 }
 
 func (e *DumpEntry) incrementCounter() {
@@ -110,100 +149,20 @@ func (d *dumper) prepareForDumping() error {
 		return err
 	}
 
-	needPm := false
-	columns := make([]string, 0)
-	for _, col := range columnList.Columns {
-		switch col.Type {
-		case umconf.FloatColumnType, umconf.DoubleColumnType,
-			umconf.MediumIntColumnType, umconf.BigIntColumnType,
-			umconf.DecimalColumnType:
-			columns = append(columns, fmt.Sprintf("`%s`+0", col.Name))
-			needPm = true
-		default:
-			columns = append(columns, fmt.Sprintf("`%s`", col.Name))
-		}
-	}
-	if needPm {
-		d.columns = strings.Join(columns, ", ")
-	} else {
-		d.columns = "*"
-	}
-
+	d.sqlBuilder = newDumpSQLBuilder(d.TableSchema, d.TableName, columnList)
 	return nil
 }
 
-func (d *dumper) buildQueryOldWay() string {
-	return fmt.Sprintf(`SELECT %s FROM %s.%s where (%s) LIMIT %d OFFSET %d`,
-		d.columns,
-		usql.EscapeName(d.TableSchema),
-		usql.EscapeName(d.TableName),
-		d.table.Where,
-		d.chunkSize,
-		d.table.Iteration*d.chunkSize,
-	)
-}
-
-func (d *dumper) buildQueryOnUniqueKey() string {
-	nCol := len(d.table.UseUniqueKey.Columns.Columns)
-	uniqueKeyColumnAscending := make([]string, nCol, nCol)
-	for i, col := range d.table.UseUniqueKey.Columns.Columns {
-		colName := usql.EscapeName(col.Name)
-		switch col.Type {
-		case umconf.EnumColumnType:
-			// TODO try mysql enum type
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("concat(%s) asc", colName)
-		default:
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("%s asc", colName)
-		}
-	}
-
-	var rangeStr string
-
-	if d.table.Iteration == 0 {
-		rangeStr = "true"
-	} else {
-		rangeItems := make([]string, nCol)
-
-		// The form like: (A > a) or (A = a and B > b) or (A = a and B = b and C > c) or ...
-		for x := 0; x < nCol; x++ {
-			innerItems := make([]string, x+1)
-
-			for y := 0; y < x; y++ {
-				colName := usql.EscapeName(d.table.UseUniqueKey.Columns.Columns[y].Name)
-				innerItems[y] = fmt.Sprintf("(%s = %s)", colName, d.table.UseUniqueKey.LastMaxVals[y])
-			}
-
-			colName := usql.EscapeName(d.table.UseUniqueKey.Columns.Columns[x].Name)
-			innerItems[x] = fmt.Sprintf("(%s > %s)", colName, d.table.UseUniqueKey.LastMaxVals[x])
-
-			rangeItems[x] = fmt.Sprintf("(%s)", strings.Join(innerItems, " and "))
-		}
-
-		rangeStr = strings.Join(rangeItems, " or ")
-	}
-
-	return fmt.Sprintf(`SELECT %s FROM %s.%s where (%s) and (%s) order by %s LIMIT %d`,
-		d.columns,
-		usql.EscapeName(d.TableSchema),
-		usql.EscapeName(d.TableName),
-		// where
-		rangeStr, d.table.Where,
-		// order by
-		strings.Join(uniqueKeyColumnAscending, ", "),
-		// limit
-		d.chunkSize,
-	)
-}
-
 // dumps a specific chunk, reading chunk info from the channel
 func (d *dumper) getChunkData() (nRows int64, err error) {
 	entry := &DumpEntry{
 		TableSchema: d.TableSchema,
 		TableName:   d.TableName,
 		RowsCount:   0,
+		HexColumns:  d.sqlBuilder.hexColumns,
+		RowsOffset:  d.offset,
 	}
 	// TODO use PS
-	// TODO escape schema/table/column name once and save
 	defer func() {
 		entry.err = err
 		if err == nil && entry.RowsCount == 0 {
@@ -234,15 +193,16 @@ func (d *dumper) getChunkData() (nRows int64, err error) {
 
 	query := ""
 	if d.oldWayDump || d.table.UseUniqueKey == nil {
-		query = d.buildQueryOldWay()
+		query = d.sqlBuilder.chunkQueryOldWay(d.table.Where, d.chunkSize, d.offset)
 	} else {
-		query = d.buildQueryOnUniqueKey()
+		query = d.sqlBuilder.chunkQueryOnUniqueKey(d.table.UseUniqueKey, d.table.Iteration == 0,
+			d.table.Where, d.chunkSize)
 	}
 	d.logger.Debugf("getChunkData. query: %s", query)
 
 	if d.doChecksum != 0 {
 		if d.doChecksum == 2 || (d.doChecksum == 1 && d.table.Iteration == 0) {
-			row := d.db.QueryRow(fmt.Sprintf("checksum table %v.%v", d.TableSchema, d.TableName))
+			row := d.db.QueryRow(d.sqlBuilder.checksumQuery())
 			var table string
 			var cs int64
 			err := row.Scan(&table, &cs)
@@ -256,6 +216,8 @@ func (d *dumper) getChunkData() (nRows int64, err error) {
 
 	// this must be increased after building query
 	d.table.Iteration += 1
+	entry.ChunkIndex = d.table.Iteration
+	start := time.Now()
 	rows, err := d.db.Query(query)
 	if err != nil {
 		return 0, fmt.Errorf("exec [%s] error: %v", query, err)
@@ -269,6 +231,7 @@ func (d *dumper) getChunkData() (nRows int64, err error) {
 	scanArgs := make([]interface{}, len(columns)) // tmp use, for casting `values` to `[]interface{}`
 
 	interfacePtrWithNil := new(interface{})
+	var nBytes int64
 
 	for rows.Next() {
 		rowValuesRaw := make([]*interface{}, len(columns))
@@ -284,6 +247,8 @@ func (d *dumper) getChunkData() (nRows int64, err error) {
 		for i := range rowValuesRaw {
 			if rowValuesRaw[i] == nil {
 				rowValuesRaw[i] = interfacePtrWithNil
+			} else if bs, ok := (*rowValuesRaw[i]).([]byte); ok {
+				nBytes += int64(len(bs))
 			}
 		}
 		entry.ValuesX = append(entry.ValuesX, rowValuesRaw)
@@ -292,6 +257,16 @@ func (d *dumper) getChunkData() (nRows int64, err error) {
 	}
 
 	d.logger.Debugf("getChunkData. n_row: %d", entry.RowsCount)
+	d.offset += entry.RowsCount
+	entry.nBytes = nBytes
+	if d.chunkSizer != nil {
+		chunkSize := d.chunkSizer.Next(d.chunkSize, entry.RowsCount, nBytes, time.Since(start))
+		if chunkSize != d.chunkSize {
+			d.logger.Debugf("mysql.dumper: chunk size of %s.%s: %d -> %d. got %d bytes in %v",
+				d.TableSchema, d.TableName, d.chunkSize, chunkSize, nBytes, time.Since(start))
+			d.chunkSize = chunkSize
+		}
+	}
 
 	if entry.RowsCount > 0 {
 		var lastVals []string
@@ -336,19 +311,23 @@ func (d *dumper) Dump() error {
 				return
 			default:
 			}
+			if d.throttler != nil {
+				d.throttler.Wait(d.shutdownCh)
+			}
 
+			chunkSize := d.chunkSize
 			nRows, err := d.getChunkData()
 			if err != nil {
 				d.logger.Errorf("mysql.dumper: error at dump %v", err)
 				break
 			}
 
-			if nRows < d.chunkSize {
+			if nRows < chunkSize {
 				// If nRows < d.chunkSize while there are still more rows, it is a possible mysql bug.
-				d.logger.Infof("mysql.dumper: nRows < d.chunkSize. %v %v", nRows, d.chunkSize)
+				d.logger.Infof("mysql.dumper: nRows < d.chunkSize. %v %v", nRows, chunkSize)
 			}
 			if nRows == 0 {
-				d.logger.Infof("mysql.dumper: nRows == 0. dump finished. %v %v", nRows, d.chunkSize)
+				d.logger.Infof("mysql.dumper: nRows == 0. dump finished. %v %v", nRows, chunkSize)
 				break
 			}
 		}
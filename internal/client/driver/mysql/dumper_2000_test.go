@@ -27,7 +27,9 @@ func TestDumper2000(t *testing.T) {
 	mysqlCtx.SetDefault()
 
 	i := NewInspector(mysqlCtx, logger)
-	u.PanicIfErr(i.InitDBConnections())
+	if err := i.InitDBConnections(); err != nil {
+		t.Skipf("no MySQL on 127.0.0.1:3307: %v", err)
+	}
 	table := config.NewTable("tpcc1", "order_line")
 	i.ValidateOriginalTable("tpcc1", "order_line", table)
 
@@ -33,6 +33,7 @@ import (
 	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
 	"github.com/actiontech/dtle/utils"
@@ -62,6 +63,7 @@ type Extractor struct {
 	dataChannel              chan *binlog.BinlogEntry
 	inspector                *Inspector
 	binlogReader             *binlog.BinlogReader
+	serverIDPool             *serverIDPool
 	initialBinlogCoordinates *base.BinlogCoordinatesX
 	currentBinlogCoordinates *base.BinlogCoordinateTx
 	rowCopyComplete          chan bool
@@ -71,14 +73,42 @@ type Extractor struct {
 	sendByTimeoutCounter  int
 	sendBySizeFullCounter int
 
-	natsConn *gonats.Conn
-	waitCh   chan *models.WaitResult
+	copyProgress      *models.TableCopyProgress
+	copyProgressMutex sync.Mutex
+
+	// The gtid_executed of the source, queried at most every
+	// sourceGtidSetInterval for the IncrProgress.
+	sourceGtidSet      string
+	sourceGtidSetTime  time.Time
+	sourceGtidSetMutex sync.Mutex
+
+	natsConn  *gonats.Conn
+	transport *Transport
+	cipher    *TrafficCipher
+	waitCh    chan *models.WaitResult
 
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
 
 	testStub1Delay int64
+
+	// The workload of a bench job, nil for the other jobs.
+	bench *benchLoad
+
+	// Pauses the full copy while the source lags behind its primary, nil
+	// without MaxSourceReplicaLagSeconds.
+	replicaLag *replicaLagThrottler
+
+	// The table being resynced, nil if none, and the tables added to the job
+	// to be copied after it.
+	resync         *tableResync
+	pendingResyncs []*config.Table
+	resyncLock     sync.Mutex
+	// Whether the tables copied are recorded on the target, see
+	// copied_tables.go, and they changed since they were last sent.
+	recordsCopiedTables bool
+	copiedTablesChanged bool
 }
 
 func NewExtractor(subject, tp string, maxPayload int, cfg *config.MySQLDriverConfig, logger *log.Logger) (*Extractor, error) {
@@ -97,9 +127,24 @@ func NewExtractor(subject, tp string, maxPayload int, cfg *config.MySQLDriverCon
 		dataChannel:     make(chan *binlog.BinlogEntry, cfg.ReplChanBufferSize),
 		rowCopyComplete: make(chan bool),
 		waitCh:          make(chan *models.WaitResult, 1),
+		transport:       NewTransport(),
 		shutdownCh:      make(chan struct{}),
 		testStub1Delay:  0,
 	}
+	e.transport.SetBandwidthLimit(int64(cfg.BandwidthLimitMBps) * 1024 * 1024)
+	if cfg.MaxSourceReplicaLagSeconds > 0 {
+		e.replicaLag = newReplicaLagThrottler(cfg.MaxSourceReplicaLagSeconds, func() (int64, bool, error) {
+			return showReplicaLag(e.db)
+		}, entry)
+	}
+	cipher, err := NewTrafficCipher(cfg.TrafficKey)
+	if err != nil {
+		return nil, err
+	}
+	if cipher != nil {
+		e.cipher = cipher
+		e.maxPayload -= trafficOverhead
+	}
 
 	if delay, err := strconv.ParseInt(os.Getenv(g.ENV_TESTSTUB1_DELAY), 10, 64); err == nil {
 		e.logger.Infof("%v = %v", g.ENV_TESTSTUB1_DELAY, delay)
@@ -149,6 +194,13 @@ func (e *Extractor) Run() {
 	e.logger.Printf("mysql.extractor: Extract binlog events from %s.%d", e.mysqlContext.ConnectionConfig.Host, e.mysqlContext.ConnectionConfig.Port)
 	e.mysqlContext.StartTime = time.Now()
 
+	if e.tp == models.JobTypeBench {
+		if err := e.prepareBench(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+	}
+
 	// Validate job arguments
 	{
 		if e.mysqlContext.SkipCreateDbTable && e.mysqlContext.DropTableIfExists {
@@ -156,6 +208,23 @@ func (e *Extractor) Run() {
 				fmt.Errorf("conflicting job argument: SkipCreateDbTable=true and DropTableIfExists=true"))
 			return
 		}
+		if e.mysqlContext.SkipCreateDbTable && e.mysqlContext.DeferSecondaryIndexes {
+			e.onError(TaskStateDead,
+				fmt.Errorf("conflicting job argument: SkipCreateDbTable=true and DeferSecondaryIndexes=true"))
+			return
+		}
+		switch e.mysqlContext.ExistingTarget {
+		case config.ExistingTargetSkipCreate, config.ExistingTargetTruncate:
+		case config.ExistingTargetDropAndRecreate, config.ExistingTargetError:
+			if e.mysqlContext.SkipCreateDbTable {
+				e.onError(TaskStateDead, fmt.Errorf("conflicting job argument: SkipCreateDbTable=true and ExistingTarget=%v",
+					e.mysqlContext.ExistingTarget))
+				return
+			}
+		default:
+			e.onError(TaskStateDead, fmt.Errorf("unknown ExistingTarget %v", e.mysqlContext.ExistingTarget))
+			return
+		}
 	}
 
 	if err := e.initiateInspector(); err != nil {
@@ -170,6 +239,13 @@ func (e *Extractor) Run() {
 		e.onError(TaskStateDead, err)
 		return
 	}
+	if e.mysqlContext.GtidBackupFile != "" {
+		if err := e.resolveBackupGtid(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+	}
+	checkpoint := e.requestCheckpoint()
 
 	if e.mysqlContext.Gtid == "" {
 		if e.mysqlContext.AutoGtid {
@@ -196,7 +272,22 @@ func (e *Extractor) Run() {
 
 	if e.mysqlContext.Gtid == "" { // still empty: full copy
 		e.mysqlContext.MarkRowCopyStartTime()
-		if err := e.mysqlDump(); err != nil {
+		var err error
+		switch e.mysqlContext.FullCopyMethod {
+		case config.FullCopyMethodXtrabackup:
+			err = e.xtrabackupCopy()
+		case config.FullCopyMethodDump:
+			err = e.mysqlDump()
+		case config.FullCopyMethodOutfile:
+			if e.mysqlContext.OutfileDir == "" {
+				err = fmt.Errorf("OutfileDir is required for FullCopyMethod outfile")
+			} else {
+				err = e.mysqlDump()
+			}
+		default:
+			err = fmt.Errorf("unknown FullCopyMethod %v", e.mysqlContext.FullCopyMethod)
+		}
+		if err != nil {
 			e.onError(TaskStateDead, err)
 			return
 		}
@@ -222,12 +313,20 @@ func (e *Extractor) Run() {
 			e.onError(TaskStateDead, err)
 			return
 		}
+		if checkpoint != nil {
+			e.binlogReader.RecordOnlineSchemaChanges = true
+			e.initCopiedTables(checkpoint.CopiedTables)
+		}
 
 		if err := e.initiateStreaming(); err != nil {
 			e.logger.Debugf("mysql.extractor error at initiateStreaming: %v", err.Error())
 			e.onError(TaskStateDead, err)
 			return
 		}
+
+		if e.bench != nil {
+			go e.generateBenchLoad()
+		}
 	}
 }
 
@@ -252,38 +351,48 @@ func (e *Extractor) inspectTables() (err error) {
 			if doDb.TableSchema == "" {
 				continue
 			}
-			db := &config.DataSource{
-				TableSchema: doDb.TableSchema,
+			schemas, err := e.matchSchemas(doDb.TableSchema)
+			if err != nil {
+				return err
 			}
-
-			if len(doDb.Tables) == 0 {
-				tbs, err := sql.ShowTables(e.db, doDb.TableSchema, e.mysqlContext.ExpandSyntaxSupport)
-				if err != nil {
-					return err
+			for _, schema := range schemas {
+				db := &config.DataSource{
+					TableSchema: schema,
 				}
-				for _, doTb := range tbs {
-					doTb.TableSchema = doDb.TableSchema
-					if err := e.inspector.ValidateOriginalTable(doDb.TableSchema, doTb.TableName, doTb); err != nil {
-						e.logger.Warnf("mysql.extractor: %v", err)
-						continue
+
+				if len(doDb.Tables) == 0 {
+					tbs, err := sql.ShowTables(e.db, schema, e.mysqlContext.ExpandSyntaxSupport)
+					if err != nil {
+						return err
 					}
-					db.Tables = append(db.Tables, doTb)
-				}
-			} else {
-				for _, doTb := range doDb.Tables {
-					doTb.TableSchema = doDb.TableSchema
-					if err := e.inspector.ValidateOriginalTable(doDb.TableSchema, doTb.TableName, doTb); err != nil {
-						e.logger.Warnf("mysql.extractor: %v", err)
-						continue
+					for _, doTb := range tbs {
+						doTb.TableSchema = schema
+						if err := e.inspector.ValidateOriginalTable(schema, doTb.TableName, doTb); err != nil {
+							e.logger.Warnf("mysql.extractor: %v", err)
+							continue
+						}
+						db.Tables = append(db.Tables, doTb)
+					}
+				} else {
+					tbs, err := e.matchTables(schema, doDb.Tables)
+					if err != nil {
+						return err
+					}
+					for _, doTb := range tbs {
+						doTb.TableSchema = schema
+						if err := e.inspector.ValidateOriginalTable(schema, doTb.TableName, doTb); err != nil {
+							e.logger.Warnf("mysql.extractor: %v", err)
+							continue
+						}
+						db.Tables = append(db.Tables, doTb)
 					}
-					db.Tables = append(db.Tables, doTb)
 				}
-			}
 
-			e.replicateDoDb = append(e.replicateDoDb, db)
+				e.replicateDoDb = append(e.replicateDoDb, db)
+			}
 		}
 	} else {
-		dbs, err := sql.ShowDatabases(e.db)
+		dbs, err := sql.ShowDatabases(e.db, e.mysqlContext.SystemSchemas)
 		if err != nil {
 			return err
 		}
@@ -379,8 +488,8 @@ func (e *Extractor) readTableColumns() (err error) {
 }
 
 func (e *Extractor) initNatsPubClient() (err error) {
-	natsAddr := fmt.Sprintf("nats://%s", e.mysqlContext.NatsAddr)
-	sc, err := gonats.Connect(natsAddr)
+	natsAddr := config.NatsURL(e.mysqlContext.NatsAddr)
+	sc, err := config.NatsConnect(e.mysqlContext.NatsAddr, e.mysqlContext.Nats)
 	if err != nil {
 		e.logger.Errorf("mysql.extractor: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
 		return err
@@ -394,6 +503,7 @@ func (e *Extractor) initNatsPubClient() (err error) {
 // initiateStreaming begins treaming of binary log events and registers listeners for such events
 func (e *Extractor) initiateStreaming() error {
 	go func() {
+		defer crash.HandlePanic()
 		e.logger.Printf("mysql.extractor: Beginning streaming")
 		err := e.StreamEvents()
 		if err != nil {
@@ -450,19 +560,50 @@ func (e *Extractor) initDBConnections() (err error) {
 
 // initBinlogReader creates and connects the reader: we hook up to a MySQL server as a replica
 func (e *Extractor) initBinlogReader(binlogCoordinates *base.BinlogCoordinatesX) error {
+	if err := e.allocateServerID(); err != nil {
+		e.logger.Debugf("mysql.extractor: err at initBinlogReader: allocateServerID: %v", err.Error())
+		return err
+	}
 	binlogReader, err := binlog.NewMySQLReader(e.mysqlContext, e.logger, e.replicateDoDb)
 	if err != nil {
 		e.logger.Debugf("mysql.extractor: err at initBinlogReader: NewMySQLReader: %v", err.Error())
 		return err
 	}
+	binlogReader.SharedServerIDAllocator = e.allocateSharedServerID
 	if err := binlogReader.ConnectBinlogStreamer(*binlogCoordinates); err != nil {
 		e.logger.Debugf("mysql.extractor: err at initBinlogReader: ConnectBinlogStreamer: %v", err.Error())
 		return err
 	}
+	binlogReader.NewTableHook = e.onNewTable
 	e.binlogReader = binlogReader
 	return nil
 }
 
+// allocateServerID allocates the server_id of the binlog reader, unless it is
+// set in the config.
+func (e *Extractor) allocateServerID() error {
+	used, err := usedServerIDs(e.db)
+	if err != nil {
+		return err
+	}
+	if e.mysqlContext.ServerID != 0 {
+		if used[e.mysqlContext.ServerID] {
+			e.logger.Warnf("mysql.extractor: server_id %v is used by the source or one of its replicas",
+				e.mysqlContext.ServerID)
+		}
+		return nil
+	}
+
+	if e.serverIDPool, err = newServerIDPool(e.mysqlContext.ConsulAddr, e.subject); err != nil {
+		return err
+	}
+	if e.mysqlContext.ServerID, err = e.serverIDPool.Allocate(used); err != nil {
+		return err
+	}
+	e.logger.Printf("mysql.extractor: allocated server_id %v", e.mysqlContext.ServerID)
+	return nil
+}
+
 // validateConnection issues a simple can-connect to MySQL
 func (e *Extractor) validateConnection() error {
 	query := `select @@global.version`
@@ -481,6 +622,60 @@ func (e *Extractor) selectSqlMode() error {
 	return nil
 }
 
+// resolveBackupGtid starts the job from the gtid of GtidBackupFile, read on the
+// agent of the task, unless Gtid is given, which takes precedence.
+func (e *Extractor) resolveBackupGtid() error {
+	if e.mysqlContext.Gtid != "" {
+		e.logger.Printf("mysql.extractor: Gtid %v takes precedence over GtidBackupFile %v",
+			e.mysqlContext.Gtid, e.mysqlContext.GtidBackupFile)
+		return nil
+	}
+	gtid, err := base.ReadBackupGtid(e.mysqlContext.GtidBackupFile)
+	if err != nil {
+		return fmt.Errorf("read GtidBackupFile: %v", err)
+	}
+	e.mysqlContext.Gtid = gtid
+	return e.validateBackupGtid()
+}
+
+// validateBackupGtid checks the source could continue from the gtid of a restored backup.
+// The backup must be taken from this source, and binlogs after it must not be purged.
+func (e *Extractor) validateBackupGtid() error {
+	backupGtid, err := gomysql.ParseMysqlGTIDSet(e.mysqlContext.Gtid)
+	if err != nil {
+		return err
+	}
+
+	coord, err := base.GetSelfBinlogCoordinates(e.db)
+	if err != nil {
+		return err
+	}
+	executed, err := gomysql.ParseMysqlGTIDSet(coord.GtidSet)
+	if err != nil {
+		return err
+	}
+	if !executed.Contain(backupGtid) {
+		return fmt.Errorf("gtid of backup %v is not executed on source %v. Is the backup taken from the source?",
+			e.mysqlContext.Gtid, coord.GtidSet)
+	}
+
+	var purgedStr string
+	if err := e.db.QueryRow("select @@global.gtid_purged").Scan(&purgedStr); err != nil {
+		return err
+	}
+	purged, err := gomysql.ParseMysqlGTIDSet(strings.Replace(purgedStr, "\n", "", -1))
+	if err != nil {
+		return err
+	}
+	if !backupGtid.Contain(purged) {
+		return fmt.Errorf("binlog after backup gtid %v has been purged on source (gtid_purged %v)",
+			e.mysqlContext.Gtid, purgedStr)
+	}
+
+	e.logger.Printf("mysql.extractor: start from gtid of backup: %v", e.mysqlContext.Gtid)
+	return nil
+}
+
 // readCurrentBinlogCoordinates reads master status from hooked server
 func (e *Extractor) readCurrentBinlogCoordinates() error {
 	if e.mysqlContext.Gtid != "" {
@@ -616,6 +811,11 @@ func Encode(v interface{}) ([]byte, error) {
 func (e *Extractor) StreamEvents() error {
 	if e.mysqlContext.ApproveHeterogeneous {
 		go func() {
+			defer crash.HandlePanic()
+			if e.mysqlContext.IncrSubjectPartitions > 1 {
+				e.streamPartitionedEvents()
+				return
+			}
 			defer e.logger.Debugf("extractor. StreamEvents goroutine exited")
 
 			entries := binlog.BinlogEntries{}
@@ -644,6 +844,16 @@ func (e *Extractor) StreamEvents() error {
 				return nil
 			}
 
+			// addResyncEntry adds an entry of a resynced table.
+			addResyncEntry := func(entry *binlog.BinlogEntry) error {
+				entries.Entries = append(entries.Entries, entry)
+				entriesSize += entry.OriginalSize
+				if entriesSize >= e.mysqlContext.GroupMaxSize {
+					return sendEntries()
+				}
+				return nil
+			}
+
 			keepGoing := true
 
 			groupTimeoutDuration := time.Duration(e.mysqlContext.GroupTimeout) * time.Millisecond
@@ -654,6 +864,9 @@ func (e *Extractor) StreamEvents() error {
 				var err error
 				select {
 				case binlogEntry := <-e.dataChannel:
+					if err = e.resyncBefore(binlogEntry, addResyncEntry); err != nil {
+						break
+					}
 					entries.Entries = append(entries.Entries, binlogEntry)
 					entriesSize += binlogEntry.OriginalSize
 
@@ -665,9 +878,12 @@ func (e *Extractor) StreamEvents() error {
 						}
 						timer.Reset(groupTimeoutDuration)
 					}
+				case dumpEntry, ok := <-e.resyncRows():
+					err = e.sendResyncRows(dumpEntry, ok, addResyncEntry)
 				case <-timer.C:
+					err = e.resyncCaughtUp(addResyncEntry)
 					nEntries := len(entries.Entries)
-					if nEntries > 0 {
+					if err == nil && nEntries > 0 {
 						e.logger.Debugf("extractor. incr. send by timeout. entriesSize: %v", entriesSize)
 						err = sendEntries()
 					}
@@ -687,6 +903,7 @@ func (e *Extractor) StreamEvents() error {
 		subject := fmt.Sprintf("%s_incr_hete", e.subject)
 
 		go func() {
+			defer crash.HandlePanic()
 		L:
 			for {
 				select {
@@ -759,6 +976,7 @@ func (e *Extractor) StreamEvents() error {
 		subject := fmt.Sprintf("%s_incr", e.subject)
 
 		go func() {
+			defer crash.HandlePanic()
 		L:
 			for {
 				select {
@@ -834,23 +1052,33 @@ func (e *Extractor) StreamEvents() error {
 // retryOperation attempts up to `count` attempts at running given function,
 // exiting as soon as it returns with non-error.
 func (e *Extractor) publish(subject, gtid string, txMsg []byte) (err error) {
+	var msg []byte
 	for {
-		e.logger.Debugf("mysql.extractor: publish. gtid: %v, msg_len: %v", gtid, len(txMsg))
-		_, err = e.natsConn.Request(subject, txMsg, DefaultConnectWait)
+		// sealed on each attempt, as the Dest task drops a message received again
+		msg, err = e.cipher.Seal(subject, txMsg)
+		if err != nil {
+			return err
+		}
+		e.logger.Debugf("mysql.extractor: publish. gtid: %v, msg_len: %v", gtid, len(msg))
+		e.transport.Throttle(e.shutdownCh)
+		e.transport.LimitBandwidth(len(msg), e.shutdownCh)
+		_, err = e.natsConn.Request(subject, msg, DefaultConnectWait)
 		if err == nil {
+			e.transport.Acked()
 			if gtid != "" {
 				e.mysqlContext.Gtid = gtid
 			}
 			break
 		} else if err == gonats.ErrTimeout {
 			e.logger.Debugf("mysql.extractor: publish timeout, got %v", err)
+			e.transport.Redelivered()
 			continue
 		} else {
 			e.logger.Errorf("mysql.extractor: unexpected error on publish, got %v", err)
 			break
 		}
 		// there's an error. Let's try again.
-		e.logger.Debugf(fmt.Sprintf("mysql.extractor: there's an error [%v]. Let's try again", err))
+		e.logger.Debugf("mysql.extractor: there's an error [%v]. Let's try again", err)
 		time.Sleep(1 * time.Second)
 	}
 	return err
@@ -1040,7 +1268,7 @@ func (e *Extractor) mysqlDump() error {
 				}
 				tb.Counter = total
 				var dbSQL string
-				var tbSQL []string
+				var tbSQL, deferredSQL []string
 				if !e.mysqlContext.SkipCreateDbTable {
 					var err error
 					if strings.ToLower(tb.TableSchema) != "mysql" {
@@ -1053,19 +1281,34 @@ func (e *Extractor) mysqlDump() error {
 							return err
 						}*/
 					} else if strings.ToLower(tb.TableSchema) != "mysql" {
-						tbSQL, err = base.ShowCreateTable(e.singletonDB, tb.TableSchema, tb.TableName, e.mysqlContext.DropTableIfExists)
+						tbSQL, err = base.ShowCreateTable(e.singletonDB, tb.TableSchema, tb.TableName,
+							e.mysqlContext.ExistingTarget == config.ExistingTargetDropAndRecreate)
 						if err != nil {
 							return err
 						}
+						if e.mysqlContext.DeferSecondaryIndexes {
+							tbSQL, deferredSQL = deferSecondaryIndexes(tb.TableSchema, tb.TableName, tbSQL)
+						}
 					}
 				}
+				if e.mysqlContext.ExistingTarget == config.ExistingTargetTruncate &&
+					strings.ToLower(tb.TableType) != "view" && strings.ToLower(tb.TableSchema) != "mysql" {
+					if len(tbSQL) == 0 {
+						tbSQL = append(tbSQL, fmt.Sprintf("USE %s", sql.EscapeName(tb.TableSchema)))
+					}
+					tbSQL = append(tbSQL, fmt.Sprintf("TRUNCATE TABLE %s", sql.EscapeName(tb.TableName)))
+				}
 				entry := &DumpEntry{
 					SystemVariablesStatement: setSystemVariablesStatement,
 					SqlMode:                  setSqlMode,
 					DbSQL:                    dbSQL,
 					TbSQL:                    tbSQL,
+					TableSchema:              tb.TableSchema,
+					TableName:                tb.TableName,
 					TotalCount:               tb.Counter + 1,
 					RowsCount:                1,
+					ExistingTarget:           e.mysqlContext.ExistingTarget,
+					DeferredSQL:              deferredSQL,
 				}
 				atomic.AddInt64(&e.mysqlContext.RowsEstimate, 1)
 				atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, 1)
@@ -1085,6 +1328,7 @@ func (e *Extractor) mysqlDump() error {
 				SystemVariablesStatement: setSystemVariablesStatement,
 				SqlMode:                  setSqlMode,
 				DbSQL:                    dbSQL,
+				TableSchema:              db.TableSchema,
 				TotalCount:               1,
 				RowsCount:                1,
 			}
@@ -1095,6 +1339,26 @@ func (e *Extractor) mysqlDump() error {
 			}
 		}
 	}
+	if e.mysqlContext.ReplicateGrants {
+		grants, err := readGrants(tx)
+		if err != nil {
+			return err
+		}
+		e.logger.Printf("mysql.extractor: Step %d: - copying %d statements of the accounts", step, len(grants))
+		entry := &DumpEntry{
+			SystemVariablesStatement: setSystemVariablesStatement,
+			SqlMode:                  setSqlMode,
+			TableSchema:              "mysql",
+			TbSQL:                    grants,
+			TotalCount:               1,
+			RowsCount:                1,
+		}
+		atomic.AddInt64(&e.mysqlContext.RowsEstimate, 1)
+		atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, 1)
+		if err := e.encodeDumpEntry(entry); err != nil {
+			e.onError(TaskStateRestart, err)
+		}
+	}
 	step++
 
 	// ------
@@ -1113,8 +1377,41 @@ func (e *Extractor) mysqlDump() error {
 			// Obtain a record maker for this table, which knows about the schema ...
 			// Choose how we create statements based on the # of rows ...
 			e.logger.Printf("mysql.extractor: Step %d: - scanning table '%s.%s' (%d of %d tables)", step, t.TableSchema, t.TableName, counter, e.tableCount)
+			progress := &models.TableCopyProgress{
+				Table:        fmt.Sprintf("%s.%s", sql.EscapeName(t.TableSchema), sql.EscapeName(t.TableName)),
+				RowsEstimate: t.Counter,
+			}
+			startTable := time.Now()
+
+			if e.mysqlContext.FullCopyMethod == config.FullCopyMethodOutfile {
+				if e.replicaLag != nil {
+					e.replicaLag.Wait(e.shutdownCh)
+				}
+				entry, err := e.outfileCopy(tx, t)
+				if err != nil {
+					return err
+				}
+				entry.SystemVariablesStatement = setSystemVariablesStatement
+				entry.SqlMode = setSqlMode
+				if e.needToSendTabelDef() {
+					entry.Table = t
+				}
+				if err = e.encodeDumpEntry(entry); err != nil {
+					e.onError(TaskStateRestart, err)
+				}
+				atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, entry.RowsCount)
+				progress.ChunkIndex, progress.Rows, progress.Done = 1, entry.RowsCount, true
+				progress.Elapsed = time.Since(startTable)
+				e.setCopyProgress(progress)
+				continue
+			}
 
 			d := NewDumper(tx, t, e.mysqlContext.ChunkSize, e.logger)
+			if e.mysqlContext.AdaptiveChunkSize {
+				d.chunkSizer = newChunkSizer(e.mysqlContext.ChunkTargetBytes,
+					time.Duration(e.mysqlContext.ChunkTargetMillis)*time.Millisecond)
+			}
+			d.throttler = e.replicaLag
 			if err := d.Dump(); err != nil {
 				e.onError(TaskStateDead, err)
 			}
@@ -1134,8 +1431,17 @@ func (e *Extractor) mysqlDump() error {
 						e.onError(TaskStateRestart, err)
 					}
 					atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, entry.RowsCount)
+					progress.ChunkIndex++
+					progress.Rows += entry.RowsCount
+					progress.Bytes += entry.nBytes
+					progress.Elapsed = time.Since(startTable)
+					e.setCopyProgress(progress)
 				}
 			}
+			progress.Done = true
+			progress.Elapsed = time.Since(startTable)
+			e.setCopyProgress(progress)
+			e.logger.Printf("mysql.extractor: Step %d: - copied %v", step, progress)
 
 			//pool.Done()
 			//}(tb)
@@ -1165,6 +1471,14 @@ func (e *Extractor) encodeDumpEntry(entry *DumpEntry) error {
 	return nil
 }
 
+// setCopyProgress saves a copy of the progress of the table, for Stats.
+func (e *Extractor) setCopyProgress(progress *models.TableCopyProgress) {
+	p := *progress
+	e.copyProgressMutex.Lock()
+	e.copyProgress = &p
+	e.copyProgressMutex.Unlock()
+}
+
 func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 	totalRowsCopied := e.mysqlContext.GetTotalRowsCopied()
 	rowsEstimate := atomic.LoadInt64(&e.mysqlContext.RowsEstimate)
@@ -1215,6 +1529,16 @@ func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 		},
 		Timestamp: time.Now().UTC().UnixNano(),
 	}
+	if e.bench != nil {
+		taskResUsage.Bench = e.bench.stat()
+	}
+	e.copyProgressMutex.Lock()
+	taskResUsage.TableCopyProgress = e.copyProgress
+	e.copyProgressMutex.Unlock()
+	taskResUsage.TransportStat = e.transport.Stat(e.natsConn, nil)
+	if e.replicaLag != nil {
+		taskResUsage.SourceReplicaLag = e.replicaLag.Stat()
+	}
 	if e.natsConn != nil {
 		taskResUsage.MsgStat = e.natsConn.Statistics
 		e.mysqlContext.TotalTransferredBytes = int(taskResUsage.MsgStat.OutBytes)
@@ -1231,6 +1555,8 @@ func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 			Position: currentBinlogCoordinates.LogPos,
 			GtidSet:  fmt.Sprintf("%s:%d", currentBinlogCoordinates.GetSid(), currentBinlogCoordinates.GNO),
 		}
+		taskResUsage.IncrProgress = e.incrProgress()
+		taskResUsage.UnsupportedStatements = e.binlogReader.UnsupportedStatements()
 	} else {
 		taskResUsage.CurrentCoordinates = &models.CurrentCoordinates{
 			File:     "",
@@ -1242,6 +1568,43 @@ func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 	return &taskResUsage, nil
 }
 
+const sourceGtidSetInterval = 5 * time.Second
+
+// incrProgress returns how far the binlog read is behind the gtid_executed of
+// the source. It is nil if the gtid_executed cannot be queried.
+func (e *Extractor) incrProgress() *models.IncrProgress {
+	e.sourceGtidSetMutex.Lock()
+	defer e.sourceGtidSetMutex.Unlock()
+	if time.Since(e.sourceGtidSetTime) >= sourceGtidSetInterval {
+		coord, err := base.GetSelfBinlogCoordinates(e.db)
+		if err != nil {
+			e.logger.Warnf("mysql.extractor: get gtid_executed of the source: %v", err)
+			return nil
+		}
+		e.sourceGtidSet = coord.GtidSet
+		e.sourceGtidSetTime = time.Now()
+	}
+
+	readGtidSet, readTimestamp := e.binlogReader.GetReadProgress()
+	behind, err := base.CountGtidSetDiff(e.sourceGtidSet, readGtidSet)
+	if err != nil {
+		e.logger.Warnf("mysql.extractor: compare gtid sets %v and %v: %v", e.sourceGtidSet, readGtidSet, err)
+		return nil
+	}
+	progress := &models.IncrProgress{
+		SourceGtidSet:      e.sourceGtidSet,
+		GtidSet:            readGtidSet,
+		TransactionsBehind: behind,
+	}
+	if behind > 0 && readTimestamp > 0 {
+		progress.SecondsBehind = time.Now().Unix() - int64(readTimestamp)
+		if progress.SecondsBehind < 0 {
+			progress.SecondsBehind = 0
+		}
+	}
+	return progress
+}
+
 func (e *Extractor) ID() string {
 	id := config.DriverCtx{
 		DriverConfig: &config.MySQLDriverConfig{
@@ -1293,6 +1656,14 @@ func (e *Extractor) Shutdown() error {
 		d.Close()
 	}
 
+	e.resyncLock.Lock()
+	if e.resync != nil {
+		e.closeResync(e.resync)
+		e.resync = nil
+	}
+	e.pendingResyncs = nil
+	e.resyncLock.Unlock()
+
 	if err := sql.CloseDB(e.singletonDB); err != nil {
 		return err
 	}
@@ -1303,6 +1674,12 @@ func (e *Extractor) Shutdown() error {
 		}
 	}
 
+	if e.serverIDPool != nil {
+		if err := e.serverIDPool.Release(); err != nil {
+			e.logger.Warnf("mysql.extractor: release server_id %v: %v", e.mysqlContext.ServerID, err)
+		}
+	}
+
 	if err := sql.CloseDB(e.db); err != nil {
 		return err
 	}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faultInjector injects the faults of the env var g.ENV_FAULTS into the Dest
+// task, to test that the jobs recover from them without losing or repeating a
+// transaction. It is a comma separated list of key=value:
+//
+//   - nats_drop: percent of the messages from the Src task dropped before they
+//     are processed and acknowledged, as lost by the network. The Src task
+//     sends them again after DefaultConnectWait.
+//   - applier_kill: percent of the incremental transactions failed after their
+//     statements are executed and before the commit, failing the task.
+//   - chunk_delay: duration to wait before applying each chunk of the full copy.
+//   - seed: seed of the random faults, to reproduce a run.
+//
+// e.g. "nats_drop=5,applier_kill=0.1,chunk_delay=200ms". A nil faultInjector
+// injects no fault.
+type faultInjector struct {
+	natsDropPct    float64
+	applierKillPct float64
+	chunkDelay     time.Duration
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+// parseFaults parses the faults to inject, nil for an empty spec.
+func parseFaults(spec string) (*faultInjector, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	f := &faultInjector{}
+	seed := time.Now().UnixNano()
+	for _, kv := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad fault %q, should be key=value", kv)
+		}
+		key, value := parts[0], parts[1]
+		var err error
+		switch key {
+		case "nats_drop":
+			f.natsDropPct, err = parsePercent(value)
+		case "applier_kill":
+			f.applierKillPct, err = parsePercent(value)
+		case "chunk_delay":
+			f.chunkDelay, err = time.ParseDuration(value)
+		case "seed":
+			seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			err = fmt.Errorf("unknown fault")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fault %v: %v", key, err)
+		}
+	}
+	f.rand = rand.New(rand.NewSource(seed))
+	return f, nil
+}
+
+func parsePercent(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if pct < 0 || pct > 100 {
+		return 0, fmt.Errorf("%v is not a percent", s)
+	}
+	return pct, nil
+}
+
+func (f *faultInjector) String() string {
+	return fmt.Sprintf("nats_drop=%v%%, applier_kill=%v%%, chunk_delay=%v",
+		f.natsDropPct, f.applierKillPct, f.chunkDelay)
+}
+
+// hit returns true pct percent of the times.
+func (f *faultInjector) hit(pct float64) bool {
+	if f == nil || pct <= 0 {
+		return false
+	}
+	f.randLock.Lock()
+	defer f.randLock.Unlock()
+	return f.rand.Float64()*100 < pct
+}
+
+// dropMessage returns whether to drop a message received.
+func (f *faultInjector) dropMessage() bool {
+	return f != nil && f.hit(f.natsDropPct)
+}
+
+// killApplier returns whether to fail the transaction being applied.
+func (f *faultInjector) killApplier() bool {
+	return f != nil && f.hit(f.applierKillPct)
+}
+
+// delayChunk waits for chunk_delay, or the shutdown.
+func (f *faultInjector) delayChunk(shutdownCh <-chan struct{}) {
+	if f == nil || f.chunkDelay <= 0 {
+		return
+	}
+	timer := time.NewTimer(f.chunkDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-shutdownCh:
+	}
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	f, err := parseFaults("")
+	if err != nil || f != nil {
+		t.Fatalf("got %v, %v", f, err)
+	}
+	if f.dropMessage() || f.killApplier() {
+		t.Errorf("nil faultInjector injected a fault")
+	}
+	f.delayChunk(nil)
+
+	f, err = parseFaults("nats_drop=5, applier_kill=0.5,chunk_delay=200ms,seed=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.natsDropPct != 5 || f.applierKillPct != 0.5 || f.chunkDelay != 200*time.Millisecond {
+		t.Errorf("faults = %v", f)
+	}
+
+	for _, spec := range []string{"nats_drop", "nats_drop=101", "applier_kill=x", "chunk_delay=1", "foo=1", "seed=x"} {
+		if _, err := parseFaults(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+func TestFaultInjector_Hit(t *testing.T) {
+	f, err := parseFaults("nats_drop=100,seed=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if !f.dropMessage() {
+			t.Fatalf("message not dropped at 100%%")
+		}
+		if f.killApplier() {
+			t.Fatalf("applier killed at 0%%")
+		}
+	}
+
+	f, _ = parseFaults("nats_drop=10,seed=1")
+	dropped := 0
+	for i := 0; i < 10000; i++ {
+		if f.dropMessage() {
+			dropped++
+		}
+	}
+	if dropped < 800 || dropped > 1200 {
+		t.Errorf("dropped %v of 10000 at 10%%", dropped)
+	}
+}
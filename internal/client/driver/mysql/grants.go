@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// grantAccount is an account of mysql.user.
+type grantAccount struct {
+	User string
+	Host string
+}
+
+func (a *grantAccount) String() string {
+	return fmt.Sprintf("'%s'@'%s'", sql.EscapeValue(a.User), sql.EscapeValue(a.Host))
+}
+
+// parseGrantAccount parses an account as returned by CURRENT_USER(), user@host.
+func parseGrantAccount(s string) *grantAccount {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return &grantAccount{User: s}
+	}
+	return &grantAccount{User: s[:i], Host: s[i+1:]}
+}
+
+// skipGrantAccount tells whether the account is not copied to the target: the
+// anonymous, root and mysql.* accounts, which the target has its own, and the
+// account of the job, which would change the account dtle connects with.
+func skipGrantAccount(account *grantAccount, jobAccount *grantAccount) bool {
+	user := strings.ToLower(account.User)
+	return user == "" || user == "root" || strings.HasPrefix(user, "mysql.") || *account == *jobAccount
+}
+
+// createUserIfNotExists makes the CREATE USER statement of SHOW CREATE USER
+// keep an account existing on the target.
+func createUserIfNotExists(query string) string {
+	const prefix = "CREATE USER "
+	if !strings.HasPrefix(strings.ToUpper(query), prefix) ||
+		strings.HasPrefix(strings.ToUpper(query[len(prefix):]), "IF NOT EXISTS ") {
+		return query
+	}
+	return prefix + "IF NOT EXISTS " + query[len(prefix):]
+}
+
+// isRoutineGrant tells whether the GRANT statement is on a stored routine,
+// which the full copy doesn't copy.
+func isRoutineGrant(query string) bool {
+	query = strings.ToUpper(query)
+	return strings.Contains(query, " ON FUNCTION ") || strings.Contains(query, " ON PROCEDURE ")
+}
+
+// readGrants returns the statements creating the accounts of the source on the
+// target, with their passwords, and then granting their privileges but on the
+// stored routines, as a GRANT may refer to another account, e.g. a role. MySQL
+// 5.6 has no SHOW CREATE USER, its GRANT statements create the accounts.
+func readGrants(db sql.QueryAble) (queries []string, err error) {
+	// The password hashes of caching_sha2_password of MySQL 8.0 are binary, and
+	// would not be valid in the statements as such.
+	if _, err := db.Exec("SET SESSION print_identified_with_as_hex = ON"); err != nil {
+		if mysqlErr, ok := err.(*gomysql.MySQLError); !ok || mysqlErr.Number != sql.ErrUnknownSystemVariable {
+			return nil, err
+		}
+	}
+	var currentUser string
+	if err := db.QueryRow("SELECT CURRENT_USER()").Scan(&currentUser); err != nil {
+		return nil, err
+	}
+	jobAccount := parseGrantAccount(currentUser)
+
+	var accounts []*grantAccount
+	rows, err := db.Query("SELECT user, host FROM mysql.user ORDER BY user, host")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		account := &grantAccount{}
+		if err := rows.Scan(&account.User, &account.Host); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !skipGrantAccount(account, jobAccount) {
+			accounts = append(accounts, account)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var grantQueries []string
+	for _, account := range accounts {
+		var createUser string
+		err := db.QueryRow(fmt.Sprintf("SHOW CREATE USER %s", account)).Scan(&createUser)
+		if mysqlErr, ok := err.(*gomysql.MySQLError); ok && mysqlErr.Number == sql.ErrParse {
+			// MySQL 5.6.
+		} else if err != nil {
+			return nil, fmt.Errorf("show create user %s: %v", account, err)
+		} else {
+			queries = append(queries, createUserIfNotExists(createUser))
+		}
+
+		grants, err := showGrants(db, account)
+		if err != nil {
+			return nil, fmt.Errorf("show grants for %s: %v", account, err)
+		}
+		grantQueries = append(grantQueries, grants...)
+	}
+	return append(queries, grantQueries...), nil
+}
+
+func showGrants(db sql.QueryAble, account *grantAccount) (grants []string, err error) {
+	rows, err := db.Query(fmt.Sprintf("SHOW GRANTS FOR %s", account))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var grant gosql.NullString
+		if err := rows.Scan(&grant); err != nil {
+			return nil, err
+		}
+		if !isRoutineGrant(grant.String) {
+			grants = append(grants, grant.String)
+		}
+	}
+	return grants, rows.Err()
+}
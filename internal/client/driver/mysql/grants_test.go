@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestSkipGrantAccount(t *testing.T) {
+	tests := []struct {
+		user string
+		want bool
+	}{
+		{"app", false},
+		{"", true},
+		{"root", true},
+		{"mysql.sys", true},
+		{"mysql.session", true},
+		{"dtle", true},
+	}
+	for _, tt := range tests {
+		if got := skipGrantAccount(&grantAccount{User: tt.user, Host: "%"}, parseGrantAccount("dtle@%")); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.user, got, tt.want)
+		}
+	}
+	// The same user of another host is not the account of the job.
+	if skipGrantAccount(&grantAccount{User: "dtle", Host: "10.0.0.1"}, parseGrantAccount("dtle@%")) {
+		t.Errorf("dtle@10.0.0.1 skipped")
+	}
+	if a := parseGrantAccount("dt@le@10.0.0.%"); *a != (grantAccount{User: "dt@le", Host: "10.0.0.%"}) {
+		t.Errorf("parseGrantAccount() = %+v", a)
+	}
+
+	account := &grantAccount{User: "o'neil", Host: "10.0.0.%"}
+	if s := account.String(); s != `'o\'neil'@'10.0.0.%'` {
+		t.Errorf("String() = %v", s)
+	}
+}
+
+func TestCreateUserIfNotExists(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"CREATE USER 'app'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*AB'",
+			"CREATE USER IF NOT EXISTS 'app'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*AB'"},
+		{"CREATE USER IF NOT EXISTS 'app'@'%'", "CREATE USER IF NOT EXISTS 'app'@'%'"},
+		// MySQL 8.0, with print_identified_with_as_hex.
+		{"CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124303035240C5A2B5D " +
+			"REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK PASSWORD HISTORY DEFAULT " +
+			"PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT DEFAULT",
+			"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124303035240C5A2B5D " +
+				"REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK PASSWORD HISTORY DEFAULT " +
+				"PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT DEFAULT"},
+		{"GRANT USAGE ON *.* TO 'app'@'%'", "GRANT USAGE ON *.* TO 'app'@'%'"},
+	}
+	for _, tt := range tests {
+		if got := createUserIfNotExists(tt.query); got != tt.want {
+			t.Errorf("%v: got %v", tt.query, got)
+		}
+	}
+}
+
+func TestIsRoutineGrant(t *testing.T) {
+	if isRoutineGrant("GRANT SELECT, INSERT ON `db1`.* TO 'app'@'%'") {
+		t.Errorf("grant on a schema is a routine grant")
+	}
+	if !isRoutineGrant("GRANT EXECUTE ON PROCEDURE `db1`.`p1` TO 'app'@'%'") {
+		t.Errorf("grant on a procedure is not a routine grant")
+	}
+}
@@ -65,6 +65,12 @@ func (i *Inspector) InitDBConnections() (err error) {
 	if err := i.validateBinlogs(); err != nil {
 		return err
 	}
+
+	if i.mysqlContext.SemiSync {
+		if err := i.validateSemiSync(); err != nil {
+			return err
+		}
+	}
 	i.logger.Printf("mysql.inspector: Initiated on %s:%d, version %+v", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port, i.mysqlContext.MySQLVersion)
 	return nil
 }
@@ -267,10 +273,34 @@ func (i *Inspector) validateBinlogs() error {
 	}
 	i.mysqlContext.BinlogRowImage = strings.ToUpper(i.mysqlContext.BinlogRowImage)
 
+	query = `select @@global.binlog_checksum`
+	var binlogChecksum string
+	if err := i.db.QueryRow(query).Scan(&binlogChecksum); err == nil && strings.ToUpper(binlogChecksum) == "NONE" {
+		i.logger.Warnf("mysql.inspector: binlog_checksum is NONE on %s:%d, corrupted events cannot be detected", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
+	}
+
 	i.logger.Printf("mysql.inspector: Binary logs validated on %s:%d", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
 	return nil
 }
 
+// validateSemiSync checks that the source waits for the acks of semi-sync
+// replicas, so that it waits for the extractor.
+func (i *Inspector) validateSemiSync() error {
+	var enabled string
+	err := usql.QueryRowsMap(i.db, `show global variables like 'rpl_semi_sync_master_enabled'`, func(m usql.RowMap) error {
+		enabled = m.GetString("Value")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if enabled != "ON" {
+		return fmt.Errorf("%s:%d must have rpl_semi_sync_master_enabled for SemiSync", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
+	}
+	i.logger.Printf("mysql.inspector: Semi-sync validated on %s:%d", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
+	return nil
+}
+
 // validateTable makes sure the table we need to operate on actually exists
 func (i *Inspector) validateTable(databaseName, tableName string) error {
 	query := fmt.Sprintf(`show table status from %s like '%s'`, usql.EscapeName(databaseName), tableName)
@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// matchSchemas returns the schemas of the source a TableSchema of ReplicateDoDb
+// matches: the schemas matching the regex after "~", not listed before, or
+// else the schema itself.
+func (e *Extractor) matchSchemas(pattern string) ([]string, error) {
+	if !strings.HasPrefix(pattern, "~") {
+		return []string{pattern}, nil
+	}
+	re, err := regexp.Compile(pattern[1:])
+	if err != nil {
+		return nil, fmt.Errorf("bad regex of TableSchema %v: %v", pattern, err)
+	}
+	dbs, err := sql.ShowDatabases(e.db, e.mysqlContext.SystemSchemas)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []string
+	for _, db := range dbs {
+		if re.MatchString(db) && !e.schemaListed(db) {
+			schemas = append(schemas, db)
+		}
+	}
+	return schemas, nil
+}
+
+func (e *Extractor) schemaListed(schema string) bool {
+	for _, db := range e.replicateDoDb {
+		if db.TableSchema == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTables returns the tables of the schema the Tables of ReplicateDoDb
+// match. A table by name is returned as is, and a regex after "~" is replaced
+// by the tables of the source matching it, with its Where.
+func (e *Extractor) matchTables(schema string, patterns []*config.Table) ([]*config.Table, error) {
+	var tables []*config.Table
+	var shown []*config.Table
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern.TableName, "~") {
+			tables = append(tables, pattern)
+			continue
+		}
+		re, err := regexp.Compile(pattern.TableName[1:])
+		if err != nil {
+			return nil, fmt.Errorf("bad regex of TableName %v: %v", pattern.TableName, err)
+		}
+		if shown == nil {
+			if shown, err = sql.ShowTables(e.db, schema, e.mysqlContext.ExpandSyntaxSupport); err != nil {
+				return nil, err
+			}
+		}
+		for _, tb := range shown {
+			if !re.MatchString(tb.TableName) || findTableOf(tables, tb.TableName) != nil {
+				continue
+			}
+			table := *tb
+			table.Where = pattern.Where
+			tables = append(tables, &table)
+		}
+	}
+	return tables, nil
+}
+
+func findTableOf(tables []*config.Table, name string) *config.Table {
+	for _, t := range tables {
+		if t.TableName == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// onNewTable adds a table created on the source, which matches the filters, to
+// the tables of the job. With BackfillNewTables, it is also copied as a table
+// added to the job, from a snapshot taken after it is created. It is called by
+// the binlog reader.
+func (e *Extractor) onNewTable(table *config.Table) {
+	e.resyncLock.Lock()
+	defer e.resyncLock.Unlock()
+	if findTable(e.replicateDoDb, table.TableSchema, table.TableName) == nil {
+		e.replicateDoDb = addTable(e.replicateDoDb, table)
+	}
+	if !e.mysqlContext.BackfillNewTables {
+		e.copiedTablesChange()
+		return
+	}
+	if err := e.checkResync(); err != nil {
+		e.logger.Warnf("mysql.extractor: not copying the new table %v.%v: %v",
+			table.TableSchema, table.TableName, err)
+		return
+	}
+	e.pendingResyncs = append(e.pendingResyncs, table)
+}
+
+// startNewTableResync starts copying the next table added to the job, if none
+// is being copied, as the tables found by the binlog reader are only queued. A
+// table which could not be copied, e.g. dropped since, is given up.
+// resyncLock is held.
+func (e *Extractor) startNewTableResync() {
+	for e.resync == nil && len(e.pendingResyncs) > 0 {
+		err := e.startPendingResync()
+		if err == nil {
+			return
+		}
+		e.logger.Warnf("mysql.extractor: %v", err)
+		e.pendingResyncs = e.pendingResyncs[1:]
+		e.copiedTablesChange()
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/g"
+)
+
+// The ALTERs of the online schema changes of the tables replicated are recorded
+// in the online_schema_changes table of the dtle schema on the target, and
+// applied to the table when the ghost table is swapped in, see
+// binlog/online_schema_change.go. As they are recorded in order with the
+// transactions, those read before the checkpoint are not read again, and not
+// lost, on a restart during an online schema change.
+
+// createTableOnlineSchemaChanges creates the online_schema_changes table.
+func (a *Applier) createTableOnlineSchemaChanges() error {
+	query := fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %v.%v (
+				id bigint NOT NULL AUTO_INCREMENT,
+				job_uuid binary(16) NOT NULL COMMENT 'unique identifier of job',
+				table_schema varchar(64) NOT NULL COMMENT 'schema of the table on the source.',
+				table_name varchar(64) NOT NULL COMMENT 'name of the table on the source.',
+				alter_query text NOT NULL COMMENT 'alter of the table, applied when the ghost table is swapped in.',
+				PRIMARY KEY (id),
+				KEY (job_uuid, table_schema, table_name)
+			);
+		`, g.DtleSchemaName, g.OnlineSchemaChangesTable)
+	_, err := a.db.Exec(query)
+	return err
+}
+
+// applyOnlineSchemaChange applies in the transaction the event of an online
+// schema change, and returns the statements executed. The swap sets the Query
+// of the event to the ALTERs applied, for the DDL hooks.
+func (a *Applier) applyOnlineSchemaChange(tx *gosql.Tx, event *binlog.DataEvent) ([]auditedStatement, error) {
+	where := fmt.Sprintf("job_uuid = unhex('%s') and table_schema = ? and table_name = ?",
+		hex.EncodeToString(a.subjectUUID.Bytes()))
+	whereArgs := []interface{}{event.DatabaseName, event.TableName}
+	var statements []auditedStatement
+	exec := func(query string, args ...interface{}) error {
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+		statements = append(statements, auditedStatement{query: query, args: args})
+		return nil
+	}
+
+	switch event.OnlineSchemaChange {
+	case binlog.OnlineSchemaChangeAlter:
+		err := exec(fmt.Sprintf("insert into %v.%v (job_uuid,table_schema,table_name,alter_query) "+
+			"values (unhex('%s'), ?, ?, ?)", g.DtleSchemaName, g.OnlineSchemaChangesTable,
+			hex.EncodeToString(a.subjectUUID.Bytes())), event.DatabaseName, event.TableName, event.Query)
+		return statements, err
+	case binlog.OnlineSchemaChangeCancel:
+		err := exec(fmt.Sprintf("delete from %v.%v where %s", g.DtleSchemaName, g.OnlineSchemaChangesTable, where),
+			whereArgs...)
+		return statements, err
+	case binlog.OnlineSchemaChangeSwap:
+		alters, err := selectOnlineSchemaChanges(tx, where, whereArgs)
+		if err != nil {
+			return nil, err
+		}
+		if len(alters) == 0 {
+			a.logger.Warnf("mysql.applier: no alter of the online schema change of %v.%v was recorded, "+
+				"e.g. it started before the job. alter the table on the target the same way",
+				event.DatabaseName, event.TableName)
+			return nil, nil
+		}
+		a.logger.Printf("mysql.applier: applying the alters of the online schema change of %v.%v: %v",
+			event.DatabaseName, event.TableName, alters)
+		if err := exec(fmt.Sprintf("USE %s", sql.QuoteName(event.DatabaseName, a.mysqlContext.AnsiQuotes))); err != nil {
+			return nil, err
+		}
+		for _, alter := range alters {
+			if err := exec(alter); err != nil {
+				if !sql.IgnoreError(err) {
+					a.logger.Errorf("mysql.applier: Exec sql error: %v", err)
+					return nil, err
+				}
+				a.logger.Warnf("mysql.applier: Ignore error: %v", err)
+			}
+		}
+		if err := exec(fmt.Sprintf("delete from %v.%v where %s", g.DtleSchemaName, g.OnlineSchemaChangesTable,
+			where), whereArgs...); err != nil {
+			return nil, err
+		}
+		event.Query = strings.Join(alters, ";\n")
+		return statements, nil
+	default:
+		return nil, fmt.Errorf("unknown online schema change event %v", event.OnlineSchemaChange)
+	}
+}
+
+// selectOnlineSchemaChanges returns the ALTERs recorded for a table, in order.
+func selectOnlineSchemaChanges(tx *gosql.Tx, where string, whereArgs []interface{}) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf("select alter_query from %v.%v where %s order by id",
+		g.DtleSchemaName, g.OnlineSchemaChangesTable, where), whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var alters []string
+	for rows.Next() {
+		var alter string
+		if err := rows.Scan(&alter); err != nil {
+			return nil, err
+		}
+		alters = append(alters, alter)
+	}
+	return alters, rows.Err()
+}
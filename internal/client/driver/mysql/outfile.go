@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// outfileName is the name of the table file of the job, e.g. `job1.db1.tb1.txt`.
+func outfileName(subject string, t *config.Table) string {
+	return fmt.Sprintf("%s.%s.%s.txt", subject, t.TableSchema, t.TableName)
+}
+
+// quoteFilePath quotes a file path as a string literal.
+func quoteFilePath(path string) string {
+	return fmt.Sprintf("'%s'", sql.EscapeValue(path))
+}
+
+// outfileCopy writes the rows of the table to a file in OutfileDir with
+// SELECT ... INTO OUTFILE, in the transaction of the consistent snapshot.
+// The rows are not sent, but loaded by the applier from the file.
+func (e *Extractor) outfileCopy(tx sql.QueryAble, t *config.Table) (*DumpEntry, error) {
+	path := filepath.Join(e.mysqlContext.OutfileDir, outfileName(e.subject, t))
+	// Left by a previous run of the job. mysqld will not overwrite it.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		e.logger.Warnf("mysql.extractor: remove outfile %v error: %v", path, err)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s.%s WHERE (%s) INTO OUTFILE %s CHARACTER SET binary",
+		sql.EscapeName(t.TableSchema), sql.EscapeName(t.TableName), t.Where, quoteFilePath(path))
+	e.logger.Debugf("mysql.extractor: exec %v", query)
+	result, err := tx.Exec(query)
+	if err != nil {
+		return nil, fmt.Errorf("write outfile for %s.%s: %v", t.TableSchema, t.TableName, err)
+	}
+	nRows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	e.logger.Printf("mysql.extractor: written %d rows of %s.%s to %v", nRows, t.TableSchema, t.TableName, path)
+
+	return &DumpEntry{
+		TableSchema: t.TableSchema,
+		TableName:   t.TableName,
+		RowsCount:   nRows,
+		OutfilePath: path,
+	}, nil
+}
+
+// outfilePath is the path of the table file of the entry on the target, with
+// OutfileDir of Dest if set.
+func (a *Applier) outfilePath(entry *DumpEntry) string {
+	if a.mysqlContext.OutfileDir == "" {
+		return entry.OutfilePath
+	}
+	return filepath.Join(a.mysqlContext.OutfileDir, filepath.Base(entry.OutfilePath))
+}
+
+func (a *Applier) buildLoadDataQuery(entry *DumpEntry) string {
+	// A duplicate key fails LOAD DATA INFILE without REPLACE or IGNORE.
+	modifier := "REPLACE "
+	switch a.mysqlContext.FullCopyConflict {
+	case config.FullCopyConflictIgnore:
+		modifier = "IGNORE "
+	case config.FullCopyConflictInsert:
+		modifier = ""
+	}
+	return fmt.Sprintf("LOAD DATA INFILE %s %sINTO TABLE %s.%s CHARACTER SET binary",
+		quoteFilePath(a.outfilePath(entry)), modifier,
+		sql.QuoteName(entry.TableSchema, a.mysqlContext.AnsiQuotes),
+		sql.QuoteName(entry.TableName, a.mysqlContext.AnsiQuotes))
+}
+
+// removeOutfile removes the table file after it is loaded. It is not an error if
+// the file is not seen by the agent.
+func (a *Applier) removeOutfile(entry *DumpEntry) {
+	path := a.outfilePath(entry)
+	if err := os.Remove(path); err != nil {
+		if !os.IsNotExist(err) && !os.IsPermission(err) {
+			a.logger.Warnf("mysql.applier: remove outfile %v error: %v", path, err)
+		}
+		return
+	}
+	a.logger.Debugf("mysql.applier: removed outfile %v", path)
+}
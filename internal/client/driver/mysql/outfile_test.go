@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func TestApplier_BuildLoadDataQuery(t *testing.T) {
+	table := &config.Table{TableSchema: "db1", TableName: "tb1"}
+	entry := &DumpEntry{
+		TableSchema: "db1",
+		TableName:   "tb1",
+		OutfilePath: "/mnt/src/it's/" + outfileName("job1", table),
+	}
+
+	a := &Applier{mysqlContext: &config.MySQLDriverConfig{}}
+	want := "LOAD DATA INFILE '/mnt/src/it\\'s/job1.db1.tb1.txt' REPLACE INTO TABLE `db1`.`tb1` CHARACTER SET binary"
+	if got := a.buildLoadDataQuery(entry); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	a.mysqlContext.FullCopyConflict = config.FullCopyConflictIgnore
+	want = "LOAD DATA INFILE '/mnt/src/it\\'s/job1.db1.tb1.txt' IGNORE INTO TABLE `db1`.`tb1` CHARACTER SET binary"
+	if got := a.buildLoadDataQuery(entry); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	a.mysqlContext.FullCopyConflict = config.FullCopyConflictInsert
+	want = "LOAD DATA INFILE '/mnt/src/it\\'s/job1.db1.tb1.txt' INTO TABLE `db1`.`tb1` CHARACTER SET binary"
+	if got := a.buildLoadDataQuery(entry); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// The dir is mounted on another path on the target.
+	a.mysqlContext.OutfileDir = "/mnt/dest"
+	if got := a.outfilePath(entry); got != "/mnt/dest/job1.db1.tb1.txt" {
+		t.Fatalf("unexpected path %v", got)
+	}
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func tableKey(schema, table string) string {
+	return schema + "\x00" + table
+}
+
+// keyPartition is the partition (1..n) of the stream of a table by its tableKey.
+func keyPartition(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()%uint32(n)) + 1
+}
+
+// tablePartition is the partition (1..n) of the stream of a table.
+func tablePartition(schema, table string, n int) int {
+	return keyPartition(tableKey(schema, table), n)
+}
+
+// tableLinks maps each table with a foreign key, or referenced by one, to the
+// first by tableKey of the tables linked to it by foreign keys, directly or
+// through others. The linked tables are in its partition, so that the rows of a
+// parent table and of its children are applied in the order of the source.
+type tableLinks map[string]string
+
+// partition is the partition (1..n) of the stream of a table.
+func (l tableLinks) partition(schema, table string, n int) int {
+	key := tableKey(schema, table)
+	if root, ok := l[key]; ok {
+		key = root
+	}
+	return keyPartition(key, n)
+}
+
+// newTableLinks returns the links of the foreign keys given as pairs of the
+// tableKey of a table and of the table it references.
+func newTableLinks(fks [][2]string) tableLinks {
+	parent := make(map[string]string)
+	var find func(key string) string
+	find = func(key string) string {
+		p, ok := parent[key]
+		if !ok || p == key {
+			parent[key] = key
+			return key
+		}
+		root := find(p)
+		parent[key] = root
+		return root
+	}
+	for _, fk := range fks {
+		a, b := find(fk[0]), find(fk[1])
+		if a < b {
+			parent[b] = a
+		} else {
+			parent[a] = b
+		}
+	}
+	links := make(tableLinks, len(parent))
+	for key := range parent {
+		links[key] = find(key)
+	}
+	return links
+}
+
+// readTableLinks reads the foreign keys of the source.
+func (e *Extractor) readTableLinks() (tableLinks, error) {
+	query := `select TABLE_SCHEMA, TABLE_NAME, REFERENCED_TABLE_SCHEMA, REFERENCED_TABLE_NAME
+		from information_schema.KEY_COLUMN_USAGE where REFERENCED_TABLE_NAME is not null`
+	rows, err := e.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks [][2]string
+	for rows.Next() {
+		var schema, table, refSchema, refTable string
+		if err := rows.Scan(&schema, &table, &refSchema, &refTable); err != nil {
+			return nil, err
+		}
+		fks = append(fks, [2]string{tableKey(schema, table), tableKey(refSchema, refTable)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newTableLinks(fks), nil
+}
+
+// entryPartition is the partition (1..n) of the stream of the tables of the entry,
+// or binlog.PartitionAll if the tables are in several partitions or it has DDL.
+func entryPartition(entry *binlog.BinlogEntry, n int, links tableLinks) int {
+	partition := binlog.PartitionAll
+	for i := range entry.Events {
+		event := &entry.Events[i]
+		if event.DML == binlog.NotDML {
+			return binlog.PartitionAll
+		}
+		p := links.partition(event.DatabaseName, event.TableName, n)
+		if partition == binlog.PartitionAll {
+			partition = p
+		} else if partition != p {
+			return binlog.PartitionAll
+		}
+	}
+	return partition
+}
+
+func hasDDL(entry *binlog.BinlogEntry) bool {
+	for i := range entry.Events {
+		if entry.Events[i].DML == binlog.NotDML {
+			return true
+		}
+	}
+	return false
+}
+
+// streamPartition groups and sends the entries of a partition of the stream.
+type streamPartition struct {
+	subject string
+	entries chan *binlog.BinlogEntry
+	// A request to send the entries received so far, answered when they are acked.
+	flush chan chan error
+}
+
+// streamPartitionedEvents sends the entries to the IncrSubjectPartitions subjects
+// `<subject>_incr_hete.<partition>` in parallel. An entry of binlog.PartitionAll is
+// sent to `<subject>_incr_hete` after all the previous entries are acked, and the
+// next entries are sent after it is acked. The applier enqueues the entries before
+// it acks, so it gets the entries of a partition, and the entries of PartitionAll
+// relative to all the others, in order.
+//
+// The tables linked by foreign keys are in the same partition. The foreign keys
+// are read from the source at start, and again after an entry with DDL, which
+// might have added some. The source being ahead of the stream, they are never
+// missing the foreign keys of the entries sent after.
+func (e *Extractor) streamPartitionedEvents() {
+	defer e.logger.Debugf("extractor. streamPartitionedEvents goroutine exited")
+
+	n := e.mysqlContext.IncrSubjectPartitions
+	links, err := e.readTableLinks()
+	if err != nil {
+		e.onError(TaskStateDead, fmt.Errorf("read the foreign keys of the source: %v", err))
+		return
+	}
+	partitions := make([]*streamPartition, n)
+	for i := range partitions {
+		partitions[i] = &streamPartition{
+			subject: fmt.Sprintf("%s_incr_hete.%d", e.subject, i+1),
+			entries: make(chan *binlog.BinlogEntry, e.mysqlContext.ReplChanBufferSize),
+			flush:   make(chan chan error),
+		}
+		go e.sendPartition(partitions[i])
+	}
+
+	flushAll := func() error {
+		for _, p := range partitions {
+			done := make(chan error, 1)
+			select {
+			case p.flush <- done:
+			case <-e.shutdownCh:
+				return nil
+			}
+			select {
+			case err := <-done:
+				if err != nil {
+					return err
+				}
+			case <-e.shutdownCh:
+				return nil
+			}
+		}
+		return nil
+	}
+
+	for !e.shutdown {
+		var binlogEntry *binlog.BinlogEntry
+		select {
+		case binlogEntry = <-e.dataChannel:
+		case <-e.shutdownCh:
+			return
+		}
+
+		binlogEntry.Partition = entryPartition(binlogEntry, n, links)
+		if binlogEntry.Partition == binlog.PartitionAll {
+			err := flushAll()
+			if err == nil {
+				err = e.sendEntries(fmt.Sprintf("%s_incr_hete", e.subject),
+					binlog.BinlogEntries{Entries: []*binlog.BinlogEntry{binlogEntry}})
+			}
+			if err == nil && hasDDL(binlogEntry) {
+				links, err = e.readTableLinks()
+			}
+			if err != nil {
+				e.onError(TaskStateDead, err)
+				return
+			}
+		} else {
+			select {
+			case partitions[binlogEntry.Partition-1].entries <- binlogEntry:
+			case <-e.shutdownCh:
+				return
+			}
+		}
+		e.mysqlContext.Stage = models.StageSendingBinlogEventToSlave
+		atomic.AddInt64(&e.mysqlContext.DeltaEstimate, 1)
+	}
+}
+
+// sendPartition groups the entries of a partition by GroupMaxSize and GroupTimeout.
+func (e *Extractor) sendPartition(p *streamPartition) {
+	entries := binlog.BinlogEntries{}
+	entriesSize := 0
+	send := func() error {
+		if len(entries.Entries) == 0 {
+			return nil
+		}
+		err := e.sendEntries(p.subject, entries)
+		entries.Entries = nil
+		entriesSize = 0
+		return err
+	}
+
+	groupTimeoutDuration := time.Duration(e.mysqlContext.GroupTimeout) * time.Millisecond
+	timer := time.NewTimer(groupTimeoutDuration)
+	defer timer.Stop()
+
+	for !e.shutdown {
+		var err error
+		select {
+		case binlogEntry := <-p.entries:
+			entries.Entries = append(entries.Entries, binlogEntry)
+			entriesSize += binlogEntry.OriginalSize
+			if entriesSize >= e.mysqlContext.GroupMaxSize {
+				err = send()
+			}
+		case done := <-p.flush:
+			// The entries before the request might be still in the channel.
+			for len(p.entries) > 0 {
+				entries.Entries = append(entries.Entries, <-p.entries)
+			}
+			err = send()
+			done <- err
+		case <-timer.C:
+			err = send()
+			timer.Reset(groupTimeoutDuration)
+		case <-e.shutdownCh:
+			return
+		}
+		if err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+	}
+}
+
+func (e *Extractor) sendEntries(subject string, entries binlog.BinlogEntries) error {
+	txMsg, err := Encode(entries)
+	if err != nil {
+		return err
+	}
+	gno := entries.Entries[0].Coordinates.GNO
+	e.logger.Debugf("mysql.extractor: sending gno: %v, n: %v to %v", gno, len(entries.Entries), subject)
+	if err = e.publish(subject, "", txMsg); err != nil {
+		return err
+	}
+	e.logger.Debugf("mysql.extractor: send acked gno: %v, n: %v to %v", gno, len(entries.Entries), subject)
+	return nil
+}
+
+// applyPartitionedEntry dispatches an entry of a partitioned stream. The entries of
+// a partition are applied in order by the same worker. The logical clock of the
+// source does not apply to the partitions arriving interleaved, so the entries are
+// numbered again here; an entry of binlog.PartitionAll is applied after all the
+// previous ones. It returns false on shutdown or error.
+func (a *Applier) applyPartitionedEntry(binlogEntry *binlog.BinlogEntry) bool {
+	if binlogEntry.Partition == binlog.PartitionAll {
+		if !a.mtsManager.WaitForAllCommitted() {
+			return false // shutdown
+		}
+	}
+	a.mtsManager.lastEnqueue += 1
+	binlogEntry.Coordinates.SeqenceNumber = a.mtsManager.lastEnqueue
+	binlogEntry.Coordinates.LastCommitted = a.mtsManager.lastEnqueue - 1
+
+	if err := a.setTableItemForBinlogEntry(binlogEntry); err != nil {
+		a.onError(TaskStateDead, err)
+		return false
+	}
+	if binlogEntry.Partition == binlog.PartitionAll {
+		if err := a.ApplyBinlogEvent(0, binlogEntry); err != nil {
+			a.onError(TaskStateDead, err)
+			return false
+		}
+		return true
+	}
+
+	select {
+	case a.partitionQueues[(binlogEntry.Partition-1)%len(a.partitionQueues)] <- binlogEntry:
+		return true
+	case <-a.shutdownCh:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+)
+
+func TestEntryPartition(t *testing.T) {
+	const n = 4
+	dml := func(schema, table string) binlog.DataEvent {
+		return binlog.DataEvent{DML: binlog.InsertDML, DatabaseName: schema, TableName: table}
+	}
+	pa := tablePartition("db1", "a", n)
+	if pa < 1 || pa > n {
+		t.Fatalf("partition %v out of 1..%v", pa, n)
+	}
+	// Another table in another partition.
+	var other string
+	for _, table := range []string{"b", "c", "d", "e", "f", "g", "h"} {
+		if tablePartition("db1", table, n) != pa {
+			other = table
+			break
+		}
+	}
+
+	tests := []struct {
+		name   string
+		events []binlog.DataEvent
+		want   int
+	}{
+		{"one table", []binlog.DataEvent{dml("db1", "a"), dml("db1", "a")}, pa},
+		{"two partitions", []binlog.DataEvent{dml("db1", "a"), dml("db1", other)}, binlog.PartitionAll},
+		{"ddl", []binlog.DataEvent{{DML: binlog.NotDML, DatabaseName: "db1", TableName: "a"}}, binlog.PartitionAll},
+		{"empty", nil, binlog.PartitionAll},
+	}
+	for _, tt := range tests {
+		if got := entryPartition(&binlog.BinlogEntry{Events: tt.events}, n, nil); got != tt.want {
+			t.Errorf("%v: entryPartition() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTableLinks(t *testing.T) {
+	const n = 4
+	// db1.b references db1.a, db1.c references db1.b, db2.x references db2.y.
+	links := newTableLinks([][2]string{
+		{tableKey("db1", "b"), tableKey("db1", "a")},
+		{tableKey("db1", "c"), tableKey("db1", "b")},
+		{tableKey("db2", "x"), tableKey("db2", "y")},
+	})
+	if links[tableKey("db1", "c")] != tableKey("db1", "a") || links[tableKey("db2", "x")] != tableKey("db2", "x") {
+		t.Fatalf("unexpected links %q", links)
+	}
+
+	pa := links.partition("db1", "a", n)
+	for _, table := range []string{"b", "c"} {
+		if p := links.partition("db1", table, n); p != pa {
+			t.Errorf("partition of db1.%v = %v, want %v of its parent", table, p, pa)
+		}
+	}
+	if p := links.partition("db1", "d", n); p != tablePartition("db1", "d", n) {
+		t.Errorf("partition of a table without foreign key = %v", p)
+	}
+
+	entry := &binlog.BinlogEntry{Events: []binlog.DataEvent{
+		{DML: binlog.InsertDML, DatabaseName: "db1", TableName: "a"},
+		{DML: binlog.InsertDML, DatabaseName: "db1", TableName: "c"},
+	}}
+	if p := entryPartition(entry, n, links); p != pa {
+		t.Errorf("entryPartition() of linked tables = %v, want %v", p, pa)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// replicaLagCheckInterval is how often the lag of the source is queried while
+// the full copy runs, and while it is paused.
+const replicaLagCheckInterval = time.Second
+
+// replicaLagThrottler pauses the full copy while the source, itself a replica
+// of another primary, is more than maxLag seconds behind it, so that the reads
+// of the copy don't slow down the replication of the source.
+type replicaLagThrottler struct {
+	logger   *log.Entry
+	maxLag   int64
+	interval time.Duration
+	// queryLag returns the Seconds_Behind_Master of the source, -1 if its
+	// replication is stopped, and false if the source is not a replica.
+	queryLag func() (int64, bool, error)
+
+	mu            sync.Mutex
+	checkTime     time.Time
+	lag           int64
+	replica       bool
+	throttled     bool
+	throttledTime time.Duration
+}
+
+func newReplicaLagThrottler(maxLag int64, queryLag func() (int64, bool, error), logger *log.Entry) *replicaLagThrottler {
+	return &replicaLagThrottler{
+		logger:   logger,
+		maxLag:   maxLag,
+		interval: replicaLagCheckInterval,
+		queryLag: queryLag,
+	}
+}
+
+// showReplicaLag returns the max Seconds_Behind_Master of the channels of the
+// replica, -1 if the SQL thread of one of them is stopped, and false if the
+// server is not a replica.
+func showReplicaLag(db *gosql.DB) (int64, bool, error) {
+	var lag int64
+	replica := false
+	err := sql.QueryRowsMap(db, `show slave status`, func(m sql.RowMap) error {
+		replica = true
+		seconds := m.GetNullInt64("Seconds_Behind_Master")
+		if !seconds.Valid {
+			lag = -1
+		} else if lag >= 0 && seconds.Int64 > lag {
+			lag = seconds.Int64
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return lag, replica, nil
+}
+
+// lagging returns whether the source is too far behind, querying its lag if it
+// was not queried in the interval. A source whose replication is stopped is
+// taken as too far behind, as its lag is unknown.
+func (t *replicaLagThrottler) lagging() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.checkTime) < t.interval {
+		return t.throttled
+	}
+	t.checkTime = time.Now()
+
+	lag, replica, err := t.queryLag()
+	if err != nil {
+		// Not knowing the lag does not stop the copy.
+		t.logger.Warnf("mysql.extractor: error querying the replica lag of the source: %v", err)
+		t.throttled = false
+		return false
+	}
+	t.lag, t.replica = lag, replica
+	throttled := replica && (lag < 0 || lag > t.maxLag)
+	if throttled && !t.throttled {
+		if lag < 0 {
+			t.logger.Warnf("mysql.extractor: the replication of the source is stopped. Pausing the full copy")
+		} else {
+			t.logger.Infof("mysql.extractor: the source is %vs behind its primary, over %vs. Pausing the full copy",
+				lag, t.maxLag)
+		}
+	} else if !throttled && t.throttled {
+		if replica {
+			t.logger.Infof("mysql.extractor: the source is %vs behind its primary. Resuming the full copy", lag)
+		} else {
+			t.logger.Infof("mysql.extractor: the source is no longer a replica. Resuming the full copy")
+		}
+	}
+	t.throttled = throttled
+	return throttled
+}
+
+// Wait waits while the source is too far behind its primary, or until
+// shutdownCh is closed.
+func (t *replicaLagThrottler) Wait(shutdownCh <-chan struct{}) {
+	for t.lagging() {
+		start := time.Now()
+		timer := time.NewTimer(t.interval)
+		select {
+		case <-timer.C:
+		case <-shutdownCh:
+			timer.Stop()
+			t.addThrottledTime(time.Since(start))
+			return
+		}
+		t.addThrottledTime(time.Since(start))
+	}
+}
+
+func (t *replicaLagThrottler) addThrottledTime(d time.Duration) {
+	t.mu.Lock()
+	t.throttledTime += d
+	t.mu.Unlock()
+}
+
+// Stat returns the lag of the source last queried, nil if the source is not a
+// replica.
+func (t *replicaLagThrottler) Stat() *models.SourceReplicaLag {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.replica {
+		return nil
+	}
+	return &models.SourceReplicaLag{
+		Seconds:       t.lag,
+		MaxSeconds:    t.maxLag,
+		Throttled:     t.throttled,
+		ThrottledTime: t.throttledTime,
+	}
+}
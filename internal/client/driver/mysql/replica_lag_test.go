@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestReplicaLagThrottler(t *testing.T) {
+	lags := []int64{20, 5, -1, 3}
+	var queryErr error
+	replica := true
+	th := newReplicaLagThrottler(10, func() (int64, bool, error) {
+		if queryErr != nil {
+			return 0, false, queryErr
+		}
+		lag := lags[0]
+		if len(lags) > 1 {
+			lags = lags[1:]
+		}
+		return lag, replica, nil
+	}, log.NewEntry(log.New(os.Stderr, log.InfoLevel)))
+	th.interval = 10 * time.Millisecond
+
+	// Paused at 20s, resumed at 5s.
+	th.Wait(nil)
+	stat := th.Stat()
+	if stat == nil || stat.Seconds != 5 || stat.Throttled || stat.ThrottledTime < th.interval {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+
+	// Paused while the replication is stopped, resumed at 3s.
+	time.Sleep(th.interval)
+	th.Wait(nil)
+	if stat := th.Stat(); stat.Seconds != 3 || stat.ThrottledTime < 2*th.interval {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+
+	// Not paused if the lag is unknown.
+	queryErr = errors.New("access denied")
+	time.Sleep(th.interval)
+	start := time.Now()
+	th.Wait(nil)
+	if time.Since(start) >= th.interval {
+		t.Fatalf("paused without the lag")
+	}
+
+	// Not paused if the source is not a replica, and interrupted by a shutdown.
+	queryErr = nil
+	lags = []int64{20}
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	time.Sleep(th.interval)
+	th.Wait(shutdownCh)
+	if !th.Stat().Throttled {
+		t.Fatalf("not paused at 20s")
+	}
+	replica = false
+	time.Sleep(th.interval)
+	th.Wait(nil)
+	if stat := th.Stat(); stat != nil {
+		t.Fatalf("unexpected stat %+v of a non-replica", stat)
+	}
+}
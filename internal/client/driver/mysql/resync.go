@@ -0,0 +1,392 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// tableResync is a table copied again while the job replicates the others.
+//
+// The rows are read from a consistent snapshot of the source, at gtidSet. The
+// incremental stream goes on until the first transaction not in gtidSet, where
+// the table is created if missing and emptied on the target, and its rows are
+// sent in the stream, as entries without GTID. The transactions of the other
+// tables keep going between the rows, until one changes the table or has a DDL:
+// it waits for the rest of the rows.
+type tableResync struct {
+	table *config.Table
+	// A table added to the job, whose events before the snapshot are dropped,
+	// as it may not exist on the target yet.
+	added   bool
+	gtidSet *gomysql.MysqlGTIDSet
+	// The CREATE TABLE of the table in the snapshot.
+	createTable string
+	db          *gosql.DB
+	tx          *gosql.Tx
+	dumper      *dumper
+	// Whether the table is emptied on the target, and the rows are being sent.
+	started bool
+}
+
+// Resync copies the table again from the source, e.g. after it is damaged on the
+// target, while the other tables keep replicating. It returns once the snapshot
+// of the table is taken. A resync interrupted by a restart of the task has to be
+// requested again.
+func (e *Extractor) Resync(schema, table string) error {
+	if err := e.checkResync(); err != nil {
+		return err
+	}
+
+	e.resyncLock.Lock()
+	defer e.resyncLock.Unlock()
+	t := findTable(e.replicateDoDb, schema, table)
+	if t == nil {
+		return fmt.Errorf("table %v.%v is not replicated by the job", schema, table)
+	}
+	if e.resync != nil {
+		return fmt.Errorf("table %v.%v is being resynced", e.resync.table.TableSchema, e.resync.table.TableName)
+	}
+	if len(e.pendingResyncs) > 0 {
+		return fmt.Errorf("the tables added to the job are being copied")
+	}
+	r, err := e.snapshotTable(t)
+	if err != nil {
+		return err
+	}
+	e.logger.Printf("mysql.extractor: resyncing %v.%v from %v", schema, table, r.gtidSet)
+	e.resync = r
+	return nil
+}
+
+// checkResync returns why the tables could not be copied while replicating, if
+// they could not.
+func (e *Extractor) checkResync() error {
+	if !e.mysqlContext.ApproveHeterogeneous {
+		return fmt.Errorf("resync needs ApproveHeterogeneous")
+	}
+	if e.mysqlContext.IncrSubjectPartitions > 1 {
+		return fmt.Errorf("resync is not supported with IncrSubjectPartitions")
+	}
+	if e.binlogReader == nil {
+		return fmt.Errorf("the incremental replication has not started")
+	}
+	return nil
+}
+
+// startPendingResync starts copying the next table added to the job, if any.
+// resyncLock is held.
+func (e *Extractor) startPendingResync() error {
+	if e.resync != nil || len(e.pendingResyncs) == 0 {
+		return nil
+	}
+	t := e.pendingResyncs[0]
+	r, err := e.snapshotTable(t)
+	if err != nil {
+		return fmt.Errorf("copying the table %v.%v added: %v", t.TableSchema, t.TableName, err)
+	}
+	r.added = true
+	e.pendingResyncs = e.pendingResyncs[1:]
+	e.logger.Printf("mysql.extractor: copying the table %v.%v added from %v", t.TableSchema, t.TableName, r.gtidSet)
+	e.resync = r
+	return nil
+}
+
+// snapshotTable starts the dump of the table in a consistent snapshot, and the
+// gtid set of the snapshot.
+func (e *Extractor) snapshotTable(t *config.Table) (r *tableResync, err error) {
+	uri := fmt.Sprintf("%s&tx_isolation='REPEATABLE-READ'", e.mysqlContext.ConnectionConfig.GetSingletonDBUri())
+	r = &tableResync{}
+	if r.db, err = sql.CreateDB(uri); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			e.closeResync(r)
+		}
+	}()
+
+	// The same gtid set before and after the snapshot is the one of the snapshot.
+	for r.gtidSet == nil {
+		if r.tx != nil {
+			r.tx.Rollback()
+		}
+		before, err := base.GetSelfBinlogCoordinates(r.db)
+		if err != nil {
+			return nil, err
+		}
+		if r.tx, err = r.db.Begin(); err != nil {
+			return nil, err
+		}
+		if _, err := r.tx.Exec("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return nil, err
+		}
+		rows, err := r.tx.Query("show master status")
+		if err != nil {
+			return nil, err
+		}
+		after, err := base.ParseBinlogCoordinatesFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		if before.GtidSet != after.GtidSet {
+			continue
+		}
+		gtidSet, err := gomysql.ParseMysqlGTIDSet(after.GtidSet)
+		if err != nil {
+			return nil, err
+		}
+		r.gtidSet = gtidSet.(*gomysql.MysqlGTIDSet)
+	}
+	if err := r.tx.QueryRow(fmt.Sprintf("show create table %s.%s", sql.EscapeName(t.TableSchema),
+		sql.EscapeName(t.TableName))).Scan(new(string), &r.createTable); err != nil {
+		return nil, err
+	}
+
+	// The dumper changes the table as it goes.
+	table := *t
+	table.Iteration = 0
+	if t.UseUniqueKey != nil {
+		uniqueKey := *t.UseUniqueKey
+		uniqueKey.LastMaxVals = make([]string, len(t.UseUniqueKey.LastMaxVals))
+		table.UseUniqueKey = &uniqueKey
+	}
+	r.table = &table
+	r.dumper = NewDumper(r.tx, r.table, e.mysqlContext.ChunkSize, e.logger)
+	if err := r.dumper.Dump(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (e *Extractor) closeResync(r *tableResync) {
+	if r.dumper != nil {
+		r.dumper.Close()
+	}
+	if r.tx != nil {
+		r.tx.Rollback()
+	}
+	if err := sql.CloseDB(r.db); err != nil {
+		e.logger.Warnf("mysql.extractor: closing the connection of the resync: %v", err)
+	}
+}
+
+// resyncRows returns the chunks of the table being resynced, nil until the
+// rows are sent.
+func (e *Extractor) resyncRows() <-chan *DumpEntry {
+	e.resyncLock.Lock()
+	defer e.resyncLock.Unlock()
+	if e.resync == nil || !e.resync.started {
+		return nil
+	}
+	return e.resync.dumper.resultsChannel
+}
+
+// resyncBefore sends, with send, what the resync needs to before the entry of
+// the incremental stream: the tables copied if they changed, the statement
+// emptying the table at the first entry after the snapshot, and the rest of the
+// rows before an entry changing the table.
+func (e *Extractor) resyncBefore(entry *binlog.BinlogEntry, send func(*binlog.BinlogEntry) error) error {
+	if err := e.recordCopiedTables(send); err != nil {
+		return err
+	}
+	e.resyncLock.Lock()
+	e.startNewTableResync()
+	r := e.resync
+	pending := e.pendingResyncs
+	e.resyncLock.Unlock()
+	// In the snapshots to be taken.
+	for _, t := range pending {
+		dropTableEvents(entry, t.TableSchema, t.TableName)
+	}
+	if r == nil {
+		return nil
+	}
+
+	if !r.started {
+		if gtidSetContains(r.gtidSet, entry.Coordinates.SID, entry.Coordinates.GNO) {
+			if r.added {
+				dropTableEvents(entry, r.table.TableSchema, r.table.TableName)
+			}
+			return nil
+		}
+		if err := e.startResync(r, send); err != nil {
+			return err
+		}
+	}
+	if !entryChangesTable(entry, r.table.TableSchema, r.table.TableName) {
+		return nil
+	}
+	e.logger.Debugf("mysql.extractor: gno %v waits for the resync of %v.%v",
+		entry.Coordinates.GNO, r.table.TableSchema, r.table.TableName)
+	for {
+		select {
+		case dumpEntry, ok := <-r.dumper.resultsChannel:
+			if err := e.sendResyncRows(dumpEntry, ok, send); err != nil || !ok {
+				return err
+			}
+		case <-e.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// resyncCaughtUp starts sending the rows of the resync if the incremental stream
+// is idle after the snapshot, and the tables copied if they changed.
+func (e *Extractor) resyncCaughtUp(send func(*binlog.BinlogEntry) error) error {
+	if err := e.recordCopiedTables(send); err != nil {
+		return err
+	}
+	e.resyncLock.Lock()
+	r := e.resync
+	e.resyncLock.Unlock()
+	if r == nil || r.started {
+		return nil
+	}
+
+	// A transaction is read once its entry is in dataChannel.
+	readGtidSet, _ := e.binlogReader.GetReadProgress()
+	read, err := gomysql.ParseMysqlGTIDSet(readGtidSet)
+	if err != nil || !read.Contain(r.gtidSet) || len(e.dataChannel) != 0 {
+		return nil
+	}
+	return e.startResync(r, send)
+}
+
+// startResync creates the table on the target if missing, and empties it,
+// before its rows.
+func (e *Extractor) startResync(r *tableResync, send func(*binlog.BinlogEntry) error) error {
+	e.logger.Printf("mysql.extractor: sending the rows of %v.%v", r.table.TableSchema, r.table.TableName)
+	schemaTable := binlog.SchemaTable{Schema: r.table.TableSchema, Table: r.table.TableName}
+	entry := &binlog.BinlogEntry{Resync: true}
+	if !e.mysqlContext.SkipCreateDbTable {
+		entry.Events = append(entry.Events,
+			binlog.NewQueryEvent("", fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s",
+				sql.EscapeName(r.table.TableSchema)), binlog.NotDML),
+			binlog.NewQueryEventAffectTable(r.table.TableSchema,
+				strings.Replace(r.createTable, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1),
+				binlog.NotDML, schemaTable))
+	}
+	entry.Events = append(entry.Events, binlog.NewQueryEventAffectTable(
+		r.table.TableSchema,
+		fmt.Sprintf("truncate table %s", sql.EscapeName(r.table.TableName)),
+		binlog.NotDML,
+		schemaTable,
+	))
+	e.resyncLock.Lock()
+	r.started = true
+	e.resyncLock.Unlock()
+	return send(entry)
+}
+
+// sendResyncRows sends a chunk of the rows of the resync, or ends it if !ok.
+func (e *Extractor) sendResyncRows(dumpEntry *DumpEntry, ok bool, send func(*binlog.BinlogEntry) error) error {
+	e.resyncLock.Lock()
+	r := e.resync
+	e.resyncLock.Unlock()
+
+	if !ok || dumpEntry.err != nil {
+		e.resyncLock.Lock()
+		defer e.resyncLock.Unlock()
+		e.resync = nil
+		e.closeResync(r)
+		if ok {
+			return fmt.Errorf("resync of %v.%v: %v", r.table.TableSchema, r.table.TableName, dumpEntry.err)
+		}
+		e.logger.Printf("mysql.extractor: resynced %v.%v", r.table.TableSchema, r.table.TableName)
+		if r.added {
+			e.copiedTablesChange()
+		}
+		return e.startPendingResync()
+	}
+	return send(resyncEntry(dumpEntry))
+}
+
+// resyncEntry returns the rows of a chunk as an entry of the incremental stream.
+func resyncEntry(dumpEntry *DumpEntry) *binlog.BinlogEntry {
+	entry := &binlog.BinlogEntry{
+		Resync:       true,
+		OriginalSize: int(dumpEntry.nBytes),
+	}
+	for _, row := range dumpEntry.ValuesX {
+		values := make([]interface{}, len(row))
+		hexColumns := dumpEntry.HexColumns
+		for i, v := range row {
+			isBinary := len(hexColumns) > 0 && hexColumns[0] == i
+			if isBinary {
+				hexColumns = hexColumns[1:]
+			}
+			// As the values of the binlog: strings, but the binary geometries.
+			if bs, ok := (*v).([]byte); ok && !isBinary {
+				values[i] = string(bs)
+			} else {
+				values[i] = *v
+			}
+		}
+		event := binlog.NewDataEvent(dumpEntry.TableSchema, dumpEntry.TableName, binlog.InsertDML, len(row))
+		event.NewColumnValues = umconf.ToColumnValues(values)
+		entry.Events = append(entry.Events, event)
+	}
+	return entry
+}
+
+// entryChangesTable returns whether the entry changes the table, or has a DDL.
+func entryChangesTable(entry *binlog.BinlogEntry, schema, table string) bool {
+	for i := range entry.Events {
+		event := &entry.Events[i]
+		if event.DML == binlog.NotDML {
+			return true
+		}
+		if event.DatabaseName == schema && event.TableName == table {
+			return true
+		}
+	}
+	return false
+}
+
+// dropTableEvents removes the events of the table from the entry.
+func dropTableEvents(entry *binlog.BinlogEntry, schema, table string) {
+	events := entry.Events[:0]
+	for _, event := range entry.Events {
+		if event.DatabaseName == schema && event.TableName == table {
+			continue
+		}
+		events = append(events, event)
+	}
+	entry.Events = events
+}
+
+// findTable returns the table of dbs, nil if none.
+func findTable(dbs []*config.DataSource, schema, table string) *config.Table {
+	for _, db := range dbs {
+		if db.TableSchema != schema {
+			continue
+		}
+		for _, tb := range db.Tables {
+			if tb.TableName == table {
+				return tb
+			}
+		}
+	}
+	return nil
+}
+
+func gtidSetContains(set *gomysql.MysqlGTIDSet, sid uuid.UUID, gno int64) bool {
+	uuidSet, ok := set.Sets[sid.String()]
+	return ok && uuidSet.Contain(gomysql.NewUUIDSet(sid, gomysql.Interval{Start: gno, Stop: gno + 1}))
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// With RowImageCheck, the row of the target an UPDATE or a DELETE applies to
+// is selected for update in the transaction of the event, by its primary key,
+// and compared with the before-image of the source. A row which differs, or
+// is missing, was written on the target by something else than the job: a
+// write conflict.
+
+// rowImageValue is the expression of a value of the column passed as a
+// parameter, converted the way it is stored in the column.
+func rowImageValue(column *umconf.Column) string {
+	switch {
+	case column.TimezoneConversion != nil:
+		return fmt.Sprintf("convert_tz(?, '%s', '%s')", column.TimezoneConversion.ToTimezone, "+00:00")
+	case column.Type == umconf.JSONColumnType:
+		return "cast(? as json)"
+	case strings.HasPrefix(column.ColumnType, "binary"):
+		// Padded like the column.
+		return fmt.Sprintf("cast(? as %s)", column.ColumnType)
+	default:
+		return "?"
+	}
+}
+
+// checksummedColumn returns whether the text of the column is the same on the
+// target as the text of its value in a binlog event. The values of FLOAT and
+// DOUBLE are rounded in the text of the column, and those of ENUM, SET and BIT
+// are numbers in the binlog: they are compared by value.
+func checksummedColumn(column *umconf.Column) bool {
+	switch column.Type {
+	case umconf.FloatColumnType, umconf.DoubleColumnType, umconf.EnumColumnType,
+		umconf.SetColumnType, umconf.BitColumnType:
+		return false
+	default:
+		return true
+	}
+}
+
+// buildRowImageQuery builds the query selecting for update the row with the
+// before-image args, by its primary key, or all the columns without one, in
+// which case only a missing row is a conflict. With checksum, it selects
+// whether the row is equal to the before-image, else whether each column is.
+func buildRowImageQuery(databaseName, tableName string, tableColumns *umconf.ColumnList, args []*interface{},
+	checksum bool, ansiQuotes bool) (query string, queryArgs []interface{}, err error) {
+
+	if len(args) < tableColumns.Len() {
+		return "", nil, fmt.Errorf("args count differs from table column count in buildRowImageQuery %v, %v",
+			len(args), tableColumns.Len())
+	}
+	var selected, targetTexts, imageTexts, keys []string
+	var selectedArgs, imageTextArgs, keyArgs, uniqueKeyArgs []interface{}
+	var uniqueKeys []string
+	for _, column := range tableColumns.ColumnList() {
+		column := column
+		arg := *args[tableColumns.Ordinals[column.Name]]
+		if arg != nil {
+			arg = column.ConvertArg(arg)
+		}
+		name := sql.QuoteName(column.Name, ansiQuotes)
+		comparison := fmt.Sprintf("(%s <=> %s)", name, rowImageValue(&column))
+
+		if checksum && checksummedColumn(&column) {
+			targetTexts = append(targetTexts, fmt.Sprintf("isnull(%s), %s", name, name))
+			imageTexts = append(imageTexts, fmt.Sprintf("isnull(%s), %s", rowImageValue(&column), rowImageValue(&column)))
+			imageTextArgs = append(imageTextArgs, arg, arg)
+		} else {
+			selected = append(selected, comparison)
+			selectedArgs = append(selectedArgs, arg)
+		}
+
+		if column.IsPk() {
+			uniqueKeys = append(uniqueKeys, comparison)
+			uniqueKeyArgs = append(uniqueKeyArgs, arg)
+		} else {
+			keys = append(keys, comparison)
+			keyArgs = append(keyArgs, arg)
+		}
+	}
+	if len(uniqueKeys) > 0 {
+		keys, keyArgs = uniqueKeys, uniqueKeyArgs
+	}
+
+	if checksum {
+		if len(targetTexts) > 0 {
+			selected = append([]string{fmt.Sprintf("(crc32(concat_ws(0x1f, %s)) = crc32(concat_ws(0x1f, %s)))",
+				strings.Join(targetTexts, ", "), strings.Join(imageTexts, ", "))}, selected...)
+			selectedArgs = append(imageTextArgs, selectedArgs...)
+		}
+		selected = []string{fmt.Sprintf("(%s)", strings.Join(selected, " and "))}
+	}
+	query = fmt.Sprintf(`
+			select %s
+				from
+					%s.%s
+				where
+					(%s)
+				limit 1
+				for update
+		`, strings.Join(selected, ", "),
+		sql.QuoteName(databaseName, ansiQuotes), sql.QuoteName(tableName, ansiQuotes),
+		strings.Join(keys, " and "),
+	)
+	queryArgs = append(selectedArgs, keyArgs...)
+	return query, queryArgs, nil
+}
+
+// checkRowImage compares the row of the target the UPDATE or DELETE event
+// applies to with its before-image, in tx. A write conflict is recorded, and
+// returned as an error with RowImageConflictError.
+func (a *Applier) checkRowImage(tx *gosql.Tx, gtid string, event binlog.DataEvent) error {
+	tableItem := event.TableItem.(*applierTableItem)
+	tableColumns, err := event.MapColumns(tableItem.columns, a.mysqlContext.ColumnMismatch)
+	if err != nil {
+		return err
+	}
+	checksum := a.mysqlContext.RowImageCheck == config.RowImageCheckChecksum
+	query, args, err := buildRowImageQuery(event.DatabaseName, event.TableName, tableColumns,
+		event.WhereColumnValues.GetAbstractValues(), checksum, a.mysqlContext.AnsiQuotes)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	conflict := &models.WriteConflict{
+		Time:  time.Now(),
+		Gtid:  gtid,
+		Table: fmt.Sprintf("%s.%s", sql.EscapeName(event.DatabaseName), sql.EscapeName(event.TableName)),
+		DML:   string(event.DML),
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		conflict.Missing = true
+		return a.writeConflict(conflict)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	equal := make([]gosql.NullBool, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range equal {
+		dest[i] = &equal[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	if checksum {
+		if !equal[0].Bool {
+			return a.writeConflict(conflict)
+		}
+		return nil
+	}
+	for i, column := range tableColumns.ColumnList() {
+		if !equal[i].Bool {
+			conflict.Columns = append(conflict.Columns, column.Name)
+		}
+	}
+	if len(conflict.Columns) > 0 {
+		return a.writeConflict(conflict)
+	}
+	return nil
+}
+
+// writeConflict records the write conflict, and returns it as an error with
+// RowImageConflictError.
+func (a *Applier) writeConflict(conflict *models.WriteConflict) error {
+	a.logger.Warnf("mysql.applier: write conflict: %v", conflict)
+	a.writeConflictsMutex.Lock()
+	a.writeConflicts.Count++
+	a.writeConflicts.Last = conflict
+	a.writeConflictsMutex.Unlock()
+	if a.mysqlContext.RowImageConflict == config.RowImageConflictError {
+		return fmt.Errorf("write conflict: %v. RowImageConflict is %v", conflict, a.mysqlContext.RowImageConflict)
+	}
+	return nil
+}
+
+// writeConflictsStat returns the write conflicts found, nil if RowImageCheck is
+// off.
+func (a *Applier) writeConflictsStat() *models.WriteConflicts {
+	if a.mysqlContext.RowImageCheck == config.RowImageCheckOff {
+		return nil
+	}
+	a.writeConflictsMutex.Lock()
+	defer a.writeConflictsMutex.Unlock()
+	stat := a.writeConflicts
+	return &stat
+}
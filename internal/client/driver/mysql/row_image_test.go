@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func TestBuildRowImageQuery(t *testing.T) {
+	spaces := regexp.MustCompile(`\s+`)
+	normalize := func(query string) string {
+		return strings.TrimSpace(spaces.ReplaceAllString(query, " "))
+	}
+	value := func(v interface{}) *interface{} {
+		return &v
+	}
+	columns := umconf.NewColumnList([]umconf.Column{
+		{Name: "id", Key: "PRI", Type: umconf.IntColumnType, ColumnType: "int(11)"},
+		{Name: "name", Type: umconf.VarcharColumnType, ColumnType: "varchar(20)"},
+		{Name: "score", Type: umconf.DoubleColumnType, ColumnType: "double"},
+		{Name: "doc", Type: umconf.JSONColumnType, ColumnType: "json"},
+	})
+	args := []*interface{}{value(int64(1)), value("a"), value(1.5), value(nil)}
+
+	query, queryArgs, err := buildRowImageQuery("db", "t", columns, args, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "select (`id` <=> ?), (`name` <=> ?), (`score` <=> ?), (`doc` <=> cast(? as json)) " +
+		"from `db`.`t` where ((`id` <=> ?)) limit 1 for update"
+	if got := normalize(query); got != want {
+		t.Errorf("columns: got %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{int64(1), "a", 1.5, nil, int64(1)}
+	if !reflect.DeepEqual(queryArgs, wantArgs) {
+		t.Errorf("columns: got args %v, want %v", queryArgs, wantArgs)
+	}
+
+	// The DOUBLE is compared by value, out of the checksum.
+	query, queryArgs, err = buildRowImageQuery("db", "t", columns, args, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "select ((crc32(concat_ws(0x1f, isnull(`id`), `id`, isnull(`name`), `name`, isnull(`doc`), `doc`)) = " +
+		"crc32(concat_ws(0x1f, isnull(?), ?, isnull(?), ?, isnull(cast(? as json)), cast(? as json)))) and (`score` <=> ?)) " +
+		"from `db`.`t` where ((`id` <=> ?)) limit 1 for update"
+	if got := normalize(query); got != want {
+		t.Errorf("checksum: got %v, want %v", got, want)
+	}
+	wantArgs = []interface{}{int64(1), int64(1), "a", "a", nil, nil, 1.5, int64(1)}
+	if !reflect.DeepEqual(queryArgs, wantArgs) {
+		t.Errorf("checksum: got args %v, want %v", queryArgs, wantArgs)
+	}
+
+	// Without a primary key, the row is selected by all the columns.
+	noKey := umconf.NewColumnList([]umconf.Column{
+		{Name: "a", Type: umconf.IntColumnType, ColumnType: "int(11)"},
+		{Name: "b", Type: umconf.IntColumnType, ColumnType: "int(11)"},
+	})
+	query, _, err = buildRowImageQuery("db", "t", noKey, args[:2], false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `select ("a" <=> ?), ("b" <=> ?) from "db"."t" where (("a" <=> ?) and ("b" <=> ?)) limit 1 for update`
+	if got := normalize(query); got != want {
+		t.Errorf("no key: got %v, want %v", got, want)
+	}
+}
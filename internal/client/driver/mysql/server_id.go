@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"math/rand"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/consul"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+const (
+	// serverIDKeyPrefix is the dir in Consul of the allocated server_ids, each
+	// key holding the job it is allocated to.
+	serverIDKeyPrefix = "udup/server_id"
+	// The server_ids are allocated above the ids usually set by hand.
+	minAutoServerID = 1 << 31
+	maxAutoServerID = 1<<32 - 1
+
+	allocateServerIDAttempts = 100
+)
+
+func init() {
+	consul.Register()
+}
+
+// serverIDPool allocates the server_id the binlog reader of a job registers on
+// the source with. The ids are claimed in Consul, so that they are unique in
+// the cluster. Without Consul, an id is picked at random.
+type serverIDPool struct {
+	kv    store.Store
+	owner string
+	rand  *rand.Rand
+	// key of the allocated server_id
+	key string
+}
+
+func newServerIDPool(consulAddr, owner string) (*serverIDPool, error) {
+	p := &serverIDPool{
+		owner: owner,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if consulAddr != "" {
+		kv, err := libkv.NewStore(store.CONSUL, []string{consulAddr}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("consul store setup failed: %v", err)
+		}
+		p.kv = kv
+	}
+	return p, nil
+}
+
+// Allocate returns a server_id not in used. The server_id already allocated to
+// the job, e.g. before the agent crashed, is reused.
+func (p *serverIDPool) Allocate(used map[uint32]bool) (uint32, error) {
+	claimed := map[uint32]bool{}
+	if p.kv != nil {
+		pairs, err := p.kv.List(serverIDKeyPrefix)
+		if err != nil && err != store.ErrKeyNotFound {
+			return 0, fmt.Errorf("list server_ids: %v", err)
+		}
+		for _, pair := range pairs {
+			id, err := strconv.ParseUint(path.Base(pair.Key), 10, 32)
+			if err != nil {
+				continue
+			}
+			if string(pair.Value) == p.owner {
+				p.key = pair.Key
+				return uint32(id), nil
+			}
+			claimed[uint32(id)] = true
+		}
+	}
+
+	for i := 0; i < allocateServerIDAttempts; i++ {
+		id := uint32(minAutoServerID + p.rand.Int63n(maxAutoServerID-minAutoServerID+1))
+		if used[id] || claimed[id] {
+			continue
+		}
+		if p.kv == nil {
+			return id, nil
+		}
+		key := path.Join(serverIDKeyPrefix, strconv.FormatUint(uint64(id), 10))
+		_, _, err := p.kv.AtomicPut(key, []byte(p.owner), nil, nil)
+		if err == store.ErrKeyExists {
+			claimed[id] = true
+			continue
+		} else if err != nil {
+			return 0, fmt.Errorf("claim server_id %v: %v", id, err)
+		}
+		p.key = key
+		return id, nil
+	}
+	return 0, fmt.Errorf("no free server_id after %v attempts", allocateServerIDAttempts)
+}
+
+// Release gives the allocated server_id back to the pool.
+func (p *serverIDPool) Release() error {
+	if p.kv == nil {
+		return nil
+	}
+	defer p.kv.Close()
+	if p.key == "" {
+		return nil
+	}
+	err := p.kv.Delete(p.key)
+	p.key = ""
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+// allocateSharedServerID allocates the server_id of a shared binlog stream,
+// claimed for the owner, see binlog.ServerIDAllocator.
+func (e *Extractor) allocateSharedServerID(owner string) (uint32, func(), error) {
+	used, err := usedServerIDs(e.db)
+	if err != nil {
+		return 0, nil, err
+	}
+	pool, err := newServerIDPool(e.mysqlContext.ConsulAddr, owner)
+	if err != nil {
+		return 0, nil, err
+	}
+	serverID, err := pool.Allocate(used)
+	if err != nil {
+		pool.Release()
+		return 0, nil, err
+	}
+	logger := e.logger
+	return serverID, func() {
+		if err := pool.Release(); err != nil {
+			logger.Warnf("mysql.extractor: release the server_id %v of the shared binlog stream: %v", serverID, err)
+		}
+	}, nil
+}
+
+// usedServerIDs returns the server_ids of the source and of the replicas
+// registered on it.
+func usedServerIDs(db *gosql.DB) (map[uint32]bool, error) {
+	used := map[uint32]bool{}
+	var serverID uint32
+	if err := db.QueryRow(`select @@global.server_id`).Scan(&serverID); err != nil {
+		return nil, err
+	}
+	used[serverID] = true
+
+	err := sql.QueryRowsMap(db, `show slave hosts`, func(m sql.RowMap) error {
+		used[uint32(m.GetUint("Server_id"))] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return used, nil
+}
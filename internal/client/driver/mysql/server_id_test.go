@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/docker/libkv/store"
+)
+
+// memKV is the part of a libkv store used by serverIDPool.
+type memKV struct {
+	store.Store
+	pairs map[string][]byte
+}
+
+func (m *memKV) List(dir string) ([]*store.KVPair, error) {
+	var pairs []*store.KVPair
+	for k, v := range m.pairs {
+		if strings.HasPrefix(k, dir+"/") {
+			pairs = append(pairs, &store.KVPair{Key: k, Value: v})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+	return pairs, nil
+}
+
+func (m *memKV) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	if _, ok := m.pairs[key]; ok {
+		return false, nil, store.ErrKeyExists
+	}
+	m.pairs[key] = value
+	return true, &store.KVPair{Key: key, Value: value}, nil
+}
+
+func (m *memKV) Delete(key string) error {
+	delete(m.pairs, key)
+	return nil
+}
+
+func (m *memKV) Close() {}
+
+func TestServerIDPool(t *testing.T) {
+	kv := &memKV{pairs: map[string][]byte{}}
+	newPool := func(owner string) *serverIDPool {
+		return &serverIDPool{kv: kv, owner: owner, rand: rand.New(rand.NewSource(1))}
+	}
+
+	// The same seed picks the same ids: the one of job1 is skipped by job2.
+	job1, job2 := newPool("job1"), newPool("job2")
+	id1, err := job1.Allocate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := job2.Allocate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 == id2 || id1 < minAutoServerID || id2 < minAutoServerID {
+		t.Fatalf("unexpected server_ids %v %v", id1, id2)
+	}
+
+	// A restarted job gets its server_id back.
+	if id, err := newPool("job1").Allocate(nil); err != nil || id != id1 {
+		t.Fatalf("job1 reallocated %v, %v, want %v", id, err, id1)
+	}
+
+	// The ids used on the source are skipped.
+	job3 := newPool("job3")
+	id3, err := job3.Allocate(map[uint32]bool{id1: true, id2: true})
+	if err != nil || id3 == id1 || id3 == id2 {
+		t.Fatalf("unexpected server_id %v, %v", id3, err)
+	}
+
+	if err := job1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv.pairs) != 2 {
+		t.Fatalf("server_id of job1 not released: %v", kv.pairs)
+	}
+}
+
+func TestServerIDPool_WithoutConsul(t *testing.T) {
+	p, err := newServerIDPool("", "job1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.Allocate(map[uint32]bool{1: true})
+	if err != nil || id < minAutoServerID {
+		t.Fatalf("unexpected server_id %v, %v", id, err)
+	}
+	if err := p.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// The session variables of the connections applying the full copy, unless
+	// overridden by FullCopySessionVariables. unique_checks=0 is left to it.
+	defaultFullCopySessionVariables = map[string]string{
+		"foreign_key_checks": "0",
+	}
+	// The session variables of the connections applying the incremental changes,
+	// unless overridden by IncrSessionVariables.
+	defaultIncrSessionVariables = map[string]string{
+		"foreign_key_checks": "0",
+	}
+
+	sessionVariableNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ansiQuotesSessionQuery adds ANSI_QUOTES to the sql_mode of a session of the
+// full copy with AnsiQuotes, after the sql_mode of the source. Its statements
+// are generated, by dtle or by SHOW CREATE, so they are valid with it. The
+// sessions of the incremental changes, which apply the DDL of the source as it
+// is, are not changed: their sql_mode must have ANSI_QUOTES already.
+const ansiQuotesSessionQuery = "SET @@session.sql_mode = CONCAT_WS(',', NULLIF(@@session.sql_mode, ''), 'ANSI_QUOTES')"
+
+// buildSessionQuery builds a `SET` of the session variables, overriding the defaults
+// by vars. The values are SQL literals.
+func buildSessionQuery(defaults map[string]string, vars map[string]string) (string, error) {
+	merged := make(map[string]string)
+	for name, value := range defaults {
+		merged[name] = value
+	}
+	for name, value := range vars {
+		if !sessionVariableNameRegexp.MatchString(name) {
+			return "", fmt.Errorf("invalid session variable name %q", name)
+		}
+		if strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("empty value of session variable %v", name)
+		}
+		merged[strings.ToLower(name)] = value
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = fmt.Sprintf("@@session.%s = %s", name, merged[name])
+	}
+	return "SET " + strings.Join(assignments, ", "), nil
+}
+
+// initIncrSessions sets IncrSessionVariables on the connections of the workers.
+// With AnsiQuotes, their sql_mode must have ANSI_QUOTES, e.g. by the global
+// sql_mode of the target or by IncrSessionVariables.
+func (a *Applier) initIncrSessions() error {
+	for _, conn := range a.dbs {
+		if _, err := conn.Db.ExecContext(context.Background(), a.incrSessionQuery); err != nil {
+			return fmt.Errorf("exec [%s] error: %v", a.incrSessionQuery, err)
+		}
+		if a.mysqlContext.AnsiQuotes {
+			var sqlMode string
+			if err := conn.Db.QueryRowContext(context.Background(), "SELECT @@session.sql_mode").Scan(&sqlMode); err != nil {
+				return err
+			}
+			if !hasAnsiQuotes(sqlMode) {
+				return fmt.Errorf("AnsiQuotes requires ANSI_QUOTES in the sql_mode of the target, "+
+					"e.g. by IncrSessionVariables. sql_mode: %v", sqlMode)
+			}
+		}
+	}
+	return nil
+}
+
+// hasAnsiQuotes tells whether the sql_mode has ANSI_QUOTES.
+func hasAnsiQuotes(sqlMode string) bool {
+	for _, mode := range strings.Split(sqlMode, ",") {
+		if strings.EqualFold(strings.TrimSpace(mode), "ANSI_QUOTES") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestBuildSessionQuery(t *testing.T) {
+	query, err := buildSessionQuery(defaultFullCopySessionVariables, map[string]string{
+		"sql_log_bin":              "0",
+		"Unique_Checks":            "1",
+		"innodb_lock_wait_timeout": "10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SET @@session.foreign_key_checks = 0, @@session.innodb_lock_wait_timeout = 10," +
+		" @@session.sql_log_bin = 0, @@session.unique_checks = 1"
+	if query != want {
+		t.Fatalf("got %v\nwant %v", query, want)
+	}
+
+	for _, defaults := range []map[string]string{defaultFullCopySessionVariables, defaultIncrSessionVariables} {
+		if query, err := buildSessionQuery(defaults, nil); err != nil ||
+			query != "SET @@session.foreign_key_checks = 0" {
+			t.Fatalf("unexpected %v, %v", query, err)
+		}
+	}
+
+	for _, vars := range []map[string]string{{"a = 1; drop table t; set b": "1"}, {"sql_log_bin": ""}} {
+		if _, err := buildSessionQuery(nil, vars); err == nil {
+			t.Fatalf("expect an error for %v", vars)
+		}
+	}
+}
+
+func TestHasAnsiQuotes(t *testing.T) {
+	for sqlMode, want := range map[string]bool{
+		"":                    false,
+		"STRICT_TRANS_TABLES": false,
+		"REAL_AS_FLOAT,PIPES_AS_CONCAT,ANSI_QUOTES,IGNORE_SPACE,ONLY_FULL_GROUP_BY,ANSI": true,
+		"ansi_quotes": true,
+	} {
+		if got := hasAnsiQuotes(sqlMode); got != want {
+			t.Errorf("%q: got %v, want %v", sqlMode, got, want)
+		}
+	}
+}
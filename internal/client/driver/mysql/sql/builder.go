@@ -9,7 +9,6 @@ package sql
 import (
 	"bytes"
 	"fmt"
-	"strconv"
 	"strings"
 
 	umconf "github.com/actiontech/dtle/internal/config/mysql"
@@ -27,14 +26,20 @@ const (
 	NotEqualsComparisonSign                               = "!="
 )
 
-// EscapeName will escape a db/table/column/... name by wrapping with backticks.
-// It is not fool proof. I'm just trying to do the right thing here, not solving
-// SQL injection issues, which should be irrelevant for this tool.
+// EscapeName will escape a db/table/column/... name by wrapping with backticks,
+// doubling the backticks in it. The name is the actual one: quotes around it
+// are part of it, and are kept.
 func EscapeName(name string) string {
-	if unquoted, err := strconv.Unquote(name); err == nil {
-		name = unquoted
+	return QuoteName(name, false)
+}
+
+// QuoteName escapes a name like EscapeName, or by wrapping with double quotes if
+// ansiQuotes, for a target with the sql_mode ANSI_QUOTES.
+func QuoteName(name string, ansiQuotes bool) string {
+	if ansiQuotes {
+		return fmt.Sprintf(`"%s"`, strings.Replace(name, `"`, `""`, -1))
 	}
-	return fmt.Sprintf("`%s`", name)
+	return fmt.Sprintf("`%s`", strings.Replace(name, "`", "``", -1))
 }
 
 func EscapeColRawToString(col *interface{}) string {
@@ -96,18 +101,18 @@ func duplicateNames(names []string) []string {
 	return duplicate
 }
 
-func BuildValueComparison(column string, value string, comparisonSign ValueComparisonSign) (result string, err error) {
+func BuildValueComparison(column string, value string, comparisonSign ValueComparisonSign, ansiQuotes bool) (result string, err error) {
 	if column == "" {
 		return "", fmt.Errorf("Empty column in GetValueComparison")
 	}
 	if value == "" {
 		return "", fmt.Errorf("Empty value in GetValueComparison")
 	}
-	comparison := fmt.Sprintf("(%s %s %s)", EscapeName(column), string(comparisonSign), value)
+	comparison := fmt.Sprintf("(%s %s %s)", QuoteName(column, ansiQuotes), string(comparisonSign), value)
 	return comparison, err
 }
 
-func BuildSetPreparedClause(columns *umconf.ColumnList) (result string, err error) {
+func BuildSetPreparedClause(columns *umconf.ColumnList, ansiQuotes bool) (result string, err error) {
 	if columns.Len() == 0 {
 		return "", fmt.Errorf("Got 0 columns in BuildSetPreparedClause")
 	}
@@ -115,16 +120,16 @@ func BuildSetPreparedClause(columns *umconf.ColumnList) (result string, err erro
 	for _, column := range columns.ColumnList() {
 		var setToken string
 		if column.TimezoneConversion != nil {
-			setToken = fmt.Sprintf("%s=convert_tz(?, '%s', '%s')", EscapeName(column.Name), column.TimezoneConversion.ToTimezone, "+00:00")
+			setToken = fmt.Sprintf("%s=convert_tz(?, '%s', '%s')", QuoteName(column.Name, ansiQuotes), column.TimezoneConversion.ToTimezone, "+00:00")
 		} else {
-			setToken = fmt.Sprintf("%s=?", EscapeName(column.Name))
+			setToken = fmt.Sprintf("%s=?", QuoteName(column.Name, ansiQuotes))
 		}
 		setTokens = append(setTokens, setToken)
 	}
 	return strings.Join(setTokens, ", "), nil
 }
 
-func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.ColumnList, args []*interface{}) (result string, columnArgs []interface{}, err error) {
+func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.ColumnList, args []*interface{}, ansiQuotes bool) (result string, columnArgs []interface{}, err error) {
 	if len(args) < tableColumns.Len() {
 		return result, columnArgs, fmt.Errorf("args count differs from table column count in BuildDMLDeleteQuery %v, %v",
 			len(args), tableColumns.Len())
@@ -135,7 +140,7 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 	for _, column := range tableColumns.ColumnList() {
 		tableOrdinal := tableColumns.Ordinals[column.Name]
 		if *args[tableOrdinal] == nil {
-			comparison, err := BuildValueComparison(column.Name, "NULL", IsEqualsComparisonSign)
+			comparison, err := BuildValueComparison(column.Name, "NULL", IsEqualsComparisonSign, ansiQuotes)
 			if err != nil {
 				return result, columnArgs, err
 			}
@@ -143,7 +148,7 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 		} else {
 			if strings.HasPrefix(column.ColumnType, "binary") {
 				arg := column.ConvertArg(*args[tableOrdinal])
-				comparison, err := BuildValueComparison(column.Name, fmt.Sprintf("cast('%v' as %s)", arg, column.ColumnType), EqualsComparisonSign)
+				comparison, err := BuildValueComparison(column.Name, fmt.Sprintf("cast('%v' as %s)", arg, column.ColumnType), EqualsComparisonSign, ansiQuotes)
 				if err != nil {
 					return result, columnArgs, err
 				}
@@ -154,7 +159,7 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 				}
 			} else {
 				arg := column.ConvertArg(*args[tableOrdinal])
-				comparison, err := BuildValueComparison(column.Name, "?", EqualsComparisonSign)
+				comparison, err := BuildValueComparison(column.Name, "?", EqualsComparisonSign, ansiQuotes)
 				if err != nil {
 					return result, columnArgs, err
 				}
@@ -174,8 +179,8 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 	if len(uniqueKeyArgs) > 0 {
 		columnArgs = uniqueKeyArgs
 	}
-	databaseName = EscapeName(databaseName)
-	tableName = EscapeName(tableName)
+	databaseName = QuoteName(databaseName, ansiQuotes)
+	tableName = QuoteName(tableName, ansiQuotes)
 	if err != nil {
 		return result, columnArgs, err
 	}
@@ -191,7 +196,7 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 	return result, columnArgs, nil
 }
 
-func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *umconf.ColumnList, args []*interface{}) (result string, sharedArgs []interface{}, err error) {
+func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *umconf.ColumnList, args []*interface{}, ansiQuotes bool) (result string, sharedArgs []interface{}, err error) {
 	if len(args) < tableColumns.Len() {
 		return result, sharedArgs, fmt.Errorf("args count differs from table column count in BuildDMLInsertQuery %v, %v",
 			len(args), tableColumns.Len())
@@ -203,8 +208,8 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	if sharedColumns.Len() == 0 {
 		return result, sharedArgs, fmt.Errorf("No shared columns found in BuildDMLInsertQuery")
 	}
-	databaseName = EscapeName(databaseName)
-	tableName = EscapeName(tableName)
+	databaseName = QuoteName(databaseName, ansiQuotes)
+	tableName = QuoteName(tableName, ansiQuotes)
 
 	for _, column := range tableColumns.ColumnList() {
 		tableOrdinal := tableColumns.Ordinals[column.Name]
@@ -218,7 +223,7 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 
 	mappedSharedColumnNames := duplicateNames(tableColumns.Names())
 	for i := range mappedSharedColumnNames {
-		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
+		mappedSharedColumnNames[i] = QuoteName(mappedSharedColumnNames[i], ansiQuotes)
 	}
 	preparedValues := buildColumnsPreparedValues(tableColumns)
 
@@ -235,7 +240,7 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	return result, sharedArgs, nil
 }
 
-func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *umconf.ColumnList, valueArgs, whereArgs []*interface{}) (result string, sharedArgs, columnArgs []interface{}, err error) {
+func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *umconf.ColumnList, valueArgs, whereArgs []*interface{}, ansiQuotes bool) (result string, sharedArgs, columnArgs []interface{}, err error) {
 	if len(valueArgs) < tableColumns.Len() {
 		return result, sharedArgs, columnArgs, fmt.Errorf("value args count differs from table column count in BuildDMLUpdateQuery %v, %v",
 			len(valueArgs), tableColumns.Len())
@@ -250,8 +255,8 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	if sharedColumns.Len() == 0 {
 		return result, sharedArgs, columnArgs, fmt.Errorf("No shared columns found in BuildDMLUpdateQuery")
 	}
-	databaseName = EscapeName(databaseName)
-	tableName = EscapeName(tableName)
+	databaseName = QuoteName(databaseName, ansiQuotes)
+	tableName = QuoteName(tableName, ansiQuotes)
 
 	for _, column := range tableColumns.ColumnList() {
 		tableOrdinal := tableColumns.Ordinals[column.Name]
@@ -270,7 +275,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	for _, column := range tableColumns.ColumnList() {
 		tableOrdinal := tableColumns.Ordinals[column.Name]
 		if *whereArgs[tableOrdinal] == nil {
-			comparison, err := BuildValueComparison(column.Name, "NULL", IsEqualsComparisonSign)
+			comparison, err := BuildValueComparison(column.Name, "NULL", IsEqualsComparisonSign, ansiQuotes)
 			if err != nil {
 				return result, sharedArgs, columnArgs, err
 			}
@@ -278,7 +283,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 		} else {
 			if strings.HasPrefix(column.ColumnType, "binary") {
 				arg := column.ConvertArg(*whereArgs[tableOrdinal])
-				comparison, err := BuildValueComparison(column.Name, fmt.Sprintf("cast('%v' as %s)", arg, column.ColumnType), EqualsComparisonSign)
+				comparison, err := BuildValueComparison(column.Name, fmt.Sprintf("cast('%v' as %s)", arg, column.ColumnType), EqualsComparisonSign, ansiQuotes)
 				if err != nil {
 					return result, sharedArgs, columnArgs, err
 				}
@@ -289,7 +294,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 				}
 			} else {
 				arg := column.ConvertArg(*whereArgs[tableOrdinal])
-				comparison, err := BuildValueComparison(column.Name, "?", EqualsComparisonSign)
+				comparison, err := BuildValueComparison(column.Name, "?", EqualsComparisonSign, ansiQuotes)
 				if err != nil {
 					return result, sharedArgs, columnArgs, err
 				}
@@ -309,7 +314,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	if len(uniqueKeyArgs) > 0 {
 		columnArgs = uniqueKeyArgs
 	}
-	setClause, err := BuildSetPreparedClause(mappedSharedColumns)
+	setClause, err := BuildSetPreparedClause(mappedSharedColumns, ansiQuotes)
 
 	result = fmt.Sprintf(`
  			update
@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"strings"
 
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	test "github.com/outbrain/golib/tests"
 )
 
@@ -27,268 +28,114 @@ func normalizeQuery(name string) string {
 	return name
 }
 
-func TestEscapeName(t *testing.T) {
-	names := []string{"my_table", `"my_table"`, "`my_table`"}
-	for _, name := range names {
-		escaped := EscapeName(name)
-		test.S(t).ExpectEquals(escaped, "`my_table`")
-	}
+func newColumnList(names []string) *umconf.ColumnList {
+	return umconf.NewColumnList(umconf.NewColumns(names))
 }
 
-func TestBuildSetPreparedClause(t *testing.T) {
-	{
-		columns := NewColumnList([]string{"c1"})
-		clause, err := BuildSetPreparedClause(columns)
-		test.S(t).ExpectNil(err)
-		test.S(t).ExpectEquals(clause, "`c1`=?")
-	}
-	{
-		columns := NewColumnList([]string{"c1", "c2"})
-		clause, err := BuildSetPreparedClause(columns)
-		test.S(t).ExpectNil(err)
-		test.S(t).ExpectEquals(clause, "`c1`=?, `c2`=?")
-	}
-	{
-		columns := NewColumnList([]string{})
-		_, err := BuildSetPreparedClause(columns)
-		test.S(t).ExpectNotNil(err)
+// newArgs returns the row values in the form of the binlog events.
+func newArgs(values ...interface{}) []*interface{} {
+	args := make([]*interface{}, len(values))
+	for i := range values {
+		args[i] = &values[i]
 	}
+	return args
 }
 
-func TestBuildRangeInsertQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
-	{
-		uniqueKey := "PRIMARY"
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		rangeStartValues := []string{"@v1s"}
-		rangeEndValues := []string{"@v1e"}
-		rangeStartArgs := []interface{}{3}
-		rangeEndArgs := []interface{}{103}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (PRIMARY)
-					where (((id > @v1s) or ((id = @v1s))) and ((id < @v1e) or ((id = @v1e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
-	}
-	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartValues := []string{"@v1s", "@v2s"}
-		rangeEndValues := []string{"@v1e", "@v2e"}
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > @v1s) or (((name = @v1s)) AND (position > @v2s)) or ((name = @v1s) and (position = @v2s))) and ((name < @v1e) or (((name = @v1e)) AND (position < @v2e)) or ((name = @v1e) and (position = @v2e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
-	}
+func TestEscapeName(t *testing.T) {
+	test.S(t).ExpectEquals(EscapeName("my_table"), "`my_table`")
+	// quotes are part of the name
+	test.S(t).ExpectEquals(EscapeName(`"my_table"`), "`\"my_table\"`")
+	test.S(t).ExpectEquals(EscapeName("`my_table`"), "```my_table```")
 }
 
-func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
-	mappedSharedColumns := []string{"id", "name", "location"}
-	{
-		uniqueKey := "PRIMARY"
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		rangeStartValues := []string{"@v1s"}
-		rangeEndValues := []string{"@v1e"}
-		rangeStartArgs := []interface{}{3}
-		rangeEndArgs := []interface{}{103}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, location)
-				(select id, name, position from mydb.tbl force index (PRIMARY)
-					where (((id > @v1s) or ((id = @v1s))) and ((id < @v1e) or ((id = @v1e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
-	}
+func TestBuildSetPreparedClause(t *testing.T) {
 	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartValues := []string{"@v1s", "@v2s"}
-		rangeEndValues := []string{"@v1e", "@v2e"}
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
+		columns := newColumnList([]string{"c1"})
+		clause, err := BuildSetPreparedClause(columns, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, location)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > @v1s) or (((name = @v1s)) AND (position > @v2s)) or ((name = @v1s) and (position = @v2s))) and ((name < @v1e) or (((name = @v1e)) AND (position < @v2e)) or ((name = @v1e) and (position = @v2e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
+		test.S(t).ExpectEquals(clause, "`c1`=?")
 	}
-}
-
-func TestBuildRangeInsertPreparedQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
 	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, true, true)
+		columns := newColumnList([]string{"c1", "c2"})
+		clause, err := BuildSetPreparedClause(columns, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))))
-				lock in share mode )
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
+		test.S(t).ExpectEquals(clause, "`c1`=?, `c2`=?")
 	}
-}
-
-func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	var chunkSize int64 = 500
 	{
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQuery(databaseName, originalTableName, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, "test")
-		test.S(t).ExpectNil(err)
-		expected := `
-				select /* udup mydb.tbl test */ name, position
-				  from (
-				    select
-				        name, position
-				      from
-				        mydb.tbl
-				      where ((name > ?) or (((name = ?)) AND (position > ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?)))
-				      order by
-				        name asc, position asc
-				      limit 500
-				  ) select_osc_chunk
-				order by
-				  name desc, position desc
-				limit 1
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}))
+		columns := newColumnList([]string{})
+		_, err := BuildSetPreparedClause(columns, false)
+		test.S(t).ExpectNotNil(err)
 	}
 }
 
-func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
+func TestBuildDMLDeleteQuery(t *testing.T) {
 	databaseName := "mydb"
-	originalTableName := "tbl"
-	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-	{
-		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
-		test.S(t).ExpectNil(err)
-		expected := `
-			select /* udup mydb.tbl */ name, position
-			  from
-			    mydb.tbl
-			  order by
-			    name asc, position asc
-			  limit 1
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-	}
+	tableName := "tbl"
+	args := newArgs(3, "testname", "first", 17, 23)
 	{
-		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
+		// no primary key: all the columns
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			select /* udup mydb.tbl */ name, position
-			  from
-			    mydb.tbl
-			  order by
-			    name desc, position desc
-			  limit 1
+			delete
+				from
+					mydb.tbl
+				where
+					((id = ?) and (name = ?) and (rank = ?) and (position = ?) and (age = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{3, "testname", "first", 17, 23}))
 	}
-}
-
-func TestBuildDMLDeleteQuery(t *testing.T) {
-	databaseName := "mydb"
-	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	args := []interface{}{3, "testname", "first", 17, 23}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position"})
-
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		tableColumns.GetColumn("position").Key = "PRI"
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
 					((position = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{17}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		tableColumns.GetColumn("position").Key = "PRI"
+		tableColumns.GetColumn("name").Key = "PRI"
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
 					((name = ?) and (position = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{"testname", 17}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns,
+			newArgs(3, nil, "first", 17, 23), false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
-					((position = ?) and (name = ?))
+					((id = ?) and (name is NULL) and (rank = ?) and (position = ?) and (age = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{3, "first", 17, 23}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		args := []interface{}{"first", 17}
-
-		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, newArgs("first", 17), false)
 		test.S(t).ExpectNotNil(err)
 	}
 }
@@ -296,82 +143,68 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	uniqueKeyColumns := NewColumnList([]string{"position"})
-	{
-		// test signed (expect no change)
-		args := []interface{}{3, "testname", "first", -1, 23}
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
-		test.S(t).ExpectNil(err)
-		expected := `
-			delete /* udup mydb.tbl */
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	tableColumns.GetColumn("position").Key = "PRI"
+	args := newArgs(3, "testname", "first", int8(-1), 23)
+	expected := `
+			delete
 				from
 					mydb.tbl
 				where
 					((position = ?))
 		`
+	{
+		// test signed (expect no change)
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args, false)
+		test.S(t).ExpectNil(err)
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{-1}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{int8(-1)}))
 	}
 	{
 		// test unsigned
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		uniqueKeyColumns.SetUnsigned("position")
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		tableColumns.SetUnsigned("position")
+		query, columnArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			delete /* udup mydb.tbl */
-				from
-					mydb.tbl
-				where
-					((position = ?))
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(255)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{uint8(255)}))
 	}
 }
 
 func TestBuildDMLInsertQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	args := []interface{}{3, "testname", "first", 17, 23}
-	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
-		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
-				values
-					(?, ?, ?, ?)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	args := newArgs(3, "testname", "first", 17, 23)
+	{
+		// all the columns of the table, whatever the shared ones
+		for _, shared := range [][]string{{"id", "name", "position", "age"}, {"position", "name", "age", "id"}} {
+			sharedColumns := newColumnList(shared)
+			query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
+			test.S(t).ExpectNil(err)
+			expected := `
+				replace into
+					mydb.tbl
+						(id, name, rank, position, age)
+					values
+						(?, ?, ?, ?, ?)
+			`
+			test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+			test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", 17, 23}))
+		}
 	}
 	{
-		sharedColumns := NewColumnList([]string{"position", "name", "age", "id"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
-		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(position, name, age, id)
-				values
-					(?, ?, ?, ?)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{17, "testname", 23, 3}))
+		sharedColumns := newColumnList([]string{"position", "name", "surprise", "id"})
+		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
+		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{"position", "name", "surprise", "id"})
-		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		sharedColumns := newColumnList([]string{})
+		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
 		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{})
-		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		sharedColumns := newColumnList([]string{"id"})
+		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, newArgs(3, "testname"), false)
 		test.S(t).ExpectNotNil(err)
 	}
 }
@@ -379,197 +212,144 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	sharedColumns := newColumnList([]string{"id", "name", "position", "age"})
+	expected := `
+		replace into
+			mydb.tbl
+				(id, name, rank, position, age)
+			values
+				(?, ?, ?, ?, ?)
+	`
 	{
 		// testing signed
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		args := newArgs(3, "testname", "first", int8(-1), 23)
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
-				values
-					(?, ?, ?, ?)
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-1), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", int8(-1), 23}))
 	}
+	tableColumns.SetUnsigned("position")
 	{
 		// testing unsigned
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		sharedColumns.SetUnsigned("position")
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		args := newArgs(3, "testname", "first", int8(-1), 23)
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
-				values
-					(?, ?, ?, ?)
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", uint8(255), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", uint8(255), 23}))
 	}
 	{
 		// testing unsigned
-		args := []interface{}{3, "testname", "first", int32(-1), 23}
-		sharedColumns.SetUnsigned("position")
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		args := newArgs(3, "testname", "first", int32(-1), 23)
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
-				values
-					(?, ?, ?, ?)
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", uint32(4294967295), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", uint32(4294967295), 23}))
 	}
 }
 
 func TestBuildDMLUpdateQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	valueArgs := []interface{}{3, "testname", "newval", 17, 23}
-	whereArgs := []interface{}{3, "testname", "findme", 17, 56}
-	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"position"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
-		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, position=?, age=?
-				where
-					((position = ?))
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
-	}
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	sharedColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	valueArgs := newArgs(3, "testname", "newval", 17, 23)
+	whereArgs := newArgs(3, "testname", "findme", 17, 56)
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		// no primary key: all the columns
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
-					((position = ?) and (name = ?))
+					((id = ?) and (name = ?) and (rank = ?) and (position = ?) and (age = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{3, "testname", "findme", 17, 56}))
 	}
+	tableColumns.GetColumn("position").Key = "PRI"
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
-					((age = ?))
+					((position = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{56}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{17}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age", "position", "id", "name"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		mappedColumns := newColumnList([]string{"id", "name", "rank", "role", "age"})
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, mappedColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, role=?, age=?
 				where
-					((age = ?) and (position = ?) and (id = ?) and (name = ?))
+					((position = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{56, 17, 3, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{17}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age", "surprise"})
-		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		sharedColumns := newColumnList([]string{"id", "name", "surprise"})
+		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{})
-		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		sharedColumns := newColumnList([]string{})
+		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		mappedColumns := NewColumnList([]string{"id", "name", "role", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, mappedColumns, uniqueKeyColumns, valueArgs, whereArgs)
-		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, role=?, age=?
-				where
-					((id = ?))
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3}))
+		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, valueArgs, newArgs(3, "testname"), false)
+		test.S(t).ExpectNotNil(err)
 	}
 }
 
 func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	valueArgs := []interface{}{3, "testname", "newval", int8(-17), int8(-2)}
-	whereArgs := []interface{}{3, "testname", "findme", int8(-3), 56}
-	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-	uniqueKeyColumns := NewColumnList([]string{"position"})
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	tableColumns.GetColumn("position").Key = "PRI"
+	valueArgs := newArgs(3, "testname", "newval", int8(-17), int8(-2))
+	whereArgs := newArgs(3, "testname", "findme", int8(-3), 56)
+	expected := `
+		update
+		  mydb.tbl
+				set id=?, name=?, rank=?, position=?, age=?
+			where
+				((position = ?))
+			limit 1
+	`
 	{
 		// test signed
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, position=?, age=?
-				where
-					((position = ?))
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-17), int8(-2)}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{int8(-3)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", int8(-17), int8(-2)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{int8(-3)}))
 	}
 	{
 		// test unsigned
-		sharedColumns.SetUnsigned("age")
-		uniqueKeyColumns.SetUnsigned("position")
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns.SetUnsigned("age")
+		tableColumns.SetUnsigned("position")
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, nil, valueArgs, whereArgs, false)
 		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, position=?, age=?
-				where
-					((position = ?))
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-17), uint8(254)}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(253)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", uint8(239), uint8(254)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{uint8(253)}))
 	}
 }
@@ -894,6 +894,12 @@ const (
 	ErrErrorLast                                                    = 1863
 )
 
+// Errors of TiDB, after which the transaction could be retried.
+const (
+	ErrTiDBCannotRetry   = 8002
+	ErrTiDBWriteConflict = 9007
+)
+
 func IgnoreError(err error) bool {
 	mysqlErr, ok := err.(*mysql.MySQLError)
 	if !ok {
@@ -925,3 +931,25 @@ func IgnoreExistsError(err error) bool {
 		return false
 	}
 }
+
+// IsTableExistsError tells if a CREATE TABLE failed as the table exists.
+func IsTableExistsError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == ErrTableExists
+}
+
+// IsTiDBRetryableError tells if the transaction failed with the error could be
+// retried on TiDB, e.g. a write conflict of the optimistic transaction.
+func IsTiDBRetryableError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return false
+	}
+
+	switch mysqlErr.Number {
+	case ErrTiDBCannotRetry, ErrTiDBWriteConflict:
+		return true
+	default:
+		return false
+	}
+}
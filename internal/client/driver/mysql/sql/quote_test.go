@@ -0,0 +1,47 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sql
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestEscapeNameSpecialChars(t *testing.T) {
+	test.S(t).ExpectEquals(EscapeName("my`table"), "`my``table`")
+	test.S(t).ExpectEquals(EscapeName("表"), "`表`")
+}
+
+func TestQuoteName(t *testing.T) {
+	test.S(t).ExpectEquals(QuoteName("order", false), "`order`")
+	test.S(t).ExpectEquals(QuoteName("order", true), `"order"`)
+	test.S(t).ExpectEquals(QuoteName("`group`", true), "\"`group`\"")
+	test.S(t).ExpectEquals(QuoteName(`a"b`, true), `"a""b"`)
+	test.S(t).ExpectEquals(QuoteName("a`b", true), "\"a`b\"")
+}
+
+// Names wrapped in quotes are kept as they are, not unquoted.
+func TestQuoteNameQuoted(t *testing.T) {
+	for name, want := range map[string]string{
+		"`a`": "```a```",
+		`"x"`: "`\"x\"`",
+		`'y'`: "`'y'`",
+		"``":  "``````",
+	} {
+		test.S(t).ExpectEquals(EscapeName(name), want)
+		test.S(t).ExpectEquals(QuoteName(name, false), want)
+	}
+	for name, want := range map[string]string{
+		"`a`": "\"`a`\"",
+		`"x"`: `"""x"""`,
+		`'y'`: `"'y'"`,
+		`""`:  `""""""`,
+	} {
+		test.S(t).ExpectEquals(QuoteName(name, true), want)
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/actiontech/dtle/internal/config"
-	"github.com/actiontech/dtle/internal/g"
 	"strconv"
 	"strings"
 	"sync"
@@ -150,6 +149,8 @@ type Conn struct {
 
 	PsDeleteExecutedGtid *gosql.Stmt
 	PsInsertExecutedGtid *gosql.Stmt
+	PsSaveTxnProgress    *gosql.Stmt
+	PsDeleteTxnProgress  *gosql.Stmt
 }
 
 type DB struct {
@@ -352,7 +353,9 @@ func Args(args ...interface{}) []interface{} {
 //INSERT INTO {{ .Name }} VALUES {{ .Values }};
 //UNLOCK TABLES;
 
-func ShowDatabases(db *gosql.DB) ([]string, error) {
+// ShowDatabases returns the databases of the server, but the system schemas:
+// those of systemSchemas, or of config.DefaultSystemSchemas if it is empty.
+func ShowDatabases(db *gosql.DB, systemSchemas []string) ([]string, error) {
 	dbs := make([]string, 0)
 
 	// Get table list
@@ -368,12 +371,10 @@ func ShowDatabases(db *gosql.DB) ([]string, error) {
 		if err := rows.Scan(&database); err != nil {
 			return dbs, err
 		}
-		switch strings.ToLower(database.String) {
-		case "sys", "mysql", "information_schema", "performance_schema", g.DtleSchemaName:
+		if config.IsSystemSchema(database.String, systemSchemas) {
 			continue
-		default:
-			dbs = append(dbs, database.String)
 		}
+		dbs = append(dbs, database.String)
 	}
 	return dbs, rows.Err()
 }
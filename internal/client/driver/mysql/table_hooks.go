@@ -0,0 +1,185 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// The statements of FullCopyBeforeTableSQL and FullCopyAfterTableSQL are run
+// on the target around the load of the rows of each table in the full copy.
+// The Src sends the schema entries of all the tables first, then the rows of
+// the tables one after the other, in the same order: a table is loaded when
+// the rows of the next one arrive, or when the full copy completes. A table
+// without rows has its statements run when its turn is passed.
+
+// hookTable is a table of the full copy, and the data of the templates of the
+// statements.
+type hookTable struct {
+	Schema string
+	Table  string
+}
+
+// tableHookStep is the statements to run for a table, before or after its
+// rows are loaded.
+type tableHookStep struct {
+	table hookTable
+	after bool
+}
+
+// tableLoadHooks tracks the table being loaded in the full copy, to run the
+// statements around the load of each table.
+type tableLoadHooks struct {
+	before []*template.Template
+	after  []*template.Template
+	// The tables announced by their schema entries, not loaded yet.
+	pending []hookTable
+	// The table whose rows are being loaded, nil if none.
+	current *hookTable
+}
+
+// newTableLoadHooks parses the templates of the statements, nil if there are
+// none.
+func newTableLoadHooks(before, after []string, ansiQuotes bool) (*tableLoadHooks, error) {
+	if len(before) == 0 && len(after) == 0 {
+		return nil, nil
+	}
+	funcs := template.FuncMap{
+		"quote": func(name string) string {
+			return sql.QuoteName(name, ansiQuotes)
+		},
+	}
+	parse := func(option string, queries []string) ([]*template.Template, error) {
+		var templates []*template.Template
+		for i, query := range queries {
+			tmpl, err := template.New(fmt.Sprintf("%v[%d]", option, i)).Funcs(funcs).Parse(query)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", option, err)
+			}
+			templates = append(templates, tmpl)
+		}
+		return templates, nil
+	}
+	h := &tableLoadHooks{}
+	var err error
+	if h.before, err = parse("FullCopyBeforeTableSQL", before); err != nil {
+		return nil, err
+	}
+	if h.after, err = parse("FullCopyAfterTableSQL", after); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// onEntry returns the steps to run before the dump entry is applied: the
+// schema entry of a table announces it, and the rows of a table finish the
+// tables before it.
+func (h *tableLoadHooks) onEntry(entry *DumpEntry) []tableHookStep {
+	if entry.TableName == "" || entry.XbstreamData != nil {
+		return nil
+	}
+	table := hookTable{Schema: entry.TableSchema, Table: entry.TableName}
+	if len(entry.ValuesX) == 0 && entry.OutfilePath == "" {
+		h.pending = append(h.pending, table)
+		return nil
+	}
+	if h.current != nil && *h.current == table {
+		return nil
+	}
+
+	var steps []tableHookStep
+	if h.current != nil {
+		steps = append(steps, tableHookStep{table: *h.current, after: true})
+		h.current = nil
+	}
+	for i, pending := range h.pending {
+		if pending == table {
+			// The tables announced before it have no rows.
+			for _, empty := range h.pending[:i] {
+				steps = append(steps, tableHookStep{table: empty}, tableHookStep{table: empty, after: true})
+			}
+			h.pending = h.pending[i+1:]
+			break
+		}
+	}
+	h.current = &table
+	return append(steps, tableHookStep{table: table})
+}
+
+// onComplete returns the steps to run when the full copy completes: the last
+// table loaded, and those without rows not passed yet.
+func (h *tableLoadHooks) onComplete() []tableHookStep {
+	var steps []tableHookStep
+	if h.current != nil {
+		steps = append(steps, tableHookStep{table: *h.current, after: true})
+		h.current = nil
+	}
+	for _, empty := range h.pending {
+		steps = append(steps, tableHookStep{table: empty}, tableHookStep{table: empty, after: true})
+	}
+	h.pending = nil
+	return steps
+}
+
+// render returns the statements of the step.
+func (h *tableLoadHooks) render(step tableHookStep) ([]string, error) {
+	templates := h.before
+	if step.after {
+		templates = h.after
+	}
+	var queries []string
+	for _, tmpl := range templates {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, step.table); err != nil {
+			return nil, err
+		}
+		queries = append(queries, b.String())
+	}
+	return queries, nil
+}
+
+// runTableHooks runs the statements of the steps on the target, on a connection
+// of their own.
+func (a *Applier) runTableHooks(steps []tableHookStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, step := range steps {
+		queries, err := a.tableHooks.render(step)
+		if err != nil {
+			return err
+		}
+		when := "before"
+		if step.after {
+			when = "after"
+		}
+		if len(queries) > 0 {
+			a.logger.Printf("mysql.applier: running the statements %v the load of %s.%s",
+				when, sql.EscapeName(step.table.Schema), sql.EscapeName(step.table.Table))
+		}
+		for _, query := range queries {
+			a.logger.Debugf("mysql.applier: Exec [%s]", query)
+			a.auditSql("", query, nil)
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("exec [%s] %v the load of %s.%s error: %v",
+					query, when, step.table.Schema, step.table.Table, err)
+			}
+		}
+	}
+	return nil
+}
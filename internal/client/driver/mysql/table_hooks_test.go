@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableLoadHooks(t *testing.T) {
+	if h, err := newTableLoadHooks(nil, nil, false); h != nil || err != nil {
+		t.Fatalf("expected no hooks, got %v, %v", h, err)
+	}
+	if _, err := newTableLoadHooks([]string{"ALTER TABLE {{.Table"}, nil, false); err == nil {
+		t.Fatalf("expected an error parsing the template")
+	}
+
+	h, err := newTableLoadHooks(
+		[]string{"ALTER TABLE {{quote .Schema}}.{{quote .Table}} DISABLE KEYS"},
+		[]string{"ALTER TABLE {{quote .Schema}}.{{quote .Table}} ENABLE KEYS", "ANALYZE TABLE {{.Schema}}.{{.Table}}_tmp"},
+		false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]*interface{}{{new(interface{})}}
+	a, b, c, d := hookTable{"db", "a"}, hookTable{"db", "b"}, hookTable{"db", "c"}, hookTable{"db", "d"}
+	var steps []tableHookStep
+	for _, entry := range []*DumpEntry{
+		// The schema entries, of a schema without tables, then of the tables.
+		{TableSchema: "empty"},
+		{TableSchema: "db", TableName: "a"},
+		{TableSchema: "db", TableName: "b"},
+		{TableSchema: "db", TableName: "c"},
+		{TableSchema: "db", TableName: "d"},
+		// The rows: b has none.
+		{TableSchema: "db", TableName: "a", ValuesX: rows},
+		{TableSchema: "db", TableName: "a", ValuesX: rows},
+		{TableSchema: "db", TableName: "c", OutfilePath: "/tmp/c"},
+	} {
+		steps = append(steps, h.onEntry(entry)...)
+	}
+	want := []tableHookStep{
+		{table: a},
+		{table: a, after: true},
+		{table: b}, {table: b, after: true},
+		{table: c},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Fatalf("got steps %+v, want %+v", steps, want)
+	}
+	// d has no rows either.
+	steps = h.onComplete()
+	want = []tableHookStep{{table: c, after: true}, {table: d}, {table: d, after: true}}
+	if !reflect.DeepEqual(steps, want) {
+		t.Fatalf("got steps %+v on complete, want %+v", steps, want)
+	}
+	if steps := h.onComplete(); len(steps) != 0 {
+		t.Fatalf("got steps %+v on complete again", steps)
+	}
+
+	queries, err := h.render(tableHookStep{table: hookTable{"db", "t`1"}, after: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQueries := []string{"ALTER TABLE `db`.`t``1` ENABLE KEYS", "ANALYZE TABLE db.t`1_tmp"}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Fatalf("got %q, want %q", queries, wantQueries)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"sync"
+	"time"
+
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// targetReadOnlyCheckInterval is how often the read_only of the target is
+// checked with TargetReadOnly, and while the applying is paused.
+const targetReadOnlyCheckInterval = 5 * time.Second
+
+// targetReadOnlyGuard sets read_only on the target, so that only the sessions
+// of the users with SUPER, like the one of dtle, write to it, and checks that
+// nobody unsets it until the cut-over. super_read_only is not used, as it
+// blocks every session but the replication threads, the one of dtle included.
+type targetReadOnlyGuard struct {
+	logger   *log.Entry
+	interval time.Duration
+	// pause the applying while read_only is unset.
+	pause bool
+	// setReadOnly sets read_only, queryReadOnly queries read_only and
+	// super_read_only.
+	setReadOnly   func() error
+	queryReadOnly func() (bool, bool, error)
+
+	mu            sync.Mutex
+	readOnly      bool
+	superReadOnly bool
+	disabled      int64
+}
+
+func newTargetReadOnlyGuard(pause bool, setReadOnly func() error, queryReadOnly func() (bool, bool, error),
+	logger *log.Entry) *targetReadOnlyGuard {
+
+	return &targetReadOnlyGuard{
+		logger:        logger,
+		interval:      targetReadOnlyCheckInterval,
+		pause:         pause,
+		setReadOnly:   setReadOnly,
+		queryReadOnly: queryReadOnly,
+	}
+}
+
+// setTargetReadOnly sets read_only on the target db.
+func setTargetReadOnly(db *gosql.DB) error {
+	_, err := db.Exec(`set global read_only = ON`)
+	return err
+}
+
+// showTargetReadOnly returns the read_only and super_read_only of the target
+// db. super_read_only is false on MySQL 5.6, which has none.
+func showTargetReadOnly(db *gosql.DB) (bool, bool, error) {
+	var readOnly, superReadOnly bool
+	if err := db.QueryRow(`select @@global.read_only`).Scan(&readOnly); err != nil {
+		return false, false, err
+	}
+	if err := db.QueryRow(`select @@global.super_read_only`).Scan(&superReadOnly); err != nil {
+		superReadOnly = false
+	}
+	return readOnly, superReadOnly, nil
+}
+
+// Enable sets read_only on the target when the task starts.
+func (t *targetReadOnlyGuard) Enable() error {
+	if err := t.setReadOnly(); err != nil {
+		return err
+	}
+	t.logger.Printf("mysql.applier: Set read_only on the target")
+	t.mu.Lock()
+	t.readOnly = true
+	t.mu.Unlock()
+	return t.check()
+}
+
+// check queries the read_only of the target, and reports when it is found
+// unset, or set again.
+func (t *targetReadOnlyGuard) check() error {
+	readOnly, superReadOnly, err := t.queryReadOnly()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !readOnly && t.readOnly {
+		t.disabled++
+		if t.pause {
+			t.logger.Warnf("mysql.applier: read_only of the target was unset. Pausing the applying until it is set again")
+		} else {
+			t.logger.Warnf("mysql.applier: read_only of the target was unset. Other sessions might write to the target")
+		}
+	} else if readOnly && !t.readOnly {
+		t.logger.Infof("mysql.applier: read_only of the target is set again")
+	}
+	if superReadOnly && !t.superReadOnly {
+		t.logger.Warnf("mysql.applier: super_read_only of the target was set, which blocks the applying too")
+	}
+	t.readOnly, t.superReadOnly = readOnly, superReadOnly
+	return nil
+}
+
+// Run checks the read_only of the target every interval, until shutdownCh is
+// closed.
+func (t *targetReadOnlyGuard) Run(shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.check(); err != nil {
+				// Not knowing read_only doesn't stop the applying.
+				t.logger.Warnf("mysql.applier: error querying the read_only of the target: %v", err)
+			}
+		case <-shutdownCh:
+			return
+		}
+	}
+}
+
+func (t *targetReadOnlyGuard) paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pause && !t.readOnly
+}
+
+// Wait waits while the applying is paused, or until shutdownCh is closed.
+func (t *targetReadOnlyGuard) Wait(shutdownCh <-chan struct{}) {
+	for t.paused() {
+		timer := time.NewTimer(t.interval)
+		select {
+		case <-timer.C:
+		case <-shutdownCh:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Stat returns the read_only of the target last checked.
+func (t *targetReadOnlyGuard) Stat() *models.TargetReadOnly {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &models.TargetReadOnly{
+		ReadOnly:      t.readOnly,
+		SuperReadOnly: t.superReadOnly,
+		Disabled:      t.disabled,
+		Paused:        t.pause && !t.readOnly,
+	}
+}
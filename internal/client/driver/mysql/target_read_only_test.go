@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestTargetReadOnlyGuard(t *testing.T) {
+	readOnly := false
+	guard := newTargetReadOnlyGuard(true, func() error {
+		readOnly = true
+		return nil
+	}, func() (bool, bool, error) {
+		return readOnly, false, nil
+	}, log.NewEntry(log.New(os.Stderr, log.InfoLevel)))
+	guard.interval = 10 * time.Millisecond
+
+	if err := guard.Enable(); err != nil {
+		t.Fatal(err)
+	}
+	if stat := guard.Stat(); !stat.ReadOnly || stat.Disabled != 0 || stat.Paused {
+		t.Fatalf("unexpected stat %+v after Enable", stat)
+	}
+
+	// Unset by someone: counted once, and paused until set again.
+	readOnly = false
+	guard.check()
+	guard.check()
+	if stat := guard.Stat(); stat.ReadOnly || stat.Disabled != 1 || !stat.Paused {
+		t.Fatalf("unexpected stat %+v after read_only is unset", stat)
+	}
+	done := make(chan struct{})
+	go func() {
+		guard.Wait(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("not paused")
+	case <-time.After(3 * guard.interval):
+	}
+	readOnly = true
+	guard.check()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("still paused after read_only is set again")
+	}
+
+	// Interrupted by a shutdown.
+	readOnly = false
+	guard.check()
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	guard.Wait(shutdownCh)
+	if stat := guard.Stat(); stat.Disabled != 2 {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// tidbRetryInterval is the base of the backoff between the retries of a
+// transaction on TiDB.
+var tidbRetryInterval = 500 * time.Millisecond
+
+// retryOnTiDBConflict runs op, and runs it again up to MaxRetries times if the
+// target is TiDB and op failed with a retryable error. op must be idempotent.
+func (a *Applier) retryOnTiDBConflict(op func() error) (err error) {
+	if !a.mysqlContext.IsTiDB() {
+		return op()
+	}
+	for i := 1; ; i++ {
+		err = op()
+		if err == nil || !sql.IsTiDBRetryableError(err) || int64(i) >= a.mysqlContext.MaxRetries {
+			return err
+		}
+		a.logger.Warnf("mysql.applier: Retry %v on TiDB error: %v", i, err)
+		time.Sleep(time.Duration(i) * tidbRetryInterval)
+	}
+}
+
+// splitsTransaction returns whether a transaction of the source is committed
+// before its next row event, after nRows, as TiDB limits the transactions.
+func (a *Applier) splitsTransaction(nRows int) bool {
+	return a.mysqlContext.IsTiDB() && !a.mysqlContext.StrictTransactionBoundaries &&
+		nRows >= a.mysqlContext.TxnSplitSize
+}
+
+// batchImportEventQueries applies the dump entry with the batch insert of TiDB,
+// which commits every TxnSplitSize rows, instead of in a transaction.
+func (a *Applier) batchImportEventQueries(db *gosql.DB, entry *DumpEntry) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	exec := func(query string, args ...interface{}) (gosql.Result, error) {
+		return conn.ExecContext(ctx, query, args...)
+	}
+	sessionQuery := fmt.Sprintf("SET @@session.tidb_batch_insert = 1, @@session.tidb_dml_batch_size = %d",
+		a.mysqlContext.TxnSplitSize)
+	if _, err := exec(sessionQuery); err != nil {
+		return err
+	}
+	defer exec("SET @@session.tidb_batch_insert = 0")
+	return a.applyEventQueries(exec, entry)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestApplier_retryOnTiDBConflict(t *testing.T) {
+	tidbRetryInterval = time.Millisecond
+	conflict := &gomysql.MySQLError{Number: sql.ErrTiDBWriteConflict, Message: "Write conflict"}
+
+	tests := []struct {
+		name       string
+		targetType string
+		errs       []error
+		wantErr    error
+		wantCalls  int
+	}{
+		{"mysql", config.TargetTypeMySQL, []error{conflict}, conflict, 1},
+		{"tidb ok", config.TargetTypeTiDB, []error{nil}, nil, 1},
+		{"tidb conflict then ok", config.TargetTypeTiDB, []error{conflict, conflict, nil}, nil, 3},
+		{"tidb other error", config.TargetTypeTiDB, []error{errors.New("other")}, nil, 1},
+		{"tidb too many conflicts", config.TargetTypeTiDB, []error{conflict, conflict, conflict, conflict, conflict}, conflict, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.MySQLDriverConfig{
+				TargetType:       tt.targetType,
+				MaxRetries:       3,
+				ConnectionConfig: &umconf.ConnectionConfig{},
+			}
+			a, err := NewApplier("8b4e4d7c-0b7e-4bd8-a0a4-2b5d4f1d6e0a", "", cfg, log.New(os.Stderr, log.InfoLevel))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calls := 0
+			err = a.retryOnTiDBConflict(func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != tt.errs[calls-1] {
+				t.Errorf("err = %v, want %v", err, tt.errs[calls-1])
+			}
+		})
+	}
+}
+
+func TestNewApplier_TargetType(t *testing.T) {
+	cfg := &config.MySQLDriverConfig{
+		TargetType:       "Oracle",
+		ConnectionConfig: &umconf.ConnectionConfig{},
+	}
+	if _, err := NewApplier("8b4e4d7c-0b7e-4bd8-a0a4-2b5d4f1d6e0a", "", cfg, log.New(os.Stderr, log.InfoLevel)); err == nil {
+		t.Errorf("NewApplier() with an unknown TargetType should fail")
+	}
+}
+
+func TestApplier_splitsTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		targetType string
+		strict     bool
+		nRows      int
+		want       bool
+	}{
+		{"mysql", config.TargetTypeMySQL, false, 10, false},
+		{"tidb small", config.TargetTypeTiDB, false, 9, false},
+		{"tidb large", config.TargetTypeTiDB, false, 10, true},
+		{"tidb strict", config.TargetTypeTiDB, true, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.MySQLDriverConfig{
+				TargetType:                  tt.targetType,
+				TxnSplitSize:                10,
+				StrictTransactionBoundaries: tt.strict,
+				ConnectionConfig:            &umconf.ConnectionConfig{},
+			}
+			a, err := NewApplier("8b4e4d7c-0b7e-4bd8-a0a4-2b5d4f1d6e0a", "", cfg, log.New(os.Stderr, log.InfoLevel))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.splitsTransaction(tt.nRows); got != tt.want {
+				t.Errorf("splitsTransaction(%v) = %v, want %v", tt.nRows, got, tt.want)
+			}
+		})
+	}
+}
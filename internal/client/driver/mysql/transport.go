@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// minThrottleDelay is the delay after the first redelivery. The
+	// throttling stops once the delay is halved below it.
+	minThrottleDelay = 100 * time.Millisecond
+	// maxThrottleDelay is the max delay before each message.
+	maxThrottleDelay = 5 * time.Second
+	// minRateInterval is the min interval the rates are computed over, so
+	// that the statistics queried in a row keep the previous rates.
+	minRateInterval = time.Second
+)
+
+// Transport tracks the statistics of a task on the nats channel of its job.
+//
+// The Dest task does not ack a message when its queues are full, so the Src
+// task publishes it again after DefaultConnectWait. A Src task is throttled
+// while this happens: each redelivery doubles the delay before the next
+// messages, and each ack halves it.
+type Transport struct {
+	redeliveries       uint64
+	slowConsumerEvents uint64
+
+	mu       sync.Mutex
+	delay    time.Duration
+	last     gonats.Statistics
+	lastTime time.Time
+	rates    models.TransportStat
+
+	// bytesPerSec is the bandwidth limit, 0 for no limit. sendTime is when the
+	// bytes published so far are in the limit.
+	bytesPerSec int64
+	sendTime    time.Time
+}
+
+func NewTransport() *Transport {
+	return &Transport{lastTime: time.Now()}
+}
+
+// Throttle waits for the throttle delay, or until shutdownCh is closed.
+func (t *Transport) Throttle(shutdownCh <-chan struct{}) {
+	t.mu.Lock()
+	delay := t.delay
+	t.mu.Unlock()
+	if delay == 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-shutdownCh:
+	}
+}
+
+// SetBandwidthLimit limits the bytes published per second. 0 means no limit.
+func (t *Transport) SetBandwidthLimit(bytesPerSec int64) {
+	t.mu.Lock()
+	t.bytesPerSec = bytesPerSec
+	t.mu.Unlock()
+}
+
+// LimitBandwidth waits until n more bytes can be published within the
+// bandwidth limit, or until shutdownCh is closed.
+func (t *Transport) LimitBandwidth(n int, shutdownCh <-chan struct{}) {
+	t.mu.Lock()
+	if t.bytesPerSec <= 0 {
+		t.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if t.sendTime.Before(now) {
+		t.sendTime = now
+	}
+	delay := t.sendTime.Sub(now)
+	t.sendTime = t.sendTime.Add(time.Duration(int64(n) * int64(time.Second) / t.bytesPerSec))
+	t.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-shutdownCh:
+	}
+}
+
+// Redelivered is called when a message is not acked in time and is to be
+// published again.
+func (t *Transport) Redelivered() {
+	atomic.AddUint64(&t.redeliveries, 1)
+	t.mu.Lock()
+	t.delay *= 2
+	if t.delay < minThrottleDelay {
+		t.delay = minThrottleDelay
+	} else if t.delay > maxThrottleDelay {
+		t.delay = maxThrottleDelay
+	}
+	t.mu.Unlock()
+}
+
+// Acked is called when a message is acked.
+func (t *Transport) Acked() {
+	t.mu.Lock()
+	t.delay /= 2
+	if t.delay < minThrottleDelay {
+		t.delay = 0
+	}
+	t.mu.Unlock()
+}
+
+// SlowConsumer is called when a message is not acked as the queues are full,
+// or nats drops messages of a subscription.
+func (t *Transport) SlowConsumer() {
+	atomic.AddUint64(&t.slowConsumerEvents, 1)
+}
+
+// ErrorHandler counts the slow consumer errors of a nats connection.
+func (t *Transport) ErrorHandler() gonats.Option {
+	return gonats.ErrorHandler(func(nc *gonats.Conn, sub *gonats.Subscription, err error) {
+		if err == gonats.ErrSlowConsumer {
+			t.SlowConsumer()
+		}
+	})
+}
+
+// Stat returns the statistics of the connection and the subscriptions of the
+// task. The rates are those since the previous call, at least minRateInterval
+// ago.
+func (t *Transport) Stat(nc *gonats.Conn, subs []*gonats.Subscription) *models.TransportStat {
+	stat := &models.TransportStat{
+		Redeliveries:       atomic.LoadUint64(&t.redeliveries),
+		SlowConsumerEvents: atomic.LoadUint64(&t.slowConsumerEvents),
+	}
+	for _, sub := range subs {
+		if msgs, bytes, err := sub.Pending(); err == nil {
+			stat.PendingMsgs += msgs
+			stat.PendingBytes += bytes
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.delay != 0 {
+		stat.ThrottleDelay = t.delay.String()
+	}
+	if nc == nil {
+		return stat
+	}
+	now := time.Now()
+	if elapsed := now.Sub(t.lastTime); elapsed >= minRateInterval {
+		current := nc.Stats()
+		seconds := elapsed.Seconds()
+		t.rates.PublishMsgsPerSec = float64(current.OutMsgs-t.last.OutMsgs) / seconds
+		t.rates.PublishBytesPerSec = float64(current.OutBytes-t.last.OutBytes) / seconds
+		t.rates.ConsumeMsgsPerSec = float64(current.InMsgs-t.last.InMsgs) / seconds
+		t.rates.ConsumeBytesPerSec = float64(current.InBytes-t.last.InBytes) / seconds
+		t.last = current
+		t.lastTime = now
+	}
+	stat.PublishMsgsPerSec = t.rates.PublishMsgsPerSec
+	stat.PublishBytesPerSec = t.rates.PublishBytesPerSec
+	stat.ConsumeMsgsPerSec = t.rates.ConsumeMsgsPerSec
+	stat.ConsumeBytesPerSec = t.rates.ConsumeBytesPerSec
+	return stat
+}
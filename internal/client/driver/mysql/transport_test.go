@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransport_Throttle(t *testing.T) {
+	tr := NewTransport()
+	if stat := tr.Stat(nil, nil); stat.ThrottleDelay != "" {
+		t.Fatalf("throttled without redelivery: %v", stat.ThrottleDelay)
+	}
+
+	for i := 0; i < 3; i++ {
+		tr.Redelivered()
+	}
+	stat := tr.Stat(nil, nil)
+	if stat.Redeliveries != 3 || stat.ThrottleDelay != (4*minThrottleDelay).String() {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+	for i := 0; i < 10; i++ {
+		tr.Redelivered()
+	}
+	if delay := tr.Stat(nil, nil).ThrottleDelay; delay != maxThrottleDelay.String() {
+		t.Fatalf("delay %v exceeds %v", delay, maxThrottleDelay)
+	}
+
+	for i := 0; i < 6; i++ {
+		tr.Acked()
+	}
+	if delay := tr.Stat(nil, nil).ThrottleDelay; delay != "" {
+		t.Fatalf("still throttled after acks: %v", delay)
+	}
+
+	start := time.Now()
+	tr.Throttle(nil)
+	if time.Since(start) > minThrottleDelay {
+		t.Fatalf("throttled while not slow")
+	}
+
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	tr.Redelivered()
+	start = time.Now()
+	tr.Throttle(shutdownCh)
+	if time.Since(start) >= minThrottleDelay {
+		t.Fatalf("throttle not interrupted by shutdown")
+	}
+}
+
+func TestTransport_SlowConsumer(t *testing.T) {
+	tr := NewTransport()
+	tr.SlowConsumer()
+	tr.SlowConsumer()
+	if stat := tr.Stat(nil, nil); stat.SlowConsumerEvents != 2 {
+		t.Fatalf("unexpected slow consumer events %v", stat.SlowConsumerEvents)
+	}
+}
+
+func TestTransport_LimitBandwidth(t *testing.T) {
+	tr := NewTransport()
+	start := time.Now()
+	tr.LimitBandwidth(1024*1024, nil)
+	tr.LimitBandwidth(1024*1024, nil)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("limited without a limit: %v", elapsed)
+	}
+
+	// 10 messages of 100KB at 1MB/s: the first is sent at once, the last after 900ms.
+	tr.SetBandwidthLimit(1000 * 1000)
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		tr.LimitBandwidth(100*1000, nil)
+	}
+	if elapsed := time.Since(start); elapsed < 850*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Fatalf("unexpected time to send 1MB at 1MB/s: %v", elapsed)
+	}
+
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	start = time.Now()
+	tr.LimitBandwidth(100*1000*1000, shutdownCh)
+	tr.LimitBandwidth(1, shutdownCh)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("not stopped on shutdown: %v", elapsed)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/hex"
+	"fmt"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/g"
+)
+
+// A transaction split on the target, see splitsTransaction, is applied in
+// parts. Each part but the last records in the txn_progress table of the dtle
+// schema how many events of the transaction are applied, in the commit of the
+// part, like a savepoint which survives a failure. A transaction failed or
+// interrupted mid-way then resumes from the last part committed, instead of
+// being applied again from the start. The last part, which records the gtid,
+// deletes the progress.
+
+// txnProgressKey identifies a transaction of the source in txnProgress.
+func txnProgressKey(sid uuid.UUID, gno int64) string {
+	return fmt.Sprintf("%s:%d", sid, gno)
+}
+
+// createTableTxnProgress creates the txn_progress table, and prepares the
+// statements saving and deleting the progress on each connection.
+func (a *Applier) createTableTxnProgress() (err error) {
+	query := fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %v.%v (
+				job_uuid binary(16) NOT NULL COMMENT 'unique identifier of job',
+				source_uuid binary(16) NOT NULL COMMENT 'uuid of the source where the transaction was originally executed.',
+				gno bigint NOT NULL COMMENT 'gno of the transaction.',
+				events int NOT NULL COMMENT 'events of the transaction applied.',
+				PRIMARY KEY (job_uuid, source_uuid, gno)
+			);
+		`, g.DtleSchemaName, g.TxnProgressTable)
+	if _, err := a.db.Exec(query); err != nil {
+		return err
+	}
+
+	jobUUID := hex.EncodeToString(a.subjectUUID.Bytes())
+	for i := range a.dbs {
+		a.dbs[i].PsSaveTxnProgress, err = a.dbs[i].Db.PrepareContext(context.Background(), fmt.Sprintf("replace into %v.%v "+
+			"(job_uuid,source_uuid,gno,events) values (unhex('%s'), ?, ?, ?)",
+			g.DtleSchemaName, g.TxnProgressTable, jobUUID))
+		if err != nil {
+			return err
+		}
+		a.dbs[i].PsDeleteTxnProgress, err = a.dbs[i].Db.PrepareContext(context.Background(), fmt.Sprintf("delete from %v.%v "+
+			"where job_uuid = unhex('%s') and source_uuid = ? and gno = ?",
+			g.DtleSchemaName, g.TxnProgressTable, jobUUID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTxnProgress loads the progress of the transactions of the job split and
+// not completely applied, e.g. before the task failed.
+func (a *Applier) readTxnProgress() error {
+	query := fmt.Sprintf("select source_uuid, gno, events from %v.%v where job_uuid = unhex('%s')",
+		g.DtleSchemaName, g.TxnProgressTable, hex.EncodeToString(a.subjectUUID.Bytes()))
+	progress := map[string]int{}
+	err := sql.QueryRowsMap(a.db, query, func(m sql.RowMap) error {
+		sid, err := uuid.FromBytes([]byte(m.GetString("source_uuid")))
+		if err != nil {
+			return err
+		}
+		key := txnProgressKey(sid, m.GetInt64("gno"))
+		progress[key] = m.GetInt("events")
+		a.logger.Printf("mysql.applier: Transaction %v was applied in part, up to event %v", key, progress[key])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.txnProgressMutex.Lock()
+	a.txnProgress = progress
+	a.txnProgressMutex.Unlock()
+	return nil
+}
+
+// appliedEvents returns how many events of the transaction are already applied
+// by the parts committed, 0 if none.
+func (a *Applier) appliedEvents(binlogEntry *binlog.BinlogEntry) int {
+	a.txnProgressMutex.Lock()
+	defer a.txnProgressMutex.Unlock()
+	return a.txnProgress[txnProgressKey(binlogEntry.Coordinates.SID, binlogEntry.Coordinates.GNO)]
+}
+
+// setAppliedEvents records in memory the events of the transaction applied, as
+// saved by the part just committed. 0 deletes the progress.
+func (a *Applier) setAppliedEvents(binlogEntry *binlog.BinlogEntry, events int) {
+	key := txnProgressKey(binlogEntry.Coordinates.SID, binlogEntry.Coordinates.GNO)
+	a.txnProgressMutex.Lock()
+	defer a.txnProgressMutex.Unlock()
+	if events == 0 {
+		delete(a.txnProgress, key)
+	} else {
+		a.txnProgress[key] = events
+	}
+}
+
+// saveTxnProgress saves in tx, the transaction of a part, that the events
+// before the nth are applied. The progress is committed with the part, or not
+// at all.
+func (a *Applier) saveTxnProgress(tx *gosql.Tx, dbApplier *sql.Conn, binlogEntry *binlog.BinlogEntry, n int) error {
+	if dbApplier.PsSaveTxnProgress == nil {
+		return nil
+	}
+	_, err := tx.Stmt(dbApplier.PsSaveTxnProgress).Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO, n)
+	return err
+}
+
+// deleteTxnProgress deletes in tx, the transaction of the last part, the
+// progress saved by the previous ones.
+func (a *Applier) deleteTxnProgress(tx *gosql.Tx, dbApplier *sql.Conn, binlogEntry *binlog.BinlogEntry) error {
+	if dbApplier.PsDeleteTxnProgress == nil {
+		return nil
+	}
+	_, err := tx.Stmt(dbApplier.PsDeleteTxnProgress).Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO)
+	return err
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"os"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestApplier_appliedEvents(t *testing.T) {
+	cfg := &config.MySQLDriverConfig{
+		TargetType:       config.TargetTypeTiDB,
+		ConnectionConfig: &umconf.ConnectionConfig{},
+	}
+	a, err := NewApplier("8b4e4d7c-0b7e-4bd8-a0a4-2b5d4f1d6e0a", "", cfg, log.New(os.Stderr, log.InfoLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := uuid.FromStringOrNil("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	entry := &binlog.BinlogEntry{Coordinates: base.BinlogCoordinateTx{SID: sid, GNO: 5}}
+	other := &binlog.BinlogEntry{Coordinates: base.BinlogCoordinateTx{SID: sid, GNO: 6}}
+
+	if n := a.appliedEvents(entry); n != 0 {
+		t.Fatalf("applied %v events of a new transaction", n)
+	}
+	a.setAppliedEvents(entry, 3)
+	if n := a.appliedEvents(entry); n != 3 {
+		t.Fatalf("applied %v events, want 3", n)
+	}
+	if n := a.appliedEvents(other); n != 0 {
+		t.Fatalf("applied %v events of another transaction", n)
+	}
+	a.setAppliedEvents(entry, 0)
+	if n := a.appliedEvents(entry); n != 0 {
+		t.Fatalf("applied %v events after the last part", n)
+	}
+
+	// Without the txn_progress table, e.g. on MySQL, nothing is saved.
+	conn := &sql.Conn{}
+	if err := a.saveTxnProgress(nil, conn, entry, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.deleteTxnProgress(nil, conn, entry); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// Update changes the tables replicated to those of the task, while the job
+// runs. The tables removed are no longer replicated. The tables added are
+// copied as by Resync, one after another, and replicated from their snapshots
+// on, and copied again if the task restarts before, see copied_tables.go. Only
+// the tables listed by name could change so.
+func (e *Extractor) Update(task *models.Task) error {
+	var driverConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return err
+	}
+	if err := checkListedTables(e.mysqlContext.ReplicateDoDb); err != nil {
+		return err
+	}
+	if err := checkListedTables(driverConfig.ReplicateDoDb); err != nil {
+		return err
+	}
+	added, removed, err := diffTables(e.mysqlContext.ReplicateDoDb, driverConfig.ReplicateDoDb)
+	if err != nil {
+		return err
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	if len(added) > 0 {
+		if err := e.checkResync(); err != nil {
+			return fmt.Errorf("adding tables: %v", err)
+		}
+	}
+
+	for _, t := range added {
+		if err := e.inspector.ValidateOriginalTable(t.TableSchema, t.TableName, t); err != nil {
+			return err
+		}
+		if err := base.ApplyColumnTypes(e.db, t.TableSchema, t.TableName, t.OriginalTableColumns); err != nil {
+			return err
+		}
+	}
+
+	// The events of the tables added are dropped until their snapshots, which
+	// have them.
+	e.resyncLock.Lock()
+	e.pendingResyncs = append(e.pendingResyncs, added...)
+	e.resyncLock.Unlock()
+
+	if err := e.binlogReader.UpdateTables(driverConfig.ReplicateDoDb, added, removed); err != nil {
+		return err
+	}
+
+	e.resyncLock.Lock()
+	defer e.resyncLock.Unlock()
+	for _, t := range removed {
+		e.replicateDoDb = removeTable(e.replicateDoDb, t.TableSchema, t.TableName)
+		var pending []*config.Table
+		for _, p := range e.pendingResyncs {
+			if p.TableSchema != t.TableSchema || p.TableName != t.TableName {
+				pending = append(pending, p)
+			}
+		}
+		e.pendingResyncs = pending
+	}
+	for _, t := range added {
+		e.replicateDoDb = addTable(e.replicateDoDb, t)
+	}
+	e.copiedTablesChange()
+	e.logger.Printf("mysql.extractor: updated the tables. added: %v, removed: %v",
+		tableNames(added), tableNames(removed))
+	return e.startPendingResync()
+}
+
+// Update is a no-op: the applier gets a table when it first applies it.
+func (a *Applier) Update(task *models.Task) error {
+	return nil
+}
+
+// checkListedTables returns an error unless each of the tables of dbs is listed
+// by name.
+func checkListedTables(dbs []*config.DataSource) error {
+	if len(dbs) == 0 {
+		return fmt.Errorf("the tables of all the schemas could not change while running")
+	}
+	for _, db := range dbs {
+		if len(db.Tables) == 0 || strings.HasPrefix(db.TableSchema, "~") {
+			return fmt.Errorf("the tables of schema %v are not listed by name", db.TableSchema)
+		}
+		for _, tb := range db.Tables {
+			if strings.HasPrefix(tb.TableName, "~") {
+				return fmt.Errorf("table %v.%v is not listed by name", db.TableSchema, tb.TableName)
+			}
+		}
+	}
+	return nil
+}
+
+// diffTables returns the tables of newDbs not in oldDbs, and those of oldDbs
+// not in newDbs. The tables in both should be the same.
+func diffTables(oldDbs, newDbs []*config.DataSource) (added, removed []*config.Table, err error) {
+	for _, db := range newDbs {
+		for _, tb := range db.Tables {
+			old := findTable(oldDbs, db.TableSchema, tb.TableName)
+			if old == nil {
+				t := config.NewTable(db.TableSchema, tb.TableName)
+				if tb.Where != "" {
+					t.Where = tb.Where
+				}
+				added = append(added, t)
+			} else if old.Where != tb.Where && tb.Where != "" {
+				return nil, nil, fmt.Errorf("the where of table %v.%v could not change while running",
+					db.TableSchema, tb.TableName)
+			}
+		}
+	}
+	for _, db := range oldDbs {
+		for _, tb := range db.Tables {
+			if findTable(newDbs, db.TableSchema, tb.TableName) == nil {
+				removed = append(removed, config.NewTable(db.TableSchema, tb.TableName))
+			}
+		}
+	}
+	return added, removed, nil
+}
+
+func addTable(dbs []*config.DataSource, t *config.Table) []*config.DataSource {
+	for _, db := range dbs {
+		if db.TableSchema == t.TableSchema {
+			db.Tables = append(db.Tables, t)
+			return dbs
+		}
+	}
+	return append(dbs, &config.DataSource{TableSchema: t.TableSchema, Tables: []*config.Table{t}})
+}
+
+func removeTable(dbs []*config.DataSource, schema, table string) []*config.DataSource {
+	for _, db := range dbs {
+		if db.TableSchema != schema {
+			continue
+		}
+		var tables []*config.Table
+		for _, tb := range db.Tables {
+			if tb.TableName != table {
+				tables = append(tables, tb)
+			}
+		}
+		db.Tables = tables
+	}
+	return dbs
+}
+
+func tableNames(tables []*config.Table) []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = fmt.Sprintf("%v.%v", t.TableSchema, t.TableName)
+	}
+	return names
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func testDataSources(tables ...string) []*config.DataSource {
+	var dbs []*config.DataSource
+	for i := 0; i < len(tables); i += 2 {
+		dbs = addTable(dbs, config.NewTable(tables[i], tables[i+1]))
+	}
+	return dbs
+}
+
+func TestDiffTables(t *testing.T) {
+	oldDbs := testDataSources("db1", "t1", "db1", "t2", "db2", "t1")
+	newDbs := testDataSources("db1", "t1", "db2", "t1", "db2", "t2", "db3", "t1")
+
+	added, removed, err := diffTables(oldDbs, newDbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tableNames(added); !reflect.DeepEqual(got, []string{"db2.t2", "db3.t1"}) {
+		t.Errorf("added %v", got)
+	}
+	if got := tableNames(removed); !reflect.DeepEqual(got, []string{"db1.t2"}) {
+		t.Errorf("removed %v", got)
+	}
+
+	newDbs[0].Tables[0].Where = "id > 10"
+	if _, _, err := diffTables(oldDbs, newDbs); err == nil {
+		t.Errorf("expected an error of a where changed")
+	}
+}
+
+func TestCheckListedTables(t *testing.T) {
+	if err := checkListedTables(testDataSources("db1", "t1")); err != nil {
+		t.Error(err)
+	}
+	for name, dbs := range map[string][]*config.DataSource{
+		"all":          nil,
+		"schema":       {{TableSchema: "db1"}},
+		"schema regex": testDataSources("~db.*", "t1"),
+		"table regex":  testDataSources("db1", "~t.*"),
+	} {
+		if err := checkListedTables(dbs); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestRemoveTable(t *testing.T) {
+	dbs := removeTable(testDataSources("db1", "t1", "db1", "t2"), "db1", "t1")
+	if len(dbs) != 1 || len(dbs[0].Tables) != 1 || dbs[0].Tables[0].TableName != "t2" {
+		t.Errorf("got %+v", dbs)
+	}
+}
+
+func TestUncopiedTables(t *testing.T) {
+	dbs := testDataSources("db1", "t1", "db1", "t2", "db2", "t1")
+	copied := []binlog.SchemaTable{{Schema: "db1", Table: "t1"}, {Schema: "db2", Table: "t1"}, {Schema: "db3", Table: "t1"}}
+	if got := tableNames(uncopiedTables(dbs, copied)); !reflect.DeepEqual(got, []string{"db1.t2"}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,382 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// verifyMaxRows is the most rows a query of a verify job may return: the
+	// queries are meant to be aggregates, such as counts and sums.
+	verifyMaxRows = 1000
+	// verifyReplyTimeout is how long the Src task of a verify job waits for the
+	// Dest task to run the queries on the target.
+	verifyReplyTimeout = 5 * time.Minute
+)
+
+// verifyRequest is sent by the Src task of a verify job to the Dest task each
+// round, with the rows of the queries on the source.
+type verifyRequest struct {
+	Queries []*verifySourceRows
+}
+
+type verifySourceRows struct {
+	Name        string
+	TargetQuery string
+	Rows        string
+	Error       string
+}
+
+// verifyReply is the result of a round, compared by the Dest task.
+type verifyReply struct {
+	Passed bool
+	Error  string
+	Checks []*models.VerifyCheckResult
+}
+
+// Verifier runs a task of a verify job. Each round, the Src task runs the
+// queries on the source and requests the Dest task to run them on the target
+// and compare the rows. Both tasks complete once a round passes, and so does
+// the job. The errors of the databases and of the requests fail the round, not
+// the task, so that the job is not complete until the queries pass.
+type Verifier struct {
+	logger   *log.Entry
+	subject  string
+	taskType string
+	cfg      *config.MySQLDriverConfig
+	cipher   *TrafficCipher
+
+	db       *gosql.DB
+	natsConn *gonats.Conn
+
+	statLock sync.Mutex
+	stat     models.VerifyStat
+
+	waitCh       chan *models.WaitResult
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewVerifier(subject, taskType string, cfg *config.MySQLDriverConfig, logger *log.Logger) (*Verifier, error) {
+	cfg = cfg.SetDefault()
+	if taskType == models.TaskTypeSrc {
+		if len(cfg.VerifyQueries) == 0 {
+			return nil, fmt.Errorf("VerifyQueries of the verify job is empty")
+		}
+		for i, q := range cfg.VerifyQueries {
+			if q == nil || q.Query == "" {
+				return nil, fmt.Errorf("VerifyQueries %d has no Query", i)
+			}
+			if q.Name == "" {
+				q.Name = fmt.Sprintf("query%d", i+1)
+			}
+		}
+	}
+	cipher, err := NewTrafficCipher(cfg.TrafficKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		logger: log.NewEntry(logger).WithFields(log.Fields{
+			"job": subject,
+		}),
+		subject:    subject,
+		taskType:   taskType,
+		cfg:        cfg,
+		cipher:     cipher,
+		waitCh:     make(chan *models.WaitResult, 1),
+		shutdownCh: make(chan struct{}),
+	}, nil
+}
+
+func (v *Verifier) Run() {
+	defer crash.HandlePanic()
+
+	var err error
+	if v.db, err = sql.CreateDB(v.cfg.ConnectionConfig.GetDBUri()); err != nil {
+		v.onError(TaskStateDead, err)
+		return
+	}
+	if v.natsConn, err = config.NatsConnect(v.cfg.NatsAddr, v.cfg.Nats); err != nil {
+		v.logger.Errorf("mysql.verifier: Can't connect nats server %v: %v", config.NatsURL(v.cfg.NatsAddr), err)
+		v.onError(TaskStateRestart, err)
+		return
+	}
+
+	if v.taskType == models.TaskTypeDest {
+		_, err := v.natsConn.Subscribe(fmt.Sprintf("%s_verify", v.subject), v.handleRequest)
+		if err != nil {
+			v.onError(TaskStateRestart, err)
+		}
+		return
+	}
+
+	interval := time.Duration(v.cfg.VerifyIntervalSeconds) * time.Second
+	for {
+		if v.requestRound() {
+			v.logger.Printf("mysql.verifier: The queries passed")
+			v.onError(TaskStateComplete, nil)
+			return
+		}
+		select {
+		case <-time.After(interval):
+		case <-v.shutdownCh:
+			return
+		}
+	}
+}
+
+// requestRound runs the queries on the source, and requests the Dest task to
+// compare them with the target. It returns whether they all passed.
+func (v *Verifier) requestRound() bool {
+	req := &verifyRequest{}
+	for _, q := range v.cfg.VerifyQueries {
+		rows := &verifySourceRows{Name: q.Name, TargetQuery: q.TargetQuery}
+		if rows.TargetQuery == "" {
+			rows.TargetQuery = q.Query
+		}
+		var err error
+		if rows.Rows, err = verifyQueryRows(v.db, q.Query); err != nil {
+			rows.Error = err.Error()
+		}
+		req.Queries = append(req.Queries, rows)
+	}
+
+	reply, err := v.request(req)
+	if err != nil {
+		v.logger.Warnf("mysql.verifier: No reply of the Dest task: %v", err)
+		reply = &verifyReply{Error: fmt.Sprintf("no reply of the Dest task: %v", err)}
+	}
+	v.setStat(reply)
+	return reply.Passed
+}
+
+func (v *Verifier) request(req *verifyRequest) (*verifyReply, error) {
+	subject := fmt.Sprintf("%s_verify", v.subject)
+	msg, err := Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	if msg, err = v.cipher.Seal(subject, msg); err != nil {
+		return nil, err
+	}
+	m, err := v.natsConn.Request(subject, msg, verifyReplyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	data, err := v.cipher.Open(subject+"_reply", m.Data)
+	if err != nil {
+		return nil, err
+	}
+	var reply verifyReply
+	if err := Decode(data, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// handleRequest compares the rows of the source of the request with the
+// target, and replies the result. The Dest task completes once it passed.
+func (v *Verifier) handleRequest(m *gonats.Msg) {
+	subject := fmt.Sprintf("%s_verify", v.subject)
+	data, err := v.cipher.Open(subject, m.Data)
+	if err != nil {
+		v.logger.Errorf("mysql.verifier: Bad request: %v", err)
+		return
+	}
+	var req verifyRequest
+	if err := Decode(data, &req); err != nil {
+		v.logger.Errorf("mysql.verifier: Bad request: %v", err)
+		return
+	}
+
+	reply := compareVerifyRows(&req, func(query string) (string, error) {
+		return verifyQueryRows(v.db, query)
+	})
+	v.setStat(reply)
+
+	msg, err := Encode(reply)
+	if err == nil {
+		msg, err = v.cipher.Seal(subject+"_reply", msg)
+	}
+	if err == nil {
+		err = v.natsConn.Publish(m.Reply, msg)
+	}
+	if err == nil {
+		err = v.natsConn.Flush()
+	}
+	if err != nil {
+		v.logger.Errorf("mysql.verifier: Failed to reply: %v", err)
+		return
+	}
+	if reply.Passed {
+		v.logger.Printf("mysql.verifier: The queries passed")
+		// Not in the handler, as the shutdown closes the nats connection.
+		go v.onError(TaskStateComplete, nil)
+	}
+}
+
+// compareVerifyRows runs the queries of the request on the target with query,
+// and compares their rows with the ones of the source.
+func compareVerifyRows(req *verifyRequest, query func(string) (string, error)) *verifyReply {
+	reply := &verifyReply{Passed: true}
+	for _, q := range req.Queries {
+		check := &models.VerifyCheckResult{
+			Name:   q.Name,
+			Source: q.Rows,
+		}
+		if q.Error != "" {
+			check.Error = fmt.Sprintf("source: %v", q.Error)
+		} else if rows, err := query(q.TargetQuery); err != nil {
+			check.Error = fmt.Sprintf("target: %v", err)
+		} else {
+			check.Target = rows
+			check.Passed = rows == q.Rows
+		}
+		reply.Passed = reply.Passed && check.Passed
+		reply.Checks = append(reply.Checks, check)
+	}
+	return reply
+}
+
+// verifyQueryRows runs the query in a read-only transaction, and returns its
+// rows as a JSON array of arrays of the columns, with NULL as null.
+func verifyQueryRows(db *gosql.DB, query string) (string, error) {
+	tx, err := db.BeginTx(context.Background(), &gosql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	result := make([][]*string, 0)
+	values := make([]gosql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result) == verifyMaxRows {
+			return "", fmt.Errorf("more than %d rows, use aggregates such as COUNT(*)", verifyMaxRows)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		row := make([]*string, len(columns))
+		for i, value := range values {
+			if value != nil {
+				s := string(value)
+				row[i] = &s
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (v *Verifier) setStat(reply *verifyReply) {
+	v.statLock.Lock()
+	defer v.statLock.Unlock()
+	v.stat.Rounds++
+	v.stat.LastRoundTime = time.Now().UnixNano()
+	v.stat.Passed = reply.Passed
+	v.stat.Error = reply.Error
+	v.stat.Checks = reply.Checks
+}
+
+func (v *Verifier) Stats() (*models.TaskStatistics, error) {
+	v.statLock.Lock()
+	stat := v.stat
+	v.statLock.Unlock()
+	return &models.TaskStatistics{
+		Verify:    &stat,
+		Timestamp: time.Now().UTC().UnixNano(),
+	}, nil
+}
+
+func (v *Verifier) ID() string {
+	id := config.DriverCtx{
+		DriverConfig: &config.MySQLDriverConfig{
+			NatsAddr:         v.cfg.NatsAddr,
+			ConnectionConfig: v.cfg.ConnectionConfig,
+		},
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		v.logger.Errorf("mysql.verifier: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (v *Verifier) onError(state int, err error) {
+	v.shutdownLock.Lock()
+	shutdown := v.shutdown
+	v.shutdownLock.Unlock()
+	if shutdown {
+		return
+	}
+	if err != nil {
+		v.logger.Errorf("mysql.verifier: %v", err)
+	}
+	v.waitCh <- models.NewWaitResult(state, err)
+	v.Shutdown()
+}
+
+func (v *Verifier) WaitCh() chan *models.WaitResult {
+	return v.waitCh
+}
+
+func (v *Verifier) Shutdown() error {
+	v.shutdownLock.Lock()
+	defer v.shutdownLock.Unlock()
+	if v.shutdown {
+		return nil
+	}
+	v.shutdown = true
+	close(v.shutdownCh)
+
+	if v.natsConn != nil {
+		v.natsConn.Close()
+	}
+	if err := sql.CloseDB(v.db); err != nil {
+		return err
+	}
+	v.logger.Printf("mysql.verifier: Shutting down")
+	return nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareVerifyRows(t *testing.T) {
+	target := map[string]string{
+		"SELECT COUNT(*) FROM db1.tb1":                `[["100"]]`,
+		"SELECT COUNT(*), SUM(amount) FROM db1.tb2":   `[["20","35.50"]]`,
+		"SELECT COUNT(*), SUM(amount) FROM db2.tb2_a": `[["20",null]]`,
+	}
+	query := func(q string) (string, error) {
+		if rows, ok := target[q]; ok {
+			return rows, nil
+		}
+		return "", fmt.Errorf("table doesn't exist")
+	}
+
+	req := &verifyRequest{Queries: []*verifySourceRows{
+		{Name: "tb1", TargetQuery: "SELECT COUNT(*) FROM db1.tb1", Rows: `[["100"]]`},
+		{Name: "tb2", TargetQuery: "SELECT COUNT(*), SUM(amount) FROM db1.tb2", Rows: `[["20","35.50"]]`},
+	}}
+	if reply := compareVerifyRows(req, query); !reply.Passed || len(reply.Checks) != 2 {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	req.Queries = append(req.Queries,
+		&verifySourceRows{Name: "renamed", TargetQuery: "SELECT COUNT(*), SUM(amount) FROM db2.tb2_a", Rows: `[["20","35.50"]]`},
+		&verifySourceRows{Name: "missing", TargetQuery: "SELECT COUNT(*) FROM db1.tb3", Rows: `[["1"]]`},
+		&verifySourceRows{Name: "source", Error: "access denied"})
+	reply := compareVerifyRows(req, query)
+	if reply.Passed {
+		t.Errorf("passed with different rows")
+	}
+	for i, passed := range []bool{true, true, false, false, false} {
+		if reply.Checks[i].Passed != passed {
+			t.Errorf("check %v = %+v", i, reply.Checks[i])
+		}
+	}
+	if c := reply.Checks[2]; c.Target != `[["20",null]]` || c.Error != "" {
+		t.Errorf("check 2 = %+v", c)
+	}
+	if c := reply.Checks[3]; c.Error != "target: table doesn't exist" {
+		t.Errorf("check 3 = %+v", c)
+	}
+	if c := reply.Checks[4]; c.Error != "source: access denied" {
+		t.Errorf("check 4 = %+v", c)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// xtrabackup prints the position of the backup like:
+//
+//	MySQL binlog position: filename 'mysql-bin.000003', position '1234', GTID of the last change '<gtid set>'
+const xbstreamChunkSize = 512 * 1024
+
+var xtrabackupGtidRegexp = regexp.MustCompile(`(?s)GTID of the last change '([^']*)'`)
+
+func parseXtrabackupGtid(output string) (string, error) {
+	m := xtrabackupGtidRegexp.FindStringSubmatch(output)
+	if m == nil {
+		return "", fmt.Errorf("cannot find gtid in xtrabackup output. Is gtid_mode on for the source?")
+	}
+	gtidSet, err := gomysql.ParseMysqlGTIDSet(strings.Join(strings.Fields(m[1]), ""))
+	if err != nil {
+		return "", err
+	}
+	return gtidSet.String(), nil
+}
+
+func xtrabackupBin(cfg *config.MySQLDriverConfig, name string) string {
+	if cfg.XtrabackupBinDir == "" {
+		return name // find in $PATH
+	}
+	return filepath.Join(cfg.XtrabackupBinDir, name)
+}
+
+// xtrabackupCmd makes an xtrabackup command connecting to the mysql.
+// The password is passed by environment variable, to be invisible in `ps`.
+func xtrabackupCmd(cfg *config.MySQLDriverConfig, conn *umconf.ConnectionConfig, args ...string) *exec.Cmd {
+	args = append([]string{
+		fmt.Sprintf("--host=%s", conn.Host),
+		fmt.Sprintf("--port=%d", conn.Port),
+		fmt.Sprintf("--user=%s", conn.User),
+	}, args...)
+	cmd := exec.Command(xtrabackupBin(cfg, "xtrabackup"), args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MYSQL_PWD=%s", conn.Password))
+	return cmd
+}
+
+// xtrabackupCopy is the full copy with `xtrabackup --backup --stream=xbstream`.
+// The stream is sent to the applier by chunks in DumpEntry.XbstreamData.
+func (e *Extractor) xtrabackupCopy() error {
+	tmpDir, err := ioutil.TempDir(e.mysqlContext.WorkDir, "dtle-xtrabackup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cmd := xtrabackupCmd(e.mysqlContext, e.mysqlContext.ConnectionConfig,
+		"--backup", "--stream=xbstream", fmt.Sprintf("--target-dir=%s", tmpDir))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	e.logger.Printf("mysql.extractor: start xtrabackup: %v", cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, xbstreamChunkSize)
+	for {
+		n, errRead := io.ReadFull(stdout, buf)
+		if n > 0 {
+			entry := &DumpEntry{
+				XbstreamData: append([]byte(nil), buf[:n]...),
+				RowsCount:    1,
+			}
+			atomic.AddInt64(&e.mysqlContext.RowsEstimate, 1)
+			if err := e.encodeDumpEntry(entry); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+			atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, 1)
+		}
+		if errRead == io.EOF || errRead == io.ErrUnexpectedEOF {
+			break
+		} else if errRead != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return errRead
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("xtrabackup error: %v. stderr: %v", err, stderr.String())
+	}
+	gtid, err := parseXtrabackupGtid(stderr.String())
+	if err != nil {
+		return err
+	}
+	e.logger.Printf("mysql.extractor: xtrabackup finished. gtid: %v", gtid)
+	e.initialBinlogCoordinates = &base.BinlogCoordinatesX{
+		GtidSet: gtid,
+	}
+	return nil
+}
+
+// xbstreamReceiver extracts the xbstream from the extractor into a dir, then prepares
+// and restores it on the target.
+type xbstreamReceiver struct {
+	cfg    *config.MySQLDriverConfig
+	dir    string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func newXbstreamReceiver(cfg *config.MySQLDriverConfig, dir string) (*xbstreamReceiver, error) {
+	if dir == "" {
+		if cfg.WorkDir == "" {
+			return nil, fmt.Errorf("XtrabackupDir is required for FullCopyMethod xtrabackup")
+		}
+		dir = filepath.Join(cfg.WorkDir, "xtrabackup")
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	r := &xbstreamReceiver{
+		cfg: cfg,
+		dir: dir,
+	}
+	r.cmd = exec.Command(xtrabackupBin(cfg, "xbstream"), "-x", "-C", dir)
+	r.cmd.Stderr = &r.stderr
+	var err error
+	r.stdin, err = r.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *xbstreamReceiver) Write(data []byte) error {
+	_, err := r.stdin.Write(data)
+	if err != nil {
+		return fmt.Errorf("write to xbstream error: %v. stderr: %v", err, r.stderr.String())
+	}
+	return nil
+}
+
+// Finish is called after all the stream is written. It prepares the backup and runs
+// XtrabackupRestoreCommand to put it into use on the target.
+func (r *xbstreamReceiver) Finish() error {
+	r.stdin.Close()
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("xbstream error: %v. stderr: %v", err, r.stderr.String())
+	}
+
+	prepare := exec.Command(xtrabackupBin(r.cfg, "xtrabackup"), "--prepare", fmt.Sprintf("--target-dir=%s", r.dir))
+	if output, err := prepare.CombinedOutput(); err != nil {
+		return fmt.Errorf("xtrabackup prepare error: %v. output: %s", err, output)
+	}
+
+	if r.cfg.XtrabackupRestoreCommand == "" {
+		return fmt.Errorf("XtrabackupRestoreCommand is not set. the prepared backup is left in %v", r.dir)
+	}
+	restore := exec.Command("sh", "-c", r.cfg.XtrabackupRestoreCommand)
+	restore.Env = append(os.Environ(), fmt.Sprintf("DTLE_XTRABACKUP_DIR=%s", r.dir))
+	if output, err := restore.CombinedOutput(); err != nil {
+		return fmt.Errorf("xtrabackup restore command error: %v. output: %s", err, output)
+	}
+	return nil
+}
+
+// finishXtrabackup restores the backup on the target, and reconnects to it, as the
+// target has been restarted with the data of the backup.
+func (a *Applier) finishXtrabackup() error {
+	a.mysqlContext.Stage = models.StageSlaveWaitingForWorkersToProcessQueue
+	a.logger.Printf("mysql.applier: preparing and restoring xtrabackup in %v", a.xbstream.dir)
+	if err := a.xbstream.Finish(); err != nil {
+		return err
+	}
+
+	sql.CloseConns(a.dbs...)
+	sql.CloseDB(a.db)
+	if err := a.initDBConnections(); err != nil {
+		return err
+	}
+	a.logger.Printf("mysql.applier: xtrabackup restored")
+	return nil
+}
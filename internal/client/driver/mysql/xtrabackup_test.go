@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func Test_parseXtrabackupGtid(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "2.4",
+			output: "xtrabackup: Transaction log of lsn (2543172) to (2543181) was copied.\n" +
+				"MySQL binlog position: filename 'mysql-bin.000003', position '1234', " +
+				"GTID of the last change '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,\n" +
+				"4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3'\n" +
+				"completed OK!\n",
+			want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3",
+		},
+		{
+			name:    "no gtid",
+			output:  "MySQL binlog position: filename 'mysql-bin.000003', position '1234'\ncompleted OK!\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseXtrabackupGtid(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseXtrabackupGtid() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			// The order of uuids in the result is not fixed.
+			gotSet, err := gomysql.ParseMysqlGTIDSet(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantSet, _ := gomysql.ParseMysqlGTIDSet(tt.want)
+			if !gotSet.Equal(wantSet) {
+				t.Errorf("parseXtrabackupGtid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXbstreamReceiver(t *testing.T) {
+	binDir, err := ioutil.TempDir("", "dtle-xtrabackup-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+	// Stubs: xbstream saves the stream as a file, xtrabackup marks the prepare.
+	stubs := map[string]string{
+		"xbstream":   "#!/bin/sh\ncat > \"$3/stream\"\n",
+		"xtrabackup": "#!/bin/sh\ntouch \"${2#--target-dir=}/prepared\"\n",
+	}
+	for name, content := range stubs {
+		if err := ioutil.WriteFile(filepath.Join(binDir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Join(binDir, "backup")
+	cfg := &config.MySQLDriverConfig{
+		XtrabackupBinDir:         binDir,
+		XtrabackupRestoreCommand: "touch \"$DTLE_XTRABACKUP_DIR/restored\"",
+	}
+	r, err := newXbstreamReceiver(cfg, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"abc", "def"} {
+		if err := r.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "stream"))
+	if err != nil || string(bs) != "abcdef" {
+		t.Errorf("stream = %q, err %v", string(bs), err)
+	}
+	for _, f := range []string{"prepared", "restored"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%v: %v", f, err)
+		}
+	}
+}
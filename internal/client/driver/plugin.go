@@ -0,0 +1,254 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+	plugin "github.com/hashicorp/go-plugin"
+
+	"github.com/actiontech/dtle/internal/models"
+	pdriver "github.com/actiontech/dtle/plugins/driver"
+)
+
+// PluginDrivers contains the drivers discovered in the plugin dir, by name.
+// They take precedence over the builtin drivers of the same name.
+var PluginDrivers = map[string]string{}
+
+// DiscoverPlugins registers the executables named "dtle-driver-<name>" in the
+// dir as the driver <name>, and returns the names. A missing dir has no plugin.
+func DiscoverPlugins(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.Mode().IsRegular() || f.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		if !strings.HasPrefix(f.Name(), pdriver.BinaryPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(f.Name(), pdriver.BinaryPrefix)
+		if name == "" {
+			continue
+		}
+		PluginDrivers[name] = filepath.Join(dir, f.Name())
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Drivers returns the names of the builtin and the plugin drivers.
+func Drivers() []string {
+	var names []string
+	for name := range BuiltinDrivers {
+		if _, ok := PluginDrivers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range PluginDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PluginDriver is a driver run by a plugin binary. A plugin process is
+// launched for each started task and for each validation.
+type PluginDriver struct {
+	DriverContext
+	name string
+	path string
+	// executor runs the builtin driver name with the dtle binary itself.
+	executor bool
+}
+
+// NewPluginDriver returns the factory of the driver of the plugin binary.
+func NewPluginDriver(name, path string) Factory {
+	return func(ctx *DriverContext) Driver {
+		return &PluginDriver{DriverContext: *ctx, name: name, path: path}
+	}
+}
+
+// launch starts the plugin process and dispenses its driver.
+func (d *PluginDriver) launch() (*plugin.Client, pdriver.Driver, error) {
+	var output io.Writer = os.Stderr
+	if d.logger != nil {
+		output = d.logger.Out
+	}
+	cmd := exec.Command(d.path)
+	if d.executor {
+		var err error
+		if cmd, err = d.executorCommand(); err != nil {
+			return nil, nil, err
+		}
+	}
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  pdriver.Handshake,
+		Plugins:          pdriver.PluginMap,
+		Cmd:              cmd,
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "driver." + d.name,
+			Output: output,
+			Level:  hclog.Info,
+		}),
+	})
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to launch driver plugin %v: %v", d.path, err)
+	}
+	raw, err := rpcClient.Dispense(pdriver.PluginName)
+	if err != nil {
+		client.Kill()
+		return nil, nil, fmt.Errorf("failed to dispense driver plugin %v: %v", d.path, err)
+	}
+	return client, raw.(pdriver.Driver), nil
+}
+
+func pluginTask(task *models.Task) *pdriver.Task {
+	if task.ConfigLock != nil {
+		task.ConfigLock.RLock()
+		defer task.ConfigLock.RUnlock()
+	}
+	config := make(map[string]interface{}, len(task.Config))
+	for k, v := range task.Config {
+		config[k] = v
+	}
+	return &pdriver.Task{Type: task.Type, Driver: task.Driver, Config: config}
+}
+
+// Validate is used to validate the driver configuration
+func (d *PluginDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	client, drv, err := d.launch()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Kill()
+
+	raw, err := drv.Validate(pluginTask(task))
+	if err != nil {
+		return nil, err
+	}
+	reply := &models.TaskValidateResponse{}
+	if err := json.Unmarshal(raw.(json.RawMessage), reply); err != nil {
+		return nil, fmt.Errorf("invalid validation result of driver plugin %v: %v", d.name, err)
+	}
+	return reply, nil
+}
+
+func (d *PluginDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	client, drv, err := d.launch()
+	if err != nil {
+		return nil, err
+	}
+	pctx := &pdriver.ExecContext{
+		Subject:    ctx.Subject,
+		Tp:         ctx.Tp,
+		MaxPayload: ctx.MaxPayload,
+		TaskDir:    ctx.TaskDir,
+	}
+	if d.executor {
+		if pctx.Builtin, err = d.builtinContext(ctx); err != nil {
+			client.Kill()
+			return nil, err
+		}
+	}
+	h, err := drv.Start(pctx, pluginTask(task))
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	handle := &pluginHandle{
+		client: client,
+		handle: h,
+		waitCh: make(chan *models.WaitResult, 1),
+	}
+	go handle.wait()
+	return handle, nil
+}
+
+// emptyHandleID is the ID of a handle whose plugin is gone.
+const emptyHandleID = `{"DriverConfig":{}}`
+
+// pluginHandle is the handle of a task of a plugin driver. The plugin process
+// is killed when the task exits.
+type pluginHandle struct {
+	client *plugin.Client
+	handle pdriver.Handle
+	waitCh chan *models.WaitResult
+}
+
+func (h *pluginHandle) wait() {
+	res := h.handle.Wait()
+	h.client.Kill()
+
+	var err error
+	if res.Err != "" {
+		err = errors.New(res.Err)
+	}
+	h.waitCh <- models.NewWaitResult(res.ExitCode, err)
+}
+
+func (h *pluginHandle) ID() string {
+	if h.client.Exited() {
+		return emptyHandleID
+	}
+	return h.handle.ID()
+}
+
+func (h *pluginHandle) Pid() int {
+	if h.client.Exited() {
+		return 0
+	}
+	if rc := h.client.ReattachConfig(); rc != nil {
+		return rc.Pid
+	}
+	return 0
+}
+
+func (h *pluginHandle) WaitCh() chan *models.WaitResult {
+	return h.waitCh
+}
+
+func (h *pluginHandle) Shutdown() error {
+	if h.client.Exited() {
+		return nil
+	}
+	return h.handle.Shutdown()
+}
+
+func (h *pluginHandle) Stats() (*models.TaskStatistics, error) {
+	raw, err := h.handle.Stats()
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.TaskStatistics{}
+	if err := json.Unmarshal(raw.(json.RawMessage), stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+	pdriver "github.com/actiontech/dtle/plugins/driver"
+	"github.com/actiontech/dtle/plugins/hooks"
+)
+
+// ServeBuiltin serves the builtin driver as a plugin, so that it runs in a
+// separate process behind the same boundary as the third party drivers. It
+// does not return unless the driver is unknown.
+func ServeBuiltin(name string, logger *log.Logger) error {
+	factory, ok := BuiltinDrivers[name]
+	if !ok {
+		return fmt.Errorf("unknown driver '%s'", name)
+	}
+	ctx := NewDriverContext(name, "", nil, nil, logger)
+	pdriver.Serve(&builtinPlugin{driver: factory(ctx), logger: logger})
+	return nil
+}
+
+// builtinPlugin adapts a builtin driver to the plugin interface.
+type builtinPlugin struct {
+	driver Driver
+	logger *log.Logger
+	// The hook plugins are loaded by the first task of an executor.
+	hooksOnce sync.Once
+}
+
+func builtinTask(task *pdriver.Task) *models.Task {
+	return &models.Task{
+		Type:       task.Type,
+		Driver:     task.Driver,
+		Config:     task.Config,
+		ConfigLock: &sync.RWMutex{},
+	}
+}
+
+func (p *builtinPlugin) Start(ctx *pdriver.ExecContext, task *pdriver.Task) (pdriver.Handle, error) {
+	execCtx := &ExecContext{
+		Subject:    ctx.Subject,
+		Tp:         ctx.Tp,
+		MaxPayload: ctx.MaxPayload,
+		TaskDir:    ctx.TaskDir,
+	}
+	if len(ctx.Builtin) > 0 {
+		var bctx builtinContext
+		if err := json.Unmarshal(ctx.Builtin, &bctx); err != nil {
+			return nil, fmt.Errorf("invalid context of the executor: %v", err)
+		}
+		execCtx.Nats = bctx.Nats
+		execCtx.ConsulAddr = bctx.ConsulAddr
+		execCtx.Vault = bctx.Vault
+		execCtx.Storage = bctx.Storage
+		p.hooksOnce.Do(func() {
+			if bctx.PluginDir == "" {
+				return
+			}
+			if _, err := hooks.LoadPlugins(bctx.PluginDir); err != nil {
+				p.logger.Errorf("executor: loading hook plugins failed: %v", err)
+			}
+		})
+	}
+	h, err := p.driver.Start(execCtx, builtinTask(task))
+	if err != nil {
+		return nil, err
+	}
+	return &builtinHandle{h}, nil
+}
+
+func (p *builtinPlugin) Validate(task *pdriver.Task) (interface{}, error) {
+	return p.driver.Validate(builtinTask(task))
+}
+
+type builtinHandle struct {
+	DriverHandle
+}
+
+func (h *builtinHandle) Wait() *pdriver.WaitResult {
+	res := <-h.WaitCh()
+	r := &pdriver.WaitResult{ExitCode: res.ExitCode}
+	if res.Err != nil {
+		r.Err = res.Err.Error()
+	}
+	return r
+}
+
+func (h *builtinHandle) Stats() (interface{}, error) {
+	return h.DriverHandle.Stats()
+}
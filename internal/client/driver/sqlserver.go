@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/sqlserver"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+type SQLServerDriver struct {
+	DriverContext
+}
+
+func NewSQLServerDriver(ctx *DriverContext) Driver {
+	return &SQLServerDriver{DriverContext: *ctx}
+}
+
+func (sd *SQLServerDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig sqlserver.SQLServerDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.Nats = ctx.Nats
+	key, err := trafficKey(ctx, driverConfig.TrafficKeyVaultPath)
+	if err != nil {
+		return nil, err
+	}
+	driverConfig.TrafficKey = key
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		extractor := sqlserver.NewExtractor(ctx.Subject, ctx.MaxPayload, &driverConfig, sd.logger)
+		go extractor.Run()
+		return extractor, nil
+	case models.TaskTypeDest:
+		return nil, fmt.Errorf("SQLServer can only be used on 'Src'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+// Validate checks that CDC is enabled on the database, and the tables to
+// replicate have capture instances.
+func (sd *SQLServerDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig sqlserver.SQLServerDriverConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeSrc {
+		return reply, fmt.Errorf("SQLServer can only be used on 'Src'")
+	}
+	if driverConfig.ConnectionConfig == nil {
+		return reply, fmt.Errorf("no ConnectionConfig")
+	}
+
+	db, err := sqlserver.Open(driverConfig.ConnectionConfig)
+	if err != nil {
+		reply.Connection.Error = err.Error()
+		return reply, nil
+	}
+	defer db.Close()
+
+	if err := sqlserver.CheckCDC(db, driverConfig.ReplicateDoDb); err != nil {
+		reply.Connection.Error = err.Error()
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
@@ -0,0 +1,789 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package sqlserver extracts the changes of SQL Server from the change tables
+// of change data capture (CDC), as the events of tables of the same shape as
+// the binlog events of MySQL, so that they are applied by the MySQL and Kafka
+// drivers.
+package sqlserver
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	gonats "github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	TaskStateComplete int = iota
+	TaskStateRestart
+	TaskStateDead
+)
+
+const (
+	defaultChunkSize      = 2000
+	defaultPollIntervalMs = 1000
+	dialTimeout           = 10 * time.Second
+)
+
+var epochDuration = time.Hour
+
+type ConnectionConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// Database is the database of the replicated tables, with CDC enabled.
+	Database string
+}
+
+// uri returns the connection string of the database.
+func (c *ConnectionConfig) uri() string {
+	port := c.Port
+	if port == 0 {
+		port = 1433
+	}
+	query := url.Values{}
+	query.Set("database", c.Database)
+	query.Set("dial timeout", strconv.Itoa(int(dialTimeout/time.Second)))
+	u := &url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(port)),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Open connects to the database of SQL Server.
+func Open(c *ConnectionConfig) (*gosql.DB, error) {
+	db, err := gosql.Open("sqlserver", c.uri())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+type SQLServerDriverConfig struct {
+	ConnectionConfig *ConnectionConfig
+	// ReplicateDoDb is the schemas and the tables (as Tables) to replicate.
+	// Defaults to all the tables with a capture instance.
+	ReplicateDoDb []*config.DataSource
+	// ChunkSize is the rows in a message of the full copy, and about the
+	// changes read from a change table at a time. Defaults to 2000.
+	ChunkSize int
+	// PollIntervalMs is how long to wait when there is no change. Defaults to 1000.
+	PollIntervalMs int
+	Gtid           string
+	NatsAddr       string
+	// Nats is set by the agent.
+	Nats *config.NatsConfig
+	// TrafficKeyVaultPath is the key in Vault encrypting the data sent to the
+	// Dest task, which must be the same as that of the Dest task.
+	TrafficKeyVaultPath string
+	// TrafficKey is set by the agent, from TrafficKeyVaultPath or the nats
+	// encrypt_key.
+	TrafficKey []byte `json:"-"`
+}
+
+// Extractor copies the tables and then polls the change tables, sending the
+// changes to the applier the same as the extractor of MySQL.
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *SQLServerDriverConfig
+
+	db        *gosql.DB
+	natsConn  *gonats.Conn
+	cipher    *mysqlDriver.TrafficCipher
+	transport *mysqlDriver.Transport
+	waitCh    chan *models.WaitResult
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+
+	instances []*captureInstance
+	tables    map[*captureInstance]*config.Table
+
+	// the epoch, the gno of the last transaction in it, and when it began
+	epoch      lsn
+	sid        uuid.UUID
+	gno        int64
+	epochBegan time.Time
+
+	stage      string
+	rowsCopied int64
+	txSent     int64
+}
+
+func NewExtractor(subject string, maxPayload int, cfg *SQLServerDriverConfig, logger *log.Logger) *Extractor {
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.PollIntervalMs <= 0 {
+		cfg.PollIntervalMs = defaultPollIntervalMs
+	}
+	return &Extractor{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        cfg,
+		waitCh:     make(chan *models.WaitResult, 1),
+		shutdownCh: make(chan struct{}),
+		transport:  mysqlDriver.NewTransport(),
+		tables:     make(map[*captureInstance]*config.Table),
+	}
+}
+
+func (e *Extractor) Run() {
+	if e.cfg.ConnectionConfig == nil {
+		e.onError(TaskStateDead, fmt.Errorf("no ConnectionConfig"))
+		return
+	}
+	var err error
+	if e.db, err = Open(e.cfg.ConnectionConfig); err != nil {
+		e.onError(TaskStateDead, err)
+		return
+	}
+	e.logger.Printf("sqlserver.extractor: Extract changes from %v:%v/%v",
+		e.cfg.ConnectionConfig.Host, e.cfg.ConnectionConfig.Port, e.cfg.ConnectionConfig.Database)
+
+	if e.instances, err = captureInstances(e.db, e.cfg.ReplicateDoDb); err != nil {
+		e.onError(TaskStateDead, err)
+		return
+	}
+	if len(e.instances) == 0 {
+		e.onError(TaskStateDead, fmt.Errorf("no table to replicate has a capture instance"))
+		return
+	}
+	for _, ci := range e.instances {
+		e.tables[ci] = newTable(ci)
+	}
+
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(TaskStateDead, err)
+		return
+	}
+	_, err = e.natsConn.Subscribe(fmt.Sprintf("%s_restart", e.subject), func(m *gonats.Msg) {
+		e.cfg.Gtid = string(m.Data)
+		e.onError(TaskStateRestart, fmt.Errorf("restart"))
+	})
+	if err != nil {
+		e.onError(TaskStateDead, err)
+		return
+	}
+
+	var skip int64
+	if e.cfg.Gtid == "" {
+		if e.epoch, err = e.maxLSN(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		e.sid = epochSID(e.epoch)
+		// the changes after the epoch are polled after the copy, some of
+		// which are already copied and applied again
+		if err := e.fullCopy(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+	} else {
+		if e.epoch, skip, err = parseCheckpoint(e.cfg.Gtid); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		e.sid = epochSID(e.epoch)
+		e.logger.Printf("sqlserver.extractor: Resume from %v", e.cfg.Gtid)
+	}
+	e.epochBegan = time.Now()
+
+	go func() {
+		e.stage = models.StageWaitingForMasterToSendEvent
+		if err := e.pollChanges(skip); err != nil {
+			e.onError(TaskStateDead, err)
+		}
+	}()
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	natsAddr := config.NatsURL(e.cfg.NatsAddr)
+	sc, err := config.NatsConnect(e.cfg.NatsAddr, e.cfg.Nats)
+	if err != nil {
+		e.logger.Errorf("sqlserver.extractor: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
+		return err
+	}
+	e.logger.Debugf("sqlserver.extractor: Connect nats server %v", natsAddr)
+	e.natsConn = sc
+	e.cipher, err = mysqlDriver.NewTrafficCipher(e.cfg.TrafficKey)
+	return err
+}
+
+// publish sends the message and waits for the ack of the applier.
+func (e *Extractor) publish(subject string, v interface{}) error {
+	msg, err := mysqlDriver.Encode(v)
+	if err != nil {
+		return err
+	}
+	for !e.shutdown {
+		// sealed on each attempt, as the Dest task drops a message received again
+		sealed, err := e.cipher.Seal(subject, msg)
+		if err != nil {
+			return err
+		}
+		e.transport.Throttle(e.shutdownCh)
+		e.logger.Debugf("sqlserver.extractor: publish. subject: %v, msg_len: %v", subject, len(sealed))
+		_, err = e.natsConn.Request(subject, sealed, mysqlDriver.DefaultConnectWait)
+		if err != gonats.ErrTimeout {
+			if err == nil {
+				e.transport.Acked()
+			}
+			return err
+		}
+		e.logger.Debugf("sqlserver.extractor: publish timeout, got %v", err)
+		e.transport.Redelivered()
+	}
+	return nil
+}
+
+// CheckCDC checks that CDC is enabled on the database and some replicated
+// table has a capture instance.
+func CheckCDC(db *gosql.DB, doDbs []*config.DataSource) error {
+	var enabled bool
+	if err := db.QueryRow("SELECT is_cdc_enabled FROM sys.databases WHERE name = DB_NAME()").Scan(&enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("CDC is not enabled on the database. see sys.sp_cdc_enable_db")
+	}
+	instances, err := captureInstances(db, doDbs)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("no table to replicate has a capture instance. see sys.sp_cdc_enable_table")
+	}
+	return nil
+}
+
+// captureInstances returns the latest capture instances of the replicated
+// tables, with the captured columns.
+func captureInstances(db *gosql.DB, doDbs []*config.DataSource) ([]*captureInstance, error) {
+	rows, err := db.Query(`SELECT OBJECT_SCHEMA_NAME(source_object_id), OBJECT_NAME(source_object_id),
+		capture_instance, object_id FROM cdc.change_tables ORDER BY create_date`)
+	if err != nil {
+		return nil, fmt.Errorf("list capture instances: %v. is CDC enabled on the database?", err)
+	}
+	latest := make(map[string]*captureInstance)
+	var names []string
+	for rows.Next() {
+		ci := &captureInstance{}
+		if err := rows.Scan(&ci.Schema, &ci.Table, &ci.Name, &ci.ObjectID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !replicated(doDbs, ci.Schema, ci.Table) {
+			continue
+		}
+		key := ci.Schema + "." + ci.Table
+		if _, ok := latest[key]; !ok {
+			names = append(names, key)
+		}
+		latest[key] = ci
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	var instances []*captureInstance
+	for _, name := range names {
+		ci := latest[name]
+		if ci.Columns, err = capturedColumns(db, ci); err != nil {
+			return nil, err
+		}
+		instances = append(instances, ci)
+	}
+	return instances, nil
+}
+
+func replicated(doDbs []*config.DataSource, schema, table string) bool {
+	if len(doDbs) == 0 {
+		return true
+	}
+	for _, doDb := range doDbs {
+		if doDb.TableSchema != schema {
+			continue
+		}
+		if len(doDb.Tables) == 0 {
+			return true
+		}
+		for _, t := range doDb.Tables {
+			if t.TableName == table {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func capturedColumns(db *gosql.DB, ci *captureInstance) ([]*column, error) {
+	rows, err := db.Query(`SELECT c.COLUMN_NAME, c.DATA_TYPE,
+		ISNULL(c.CHARACTER_MAXIMUM_LENGTH, 0), ISNULL(c.NUMERIC_PRECISION, 0),
+		ISNULL(c.NUMERIC_SCALE, ISNULL(c.DATETIME_PRECISION, 0)), c.IS_NULLABLE,
+		CASE WHEN k.COLUMN_NAME IS NULL THEN 0 ELSE 1 END
+		FROM cdc.captured_columns cc
+		JOIN INFORMATION_SCHEMA.COLUMNS c
+			ON c.TABLE_SCHEMA = @p1 AND c.TABLE_NAME = @p2 AND c.COLUMN_NAME = cc.column_name
+		LEFT JOIN (SELECT kcu.COLUMN_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+			JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
+				ON kcu.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+			WHERE tc.CONSTRAINT_TYPE = 'PRIMARY KEY' AND tc.TABLE_SCHEMA = @p1 AND tc.TABLE_NAME = @p2) k
+			ON k.COLUMN_NAME = c.COLUMN_NAME
+		WHERE cc.object_id = @p3 ORDER BY cc.column_ordinal`, ci.Schema, ci.Table, ci.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*column
+	for rows.Next() {
+		c := &column{}
+		var nullable string
+		if err := rows.Scan(&c.Name, &c.DataType, &c.Length, &c.Precision, &c.Scale, &nullable, &c.Key); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no captured column of %v", ci)
+	}
+	return columns, nil
+}
+
+// maxLSN returns the LSN of the last change in the change tables.
+func (e *Extractor) maxLSN() (lsn, error) {
+	var max []byte
+	if err := e.db.QueryRow("SELECT sys.fn_cdc_get_max_lsn()").Scan(&max); err != nil {
+		return nil, err
+	}
+	if len(max) != lsnSize {
+		return nil, fmt.Errorf("no max LSN of CDC. is the capture job of SQL Server Agent running?")
+	}
+	return lsn(max), nil
+}
+
+// fromLSN returns the first LSN to read from the change table after the LSN,
+// which is not less than the low endpoint of the capture instance.
+func (e *Extractor) fromLSN(ci *captureInstance, after lsn) (lsn, error) {
+	from := make(lsn, lsnSize)
+	copy(from, after)
+	for i := lsnSize - 1; i >= 0; i-- {
+		from[i]++
+		if from[i] != 0 {
+			break
+		}
+	}
+
+	var min []byte
+	if err := e.db.QueryRow("SELECT sys.fn_cdc_get_min_lsn(@p1)", ci.Name).Scan(&min); err != nil {
+		return nil, err
+	}
+	if from.less(min) {
+		e.logger.Warnf("sqlserver.extractor: The changes of %v before %v are cleaned up, reading from %v",
+			ci, from, lsn(min))
+		return min, nil
+	}
+	return from, nil
+}
+
+func (e *Extractor) changesFunction(ci *captureInstance) string {
+	return "cdc." + quoteName("fn_cdc_get_all_changes_"+ci.Name)
+}
+
+// bound returns the LSN to read the changes up to, so that about ChunkSize
+// changes are read from each change table.
+func (e *Extractor) bound(after, max lsn) (lsn, error) {
+	to := max
+	for _, ci := range e.instances {
+		from, err := e.fromLSN(ci, after)
+		if err != nil {
+			return nil, err
+		}
+		if to.less(from) {
+			continue
+		}
+		var count int
+		var last []byte
+		err = e.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*), MAX(l) FROM (SELECT TOP (@p3) __$start_lsn AS l
+			FROM %s(@p1, @p2, N'all') ORDER BY __$start_lsn, __$seqval) t`, e.changesFunction(ci)),
+			[]byte(from), []byte(to), e.cfg.ChunkSize).Scan(&count, &last)
+		if err != nil {
+			return nil, fmt.Errorf("read changes of %v: %v", ci, err)
+		}
+		// the transaction of the last LSN is read as a whole
+		if count == e.cfg.ChunkSize && lsn(last).less(to) {
+			to = last
+		}
+	}
+	return to, nil
+}
+
+// readChanges returns the changes after the LSN and up to the other, in order.
+func (e *Extractor) readChanges(after, to lsn) ([]*change, error) {
+	var changes []*change
+	for _, ci := range e.instances {
+		from, err := e.fromLSN(ci, after)
+		if err != nil {
+			return nil, err
+		}
+		if to.less(from) {
+			continue
+		}
+		var names []string
+		for _, c := range ci.Columns {
+			names = append(names, quoteName(c.Name))
+		}
+		rows, err := e.db.Query(fmt.Sprintf(`SELECT __$start_lsn, __$seqval, __$operation, %s
+			FROM %s(@p1, @p2, N'all update old') ORDER BY __$start_lsn, __$seqval, __$operation`,
+			strings.Join(names, ", "), e.changesFunction(ci)), []byte(from), []byte(to))
+		if err != nil {
+			return nil, fmt.Errorf("read changes of %v: %v", ci, err)
+		}
+		for rows.Next() {
+			c := &change{instance: ci, table: e.tables[ci]}
+			values := make([]interface{}, len(ci.Columns))
+			dest := []interface{}{&c.startLSN, &c.seqval, &c.operation}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if c.values, err = convertValues(ci, values); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			changes = append(changes, c)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].less(changes[j])
+	})
+	return changes, nil
+}
+
+func convertValues(ci *captureInstance, values []interface{}) ([]interface{}, error) {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		var err error
+		if result[i], err = ci.Columns[i].convertValue(v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// dumpStatResult is the message of the end of the full copy. It is that of
+// the extractor of MySQL.
+type dumpStatResult struct {
+	Gtid       string
+	TotalCount int64
+}
+
+func (e *Extractor) fullCopy() error {
+	e.stage = models.StageSendingBinlogEventToSlave
+	var total int64
+	for _, ci := range e.instances {
+		n, err := e.copyTable(ci)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+
+	gtid := checkpoint(e.sid, 0)
+	if err := e.publish(fmt.Sprintf("%s_full_complete", e.subject), &dumpStatResult{Gtid: gtid, TotalCount: total}); err != nil {
+		return err
+	}
+	e.cfg.Gtid = gtid
+	e.logger.Printf("sqlserver.extractor: Copied %v rows of %v tables", total, len(e.instances))
+	return nil
+}
+
+// copyTable sends the statements creating the table and then the rows,
+// ChunkSize in a message.
+func (e *Extractor) copyTable(ci *captureInstance) (int64, error) {
+	subject := fmt.Sprintf("%s_full", e.subject)
+	table := e.tables[ci]
+	var count int64
+	err := e.db.QueryRow(fmt.Sprintf("SELECT COUNT_BIG(*) FROM %s.%s", quoteName(ci.Schema), quoteName(ci.Table))).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	err = e.publish(subject, &mysqlDriver.DumpEntry{
+		DbSQL:       createDatabaseQuery(ci.Schema),
+		TbSQL:       []string{createTableQuery(ci)},
+		TableSchema: ci.Schema,
+		TableName:   ci.Table,
+		Table:       table,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var names []string
+	for _, c := range ci.Columns {
+		names = append(names, quoteName(c.Name))
+	}
+	rows, err := e.db.Query(fmt.Sprintf("SELECT %s FROM %s.%s",
+		strings.Join(names, ", "), quoteName(ci.Schema), quoteName(ci.Table)))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	newEntry := func() *mysqlDriver.DumpEntry {
+		return &mysqlDriver.DumpEntry{
+			TableSchema: ci.Schema,
+			TableName:   ci.Table,
+			Table:       table,
+			TotalCount:  count,
+		}
+	}
+	entry := newEntry()
+	var copied int64
+	values := make([]interface{}, len(ci.Columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		converted, err := convertValues(ci, values)
+		if err != nil {
+			return 0, err
+		}
+		row := make([]*interface{}, len(converted))
+		for i := range converted {
+			v := dumpValue(converted[i])
+			row[i] = &v
+		}
+		entry.ValuesX = append(entry.ValuesX, row)
+		entry.RowsCount++
+
+		if len(entry.ValuesX) >= e.cfg.ChunkSize {
+			if err := e.publish(subject, entry); err != nil {
+				return 0, err
+			}
+			copied += entry.RowsCount
+			atomic.AddInt64(&e.rowsCopied, entry.RowsCount)
+			entry = newEntry()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(entry.ValuesX) > 0 {
+		if err := e.publish(subject, entry); err != nil {
+			return 0, err
+		}
+		copied += entry.RowsCount
+		atomic.AddInt64(&e.rowsCopied, entry.RowsCount)
+	}
+	e.logger.Debugf("sqlserver.extractor: Copied %v rows of %v", copied, ci)
+	return copied, nil
+}
+
+// pollChanges polls the changes after the epoch, skipping the first skip
+// transactions, which are already applied. The changes of a transaction, of
+// the same commit LSN, are sent as one entry.
+func (e *Extractor) pollChanges(skip int64) error {
+	last := e.epoch
+	var entries []*binlog.BinlogEntry
+	size := 0
+
+	send := func() error {
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := e.publish(fmt.Sprintf("%s_incr_hete", e.subject), &binlog.BinlogEntries{Entries: entries}); err != nil {
+			return err
+		}
+		lastEntry := entries[len(entries)-1].Coordinates
+		e.cfg.Gtid = checkpoint(lastEntry.SID, lastEntry.GNO)
+		atomic.AddInt64(&e.txSent, int64(len(entries)))
+		entries = nil
+		size = 0
+		return nil
+	}
+	addTx := func(changes []*change) error {
+		e.gno++
+		if e.gno <= skip {
+			return nil
+		}
+		entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{SID: e.sid, GNO: e.gno})
+		events, err := dataEvents(changes)
+		if err != nil {
+			return err
+		}
+		entry.Events = events
+		for _, c := range changes {
+			for _, v := range c.values {
+				switch v := v.(type) {
+				case string:
+					entry.OriginalSize += len(v)
+				case []byte:
+					entry.OriginalSize += len(v)
+				default:
+					entry.OriginalSize += 8
+				}
+			}
+		}
+		entries = append(entries, entry)
+		size += entry.OriginalSize
+		if size >= e.maxPayload/2 {
+			return send()
+		}
+		return nil
+	}
+
+	for !e.shutdown {
+		max, err := e.maxLSN()
+		if err != nil {
+			return err
+		}
+		if !last.less(max) {
+			if e.gno >= skip && time.Since(e.epochBegan) >= epochDuration {
+				// everything up to the last LSN is sent
+				e.epoch, e.sid, e.gno, skip = last, epochSID(last), 0, 0
+				e.epochBegan = time.Now()
+				e.cfg.Gtid = checkpoint(e.sid, 0)
+				e.logger.Printf("sqlserver.extractor: Begin epoch %v", e.sid)
+			}
+			select {
+			case <-e.shutdownCh:
+			case <-time.After(time.Duration(e.cfg.PollIntervalMs) * time.Millisecond):
+			}
+			continue
+		}
+
+		to, err := e.bound(last, max)
+		if err != nil {
+			return err
+		}
+		changes, err := e.readChanges(last, to)
+		if err != nil {
+			return err
+		}
+		for _, tx := range transactions(changes) {
+			if err := addTx(tx); err != nil {
+				return err
+			}
+		}
+		if err := send(); err != nil {
+			return err
+		}
+		last = to
+	}
+	return nil
+}
+
+func (e *Extractor) ID() string {
+	id := config.DriverCtx{
+		DriverConfig: &config.MySQLDriverConfig{
+			Gtid:     e.cfg.Gtid,
+			NatsAddr: e.cfg.NatsAddr,
+		},
+	}
+
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("sqlserver.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	return &models.TaskStatistics{
+		CurrentCoordinates: &models.CurrentCoordinates{
+			RetrievedGtidSet: e.cfg.Gtid,
+		},
+		ExecMasterRowCount: atomic.LoadInt64(&e.rowsCopied),
+		ExecMasterTxCount:  atomic.LoadInt64(&e.txSent),
+		Stage:              e.stage,
+		TransportStat:      e.transport.Stat(e.natsConn, nil),
+		Timestamp:          time.Now().UTC().UnixNano(),
+	}, nil
+}
+
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+
+	if e.natsConn != nil {
+		e.natsConn.Close()
+	}
+	if e.db != nil {
+		e.db.Close()
+	}
+
+	e.logger.Printf("sqlserver.extractor: Shutting down")
+	return nil
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("sqlserver.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/satori/go.uuid"
+)
+
+// The position in the change tables is given to the applier as a gtid, so
+// that it is checkpointed and deduplicated the same as that of MySQL.
+//
+// The transactions are numbered in an epoch, which begins after an LSN, and
+// the SID of the epoch is made of the LSN. The transaction of gno N in the
+// epoch is the Nth commit LSN after the LSN in the change tables of the
+// replicated tables, so the changes are resumed from a gtid by reading after
+// the LSN and skipping N transactions. A new epoch is begun each
+// epochDuration, so that the LSN of a checkpoint is not older than the
+// retention of the change tables.
+
+// lsnSize is the size of an LSN, binary(10).
+const lsnSize = 10
+
+// epochMarker is the rest of the SID of an epoch.
+var epochMarker = []byte("mssql\x00")
+
+// lsn is a log sequence number of SQL Server, ordered as bytes.
+type lsn []byte
+
+func (l lsn) String() string {
+	return "0x" + strings.ToUpper(hex.EncodeToString(l))
+}
+
+func (l lsn) less(other lsn) bool {
+	return bytes.Compare(l, other) < 0
+}
+
+// epochSID returns the SID of the epoch beginning after the LSN.
+func epochSID(after lsn) uuid.UUID {
+	var sid uuid.UUID
+	copy(sid[:lsnSize], after)
+	copy(sid[lsnSize:], epochMarker)
+	return sid
+}
+
+// checkpoint returns the gtid of the transaction of gno in the epoch. A gno
+// of 0 is the beginning of the epoch, before any transaction.
+func checkpoint(sid uuid.UUID, gno int64) string {
+	if gno == 0 {
+		return sid.String()
+	}
+	return fmt.Sprintf("%s:1-%d", sid, gno)
+}
+
+// parseCheckpoint returns the LSN the epoch begins after and the gno of the
+// gtid returned by checkpoint.
+func parseCheckpoint(gtid string) (lsn, int64, error) {
+	parts := strings.Split(strings.TrimSpace(gtid), ":")
+	sid, err := uuid.FromString(parts[0])
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid SQL Server position %q: %v", gtid, err)
+	}
+	if !bytes.Equal(sid[lsnSize:], epochMarker) {
+		return nil, 0, fmt.Errorf("invalid SQL Server position %q: not an LSN epoch", gtid)
+	}
+	after := make(lsn, lsnSize)
+	copy(after, sid[:lsnSize])
+
+	var gno int64
+	switch len(parts) {
+	case 1:
+	case 2:
+		interval := parts[1]
+		if i := strings.LastIndexByte(interval, '-'); i >= 0 {
+			interval = interval[i+1:]
+		}
+		if gno, err = strconv.ParseInt(interval, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("invalid SQL Server position %q: %v", gtid, err)
+		}
+	default:
+		return nil, 0, fmt.Errorf("invalid SQL Server position %q", gtid)
+	}
+	return after, gno, nil
+}
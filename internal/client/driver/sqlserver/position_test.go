@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	after := lsn{0, 0, 0, 0x2a, 0, 0, 0x01, 0x90, 0, 0x03}
+	sid := epochSID(after)
+
+	for _, gno := range []int64{0, 1, 123456} {
+		gtid := checkpoint(sid, gno)
+		gotLSN, gotGno, err := parseCheckpoint(gtid)
+		if err != nil {
+			t.Fatalf("parseCheckpoint(%v) error = %v", gtid, err)
+		}
+		if !bytes.Equal(gotLSN, after) || gotGno != gno {
+			t.Errorf("parseCheckpoint(%v) = %v, %v, want %v, %v", gtid, gotLSN, gotGno, after, gno)
+		}
+	}
+}
+
+func TestParseCheckpoint_Invalid(t *testing.T) {
+	for _, gtid := range []string{
+		"not-a-uuid",
+		"a6c63e32-f5a0-11e8-9f7d-0242ac110002:1-10", // a gtid of MySQL
+		checkpoint(epochSID(make(lsn, lsnSize)), 1) + ":2",
+	} {
+		if _, _, err := parseCheckpoint(gtid); err == nil {
+			t.Errorf("parseCheckpoint(%v) expected an error", gtid)
+		}
+	}
+}
+
+func TestLSN(t *testing.T) {
+	a := lsn{0, 0, 0, 0x2a, 0, 0, 0x01, 0x90, 0, 0x03}
+	b := lsn{0, 0, 0, 0x2a, 0, 0, 0x01, 0x90, 0x01, 0x00}
+	if !a.less(b) || b.less(a) || a.less(a) {
+		t.Errorf("less() of %v and %v is wrong", a, b)
+	}
+	if got, want := a.String(), "0x0000002A000001900003"; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+}
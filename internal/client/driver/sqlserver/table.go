@@ -0,0 +1,337 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// A table of a schema is replicated as the table of the same name in the
+// database of the same name as the schema, with the captured columns of the
+// capture instance.
+
+// column is a captured column, as in INFORMATION_SCHEMA.COLUMNS.
+type column struct {
+	Name      string
+	DataType  string
+	Length    int // -1 for max
+	Precision int
+	Scale     int
+	Nullable  bool
+	Key       bool
+}
+
+// captureInstance is the latest capture instance of a replicated table.
+type captureInstance struct {
+	Name     string
+	Schema   string
+	Table    string
+	ObjectID int64
+	Columns  []*column
+}
+
+func (c *captureInstance) String() string {
+	return fmt.Sprintf("%s.%s(%s)", c.Schema, c.Table, c.Name)
+}
+
+// mysqlType returns the MySQL type of the column.
+func (c *column) mysqlType() string {
+	switch c.DataType {
+	case "bit":
+		return "tinyint(1)"
+	case "tinyint":
+		return "tinyint unsigned"
+	case "smallint", "int", "bigint", "date":
+		return c.DataType
+	case "decimal", "numeric":
+		return fmt.Sprintf("decimal(%d,%d)", c.Precision, c.Scale)
+	case "money":
+		return "decimal(19,4)"
+	case "smallmoney":
+		return "decimal(10,4)"
+	case "float":
+		return "double"
+	case "real":
+		return "float"
+	case "time", "datetime2":
+		fsp := c.Scale
+		if fsp > 6 {
+			fsp = 6
+		}
+		if c.DataType == "datetime2" {
+			return fmt.Sprintf("datetime(%d)", fsp)
+		}
+		return fmt.Sprintf("time(%d)", fsp)
+	case "datetime":
+		return "datetime(3)"
+	case "smalldatetime":
+		return "datetime"
+	case "datetimeoffset":
+		return "varchar(34)"
+	case "char", "nchar":
+		if c.Length > 0 && c.Length <= 255 {
+			return fmt.Sprintf("char(%d)", c.Length)
+		}
+		return "longtext"
+	case "varchar", "nvarchar":
+		if c.Length > 0 && c.Length <= 16383 {
+			return fmt.Sprintf("varchar(%d)", c.Length)
+		}
+		return "longtext"
+	case "text", "ntext", "xml":
+		return "longtext"
+	case "binary":
+		if c.Length > 0 && c.Length <= 255 {
+			return fmt.Sprintf("binary(%d)", c.Length)
+		}
+		return "longblob"
+	case "varbinary":
+		if c.Length > 0 && c.Length <= 65535 {
+			return fmt.Sprintf("varbinary(%d)", c.Length)
+		}
+		return "longblob"
+	case "timestamp", "rowversion":
+		return "binary(8)"
+	case "uniqueidentifier":
+		return "char(36)"
+	default:
+		// image, geometry, geography, hierarchyid, sql_variant
+		return "longblob"
+	}
+}
+
+// columnType returns the type of the column as that of MySQL.
+func (c *column) columnType() mysql.ColumnType {
+	switch t := c.mysqlType(); {
+	case strings.HasPrefix(t, "tinyint"):
+		return mysql.TinyintColumnType
+	case t == "smallint":
+		return mysql.SmallintColumnType
+	case t == "int":
+		return mysql.IntColumnType
+	case t == "bigint":
+		return mysql.BigIntColumnType
+	case strings.HasPrefix(t, "decimal"):
+		return mysql.DecimalColumnType
+	case t == "double":
+		return mysql.DoubleColumnType
+	case t == "float":
+		return mysql.FloatColumnType
+	case t == "date":
+		return mysql.DateColumnType
+	case strings.HasPrefix(t, "time"):
+		return mysql.TimeColumnType
+	case strings.HasPrefix(t, "datetime"):
+		return mysql.DateTimeColumnType
+	case strings.HasPrefix(t, "char"):
+		return mysql.CharColumnType
+	case strings.HasPrefix(t, "varchar"):
+		return mysql.VarcharColumnType
+	case t == "longtext":
+		return mysql.TextColumnType
+	case strings.HasPrefix(t, "binary"):
+		return mysql.BinaryColumnType
+	case strings.HasPrefix(t, "varbinary"):
+		return mysql.VarbinaryColumnType
+	default:
+		return mysql.BlobColumnType
+	}
+}
+
+// newTable returns the table of the capture instance.
+func newTable(ci *captureInstance) *config.Table {
+	table := config.NewTable(ci.Schema, ci.Table)
+	var columns []mysql.Column
+	for _, c := range ci.Columns {
+		col := mysql.Column{
+			Name:       c.Name,
+			Type:       c.columnType(),
+			ColumnType: c.mysqlType(),
+			Nullable:   c.Nullable,
+			IsUnsigned: c.DataType == "tinyint",
+			Precision:  c.Precision,
+			Scale:      c.Scale,
+		}
+		if c.Key {
+			col.Key = "PRI"
+		}
+		columns = append(columns, col)
+	}
+	table.OriginalTableColumns = mysql.NewColumnList(columns)
+	return table
+}
+
+func createDatabaseQuery(schema string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", usql.EscapeName(schema))
+}
+
+func createTableQuery(ci *captureInstance) string {
+	var defs, keys []string
+	for _, c := range ci.Columns {
+		def := fmt.Sprintf("%s %s", usql.EscapeName(c.Name), c.mysqlType())
+		if !c.Nullable {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+		if c.Key {
+			keys = append(keys, usql.EscapeName(c.Name))
+		}
+	}
+	if len(keys) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)",
+		usql.EscapeName(ci.Schema), usql.EscapeName(ci.Table), strings.Join(defs, ", "))
+}
+
+// quoteName quotes a name of SQL Server.
+func quoteName(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
+
+// convertValue converts a value read from SQL Server to one for MySQL, of
+// nil, int64, float64, string or []byte.
+func (c *column) convertValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, int64, float64, string:
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		switch c.DataType {
+		case "date":
+			return v.Format("2006-01-02"), nil
+		case "time":
+			return v.Format("15:04:05.999999"), nil
+		case "datetimeoffset":
+			return v.Format("2006-01-02 15:04:05.9999999 -07:00"), nil
+		default:
+			return v.Format("2006-01-02 15:04:05.999999"), nil
+		}
+	case []byte:
+		switch c.DataType {
+		case "uniqueidentifier":
+			var id mssql.UniqueIdentifier
+			if err := id.Scan(v); err != nil {
+				return nil, err
+			}
+			return id.String(), nil
+		case "decimal", "numeric", "money", "smallmoney":
+			return string(v), nil
+		}
+		// the buffer is reused by the next row
+		return append([]byte(nil), v...), nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v (%T) of column %v", v, v, c.Name)
+	}
+}
+
+// dumpValue returns the text of a converted value, as in a DumpEntry.
+func dumpValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		return []byte(v)
+	default:
+		return v
+	}
+}
+
+// Operations of the rows of a change table.
+const (
+	opDelete       = 1
+	opInsert       = 2
+	opUpdateBefore = 3
+	opUpdateAfter  = 4
+)
+
+// change is a row of a change table, with the values converted.
+type change struct {
+	instance  *captureInstance
+	table     *config.Table
+	startLSN  lsn
+	seqval    []byte
+	operation int
+	values    []interface{}
+}
+
+// less orders the changes of the change tables as they are committed.
+func (c *change) less(other *change) bool {
+	if r := bytes.Compare(c.startLSN, other.startLSN); r != 0 {
+		return r < 0
+	}
+	if r := bytes.Compare(c.seqval, other.seqval); r != 0 {
+		return r < 0
+	}
+	return c.operation < other.operation
+}
+
+// transactions splits the ordered changes by the commit LSN.
+func transactions(changes []*change) [][]*change {
+	var txs [][]*change
+	begin := 0
+	for i := 1; i <= len(changes); i++ {
+		if i < len(changes) && bytes.Equal(changes[i].startLSN, changes[begin].startLSN) {
+			continue
+		}
+		txs = append(txs, changes[begin:i])
+		begin = i
+	}
+	return txs
+}
+
+// dataEvents returns the events of the changes of a transaction, in order.
+// An update is the before image followed by the after image.
+func dataEvents(changes []*change) ([]binlog.DataEvent, error) {
+	var events []binlog.DataEvent
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		ci := c.instance
+		n := len(ci.Columns)
+		var event binlog.DataEvent
+		switch c.operation {
+		case opDelete:
+			event = binlog.NewDataEvent(ci.Schema, ci.Table, binlog.DeleteDML, n)
+			event.WhereColumnValues = mysql.ToColumnValues(c.values)
+		case opInsert:
+			event = binlog.NewDataEvent(ci.Schema, ci.Table, binlog.InsertDML, n)
+			event.NewColumnValues = mysql.ToColumnValues(c.values)
+		case opUpdateBefore:
+			if i+1 == len(changes) || changes[i+1].operation != opUpdateAfter || changes[i+1].instance != ci {
+				return nil, fmt.Errorf("no after image of the update of %v at %v", ci, c.startLSN)
+			}
+			i++
+			event = binlog.NewDataEvent(ci.Schema, ci.Table, binlog.UpdateDML, n)
+			event.WhereColumnValues = mysql.ToColumnValues(c.values)
+			event.NewColumnValues = mysql.ToColumnValues(changes[i].values)
+		default:
+			return nil, fmt.Errorf("unexpected operation %v of %v at %v", c.operation, ci, c.startLSN)
+		}
+		event.Table = c.table
+		events = append(events, event)
+	}
+	return events, nil
+}
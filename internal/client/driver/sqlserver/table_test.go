@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+)
+
+func TestCreateTableQuery(t *testing.T) {
+	ci := &captureInstance{
+		Name:   "dbo_orders",
+		Schema: "dbo",
+		Table:  "orders",
+		Columns: []*column{
+			{Name: "id", DataType: "int", Key: true},
+			{Name: "code", DataType: "uniqueidentifier"},
+			{Name: "name", DataType: "nvarchar", Length: 50, Nullable: true},
+			{Name: "note", DataType: "nvarchar", Length: -1, Nullable: true},
+			{Name: "amount", DataType: "decimal", Precision: 10, Scale: 2, Nullable: true},
+			{Name: "created", DataType: "datetime2", Scale: 7, Nullable: true},
+		},
+	}
+	want := "CREATE TABLE IF NOT EXISTS `dbo`.`orders` (`id` int NOT NULL, `code` char(36) NOT NULL, " +
+		"`name` varchar(50), `note` longtext, `amount` decimal(10,2), `created` datetime(6), PRIMARY KEY (`id`))"
+	if got := createTableQuery(ci); got != want {
+		t.Errorf("createTableQuery() = %v, want %v", got, want)
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	ts := time.Date(2018, 7, 1, 12, 30, 45, 123456700, time.UTC)
+	tests := []struct {
+		dataType string
+		v        interface{}
+		want     interface{}
+	}{
+		{"int", int64(3), int64(3)},
+		{"bit", true, int64(1)},
+		{"decimal", []byte("12.50"), "12.50"},
+		{"date", ts, "2018-07-01"},
+		{"datetime2", ts, "2018-07-01 12:30:45.123456"},
+		{"uniqueidentifier",
+			[]byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+			"12345678-1234-5678-1234-56789ABCDEF0"},
+		{"varbinary", []byte{1, 2}, []byte{1, 2}},
+		{"nvarchar", nil, nil},
+	}
+	for _, tt := range tests {
+		c := &column{Name: "c", DataType: tt.dataType}
+		got, err := c.convertValue(tt.v)
+		if err != nil {
+			t.Fatalf("convertValue(%v) of %v error = %v", tt.v, tt.dataType, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(%v) of %v = %#v, want %#v", tt.v, tt.dataType, got, tt.want)
+		}
+	}
+}
+
+func TestDataEvents(t *testing.T) {
+	ci := &captureInstance{Name: "dbo_t", Schema: "dbo", Table: "t",
+		Columns: []*column{{Name: "id", DataType: "int", Key: true}, {Name: "v", DataType: "int"}}}
+	lsn1 := lsn{0, 0, 0, 1, 0, 0, 0, 1, 0, 1}
+	lsn2 := lsn{0, 0, 0, 1, 0, 0, 0, 2, 0, 1}
+	changes := []*change{
+		{instance: ci, startLSN: lsn1, seqval: []byte{1}, operation: opInsert, values: []interface{}{int64(1), int64(1)}},
+		{instance: ci, startLSN: lsn1, seqval: []byte{2}, operation: opUpdateBefore, values: []interface{}{int64(1), int64(1)}},
+		{instance: ci, startLSN: lsn1, seqval: []byte{2}, operation: opUpdateAfter, values: []interface{}{int64(1), int64(2)}},
+		{instance: ci, startLSN: lsn2, seqval: []byte{1}, operation: opDelete, values: []interface{}{int64(1), int64(2)}},
+	}
+
+	txs := transactions(changes)
+	if len(txs) != 2 || len(txs[0]) != 3 || len(txs[1]) != 1 {
+		t.Fatalf("transactions() = %v", txs)
+	}
+	events, err := dataEvents(txs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].DML != binlog.InsertDML || events[1].DML != binlog.UpdateDML {
+		t.Fatalf("dataEvents() = %v", events)
+	}
+	if got := *events[1].NewColumnValues.GetAbstractValues()[1]; got != int64(2) {
+		t.Errorf("the new value of the update = %v, want 2", got)
+	}
+
+	if _, err := dataEvents(changes[1:2]); err == nil {
+		t.Errorf("dataEvents() of an update without after image expected an error")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package stats
+
+import (
+	"syscall"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// diskStats returns the usage of the file system of path.
+func diskStats(path string) (*models.HostDiskStats, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return nil, err
+	}
+	ds := &models.HostDiskStats{
+		Path:      path,
+		Size:      fs.Blocks * uint64(fs.Bsize),
+		Available: fs.Bavail * uint64(fs.Bsize),
+	}
+	ds.Used = ds.Size - fs.Bfree*uint64(fs.Bsize)
+	if ds.Used+ds.Available > 0 {
+		ds.UsedPercent = float64(ds.Used) / float64(ds.Used+ds.Available) * 100
+	}
+	return ds, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package stats
+
+import (
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// diskStats returns no usage, the disk is only collected on Linux.
+func diskStats(path string) (*models.HostDiskStats, error) {
+	return nil, nil
+}
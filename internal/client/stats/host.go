@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package stats collects the resource usage of the host of an agent, and of
+// the processes of its tasks.
+package stats
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// HostStatsCollector collects the resource usage of the host and of the
+// processes of the tasks. The CPU usage is the one since the previous
+// collection, since boot or the start of a process for the first one.
+type HostStatsCollector struct {
+	// The path whose disk is collected, e.g. the AllocDir.
+	diskPath string
+
+	lock      sync.Mutex
+	lastCPU   *cpu.TimesStat
+	processes map[int]*process.Process
+}
+
+// NewHostStatsCollector returns a HostStatsCollector of the disk of diskPath.
+func NewHostStatsCollector(diskPath string) *HostStatsCollector {
+	return &HostStatsCollector{
+		diskPath:  diskPath,
+		processes: make(map[int]*process.Process),
+	}
+}
+
+// Collect returns the resource usage of the host.
+func (h *HostStatsCollector) Collect() (*models.HostStats, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	hs := &models.HostStats{Timestamp: time.Now().UnixNano()}
+	var err error
+	if hs.Uptime, err = host.Uptime(); err != nil {
+		return nil, fmt.Errorf("collecting uptime: %v", err)
+	}
+	if hs.CPU, err = h.collectCPU(); err != nil {
+		return nil, fmt.Errorf("collecting cpu: %v", err)
+	}
+
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, fmt.Errorf("collecting memory: %v", err)
+	}
+	hs.Memory = &models.HostMemoryStats{
+		Total:     vm.Total,
+		Available: vm.Available,
+		Used:      vm.Used,
+		Free:      vm.Free,
+	}
+
+	if h.diskPath != "" {
+		if hs.Disk, err = diskStats(h.diskPath); err != nil {
+			return nil, fmt.Errorf("collecting disk of %v: %v", h.diskPath, err)
+		}
+	}
+
+	counters, err := net.IOCounters(false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting network: %v", err)
+	}
+	hs.Network = &models.HostNetworkStats{}
+	for _, c := range counters {
+		hs.Network.BytesSent += c.BytesSent
+		hs.Network.BytesRecv += c.BytesRecv
+		hs.Network.PacketsSent += c.PacketsSent
+		hs.Network.PacketsRecv += c.PacketsRecv
+	}
+	return hs, nil
+}
+
+func (h *HostStatsCollector) collectCPU() (*models.HostCPUStats, error) {
+	times, err := cpu.Times(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("no cpu times")
+	}
+	last := h.lastCPU
+	if last == nil {
+		last = &cpu.TimesStat{}
+	}
+	h.lastCPU = &times[0]
+	return &models.HostCPUStats{
+		Cores:   runtime.NumCPU(),
+		Percent: busyPercent(*last, times[0]),
+	}, nil
+}
+
+// busyPercent returns the percentage of the time the CPU was busy between the
+// times t1 and t2.
+func busyPercent(t1, t2 cpu.TimesStat) float64 {
+	all := t2.Total() - t1.Total()
+	idle := (t2.Idle + t2.Iowait) - (t1.Idle + t1.Iowait)
+	if all <= 0 {
+		return 0
+	}
+	return (all - idle) / all * 100
+}
+
+// ProcessesUsage returns the resource usage of the processes of pids. The
+// processes collected before but not in pids are forgotten. A process failed to
+// be collected, e.g. which exited, is not in the result.
+func (h *HostStatsCollector) ProcessesUsage(pids []int) map[int]*models.TaskResourceUsage {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	usages := make(map[int]*models.TaskResourceUsage, len(pids))
+	processes := make(map[int]*process.Process, len(pids))
+	for _, pid := range pids {
+		p, ok := h.processes[pid]
+		if !ok {
+			var err error
+			if p, err = process.NewProcess(int32(pid)); err != nil {
+				continue
+			}
+		}
+		processes[pid] = p
+
+		usage := &models.TaskResourceUsage{Pid: pid}
+		if ok {
+			usage.CPUPercent, _ = p.Percent(0)
+		} else {
+			// The usage since the start of the process. Percent(0) only starts
+			// the measurement.
+			usage.CPUPercent, _ = p.CPUPercent()
+			p.Percent(0)
+		}
+		if mi, err := p.MemoryInfo(); err == nil {
+			usage.MemoryRSS = mi.RSS
+		}
+		usages[pid] = usage
+	}
+	h.processes = processes
+	return usages
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package stats
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+func TestHostStatsCollector_Collect(t *testing.T) {
+	h := NewHostStatsCollector(os.TempDir())
+	for i := 0; i < 2; i++ {
+		hs, err := h.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hs.CPU.Cores == 0 || hs.CPU.Percent < 0 || hs.CPU.Percent > 100 {
+			t.Errorf("bad cpu %+v", hs.CPU)
+		}
+		if hs.Memory.Total == 0 || hs.Memory.Used > hs.Memory.Total {
+			t.Errorf("bad memory %+v", hs.Memory)
+		}
+		if hs.Disk != nil && (hs.Disk.Size == 0 || hs.Disk.Used > hs.Disk.Size) {
+			t.Errorf("bad disk %+v", hs.Disk)
+		}
+	}
+}
+
+func TestBusyPercent(t *testing.T) {
+	t1 := cpu.TimesStat{User: 10, System: 10, Idle: 70, Iowait: 10}
+	t2 := cpu.TimesStat{User: 30, System: 20, Idle: 120, Iowait: 30}
+	if p := busyPercent(t1, t2); p != 30 {
+		t.Errorf("got %v", p)
+	}
+	if p := busyPercent(t2, t2); p != 0 {
+		t.Errorf("got %v without time passed", p)
+	}
+}
+
+func TestHostStatsCollector_ProcessesUsage(t *testing.T) {
+	h := NewHostStatsCollector("")
+	pid := os.Getpid()
+	for i := 0; i < 2; i++ {
+		usages := h.ProcessesUsage([]int{pid, -1})
+		if len(usages) != 1 || usages[pid] == nil || usages[pid].MemoryRSS == 0 {
+			t.Fatalf("got %+v", usages)
+		}
+	}
+	h.ProcessesUsage(nil)
+	if len(h.processes) != 0 {
+		t.Errorf("processes not forgotten: %v", h.processes)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/tlsutil"
+)
+
+const (
+	// certRetryIntv is minimum interval on which we retry to get a
+	// certificate. We pick a value between this and 2x this.
+	certRetryIntv = 15 * time.Second
+
+	// tlsDir is the directory of the state dir keeping the certificate of the
+	// client, its key and the roots of the CA.
+	tlsDir = "tls"
+)
+
+// setupTLS loads the certificate of the client, and starts getting it signed
+// by the servers and renewing it. The RPC is made without TLS until the client
+// has a certificate. An agent also running a server has no RPC to secure.
+func (c *Client) setupTLS() error {
+	conf := c.config.TLSConfig
+	if conf == nil || !conf.EnableRPC || c.config.RPCHandler != nil {
+		return nil
+	}
+	if conf.BootstrapToken == "" {
+		return fmt.Errorf("tls bootstrap_token is needed to get a certificate")
+	}
+	if conf.CAFingerprint == "" {
+		return fmt.Errorf("tls ca_fingerprint is needed to verify the servers the bootstrap_token is given to")
+	}
+
+	c.tlsConfigurator = tlsutil.NewConfigurator(c.Region())
+	if err := c.loadCert(); err != nil {
+		c.logger.Warnf("agent: Ignoring the saved TLS certificate: %v", err)
+	}
+	c.connPool.SetTLSConfigurator(c.tlsConfigurator)
+
+	go c.renewCert()
+	go c.watchCARoots()
+	return nil
+}
+
+func (c *Client) tlsPath(name string) string {
+	return filepath.Join(c.config.StateDir, tlsDir, name)
+}
+
+// loadCert loads the certificate saved, unless it has expired.
+func (c *Client) loadCert() error {
+	cert, err := ioutil.ReadFile(c.tlsPath("cert.pem"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	key, err := ioutil.ReadFile(c.tlsPath("key.pem"))
+	if err != nil {
+		return err
+	}
+	roots, err := ioutil.ReadFile(c.tlsPath("ca.pem"))
+	if err != nil {
+		return err
+	}
+	leaf, err := tlsutil.ParseCertificate(string(cert))
+	if err != nil {
+		return err
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired at %v", leaf.NotAfter)
+	}
+	return c.tlsConfigurator.Update(string(cert), string(key), tlsutil.SplitCertificates(string(roots)))
+}
+
+// saveCert saves the certificate, its key and the roots, for the client to
+// restart with them.
+func (c *Client) saveCert(cert, key string, roots []string) error {
+	if err := os.MkdirAll(filepath.Join(c.config.StateDir, tlsDir), 0700); err != nil {
+		return err
+	}
+	files := map[string]string{
+		"cert.pem": cert,
+		"key.pem":  key,
+		"ca.pem":   strings.Join(roots, ""),
+	}
+	for name, content := range files {
+		if content == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(c.tlsPath(name), []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renewCert is a long lived goroutine getting the certificate of the client
+// signed, and renewing it at half of its life.
+func (c *Client) renewCert() {
+	for {
+		select {
+		case <-time.After(c.tlsConfigurator.RenewTime().Sub(time.Now())):
+		case <-c.shutdownCh:
+			return
+		}
+
+		for {
+			err := c.signCert()
+			if err == nil {
+				break
+			}
+			c.logger.Errorf("agent: Failed to get the TLS certificate signed: %v", err)
+			select {
+			case <-time.After(c.retryIntv(certRetryIntv)):
+			case <-c.shutdownCh:
+				return
+			}
+		}
+		c.logger.Printf("agent: Got the TLS certificate signed, renewing at %v",
+			c.tlsConfigurator.RenewTime().Format(time.RFC3339))
+	}
+}
+
+// signCert gets a certificate of a new key signed by the CA. It is requested
+// over the TLS of the current certificate, or over TLS without a certificate,
+// verifying the servers against the CA fingerprint, if there is none or it
+// expired.
+func (c *Client) signCert() error {
+	key, keyPEM, err := tlsutil.GenerateKey()
+	if err != nil {
+		return err
+	}
+	csr, err := tlsutil.CreateCSR(key, c.Node().ID+"."+tlsutil.ClientName(c.Region()))
+	if err != nil {
+		return err
+	}
+	conf := c.config.TLSConfig
+	args := models.CASignRequest{
+		CSR:            csr,
+		BootstrapToken: conf.BootstrapToken,
+		QueryOptions: models.QueryOptions{
+			Region:     c.Region(),
+			AllowStale: true,
+		},
+	}
+	var reply models.CASignResponse
+
+	bootstrap := !c.tlsConfigurator.Ready()
+	if !bootstrap {
+		if err := c.RPC("CA.Sign", &args, &reply); err != nil {
+			c.logger.Warnf("agent: Failed to renew the TLS certificate, getting a new one: %v", err)
+			bootstrap = true
+		}
+	}
+	if bootstrap {
+		servers := c.servers.all()
+		if len(servers) == 0 {
+			return noServersErr
+		}
+		config := tlsutil.BootstrapConfig(c.Region(), conf.CAFingerprint)
+		for _, s := range servers {
+			if err = c.connPool.BootstrapRPC(s.addr, config, "CA.Sign", &args, &reply); err == nil {
+				break
+			}
+			c.logger.Debugf("agent: Failed to get the TLS certificate from %s: %v", s.addr, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.tlsConfigurator.Update(reply.Certificate, keyPEM, reply.Roots); err != nil {
+		return err
+	}
+	if err := c.saveCert(reply.Certificate, keyPEM, reply.Roots); err != nil {
+		c.logger.Errorf("agent: Failed to save the TLS certificate: %v", err)
+	}
+	if bootstrap {
+		// Dial again with TLS.
+		c.connPool.Reset()
+	}
+	return nil
+}
+
+// watchCARoots is a long lived goroutine updating the roots of the CA trusted
+// when they are rotated, once the client has a certificate.
+func (c *Client) watchCARoots() {
+	var index uint64
+	for {
+		if c.tlsConfigurator.Ready() {
+			args := models.CARootsRequest{
+				QueryOptions: models.QueryOptions{
+					Region:        c.Region(),
+					AllowStale:    true,
+					MinQueryIndex: index,
+				},
+			}
+			var reply models.CARootsResponse
+			err := c.RPC("CA.Roots", &args, &reply)
+			if err == nil && reply.Index > index && len(reply.Roots) > 0 {
+				roots := make([]string, 0, len(reply.Roots))
+				for _, root := range reply.Roots {
+					roots = append(roots, root.Certificate)
+				}
+				if err = c.tlsConfigurator.UpdateRoots(roots); err == nil {
+					index = reply.Index
+					if err := c.saveCert("", "", roots); err != nil {
+						c.logger.Errorf("agent: Failed to save the CA roots: %v", err)
+					}
+					continue
+				}
+			}
+			if err == nil {
+				continue
+			}
+			c.logger.Debugf("agent: Failed to get the CA roots: %v", err)
+		}
+
+		select {
+		case <-time.After(c.retryIntv(certRetryIntv)):
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
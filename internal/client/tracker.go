@@ -21,13 +21,20 @@ const (
 	ReasonUnrecoverableErrror = "Error was unrecoverable"
 	ReasonWithinPolicy        = "Restart within policy"
 	ReasonDelay               = "Exceeded allowed attempts, applying a delay"
+	ReasonFail                = "Exceeded allowed attempts and the restart mode is fail"
 )
 
-func newRestartTracker() *RestartTracker {
+// newRestartTracker returns a tracker of the restarts of a task with the
+// restart policy of its job, or the default one if nil.
+func newRestartTracker(policy *models.RestartPolicy) *RestartTracker {
 	onSuccess := true
+	if policy == nil {
+		policy = models.DefaultRestartPolicy()
+	}
 	return &RestartTracker{
 		startTime: time.Now(),
 		onSuccess: onSuccess,
+		policy:    policy,
 		rand:      rand.New(rand.NewSource(time.Now().Unix())),
 	}
 }
@@ -40,6 +47,7 @@ type RestartTracker struct {
 	onSuccess        bool      // Whether to restart on successful exit code.
 	startTime        time.Time // When the interval began
 	reason           string    // The reason for the last store
+	policy           *models.RestartPolicy
 	rand             *rand.Rand
 	lock             sync.Mutex
 }
@@ -116,7 +124,7 @@ func (r *RestartTracker) GetState() (string, time.Duration) {
 	r.count++
 
 	// Check if we have entered a new interval.
-	end := r.startTime.Add(1 * time.Minute)
+	end := r.startTime.Add(r.getPolicy().Interval)
 	now := time.Now()
 	if now.After(end) {
 		r.count = 0
@@ -133,9 +141,8 @@ func (r *RestartTracker) GetState() (string, time.Duration) {
 }
 
 // handleStartError returns the new store and potential wait duration for
-// restarting the task after it was not successfully started. On start errors,
-// the restart policy is always treated as fail mode to ensure we don't
-// infinitely try to start a task.
+// restarting the task after it was not successfully started. The errors which
+// are not recoverable are never retried.
 func (r *RestartTracker) handleStartError() (string, time.Duration) {
 	// If the error is not recoverable, do not restart.
 	if !models.IsRecoverable(r.startErr) {
@@ -143,13 +150,7 @@ func (r *RestartTracker) handleStartError() (string, time.Duration) {
 		return models.TaskNotRestarting, 0
 	}
 
-	if r.count > 5 {
-		r.reason = ReasonDelay
-		return models.TaskRestarting, r.getDelay()
-	}
-
-	r.reason = ReasonWithinPolicy
-	return models.TaskRestarting, r.jitter()
+	return r.handlePolicy()
 }
 
 // handleWaitResult returns the new store and potential wait duration for
@@ -162,7 +163,18 @@ func (r *RestartTracker) handleWaitResult() (string, time.Duration) {
 		return models.TaskTerminated, 0
 	}
 
-	if r.count > 5 {
+	return r.handlePolicy()
+}
+
+// handlePolicy returns the new store and the wait duration of the restart
+// policy, once the attempts of the interval are counted.
+func (r *RestartTracker) handlePolicy() (string, time.Duration) {
+	policy := r.getPolicy()
+	if r.count > policy.Attempts {
+		if policy.Mode == models.RestartPolicyModeFail {
+			r.reason = ReasonFail
+			return models.TaskNotRestarting, 0
+		}
 		r.reason = ReasonDelay
 		return models.TaskRestarting, r.getDelay()
 	}
@@ -171,9 +183,16 @@ func (r *RestartTracker) handleWaitResult() (string, time.Duration) {
 	return models.TaskRestarting, r.jitter()
 }
 
+func (r *RestartTracker) getPolicy() *models.RestartPolicy {
+	if r.policy == nil {
+		return models.DefaultRestartPolicy()
+	}
+	return r.policy
+}
+
 // getDelay returns the delay time to enter the next interval.
 func (r *RestartTracker) getDelay() time.Duration {
-	end := r.startTime.Add(1 * time.Minute)
+	end := r.startTime.Add(r.getPolicy().Interval)
 	now := time.Now()
 	return end.Sub(now)
 }
@@ -181,7 +200,7 @@ func (r *RestartTracker) getDelay() time.Duration {
 // jitter returns the delay time plus a jitter.
 func (r *RestartTracker) jitter() time.Duration {
 	// Get the delay and ensure it is valid.
-	d := 15 * time.Second.Nanoseconds()
+	d := r.getPolicy().Delay.Nanoseconds()
 	if d == 0 {
 		d = 1
 	}
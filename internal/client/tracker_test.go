@@ -7,6 +7,7 @@
 package client
 
 import (
+	"errors"
 	"math/rand"
 	"reflect"
 	"sync"
@@ -24,7 +25,7 @@ func Test_newRestartTracker(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := newRestartTracker(); !reflect.DeepEqual(got, tt.want) {
+			if got := newRestartTracker(nil); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("newRestartTracker() = %v, want %v", got, tt.want)
 			}
 		})
@@ -404,3 +405,51 @@ func TestRestartTracker_jitter(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartTracker_Policy(t *testing.T) {
+	policy := &models.RestartPolicy{
+		Attempts: 2,
+		Interval: time.Hour,
+		Delay:    time.Second,
+		Mode:     models.RestartPolicyModeFail,
+	}
+	failed := models.NewWaitResult(1, errors.New("driver crashed"))
+
+	r := newRestartTracker(policy)
+	for i := 0; i < policy.Attempts; i++ {
+		state, when := r.SetWaitResult(failed).GetState()
+		if state != models.TaskRestarting {
+			t.Fatalf("attempt %v: state %q, want %q", i, state, models.TaskRestarting)
+		}
+		if when < policy.Delay || when > policy.Delay+policy.Delay/4 {
+			t.Errorf("attempt %v: delay %v, want %v plus a jitter", i, when, policy.Delay)
+		}
+	}
+	if state, _ := r.SetWaitResult(failed).GetState(); state != models.TaskNotRestarting {
+		t.Errorf("state %q after the attempts in fail mode, want %q", state, models.TaskNotRestarting)
+	}
+	if reason := r.GetReason(); reason != ReasonFail {
+		t.Errorf("reason %q, want %q", reason, ReasonFail)
+	}
+
+	policy.Mode = models.RestartPolicyModeDelay
+	r = newRestartTracker(policy)
+	for i := 0; i < policy.Attempts; i++ {
+		r.SetWaitResult(failed).GetState()
+	}
+	state, when := r.SetWaitResult(failed).GetState()
+	if state != models.TaskRestarting || when <= policy.Delay+policy.Delay/4 || when > policy.Interval {
+		t.Errorf("state %q in %v after the attempts in delay mode, want %q in the next interval",
+			state, when, models.TaskRestarting)
+	}
+
+	r = newRestartTracker(policy)
+	startErr := models.NewRecoverableError(errors.New("unrecoverable"), false)
+	if state, _ := r.SetStartError(startErr).GetState(); state != models.TaskNotRestarting {
+		t.Errorf("state %q of an unrecoverable start error, want %q", state, models.TaskNotRestarting)
+	}
+	startErr = models.NewRecoverableError(errors.New("recoverable"), true)
+	if state, _ := r.SetStartError(startErr).GetState(); state != models.TaskRestarting {
+		t.Errorf("state %q of a recoverable start error, want %q", state, models.TaskRestarting)
+	}
+}
@@ -19,10 +19,13 @@ import (
 
 	"github.com/armon/go-metrics"
 
+	"github.com/actiontech/dtle/internal/client/cgroup"
 	"github.com/actiontech/dtle/internal/client/driver"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/crash"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/plugins/hooks"
 )
 
 const (
@@ -37,6 +40,10 @@ const (
 	// killFailureLimit is how many times we will attempt to kill a task before
 	// giving up and potentially leaking resources.
 	killFailureLimit = 5
+
+	// copyProgressEventInterval is the min interval of the task events of the
+	// table copy progress.
+	copyProgressEventInterval = time.Minute
 )
 
 // Worker is used to wrap a task within an allocation and provide the execution context.
@@ -47,6 +54,10 @@ type Worker struct {
 	alloc          *models.Allocation
 	restartTracker *RestartTracker
 
+	// rpc gets the connection profile of the task from the servers, nil if
+	// the client has none.
+	rpc config.RPCHandler
+
 	// running marks whether the task is running
 	running     bool
 	runningLock sync.Mutex
@@ -54,11 +65,24 @@ type Worker struct {
 	taskStats     *models.TaskStatistics
 	taskStatsLock sync.RWMutex
 
+	// The table copy progress last reported in a task event, and when.
+	lastCopyProgress     *models.TableCopyProgress
+	lastCopyProgressTime time.Time
+	// The full copy complete event has been emitted.
+	fullCopyCompleteReported bool
+	// The write conflicts already reported in task events.
+	writeConflictsReported int64
+	// The times read_only of the target was found unset already reported.
+	targetReadOnlyDisabledReported int64
+
 	task *models.Task
 
 	handle     driver.DriverHandle
 	handleLock sync.Mutex
 
+	// cgroup limits the process of the task, nil if none.
+	cgroup *cgroup.Cgroup
+
 	// payloadRendered tracks whether the payload has been rendered to disk
 	payloadRendered bool
 
@@ -73,6 +97,9 @@ type Worker struct {
 	// restartCh is used to restart a task
 	restartCh chan *models.TaskEvent
 
+	// updateCh is used to apply the updates of the alloc to the task
+	updateCh chan *models.Allocation
+
 	destroy      bool
 	destroyCh    chan struct{}
 	destroyLock  sync.Mutex
@@ -112,7 +139,7 @@ func NewWorker(logger *log.Logger, config *config.ClientConfig,
 		return nil
 	}
 
-	restartTracker := newRestartTracker()
+	restartTracker := newRestartTracker(alloc.Job.RestartPolicy)
 
 	tc := &Worker{
 		config:         config,
@@ -126,12 +153,20 @@ func NewWorker(logger *log.Logger, config *config.ClientConfig,
 		startCh:        make(chan struct{}, 1),
 		unblockCh:      make(chan struct{}),
 		restartCh:      make(chan *models.TaskEvent),
+		updateCh:       make(chan *models.Allocation, 64),
 		workUpdates:    workUpdates,
 	}
 
 	return tc
 }
 
+// IsRunning returns whether the task is running.
+func (r *Worker) IsRunning() bool {
+	r.runningLock.Lock()
+	defer r.runningLock.Unlock()
+	return r.running
+}
+
 // MarkReceived marks the task as received.
 func (r *Worker) MarkReceived() {
 	r.logger.Debugf("MarkReceived")
@@ -169,6 +204,10 @@ func (r *Worker) SaveState() error {
 			r.logger.Errorf("agent: Failed to parse handle '%s': %v",
 				handleID, err)
 		}
+		if id.DriverConfig == nil {
+			// e.g. a driver plugin without DriverConfig in its handle ID
+			id.DriverConfig = &config.MySQLDriverConfig{}
+		}
 		if id.DriverConfig.Gtid != "" {
 			if r.task.Type == models.TaskTypeDest {
 				r.workUpdates <- &models.TaskUpdate{
@@ -214,6 +253,34 @@ func (r *Worker) setState(state string, event *models.TaskEvent) {
 	// Indicate the task has been updated.
 	r.logger.Debugf("updater")
 	r.updater(r.task.Type, state, event)
+
+	if event != nil {
+		r.callLifecycleHooks(event)
+	}
+}
+
+// callLifecycleHooks calls the lifecycle hooks with the event of the task.
+func (r *Worker) callLifecycleHooks(event *models.TaskEvent) {
+	hookEvent := &hooks.LifecycleEvent{
+		JobID:   r.alloc.JobID,
+		AllocID: r.alloc.ID,
+		Task:    r.task.Type,
+		Type:    event.Type,
+		Message: event.Message,
+		Time:    event.Time,
+	}
+	if r.alloc.Job != nil {
+		hookEvent.JobName = r.alloc.Job.Name
+	}
+	for _, msg := range []string{event.SetupError, event.DriverError, event.KillError, event.RestartReason} {
+		if hookEvent.Message == "" {
+			hookEvent.Message = msg
+		}
+	}
+	for _, err := range hooks.OnLifecycle(hookEvent) {
+		r.logger.Warnf("agent: Lifecycle hook on %v of task %q for alloc %q: %v",
+			event.Type, r.task.Type, r.alloc.ID, err)
+	}
 }
 
 // createDriver makes a driver for the task
@@ -229,6 +296,7 @@ func (r *Worker) createDriver() (driver.Driver, error) {
 
 // Run is a long running routine used to manage the task
 func (r *Worker) Run() {
+	defer crash.HandlePanic()
 	defer close(r.waitCh)
 	r.logger.Debugf("agent: Starting task context for '%s' (alloc '%s')",
 		r.task.Type, r.alloc.ID)
@@ -337,6 +405,7 @@ func (r *Worker) run() {
 
 				// Stop collection of the task's resource usage
 				close(stopCollection)
+				r.destroyCgroup()
 
 				// Log whether the task was successful or not.
 				r.restartTracker.SetWaitResult(waitRes)
@@ -350,6 +419,9 @@ func (r *Worker) run() {
 
 				break WAIT
 
+			case update := <-r.updateCh:
+				r.handleUpdate(update)
+
 			case event := <-r.restartCh:
 				r.runningLock.Lock()
 				running := r.running
@@ -370,6 +442,7 @@ func (r *Worker) run() {
 				if handleWaitCh != nil {
 					<-handleWaitCh
 				}
+				r.destroyCgroup()
 
 				// Since the restart isn't from a failure, restart immediately
 				// and don't count against the restart policy
@@ -403,6 +476,7 @@ func (r *Worker) run() {
 				close(stopCollection)
 				// Wait for handler to exit before calling cleanup
 				<-handleWaitCh
+				r.destroyCgroup()
 
 				r.logger.Debugf("setState 8")
 				r.setState(models.TaskStateDead, nil)
@@ -525,11 +599,26 @@ func (r *Worker) startTask() error {
 			r.task.Type, r.alloc.ID, err)
 	}
 
+	task, err := r.resolveConnectionProfile()
+	if err != nil {
+		wrapped := fmt.Sprintf("Failed to resolve the connection profile of task %q for alloc %q: %v",
+			r.task.Type, r.alloc.ID, err)
+		r.logger.Warnf("agent: %s", wrapped)
+		return models.WrapRecoverable(wrapped, err)
+	}
+
 	// Run prestart
 	ctx := driver.NewExecContext(r.alloc.Job.ID, r.alloc.Job.Type, r.config.MaxPayload)
+	ctx.TaskDir = taskDir(r.config, r.alloc.ID, r.task.Type)
+	ctx.Nats = r.config.Nats
+	ctx.Vault = r.config.Vault
+	ctx.Storage = r.config.Storage
+	if r.config.ConsulConfig != nil {
+		ctx.ConsulAddr = r.config.ConsulConfig.Addr
+	}
 
 	// Start the job
-	handle, err := drv.Start(ctx, r.task)
+	handle, err := drv.Start(ctx, task)
 	if err != nil {
 		wrapped := fmt.Sprintf("Failed to start task %q for alloc %q: %v",
 			r.task.Type, r.alloc.ID, err)
@@ -541,9 +630,106 @@ func (r *Worker) startTask() error {
 	r.handleLock.Lock()
 	r.handle = handle
 	r.handleLock.Unlock()
+
+	if err := r.applyCgroup(handle); err != nil {
+		if shutdownErr := handle.Shutdown(); shutdownErr == nil {
+			<-handle.WaitCh()
+		}
+		r.destroyCgroup()
+		r.handleLock.Lock()
+		r.handle = nil
+		r.handleLock.Unlock()
+		wrapped := fmt.Sprintf("Failed to limit the resources of task %q for alloc %q: %v",
+			r.task.Type, r.alloc.ID, err)
+		r.logger.Warnf("agent: %s", wrapped)
+		return models.WrapRecoverable(wrapped, err)
+	}
 	return nil
 }
 
+// resolveConnectionProfile returns the task with the ConnectionConfig of the
+// connection profile it references, got from the servers each time the task
+// starts, so that the task restarts with the profile changed.
+func (r *Worker) resolveConnectionProfile() (*models.Task, error) {
+	name := r.task.ConnectionProfileName()
+	if name == "" {
+		return r.task, nil
+	}
+	if r.rpc == nil {
+		return nil, fmt.Errorf("no server to get connection profile %v from", name)
+	}
+	args := models.ConnectionProfileSpecificRequest{
+		Name:         name,
+		QueryOptions: models.QueryOptions{Region: r.config.Region},
+	}
+	var reply models.SingleConnectionProfileResponse
+	if err := r.rpc.RPC("ConnectionProfile.GetProfile", &args, &reply); err != nil {
+		return nil, err
+	}
+	if reply.Profile == nil {
+		return nil, fmt.Errorf("connection profile %v not found", name)
+	}
+	if err := reply.Profile.CheckNamespace(r.alloc.Job.Namespace); err != nil {
+		return nil, err
+	}
+	task := r.task.Copy()
+	task.ConfigLock.RLock()
+	taskConfig, err := config.ResolveConnectionProfile(task.Config, reply.Profile, r.config.Vault)
+	task.ConfigLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	task.Config = taskConfig
+	task.ConfigLock = &sync.RWMutex{}
+	return task, nil
+}
+
+// pid returns the pid of the process of the task, 0 if it runs in the agent
+// process or is not running.
+func (r *Worker) pid() int {
+	r.handleLock.Lock()
+	defer r.handleLock.Unlock()
+	if ph, ok := r.handle.(driver.ProcessHandle); ok {
+		return ph.Pid()
+	}
+	return 0
+}
+
+// applyCgroup moves the process of the task into a cgroup with the Resources
+// of the task, if the client has a CgroupParent. A task run in the agent
+// process is not limited.
+func (r *Worker) applyCgroup(handle driver.DriverHandle) error {
+	if r.config.CgroupParent == "" || r.task.Resources == nil {
+		return nil
+	}
+	ph, ok := handle.(driver.ProcessHandle)
+	if !ok || ph.Pid() == 0 {
+		r.logger.Warnf("agent: Task %q for alloc %q runs in the agent process, its resources are not limited",
+			r.task.Type, r.alloc.ID)
+		return nil
+	}
+	cg, err := cgroup.Create(r.config.CgroupParent, cgroup.Name(r.alloc.ID, r.task.Type), cgroup.Limits{
+		CPU:      r.task.Resources.CPU,
+		MemoryMB: r.task.Resources.MemoryMB,
+	})
+	if err != nil {
+		return err
+	}
+	r.cgroup = cg
+	return cg.AddProcess(ph.Pid())
+}
+
+// destroyCgroup removes the cgroup of the task, once its process exited.
+func (r *Worker) destroyCgroup() {
+	if r.cgroup == nil {
+		return
+	}
+	if err := r.cgroup.Destroy(); err != nil {
+		r.logger.Warnf("agent: Failed to remove cgroup %v: %v", r.cgroup.Path(), err)
+	}
+	r.cgroup = nil
+}
+
 // collectResourceUsageStats starts collecting resource usage stats of a Task.
 // Collection ends when the passed channel is closed
 func (r *Worker) collectResourceUsageStats(stopCollection <-chan struct{}) {
@@ -581,6 +767,24 @@ func (r *Worker) collectResourceUsageStats(stopCollection <-chan struct{}) {
 			r.taskStatsLock.Unlock()
 			if ru != nil {
 				r.emitStats(ru)
+				if ru.TableCopyProgress != nil {
+					r.emitCopyProgressEvent(ru.TableCopyProgress)
+				}
+				if ru.FullCopyComplete && !r.fullCopyCompleteReported {
+					r.fullCopyCompleteReported = true
+					r.setState("", models.NewTaskEvent(models.TaskFullCopyComplete))
+				}
+				if ru.WriteConflicts != nil {
+					r.emitWriteConflictEvent(ru.WriteConflicts)
+				}
+				if ru.TargetReadOnly != nil && ru.TargetReadOnly.Disabled > r.targetReadOnlyDisabledReported {
+					r.targetReadOnlyDisabledReported = ru.TargetReadOnly.Disabled
+					msg := "read_only of the target was unset"
+					if ru.TargetReadOnly.Paused {
+						msg += ", the applying is paused until it is set again"
+					}
+					r.setState("", models.NewTaskEvent(models.TaskTargetReadOnlyDisabled).SetMessage(msg))
+				}
 			}
 		case <-stopCollection:
 			return
@@ -628,6 +832,76 @@ func (r *Worker) handleDestroy() (destroyed bool, err error) {
 	return
 }
 
+// Drain lets the task finish the work in flight if its driver supports it.
+func (r *Worker) Drain(timeout time.Duration) error {
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+
+	if drainable, ok := handle.(driver.DrainableHandle); ok {
+		r.logger.Printf("agent: Draining task '%s' for alloc %q", r.task.Type, r.alloc.ID)
+		return drainable.Drain(timeout)
+	}
+	return nil
+}
+
+// Resync copies a table of the task again, if its driver supports it.
+func (r *Worker) Resync(schema, table string) error {
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+
+	if handle == nil {
+		return fmt.Errorf("task %v is not running", r.task.Type)
+	}
+	resyncable, ok := handle.(driver.ResyncableHandle)
+	if !ok {
+		return fmt.Errorf("the driver of task %v does not support resync", r.task.Type)
+	}
+	r.logger.Printf("agent: Resyncing %v.%v of task '%s' for alloc %q", schema, table, r.task.Type, r.alloc.ID)
+	return resyncable.Resync(schema, table)
+}
+
+// Update is used to update the task of the alloc.
+func (r *Worker) Update(update *models.Allocation) {
+	select {
+	case r.updateCh <- update:
+	default:
+		r.logger.Errorf("agent: Dropping update to task %q for alloc %q", r.task.Type, r.alloc.ID)
+	}
+}
+
+// handleUpdate applies the tables of the updated task, the only change of a
+// task which is not a new alloc. A running task changes them live if its
+// driver supports it, else it is restarted with them.
+func (r *Worker) handleUpdate(update *models.Allocation) {
+	task := update.Job.LookupTask(update.Task)
+	if task == nil || !r.task.TablesChanged(task) {
+		return
+	}
+	r.task.SetTables(task)
+
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+	if handle == nil {
+		// Started with them.
+		return
+	}
+
+	if updatable, ok := handle.(driver.UpdatableHandle); ok {
+		err := updatable.Update(task)
+		if err == nil {
+			r.logger.Printf("agent: Updated the tables of task %q for alloc %q", r.task.Type, r.alloc.ID)
+			return
+		}
+		r.logger.Warnf("agent: Failed to update the tables of task %q for alloc %q: %v",
+			r.task.Type, r.alloc.ID, err)
+	}
+	// Not from the run loop, which receives it.
+	go r.Restart("update", "the tables of the task changed")
+}
+
 // Restart will restart the task
 func (r *Worker) Restart(source, reason string) {
 	reasonStr := fmt.Sprintf("%s: %s", source, reason)
@@ -687,10 +961,48 @@ func (r *Worker) Destroy(event *models.TaskEvent) {
 	close(r.destroyCh)
 }
 
+// emitCopyProgressEvent reports the progress of the full copy in a task event,
+// at most every copyProgressEventInterval, and when the table reported is done.
+// As a task keeps only its last events, not every chunk is reported.
+func (r *Worker) emitCopyProgressEvent(p *models.TableCopyProgress) {
+	if p.ChunkIndex == 0 && !p.Done {
+		return
+	}
+	if last := r.lastCopyProgress; last != nil {
+		sameTable := last.Table == p.Table
+		if sameTable && last.Done {
+			return
+		}
+		finishing := sameTable && p.Done
+		if !finishing && time.Since(r.lastCopyProgressTime) < copyProgressEventInterval {
+			return
+		}
+	}
+	r.lastCopyProgress = p
+	r.lastCopyProgressTime = time.Now()
+	r.setState("", models.NewTaskEvent(models.TaskCopyProgress).SetCopyProgress(p))
+}
+
+// emitWriteConflictEvent reports the last write conflict in a task event, if
+// there are new ones since the last reported. The conflicts between two stats
+// are counted in the message, but only the last is described.
+func (r *Worker) emitWriteConflictEvent(c *models.WriteConflicts) {
+	if c.Count <= r.writeConflictsReported || c.Last == nil {
+		return
+	}
+	n := c.Count - r.writeConflictsReported
+	r.writeConflictsReported = c.Count
+	msg := c.Last.String()
+	if n > 1 {
+		msg = fmt.Sprintf("%v, and %d other conflicts", msg, n-1)
+	}
+	r.setState("", models.NewTaskEvent(models.TaskWriteConflict).SetMessage(msg))
+}
+
 // emitStats emits resource usage stats of tasks to remote metrics collector
 // sinks
 func (r *Worker) emitStats(ru *models.TaskStatistics) {
-	labels := []metrics.Label{{"task_name", fmt.Sprintf("%s_%s", r.alloc.Job.Name, r.alloc.Task)}}
+	labels := []metrics.Label{{Name: "task_name", Value: fmt.Sprintf("%s_%s", r.alloc.Job.Name, r.alloc.Task)}}
 	if r.config.PublishAllocationMetrics {
 		metrics.SetGaugeWithLabels([]string{"network", "in_msgs"}, float32(ru.MsgStat.InMsgs), labels)
 		metrics.SetGaugeWithLabels([]string{"network", "out_msgs"}, float32(ru.MsgStat.OutMsgs), labels)
@@ -702,17 +1014,65 @@ func (r *Worker) emitStats(ru *models.TaskStatistics) {
 		metrics.SetGaugeWithLabels([]string{"buffer", "send_by_timeout"}, float32(ru.BufferStat.SendByTimeout), labels)
 		metrics.SetGaugeWithLabels([]string{"buffer", "send_by_size_full"}, float32(ru.BufferStat.SendBySizeFull), labels)
 	}
+	if ru.TransportStat != nil && r.config.PublishAllocationMetrics {
+		metrics.SetGaugeWithLabels([]string{"transport", "publish_msgs_per_sec"}, float32(ru.TransportStat.PublishMsgsPerSec), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "publish_bytes_per_sec"}, float32(ru.TransportStat.PublishBytesPerSec), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "consume_msgs_per_sec"}, float32(ru.TransportStat.ConsumeMsgsPerSec), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "consume_bytes_per_sec"}, float32(ru.TransportStat.ConsumeBytesPerSec), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "pending_msgs"}, float32(ru.TransportStat.PendingMsgs), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "pending_bytes"}, float32(ru.TransportStat.PendingBytes), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "redeliveries"}, float32(ru.TransportStat.Redeliveries), labels)
+		metrics.SetGaugeWithLabels([]string{"transport", "slow_consumer_events"}, float32(ru.TransportStat.SlowConsumerEvents), labels)
+	}
 	if ru.TableStats != nil && r.config.PublishAllocationMetrics {
 		metrics.SetGaugeWithLabels([]string{"table", "insert"}, float32(ru.TableStats.InsertCount), labels)
 		metrics.SetGaugeWithLabels([]string{"table", "update"}, float32(ru.TableStats.UpdateCount), labels)
 		metrics.SetGaugeWithLabels([]string{"table", "delete"}, float32(ru.TableStats.DelCount), labels)
 	}
 
+	if ru.TableCopyProgress != nil && r.config.PublishAllocationMetrics {
+		p := ru.TableCopyProgress
+		copyLabels := append([]metrics.Label{{Name: "table", Value: p.Table}}, labels...)
+		metrics.SetGaugeWithLabels([]string{"copy", "chunks"}, float32(p.ChunkIndex), copyLabels)
+		metrics.SetGaugeWithLabels([]string{"copy", "rows"}, float32(p.Rows), copyLabels)
+		metrics.SetGaugeWithLabels([]string{"copy", "bytes"}, float32(p.Bytes), copyLabels)
+		metrics.SetGaugeWithLabels([]string{"copy", "pct"}, float32(p.Pct()), copyLabels)
+		metrics.SetGaugeWithLabels([]string{"copy", "elapsed_seconds"}, float32(p.Elapsed.Seconds()), copyLabels)
+	}
+	if ru.SourceReplicaLag != nil && r.config.PublishAllocationMetrics {
+		metrics.SetGaugeWithLabels([]string{"copy", "source_replica_lag_seconds"}, float32(ru.SourceReplicaLag.Seconds), labels)
+		metrics.SetGaugeWithLabels([]string{"copy", "source_replica_lag_throttled_seconds"},
+			float32(ru.SourceReplicaLag.ThrottledTime.Seconds()), labels)
+	}
+
+	if ru.WriteConflicts != nil && r.config.PublishAllocationMetrics {
+		metrics.SetGaugeWithLabels([]string{"incr", "write_conflicts"}, float32(ru.WriteConflicts.Count), labels)
+	}
+	if ru.TargetReadOnly != nil && r.config.PublishAllocationMetrics {
+		var readOnly float32
+		if ru.TargetReadOnly.ReadOnly {
+			readOnly = 1
+		}
+		metrics.SetGaugeWithLabels([]string{"incr", "target_read_only"}, readOnly, labels)
+	}
+
 	if ru.DelayCount != nil && r.config.PublishAllocationMetrics {
 		metrics.SetGaugeWithLabels([]string{"delay", "num"}, float32(ru.DelayCount.Num), labels)
 		metrics.SetGaugeWithLabels([]string{"delay", "time"}, float32(ru.DelayCount.Time), labels)
 	}
 
+	if ru.IncrProgress != nil && r.config.PublishAllocationMetrics {
+		metrics.SetGaugeWithLabels([]string{"incr", "transactions_behind"}, float32(ru.IncrProgress.TransactionsBehind), labels)
+		metrics.SetGaugeWithLabels([]string{"incr", "seconds_behind"}, float32(ru.IncrProgress.SecondsBehind), labels)
+	}
+
+	if r.config.PublishAllocationMetrics {
+		for category, n := range ru.UnsupportedStatements {
+			categoryLabels := append([]metrics.Label{{Name: "category", Value: category}}, labels...)
+			metrics.SetGaugeWithLabels([]string{"incr", "unsupported_statements"}, float32(n), categoryLabels)
+		}
+	}
+
 	if ru.ThroughputStat != nil && r.config.PublishAllocationMetrics {
 		metrics.SetGaugeWithLabels([]string{"throughput", "num"}, float32(ru.ThroughputStat.Num), labels)
 		metrics.SetGaugeWithLabels([]string{"throughput", "time"}, float32(ru.ThroughputStat.Time), labels)
@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+// LDAPConfig authenticates the users of the HTTP API and the web UI by binding
+// to an LDAP server with the credentials of the HTTP basic auth.
+type LDAPConfig struct {
+	// Address is the host:port of the LDAP server.
+	Address string `mapstructure:"address"`
+
+	// TLS connects with LDAPS. CAFile verifies the server certificate instead of
+	// the system CAs.
+	TLS                bool   `mapstructure:"tls"`
+	CAFile             string `mapstructure:"ca_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+
+	// UserDN is the DN of the users, "%s" being replaced by the user name, e.g.
+	// "uid=%s,ou=people,dc=example,dc=com".
+	UserDN string `mapstructure:"user_dn"`
+
+	// GroupAttribute is the attribute of the user entry listing its groups,
+	// "memberOf" by default.
+	GroupAttribute string `mapstructure:"group_attribute"`
+}
+
+// OIDCConfig authenticates the users of the HTTP API by the ID tokens (JWT) of an
+// OpenID Connect provider, and logs the users of the web UI in with the
+// authorization code flow.
+type OIDCConfig struct {
+	// Issuer is the URL of the provider. The endpoints and the keys are
+	// discovered from Issuer + "/.well-known/openid-configuration".
+	Issuer string `mapstructure:"issuer"`
+
+	// ClientID is the client registered at the provider, the audience of the ID
+	// tokens. ClientSecret is only needed by the web UI login.
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+
+	// RedirectURL is the URL of /v1/acl/oidc/callback as reached by the browsers,
+	// e.g. "https://dtle.example.com/v1/acl/oidc/callback".
+	RedirectURL string `mapstructure:"redirect_url"`
+
+	// Scopes are requested by the web UI login, "openid" and "profile" by
+	// default.
+	Scopes []string `mapstructure:"scopes"`
+
+	// UsernameClaim is the claim of the user name, "preferred_username" by
+	// default, falling back to "sub". GroupsClaim is the claim listing the
+	// groups of the user, "groups" by default.
+	UsernameClaim string `mapstructure:"username_claim"`
+	GroupsClaim   string `mapstructure:"groups_claim"`
+}
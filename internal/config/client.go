@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/actiontech/dtle/internal"
+	"github.com/actiontech/dtle/internal/g"
 	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	"github.com/actiontech/dtle/internal/models"
 
@@ -35,6 +36,55 @@ const (
 	defaultChunkSize  = 2000
 	defaultNumWorkers = 1
 	defaultMsgBytes   = 20 * 1024
+
+	defaultChunkTargetBytes  = 4 * 1024 * 1024
+	defaultChunkTargetMillis = 1000
+
+	FullCopyMethodDump       = "dump"
+	FullCopyMethodXtrabackup = "xtrabackup"
+	FullCopyMethodOutfile    = "outfile"
+
+	TargetTypeMySQL = "MySQL"
+	TargetTypeTiDB  = "TiDB"
+
+	ExistingTargetError           = "error"
+	ExistingTargetSkipCreate      = "skip-create"
+	ExistingTargetDropAndRecreate = "drop-and-recreate"
+	ExistingTargetTruncate        = "truncate-then-load"
+
+	ColumnMismatchError        = "error"
+	ColumnMismatchIgnoreExtra  = "ignore-extra"
+	ColumnMismatchFillDefaults = "fill-defaults"
+
+	FullCopyConflictReplace = "replace"
+	FullCopyConflictIgnore  = "ignore"
+	FullCopyConflictInsert  = "insert"
+
+	RowImageCheckOff      = "off"
+	RowImageCheckColumns  = "columns"
+	RowImageCheckChecksum = "checksum"
+
+	RowImageConflictApply = "apply"
+	RowImageConflictError = "error"
+
+	TargetReadOnlyOff   = "off"
+	TargetReadOnlyAlert = "alert"
+	TargetReadOnlyPause = "pause"
+
+	// the default stmt-count-limit of TiDB
+	defaultTxnSplitSize = 5000
+
+	// BenchSchemaName is the schema generated by the Src task of a bench job,
+	// dropped and created again each time the task starts.
+	BenchSchemaName = "dtle_bench"
+
+	defaultBenchTables       = 4
+	defaultBenchRows         = 100000
+	defaultBenchTransactions = 10000
+
+	defaultVerifyIntervalSeconds = 30
+
+	defaultBinlogReconnectTimeoutSeconds = 600
 )
 
 // RPCHandler can be provided to the Client if there is a local server
@@ -52,6 +102,21 @@ type ClientConfig struct {
 	// AllocDir is where we store data for allocations
 	AllocDir string
 
+	// AllocDirSizeLimit is the max size in bytes of the working dir of an
+	// allocation. Zero means no limit.
+	AllocDirSizeLimit int64
+
+	// CgroupParent is the cgroup v2 dir under which the tasks with Resources
+	// get a cgroup. Empty disables cgroups.
+	CgroupParent string
+
+	// DriverExecutor runs the builtin drivers of the tasks in executor
+	// processes instead of the agent process.
+	DriverExecutor bool
+
+	// PluginDir is the dir of the driver and the hook plugins.
+	PluginDir string
+
 	// LogOutput is the destination for logs
 	LogOutput io.Writer
 
@@ -80,8 +145,29 @@ type ClientConfig struct {
 	// ConsulConfig is this Agent's Consul configuration
 	ConsulConfig *ConsulConfig
 
+	// TLSConfig is the mutual TLS of the RPC to the servers.
+	TLSConfig *TLSConfig
+
+	// NatsAddr is the advertised address of the nats streaming server
 	NatsAddr string
 
+	// NatsBindAddr is the address the nats streaming server binds to. NatsAddr
+	// is used if it is empty.
+	NatsBindAddr string
+
+	// Nats is the configuration of an external NATS cluster and of the
+	// connections of the tasks. The embedded server is not started if it has
+	// servers, and NatsAddr is then the addresses of the servers.
+	Nats *NatsConfig
+
+	// Vault is the configuration of the Vault server the keys of the jobs are
+	// read from.
+	Vault *VaultConfig
+
+	// Storage is the object storage the dumps exported by the jobs are put in,
+	// nil if not configured.
+	Storage *StorageConfig
+
 	MaxPayload int
 
 	// StatsCollectionInterval is the interval at which the Udup client
@@ -110,6 +196,7 @@ func (c *ClientConfig) Copy() *ClientConfig {
 	nc.Node = nc.Node.Copy()
 	nc.Servers = internal.CopySliceString(nc.Servers)
 	nc.ConsulConfig = c.ConsulConfig.Copy()
+	nc.TLSConfig = c.TLSConfig.Copy()
 	return nc
 }
 
@@ -154,6 +241,7 @@ type MySQLDriverConfig struct {
 	NatsAddr                 string
 	ParallelWorkers          int
 	ConnectionConfig         *umconf.ConnectionConfig
+	ConnectionProfile        string // A connection profile of the managers instead of ConnectionConfig, resolved when the task starts.
 	SystemVariables          map[string]string
 	HasSuperPrivilege        bool
 	BinlogFormat             string
@@ -176,6 +264,23 @@ type MySQLDriverConfig struct {
 	Stage                string
 	ApproveHeterogeneous bool
 	SkipCreateDbTable    bool
+	// The policy of each category of statements which could not be replicated
+	// faithfully, e.g. {"load-data": "log"}. See binlog.UnsupportedLoadData.
+	UnsupportedStatements map[string]string
+	// Replicate the tables of gh-ost and pt-online-schema-change as the others,
+	// instead of applying their online schema changes to the tables altered.
+	SkipOnlineSchemaChangeDetection bool
+	// Copy the tables created on the source matching ReplicateDoDb while
+	// replicating, as the tables added to a running job.
+	BackfillNewTables bool
+	// (Src) The schemas skipped when ReplicateDoDb is empty, and whose binlog
+	// events are never replicated. Defaults to DefaultSystemSchemas. The schema
+	// of dtle is always skipped.
+	SystemSchemas []string
+	// (Src) Copy the accounts of the source, but the root and the mysql.*
+	// ones and the user of the job, to the target with the full copy: their
+	// CREATE USER and GRANT statements, from SHOW CREATE USER and SHOW GRANTS.
+	ReplicateGrants bool
 
 	throttleMutex               *sync.Mutex
 	CountingRowsFlag            int64
@@ -183,6 +288,193 @@ type MySQLDriverConfig struct {
 
 	SkipPrivilegeCheck  bool
 	SkipIncrementalCopy bool
+
+	// If not empty, every statement applied on the target is appended to this file.
+	SqlAuditFile string
+	// If not empty, the full copy is also written to this dir in mydumper layout.
+	// Only with FullCopyMethodDump.
+	DumpExportDir string
+	// (Dest) Put the full copy exported in the storage of the agent, under
+	// "dumps/<job>/". DumpExportDir defaults to "dump-export" under WorkDir then.
+	DumpExportStorage bool
+	// xtrabackup_binlog_info, mydumper metadata or mysqldump file of a backup restored on
+	// the target. If set, the full copy is skipped and the job starts from the gtid of it.
+	GtidBackupFile string
+
+	// FullCopyMethodDump (default), FullCopyMethodXtrabackup or FullCopyMethodOutfile.
+	FullCopyMethod string
+	// Dir for the table files of FullCopyMethodOutfile, written by the source mysqld with
+	// SELECT ... INTO OUTFILE and read by the target mysqld with LOAD DATA INFILE.
+	// Both must see the dir, e.g. on a shared storage. On Dest, it is only set if the dir
+	// is mounted on another path than on the source.
+	OutfileDir string
+	// Dir of xtrabackup and xbstream. Find in $PATH if empty.
+	XtrabackupBinDir string
+	// (Dest) Dir to extract and prepare the backup. Defaults to "xtrabackup" under WorkDir.
+	XtrabackupDir string
+	// (Dest) Shell command to put the prepared backup in XtrabackupDir (also in env
+	// DTLE_XTRABACKUP_DIR) into use, e.g. stop mysqld, `xtrabackup --copy-back` and start mysqld.
+	XtrabackupRestoreCommand string
+
+	// Working dir of the task for temporary files, removed with the allocation.
+	// For internal use. Set by the agent.
+	WorkDir string
+	// Options of the connection to the NATS server(s) of NatsAddr.
+	// For internal use. Set by the agent.
+	Nats *NatsConfig `json:"-"`
+	// Address of the Consul agent, where the server_ids are allocated.
+	// For internal use. Set by the agent.
+	ConsulAddr string `json:"-"`
+	// Object storage of the agent, nil if not configured.
+	// For internal use. Set by the agent.
+	Storage *StorageConfig `json:"-"`
+
+	// (Dest) TargetTypeMySQL (default) or TargetTypeTiDB. On TiDB, large transactions are
+	// split and the transactions failed with a write conflict are retried up to MaxRetries.
+	TargetType string
+	// (Dest) Max row events in a transaction on TiDB. Defaults to 5000.
+	TxnSplitSize int
+	// (Dest) Copy the full data to TiDB with tidb_batch_insert, which commits every
+	// TxnSplitSize rows, instead of in a transaction per chunk.
+	TiDBBatchImport bool
+	// (Dest) Apply each transaction of the source as one transaction of the target,
+	// one at a time in the order of the source: never split by TxnSplitSize, nor
+	// applied in parallel with another, so that a reader of the target never sees a
+	// part of a transaction, nor a transaction without the previous ones. It costs
+	// the throughput of ParallelWorkers. Not supported with IncrSubjectPartitions of Src.
+	StrictTransactionBoundaries bool
+	// (Dest) Check, before an UPDATE or a DELETE is applied, that the row of the target
+	// still has the before-image of the source, to detect the writes to the target not
+	// made by the job. RowImageCheckOff (default) doesn't check. RowImageCheckColumns
+	// compares each column, and reports those which differ. RowImageCheckChecksum
+	// compares a CRC32 of the text of the columns instead, computed by the target. A row
+	// which differs, or is missing, is a write conflict.
+	RowImageCheck string
+	// (Dest) What to do with a write conflict found by RowImageCheck.
+	// RowImageConflictApply (default) reports it and applies the event.
+	// RowImageConflictError reports it and fails the task.
+	RowImageConflict string
+	// (Dest) Set read_only on the target when the task starts, so that only the
+	// sessions of the users with SUPER (or CONNECTION_ADMIN), like the one of dtle,
+	// write to it until the cut-over, and check every few seconds that it is still
+	// set. super_read_only can't be used, as it blocks the session of dtle too. It
+	// is not unset when the task stops. TargetReadOnlyOff (default) doesn't set it.
+	// If read_only is found unset, TargetReadOnlyAlert reports it, and
+	// TargetReadOnlyPause also pauses the applying until it is set again.
+	TargetReadOnly string
+
+	// (Src) How long to try reconnecting the binlog stream when it is broken, e.g. the
+	// source restarts, before failing the task. Defaults to 600. -1 retries forever.
+	BinlogReconnectTimeoutSeconds int
+	// (Src) server_id to register on the source with. If 0, a unique one is allocated
+	// from the pool in Consul (or picked at random without Consul), skipping the ids
+	// of the source and its replicas.
+	ServerID uint32
+	// (Src) Register on the source as a semi-sync replica. The source, with
+	// rpl_semi_sync_master_enabled, waits for the extractor to receive the events of a
+	// transaction before the commit returns, so that a transaction committed on a source
+	// that crashes is not lost.
+	SemiSync bool
+	// (Src) Read the binlog with the other jobs of the agent with SharedBinlogReader and
+	// the same source and user: the source has one replica connection for all of them,
+	// and each event is read and decoded once, then filtered by the tables of each job.
+	// A job whose GTID set is behind the shared stream reads the binlog on its own.
+	SharedBinlogReader bool
+	// (Dest) How to apply the rows of a table whose columns differ between the source and
+	// the target. ColumnMismatchError (default) fails the task. ColumnMismatchIgnoreExtra
+	// drops the values of the columns not on the target. ColumnMismatchFillDefaults also
+	// leaves out the columns of the target not in the source, to get their defaults.
+	// The columns are matched by name with binlog_row_metadata=FULL, or else by position.
+	ColumnMismatch string
+	// (Src) What to do in the full copy with a table already on the target.
+	// ExistingTargetSkipCreate (default) keeps it, and the rows are loaded into it.
+	// ExistingTargetDropAndRecreate (default with DropTableIfExists) drops and creates
+	// it again. ExistingTargetTruncate empties it before the rows are loaded.
+	// ExistingTargetError fails the task.
+	ExistingTarget string
+	// (Dest) How the full copy loads a row whose primary or unique key is already on
+	// the target. FullCopyConflictReplace (default) replaces the row of the target with
+	// all the columns of the source. FullCopyConflictIgnore keeps the row of the target.
+	// FullCopyConflictInsert fails the task, which also fails a full copy resumed after
+	// some rows of a chunk are loaded.
+	FullCopyConflict string
+	// (Src) Create the tables without the secondary indexes and the foreign keys, and
+	// add them after the full copy, which is faster to load a large table.
+	DeferSecondaryIndexes bool
+	// (Dest) SQL statements executed on the target before the rows of each table are
+	// loaded in the full copy, e.g. to disable its triggers, and after, e.g. to exchange
+	// the partition loaded. They are text/template templates, with {{.Schema}} and
+	// {{.Table}} the names of the table, and {{quote .Table}} a name quoted. A failed
+	// statement fails the task. Not run with FullCopyMethodXtrabackup.
+	FullCopyBeforeTableSQL []string
+	FullCopyAfterTableSQL  []string
+	// (Dest) Session variables of the connections applying the full copy, e.g.
+	// {"sql_log_bin": "0", "innodb_lock_wait_timeout": "10"}. The values are SQL literals.
+	// foreign_key_checks is 0 unless set here. unique_checks is kept unless set, e.g. {"unique_checks": "0"}.
+	FullCopySessionVariables map[string]string
+	// (Dest) Session variables of the connections applying the incremental changes.
+	// foreign_key_checks is 0 unless set here.
+	IncrSessionVariables map[string]string
+	// (Dest) Quote the identifiers of the statements applied with double quotes instead
+	// of backticks, and add ANSI_QUOTES to the sql_mode of the sessions, for a target
+	// expecting the ANSI quoting of identifiers.
+	AnsiQuotes bool
+	// (Src) Adapt the rows of a chunk of the full copy, starting from ChunkSize, to the
+	// average row size and the time to fetch a chunk, aiming at ChunkTargetBytes and
+	// ChunkTargetMillis per chunk, whichever is reached first.
+	AdaptiveChunkSize bool
+	// (Src) Bytes of a chunk aimed at with AdaptiveChunkSize. Defaults to 4MB.
+	ChunkTargetBytes int64
+	// (Src) Milliseconds to fetch a chunk aimed at with AdaptiveChunkSize. Defaults to 1000.
+	ChunkTargetMillis int64
+	// (Src) Partitions of the incremental stream with ApproveHeterogeneous. A transaction
+	// is sent to the partition of its tables by a hash of the table names, and the
+	// partitions are applied in parallel, each in order. A transaction of the tables of
+	// several partitions, or with DDL, waits for all the previous ones. The tables
+	// linked by foreign keys on the source are in the same partition, so that a
+	// child row is not applied before its parent row. Defaults to 1.
+	IncrSubjectPartitions int
+	// (Src) Pause the full copy while the source, itself a replica of another primary,
+	// is more than this many seconds behind it by Seconds_Behind_Master, or its
+	// replication is stopped, so that the reads of the copy don't add to its lag. 0
+	// means no limit. Ignored if the source is not a replica.
+	MaxSourceReplicaLagSeconds int64
+	// (Src) Max MB per second the task sends to the Dest task. 0 means no limit. It
+	// must be set for the jobs of a namespace with a bandwidth quota.
+	BandwidthLimitMBps int
+	// Path in Vault of the key encrypting the data the Src task sends to the Dest task,
+	// e.g. "secret/data/dtle/job1", read by the agents of both tasks with their vault
+	// config. The key is the base64 of 16, 24 or 32 bytes in the field "key". It must be
+	// the same on Src and Dest. If empty, the key of the job is derived from the nats
+	// encrypt_key of the agents, if set.
+	TrafficKeyVaultPath string
+	// Key encrypting the data sent to the Dest task with AES-GCM, nil for none.
+	// For internal use. Set by the agent.
+	TrafficKey []byte `json:"-"`
+	// (Src) Tables of BenchSchemaName generated for the full copy of a bench job.
+	// Defaults to 4.
+	BenchTables int
+	// (Src) Rows generated in each table for the full copy of a bench job. Defaults
+	// to 100000.
+	BenchRows int64
+	// (Src) Transactions generated after the full copy of a bench job, measuring the
+	// incremental replication. Defaults to 10000.
+	BenchTransactions int64
+	// (Src) Read queries of a verify job, run on the source and the target and
+	// compared, e.g. row counts and sums. The job completes once they all return
+	// the same rows.
+	VerifyQueries []*VerifyQuery
+	// (Src) Seconds between the rounds of the queries of a verify job, until they
+	// pass. Defaults to 30.
+	VerifyIntervalSeconds int
+}
+
+// VerifyQuery is a read query of a verify job.
+type VerifyQuery struct {
+	Name string
+	// Query is run on the source, and on the target unless TargetQuery is set.
+	Query       string
+	TargetQuery string
 }
 
 func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
@@ -194,6 +486,22 @@ func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
 	if result.ChunkSize <= 0 {
 		result.ChunkSize = defaultChunkSize
 	}
+	if result.ExistingTarget == "" {
+		if result.DropTableIfExists {
+			result.ExistingTarget = ExistingTargetDropAndRecreate
+		} else {
+			result.ExistingTarget = ExistingTargetSkipCreate
+		}
+	}
+	if result.ChunkTargetBytes <= 0 {
+		result.ChunkTargetBytes = defaultChunkTargetBytes
+	}
+	if result.ChunkTargetMillis <= 0 {
+		result.ChunkTargetMillis = defaultChunkTargetMillis
+	}
+	if result.IncrSubjectPartitions <= 0 {
+		result.IncrSubjectPartitions = 1
+	}
 	if result.ReplChanBufferSize <= 0 {
 		result.ReplChanBufferSize = channelBufferSize
 	}
@@ -212,16 +520,88 @@ func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
 	if result.GroupTimeout == 0 {
 		result.GroupTimeout = 100
 	}
+	if result.FullCopyMethod == "" {
+		result.FullCopyMethod = FullCopyMethodDump
+	}
+	if result.TargetType == "" {
+		result.TargetType = TargetTypeMySQL
+	}
+	if result.TxnSplitSize <= 0 {
+		result.TxnSplitSize = defaultTxnSplitSize
+	}
+	if result.BinlogReconnectTimeoutSeconds == 0 {
+		result.BinlogReconnectTimeoutSeconds = defaultBinlogReconnectTimeoutSeconds
+	}
+	if result.ColumnMismatch == "" {
+		result.ColumnMismatch = ColumnMismatchError
+	}
+	if result.FullCopyConflict == "" {
+		result.FullCopyConflict = FullCopyConflictReplace
+	}
+	if result.RowImageCheck == "" {
+		result.RowImageCheck = RowImageCheckOff
+	}
+	if result.RowImageConflict == "" {
+		result.RowImageConflict = RowImageConflictApply
+	}
+	if result.TargetReadOnly == "" {
+		result.TargetReadOnly = TargetReadOnlyOff
+	}
+	if result.BenchTables <= 0 {
+		result.BenchTables = defaultBenchTables
+	}
+	if result.BenchRows <= 0 {
+		result.BenchRows = defaultBenchRows
+	}
+	if result.BenchTransactions <= 0 {
+		result.BenchTransactions = defaultBenchTransactions
+	}
+	if result.VerifyIntervalSeconds <= 0 {
+		result.VerifyIntervalSeconds = defaultVerifyIntervalSeconds
+	}
 
 	// TODO temporarily (or permanently) disable homogeneous replication, hetero only.
 	result.ApproveHeterogeneous = true
 
-	if "" == result.ConnectionConfig.Charset {
+	if result.ConnectionConfig != nil && "" == result.ConnectionConfig.Charset {
 		result.ConnectionConfig.Charset = "utf8mb4"
 	}
 	return &result
 }
 
+// DefaultSystemSchemas are the schemas of MySQL a job doesn't replicate, if
+// its SystemSchemas is empty.
+var DefaultSystemSchemas = []string{"sys", "mysql", "information_schema", "performance_schema"}
+
+// IsSystemSchema is `true` when the schema is one of SystemSchemas, or the
+// schema of dtle.
+func (m *MySQLDriverConfig) IsSystemSchema(schema string) bool {
+	return IsSystemSchema(schema, m.SystemSchemas)
+}
+
+// IsSystemSchema is `true` when the schema is one of systemSchemas, or of
+// DefaultSystemSchemas if it is empty, or the schema of dtle.
+func IsSystemSchema(schema string, systemSchemas []string) bool {
+	if len(systemSchemas) == 0 {
+		systemSchemas = DefaultSystemSchemas
+	}
+	schema = strings.ToLower(schema)
+	if schema == strings.ToLower(g.DtleSchemaName) {
+		return true
+	}
+	for _, systemSchema := range systemSchemas {
+		if schema == strings.ToLower(systemSchema) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTiDB is `true` when the target is TiDB
+func (m *MySQLDriverConfig) IsTiDB() bool {
+	return m.TargetType == TargetTypeTiDB
+}
+
 // RequiresBinlogFormatChange is `true` when the original binlog format isn't `ROW`
 func (m *MySQLDriverConfig) RequiresBinlogFormatChange() bool {
 	return m.BinlogFormat != "ROW"
@@ -429,6 +809,7 @@ func DefaultClientConfig() *ClientConfig {
 	return &ClientConfig{
 		NatsAddr:                "0.0.0.0:8193",
 		ConsulConfig:            DefaultConsulConfig(),
+		TLSConfig:               DefaultTLSConfig(),
 		LogOutput:               os.Stderr,
 		Region:                  "global",
 		StatsCollectionInterval: 1 * time.Second,
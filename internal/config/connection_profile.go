@@ -0,0 +1,41 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// ResolveConnectionProfile returns a copy of the config of a MySQL task with the
+// ConnectionConfig of the profile. The password of a profile with a
+// PasswordVaultPath is read from vault.
+func ResolveConnectionProfile(taskConfig map[string]interface{}, profile *models.ConnectionProfile,
+	vault *VaultConfig) (map[string]interface{}, error) {
+
+	password := profile.Password
+	if profile.PasswordVaultPath != "" {
+		var err error
+		if password, err = vault.ReadSecretField(profile.PasswordVaultPath, "password"); err != nil {
+			return nil, fmt.Errorf("failed to read the password of connection profile %v: %v", profile.Name, err)
+		}
+	}
+
+	resolved := make(map[string]interface{}, len(taskConfig)+1)
+	for k, v := range taskConfig {
+		resolved[k] = v
+	}
+	resolved["ConnectionConfig"] = map[string]interface{}{
+		"Host":     profile.Host,
+		"Port":     profile.Port,
+		"User":     profile.User,
+		"Password": password,
+		"Charset":  profile.Charset,
+	}
+	return resolved, nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestResolveConnectionProfile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/src1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"password":"from vault"},"metadata":{}}}`))
+	}))
+	defer srv.Close()
+	vault := &VaultConfig{Addr: srv.URL, Token: "s.token"}
+
+	taskConfig := map[string]interface{}{"ConnectionProfile": "src1", "Gtid": "x"}
+	profile := &models.ConnectionProfile{Name: "src1", Host: "10.0.0.1", Port: 3306, User: "dtle", Password: "p"}
+	resolved, err := ResolveConnectionProfile(taskConfig, profile, vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := taskConfig["ConnectionConfig"]; ok {
+		t.Errorf("the config of the task was modified")
+	}
+	var driverConfig MySQLDriverConfig
+	if err := mapstructure.WeakDecode(resolved, &driverConfig); err != nil {
+		t.Fatal(err)
+	}
+	c := driverConfig.ConnectionConfig
+	if c == nil || c.Host != "10.0.0.1" || c.Port != 3306 || c.User != "dtle" || c.Password != "p" ||
+		driverConfig.Gtid != "x" || driverConfig.ConnectionProfile != "src1" {
+		t.Errorf("resolved = %+v, ConnectionConfig = %+v", driverConfig, c)
+	}
+
+	profile.Password = ""
+	profile.PasswordVaultPath = "secret/data/src1"
+	if resolved, err = ResolveConnectionProfile(taskConfig, profile, vault); err != nil {
+		t.Fatal(err)
+	}
+	if password := resolved["ConnectionConfig"].(map[string]interface{})["Password"]; password != "from vault" {
+		t.Errorf("Password = %v", password)
+	}
+
+	profile.PasswordVaultPath = "secret/data/src2"
+	if _, err := ResolveConnectionProfile(taskConfig, profile, vault); err == nil {
+		t.Errorf("ResolveConnectionProfile() with a missing secret succeeded")
+	}
+}
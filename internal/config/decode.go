@@ -0,0 +1,31 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeTaskConfig decodes the config of a task into result the same as
+// mapstructure.WeakDecode, but fails on a key result has no field for, e.g.
+// a misspelled one, instead of ignoring it.
+func DecodeTaskConfig(taskConfig map[string]interface{}, result interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           result,
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(taskConfig); err != nil {
+		return fmt.Errorf("invalid task config: %v", err)
+	}
+	return nil
+}
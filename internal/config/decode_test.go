@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeTaskConfig(t *testing.T) {
+	var cfg MySQLDriverConfig
+	err := DecodeTaskConfig(map[string]interface{}{
+		"Gtid":            "",
+		"ParallelWorkers": "4",
+		"ConnectionConfig": map[string]interface{}{
+			"Host": "127.0.0.1",
+			"Port": "3306",
+		},
+	}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ParallelWorkers != 4 || cfg.ConnectionConfig.Port != 3306 {
+		t.Errorf("decoded %+v", cfg)
+	}
+
+	for _, taskConfig := range []map[string]interface{}{
+		{"ReplicateDoDbs": []interface{}{}},
+		{"ConnectionConfig": map[string]interface{}{"Hots": "127.0.0.1"}},
+	} {
+		err := DecodeTaskConfig(taskConfig, &MySQLDriverConfig{})
+		if err == nil || !strings.Contains(err.Error(), "invalid keys") {
+			t.Errorf("DecodeTaskConfig(%v) error = %v", taskConfig, err)
+		}
+	}
+}
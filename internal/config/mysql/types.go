@@ -48,6 +48,7 @@ const (
 	VarcharColumnType
 	BlobColumnType
 	BooleanColumnType
+	GeometryColumnType
 	// TODO: more type
 )
 
@@ -80,6 +81,10 @@ func (c *Column) ConvertArg(arg interface{}) interface{} {
 		return ""
 	}
 
+	if c.Type == GeometryColumnType {
+		// The internal format of the geometry, SRID + WKB, is binary.
+		return arg
+	}
 	if strings.Contains(c.ColumnType, "text") {
 		if encoding, ok := charsetEncodingMap[c.Charset]; ok {
 			arg, _, _ = transform.String(encoding.NewDecoder(), fmt.Sprintf("%s", arg))
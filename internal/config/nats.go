@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+)
+
+// NatsConfig is the configuration of the NATS servers the tasks of a job
+// exchange data through.
+//
+// By default each agent runs an embedded NATS streaming server, and the tasks
+// connect to that of the agent running the Src task. This single broker per
+// agent is deprecated in favor of an external NATS cluster: when Servers is
+// set, the embedded server is not started, and the tasks of all the agents
+// connect to the servers, reconnecting to another one if a server is lost.
+type NatsConfig struct {
+	// Servers are the addresses, as "host:port", of the servers of an external
+	// NATS cluster.
+	Servers []string `mapstructure:"servers"`
+
+	// ReconnectWait is the time to wait between the attempts to reconnect.
+	ReconnectWait time.Duration `mapstructure:"reconnect_wait"`
+
+	// MaxReconnects is the max number of attempts to reconnect before giving
+	// up, and -1 for no limit.
+	MaxReconnects int `mapstructure:"max_reconnects"`
+
+	// PingInterval is the interval of the pings to the server, to detect a
+	// lost connection.
+	PingInterval time.Duration `mapstructure:"ping_interval"`
+
+	// MaxPingsOut is the number of pings without a reply before the
+	// connection is considered lost.
+	MaxPingsOut int `mapstructure:"max_pings_out"`
+
+	// EncryptKey is the base64 of at least 16 random bytes, the same on all
+	// the agents. The key of each job, encrypting the data the Src task sends
+	// to the Dest task, is derived from it. Empty for no encryption, unless
+	// the job has a key in Vault.
+	EncryptKey string `mapstructure:"encrypt_key"`
+}
+
+// minEncryptKeySize is the min bytes of EncryptKey.
+const minEncryptKeySize = 16
+
+// Validate checks the EncryptKey.
+func (c *NatsConfig) Validate() error {
+	_, err := c.encryptKey()
+	return err
+}
+
+func (c *NatsConfig) encryptKey() ([]byte, error) {
+	if c == nil || c.EncryptKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt_key is not valid base64: %v", err)
+	}
+	if len(key) < minEncryptKeySize {
+		return nil, fmt.Errorf("encrypt_key must be at least %d bytes, got %d", minEncryptKeySize, len(key))
+	}
+	return key, nil
+}
+
+// TrafficKey returns the AES-256 key of the job derived from EncryptKey, nil
+// if there is no EncryptKey.
+func (c *NatsConfig) TrafficKey(jobID string) ([]byte, error) {
+	key, err := c.encryptKey()
+	if err != nil || key == nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("dtle traffic key\x00" + jobID))
+	return mac.Sum(nil), nil
+}
+
+// External tells if the tasks connect to an external NATS cluster instead of
+// the embedded server.
+func (c *NatsConfig) External() bool {
+	return c != nil && len(c.Servers) > 0
+}
+
+// Addr returns the address of the external cluster, which is given to the
+// tasks as their NatsAddr.
+func (c *NatsConfig) Addr() string {
+	return strings.Join(c.Servers, ",")
+}
+
+// Options returns the options of the connections of the tasks. The defaults of
+// the NATS client are kept for the zero values.
+func (c *NatsConfig) Options() []gonats.Option {
+	if c == nil {
+		return nil
+	}
+	var opts []gonats.Option
+	if c.ReconnectWait != 0 {
+		opts = append(opts, gonats.ReconnectWait(c.ReconnectWait))
+	}
+	if c.MaxReconnects != 0 {
+		opts = append(opts, gonats.MaxReconnects(c.MaxReconnects))
+	}
+	if c.PingInterval != 0 || c.MaxPingsOut != 0 {
+		pingInterval, maxPingsOut := c.PingInterval, c.MaxPingsOut
+		opts = append(opts, func(o *gonats.Options) error {
+			if pingInterval != 0 {
+				o.PingInterval = pingInterval
+			}
+			if maxPingsOut != 0 {
+				o.MaxPingsOut = maxPingsOut
+			}
+			return nil
+		})
+	}
+	return opts
+}
+
+// Merge merges two NATS configurations together.
+func (a *NatsConfig) Merge(b *NatsConfig) *NatsConfig {
+	result := *a
+
+	if len(b.Servers) != 0 {
+		result.Servers = b.Servers
+	}
+	if b.ReconnectWait != 0 {
+		result.ReconnectWait = b.ReconnectWait
+	}
+	if b.MaxReconnects != 0 {
+		result.MaxReconnects = b.MaxReconnects
+	}
+	if b.PingInterval != 0 {
+		result.PingInterval = b.PingInterval
+	}
+	if b.MaxPingsOut != 0 {
+		result.MaxPingsOut = b.MaxPingsOut
+	}
+	if b.EncryptKey != "" {
+		result.EncryptKey = b.EncryptKey
+	}
+	return &result
+}
+
+// NatsURL returns the URL to connect to the NatsAddr of a task, which is
+// either the address of an embedded server or the comma separated addresses
+// of the servers of a cluster.
+func NatsURL(natsAddr string) string {
+	var urls []string
+	for _, addr := range strings.Split(natsAddr, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addr = "nats://" + addr
+		}
+		urls = append(urls, addr)
+	}
+	return strings.Join(urls, ",")
+}
+
+// NatsConnect connects a task to the NATS server(s) of natsAddr, with the
+// options of c followed by opts.
+func NatsConnect(natsAddr string, c *NatsConfig, opts ...gonats.Option) (*gonats.Conn, error) {
+	return gonats.Connect(NatsURL(natsAddr), append(c.Options(), opts...)...)
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+)
+
+func TestNatsURL(t *testing.T) {
+	for natsAddr, url := range map[string]string{
+		"127.0.0.1:8193":                        "nats://127.0.0.1:8193",
+		"10.0.0.1:4222,10.0.0.2:4222":           "nats://10.0.0.1:4222,nats://10.0.0.2:4222",
+		" 10.0.0.1:4222 , tls://10.0.0.2:4222,": "nats://10.0.0.1:4222,tls://10.0.0.2:4222",
+	} {
+		if got := NatsURL(natsAddr); got != url {
+			t.Errorf("NatsURL(%q) = %q, want %q", natsAddr, got, url)
+		}
+	}
+}
+
+func TestNatsConfig_Options(t *testing.T) {
+	var nilConfig *NatsConfig
+	if nilConfig.External() || len(nilConfig.Options()) != 0 {
+		t.Fatalf("nil config should be the embedded server with the default options")
+	}
+
+	c := &NatsConfig{
+		Servers:       []string{"10.0.0.1:4222", "10.0.0.2:4222"},
+		ReconnectWait: 5 * time.Second,
+		MaxReconnects: -1,
+		PingInterval:  10 * time.Second,
+	}
+	if !c.External() || c.Addr() != "10.0.0.1:4222,10.0.0.2:4222" {
+		t.Fatalf("unexpected external %v addr %v", c.External(), c.Addr())
+	}
+	opts := gonats.GetDefaultOptions()
+	for _, opt := range c.Options() {
+		if err := opt(&opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opts.ReconnectWait != 5*time.Second || opts.MaxReconnect != -1 ||
+		opts.PingInterval != 10*time.Second || opts.MaxPingsOut != gonats.DefaultMaxPingOut {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+}
+
+func TestNatsConfig_Merge(t *testing.T) {
+	a := &NatsConfig{Servers: []string{"10.0.0.1:4222"}, MaxReconnects: 10}
+	b := &NatsConfig{PingInterval: time.Minute, MaxReconnects: 20}
+	result := a.Merge(b)
+	if len(result.Servers) != 1 || result.MaxReconnects != 20 || result.PingInterval != time.Minute {
+		t.Fatalf("unexpected merge result %+v", result)
+	}
+}
+
+func TestNatsConfig_TrafficKey(t *testing.T) {
+	var nilConfig *NatsConfig
+	if key, err := nilConfig.TrafficKey("job1"); key != nil || err != nil {
+		t.Fatalf("nil config should have no key, got %v, %v", key, err)
+	}
+
+	c := &NatsConfig{EncryptKey: "MDEyMzQ1Njc4OWFiY2RlZg=="}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	key1, err := c.TrafficKey("job1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, _ := c.TrafficKey("job2")
+	again, _ := c.TrafficKey("job1")
+	if len(key1) != 32 || string(key1) == string(key2) || string(key1) != string(again) {
+		t.Fatalf("unexpected keys %x %x %x", key1, key2, again)
+	}
+
+	for _, bad := range []string{"not base64!", "c2hvcnQ="} {
+		if err := (&NatsConfig{EncryptKey: bad}).Validate(); err == nil {
+			t.Errorf("Validate() accepted %q", bad)
+		}
+	}
+}
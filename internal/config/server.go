@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
 
+	"github.com/actiontech/dtle/internal/models"
 	"github.com/actiontech/dtle/internal/server/scheduler"
 )
 
@@ -124,6 +125,21 @@ type ServerConfig struct {
 	// as well as clock skew.
 	HeartbeatGrace time.Duration
 
+	// EvalGCThreshold, JobGCThreshold and NodeGCThreshold are how long a
+	// terminal evaluation (with its allocations), a terminal job and a down
+	// node are kept before being garbage collected.
+	EvalGCThreshold time.Duration
+	JobGCThreshold  time.Duration
+	NodeGCThreshold time.Duration
+
+	// GCInterval is the interval of the garbage collection. Zero disables the
+	// periodic garbage collection.
+	GCInterval time.Duration
+
+	// AuditGCThreshold is how long the audit events are kept. Zero keeps them
+	// forever.
+	AuditGCThreshold time.Duration
+
 	// FailoverHeartbeatTTL is the TTL applied to heartbeats after
 	// a new leader is elected, since we no longer know the status
 	// of all the heartbeats.
@@ -132,12 +148,28 @@ type ServerConfig struct {
 	// ConsulConfig is this Agent's Consul configuration
 	ConsulConfig *ConsulConfig
 
+	// TLSConfig is the mutual TLS of the RPC, whose certificates the server
+	// issues with the built-in CA.
+	TLSConfig *TLSConfig
+
 	// RPCHoldTimeout is how long an RPC can be "held" before it is errored.
 	// This is used to paper over a loss of leadership by instead holding RPCs,
 	// so that the caller experiences a slow response rather than an error.
 	// This period is meant to be long enough for a leader election to take
 	// place, and a small jitter is applied to avoid a thundering herd.
 	RPCHoldTimeout time.Duration
+
+	// RPCHandshakeTimeout is the time allowed for a new RPC connection to
+	// send its type and complete its TLS handshake. 0 means no timeout.
+	RPCHandshakeTimeout time.Duration
+
+	// RPCMaxConnsPerClient is the max number of concurrent RPC connections of
+	// a client IP. 0 means no limit.
+	RPCMaxConnsPerClient int
+
+	// NamespaceQuotas are the quotas of the namespaces. The jobs of the other
+	// namespaces are not limited.
+	NamespaceQuotas map[string]*models.NamespaceQuota
 }
 
 // DefaultConfig returns the default configuration
@@ -164,8 +196,16 @@ func DefaultServerConfig() *ServerConfig {
 		MaxHeartbeatsPerSecond: 50.0,
 		HeartbeatGrace:         10 * time.Second,
 		FailoverHeartbeatTTL:   300 * time.Second,
+		EvalGCThreshold:        1 * time.Hour,
+		JobGCThreshold:         24 * time.Hour,
+		NodeGCThreshold:        24 * time.Hour,
+		GCInterval:             5 * time.Minute,
+		AuditGCThreshold:       30 * 24 * time.Hour,
 		ConsulConfig:           DefaultConsulConfig(),
+		TLSConfig:              DefaultTLSConfig(),
 		RPCHoldTimeout:         5 * time.Second,
+		RPCHandshakeTimeout:    5 * time.Second,
+		RPCMaxConnsPerClient:   100,
 	}
 
 	// Enable all known schedulers by default
@@ -179,6 +219,9 @@ func DefaultServerConfig() *ServerConfig {
 	// Increase our reap interval to 3 days instead of 24h.
 	c.SerfConfig.ReconnectTimeout = 3 * 24 * time.Hour
 
+	// Rejoin the cluster known before a restart, even after leaving it.
+	c.SerfConfig.RejoinAfterLeave = true
+
 	// Serf should use the WAN timing, since we are using it
 	// to communicate between DC's
 	c.SerfConfig.MemberlistConfig = memberlist.DefaultWANConfig()
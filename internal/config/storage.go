@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"fmt"
+)
+
+// The types of object storage.
+const (
+	StorageTypeS3    = "s3"
+	StorageTypeOSS   = "oss"
+	StorageTypeMinIO = "minio"
+	// StorageTypeFile stores the objects as files under Path, e.g. on a
+	// shared file system.
+	StorageTypeFile = "file"
+)
+
+// StorageConfig is the configuration of the object storage the agent keeps
+// the dumps exported and the snapshots of the managers in.
+type StorageConfig struct {
+	// Type is s3, oss, minio or file.
+	Type string `mapstructure:"type"`
+
+	// Endpoint is the URL of the service, e.g. "https://oss-cn-hangzhou.aliyuncs.com"
+	// or "http://minio:9000". Defaults to the endpoint of Region for s3.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Region is the region of the bucket, "us-east-1" by default.
+	Region string `mapstructure:"region"`
+
+	Bucket string `mapstructure:"bucket"`
+
+	// Prefix is prepended to the keys of the objects, e.g. "dtle/cluster1/".
+	Prefix string `mapstructure:"prefix"`
+
+	// AccessKey and SecretKey are the credentials, defaulting to the
+	// environment and the instance profile of s3.
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+
+	// Path is the directory of the file type.
+	Path string `mapstructure:"path"`
+}
+
+// Merge merges two storage configurations together.
+func (a *StorageConfig) Merge(b *StorageConfig) *StorageConfig {
+	result := *a
+
+	if b.Type != "" {
+		result.Type = b.Type
+	}
+	if b.Endpoint != "" {
+		result.Endpoint = b.Endpoint
+	}
+	if b.Region != "" {
+		result.Region = b.Region
+	}
+	if b.Bucket != "" {
+		result.Bucket = b.Bucket
+	}
+	if b.Prefix != "" {
+		result.Prefix = b.Prefix
+	}
+	if b.AccessKey != "" {
+		result.AccessKey = b.AccessKey
+	}
+	if b.SecretKey != "" {
+		result.SecretKey = b.SecretKey
+	}
+	if b.Path != "" {
+		result.Path = b.Path
+	}
+	return &result
+}
+
+// Validate returns an error if the storage could not be used.
+func (c *StorageConfig) Validate() error {
+	switch c.Type {
+	case StorageTypeS3:
+		if c.Bucket == "" {
+			return fmt.Errorf("bucket is required")
+		}
+	case StorageTypeOSS, StorageTypeMinIO:
+		if c.Bucket == "" || c.Endpoint == "" {
+			return fmt.Errorf("bucket and endpoint are required for %v", c.Type)
+		}
+	case StorageTypeFile:
+		if c.Path == "" {
+			return fmt.Errorf("path is required for %v", c.Type)
+		}
+	default:
+		return fmt.Errorf("unknown type %q, should be %v, %v, %v or %v",
+			c.Type, StorageTypeS3, StorageTypeOSS, StorageTypeMinIO, StorageTypeFile)
+	}
+	return nil
+}
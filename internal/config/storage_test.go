@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestStorageConfig_Validate(t *testing.T) {
+	for _, c := range []struct {
+		cfg StorageConfig
+		ok  bool
+	}{
+		{StorageConfig{Type: StorageTypeS3, Bucket: "b"}, true},
+		{StorageConfig{Type: StorageTypeS3}, false},
+		{StorageConfig{Type: StorageTypeOSS, Bucket: "b", Endpoint: "https://oss-cn-hangzhou.aliyuncs.com"}, true},
+		{StorageConfig{Type: StorageTypeMinIO, Bucket: "b"}, false},
+		{StorageConfig{Type: StorageTypeFile, Path: "/data"}, true},
+		{StorageConfig{Type: StorageTypeFile}, false},
+		{StorageConfig{Type: "gcs", Bucket: "b"}, false},
+	} {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v", c.cfg, err)
+		}
+	}
+}
+
+func TestStorageConfig_Merge(t *testing.T) {
+	a := &StorageConfig{Type: StorageTypeS3, Bucket: "a", Region: "us-west-2"}
+	b := &StorageConfig{Bucket: "b", Prefix: "dtle/"}
+	got := a.Merge(b)
+	if got.Type != StorageTypeS3 || got.Bucket != "b" || got.Region != "us-west-2" || got.Prefix != "dtle/" {
+		t.Errorf("got %+v", got)
+	}
+	if a.Bucket != "a" {
+		t.Errorf("Merge() changed the receiver")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// TLSConfig secures the RPC between the agents with mutual TLS. The
+// certificates are issued by the CA built in the servers, and renewed before
+// they expire, so no PKI has to be managed.
+type TLSConfig struct {
+	// EnableRPC dials the servers with TLS, once the agent has a certificate.
+	EnableRPC bool `mapstructure:"rpc"`
+
+	// VerifyIncoming makes the servers refuse the RPC connections without TLS,
+	// and the TLS connections without a certificate of the CA, except to get
+	// one. Enable it once all the agents have EnableRPC.
+	VerifyIncoming bool `mapstructure:"verify_incoming"`
+
+	// BootstrapToken is the secret shared by the agents, which the clients give
+	// to get a certificate signed.
+	BootstrapToken string `mapstructure:"bootstrap_token"`
+
+	// CAFingerprint is the SHA-256, in hex, of a root of the CA, which the
+	// clients verify the servers against when getting their first
+	// certificate. The clients need it along with BootstrapToken, not to give
+	// the token to a server they cannot trust.
+	CAFingerprint string `mapstructure:"ca_fingerprint"`
+
+	// CertTTL is how long the certificates of the agents are valid. They are
+	// renewed at half of it.
+	CertTTL time.Duration `mapstructure:"cert_ttl"`
+
+	// CAKey is the base64 of at least 16 random bytes, the same on all the
+	// servers and only on them. The private keys of the roots of the CA,
+	// replicated by Raft, are sealed with a key derived from it.
+	CAKey string `mapstructure:"ca_key"`
+}
+
+// minCAKeySize is the min bytes of CAKey.
+const minCAKeySize = 16
+
+// CASealKey returns the AES-256 key sealing the private keys of the CA, derived
+// from CAKey, nil if there is no CAKey.
+func (c *TLSConfig) CASealKey() ([]byte, error) {
+	if c == nil || c.CAKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.CAKey)
+	if err != nil {
+		return nil, fmt.Errorf("ca_key is not valid base64: %v", err)
+	}
+	if len(key) < minCAKeySize {
+		return nil, fmt.Errorf("ca_key must be at least %d bytes, got %d", minCAKeySize, len(key))
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("dtle ca seal key"))
+	return mac.Sum(nil), nil
+}
+
+// DefaultTLSConfig returns the defaults of the `tls` configuration.
+func DefaultTLSConfig() *TLSConfig {
+	return &TLSConfig{
+		CertTTL: 72 * time.Hour,
+	}
+}
+
+// Copy returns a copy of the configuration.
+func (c *TLSConfig) Copy() *TLSConfig {
+	if c == nil {
+		return nil
+	}
+	nc := *c
+	return &nc
+}
+
+// Merge merges two TLS configurations together.
+func (c *TLSConfig) Merge(b *TLSConfig) *TLSConfig {
+	result := c.Copy()
+
+	if b.EnableRPC {
+		result.EnableRPC = true
+	}
+	if b.VerifyIncoming {
+		result.VerifyIncoming = true
+	}
+	if b.BootstrapToken != "" {
+		result.BootstrapToken = b.BootstrapToken
+	}
+	if b.CAFingerprint != "" {
+		result.CAFingerprint = b.CAFingerprint
+	}
+	if b.CertTTL != 0 {
+		result.CertTTL = b.CertTTL
+	}
+	if b.CAKey != "" {
+		result.CAKey = b.CAKey
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTimeout is the timeout of the requests to Vault.
+const vaultTimeout = 10 * time.Second
+
+// VaultConfig is the configuration of the Vault server the agent reads the
+// keys of the jobs from.
+type VaultConfig struct {
+	// Addr is the address of Vault, e.g. "https://vault.example.com:8200".
+	// Defaults to $VAULT_ADDR.
+	Addr string `mapstructure:"address"`
+
+	// Token is the token to read the keys with. Defaults to $VAULT_TOKEN.
+	Token string `mapstructure:"token"`
+}
+
+// Merge merges two Vault configurations together.
+func (a *VaultConfig) Merge(b *VaultConfig) *VaultConfig {
+	result := *a
+
+	if b.Addr != "" {
+		result.Addr = b.Addr
+	}
+	if b.Token != "" {
+		result.Token = b.Token
+	}
+	return &result
+}
+
+// ReadKey reads the base64 key in the field "key" of the secret at the path,
+// of either version of the KV secrets engine.
+func (c *VaultConfig) ReadKey(path string) ([]byte, error) {
+	encoded, err := c.ReadSecretField(path, "key")
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key in %v is not valid base64: %v", path, err)
+	}
+	return key, nil
+}
+
+// ReadSecretField reads the string field of the secret at the path, of either
+// version of the KV secrets engine.
+func (c *VaultConfig) ReadSecretField(path, field string) (string, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if c != nil && c.Addr != "" {
+		addr = c.Addr
+	}
+	if c != nil && c.Token != "" {
+		token = c.Token
+	}
+	if addr == "" {
+		return "", fmt.Errorf("no vault address configured to read %v", path)
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := (&http.Client{Timeout: vaultTimeout}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read %v from vault: %v", path, resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		// KV version 2
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("no field %q in %v", field, path)
+	}
+	return value, nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultConfig_ReadKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/job1":
+			w.Write([]byte(`{"data":{"key":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`))
+		case "/v1/secret/data/job2":
+			w.Write([]byte(`{"data":{"data":{"key":"MDEyMzQ1Njc4OWFiY2RlZg=="},"metadata":{}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := &VaultConfig{Addr: srv.URL, Token: "s.token"}
+	for _, path := range []string{"secret/job1", "/secret/data/job2"} {
+		if key, err := c.ReadKey(path); err != nil || string(key) != "0123456789abcdef" {
+			t.Errorf("ReadKey(%q) = %q, %v", path, key, err)
+		}
+	}
+	if _, err := c.ReadKey("secret/job3"); err == nil {
+		t.Errorf("ReadKey() of a missing secret succeeded")
+	}
+	if _, err := (&VaultConfig{Addr: srv.URL, Token: "bad"}).ReadKey("secret/job1"); err == nil {
+		t.Errorf("ReadKey() with a bad token succeeded")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package connlimit limits the concurrent connections of each client IP, so a
+// misbehaving client could not use up the file descriptors of a server.
+package connlimit
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrPerClientIPLimitReached is returned by Accept when the client IP has the
+// max number of connections.
+var ErrPerClientIPLimitReached = errors.New("too many concurrent connections from the client IP")
+
+// Limiter counts the open connections of each client IP.
+type Limiter struct {
+	max   int
+	mu    sync.Mutex
+	conns map[string]int
+}
+
+// NewLimiter returns a Limiter of max connections per client IP. 0 means no
+// limit.
+func NewLimiter(max int) *Limiter {
+	return &Limiter{
+		max:   max,
+		conns: make(map[string]int),
+	}
+}
+
+// Accept counts the connection, and returns it wrapped to be uncounted when it
+// is closed. If the client IP has the max number of connections already, the
+// connection is not counted nor closed, and ErrPerClientIPLimitReached is
+// returned.
+func (l *Limiter) Accept(conn net.Conn) (net.Conn, error) {
+	if l.max <= 0 {
+		return conn, nil
+	}
+	ip := clientIP(conn.RemoteAddr())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] >= l.max {
+		return nil, ErrPerClientIPLimitReached
+	}
+	l.conns[ip]++
+	return &limitedConn{Conn: conn, free: func() { l.free(ip) }}, nil
+}
+
+// NumConns returns the number of open connections of the client IP.
+func (l *Limiter) NumConns(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conns[ip]
+}
+
+func (l *Limiter) free(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] <= 1 {
+		delete(l.conns, ip)
+	} else {
+		l.conns[ip]--
+	}
+}
+
+// clientIP returns the IP of the address, or the whole address if it has
+// none, e.g. a unix socket.
+func clientIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// limitedConn uncounts the connection once on Close.
+type limitedConn struct {
+	net.Conn
+	once sync.Once
+	free func()
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.free)
+	return c.Conn.Close()
+}
+
+// Listener wraps a listener to limit the connections accepted. A connection
+// over the limit is given to reject, which should close it, e.g. after
+// writing an error to the client.
+type Listener struct {
+	net.Listener
+	Limiter *Limiter
+	Reject  func(conn net.Conn)
+}
+
+// Accept returns the next connection within the limit.
+func (ln *Listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		limited, err := ln.Limiter.Accept(conn)
+		if err == nil {
+			return limited, nil
+		}
+		if ln.Reject != nil {
+			ln.Reject(conn)
+		} else {
+			conn.Close()
+		}
+	}
+}
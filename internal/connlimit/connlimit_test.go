@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package connlimit
+
+import (
+	"net"
+	"testing"
+)
+
+type testConn struct {
+	net.Conn
+	addr   net.Addr
+	closed bool
+}
+
+func (c *testConn) RemoteAddr() net.Addr { return c.addr }
+func (c *testConn) Close() error         { c.closed = true; return nil }
+
+func newTestConn(ip string) *testConn {
+	return &testConn{addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2)
+
+	c1, err := l.Accept(newTestConn("10.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Accept(newTestConn("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Accept(newTestConn("10.0.0.1")); err != ErrPerClientIPLimitReached {
+		t.Fatalf("expected the limit reached, got %v", err)
+	}
+	if _, err := l.Accept(newTestConn("10.0.0.2")); err != nil {
+		t.Fatalf("another client IP: %v", err)
+	}
+
+	c1.Close()
+	c1.Close()
+	if n := l.NumConns("10.0.0.1"); n != 1 {
+		t.Fatalf("expected 1 conn after close, got %v", n)
+	}
+	if _, err := l.Accept(newTestConn("10.0.0.1")); err != nil {
+		t.Fatalf("after close: %v", err)
+	}
+}
+
+func TestLimiter_NoLimit(t *testing.T) {
+	l := NewLimiter(0)
+	for i := 0; i < 10; i++ {
+		if _, err := l.Accept(newTestConn("10.0.0.1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := make(chan struct{}, 1)
+	limited := &Listener{
+		Listener: ln,
+		Limiter:  NewLimiter(1),
+		Reject: func(conn net.Conn) {
+			conn.Close()
+			rejected <- struct{}{}
+		},
+	}
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	<-accepted
+
+	c2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	<-rejected
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package crash writes a crash report when the agent panics: the stack traces
+// of all the goroutines, the last lines of the log, and the states given by
+// the sources of the reporter, e.g. the allocations and the config, with the
+// secrets redacted. The reports are kept in the data dir, to be collected by
+// "dtle debug".
+package crash
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxReports is the number of the latest reports kept in the dir.
+	maxReports = 10
+
+	// sourceTimeout bounds the collection of a source, which could wait for a
+	// lock held by the goroutine panicking.
+	sourceTimeout = 2 * time.Second
+
+	// maxStackSize bounds the stack traces of all the goroutines.
+	maxStackSize = 64 * 1024 * 1024
+)
+
+// reportNameRegexp matches the names of the reports, crash-<UTC time>.tar.gz.
+var reportNameRegexp = regexp.MustCompile(`^crash-[0-9]{8}T[0-9]{6}(\.[0-9]+)?Z\.tar\.gz$`)
+
+// Dir returns the dir of the crash reports in the data dir.
+func Dir(dataDir string) string {
+	if dataDir == "" {
+		dataDir = os.TempDir()
+	}
+	return filepath.Join(dataDir, "crash")
+}
+
+type source struct {
+	name    string
+	collect func() interface{}
+}
+
+// Reporter writes the crash reports to a dir.
+type Reporter struct {
+	dir     string
+	version string
+	logs    *LogRing
+
+	mu      sync.Mutex
+	sources []source
+	written bool
+}
+
+// NewReporter returns a Reporter writing to dir. The logs, if any, are the last
+// lines of the log, redacted by RedactString.
+func NewReporter(dir, version string, logs *LogRing) *Reporter {
+	return &Reporter{
+		dir:     dir,
+		version: version,
+		logs:    logs,
+	}
+}
+
+// AddSource adds a state to the reports, as <name>.json. The state is redacted
+// by Redact.
+func (r *Reporter) AddSource(name string, collect func() interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = append(r.sources, source{name: name, collect: collect})
+}
+
+// Write writes a report of the reason, e.g. a panic, with the stack traces of
+// all the goroutines, and returns its path. Only the first report of the
+// process is written.
+func (r *Reporter) Write(reason string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.written {
+		return "", fmt.Errorf("a crash report was written already")
+	}
+	r.written = true
+
+	now := time.Now().UTC()
+	var panicTxt bytes.Buffer
+	fmt.Fprintf(&panicTxt, "%v\n\ntime: %v\nversion: %v\ngo: %v\n\n", reason,
+		now.Format(time.RFC3339Nano), r.version, runtime.Version())
+	panicTxt.Write(allStacks())
+
+	files := []file{{name: "panic.txt", data: panicTxt.Bytes()}}
+	if r.logs != nil {
+		lines := r.logs.Lines()
+		for i := range lines {
+			lines[i] = RedactString(lines[i])
+		}
+		files = append(files, file{name: "log.txt", data: []byte(strings.Join(lines, "\n") + "\n")})
+	}
+	for _, s := range r.sources {
+		files = append(files, file{name: s.name + ".json", data: collectSource(s)})
+	}
+
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.dir, "crash-"+now.Format("20060102T150405.000000000Z")+".tar.gz")
+	if err := writeTarGz(path, files); err != nil {
+		return "", err
+	}
+	pruneReports(r.dir)
+	return path, nil
+}
+
+// collectSource returns the redacted state of the source as JSON, or the error
+// collecting it.
+func collectSource(s source) []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				ch <- []byte(fmt.Sprintf("{\"Error\": %q}\n", fmt.Sprintf("panic: %v", v)))
+			}
+		}()
+		data, err := json.MarshalIndent(Redact(s.collect()), "", "  ")
+		if err != nil {
+			data = []byte(fmt.Sprintf("{\"Error\": %q}", err.Error()))
+		}
+		ch <- append(data, '\n')
+	}()
+	select {
+	case data := <-ch:
+		return data
+	case <-time.After(sourceTimeout):
+		return []byte(fmt.Sprintf("{\"Error\": \"timed out after %v\"}\n", sourceTimeout))
+	}
+}
+
+// allStacks returns the stack traces of all the goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 1024*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackSize {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+func writeTarGz(path string, files []file) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	dir := strings.TrimSuffix(filepath.Base(path), ".tar.gz")
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    dir + "/" + f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// ReportInfo describes a crash report in the dir.
+type ReportInfo struct {
+	Name string
+	Size int64
+	// ModifyTime is in UnixNano.
+	ModifyTime int64
+}
+
+// ListReports returns the crash reports in the dir, the oldest first.
+func ListReports(dir string) ([]*ReportInfo, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var reports []*ReportInfo
+	for _, e := range entries {
+		if e.Mode().IsRegular() && reportNameRegexp.MatchString(e.Name()) {
+			reports = append(reports, &ReportInfo{
+				Name:       e.Name(),
+				Size:       e.Size(),
+				ModifyTime: e.ModTime().UnixNano(),
+			})
+		}
+	}
+	// The names sort by time.
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports, nil
+}
+
+// ReportPath returns the path of the crash report of the name in the dir. The
+// name should be one of ListReports.
+func ReportPath(dir, name string) (string, error) {
+	if !reportNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid crash report name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// pruneReports removes the reports but the latest maxReports.
+func pruneReports(dir string) {
+	reports, err := ListReports(dir)
+	if err != nil {
+		return
+	}
+	for len(reports) > maxReports {
+		os.Remove(filepath.Join(dir, reports[0].Name))
+		reports = reports[1:]
+	}
+}
+
+var (
+	reporterLock sync.Mutex
+	reporter     *Reporter
+)
+
+// SetReporter sets the reporter of HandlePanic.
+func SetReporter(r *Reporter) {
+	reporterLock.Lock()
+	defer reporterLock.Unlock()
+	reporter = r
+}
+
+// HandlePanic writes a crash report of a panic, then panics again, so the
+// process still crashes. It should be deferred first in a goroutine, e.g. that
+// of a task:
+//
+//	go func() {
+//		defer crash.HandlePanic()
+//		...
+//	}()
+func HandlePanic() {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	reporterLock.Lock()
+	r := reporter
+	reporterLock.Unlock()
+	if r != nil {
+		if path, err := r.Write(fmt.Sprintf("panic: %v", v)); err == nil {
+			fmt.Fprintf(os.Stderr, "Wrote the crash report %v\n", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to write the crash report: %v\n", err)
+		}
+	}
+	panic(v)
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package crash
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogRing is a writer keeping the last lines written to it, e.g. of the log,
+// for the crash reports.
+type LogRing struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// NewLogRing returns a LogRing of the last n lines.
+func NewLogRing(n int) *LogRing {
+	return &LogRing{lines: make([]string, n)}
+}
+
+// Write keeps the lines of p. A line not ended yet is kept until it is.
+func (r *LogRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			r.partial = append(r.partial, data...)
+			break
+		}
+		r.add(string(append(r.partial, data[:i]...)))
+		r.partial = r.partial[:0]
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+func (r *LogRing) add(line string) {
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns the lines kept, the oldest first, and the line not ended yet.
+func (r *LogRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	if len(r.partial) > 0 {
+		lines = append(lines, string(r.partial))
+	}
+	return lines
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package crash
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Redacted replaces the secrets in the redacted values.
+const Redacted = "<redacted>"
+
+var (
+	// secretKeyRegexp matches the names of the fields and keys of secrets,
+	// e.g. ManagementTokens, ClientSecret, Password, EncryptKey (of the nats)
+	// or Auth (user:password of consul), but not the paths of the files of
+	// keys, e.g. KeyFile.
+	secretKeyRegexp = regexp.MustCompile(`(?i)(token|secret|passw|credential|encrypt|private_?key|access_?key|api_?key|^auth$)`)
+
+	// urlUserinfoRegexp matches the user and password of a URL.
+	urlUserinfoRegexp = regexp.MustCompile(`://[^/@\s]+@`)
+
+	// argRegexp matches the key=value args of a string, e.g. of the cloud
+	// auto-join strings of retry_join, the value possibly quoted.
+	argRegexp = regexp.MustCompile(`(^|\s)([A-Za-z0-9_]+)=("[^"]*"|[^\s"]*)`)
+)
+
+// Redact returns v as JSON values, with the values of the secret fields and
+// keys, and the passwords of the URLs, replaced.
+func Redact(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return map[string]string{"Error": err.Error()}
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]string{"Error": err.Error()}
+	}
+	return redactValue(out)
+}
+
+// IsSecretKey returns whether the values of the field or key are secrets.
+func IsSecretKey(k string) bool {
+	return secretKeyRegexp.MatchString(k)
+}
+
+// ReplaceURLUserinfo replaces the user and password of the URLs of s by repl.
+func ReplaceURLUserinfo(s string, repl string) string {
+	return urlUserinfoRegexp.ReplaceAllLiteralString(s, "://"+repl+"@")
+}
+
+// RedactString returns s with the passwords of the URLs and the values of the
+// secret key=value args, e.g. secret_access_key=..., replaced.
+func RedactString(s string) string {
+	s = ReplaceURLUserinfo(s, Redacted)
+	return argRegexp.ReplaceAllStringFunc(s, func(arg string) string {
+		m := argRegexp.FindStringSubmatch(arg)
+		if !IsSecretKey(m[2]) || m[3] == "" {
+			return arg
+		}
+		return m[1] + m[2] + "=" + Redacted
+	})
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if IsSecretKey(k) && !isEmpty(e) {
+				v[k] = Redacted
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+		return v
+	case string:
+		return RedactString(v)
+	}
+	return v
+}
+
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package discover
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// awsProvider finds the running EC2 instances with a tag, by the EC2 API.
+type awsProvider struct{}
+
+func (p *awsProvider) Help() string {
+	return `Amazon AWS:
+
+    provider:          "aws"
+    tag_key:           The key of the tag of the instances
+    tag_value:         The value of the tag of the instances
+    region:            The AWS region. Defaults to the one of the instance
+    addr_type:         "private_v4" (default) or "public_v4"
+    access_key_id:     The access key. Defaults to the environment, the shared
+                       credentials file, or the IAM role of the instance
+    secret_access_key: The secret key of access_key_id
+    endpoint:          The EC2 endpoint. Defaults to the one of the region
+
+    The credentials need the permission ec2:DescribeInstances.`
+}
+
+// describeInstancesResponse is the part of the DescribeInstances response used.
+type describeInstancesResponse struct {
+	Reservations []struct {
+		Instances []struct {
+			PrivateIPAddress string `xml:"privateIpAddress"`
+			PublicIPAddress  string `xml:"ipAddress"`
+		} `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+func (p *awsProvider) Addrs(args map[string]string, logger *ulog.Logger) ([]string, error) {
+	if err := requireArgs(args, "tag_key", "tag_value"); err != nil {
+		return nil, err
+	}
+	addrType := args["addr_type"]
+	switch addrType {
+	case "":
+		addrType = "private_v4"
+	case "private_v4", "public_v4":
+	default:
+		return nil, fmt.Errorf("bad addr_type %q, should be private_v4 or public_v4", addrType)
+	}
+
+	cfg := aws.NewConfig().WithHTTPClient(httpClient)
+	if args["access_key_id"] != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(args["access_key_id"], args["secret_access_key"], ""))
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	region := args["region"]
+	if region == "" {
+		if region, err = ec2metadata.New(sess).Region(); err != nil {
+			return nil, fmt.Errorf("region is not set and could not be found from the instance metadata: %v", err)
+		}
+	}
+	endpoint := args["endpoint"]
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://ec2.%s.amazonaws.com/", region)
+	}
+	signer := v4.NewSigner(sess.Config.Credentials)
+
+	var addrs []string
+	nextToken := ""
+	for {
+		query := url.Values{
+			"Action":           {"DescribeInstances"},
+			"Version":          {"2016-11-15"},
+			"Filter.1.Name":    {"tag:" + args["tag_key"]},
+			"Filter.1.Value.1": {args["tag_value"]},
+			"Filter.2.Name":    {"instance-state-name"},
+			"Filter.2.Value.1": {"running"},
+		}
+		if nextToken != "" {
+			query.Set("NextToken", nextToken)
+		}
+		body := strings.NewReader(query.Encode())
+		req, err := http.NewRequest("POST", endpoint, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		if _, err := signer.Sign(req, body, "ec2", region, time.Now()); err != nil {
+			return nil, err
+		}
+
+		var out describeInstancesResponse
+		if err := doXML(req, &out); err != nil {
+			return nil, fmt.Errorf("DescribeInstances: %v", err)
+		}
+		for _, r := range out.Reservations {
+			for _, i := range r.Instances {
+				addr := i.PrivateIPAddress
+				if addrType == "public_v4" {
+					addr = i.PublicIPAddress
+				}
+				if addr != "" {
+					addrs = append(addrs, addr)
+				}
+			}
+		}
+		if out.NextToken == "" {
+			return addrs, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+// doXML sends the request and decodes the XML response.
+func doXML(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, body)
+	}
+	return xml.Unmarshal(body, out)
+}
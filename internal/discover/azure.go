@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package discover
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// The endpoints of Azure, changed by tests.
+var (
+	azureLoginURL      = "https://login.microsoftonline.com"
+	azureManagementURL = "https://management.azure.com"
+	azureMetadataURL   = "http://169.254.169.254/metadata"
+)
+
+// azureProvider finds the network interfaces with a tag, by the Azure Resource
+// Manager API.
+type azureProvider struct{}
+
+func (p *azureProvider) Help() string {
+	return `Microsoft Azure:
+
+    provider:          "azure"
+    tag_name:          The name of the tag of the network interfaces
+    tag_value:         The value of the tag of the network interfaces
+    subscription_id:   The subscription
+    tenant_id:         The tenant of the service principal
+    client_id:         The client of the service principal
+    secret_access_key: The secret of the service principal
+
+    Without client_id, the managed identity of the instance is used. The
+    service principal or the identity needs the role Reader.`
+}
+
+// azureInterfaces is the part of the network interfaces list response used.
+type azureInterfaces struct {
+	Value []struct {
+		Tags       map[string]string `json:"tags"`
+		Properties struct {
+			IPConfigurations []struct {
+				Properties struct {
+					PrivateIPAddress string `json:"privateIPAddress"`
+				} `json:"properties"`
+			} `json:"ipConfigurations"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+func (p *azureProvider) Addrs(args map[string]string, logger *ulog.Logger) ([]string, error) {
+	if err := requireArgs(args, "tag_name", "tag_value", "subscription_id"); err != nil {
+		return nil, err
+	}
+	token, err := azureToken(args)
+	if err != nil {
+		return nil, fmt.Errorf("getting the token: %v", err)
+	}
+
+	var addrs []string
+	u := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.Network/networkInterfaces?api-version=2018-10-01",
+		azureManagementURL, url.PathEscape(args["subscription_id"]))
+	for u != "" {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		var out azureInterfaces
+		if err := doJSON(req, &out); err != nil {
+			return nil, fmt.Errorf("listing network interfaces: %v", err)
+		}
+		for _, nic := range out.Value {
+			if v, ok := nic.Tags[args["tag_name"]]; !ok || v != args["tag_value"] {
+				continue
+			}
+			for _, c := range nic.Properties.IPConfigurations {
+				if c.Properties.PrivateIPAddress != "" {
+					addrs = append(addrs, c.Properties.PrivateIPAddress)
+				}
+			}
+		}
+		u = out.NextLink
+	}
+	return addrs, nil
+}
+
+// azureToken returns an access token of the management API, of the service
+// principal of the args or the managed identity of the instance.
+func azureToken(args map[string]string) (string, error) {
+	const resource = "https://management.azure.com/"
+	var req *http.Request
+	var err error
+	if args["client_id"] != "" {
+		if err := requireArgs(args, "tenant_id", "secret_access_key"); err != nil {
+			return "", err
+		}
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {args["client_id"]},
+			"client_secret": {args["secret_access_key"]},
+			"resource":      {resource},
+		}
+		req, err = http.NewRequest("POST", fmt.Sprintf("%s/%s/oauth2/token", azureLoginURL, url.PathEscape(args["tenant_id"])),
+			strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequest("GET", azureMetadataURL+"/identity/oauth2/token?api-version=2018-02-01&resource="+
+			url.QueryEscape(resource), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata", "true")
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package discover finds the addresses of the managers by the instances of a
+// cloud, for the cloud auto-join strings like
+// "provider=aws tag_key=dtle tag_value=manager" of the go-discover format.
+package discover
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+const httpTimeout = 30 * time.Second
+
+// Provider finds the addresses of the instances of a cloud.
+type Provider interface {
+	// Addrs returns the addresses of the instances matching the args of the
+	// auto-join string, "provider" excluded.
+	Addrs(args map[string]string, logger *ulog.Logger) ([]string, error)
+
+	// Help describes the args of the provider.
+	Help() string
+}
+
+// Providers are the providers by name, the value of "provider".
+var Providers = map[string]Provider{
+	"aws":   &awsProvider{},
+	"azure": &azureProvider{},
+	"gce":   &gceProvider{},
+}
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// IsAutoJoin returns whether the address is a cloud auto-join string.
+func IsAutoJoin(addr string) bool {
+	return strings.Contains(addr, "provider=")
+}
+
+// Parse parses a cloud auto-join string of space separated key=value pairs. A
+// value with spaces is quoted by double quotes.
+func Parse(s string) (map[string]string, error) {
+	args := make(map[string]string)
+	lastKey := "the start"
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		i := strings.Index(s, "=")
+		if i <= 0 || strings.ContainsAny(s[:i], " \t") {
+			// The rest of the string is not quoted, as it may hold a secret.
+			return nil, fmt.Errorf("bad auto-join string: expected key=value after %v", lastKey)
+		}
+		key := s[:i]
+		s = s[i+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			j := strings.Index(s[1:], `"`)
+			if j < 0 {
+				return nil, fmt.Errorf("bad auto-join string: unterminated quote of %v", key)
+			}
+			value, s = s[1:j+1], s[j+2:]
+		} else if j := strings.IndexAny(s, " \t"); j >= 0 {
+			value, s = s[:j], s[j:]
+		} else {
+			value, s = s, ""
+		}
+		if _, ok := args[key]; ok {
+			return nil, fmt.Errorf("bad auto-join string: duplicate key %v", key)
+		}
+		args[key] = value
+		lastKey = key
+	}
+	return args, nil
+}
+
+// Redact returns the cloud auto-join string with the values of the keys of
+// credentials replaced, to be logged. The other addresses are returned as is.
+func Redact(s string) string {
+	if !IsAutoJoin(s) {
+		return s
+	}
+	args, err := Parse(s)
+	if err != nil {
+		return "<bad auto-join string>"
+	}
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		if key != "provider" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	pairs := []string{"provider=" + args["provider"]}
+	for _, key := range keys {
+		value := args[key]
+		if isSecretArg(key) {
+			value = "<redacted>"
+		} else if strings.ContainsAny(value, " \t") {
+			value = `"` + value + `"`
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// isSecretArg returns whether the arg of an auto-join string is a credential,
+// e.g. secret_access_key.
+func isSecretArg(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "secret") || strings.Contains(key, "password") ||
+		strings.Contains(key, "token") || key == "access_key_id"
+}
+
+// Addrs returns the addresses of the instances of a cloud auto-join string.
+func Addrs(s string, logger *ulog.Logger) ([]string, error) {
+	args, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	name := args["provider"]
+	p, ok := Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown auto-join provider %q, should be one of %v", name, providerNames())
+	}
+	delete(args, "provider")
+	addrs, err := p.Addrs(args, logger)
+	if err != nil {
+		return nil, fmt.Errorf("discovering by provider %v: %v", name, err)
+	}
+	return addrs, nil
+}
+
+// Resolve replaces the cloud auto-join strings of addrs by the addresses they
+// find. The other addresses are kept. It fails only if no address is left.
+func Resolve(addrs []string, logger *ulog.Logger) ([]string, error) {
+	var resolved []string
+	var lastErr error
+	for _, addr := range addrs {
+		if !IsAutoJoin(addr) {
+			resolved = append(resolved, addr)
+			continue
+		}
+		found, err := Addrs(addr, logger)
+		if err != nil {
+			logger.Warnf("discover: %v", err)
+			lastErr = err
+			continue
+		}
+		logger.Printf("discover: found %v by %v", found, Redact(addr))
+		resolved = append(resolved, found...)
+	}
+	if len(resolved) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return resolved, nil
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requireArgs returns an error unless each of the keys has a value in args.
+func requireArgs(args map[string]string, keys ...string) error {
+	for _, k := range keys {
+		if args[k] == "" {
+			return fmt.Errorf("%v is required", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package discover
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+func testLogger() *ulog.Logger {
+	return ulog.New(os.Stderr, ulog.InfoLevel)
+}
+
+func TestParse(t *testing.T) {
+	args, err := Parse(`provider=aws  tag_key=dtle tag_value="manager a" region=us-east-1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"provider": "aws", "tag_key": "dtle", "tag_value": "manager a", "region": "us-east-1"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got %v", args)
+	}
+	for _, s := range []string{`provider`, `provider=aws tag_key`, `provider=aws provider=gce`, `tag_value="a`} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%v: expected an error", s)
+		}
+	}
+}
+
+type fakeProvider struct {
+	addrs []string
+	args  map[string]string
+}
+
+func (p *fakeProvider) Addrs(args map[string]string, logger *ulog.Logger) ([]string, error) {
+	p.args = args
+	if p.addrs == nil {
+		return nil, fmt.Errorf("no instances")
+	}
+	return p.addrs, nil
+}
+
+func (p *fakeProvider) Help() string { return "" }
+
+func TestRedact(t *testing.T) {
+	s := `provider=aws tag_key=dtle tag_value="manager a" access_key_id=AKID secret_access_key=s3cr3t`
+	got := Redact(s)
+	want := `provider=aws access_key_id=<redacted> secret_access_key=<redacted> tag_key=dtle tag_value="manager a"`
+	if got != want {
+		t.Errorf("Redact() = %v", got)
+	}
+	if got := Redact("10.0.0.1:8191"); got != "10.0.0.1:8191" {
+		t.Errorf("Redact() of an address = %v", got)
+	}
+	if _, err := Parse(`provider=aws secret_access_key=s3cr3t oops`); err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("Parse() error = %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := &fakeProvider{addrs: []string{"10.0.0.2", "10.0.0.3"}}
+	Providers["fake"] = p
+	defer delete(Providers, "fake")
+
+	got, err := Resolve([]string{"10.0.0.1:8191", "provider=fake tag=x"}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"10.0.0.1:8191", "10.0.0.2", "10.0.0.3"}) {
+		t.Errorf("got %v", got)
+	}
+	if !reflect.DeepEqual(p.args, map[string]string{"tag": "x"}) {
+		t.Errorf("args %v", p.args)
+	}
+
+	p.addrs = nil
+	if _, err := Resolve([]string{"provider=fake"}, testLogger()); err == nil {
+		t.Errorf("expected an error without any address")
+	}
+	if _, err := Resolve([]string{"provider=none"}, testLogger()); err == nil {
+		t.Errorf("expected an error of an unknown provider")
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "DescribeInstances" || r.Form.Get("Filter.1.Name") != "tag:dtle" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("NextToken") == "" {
+			fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>
+<item><privateIpAddress>10.0.0.1</privateIpAddress><ipAddress>1.1.1.1</ipAddress></item>
+<item><privateIpAddress>10.0.0.2</privateIpAddress></item>
+</instancesSet></item></reservationSet><nextToken>t2</nextToken></DescribeInstancesResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>
+<item><privateIpAddress>10.0.0.3</privateIpAddress></item>
+</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+	}))
+	defer ts.Close()
+
+	args := map[string]string{"tag_key": "dtle", "tag_value": "manager", "region": "us-east-1",
+		"access_key_id": "AKID", "secret_access_key": "secret", "endpoint": ts.URL}
+	addrs, err := (&awsProvider{}).Addrs(args, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}) {
+		t.Errorf("got %v", addrs)
+	}
+	args["addr_type"] = "public_v4"
+	if addrs, err := (&awsProvider{}).Addrs(args, testLogger()); err != nil || !reflect.DeepEqual(addrs, []string{"1.1.1.1"}) {
+		t.Errorf("got %v, %v", addrs, err)
+	}
+}
+
+func TestGCEProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata/project/project-id":
+			fmt.Fprint(w, "p1")
+		case "/metadata/instance/service-accounts/default/token":
+			fmt.Fprint(w, `{"access_token": "token"}`)
+		case "/compute/projects/p1/aggregated/instances":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"items": {
+"zones/us-west1-a": {"instances": [
+  {"status": "RUNNING", "tags": {"items": ["dtle"]}, "networkInterfaces": [{"networkIP": "10.0.0.1"}]},
+  {"status": "TERMINATED", "tags": {"items": ["dtle"]}, "networkInterfaces": [{"networkIP": "10.0.0.2"}]},
+  {"status": "RUNNING", "tags": {"items": ["web"]}, "networkInterfaces": [{"networkIP": "10.0.0.3"}]}]},
+"zones/us-east1-b": {"instances": [
+  {"status": "RUNNING", "tags": {"items": ["dtle"]}, "networkInterfaces": [{"networkIP": "10.0.0.4"}]}]}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(m, c string) { gceMetadataURL, gceComputeURL = m, c }(gceMetadataURL, gceComputeURL)
+	gceMetadataURL, gceComputeURL = ts.URL+"/metadata", ts.URL+"/compute"
+
+	addrs, err := (&gceProvider{}).Addrs(map[string]string{"tag_value": "dtle", "zone_pattern": "us-west1-.*"}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) {
+		t.Errorf("got %v", addrs)
+	}
+}
+
+func TestAzureProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/login/t1/oauth2/token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "token"}`)
+		case r.URL.Path == "/management/subscriptions/s1/providers/Microsoft.Network/networkInterfaces":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"value": [
+{"tags": {"role": "dtle"}, "properties": {"ipConfigurations": [{"properties": {"privateIPAddress": "10.0.0.1"}}]}},
+{"tags": {"role": "web"}, "properties": {"ipConfigurations": [{"properties": {"privateIPAddress": "10.0.0.2"}}]}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(l, m string) { azureLoginURL, azureManagementURL = l, m }(azureLoginURL, azureManagementURL)
+	azureLoginURL, azureManagementURL = ts.URL+"/login", ts.URL+"/management"
+
+	addrs, err := (&azureProvider{}).Addrs(map[string]string{"tag_name": "role", "tag_value": "dtle",
+		"subscription_id": "s1", "tenant_id": "t1", "client_id": "c1", "secret_access_key": "secret"}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) {
+		t.Errorf("got %v", addrs)
+	}
+}
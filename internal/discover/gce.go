@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package discover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// The endpoints of the GCE metadata server and the compute API, changed by
+// tests.
+var (
+	gceMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+	gceComputeURL  = "https://www.googleapis.com/compute/v1"
+)
+
+// gceProvider finds the running GCE instances with a network tag, by the
+// compute API and the service account of the instance.
+type gceProvider struct{}
+
+func (p *gceProvider) Help() string {
+	return `Google Cloud:
+
+    provider:     "gce"
+    tag_value:    The network tag of the instances
+    project_name: The project. Defaults to the one of the instance
+    zone_pattern: A regular expression of the zones, e.g. "us-west1-.*".
+                  Defaults to all the zones
+
+    The service account of the instance needs the scope compute.readonly.`
+}
+
+// gceInstances is the part of the aggregated instances list response used.
+type gceInstances struct {
+	Items map[string]struct {
+		Instances []struct {
+			Status string `json:"status"`
+			Tags   struct {
+				Items []string `json:"items"`
+			} `json:"tags"`
+			NetworkInterfaces []struct {
+				NetworkIP string `json:"networkIP"`
+			} `json:"networkInterfaces"`
+		} `json:"instances"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (p *gceProvider) Addrs(args map[string]string, logger *ulog.Logger) ([]string, error) {
+	if err := requireArgs(args, "tag_value"); err != nil {
+		return nil, err
+	}
+	var zonePattern *regexp.Regexp
+	if args["zone_pattern"] != "" {
+		var err error
+		if zonePattern, err = regexp.Compile("^(" + args["zone_pattern"] + ")$"); err != nil {
+			return nil, fmt.Errorf("bad zone_pattern: %v", err)
+		}
+	}
+	project := args["project_name"]
+	if project == "" {
+		b, err := gceMetadata("/project/project-id")
+		if err != nil {
+			return nil, fmt.Errorf("project_name is not set and could not be found from the instance metadata: %v", err)
+		}
+		project = string(b)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	b, err := gceMetadata("/instance/service-accounts/default/token")
+	if err == nil {
+		err = json.Unmarshal(b, &token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting the token of the service account: %v", err)
+	}
+
+	var addrs []string
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%s/projects/%s/aggregated/instances", gceComputeURL, url.PathEscape(project))
+		if pageToken != "" {
+			u += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		var out gceInstances
+		if err := doJSON(req, &out); err != nil {
+			return nil, fmt.Errorf("listing instances: %v", err)
+		}
+		for zone, list := range out.Items {
+			if zonePattern != nil && !zonePattern.MatchString(strings.TrimPrefix(zone, "zones/")) {
+				continue
+			}
+			for _, i := range list.Instances {
+				if i.Status != "RUNNING" || !stringIn(args["tag_value"], i.Tags.Items) {
+					continue
+				}
+				if len(i.NetworkInterfaces) > 0 && i.NetworkInterfaces[0].NetworkIP != "" {
+					addrs = append(addrs, i.NetworkInterfaces[0].NetworkIP)
+				}
+			}
+		}
+		if out.NextPageToken == "" {
+			return addrs, nil
+		}
+		pageToken = out.NextPageToken
+	}
+}
+
+// gceMetadata returns a value of the metadata server.
+func gceMetadata(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", gceMetadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, b)
+	}
+	return b, nil
+}
+
+// doJSON sends the request and decodes the JSON response.
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, b)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func stringIn(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
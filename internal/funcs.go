@@ -53,6 +53,11 @@ func Uint64ToPtr(u uint64) *uint64 {
 	return &u
 }
 
+// Int64ToPtr returns the pointer to an int64
+func Int64ToPtr(i int64) *int64 {
+	return &i
+}
+
 // StringToPtr returns the pointer to a string
 func StringToPtr(str string) *string {
 	return &str
@@ -115,3 +120,45 @@ func CopySliceString(s []string) []string {
 	}
 	return c
 }
+
+// ClosestString returns the candidate most similar to s, for "did you mean"
+// hints. It returns "" if none is close enough.
+func ClosestString(s string, candidates []string) string {
+	closest, min := "", len(s)/2+1
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < min {
+			closest, min = c, d
+		}
+	}
+	return closest
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(n int, ns ...int) int {
+	for _, m := range ns {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
@@ -11,6 +11,13 @@ const (
 	GtidExecutedTablePrefix     string = "gtid_executed_"
 	GtidExecutedTableV2         string = "gtid_executed_v2"
 	GtidExecutedTableV3         string = "gtid_executed_v3"
+	// The progress of the transactions split on the target, see txn_progress.go.
+	TxnProgressTable string = "txn_progress"
+	// The tables copied of the jobs, see copied_tables.go.
+	CopiedTablesTable string = "copied_tables"
+	// The alters of the online schema changes of the jobs, see
+	// online_schema_change.go.
+	OnlineSchemaChangesTable string = "online_schema_changes"
 
 	ENV_PRINT_TPS         = "UDUP_PRINT_TPS"
 	ENV_DUMP_CHECKSUM     = "DTLE_DUMP_CHECKSUM"
@@ -18,4 +25,6 @@ const (
 	ENV_TESTSTUB1_DELAY   = "UDUP_TESTSTUB1_DELAY"
 	ENV_FULL_APPLY_DELAY  = "DTLE_FULL_APPLY_DELAY"
 	ENV_COUNT_INFO_SCHEMA = "DTLE_COUNT_INFO_SCHEMA"
+	// Faults injected into the Dest tasks for testing, see faultInjector.
+	ENV_FAULTS = "DTLE_FAULTS"
 )
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package i18n
+
+// chineseCatalog translates the messages to Chinese.
+var chineseCatalog = map[string]string{
+	// The errors of the HTTP API.
+	"Invalid method":                                        "不支持的HTTP方法",
+	"ACL token not found":                                   "ACL token不存在",
+	"Permission denied":                                     "权限不足",
+	"Permission denied for namespace %q":                    "无权访问命名空间 %q",
+	"Permission denied for user %v: no group allowed":       "用户 %v 无权访问：所属组均未被授权",
+	"authentication by %v failed":                           "%v 认证失败",
+	"OIDC is not configured":                                "未配置OIDC",
+	"OIDC login failed: %v %v":                              "OIDC登录失败：%v %v",
+	"OIDC login state mismatch, try logging in again":       "OIDC登录状态不匹配，请重新登录",
+	"missing address to join":                               "缺少要加入的地址",
+	"missing node to force leave":                           "缺少要强制移除的节点",
+	"missing server address":                                "缺少manager地址",
+	"resource not found":                                    "资源不存在",
+	"alloc not found":                                       "分配（allocation）不存在",
+	"eval not found":                                        "评估（evaluation）不存在",
+	"job not found":                                         "任务不存在",
+	"node not found":                                        "节点不存在",
+	"order not found":                                       "订单不存在",
+	"bad since: %v":                                         "since 参数错误：%v",
+	"bad until: %v":                                         "until 参数错误：%v",
+	"Debugging is disabled (see enable_debug)":              "调试未开启（见 enable_debug）",
+	"Invalid seconds %q, should be 1 to %v":                 "seconds 参数 %q 错误，应为1至%v",
+	"Crash report %v not found":                             "崩溃报告 %v 不存在",
+	"Streaming is not supported":                            "不支持流式响应",
+	"Invalid topic: %v":                                     "topic 参数错误：%v",
+	"job %v is not a %v job":                                "任务 %v 不是 %v 类型的任务",
+	"Job hasn't been provided":                              "未提供任务",
+	"Job Name hasn't been provided":                         "未提供任务名",
+	"Order hasn't been provided":                            "未提供订单",
+	"Order Id hasn't been provided":                         "未提供订单ID",
+	"Token hasn't been provided":                            "未提供token",
+	"Invalid security token":                                "安全token无效",
+	"SkuId hasn't been provided":                            "未提供SkuId",
+	"no running alloc of task %v":                           "%v 任务没有运行中的分配（allocation）",
+	"simulating a job of a %v Src task is not supported":    "不支持模拟 %v 类型源端任务的任务",
+	"ConnectionConfig of the Src task hasn't been provided": "未提供源端任务的 ConnectionConfig",
+	"Src task hasn't been provided":                         "未提供源端任务",
+	"Failed to read snapshot: %v":                           "读取快照失败：%v",
+	"Snapshot hasn't been provided":                         "未提供快照",
+	"request body larger than %v bytes (see http_max_request_body_size)": "请求体超过 %v 字节（见 http_max_request_body_size）",
+	"unsupported locale %q, should be %v or %v":                          "不支持的语言 %q，应为 %v 或 %v",
+
+	// The errors of the CLI.
+	"Error collecting the crash reports of node %s (%s): %s": "收集节点 %s（%s）的崩溃报告失败：%s",
+	"Error connecting to the MySQL server: %s":               "连接MySQL失败：%s",
+	"Error converting job: %s":                               "转换任务失败：%s",
+	"Error creating file: %s":                                "创建文件失败：%s",
+	"Error cloning job: %s":                                  "复制任务失败：%s",
+	"Error deregistering job: %s":                            "注销任务失败：%s",
+	"Error determining leaders: %s":                          "确定leader失败：%s",
+	"Error force-leaving server %s: %s":                      "强制移除manager %s 失败：%s",
+	"Error exporting job: %s":                                "导出任务失败：%s",
+	"Error formatting job: %s":                               "格式化任务失败：%s",
+	"Error formatting output: %s":                            "格式化输出失败：%s",
+	"Error getting job struct: %s":                           "读取任务失败：%s",
+	"Error initializing client: %s":                          "初始化客户端失败：%s",
+	"Error initializing consul client: %s":                   "初始化consul客户端失败：%s",
+	"Error inspecting job: %s":                               "查看任务配置失败：%s",
+	"Error opening snapshot file: %s":                        "打开快照文件失败：%s",
+	"Error parsing check-index value %q: %v":                 "解析 check-index 值 %q 失败：%v",
+	"Error profiling the agent: %s":                          "剖析agent失败：%s",
+	"Error querying CA roots: %s":                            "查询CA根证书失败：%s",
+	"Error querying audit log: %s":                           "查询审计日志失败：%s",
+	"Error querying job allocations: %s":                     "查询任务的分配（allocation）失败：%s",
+	"Error querying job evaluations: %s":                     "查询任务的评估（evaluation）失败：%s",
+	"Error querying job: %s":                                 "查询任务失败：%s",
+	"Error querying jobs: %s":                                "查询任务列表失败：%s",
+	"Error querying node allocations: %s":                    "查询节点的分配（allocation）失败：%s",
+	"Error querying node info: %s":                           "查询节点信息失败：%s",
+	"Error querying node status: %s":                         "查询节点状态失败：%s",
+	"Error querying nodes: %s":                               "查询节点列表失败：%s",
+	"Error querying server list: %s":                         "查询manager列表失败：%s",
+	"Error querying servers: %s":                             "查询manager失败：%s",
+	"Error querying topology: %s":                            "查询复制拓扑失败：%s",
+	"Error reading sql audit file: %s":                       "读取sql审计文件失败：%s",
+	"Error removing peer: %v":                                "移除节点失败：%v",
+	"Error replaying sql audit file: %s":                     "回放sql审计文件失败：%s",
+	"Error restoring snapshot: %s":                           "恢复快照失败：%s",
+	"Error rotating the CA root: %s":                         "轮换CA根证书失败：%s",
+	"Error saving snapshot: %s":                              "保存快照失败：%s",
+	"Error simulating job: %s":                               "模拟任务失败：%s",
+	"Error submitting job: %s":                               "提交任务失败：%s",
+	"Error updating server list: %s":                         "更新manager列表失败：%s",
+	"Error validating job: %s":                               "校验任务失败：%s",
+	"Error verifying dump: %s":                               "校验全量导出失败：%s",
+	"Error writing debug bundle: %s":                         "写入调试包失败：%s",
+	"Error writing snapshot: %s":                             "写入快照失败：%s",
+	"Failed to parse answer: %v":                             "解析回答失败：%v",
+	"Failed to parse args: %v":                               "解析参数失败：%v",
+	"Failed to stat '%s': %v":                                "读取 '%s' 的状态失败：%v",
+	"Failed to write '%s': %v":                               "写入 '%s' 失败：%v",
+	"Invalid -gtid: %s":                                      "-gtid 参数错误：%s",
+	"Invalid -since: %s":                                     "-since 参数错误：%s",
+	"Invalid -until: %s":                                     "-until 参数错误：%s",
+	"Job '%s' already exists":                                "任务 '%s' 已存在",
+	"Identifier must contain at least two characters.":       "标识至少需要两个字符。",
+	"No job(s) with prefix or id %q found":                   "未找到前缀或ID为 %q 的任务",
+	"No node(s) with prefix %q found":                        "未找到前缀为 %q 的节点",
+	"No nodes found":                                         "未找到节点",
+	"Job not updated":                                        "任务未更新",
+	"Agent doesn't know about server members":                "agent不知道manager成员",
+}
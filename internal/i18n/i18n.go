@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package i18n translates the messages shown to the users, the errors of the
+// API and the CLI, to their locale.
+//
+// A message is identified by its English format, as the code has it, e.g.
+// "job not found" or "Error querying job: %s". The catalog of a locale maps
+// the English formats to its own, with the same verbs in the same order. A
+// message not in the catalog is shown in English.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The locales supported.
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// catalogs are the translations of the English formats of each locale but
+// English.
+var catalogs = map[string]map[string]string{
+	Chinese: chineseCatalog,
+}
+
+// ParseLocale returns the locale supported of a language tag, e.g. "zh-CN", or
+// of a POSIX locale, e.g. "zh_CN.UTF-8", and false if it is not supported.
+func ParseLocale(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(s, "-_.@"); i >= 0 {
+		s = s[:i]
+	}
+	switch s {
+	case English, "c", "posix":
+		return English, true
+	case Chinese:
+		return Chinese, true
+	}
+	return "", false
+}
+
+// ValidateLocale returns an error unless the locale is supported.
+func ValidateLocale(locale string) error {
+	if l, ok := ParseLocale(locale); !ok || l != locale {
+		return fmt.Errorf("unsupported locale %q, should be %v or %v", locale, English, Chinese)
+	}
+	return nil
+}
+
+// AcceptLanguage returns the locale supported preferred by an Accept-Language
+// header, e.g. "zh-CN,zh;q=0.9,en;q=0.8", or the fallback.
+func AcceptLanguage(header, fallback string) string {
+	type language struct {
+		locale string
+		q      float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		locale, ok := ParseLocale(fields[0])
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			languages = append(languages, language{locale: locale, q: q})
+		}
+	}
+	if len(languages) == 0 {
+		return fallback
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+	return languages[0].locale
+}
+
+// EnvLocale returns the locale of the environment: UDUP_LANG, or else the
+// POSIX LC_ALL, LC_MESSAGES and LANG. It is English if none is supported.
+func EnvLocale() string {
+	for _, name := range []string{"UDUP_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			if locale, ok := ParseLocale(v); ok {
+				return locale
+			}
+		}
+	}
+	return English
+}
+
+// Translate returns the format translated to the locale, or the format if it
+// is not in the catalog of the locale.
+func Translate(locale, format string) string {
+	if translated, ok := catalogs[locale][format]; ok {
+		return translated
+	}
+	return format
+}
+
+// Sprintf formats the message translated to the locale.
+func Sprintf(locale, format string, args ...interface{}) string {
+	if len(args) == 0 {
+		return Translate(locale, format)
+	}
+	return fmt.Sprintf(Translate(locale, format), args...)
+}
+
+// Localizer is an error which could be shown in a locale.
+type Localizer interface {
+	error
+	Localize(locale string) string
+}
+
+// Message is an error of a message, whose Error is in English.
+type Message struct {
+	Format string
+	Args   []interface{}
+}
+
+// Errorf returns the error of the message formatted.
+func Errorf(format string, args ...interface{}) error {
+	return &Message{Format: format, Args: args}
+}
+
+func (m *Message) Error() string {
+	return m.Localize(English)
+}
+
+func (m *Message) Localize(locale string) string {
+	return Sprintf(locale, m.Format, m.Args...)
+}
+
+// Localize returns the error shown in the locale: the message of a Localizer
+// translated, or the error translated if it is a message of the catalog, e.g.
+// the error of an RPC, which lost its arguments. Otherwise, it is in English.
+func Localize(locale string, err error) string {
+	if l, ok := err.(Localizer); ok {
+		return l.Localize(locale)
+	}
+	return Translate(locale, err.Error())
+}
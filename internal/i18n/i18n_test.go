@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package i18n
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseLocale(t *testing.T) {
+	for s, want := range map[string]string{
+		"en":          English,
+		"en-US":       English,
+		"C":           English,
+		"POSIX":       English,
+		"zh":          Chinese,
+		"zh-CN":       Chinese,
+		"zh_CN.UTF-8": Chinese,
+		" zh-Hans ":   Chinese,
+		"fr":          "",
+		"":            "",
+	} {
+		got, ok := ParseLocale(s)
+		if got != want || ok != (want != "") {
+			t.Errorf("%q: got %q, %v, want %q", s, got, ok, want)
+		}
+	}
+
+	if err := ValidateLocale("zh"); err != nil {
+		t.Errorf("zh: %v", err)
+	}
+	for _, locale := range []string{"zh-CN", "fr", ""} {
+		if err := ValidateLocale(locale); err == nil {
+			t.Errorf("%q: expected an error", locale)
+		}
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                           "en",
+		"zh-CN,zh;q=0.9,en;q=0.8":    Chinese,
+		"en-US,en;q=0.9,zh-CN;q=0.8": English,
+		"fr, zh;q=0.5":               Chinese,
+		"zh;q=0.5, en;q=0.7":         English,
+		"zh;q=0, fr":                 "en",
+		"*":                          "en",
+	} {
+		if got := AcceptLanguage(header, "en"); got != want {
+			t.Errorf("%q: got %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestEnvLocale(t *testing.T) {
+	names := []string{"UDUP_LANG", "LC_ALL", "LC_MESSAGES", "LANG"}
+	saved := map[string]string{}
+	for _, name := range names {
+		saved[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+	defer func() {
+		for name, v := range saved {
+			os.Setenv(name, v)
+		}
+	}()
+
+	if got := EnvLocale(); got != English {
+		t.Errorf("no env: got %q", got)
+	}
+	os.Setenv("LANG", "zh_CN.UTF-8")
+	if got := EnvLocale(); got != Chinese {
+		t.Errorf("LANG: got %q", got)
+	}
+	os.Setenv("LC_ALL", "C")
+	if got := EnvLocale(); got != English {
+		t.Errorf("LC_ALL: got %q", got)
+	}
+	os.Setenv("UDUP_LANG", "zh")
+	if got := EnvLocale(); got != Chinese {
+		t.Errorf("UDUP_LANG: got %q", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := Errorf("Error querying job: %s", "timeout")
+	if got := err.Error(); got != "Error querying job: timeout" {
+		t.Errorf("Error: got %q", got)
+	}
+	if got := Localize(Chinese, err); got != "查询任务失败：timeout" {
+		t.Errorf("zh: got %q", got)
+	}
+	if got := Localize(Chinese, errors.New("job not found")); got != "任务不存在" {
+		t.Errorf("zh of a message: got %q", got)
+	}
+	if got := Localize(Chinese, errors.New("an unknown error")); got != "an unknown error" {
+		t.Errorf("zh of an unknown error: got %q", got)
+	}
+}
+
+// verbRegexp matches the verbs of a format.
+var verbRegexp = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for format, translated := range catalog {
+			want := verbRegexp.FindAllString(format, -1)
+			got := verbRegexp.FindAllString(translated, -1)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: %q has the verbs %v, want %v", locale, translated, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+// AuditEvent records a change requested through the HTTP API.
+type AuditEvent struct {
+	ID string
+	// Time is when the request was received, in UnixNano.
+	Time int64
+	// Identity is who made the request: the kind of its ACL token, e.g.
+	// "management" or "namespace:team-a", the user logged in by LDAP or OIDC,
+	// e.g. "ldap:alice", "unknown" for a token or a user denied, or "anonymous"
+	// without credentials.
+	Identity string
+	// Accessor tells the tokens apart without storing them: the first 8 hex
+	// digits of the SHA-256 of the secret.
+	Accessor string
+	// RemoteAddr is the address of the client, or of the last proxy.
+	RemoteAddr string
+	// Agent is the name of the agent which served the request.
+	Agent string
+
+	Method string
+	Path   string
+	// Targets are the IDs of the jobs, orders or nodes changed.
+	Targets   []string
+	Namespace string
+
+	// Code is the HTTP status of the response, Error the error returned.
+	Code  int
+	Error string
+
+	CreateIndex uint64
+}
+
+// AuditEventUpsertRequest is used to record audit events.
+type AuditEventUpsertRequest struct {
+	Events []*AuditEvent
+	WriteRequest
+}
+
+// AuditEventPruneRequest is used to delete the audit events older than Before,
+// in UnixNano.
+type AuditEventPruneRequest struct {
+	Before int64
+	WriteRequest
+}
+
+// AuditEventListRequest is used to query the audit events. The events of any
+// target, namespace and time are returned if the filters are empty.
+type AuditEventListRequest struct {
+	Target    string
+	Namespace string
+	// Since and Until are in UnixNano.
+	Since int64
+	Until int64
+	QueryOptions
+}
+
+// AuditEventListResponse is used for an audit list request.
+type AuditEventListResponse struct {
+	Events []*AuditEvent
+	QueryMeta
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+// CARoot is a root certificate of the built-in CA, which issues the
+// certificates of the mutual TLS of the RPC.
+type CARoot struct {
+	ID string
+	// Certificate is in PEM. SealedKey is its private key, in PEM, sealed with
+	// the tls ca_key of the servers, for the key not to be in clear in the
+	// Raft log and snapshots. It is never returned by the API.
+	Certificate string
+	SealedKey   []byte
+	// Active is whether the root signs the new certificates. The other roots
+	// are still trusted until the certificates they signed expire.
+	Active bool
+	// CreateTime and RotateTime, when the root was replaced by a new one, are in
+	// UnixNano.
+	CreateTime int64
+	RotateTime int64
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// CARootsSetRequest is used to replace the roots of the CA.
+type CARootsSetRequest struct {
+	Roots []*CARoot
+	WriteRequest
+}
+
+// CARootsRequest is used to query the roots of the CA.
+type CARootsRequest struct {
+	QueryOptions
+}
+
+// CARootsResponse is used for a roots request. The private keys are removed.
+type CARootsResponse struct {
+	Roots []*CARoot
+	QueryMeta
+}
+
+// CASignRequest is used by an agent to get the certificate of its key signed by
+// the CA. BootstrapToken must be the token shared by the agents.
+type CASignRequest struct {
+	// CSR is the certificate request, in PEM.
+	CSR            string
+	BootstrapToken string
+	QueryOptions
+}
+
+// CASignResponse is the certificate signed, and the roots to trust, in PEM.
+type CASignResponse struct {
+	Certificate string
+	Roots       []string
+	QueryMeta
+}
+
+// CARotateRequest is used to replace the active root of the CA.
+type CARotateRequest struct {
+	WriteRequest
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// connectionProfileNameRe is what the names of the connection profiles are made
+// of, to be used in the paths of the API.
+var connectionProfileNameRe = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ConnectionProfile is a named connection to a MySQL server, which the MySQL
+// tasks reference by name with ConnectionProfile instead of a ConnectionConfig.
+// It is resolved each time a task starts, so changing the profile changes the
+// connection of the tasks when they restart.
+type ConnectionProfile struct {
+	Name string
+	// Namespace is the namespace of the jobs allowed to use the profile,
+	// DefaultNamespace if empty.
+	Namespace string
+	Host      string
+	Port      int
+	User      string
+	// Password is never returned by the HTTP API.
+	Password string
+	// PasswordVaultPath, exclusive with Password, is the path in Vault of the
+	// secret whose field "password" is the password, read by the agents
+	// running the tasks with their vault config.
+	PasswordVaultPath string
+	Charset           string
+
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// Validate returns an error if the profile is not usable.
+func (p *ConnectionProfile) Validate() error {
+	if p == nil {
+		return fmt.Errorf("missing connection profile")
+	}
+	if !connectionProfileNameRe.MatchString(p.Name) {
+		return fmt.Errorf("bad connection profile name %q: only letters, digits, '_', '.' and '-' are allowed", p.Name)
+	}
+	if p.Namespace != "" && !ValidNamespace(p.Namespace) {
+		return fmt.Errorf("connection profile %v: invalid namespace %q", p.Name, p.Namespace)
+	}
+	if p.Host == "" {
+		return fmt.Errorf("connection profile %v: missing Host", p.Name)
+	}
+	if p.Port <= 0 || p.Port > 65535 {
+		return fmt.Errorf("connection profile %v: bad Port %d", p.Name, p.Port)
+	}
+	if p.User == "" {
+		return fmt.Errorf("connection profile %v: missing User", p.Name)
+	}
+	if p.Password != "" && p.PasswordVaultPath != "" {
+		return fmt.Errorf("connection profile %v: Password and PasswordVaultPath are exclusive", p.Name)
+	}
+	return nil
+}
+
+// CheckNamespace returns an error if the jobs of the namespace are not allowed
+// to use the profile.
+func (p *ConnectionProfile) CheckNamespace(namespace string) error {
+	profileNamespace := p.Namespace
+	if profileNamespace == "" {
+		profileNamespace = DefaultNamespace
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if profileNamespace != namespace {
+		return fmt.Errorf("connection profile %v is of namespace %v, not %v", p.Name, profileNamespace, namespace)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the profile without the password.
+func (p *ConnectionProfile) Redacted() *ConnectionProfile {
+	redacted := *p
+	redacted.Password = ""
+	return &redacted
+}
+
+// ConnectionProfileName returns the name of the connection profile the MySQL
+// task references with ConnectionProfile, empty if none.
+func (t *Task) ConnectionProfileName() string {
+	if t.Driver != TaskDriverMySQL {
+		return ""
+	}
+	name, _ := t.Config["ConnectionProfile"].(string)
+	return name
+}
+
+// ConnectionProfileUpsertRequest is used to create or replace a profile.
+type ConnectionProfileUpsertRequest struct {
+	Profile *ConnectionProfile
+	WriteRequest
+}
+
+// ConnectionProfileDeleteRequest is used to delete a profile.
+type ConnectionProfileDeleteRequest struct {
+	Name string
+	WriteRequest
+}
+
+// ConnectionProfileSpecificRequest is used to get a profile by name.
+type ConnectionProfileSpecificRequest struct {
+	Name string
+	QueryOptions
+}
+
+// ConnectionProfileListRequest is used to list the profiles.
+type ConnectionProfileListRequest struct {
+	QueryOptions
+}
+
+// SingleConnectionProfileResponse is used to return a profile, nil if not
+// found.
+type SingleConnectionProfileResponse struct {
+	Profile *ConnectionProfile
+	QueryMeta
+}
+
+// ConnectionProfileListResponse is used for a list request. The passwords are
+// removed.
+type ConnectionProfileListResponse struct {
+	Profiles []*ConnectionProfile
+	QueryMeta
+}
@@ -28,6 +28,7 @@ const (
 	EvalTriggerScheduled     = "scheduled"
 	EvalTriggerRollingUpdate = "rolling-update"
 	EvalTriggerMaxPlans      = "max-plan-attempts"
+	EvalTriggerPreemption    = "preemption"
 )
 
 // Evaluation is used anytime we need to apply business logic as a result
@@ -40,6 +41,10 @@ type Evaluation struct {
 	// is assigned upon the creation of the evaluation.
 	ID string
 
+	// Priority is used to control scheduling importance and if this job
+	// can preempt other jobs.
+	Priority int
+
 	// Type is used to control which schedulers are available to handle
 	// this evaluation.
 	Type string
@@ -211,6 +216,7 @@ func (e *Evaluation) MakePlan(j *Job) *Plan {
 func (e *Evaluation) NextRollingEval(wait time.Duration) *Evaluation {
 	return &Evaluation{
 		ID:             GenerateUUID(),
+		Priority:       e.Priority,
 		Type:           e.Type,
 		TriggeredBy:    EvalTriggerRollingUpdate,
 		JobID:          e.JobID,
@@ -227,6 +233,7 @@ func (e *Evaluation) NextRollingEval(wait time.Duration) *Evaluation {
 func (e *Evaluation) CreateBlockedEval(classEligibility map[string]bool, escaped bool) *Evaluation {
 	return &Evaluation{
 		ID:                   GenerateUUID(),
+		Priority:             e.Priority,
 		Type:                 e.Type,
 		TriggeredBy:          e.TriggeredBy,
 		JobID:                e.JobID,
@@ -7,9 +7,14 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -18,6 +23,33 @@ import (
 
 const (
 	JobTypeSync = "synchronous"
+	// JobTypeBench generates a workload on the source and measures how fast it
+	// is copied and applied to the target.
+	JobTypeBench = "bench"
+	// JobTypeVerify runs read queries on the source and the target after the
+	// cut-over, and completes once they return the same rows.
+	JobTypeVerify = "verify"
+)
+
+// DefaultNamespace is the namespace of the jobs registered without one.
+const DefaultNamespace = "default"
+
+var namespaceRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidNamespace returns whether the name can be used as a namespace.
+func ValidNamespace(name string) bool {
+	return namespaceRegexp.MatchString(name)
+}
+
+const (
+	// JobDefaultPriority is the default priority if not specified.
+	JobDefaultPriority = 50
+
+	// JobMinPriority is the minimum allowed priority
+	JobMinPriority = 1
+
+	// JobMaxPriority is the maximum allowed priority
+	JobMaxPriority = 100
 )
 
 const (
@@ -57,19 +89,37 @@ type Job struct {
 
 	Failover bool
 
+	// Namespace is the namespace the job belongs to. The job names are unique
+	// per namespace.
+	Namespace string
+
 	// Type is used to control various behaviors about the job. Most jobs
 	// are service jobs, meaning they are expected to be long lived.
 	// Some jobs are batch oriented meaning they run and then terminate.
 	// This can be extended in the future to support custom schedulers.
 	Type string
 
+	// Priority is used to control scheduling importance and if this job
+	// can preempt other jobs when a node is at its allocation limit.
+	Priority int
+
 	// Datacenters contains all the datacenters this job is allowed to span
 	Datacenters []string
 
+	// Labels are arbitrary key/values for grouping jobs, e.g. by tenant.
+	Labels map[string]string
+
+	// DependsOn are the IDs of the jobs whose full copy must complete before
+	// this job is scheduled.
+	DependsOn []string
+
 	// Constraints can be specified at a job level and apply to
 	// all the tasks.
 	Constraints []*Constraint
 
+	// RestartPolicy is how the clients restart the failed tasks of the job.
+	RestartPolicy *RestartPolicy
+
 	// Tasks are the collections of tasks that this job needs
 	// to run. Each task is an atomic unit of scheduling and placement.
 	Tasks []*Task
@@ -80,6 +130,15 @@ type Job struct {
 	// StatusDescription is meant to provide more human useful information
 	StatusDescription string
 
+	// Waiting is set while the job waits for the full copy of the jobs it
+	// depends on, or for the quota of its namespace, to be evaluated.
+	Waiting bool
+
+	// FullCopyComplete is set once the Dest task has reported the full copy
+	// complete, for the jobs depending on this one. It is kept in the job as
+	// the events of the task are a ring of the last ones.
+	FullCopyComplete bool
+
 	EnforceIndex bool
 
 	// Raft Indexes
@@ -91,6 +150,15 @@ type Job struct {
 // Canonicalize is used to canonicalize fields in the Job. This should be called
 // when registering a Job.
 func (j *Job) Canonicalize() {
+	if j.Namespace == "" {
+		j.Namespace = DefaultNamespace
+	}
+	if j.Priority == 0 {
+		j.Priority = JobDefaultPriority
+	}
+	if j.RestartPolicy == nil {
+		j.RestartPolicy = DefaultRestartPolicy()
+	}
 	for _, t := range j.Tasks {
 		t.Canonicalize(j)
 	}
@@ -105,7 +173,10 @@ func (j *Job) Copy() *Job {
 	nj := new(Job)
 	*nj = *j
 	nj.Datacenters = internal.CopySliceString(nj.Datacenters)
+	nj.Labels = internal.CopyMapStringString(nj.Labels)
+	nj.DependsOn = internal.CopySliceString(nj.DependsOn)
 	nj.Constraints = CopySliceConstraints(nj.Constraints)
+	nj.RestartPolicy = nj.RestartPolicy.Copy()
 
 	if j.Tasks != nil {
 		ts := make([]*Task, len(nj.Tasks))
@@ -118,6 +189,138 @@ func (j *Job) Copy() *Job {
 	return nj
 }
 
+// jobRuntimeConfigKeys are the task config keys set by the servers and the
+// tasks while the job runs, which are not part of the job spec.
+var jobRuntimeConfigKeys = map[string]bool{
+	"Gtid":                 true,
+	"NatsAddr":             true,
+	"TrafficAgainstLimits": true,
+}
+
+// IsJobRuntimeConfigKey returns whether the task config key is set by the
+// servers and the tasks while the job runs, rather than by the job spec.
+func IsJobRuntimeConfigKey(key string) bool {
+	return jobRuntimeConfigKeys[key]
+}
+
+// jobTablesConfigKeys are the task config keys of the tables replicated, which
+// could change while the job runs.
+var jobTablesConfigKeys = map[string]bool{
+	"ReplicateDoDb": true,
+}
+
+// SpecChanged returns whether the spec of the new job differs from j. The
+// status, the raft indexes and the task config set while running are ignored,
+// but for a Gtid given in the new job, which differs from that of j.
+func (j *Job) SpecChanged(new *Job) bool {
+	if j == nil || new == nil {
+		return j != new
+	}
+	h1, err := j.specHash(nil)
+	if err != nil {
+		return true
+	}
+	h2, err := new.specHash(nil)
+	if err != nil {
+		return true
+	}
+	return !bytes.Equal(h1, h2) || j.gtidChanged(new)
+}
+
+// gtidChanged returns whether a task of the new job is given a Gtid other than
+// that of the task of the same type of j.
+func (j *Job) gtidChanged(new *Job) bool {
+	for _, t2 := range new.Tasks {
+		gtid := t2.configString("Gtid")
+		if gtid == "" {
+			continue
+		}
+		for _, t1 := range j.Tasks {
+			if t1.Type == t2.Type && t1.configString("Gtid") != gtid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *Task) configString(key string) string {
+	if t.ConfigLock != nil {
+		t.ConfigLock.RLock()
+		defer t.ConfigLock.RUnlock()
+	}
+	if v, ok := t.Config[key]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+// OnlyTablesChanged returns whether the spec of the new job differs from j in
+// the tables replicated only, which the tasks apply while running.
+func (j *Job) OnlyTablesChanged(new *Job) bool {
+	if !j.SpecChanged(new) {
+		return false
+	}
+	h1, err := j.specHash(jobTablesConfigKeys)
+	if err != nil {
+		return false
+	}
+	h2, err := new.specHash(jobTablesConfigKeys)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(h1, h2)
+}
+
+// specHash is the hash of the job spec. Values are hashed by their JSON
+// encoding, as the task configs decoded from JSON and from msgpack may hold
+// different types for the same value. The task config keys in ignored are left
+// out.
+func (j *Job) specHash(ignored map[string]bool) ([]byte, error) {
+	nj := *j
+	nj.Status = ""
+	nj.StatusDescription = ""
+	nj.Waiting = false
+	nj.FullCopyComplete = false
+	nj.EnforceIndex = false
+	nj.CreateIndex = 0
+	nj.ModifyIndex = 0
+	nj.JobModifyIndex = 0
+	nj.Tasks = nil
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	if err := enc.Encode(&nj); err != nil {
+		return nil, err
+	}
+	for _, t := range j.Tasks {
+		if err := t.encodeSpec(enc, ignored); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+func (t *Task) encodeSpec(enc *json.Encoder, ignored map[string]bool) error {
+	if t.ConfigLock != nil {
+		t.ConfigLock.RLock()
+		defer t.ConfigLock.RUnlock()
+	}
+	nt := *t
+	nt.ConfigLock = nil
+	nt.Config = nil
+	for k, v := range t.Config {
+		if jobRuntimeConfigKeys[k] || ignored[k] {
+			continue
+		}
+		if nt.Config == nil {
+			nt.Config = make(map[string]interface{})
+		}
+		nt.Config[k] = v
+	}
+	return enc.Encode(&nt)
+}
+
 // Validate is used to sanity check a job input
 func (j *Job) Validate() error {
 	var mErr multierror.Error
@@ -133,9 +336,15 @@ func (j *Job) Validate() error {
 	if j.Name == "" {
 		mErr.Errors = append(mErr.Errors, errors.New("Missing job name"))
 	}
+	if !ValidNamespace(j.Namespace) {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid job namespace %q", j.Namespace))
+	}
 	if j.Type == "" {
 		mErr.Errors = append(mErr.Errors, errors.New("Missing job type"))
 	}
+	if j.Priority < JobMinPriority || j.Priority > JobMaxPriority {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Job must be between priority levels %d and %d", JobMinPriority, JobMaxPriority))
+	}
 	if len(j.Datacenters) == 0 {
 		mErr.Errors = append(mErr.Errors, errors.New("Missing job datacenters"))
 	}
@@ -149,6 +358,12 @@ func (j *Job) Validate() error {
 		}
 	}
 
+	if j.RestartPolicy != nil {
+		if err := j.RestartPolicy.Validate(); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart policy validation failed: %v", err))
+		}
+	}
+
 	// Check for duplicate tasks
 	tasks := make(map[string]int)
 	for idx, t := range j.Tasks {
@@ -169,6 +384,73 @@ func (j *Job) Validate() error {
 		}
 	}
 
+	if j.Type == JobTypeBench || j.Type == JobTypeVerify {
+		for _, t := range j.Tasks {
+			if t.Driver != TaskDriverMySQL {
+				mErr.Errors = append(mErr.Errors, fmt.Errorf("Task %s of a %s job must use the %s driver", t.Type, j.Type, TaskDriverMySQL))
+			}
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+const (
+	// RestartPolicyModeDelay restarts the task after the next interval begins
+	// once the attempts of an interval are exhausted.
+	RestartPolicyModeDelay = "delay"
+	// RestartPolicyModeFail fails the task once the attempts of an interval
+	// are exhausted.
+	RestartPolicyModeFail = "fail"
+)
+
+// RestartPolicy is how the client restarts a task whose driver failed or
+// exited. Up to Attempts restarts are made within Interval, each after Delay
+// plus a jitter. Mode then decides between waiting for the next interval and
+// failing the task.
+type RestartPolicy struct {
+	Attempts int
+	Interval time.Duration
+	Delay    time.Duration
+	Mode     string
+}
+
+// DefaultRestartPolicy is the restart policy of the jobs registered without
+// one.
+func DefaultRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{
+		Attempts: 5,
+		Interval: 1 * time.Minute,
+		Delay:    15 * time.Second,
+		Mode:     RestartPolicyModeDelay,
+	}
+}
+
+func (p *RestartPolicy) Copy() *RestartPolicy {
+	if p == nil {
+		return nil
+	}
+	np := new(RestartPolicy)
+	*np = *p
+	return np
+}
+
+func (p *RestartPolicy) Validate() error {
+	var mErr multierror.Error
+	switch p.Mode {
+	case RestartPolicyModeDelay, RestartPolicyModeFail:
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Unsupported restart mode: %q", p.Mode))
+	}
+	if p.Attempts < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Attempts must be non-negative, got %v", p.Attempts))
+	}
+	if p.Interval <= 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Interval must be positive, got %v", p.Interval))
+	}
+	if p.Delay < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Delay must be non-negative, got %v", p.Delay))
+	}
 	return mErr.ErrorOrNil()
 }
 
@@ -182,12 +464,25 @@ func (j *Job) LookupTask(tp string) *Task {
 	return nil
 }
 
+// MatchLabels returns true if the job has all the labels in selector.
+func (j *Job) MatchLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := j.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Stub is used to return a summary of the job
 func (j *Job) Stub(job *Job) *JobListStub {
 	return &JobListStub{
 		ID:                j.ID,
 		Name:              j.Name,
+		Namespace:         j.Namespace,
 		Type:              j.Type,
+		Priority:          j.Priority,
+		Labels:            j.Labels,
 		Status:            j.Status,
 		StatusDescription: j.StatusDescription,
 		CreateIndex:       j.CreateIndex,
@@ -202,7 +497,10 @@ func (j *Job) Stub(job *Job) *JobListStub {
 type JobListStub struct {
 	ID                string
 	Name              string
+	Namespace         string
 	Type              string
+	Priority          int
+	Labels            map[string]string
 	Status            string
 	StatusDescription string
 	JobSummary        *Job
@@ -213,6 +511,10 @@ type JobListStub struct {
 
 type JobResponse struct {
 	Success bool
+
+	// JobModifyIndex is the job modify index after a register. It is left
+	// unchanged if the registered spec is the same as the existing job.
+	JobModifyIndex uint64
 	QueryMeta
 }
 
@@ -176,6 +176,10 @@ type Node struct {
 
 	NatsAddr string
 
+	// MaxAllocs is the number of allocations the node could run at the same
+	// time. Zero means no limit.
+	MaxAllocs int
+
 	// Attributes is an arbitrary set of key/value
 	// data that can be used for constraints. Examples
 	// include "kernel.name=linux", "arch=386", "driver.docker=1",
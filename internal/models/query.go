@@ -39,6 +39,11 @@ const (
 	EvalDeleteRequestType
 	AllocUpdateRequestType
 	AllocClientUpdateRequestType
+	AuditEventUpsertRequestType
+	AuditEventPruneRequestType
+	CARootsSetRequestType
+	ConnectionProfileUpsertRequestType
+	ConnectionProfileDeleteRequestType
 )
 
 const (
@@ -279,6 +284,30 @@ type RaftPeerByAddressRequest struct {
 	WriteRequest
 }
 
+// SnapshotResponse is used by the Operator.SnapshotSave endpoint to return an
+// archive of the server state.
+type SnapshotResponse struct {
+	Snapshot []byte
+	QueryMeta
+}
+
+// SnapshotRestoreRequest is used by the Operator.SnapshotRestore endpoint to
+// restore the server state from an archive saved by Operator.SnapshotSave.
+type SnapshotRestoreRequest struct {
+	Snapshot []byte
+	WriteRequest
+}
+
+// GarbageCollectResponse is used by the System.GarbageCollect endpoint to
+// return the numbers of collected objects.
+type GarbageCollectResponse struct {
+	Evals       int
+	Allocations int
+	Jobs        int
+	Nodes       int
+	WriteMeta
+}
+
 // RaftRemovePeerRequest is used by the Operator endpoint to apply a Raft
 // operation on a specific Raft peer by address in the form of "IP:port".
 type RaftRemovePeerRequest struct {
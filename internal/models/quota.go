@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+import "fmt"
+
+// NamespaceQuota limits the resources of the running jobs of a namespace. A
+// zero limit means no limit.
+type NamespaceQuota struct {
+	// MaxRunningJobs is the max number of running jobs.
+	MaxRunningJobs int
+	// MaxDumpWorkers is the max sum of the ParallelWorkers of the Dest tasks,
+	// which apply the full copy.
+	MaxDumpWorkers int
+	// MaxBandwidthMBps is the max sum of the BandwidthLimitMBps of the Src tasks.
+	// The Src tasks of the namespace must set BandwidthLimitMBps.
+	MaxBandwidthMBps int
+	// Queue keeps a new job exceeding the quota pending until the running jobs
+	// leave room for it, instead of rejecting it.
+	Queue bool
+}
+
+// QuotaUsage is the resources used by jobs, counted against a NamespaceQuota.
+type QuotaUsage struct {
+	Jobs          int
+	DumpWorkers   int
+	BandwidthMBps int
+}
+
+// Add returns the sum of the usages.
+func (u QuotaUsage) Add(o QuotaUsage) QuotaUsage {
+	return QuotaUsage{
+		Jobs:          u.Jobs + o.Jobs,
+		DumpWorkers:   u.DumpWorkers + o.DumpWorkers,
+		BandwidthMBps: u.BandwidthMBps + o.BandwidthMBps,
+	}
+}
+
+// Exceeded returns an error describing the first limit of the quota the usage
+// exceeds, or nil.
+func (q *NamespaceQuota) Exceeded(u QuotaUsage) error {
+	if q == nil {
+		return nil
+	}
+	if q.MaxRunningJobs > 0 && u.Jobs > q.MaxRunningJobs {
+		return fmt.Errorf("running jobs %d exceed the quota %d", u.Jobs, q.MaxRunningJobs)
+	}
+	if q.MaxDumpWorkers > 0 && u.DumpWorkers > q.MaxDumpWorkers {
+		return fmt.Errorf("dump workers %d exceed the quota %d", u.DumpWorkers, q.MaxDumpWorkers)
+	}
+	if q.MaxBandwidthMBps > 0 && u.BandwidthMBps > q.MaxBandwidthMBps {
+		return fmt.Errorf("bandwidth %dMB/s exceeds the quota %dMB/s", u.BandwidthMBps, q.MaxBandwidthMBps)
+	}
+	return nil
+}
@@ -7,6 +7,11 @@
 package models
 
 import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
 	gonats "github.com/nats-io/go-nats"
 )
 
@@ -55,6 +60,27 @@ type BufferStat struct {
 	SendBySizeFull          int
 }
 
+// TransportStat is the statistics of a task on the nats channel of its job.
+// The rates are those since the previous statistics of the task.
+type TransportStat struct {
+	// (Src) Messages and bytes published and acked by the Dest task per second.
+	PublishMsgsPerSec  float64
+	PublishBytesPerSec float64
+	// (Dest) Messages and bytes received per second.
+	ConsumeMsgsPerSec  float64
+	ConsumeBytesPerSec float64
+	// (Dest) Messages and bytes received but not yet handled.
+	PendingMsgs  int
+	PendingBytes int
+	// (Src) Messages published again as the Dest task did not ack in time.
+	Redeliveries uint64
+	// (Dest) Messages not acked as the queues were full, and the messages
+	// dropped by nats as the task did not keep up.
+	SlowConsumerEvents uint64
+	// (Src) The delay before each message, while the Dest task is slow.
+	ThrottleDelay string
+}
+
 type CurrentCoordinates struct {
 	File     string
 	Position int64
@@ -80,10 +106,250 @@ type TaskStatistics struct {
 	ThroughputStat     *ThroughputStat
 	MsgStat            gonats.Statistics
 	BufferStat         BufferStat
+	TransportStat      *TransportStat
 	Stage              string
 	Timestamp          int64
+	// The last time a transaction was applied, in unix nanoseconds, 0 if none.
+	// DelayCount.Time is then how many seconds it was behind the source.
+	LastApplyTime int64
+	// The table being copied in the full copy, nil if none.
+	TableCopyProgress *TableCopyProgress
+	// The full copy has been applied, or there is none.
+	FullCopyComplete bool
+	// The progress of a bench job, nil for the other jobs.
+	Bench *BenchStat
+	// The checks of a verify job, nil for the other jobs.
+	Verify *VerifyStat
+	// The progress of the incremental replication, nil before it starts.
+	IncrProgress *IncrProgress
+	// (Src) How many statements of each category of UnsupportedStatements were
+	// not replicated, nil if none.
+	UnsupportedStatements map[string]int64
+	// (Src) The lag of the source behind its primary, with
+	// MaxSourceReplicaLagSeconds, nil if the source is not a replica.
+	SourceReplicaLag *SourceReplicaLag
+	// (Dest) The write conflicts found by RowImageCheck, nil if it is off.
+	WriteConflicts *WriteConflicts
+	// (Dest) The read_only of the target, with TargetReadOnly, nil if off.
+	TargetReadOnly *TargetReadOnly
+}
+
+// TargetReadOnly is the read_only of the target last checked, which the task
+// set when it started.
+type TargetReadOnly struct {
+	ReadOnly      bool
+	SuperReadOnly bool
+	// How many times read_only was found unset.
+	Disabled int64
+	// Whether the applying is paused until read_only is set again.
+	Paused bool
+}
+
+// WriteConflicts counts the rows of the target which differed from the
+// before-image of an UPDATE or a DELETE of the source, written by something
+// else than the job.
+type WriteConflicts struct {
+	Count int64
+	// The last conflict found, nil if none.
+	Last *WriteConflict
+}
+
+// WriteConflict is a row of the target which differed from the before-image of
+// an UPDATE or a DELETE.
+type WriteConflict struct {
+	Time time.Time
+	Gtid string
+	// `schema`.`table`
+	Table string
+	// Update or Delete.
+	DML string
+	// The row is missing on the target.
+	Missing bool
+	// The columns which differ, nil with RowImageCheckChecksum.
+	Columns []string
+}
+
+func (c *WriteConflict) String() string {
+	switch {
+	case c.Missing:
+		return fmt.Sprintf("%v of %v in %v: the row is missing on the target", c.DML, c.Table, c.Gtid)
+	case len(c.Columns) > 0:
+		return fmt.Sprintf("%v of %v in %v: columns %v of the row differ on the target",
+			c.DML, c.Table, c.Gtid, strings.Join(c.Columns, ", "))
+	default:
+		return fmt.Sprintf("%v of %v in %v: the checksum of the row differs on the target", c.DML, c.Table, c.Gtid)
+	}
+}
+
+// SourceReplicaLag is the lag of the source, itself a replica, which pauses the
+// full copy while over MaxSeconds.
+type SourceReplicaLag struct {
+	// The Seconds_Behind_Master of the source last queried, -1 if its
+	// replication is stopped.
+	Seconds    int64
+	MaxSeconds int64
+	// Whether the full copy is paused.
+	Throttled bool
+	// How long the full copy has been paused in total.
+	ThrottledTime time.Duration
+}
+
+// IncrProgress is how far the incremental replication of a task is behind the
+// source.
+type IncrProgress struct {
+	// (Src) The gtid_executed of the source, refreshed every few seconds.
+	SourceGtidSet string
+	// (Src) The gtid set read from the binlog. (Dest) The gtid set applied.
+	GtidSet string
+	// (Src) Transactions of SourceGtidSet not read yet.
+	TransactionsBehind int64
+	// (Src) Seconds from the last transaction read to now, 0 if none is behind.
+	// (Dest) Seconds the last transaction applied was behind the source.
+	SecondsBehind int64
+}
+
+// BenchStat is the progress of a task of a bench job. The Src task fills the
+// generated transactions, the Dest task the rows and transactions applied.
+type BenchStat struct {
+	// Transactions to generate after the full copy.
+	Transactions          int64
+	GeneratedTransactions int64
+	// Seconds from the first transaction generated to the last, or to now.
+	GenerateSeconds float64
+	// Rows of the full copy applied, and the seconds from receiving the first
+	// chunk to applying the last, or to now.
+	DumpRows    int64
+	DumpSeconds float64
+	// Transactions applied after the full copy, and the seconds from the first
+	// applied to the last.
+	AppliedTransactions int64
+	ApplySeconds        float64
+}
+
+// VerifyStat is the progress of a task of a verify job. The Dest task compares
+// the rows of each query, and replies the results to the Src task.
+type VerifyStat struct {
+	// Rounds of the queries run.
+	Rounds int64
+	// The last round, in unix nanoseconds, 0 if none.
+	LastRoundTime int64
+	// All the queries returned the same rows in the last round.
+	Passed bool
+	// Why the last round failed, besides the queries, e.g. the source or the
+	// target could not be reached.
+	Error  string
+	Checks []*VerifyCheckResult
+}
+
+// VerifyCheckResult is the result of a query of a verify job in the last round.
+type VerifyCheckResult struct {
+	Name   string
+	Passed bool
+	// The rows of the source and the target, as JSON arrays of the columns
+	// with NULL as null, empty if the query failed.
+	Source string
+	Target string
+	Error  string
+}
+
+// TableCopyProgress is the progress of the full copy of a table, updated after each chunk.
+type TableCopyProgress struct {
+	// `schema`.`table`
+	Table string
+	// Chunks copied so far.
+	ChunkIndex int64
+	Rows       int64
+	// Counted before the copy. Might be inexact.
+	RowsEstimate int64
+	Bytes        int64
+	Elapsed      time.Duration
+	Done         bool
+}
+
+// Pct is the percent of the rows copied, at most 100.
+func (p *TableCopyProgress) Pct() float64 {
+	if p.Done {
+		return 100
+	}
+	if p.RowsEstimate <= 0 {
+		return 0
+	}
+	return math.Min(100, 100*float64(p.Rows)/float64(p.RowsEstimate))
+}
+
+func (p *TableCopyProgress) String() string {
+	return fmt.Sprintf("%s: %.1f%%, %d rows, %d bytes in %d chunks, %v",
+		p.Table, p.Pct(), p.Rows, p.Bytes, p.ChunkIndex, p.Elapsed)
 }
 
 type AllocStatistics struct {
 	Tasks map[string]*TaskStatistics
 }
+
+// ClientStats is the load of the node of an agent: the resource usage of its
+// host, and of each of its allocations.
+type ClientStats struct {
+	NodeID string
+	Host   *HostStats
+	Allocs []*AllocResourceUsage
+}
+
+// HostStats is the resource usage of a host.
+type HostStats struct {
+	// Unix nanoseconds of the collection.
+	Timestamp int64
+	// Seconds since boot.
+	Uptime uint64
+	CPU    *HostCPUStats
+	Memory *HostMemoryStats
+	// The disk of the AllocDir.
+	Disk    *HostDiskStats
+	Network *HostNetworkStats
+}
+
+type HostCPUStats struct {
+	Cores int
+	// The usage of all the cores since the previous collection, 0-100.
+	Percent float64
+}
+
+type HostMemoryStats struct {
+	Total     uint64
+	Available uint64
+	Used      uint64
+	Free      uint64
+}
+
+type HostDiskStats struct {
+	Path        string
+	Size        uint64
+	Used        uint64
+	Available   uint64
+	UsedPercent float64
+}
+
+// HostNetworkStats is the traffic of all the interfaces since boot.
+type HostNetworkStats struct {
+	BytesSent   uint64
+	BytesRecv   uint64
+	PacketsSent uint64
+	PacketsRecv uint64
+}
+
+// AllocResourceUsage is the resource usage of the tasks of an allocation.
+type AllocResourceUsage struct {
+	AllocID      string
+	JobID        string
+	ClientStatus string
+	Tasks        map[string]*TaskResourceUsage
+}
+
+// TaskResourceUsage is the resource usage of the process of a task. A task run
+// in the agent process has no usage of its own.
+type TaskResourceUsage struct {
+	// The pid of the process of the task, 0 if it runs in the agent process.
+	Pid int
+	// The CPU usage since the previous collection, 0-100 per core.
+	CPUPercent float64
+	MemoryRSS  uint64
+}
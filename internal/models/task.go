@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"time"
 
@@ -22,9 +23,12 @@ const (
 	TaskTypeSrc  = "Src"
 	TaskTypeDest = "Dest"
 
-	TaskDriverMySQL  = "MySQL"
-	TaskDriverKafka  = "Kafka"
-	TaskDriverOracle = "Oracle"
+	TaskDriverMySQL      = "MySQL"
+	TaskDriverKafka      = "Kafka"
+	TaskDriverOracle     = "Oracle"
+	TaskDriverClickHouse = "ClickHouse"
+	TaskDriverMongoDB    = "MongoDB"
+	TaskDriverSQLServer  = "SQLServer"
 )
 
 // Task is a single process typically that is executed as part of a task.
@@ -50,6 +54,18 @@ type Task struct {
 	// Constraints can be specified at a task group level and apply to
 	// all the tasks contained.
 	Constraints []*Constraint
+
+	// Resources limits the processes of the task, nil for no limit.
+	Resources *Resources
+}
+
+// Resources are the limits of the processes of a task, enforced with cgroups
+// on the clients configured with a cgroup_parent. Zero means no limit.
+type Resources struct {
+	// CPU is the number of cores, e.g. 0.5 for half a core.
+	CPU float64
+	// MemoryMB is the memory in MB, the task is killed beyond it.
+	MemoryMB int
 }
 
 func NewTask() *Task {
@@ -68,6 +84,10 @@ func (t *Task) Copy() *Task {
 
 	nt := new(Task)
 	*nt = *t
+	if t.Resources != nil {
+		nt.Resources = new(Resources)
+		*nt.Resources = *t.Resources
+	}
 
 	nt.ConfigLock.RLock()
 	defer nt.ConfigLock.RUnlock()
@@ -79,6 +99,67 @@ func (t *Task) Copy() *Task {
 	return nt
 }
 
+// ConfigChanged returns whether the config of the new task differs from t but
+// in the tables replicated, which could change while the task runs.
+func (t *Task) ConfigChanged(new *Task) bool {
+	return !reflect.DeepEqual(t.configWithoutTables(), new.configWithoutTables())
+}
+
+// TablesChanged returns whether the tables replicated by the new task differ
+// from t.
+func (t *Task) TablesChanged(new *Task) bool {
+	t.rLockConfig()
+	defer t.rUnlockConfig()
+	for k := range jobTablesConfigKeys {
+		if !reflect.DeepEqual(t.Config[k], new.Config[k]) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTables sets the tables replicated by t to those of the new task.
+func (t *Task) SetTables(new *Task) {
+	if t.ConfigLock != nil {
+		t.ConfigLock.Lock()
+		defer t.ConfigLock.Unlock()
+	}
+	if t.Config == nil {
+		t.Config = make(map[string]interface{})
+	}
+	for k := range jobTablesConfigKeys {
+		if v, ok := new.Config[k]; ok {
+			t.Config[k] = v
+		} else {
+			delete(t.Config, k)
+		}
+	}
+}
+
+func (t *Task) configWithoutTables() map[string]interface{} {
+	t.rLockConfig()
+	defer t.rUnlockConfig()
+	config := make(map[string]interface{}, len(t.Config))
+	for k, v := range t.Config {
+		if !jobTablesConfigKeys[k] {
+			config[k] = v
+		}
+	}
+	return config
+}
+
+func (t *Task) rLockConfig() {
+	if t.ConfigLock != nil {
+		t.ConfigLock.RLock()
+	}
+}
+
+func (t *Task) rUnlockConfig() {
+	if t.ConfigLock != nil {
+		t.ConfigLock.RUnlock()
+	}
+}
+
 // Canonicalize canonicalizes fields in the task.
 func (t *Task) Canonicalize(job *Job) {
 	if len(t.Config) == 0 {
@@ -104,6 +185,9 @@ func (t *Task) Validate() error {
 	if t.Driver == "" {
 		mErr.Errors = append(mErr.Errors, errors.New("Missing task driver"))
 	}
+	if t.Resources != nil && (t.Resources.CPU < 0 || t.Resources.MemoryMB < 0) {
+		mErr.Errors = append(mErr.Errors, errors.New("Task resources cannot be negative"))
+	}
 
 	return mErr.ErrorOrNil()
 }
@@ -219,6 +303,21 @@ const (
 
 	// TaskLeaderDead indicates that the leader task within the has finished.
 	TaskLeaderDead = "Leader Task Dead"
+
+	// TaskCopyProgress reports the progress of the full copy of a table.
+	TaskCopyProgress = "Copy Progress"
+
+	// TaskFullCopyComplete indicates that the full copy has been applied. The
+	// jobs depending on the job are scheduled after it.
+	TaskFullCopyComplete = "Full Copy Complete"
+
+	// TaskWriteConflict reports a row of the target which differed from the
+	// before-image of an UPDATE or a DELETE, with RowImageCheck.
+	TaskWriteConflict = "Write Conflict"
+
+	// TaskTargetReadOnlyDisabled reports that the read_only of the target,
+	// set with TargetReadOnly, was found unset.
+	TaskTargetReadOnlyDisabled = "Target Read Only Disabled"
 )
 
 // TaskEvent is an event that effects the state of a task and contains meta-data
@@ -270,6 +369,9 @@ type TaskEvent struct {
 
 	// DriverMessage indicates a driver action being taken.
 	DriverMessage string
+
+	// CopyProgress is the progress of the full copy of a table.
+	CopyProgress *TableCopyProgress
 }
 
 func (te *TaskEvent) GoString() string {
@@ -383,6 +485,12 @@ func (e *TaskEvent) SetDriverMessage(m string) *TaskEvent {
 	return e
 }
 
+func (e *TaskEvent) SetCopyProgress(p *TableCopyProgress) *TaskEvent {
+	e.CopyProgress = p
+	e.Message = p.String()
+	return e
+}
+
 type TaskUpdate struct {
 	JobID    string
 	Gtid     string
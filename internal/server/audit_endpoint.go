@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/armon/go-metrics"
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// Audit endpoint is used to record and query the changes requested through the
+// HTTP API.
+type Audit struct {
+	srv *Server
+}
+
+// Record is used to record audit events.
+func (a *Audit) Record(args *models.AuditEventUpsertRequest, reply *models.GenericResponse) error {
+	if done, err := a.srv.forward("Audit.Record", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "audit", "record"}, time.Now())
+
+	if len(args.Events) == 0 {
+		return fmt.Errorf("missing audit events")
+	}
+	for _, event := range args.Events {
+		if event.ID == "" {
+			event.ID = models.GenerateUUID()
+		}
+	}
+
+	// Servers of previous versions ignore the events.
+	_, index, err := a.srv.raftApply(models.AuditEventUpsertRequestType|models.IgnoreUnknownTypeFlag, args)
+	if err != nil {
+		a.srv.logger.Errorf("server.audit: Record failed: %v", err)
+		return err
+	}
+	reply.Index = index
+	return nil
+}
+
+// List is used to list the audit events matching the filters, the oldest first.
+func (a *Audit) List(args *models.AuditEventListRequest,
+	reply *models.AuditEventListResponse) error {
+	if done, err := a.srv.forward("Audit.List", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "audit", "list"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			iter, err := state.AuditEvents(ws)
+			if err != nil {
+				return err
+			}
+
+			events := []*models.AuditEvent{}
+			for raw := iter.Next(); raw != nil; raw = iter.Next() {
+				event := raw.(*models.AuditEvent)
+				if auditEventMatches(event, args) {
+					events = append(events, event)
+				}
+			}
+			sort.Slice(events, func(i, j int) bool {
+				if events[i].Time != events[j].Time {
+					return events[i].Time < events[j].Time
+				}
+				return events[i].CreateIndex < events[j].CreateIndex
+			})
+			reply.Events = events
+
+			// Use the last index that affected the audit table
+			index, err := state.Index("audit")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			a.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return a.srv.blockingRPC(&opts)
+}
+
+// auditEventMatches returns whether the event matches the filters of the request.
+func auditEventMatches(event *models.AuditEvent, args *models.AuditEventListRequest) bool {
+	if args.Since != 0 && event.Time < args.Since {
+		return false
+	}
+	if args.Until != 0 && event.Time >= args.Until {
+		return false
+	}
+	if args.Namespace != "" && event.Namespace != args.Namespace {
+		return false
+	}
+	if args.Target != "" {
+		for _, target := range event.Targets {
+			if target == args.Target {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
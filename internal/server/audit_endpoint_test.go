@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func TestAuditEventMatches(t *testing.T) {
+	event := &models.AuditEvent{Time: 100, Namespace: "team-a", Targets: []string{"job1", "job2"}}
+	cases := []struct {
+		args  models.AuditEventListRequest
+		match bool
+	}{
+		{args: models.AuditEventListRequest{}, match: true},
+		{args: models.AuditEventListRequest{Target: "job2", Namespace: "team-a"}, match: true},
+		{args: models.AuditEventListRequest{Target: "job3"}, match: false},
+		{args: models.AuditEventListRequest{Namespace: "team-b"}, match: false},
+		{args: models.AuditEventListRequest{Since: 100, Until: 101}, match: true},
+		{args: models.AuditEventListRequest{Since: 101}, match: false},
+		{args: models.AuditEventListRequest{Until: 100}, match: false},
+	}
+	for i, c := range cases {
+		if match := auditEventMatches(event, &c.args); match != c.match {
+			t.Errorf("case %d: auditEventMatches() = %v, expect %v", i, match, c.match)
+		}
+	}
+}
+
+func TestPruneAuditEvents(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []*models.AuditEvent{
+		{ID: uuidA1, Time: 100, Method: "POST", Path: "/v1/jobs"},
+		{ID: uuidE1, Time: 200, Method: "DELETE", Path: "/v1/job/job1"},
+	}
+	if err := state.UpsertAuditEvents(10, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.PruneAuditEvents(11, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	iter, err := state.AuditEvents(memdb.NewWatchSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		event := raw.(*models.AuditEvent)
+		if event.CreateIndex != 10 {
+			t.Errorf("CreateIndex = %d, expect 10", event.CreateIndex)
+		}
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 1 || ids[0] != uuidE1 {
+		t.Errorf("audit events after pruning = %v, expect [%v]", ids, uuidE1)
+	}
+	if index, err := state.Index("audit"); err != nil || index != 11 {
+		t.Errorf("Index(audit) = %d, %v", index, err)
+	}
+}
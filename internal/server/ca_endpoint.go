@@ -0,0 +1,222 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+	"github.com/actiontech/dtle/internal/tlsutil"
+)
+
+// CA endpoint is used to issue the certificates of the mutual TLS of the RPC,
+// and to rotate the roots of the built-in CA.
+type CA struct {
+	srv *Server
+}
+
+// caBootstrap only exposes CA.Sign, to the agents connecting without a
+// certificate.
+type caBootstrap struct {
+	ca *CA
+}
+
+// Sign is used to sign the certificate request of an agent.
+func (b *caBootstrap) Sign(args *models.CASignRequest, reply *models.CASignResponse) error {
+	return b.ca.Sign(args, reply)
+}
+
+// Sign is used to sign the certificate request of a client with the active root.
+// The roots to trust are returned along with the certificate.
+func (c *CA) Sign(args *models.CASignRequest, reply *models.CASignResponse) error {
+	if done, err := c.srv.forward("CA.Sign", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "ca", "sign"}, time.Now())
+
+	if c.srv.tlsConfigurator == nil {
+		return fmt.Errorf("TLS is not enabled on the server")
+	}
+	token := c.srv.config.TLSConfig.BootstrapToken
+	if token == "" || subtle.ConstantTimeCompare([]byte(args.BootstrapToken), []byte(token)) != 1 {
+		return fmt.Errorf("permission denied: bad bootstrap token")
+	}
+
+	state := c.srv.State()
+	roots, err := state.CARoots(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+	active := activeCARoot(roots)
+	if active == nil {
+		return fmt.Errorf("the CA is not initialized yet")
+	}
+	key, err := c.srv.caRootKey(active)
+	if err != nil {
+		return err
+	}
+	cert, err := tlsutil.SignCSR(active.Certificate, key, args.CSR,
+		tlsutil.ClientName(c.srv.config.Region), c.srv.config.TLSConfig.CertTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign the certificate: %v", err)
+	}
+	reply.Certificate = cert
+	reply.Roots = caRootCertificates(roots)
+
+	index, err := state.Index("ca_roots")
+	if err != nil {
+		return err
+	}
+	reply.Index = index
+	c.srv.setQueryMeta(&reply.QueryMeta)
+	return nil
+}
+
+// Roots is used to list the roots of the CA, without their private keys.
+func (c *CA) Roots(args *models.CARootsRequest, reply *models.CARootsResponse) error {
+	if done, err := c.srv.forward("CA.Roots", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "ca", "roots"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			roots, err := state.CARoots(ws)
+			if err != nil {
+				return err
+			}
+			reply.Roots = make([]*models.CARoot, 0, len(roots))
+			for _, root := range roots {
+				public := *root
+				public.SealedKey = nil
+				reply.Roots = append(reply.Roots, &public)
+			}
+
+			// Use the last index that affected the ca_roots table
+			index, err := state.Index("ca_roots")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			c.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return c.srv.blockingRPC(&opts)
+}
+
+// Rotate is used to replace the active root by a new one. The previous roots
+// are still trusted until the certificates they signed expire.
+func (c *CA) Rotate(args *models.CARotateRequest, reply *models.GenericResponse) error {
+	if done, err := c.srv.forward("CA.Rotate", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "ca", "rotate"}, time.Now())
+
+	if c.srv.tlsConfigurator == nil {
+		return fmt.Errorf("TLS is not enabled on the server")
+	}
+	roots, err := c.srv.State().CARoots(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+	rotated, err := rotateCARoots(roots, c.srv.config.Region, c.srv.caSealKey,
+		c.srv.config.TLSConfig.CertTTL, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, index, err := c.srv.raftApply(models.CARootsSetRequestType|models.IgnoreUnknownTypeFlag,
+		&models.CARootsSetRequest{Roots: rotated})
+	if err != nil {
+		c.srv.logger.Errorf("server.ca: Rotate failed: %v", err)
+		return err
+	}
+	c.srv.logger.Printf("server.ca: rotated the root of the CA")
+	reply.Index = index
+	return nil
+}
+
+// newCARoot returns a new active root, its private key sealed with the seal
+// key.
+func newCARoot(region string, sealKey []byte, now time.Time) (*models.CARoot, error) {
+	cert, key, err := tlsutil.GenerateCA(region)
+	if err != nil {
+		return nil, err
+	}
+	id := models.GenerateUUID()
+	sealed, err := tlsutil.SealKey(sealKey, id, key)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CARoot{
+		ID:          id,
+		Certificate: cert,
+		SealedKey:   sealed,
+		Active:      true,
+		CreateTime:  now.UnixNano(),
+	}, nil
+}
+
+// caRootKey returns the private key of the root, in PEM.
+func (s *Server) caRootKey(root *models.CARoot) (string, error) {
+	return tlsutil.OpenKey(s.caSealKey, root.ID, root.SealedKey)
+}
+
+// rotateCARoots returns the roots after a rotation: a new active root, the
+// previous one, and the older ones which may still have signed certificates
+// valid. The certificates live at most certTTL.
+func rotateCARoots(roots []*models.CARoot, region string, sealKey []byte, certTTL time.Duration,
+	now time.Time) ([]*models.CARoot, error) {
+
+	root, err := newCARoot(region, sealKey, now)
+	if err != nil {
+		return nil, err
+	}
+	rotated := []*models.CARoot{root}
+	for _, old := range roots {
+		old := *old
+		if old.Active {
+			old.Active = false
+			old.RotateTime = now.UnixNano()
+		} else if time.Unix(0, old.RotateTime).Add(certTTL).Before(now) {
+			continue
+		}
+		rotated = append(rotated, &old)
+	}
+	return rotated, nil
+}
+
+// activeCARoot returns the root signing the new certificates, or nil if the CA
+// is not initialized.
+func activeCARoot(roots []*models.CARoot) *models.CARoot {
+	for _, root := range roots {
+		if root.Active {
+			return root
+		}
+	}
+	return nil
+}
+
+// caRootCertificates returns the certificates of the roots.
+func caRootCertificates(roots []*models.CARoot) []string {
+	certs := make([]string, 0, len(roots))
+	for _, root := range roots {
+		certs = append(certs, root.Certificate)
+	}
+	return certs
+}
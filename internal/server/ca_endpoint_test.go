@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/tlsutil"
+)
+
+func TestRotateCARoots(t *testing.T) {
+	now := time.Now()
+	ttl := time.Hour
+	sealKey := []byte(strings.Repeat("k", 32))
+	first, err := newCARoot("global", sealKey, now.Add(-3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotated twice: the first root is still trusted, the certificates it
+	// signed being valid for an hour after the rotation.
+	roots, err := rotateCARoots([]*models.CARoot{first}, "global", sealKey, ttl, now.Add(-50*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err = rotateCARoots(roots, "global", sealKey, ttl, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 3 || !roots[0].Active || roots[1].Active || roots[2].ID != first.ID || roots[2].Active {
+		t.Fatalf("rotateCARoots() = %+v", roots)
+	}
+	s := &Server{caSealKey: sealKey}
+	if key, err := s.caRootKey(roots[0]); err != nil || !strings.Contains(key, "PRIVATE KEY") {
+		t.Errorf("caRootKey() = %v", err)
+	}
+	if first.Active != true {
+		t.Errorf("rotateCARoots() changed the roots given")
+	}
+
+	// Rotated again, more than an hour after the first rotation.
+	roots, err = rotateCARoots(roots, "global", sealKey, ttl, now.Add(15*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 3 || activeCARoot(roots) != roots[0] {
+		t.Fatalf("rotateCARoots() = %+v", roots)
+	}
+	for _, root := range roots {
+		if root.ID == first.ID {
+			t.Errorf("rotateCARoots() kept the expired root")
+		}
+	}
+}
+
+// testTLSServer returns a server verifying the incoming connections, serving
+// Status and, without a certificate, a stub of CA.Sign on the listener, with a
+// certificate signed by the root.
+func testTLSServer(t *testing.T, rootCert, rootKey string) (*Server, net.Listener) {
+	s := &Server{
+		config: &uconf.ServerConfig{
+			Region:    "global",
+			LogOutput: os.Stderr,
+			TLSConfig: &uconf.TLSConfig{EnableRPC: true, VerifyIncoming: true},
+		},
+		logger:             ulog.New(os.Stderr, ulog.InfoLevel),
+		rpcServer:          rpc.NewServer(),
+		bootstrapRPCServer: rpc.NewServer(),
+		tlsConfigurator:    tlsutil.NewConfigurator("global"),
+		shutdownCh:         make(chan struct{}),
+	}
+	s.rpcServer.Register(&Status{s})
+	s.bootstrapRPCServer.RegisterName("CA", &stubCASign{})
+
+	key, keyPEM, err := tlsutil.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tlsutil.Sign(rootCert, rootKey, key.Public(), "s1", tlsutil.ServerName("global"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.tlsConfigurator.Update(cert, keyPEM, []string{rootCert}); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn)
+		}
+	}()
+	return s, ln
+}
+
+type stubCASign struct{}
+
+func (*stubCASign) Sign(args *models.CASignRequest, reply *models.CASignResponse) error {
+	reply.Certificate = "signed " + args.CSR
+	return nil
+}
+
+func TestServerTLSConn(t *testing.T) {
+	rootCert, rootKey, err := tlsutil.GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ln := testTLSServer(t, rootCert, rootKey)
+	defer ln.Close()
+	defer close(s.shutdownCh)
+	addr := ln.Addr()
+
+	// Without TLS.
+	plain := NewPool(os.Stderr, 0, 1)
+	defer plain.Shutdown()
+	if err := plain.RPC("global", addr, "Status.Ping", struct{}{}, &struct{}{}); err == nil {
+		t.Errorf("RPC without TLS succeeded")
+	}
+
+	// Without a certificate, only to get one.
+	root, err := tlsutil.ParseCertificate(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootstrap := tlsutil.BootstrapConfig("global", tlsutil.Fingerprint(root))
+	var reply models.CASignResponse
+	if err := plain.BootstrapRPC(addr, bootstrap, "CA.Sign", &models.CASignRequest{CSR: "csr"}, &reply); err != nil || reply.Certificate != "signed csr" {
+		t.Errorf("BootstrapRPC(CA.Sign) = %v, %q", err, reply.Certificate)
+	}
+	if err := plain.BootstrapRPC(addr, bootstrap, "Status.Ping", struct{}{}, &struct{}{}); err == nil {
+		t.Errorf("BootstrapRPC(Status.Ping) succeeded")
+	}
+
+	// With a certificate of the CA.
+	key, keyPEM, err := tlsutil.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tlsutil.Sign(rootCert, rootKey, key.Public(), "c1", tlsutil.ClientName("global"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configurator := tlsutil.NewConfigurator("global")
+	if err := configurator.Update(cert, keyPEM, []string{rootCert}); err != nil {
+		t.Fatal(err)
+	}
+	secure := NewPool(os.Stderr, 0, 1)
+	defer secure.Shutdown()
+	secure.SetTLSConfigurator(configurator)
+	if err := secure.RPC("global", addr, "Status.Ping", struct{}{}, &struct{}{}); err != nil {
+		t.Errorf("RPC with TLS = %v", err)
+	}
+}
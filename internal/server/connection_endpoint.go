@@ -0,0 +1,192 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// ConnectionProfile endpoint is used to manage the connection profiles the
+// MySQL tasks reference by name.
+type ConnectionProfile struct {
+	srv *Server
+}
+
+// Upsert is used to create or replace a profile.
+func (c *ConnectionProfile) Upsert(args *models.ConnectionProfileUpsertRequest, reply *models.GenericResponse) error {
+	if done, err := c.srv.forward("ConnectionProfile.Upsert", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "connection_profile", "upsert"}, time.Now())
+
+	if err := args.Profile.Validate(); err != nil {
+		return err
+	}
+
+	_, index, err := c.srv.raftApply(models.ConnectionProfileUpsertRequestType|models.IgnoreUnknownTypeFlag, args)
+	if err != nil {
+		c.srv.logger.Errorf("server.connection_profile: Upsert failed: %v", err)
+		return err
+	}
+	reply.Index = index
+	return nil
+}
+
+// Delete is used to delete a profile no job references.
+func (c *ConnectionProfile) Delete(args *models.ConnectionProfileDeleteRequest, reply *models.GenericResponse) error {
+	if done, err := c.srv.forward("ConnectionProfile.Delete", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "connection_profile", "delete"}, time.Now())
+
+	snap, err := c.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	profile, err := snap.ConnectionProfileByName(memdb.NewWatchSet(), args.Name)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		return fmt.Errorf("connection profile %v not found", args.Name)
+	}
+	if jobID, err := jobUsingConnectionProfile(snap, args.Name); err != nil {
+		return err
+	} else if jobID != "" {
+		return fmt.Errorf("connection profile %v is used by job %v", args.Name, jobID)
+	}
+
+	_, index, err := c.srv.raftApply(models.ConnectionProfileDeleteRequestType|models.IgnoreUnknownTypeFlag, args)
+	if err != nil {
+		c.srv.logger.Errorf("server.connection_profile: Delete failed: %v", err)
+		return err
+	}
+	reply.Index = index
+	return nil
+}
+
+// GetProfile is used to get a profile with its password, for the agents
+// starting the tasks referencing it.
+func (c *ConnectionProfile) GetProfile(args *models.ConnectionProfileSpecificRequest,
+	reply *models.SingleConnectionProfileResponse) error {
+	if done, err := c.srv.forward("ConnectionProfile.GetProfile", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "connection_profile", "get_profile"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			profile, err := state.ConnectionProfileByName(ws, args.Name)
+			if err != nil {
+				return err
+			}
+			reply.Profile = profile
+
+			// Use the last index that affected the connection_profiles table
+			index, err := state.Index("connection_profiles")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			c.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return c.srv.blockingRPC(&opts)
+}
+
+// List is used to list the profiles, without their passwords.
+func (c *ConnectionProfile) List(args *models.ConnectionProfileListRequest,
+	reply *models.ConnectionProfileListResponse) error {
+	if done, err := c.srv.forward("ConnectionProfile.List", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "connection_profile", "list"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			profiles, err := state.ConnectionProfiles(ws)
+			if err != nil {
+				return err
+			}
+			reply.Profiles = make([]*models.ConnectionProfile, 0, len(profiles))
+			for _, profile := range profiles {
+				reply.Profiles = append(reply.Profiles, profile.Redacted())
+			}
+
+			// Use the last index that affected the connection_profiles table
+			index, err := state.Index("connection_profiles")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			c.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return c.srv.blockingRPC(&opts)
+}
+
+// validateJobConnectionProfiles checks that the profiles the tasks of the job
+// reference exist in the namespace of the job, and that the tasks don't have a
+// ConnectionConfig as well.
+func validateJobConnectionProfiles(snap *store.StateSnapshot, job *models.Job) error {
+	ws := memdb.NewWatchSet()
+	for _, task := range job.Tasks {
+		name := task.ConnectionProfileName()
+		if name == "" {
+			continue
+		}
+		if _, ok := task.Config["ConnectionConfig"]; ok {
+			return fmt.Errorf("task %v: ConnectionConfig and ConnectionProfile are exclusive", task.Type)
+		}
+		profile, err := snap.ConnectionProfileByName(ws, name)
+		if err != nil {
+			return err
+		}
+		if profile == nil {
+			return fmt.Errorf("task %v: connection profile %v not found", task.Type, name)
+		}
+		if err := profile.CheckNamespace(job.Namespace); err != nil {
+			return fmt.Errorf("task %v: %v", task.Type, err)
+		}
+	}
+	return nil
+}
+
+// jobUsingConnectionProfile returns the ID of a job referencing the profile,
+// empty if none.
+func jobUsingConnectionProfile(snap *store.StateSnapshot, name string) (string, error) {
+	iter, err := snap.Jobs(memdb.NewWatchSet())
+	if err != nil {
+		return "", err
+	}
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		for _, task := range job.Tasks {
+			if task.ConnectionProfileName() == name {
+				return job.ID, nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func TestConnectionProfiles(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := &models.ConnectionProfile{Name: "src1", Host: "10.0.0.1", Port: 3306, User: "dtle", Password: "a"}
+	if err := state.UpsertConnectionProfile(10, profile); err != nil {
+		t.Fatal(err)
+	}
+	rotated := &models.ConnectionProfile{Name: "src1", Host: "10.0.0.1", Port: 3306, User: "dtle", Password: "b"}
+	if err := state.UpsertConnectionProfile(11, rotated); err != nil {
+		t.Fatal(err)
+	}
+	got, err := state.ConnectionProfileByName(memdb.NewWatchSet(), "src1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Password != "b" || got.CreateIndex != 10 || got.ModifyIndex != 11 {
+		t.Errorf("profile = %+v", got)
+	}
+	if redacted := got.Redacted(); redacted.Password != "" || got.Password != "b" {
+		t.Errorf("Redacted() = %+v, profile = %+v", redacted, got)
+	}
+
+	jobs := []*models.Job{
+		{ID: "job1", Type: models.JobTypeSync, Tasks: []*models.Task{
+			{Type: models.TaskTypeSrc, Driver: models.TaskDriverMySQL,
+				Config: map[string]interface{}{"ConnectionProfile": "src1"}},
+			{Type: models.TaskTypeDest, Driver: models.TaskDriverMySQL,
+				Config: map[string]interface{}{"ConnectionConfig": map[string]interface{}{"Host": "10.0.0.2"}}},
+		}},
+	}
+	for i, job := range jobs {
+		if err := state.UpsertJob(uint64(12+i), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateJobConnectionProfiles(snap, jobs[0]); err != nil {
+		t.Errorf("validateJobConnectionProfiles(job1) = %v", err)
+	}
+	missing := &models.Job{ID: "job2", Tasks: []*models.Task{
+		{Type: models.TaskTypeSrc, Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{"ConnectionProfile": "src2"}},
+	}}
+	if err := validateJobConnectionProfiles(snap, missing); err == nil {
+		t.Errorf("validateJobConnectionProfiles() of a missing profile succeeded")
+	}
+	both := &models.Job{ID: "job3", Tasks: []*models.Task{
+		{Type: models.TaskTypeSrc, Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{"ConnectionProfile": "src1", "ConnectionConfig": map[string]interface{}{}}},
+	}}
+	if err := validateJobConnectionProfiles(snap, both); err == nil {
+		t.Errorf("validateJobConnectionProfiles() with a ConnectionConfig succeeded")
+	}
+	otherNamespace := &models.Job{ID: "job4", Namespace: "tenant1", Tasks: []*models.Task{
+		{Type: models.TaskTypeSrc, Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{"ConnectionProfile": "src1"}},
+	}}
+	if err := validateJobConnectionProfiles(snap, otherNamespace); err == nil {
+		t.Errorf("validateJobConnectionProfiles() of a profile of another namespace succeeded")
+	}
+
+	if jobID, err := jobUsingConnectionProfile(snap, "src1"); err != nil || jobID != "job1" {
+		t.Errorf("jobUsingConnectionProfile(src1) = %q, %v", jobID, err)
+	}
+	if jobID, err := jobUsingConnectionProfile(snap, "src2"); err != nil || jobID != "" {
+		t.Errorf("jobUsingConnectionProfile(src2) = %q, %v", jobID, err)
+	}
+
+	if err := state.DeleteConnectionProfile(20, "src1"); err != nil {
+		t.Fatal(err)
+	}
+	if profiles, err := state.ConnectionProfiles(memdb.NewWatchSet()); err != nil || len(profiles) != 0 {
+		t.Errorf("ConnectionProfiles() = %v, %v", profiles, err)
+	}
+	if index, err := state.Index("connection_profiles"); err != nil || index != 20 {
+		t.Errorf("Index(connection_profiles) = %d, %v", index, err)
+	}
+	if err := state.DeleteConnectionProfile(21, "src1"); err == nil {
+		t.Errorf("DeleteConnectionProfile() of a missing profile succeeded")
+	}
+}
+
+func TestConnectionProfile_Validate(t *testing.T) {
+	cases := []struct {
+		profile *models.ConnectionProfile
+		valid   bool
+	}{
+		{&models.ConnectionProfile{Name: "src-1.a_b", Host: "h", Port: 3306, User: "u"}, true},
+		{&models.ConnectionProfile{Name: "src/1", Host: "h", Port: 3306, User: "u"}, false},
+		{&models.ConnectionProfile{Name: "src1", Port: 3306, User: "u"}, false},
+		{&models.ConnectionProfile{Name: "src1", Host: "h", User: "u"}, false},
+		{&models.ConnectionProfile{Name: "src1", Host: "h", Port: 3306}, false},
+		{&models.ConnectionProfile{Name: "src1", Host: "h", Port: 3306, User: "u",
+			Password: "p", PasswordVaultPath: "secret/src1"}, false},
+		{&models.ConnectionProfile{Name: "src1", Namespace: "tenant1", Host: "h", Port: 3306, User: "u"}, true},
+		{&models.ConnectionProfile{Name: "src1", Namespace: "tenant/1", Host: "h", Port: 3306, User: "u"}, false},
+		{nil, false},
+	}
+	for i, c := range cases {
+		err := c.profile.Validate()
+		if c.valid && err != nil {
+			t.Errorf("case %d: Validate() = %v", i, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("case %d: expect an error", i)
+		}
+	}
+}
+
+func TestConnectionProfile_CheckNamespace(t *testing.T) {
+	profile := &models.ConnectionProfile{Name: "src1"}
+	if err := profile.CheckNamespace(""); err != nil {
+		t.Errorf("CheckNamespace() of the default namespace = %v", err)
+	}
+	if err := profile.CheckNamespace(models.DefaultNamespace); err != nil {
+		t.Errorf("CheckNamespace(default) = %v", err)
+	}
+	if err := profile.CheckNamespace("tenant1"); err == nil {
+		t.Errorf("CheckNamespace(tenant1) of a profile of the default namespace succeeded")
+	}
+	profile.Namespace = "tenant1"
+	if err := profile.CheckNamespace("tenant1"); err != nil {
+		t.Errorf("CheckNamespace(tenant1) = %v", err)
+	}
+}
@@ -311,6 +311,7 @@ func (b *EvalBroker) scanForSchedulers(schedulers []string) (*models.Evaluation,
 
 	// Scan for eligible work
 	var eligibleSched []string
+	var eligiblePriority int
 	for _, sched := range schedulers {
 		// Get the pending queue
 		pending, ok := b.ready[sched]
@@ -325,10 +326,14 @@ func (b *EvalBroker) scanForSchedulers(schedulers []string) (*models.Evaluation,
 		}
 
 		// Add to eligible if equal or greater priority
-		if len(eligibleSched) == 0 {
+		if len(eligibleSched) == 0 || ready.Priority > eligiblePriority {
 			eligibleSched = []string{sched}
+			eligiblePriority = ready.Priority
 
-		} else {
+		} else if eligiblePriority > ready.Priority {
+			continue
+
+		} else if eligiblePriority == ready.Priority {
 			eligibleSched = append(eligibleSched, sched)
 		}
 	}
@@ -693,6 +698,9 @@ func (p PendingEvaluations) Len() int {
 // so that the "min" in the min-heap is the element with the
 // highest priority
 func (p PendingEvaluations) Less(i, j int) bool {
+	if p[i].JobID != p[j].JobID && p[i].Priority != p[j].Priority {
+		return !(p[i].Priority < p[j].Priority)
+	}
 	return p[i].CreateIndex < p[j].CreateIndex
 }
 
@@ -41,6 +41,10 @@ const (
 	EvalSnapshot
 	AllocSnapshot
 	TimeTableSnapshot
+	OrderSnapshot
+	AuditEventSnapshot
+	CARootSnapshot
+	ConnectionProfileSnapshot
 )
 
 // udupFSM implements a finite store machine that is used
@@ -155,6 +159,16 @@ func (n *udupFSM) Apply(log *raft.Log) interface{} {
 		return n.applyAllocUpdate(buf[1:], log.Index)
 	case models.AllocClientUpdateRequestType:
 		return n.applyAllocClientUpdate(buf[1:], log.Index)
+	case models.AuditEventUpsertRequestType:
+		return n.applyUpsertAuditEvents(buf[1:], log.Index)
+	case models.AuditEventPruneRequestType:
+		return n.applyPruneAuditEvents(buf[1:], log.Index)
+	case models.CARootsSetRequestType:
+		return n.applySetCARoots(buf[1:], log.Index)
+	case models.ConnectionProfileUpsertRequestType:
+		return n.applyUpsertConnectionProfile(buf[1:], log.Index)
+	case models.ConnectionProfileDeleteRequestType:
+		return n.applyDeleteConnectionProfile(buf[1:], log.Index)
 	default:
 		if ignoreUnknown {
 			n.logger.Warnf("server.fsm: ignoring unknown message type (%d), upgrade to newer version", msgType)
@@ -435,6 +449,81 @@ func (n *udupFSM) applyDeregisterOrder(buf []byte, index uint64) interface{} {
 	return nil
 }
 
+func (n *udupFSM) applyUpsertAuditEvents(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "upsert_audit_events"}, time.Now())
+	var req models.AuditEventUpsertRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.UpsertAuditEvents(index, req.Events); err != nil {
+		n.logger.Errorf("server.fsm: UpsertAuditEvents failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applyPruneAuditEvents(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "prune_audit_events"}, time.Now())
+	var req models.AuditEventPruneRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.PruneAuditEvents(index, req.Before); err != nil {
+		n.logger.Errorf("server.fsm: PruneAuditEvents failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applySetCARoots(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "set_ca_roots"}, time.Now())
+	var req models.CARootsSetRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.SetCARoots(index, req.Roots); err != nil {
+		n.logger.Errorf("server.fsm: SetCARoots failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applyUpsertConnectionProfile(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "upsert_connection_profile"}, time.Now())
+	var req models.ConnectionProfileUpsertRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.UpsertConnectionProfile(index, req.Profile); err != nil {
+		n.logger.Errorf("server.fsm: UpsertConnectionProfile failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applyDeleteConnectionProfile(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "delete_connection_profile"}, time.Now())
+	var req models.ConnectionProfileDeleteRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.DeleteConnectionProfile(index, req.Name); err != nil {
+		n.logger.Errorf("server.fsm: DeleteConnectionProfile failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 func (n *udupFSM) applyUpdateEval(buf []byte, index uint64) interface{} {
 	defer metrics.MeasureSince([]string{"server", "fsm", "update_eval"}, time.Now())
 	var req models.EvalUpdateRequest
@@ -690,6 +779,42 @@ func (n *udupFSM) Restore(old io.ReadCloser) error {
 				return err
 			}
 
+		case OrderSnapshot:
+			order := new(models.Order)
+			if err := dec.Decode(order); err != nil {
+				return err
+			}
+			if err := restore.OrderRestore(order); err != nil {
+				return err
+			}
+
+		case AuditEventSnapshot:
+			event := new(models.AuditEvent)
+			if err := dec.Decode(event); err != nil {
+				return err
+			}
+			if err := restore.AuditEventRestore(event); err != nil {
+				return err
+			}
+
+		case CARootSnapshot:
+			root := new(models.CARoot)
+			if err := dec.Decode(root); err != nil {
+				return err
+			}
+			if err := restore.CARootRestore(root); err != nil {
+				return err
+			}
+
+		case ConnectionProfileSnapshot:
+			profile := new(models.ConnectionProfile)
+			if err := dec.Decode(profile); err != nil {
+				return err
+			}
+			if err := restore.ConnectionProfileRestore(profile); err != nil {
+				return err
+			}
+
 		case IndexSnapshot:
 			idx := new(store.IndexEntry)
 			if err := dec.Decode(idx); err != nil {
@@ -761,6 +886,22 @@ func (s *udupSnapshot) Persist(sink raft.SnapshotSink) error {
 		sink.Cancel()
 		return err
 	}
+	if err := s.persistOrders(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if err := s.persistAuditEvents(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if err := s.persistCARoots(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if err := s.persistConnectionProfiles(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
 
 	return nil
 }
@@ -904,6 +1045,100 @@ func (s *udupSnapshot) persistAllocs(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *udupSnapshot) persistOrders(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the orders
+	ws := memdb.NewWatchSet()
+	orders, err := s.snap.Orders(ws)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := orders.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		order := raw.(*models.Order)
+
+		// Write out the order
+		sink.Write([]byte{byte(OrderSnapshot)})
+		if err := encoder.Encode(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *udupSnapshot) persistAuditEvents(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the audit events
+	ws := memdb.NewWatchSet()
+	events, err := s.snap.AuditEvents(ws)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := events.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		event := raw.(*models.AuditEvent)
+
+		// Write out the audit event
+		sink.Write([]byte{byte(AuditEventSnapshot)})
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *udupSnapshot) persistCARoots(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the roots of the CA
+	ws := memdb.NewWatchSet()
+	roots, err := s.snap.CARoots(ws)
+	if err != nil {
+		return err
+	}
+
+	for _, root := range roots {
+		// Write out the root
+		sink.Write([]byte{byte(CARootSnapshot)})
+		if err := encoder.Encode(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *udupSnapshot) persistConnectionProfiles(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the connection profiles
+	ws := memdb.NewWatchSet()
+	profiles, err := s.snap.ConnectionProfiles(ws)
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range profiles {
+		// Write out the profile
+		sink.Write([]byte{byte(ConnectionProfileSnapshot)})
+		if err := encoder.Encode(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Release is a no-op, as we just need to GC the pointer
 // to the store store snapshot. There is nothing to explicitly
 // cleanup.
@@ -0,0 +1,316 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"math"
+	"time"
+
+	"github.com/armon/go-metrics"
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// maxIDsPerReap is the max number of evaluations or allocations deleted by
+// one raft entry, to keep the entries small.
+const maxIDsPerReap = 1024
+
+// gcStats are the numbers of objects collected by a garbage collection.
+type gcStats struct {
+	Evals  int
+	Allocs int
+	Jobs   int
+	Nodes  int
+}
+
+// periodicGC runs the garbage collection every GCInterval until stopCh is
+// closed. It is run by the leader.
+func (s *Server) periodicGC(stopCh chan struct{}) {
+	if s.config.GCInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.config.GCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if _, err := s.garbageCollect(false); err != nil {
+				s.logger.Errorf("server.gc: Garbage collection failed: %v", err)
+			}
+			if err := s.pruneAuditEvents(); err != nil {
+				s.logger.Errorf("server.gc: Pruning the audit events failed: %v", err)
+			}
+		}
+	}
+}
+
+// garbageCollect deletes the terminal evaluations with their allocations, the
+// terminal jobs and the down nodes which are older than their thresholds. If
+// force is set, the thresholds are ignored.
+func (s *Server) garbageCollect(force bool) (*gcStats, error) {
+	defer metrics.MeasureSince([]string{"server", "gc"}, time.Now())
+
+	evalCutoff := s.gcCutoff(s.config.EvalGCThreshold, force)
+	jobCutoff := s.gcCutoff(s.config.JobGCThreshold, force)
+	nodeCutoff := s.gcCutoff(s.config.NodeGCThreshold, force)
+
+	snap, err := s.fsm.State().Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	stats := &gcStats{}
+
+	jobs, err := gcJobs(snap, jobCutoff)
+	if err != nil {
+		return nil, err
+	}
+	evals, allocs, err := gcEvals(snap, evalCutoff, jobs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reapEvals(evals, allocs); err != nil {
+		return nil, err
+	}
+	stats.Evals = len(evals)
+	stats.Allocs = len(allocs)
+
+	for _, jobID := range jobs {
+		req := models.JobDeregisterRequest{
+			JobID:        jobID,
+			WriteRequest: models.WriteRequest{Region: s.config.Region},
+		}
+		if _, _, err := s.raftApply(models.JobDeregisterRequestType, &req); err != nil {
+			return nil, err
+		}
+		stats.Jobs++
+	}
+
+	nodes, err := gcNodes(snap, nodeCutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeID := range nodes {
+		req := models.NodeDeregisterRequest{
+			NodeID:       nodeID,
+			WriteRequest: models.WriteRequest{Region: s.config.Region},
+		}
+		if _, _, err := s.raftApply(models.NodeDeregisterRequestType, &req); err != nil {
+			return nil, err
+		}
+		stats.Nodes++
+	}
+
+	if stats.Evals+stats.Allocs+stats.Jobs+stats.Nodes > 0 {
+		s.logger.Infof("server.gc: Collected %d evaluations, %d allocations, %d jobs and %d nodes",
+			stats.Evals, stats.Allocs, stats.Jobs, stats.Nodes)
+	}
+	return stats, nil
+}
+
+// gcCutoff returns the raft index before which the objects are older than the
+// threshold. The time table only tracks timeTableLimit, so a larger threshold
+// is capped to it.
+func (s *Server) gcCutoff(threshold time.Duration, force bool) uint64 {
+	if force {
+		return math.MaxUint64
+	}
+	if threshold > timeTableLimit {
+		threshold = timeTableLimit
+	}
+	return s.fsm.TimeTable().NearestIndex(time.Now().UTC().Add(-threshold))
+}
+
+// pruneAuditEvents deletes the audit events older than AuditGCThreshold. Unlike
+// the other objects, they are not collected by a forced collection.
+func (s *Server) pruneAuditEvents() error {
+	if s.config.AuditGCThreshold <= 0 {
+		return nil
+	}
+	before := time.Now().Add(-s.config.AuditGCThreshold).UnixNano()
+
+	snap, err := s.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	iter, err := snap.AuditEvents(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+	expired := 0
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		if raw.(*models.AuditEvent).Time < before {
+			expired++
+		}
+	}
+	if expired == 0 {
+		return nil
+	}
+
+	req := models.AuditEventPruneRequest{
+		Before:       before,
+		WriteRequest: models.WriteRequest{Region: s.config.Region},
+	}
+	if _, _, err := s.raftApply(models.AuditEventPruneRequestType|models.IgnoreUnknownTypeFlag, &req); err != nil {
+		return err
+	}
+	s.logger.Infof("server.gc: Pruned %d audit events", expired)
+	return nil
+}
+
+// reapEvals deletes the evaluations and allocations in batches.
+func (s *Server) reapEvals(evals, allocs []string) error {
+	for len(evals) > 0 || len(allocs) > 0 {
+		req := models.EvalDeleteRequest{
+			WriteRequest: models.WriteRequest{Region: s.config.Region},
+		}
+		n := len(evals)
+		if n > maxIDsPerReap {
+			n = maxIDsPerReap
+		}
+		req.Evals, evals = evals[:n], evals[n:]
+		n = len(allocs)
+		if n > maxIDsPerReap-len(req.Evals) {
+			n = maxIDsPerReap - len(req.Evals)
+		}
+		req.Allocs, allocs = allocs[:n], allocs[n:]
+
+		if _, _, err := s.raftApply(models.EvalDeleteRequestType, &req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcJobs returns the terminal jobs modified before the cutoff, whose
+// evaluations and allocations are all terminal. A job with an allocation
+// failed or lost is dead too, but kept along with its Gtid, to be restarted
+// by registering it again; only the jobs completed or stopped are collected.
+func gcJobs(snap *store.StateSnapshot, cutoff uint64) ([]string, error) {
+	ws := memdb.NewWatchSet()
+	iter, err := snap.Jobs(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+OUTER:
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		if job.ModifyIndex > cutoff {
+			continue
+		}
+		if job.Status != models.JobStatusComplete && job.Status != models.JobStatusDead {
+			continue
+		}
+
+		evals, err := snap.EvalsByJob(ws, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, eval := range evals {
+			if !eval.TerminalStatus() {
+				continue OUTER
+			}
+		}
+		allocs, err := snap.AllocsByJob(ws, job.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, alloc := range allocs {
+			if !alloc.TerminalStatus() ||
+				alloc.ClientStatus == models.AllocClientStatusFailed ||
+				alloc.ClientStatus == models.AllocClientStatusLost {
+				continue OUTER
+			}
+		}
+		result = append(result, job.ID)
+	}
+	return result, nil
+}
+
+// gcEvals returns the terminal evaluations modified before the cutoff whose
+// allocations are all terminal and modified before the cutoff, along with
+// these allocations. All the evaluations and allocations of the collected
+// jobs are included.
+func gcEvals(snap *store.StateSnapshot, cutoff uint64, jobs []string) ([]string, []string, error) {
+	collectedJobs := make(map[string]bool, len(jobs))
+	for _, jobID := range jobs {
+		collectedJobs[jobID] = true
+	}
+
+	ws := memdb.NewWatchSet()
+	iter, err := snap.Evals(ws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var evals, allocs []string
+OUTER:
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		eval := raw.(*models.Evaluation)
+		if !eval.TerminalStatus() {
+			continue
+		}
+		collectedJob := collectedJobs[eval.JobID]
+		if !collectedJob && eval.ModifyIndex > cutoff {
+			continue
+		}
+
+		evalAllocs, err := snap.AllocsByEval(ws, eval.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !collectedJob {
+			for _, alloc := range evalAllocs {
+				if !alloc.TerminalStatus() || alloc.ModifyIndex > cutoff {
+					continue OUTER
+				}
+			}
+		}
+
+		evals = append(evals, eval.ID)
+		for _, alloc := range evalAllocs {
+			allocs = append(allocs, alloc.ID)
+		}
+	}
+	return evals, allocs, nil
+}
+
+// gcNodes returns the down nodes modified before the cutoff without
+// non-terminal allocations.
+func gcNodes(snap *store.StateSnapshot, cutoff uint64) ([]string, error) {
+	ws := memdb.NewWatchSet()
+	iter, err := snap.Nodes(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+OUTER:
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		node := raw.(*models.Node)
+		if node.Status != models.NodeStatusDown || node.ModifyIndex > cutoff {
+			continue
+		}
+
+		allocs, err := snap.AllocsByNode(ws, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, alloc := range allocs {
+			if !alloc.TerminalStatus() {
+				continue OUTER
+			}
+		}
+		result = append(result, node.ID)
+	}
+	return result, nil
+}
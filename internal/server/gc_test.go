@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+const (
+	uuidE1 = "e1000000-0000-0000-0000-000000000000"
+	uuidE2 = "e2000000-0000-0000-0000-000000000000"
+	uuidE3 = "e3000000-0000-0000-0000-000000000000"
+	uuidA1 = "a1000000-0000-0000-0000-000000000000"
+	uuidA2 = "a2000000-0000-0000-0000-000000000000"
+	uuidA3 = "a3000000-0000-0000-0000-000000000000"
+	uuidN1 = "11000000-0000-0000-0000-000000000000"
+	uuidN2 = "12000000-0000-0000-0000-000000000000"
+)
+
+func TestGarbageCollectCandidates(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "done" has terminated, "running" has a running allocation and a
+	// terminal evaluation of an earlier placement.
+	for i, id := range []string{"done", "running"} {
+		if err := state.UpsertJob(uint64(10+i), &models.Job{ID: id, Name: id, Type: models.JobTypeSync}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evals := []*models.Evaluation{
+		{ID: uuidE1, JobID: "done", Status: models.EvalStatusComplete},
+		{ID: uuidE2, JobID: "running", Status: models.EvalStatusComplete},
+		{ID: uuidE3, JobID: "running", Status: models.EvalStatusComplete},
+	}
+	if err := state.UpsertEvals(20, evals); err != nil {
+		t.Fatal(err)
+	}
+	allocs := []*models.Allocation{
+		{ID: uuidA1, JobID: "done", EvalID: uuidE1, NodeID: uuidN1,
+			DesiredStatus: models.AllocDesiredStatusStop, ClientStatus: models.AllocClientStatusComplete},
+		{ID: uuidA2, JobID: "running", EvalID: uuidE2, NodeID: uuidN1,
+			DesiredStatus: models.AllocDesiredStatusStop, ClientStatus: models.AllocClientStatusComplete},
+		{ID: uuidA3, JobID: "running", EvalID: uuidE3, NodeID: uuidN2,
+			DesiredStatus: models.AllocDesiredStatusRun, ClientStatus: models.AllocClientStatusRunning},
+	}
+	if err := state.UpsertAllocs(30, allocs); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{uuidN1, uuidN2} {
+		if err := state.UpsertNode(uint64(40+i), &models.Node{ID: id, Status: models.NodeStatusDown}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := gcJobs(snap, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(jobs, []string{"done"}) {
+		t.Errorf("gcJobs() = %v", jobs)
+	}
+
+	gotEvals, gotAllocs, err := gcEvals(snap, math.MaxUint64, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotEvals)
+	sort.Strings(gotAllocs)
+	if !reflect.DeepEqual(gotEvals, []string{uuidE1, uuidE2}) || !reflect.DeepEqual(gotAllocs, []string{uuidA1, uuidA2}) {
+		t.Errorf("gcEvals() = %v, %v", gotEvals, gotAllocs)
+	}
+
+	// Nothing is older than the cutoff, except what belongs to collected jobs.
+	gotEvals, gotAllocs, err = gcEvals(snap, 0, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotEvals, []string{uuidE1}) || !reflect.DeepEqual(gotAllocs, []string{uuidA1}) {
+		t.Errorf("gcEvals() with cutoff 0 = %v, %v", gotEvals, gotAllocs)
+	}
+
+	nodes, err := gcNodes(snap, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nodes, []string{uuidN1}) {
+		t.Errorf("gcNodes() = %v", nodes)
+	}
+	if nodes, _ := gcNodes(snap, 39); len(nodes) != 0 {
+		t.Errorf("gcNodes() with cutoff 39 = %v", nodes)
+	}
+}
+
+func TestGarbageCollectCandidates_failedJob(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The allocation of "failed" failed, after which the job is dead.
+	if err := state.UpsertJob(10, &models.Job{ID: "failed", Name: "failed", Type: models.JobTypeSync}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.UpsertEvals(20, []*models.Evaluation{
+		{ID: uuidE1, JobID: "failed", Status: models.EvalStatusComplete},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.UpsertAllocs(30, []*models.Allocation{
+		{ID: uuidA1, JobID: "failed", EvalID: uuidE1, NodeID: uuidN1,
+			DesiredStatus: models.AllocDesiredStatusRun, ClientStatus: models.AllocClientStatusFailed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := gcJobs(snap, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("gcJobs() = %v, the failed job is to be kept", jobs)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// waitingJobsInterval is how often the leader checks whether the jobs waiting
+// for other jobs, or for the quota of their namespace, can be scheduled.
+const waitingJobsInterval = 10 * time.Second
+
+// validateJobDependencies checks that the jobs the job depends on exist, and
+// that the job does not depend on itself through them.
+func validateJobDependencies(snap *store.StateSnapshot, job *models.Job) error {
+	ws := memdb.NewWatchSet()
+	visited := make(map[string]bool)
+	queue := append([]string{}, job.DependsOn...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == job.ID {
+			return fmt.Errorf("job %v depends on itself", job.ID)
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		dep, err := snap.JobByID(ws, id)
+		if err != nil {
+			return err
+		}
+		if dep == nil {
+			return fmt.Errorf("job %v depends on job %v, which does not exist", job.ID, id)
+		}
+		queue = append(queue, dep.DependsOn...)
+	}
+	return nil
+}
+
+// jobDependenciesMet returns whether the full copy of every job the job depends
+// on has completed.
+func jobDependenciesMet(snap *store.StateSnapshot, job *models.Job) (bool, error) {
+	ws := memdb.NewWatchSet()
+	for _, id := range job.DependsOn {
+		dep, err := snap.JobByID(ws, id)
+		if err != nil {
+			return false, err
+		}
+		if dep == nil || !dep.FullCopyComplete {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// periodicScheduleWaitingJobs creates the evaluations of the jobs waiting for
+// other jobs, once their full copy has completed, and of the jobs queued by the
+// quota of their namespace, once it leaves room for them. It is run by the leader.
+func (s *Server) periodicScheduleWaitingJobs(stopCh chan struct{}) {
+	ticker := time.NewTicker(waitingJobsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := s.scheduleWaitingJobs(); err != nil {
+				s.logger.Errorf("server.job: Scheduling waiting jobs failed: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Server) scheduleWaitingJobs() error {
+	snap, err := s.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	ws := memdb.NewWatchSet()
+	iter, err := snap.Jobs(ws)
+	if err != nil {
+		return err
+	}
+
+	var waiting []*models.Job
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		if job.Waiting && job.Status != models.JobStatusPause {
+			waiting = append(waiting, job)
+		}
+	}
+	// The jobs of higher priority, then the older ones, take the room first.
+	sort.SliceStable(waiting, func(i, j int) bool {
+		if waiting[i].Priority != waiting[j].Priority {
+			return waiting[i].Priority > waiting[j].Priority
+		}
+		return waiting[i].CreateIndex < waiting[j].CreateIndex
+	})
+
+	var evals []*models.Evaluation
+	scheduled := make(map[string]models.QuotaUsage)
+	for _, job := range waiting {
+		met, err := jobDependenciesMet(snap, job)
+		if err != nil {
+			return err
+		}
+		if !met {
+			continue
+		}
+		namespace := jobNamespace(job)
+		if err := s.checkNamespaceQuota(snap, job, scheduled[namespace]); err != nil {
+			if _, ok := err.(*quotaExceededError); !ok {
+				s.logger.Warnf("server.job: Job %v can not be scheduled: %v", job.ID, err)
+			}
+			continue
+		}
+		usage, err := jobQuotaUsage(job)
+		if err != nil {
+			return err
+		}
+		scheduled[namespace] = scheduled[namespace].Add(usage)
+
+		s.logger.Infof("server.job: Scheduling waiting job %v", job.ID)
+		evals = append(evals, &models.Evaluation{
+			ID:             models.GenerateUUID(),
+			Priority:       job.Priority,
+			Type:           job.Type,
+			TriggeredBy:    models.EvalTriggerJobRegister,
+			JobID:          job.ID,
+			JobModifyIndex: job.JobModifyIndex,
+			Status:         models.EvalStatusPending,
+		})
+	}
+	if len(evals) == 0 {
+		return nil
+	}
+
+	update := &models.EvalUpdateRequest{
+		Evals:        evals,
+		WriteRequest: models.WriteRequest{Region: s.config.Region},
+	}
+	_, _, err = s.raftApply(models.EvalUpdateRequestType, update)
+	return err
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func TestJobDependencies(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := []*models.Job{
+		{ID: "schema", Name: "schema", Type: models.JobTypeSync},
+		{ID: "data", Name: "data", Type: models.JobTypeSync, DependsOn: []string{"schema"}},
+	}
+	for i, job := range jobs {
+		if err := state.UpsertJob(uint64(10+i), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alloc := &models.Allocation{ID: uuidA1, JobID: "schema", EvalID: uuidE1, NodeID: uuidN1, Task: models.TaskTypeDest,
+		DesiredStatus: models.AllocDesiredStatusRun, ClientStatus: models.AllocClientStatusRunning,
+		TaskStates: map[string]*models.TaskState{models.TaskTypeDest: {
+			State:  models.TaskStateRunning,
+			Events: []*models.TaskEvent{models.NewTaskEvent(models.TaskStarted)},
+		}},
+	}
+	if err := state.UpsertAllocs(20, []*models.Allocation{alloc}); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := &models.Job{ID: "verify", DependsOn: []string{"data"}}
+	if err := validateJobDependencies(snap, verify); err != nil {
+		t.Errorf("validateJobDependencies() = %v", err)
+	}
+	if err := validateJobDependencies(snap, &models.Job{ID: "x", DependsOn: []string{"missing"}}); err == nil {
+		t.Errorf("expect an error for a missing job")
+	}
+	// schema would depend on itself through data.
+	if err := validateJobDependencies(snap, &models.Job{ID: "schema", DependsOn: []string{"data"}}); err == nil {
+		t.Errorf("expect an error for a cycle")
+	}
+
+	if met, err := jobDependenciesMet(snap, jobs[1]); err != nil || met {
+		t.Errorf("jobDependenciesMet() before the full copy = %v, %v", met, err)
+	}
+
+	alloc = alloc.Copy()
+	taskState := alloc.TaskStates[models.TaskTypeDest]
+	taskState.Events = append(taskState.Events, models.NewTaskEvent(models.TaskFullCopyComplete))
+	if err := state.UpdateAllocsFromClient(30, []*models.Allocation{alloc}); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = state.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if met, err := jobDependenciesMet(snap, jobs[1]); err != nil || !met {
+		t.Errorf("jobDependenciesMet() after the full copy = %v, %v", met, err)
+	}
+
+	// The event leaves the ring of the last 10 events of the task.
+	alloc = alloc.Copy()
+	taskState = alloc.TaskStates[models.TaskTypeDest]
+	taskState.Events = nil
+	for i := 0; i < 11; i++ {
+		taskState.Events = append(taskState.Events, models.NewTaskEvent(models.TaskStarted))
+	}
+	if err := state.UpdateAllocsFromClient(40, []*models.Allocation{alloc}); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = state.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if met, err := jobDependenciesMet(snap, jobs[1]); err != nil || !met {
+		t.Errorf("jobDependenciesMet() after more than 10 events = %v, %v", met, err)
+	}
+
+	// The job stopped and registered again with another spec copies its data
+	// again.
+	alloc = alloc.Copy()
+	alloc.ClientStatus = models.AllocClientStatusComplete
+	if err := state.UpdateAllocsFromClient(45, []*models.Allocation{alloc}); err != nil {
+		t.Fatal(err)
+	}
+	schema := jobs[0].Copy()
+	schema.Priority = 80
+	if err := state.UpsertJob(50, schema); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = state.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if met, err := jobDependenciesMet(snap, jobs[1]); err != nil || met {
+		t.Errorf("jobDependenciesMet() after the spec changed = %v, %v", met, err)
+	}
+}
+
+func TestJobWaiting(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &models.Job{ID: "data", Name: "data", Type: models.JobTypeSync, DependsOn: []string{"schema"}, Waiting: true}
+	if err := state.UpsertJob(10, job); err != nil {
+		t.Fatal(err)
+	}
+	// An old eval of the job, e.g. of a previous registration, doesn't end
+	// the wait.
+	old := &models.Evaluation{ID: uuidE1, JobID: "data", JobModifyIndex: 5, Status: models.EvalStatusComplete}
+	if err := state.UpsertEvals(20, []*models.Evaluation{old}); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := state.JobByID(nil, "data"); !job.Waiting {
+		t.Errorf("job not waiting after an old eval")
+	}
+	eval := &models.Evaluation{ID: uuidE2, JobID: "data", JobModifyIndex: 10, Status: models.EvalStatusPending}
+	if err := state.UpsertEvals(30, []*models.Evaluation{eval}); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := state.JobByID(nil, "data"); job.Waiting {
+		t.Errorf("job still waiting after evaluated")
+	}
+}
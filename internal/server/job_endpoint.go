@@ -33,6 +33,21 @@ type Job struct {
 	srv *Server
 }
 
+// registerUnchanged returns whether registering the job is a no-op: the
+// existing job is pending or running, with the same spec. A dead or complete
+// job registered again is restarted.
+func registerUnchanged(existing, job *models.Job) bool {
+	if existing == nil {
+		return false
+	}
+	switch existing.Status {
+	case models.JobStatusPending, models.JobStatusRunning:
+		return !existing.SpecChanged(job)
+	default:
+		return false
+	}
+}
+
 // Register is used to upsert a job for scheduling
 func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobResponse) error {
 	if done, err := j.srv.forward("Job.Register", args, args, reply); done {
@@ -49,25 +64,31 @@ func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobRespons
 	// Initialize the job fields (sets defaults and any necessary init work).
 	args.Job.Canonicalize()
 
+	if args.Job.Priority < models.JobMinPriority || args.Job.Priority > models.JobMaxPriority {
+		reply.Success = false
+		return fmt.Errorf("job priority must be between %d and %d", models.JobMinPriority, models.JobMaxPriority)
+	}
+
 	// Validate the job.
 	/*if err := validateJob(args.Job); err != nil {
 		reply.Success = false
 		return err
 	}*/
 
+	// Lookup the job
+	snap, err := j.srv.fsm.State().Snapshot()
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+	ws := memdb.NewWatchSet()
+	job, err := snap.JobByID(ws, args.Job.ID)
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+
 	if args.EnforceIndex {
-		// Lookup the job
-		snap, err := j.srv.fsm.State().Snapshot()
-		if err != nil {
-			reply.Success = false
-			return err
-		}
-		ws := memdb.NewWatchSet()
-		job, err := snap.JobByID(ws, args.Job.ID)
-		if err != nil {
-			reply.Success = false
-			return err
-		}
 		jmi := args.JobModifyIndex
 		if job != nil {
 			if jmi == 0 {
@@ -84,6 +105,60 @@ func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobRespons
 		}
 	}
 
+	// Registering the same spec again is a no-op, so that the job is not
+	// re-evaluated.
+	if registerUnchanged(job, args.Job) {
+		reply.Success = true
+		reply.JobModifyIndex = job.JobModifyIndex
+		reply.Index = job.ModifyIndex
+		return nil
+	}
+
+	if err := validateJobNamespace(snap, args.Job); err != nil {
+		reply.Success = false
+		return err
+	}
+	if err := validateJobConnectionProfiles(snap, args.Job); err != nil {
+		reply.Success = false
+		return err
+	}
+
+	// The job is evaluated after the jobs it depends on.
+	dependenciesMet := true
+	if len(args.Job.DependsOn) > 0 {
+		if err := validateJobDependencies(snap, args.Job); err != nil {
+			reply.Success = false
+			return err
+		}
+		if dependenciesMet, err = jobDependenciesMet(snap, args.Job); err != nil {
+			reply.Success = false
+			return err
+		}
+	}
+
+	// The job is evaluated when the quota of its namespace leaves room for it,
+	// or rejected.
+	quotaMet := true
+	if err := j.srv.checkNamespaceQuota(snap, args.Job, models.QuotaUsage{}); err != nil {
+		if _, ok := err.(*quotaExceededError); !ok {
+			reply.Success = false
+			return err
+		}
+		queue, qErr := j.srv.queueOnQuotaExceeded(snap, args.Job)
+		if qErr != nil {
+			reply.Success = false
+			return qErr
+		}
+		if !queue {
+			reply.Success = false
+			return err
+		}
+		j.srv.logger.Infof("server.job: Job %v is queued: %v", args.Job.ID, err)
+		quotaMet = false
+	}
+
+	args.Job.Waiting = !dependenciesMet || !quotaMet
+
 	// Commit this update via Raft
 	_, index, err := j.srv.raftApply(models.JobRegisterRequestType, args)
 	if err != nil {
@@ -92,9 +167,20 @@ func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobRespons
 		return err
 	}
 
+	if !dependenciesMet || !quotaMet {
+		if !dependenciesMet {
+			j.srv.logger.Infof("server.job: Job %v waits for the full copy of %v", args.Job.ID, args.Job.DependsOn)
+		}
+		reply.Success = true
+		reply.JobModifyIndex = index
+		reply.Index = index
+		return nil
+	}
+
 	// Create a new evaluation
 	eval := &models.Evaluation{
 		ID:             models.GenerateUUID(),
+		Priority:       args.Job.Priority,
 		Type:           args.Job.Type,
 		TriggeredBy:    models.EvalTriggerJobRegister,
 		JobID:          args.Job.ID,
@@ -118,6 +204,7 @@ func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobRespons
 
 	// Populate the reply with eval information
 	reply.Success = true
+	reply.JobModifyIndex = index
 	reply.Index = evalIndex
 	return nil
 }
@@ -193,6 +280,12 @@ func (j *Job) UpdateStatus(args *models.JobUpdateStatusRequest, reply *models.Jo
 		reply.Success = false
 		return fmt.Errorf("job not found")
 	}
+	if args.Status == models.JobStatusRunning && job.Status == models.JobStatusPause {
+		if err := j.srv.checkNamespaceQuota(snap, job, models.QuotaUsage{}); err != nil {
+			reply.Success = false
+			return err
+		}
+	}
 	// Commit this update via Raft
 	if job.Status != args.Status {
 		_, index, err := j.srv.raftApply(models.JobUpdateStatusRequestType, args)
@@ -210,6 +303,7 @@ func (j *Job) UpdateStatus(args *models.JobUpdateStatusRequest, reply *models.Jo
 		// Create a new evaluation
 		eval := &models.Evaluation{
 			ID:             models.GenerateUUID(),
+			Priority:       job.Priority,
 			Type:           job.Type,
 			TriggeredBy:    triggeredBy,
 			JobID:          args.JobID,
@@ -309,6 +403,7 @@ func (j *Job) Evaluate(args *models.JobEvaluateRequest, reply *models.JobRespons
 	// Create a new evaluation
 	eval := &models.Evaluation{
 		ID:             models.GenerateUUID(),
+		Priority:       job.Priority,
 		Type:           job.Type,
 		TriggeredBy:    models.EvalTriggerJobRegister,
 		JobID:          job.ID,
@@ -361,6 +456,7 @@ func (j *Job) Deregister(args *models.JobDeregisterRequest, reply *models.JobRes
 	// since all should be able to handle deregistration in the same way.
 	eval := &models.Evaluation{
 		ID:             models.GenerateUUID(),
+		Priority:       models.JobDefaultPriority,
 		Type:           models.JobTypeSync,
 		TriggeredBy:    models.EvalTriggerJobDeregister,
 		JobID:          args.JobID,
@@ -616,6 +712,7 @@ func (j *Job) Plan(args *models.JobPlanRequest, reply *models.JobPlanResponse) e
 	// Create an eval and mark it as requiring annotations and insert that as well
 	eval := &models.Evaluation{
 		ID:             models.GenerateUUID(),
+		Priority:       args.Job.Priority,
 		Type:           args.Job.Type,
 		TriggeredBy:    models.EvalTriggerJobRegister,
 		JobID:          args.Job.ID,
@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// validateJobNamespace checks the namespace of the job, and that no other job of
+// the namespace has the same name.
+func validateJobNamespace(snap *store.StateSnapshot, job *models.Job) error {
+	if !models.ValidNamespace(job.Namespace) {
+		return fmt.Errorf("invalid job namespace %q", job.Namespace)
+	}
+	if job.Name == "" {
+		return nil
+	}
+
+	ws := memdb.NewWatchSet()
+	iter, err := snap.Jobs(ws)
+	if err != nil {
+		return err
+	}
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		other := raw.(*models.Job)
+		namespace := other.Namespace
+		if namespace == "" {
+			// Registered before the namespaces.
+			namespace = models.DefaultNamespace
+		}
+		if other.ID != job.ID && namespace == job.Namespace && other.Name == job.Name {
+			return fmt.Errorf("job name %q is already used by job %v in namespace %q",
+				job.Name, other.ID, job.Namespace)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func TestValidateJobNamespace(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []*models.Job{
+		{ID: "a1", Name: "orders", Namespace: "team-a", Type: models.JobTypeSync},
+		{ID: "old", Name: "legacy", Type: models.JobTypeSync},
+	}
+	for i, job := range jobs {
+		if err := state.UpsertJob(uint64(10+i), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		job   *models.Job
+		valid bool
+	}{
+		{&models.Job{ID: "a1", Name: "orders", Namespace: "team-a"}, true},
+		{&models.Job{ID: "b1", Name: "orders", Namespace: "team-b"}, true},
+		{&models.Job{ID: "a2", Name: "orders", Namespace: "team-a"}, false},
+		{&models.Job{ID: "new", Name: "legacy", Namespace: models.DefaultNamespace}, false},
+		{&models.Job{ID: "x", Name: "x", Namespace: "team a"}, false},
+	}
+	for _, c := range cases {
+		err := validateJobNamespace(snap, c.job)
+		if c.valid && err != nil {
+			t.Errorf("validateJobNamespace(%v) = %v", c.job.ID, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("validateJobNamespace(%v): expect an error", c.job.ID)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+
+	memdb "github.com/hashicorp/go-memdb"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// quotaExceededError is returned when running a job would exceed the quota of
+// its namespace.
+type quotaExceededError struct {
+	namespace string
+	err       error
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("quota of namespace %q exceeded: %v", e.namespace, e.err)
+}
+
+// jobQuotaUsage returns the resources of the job counted against a quota: the
+// ParallelWorkers of the Dest tasks and the BandwidthLimitMBps of the Src tasks.
+func jobQuotaUsage(job *models.Job) (models.QuotaUsage, error) {
+	usage := models.QuotaUsage{Jobs: 1}
+	for _, task := range job.Tasks {
+		if task.Driver != models.TaskDriverMySQL {
+			if task.Type == models.TaskTypeDest {
+				usage.DumpWorkers++
+			}
+			continue
+		}
+		var driverConfig config.MySQLDriverConfig
+		if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+			return usage, err
+		}
+		switch task.Type {
+		case models.TaskTypeSrc:
+			usage.BandwidthMBps += driverConfig.BandwidthLimitMBps
+		case models.TaskTypeDest:
+			usage.DumpWorkers += driverConfig.SetDefault().ParallelWorkers
+		}
+	}
+	return usage, nil
+}
+
+// jobNamespace returns the namespace of the job, including the jobs registered
+// before the namespaces.
+func jobNamespace(job *models.Job) string {
+	if job.Namespace == "" {
+		return models.DefaultNamespace
+	}
+	return job.Namespace
+}
+
+// jobActive returns whether the job is counted against the quota of its
+// namespace: it is running, or about to. The jobs waiting to be evaluated, paused
+// or terminated are not.
+func jobActive(snap *store.StateSnapshot, job *models.Job) (bool, error) {
+	switch job.Status {
+	case models.JobStatusRunning:
+		return true, nil
+	case models.JobStatusPending:
+		evals, err := snap.EvalsByJob(memdb.NewWatchSet(), job.ID)
+		if err != nil {
+			return false, err
+		}
+		return len(evals) > 0, nil
+	default:
+		return false, nil
+	}
+}
+
+// namespaceQuotaUsage returns the resources used by the active jobs of the
+// namespace, other than the job excluded.
+func namespaceQuotaUsage(snap *store.StateSnapshot, namespace, excludeID string) (models.QuotaUsage, error) {
+	var usage models.QuotaUsage
+	iter, err := snap.Jobs(memdb.NewWatchSet())
+	if err != nil {
+		return usage, err
+	}
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		if job.ID == excludeID || jobNamespace(job) != namespace {
+			continue
+		}
+		active, err := jobActive(snap, job)
+		if err != nil {
+			return usage, err
+		}
+		if !active {
+			continue
+		}
+		jobUsage, err := jobQuotaUsage(job)
+		if err != nil {
+			return usage, fmt.Errorf("job %v: %v", job.ID, err)
+		}
+		usage = usage.Add(jobUsage)
+	}
+	return usage, nil
+}
+
+// checkNamespaceQuota returns a quotaExceededError if running the job, besides
+// the active jobs of its namespace and the usage scheduled, would exceed the
+// quota of the namespace.
+func (s *Server) checkNamespaceQuota(snap *store.StateSnapshot, job *models.Job,
+	scheduled models.QuotaUsage) error {
+	namespace := jobNamespace(job)
+	quota := s.config.NamespaceQuotas[namespace]
+	if quota == nil {
+		return nil
+	}
+
+	jobUsage, err := jobQuotaUsage(job)
+	if err != nil {
+		return err
+	}
+	if quota.MaxBandwidthMBps > 0 {
+		for _, task := range job.Tasks {
+			if task.Type != models.TaskTypeSrc || task.Driver != models.TaskDriverMySQL {
+				continue
+			}
+			var driverConfig config.MySQLDriverConfig
+			if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+				return err
+			}
+			if driverConfig.BandwidthLimitMBps <= 0 {
+				return fmt.Errorf("namespace %q has a bandwidth quota, BandwidthLimitMBps of the Src task must be set",
+					namespace)
+			}
+		}
+	}
+
+	usage, err := namespaceQuotaUsage(snap, namespace, job.ID)
+	if err != nil {
+		return err
+	}
+	if err := quota.Exceeded(usage.Add(scheduled).Add(jobUsage)); err != nil {
+		return &quotaExceededError{namespace: namespace, err: err}
+	}
+	return nil
+}
+
+// queueOnQuotaExceeded returns whether the job, exceeding the quota of its
+// namespace, waits to be scheduled instead of being rejected. A job already
+// active is never queued.
+func (s *Server) queueOnQuotaExceeded(snap *store.StateSnapshot, job *models.Job) (bool, error) {
+	quota := s.config.NamespaceQuotas[jobNamespace(job)]
+	if quota == nil || !quota.Queue {
+		return false, nil
+	}
+	existing, err := snap.JobByID(memdb.NewWatchSet(), job.ID)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return true, nil
+	}
+	active, err := jobActive(snap, existing)
+	return !active, err
+}
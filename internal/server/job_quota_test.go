@@ -0,0 +1,102 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func quotaTestJob(id, namespace string, workers, bandwidth int) *models.Job {
+	return &models.Job{ID: id, Name: id, Namespace: namespace, Type: models.JobTypeSync,
+		Tasks: []*models.Task{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{"BandwidthLimitMBps": bandwidth},
+		}, {
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{"ParallelWorkers": workers},
+		}},
+	}
+}
+
+func TestCheckNamespaceQuota(t *testing.T) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := quotaTestJob("a1", "team-a", 4, 10)
+	paused := quotaTestJob("a0", "team-a", 4, 10)
+	paused.Status = models.JobStatusPause
+	for i, job := range []*models.Job{running, paused} {
+		if err := state.UpsertJob(uint64(10+i), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eval := &models.Evaluation{ID: uuidE1, JobID: "a1", Status: models.EvalStatusPending}
+	if err := state.UpsertEvals(20, []*models.Evaluation{eval}); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := state.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quota := &models.NamespaceQuota{MaxRunningJobs: 1, MaxDumpWorkers: 10, Queue: true}
+	s := &Server{config: &uconf.ServerConfig{NamespaceQuotas: map[string]*models.NamespaceQuota{
+		"team-a": quota,
+		"team-b": {MaxBandwidthMBps: 100},
+	}}}
+
+	usage, err := namespaceQuotaUsage(snap, "team-a", "")
+	if err != nil || usage != (models.QuotaUsage{Jobs: 1, DumpWorkers: 4, BandwidthMBps: 10}) {
+		t.Errorf("namespaceQuotaUsage() = %+v, %v", usage, err)
+	}
+
+	// Re-registering the running job does not count it twice.
+	if err := s.checkNamespaceQuota(snap, running, models.QuotaUsage{}); err != nil {
+		t.Errorf("checkNamespaceQuota(a1) = %v", err)
+	}
+	a2 := quotaTestJob("a2", "team-a", 4, 10)
+	if _, ok := s.checkNamespaceQuota(snap, a2, models.QuotaUsage{}).(*quotaExceededError); !ok {
+		t.Errorf("expect the running jobs exceeded")
+	}
+	if queue, err := s.queueOnQuotaExceeded(snap, a2); err != nil || !queue {
+		t.Errorf("queueOnQuotaExceeded(a2) = %v, %v", queue, err)
+	}
+	if queue, err := s.queueOnQuotaExceeded(snap, running); err != nil || queue {
+		t.Errorf("queueOnQuotaExceeded(a1) = %v, %v", queue, err)
+	}
+
+	quota.MaxRunningJobs = 3
+	if err := s.checkNamespaceQuota(snap, a2, models.QuotaUsage{}); err != nil {
+		t.Errorf("checkNamespaceQuota(a2) = %v", err)
+	}
+	// A job scheduled in the same pass takes the room.
+	scheduled := models.QuotaUsage{Jobs: 1, DumpWorkers: 4}
+	if _, ok := s.checkNamespaceQuota(snap, a2, scheduled).(*quotaExceededError); !ok {
+		t.Errorf("expect the dump workers exceeded")
+	}
+
+	// No quota for the other namespaces.
+	if err := s.checkNamespaceQuota(snap, quotaTestJob("c1", "team-c", 100, 0), models.QuotaUsage{}); err != nil {
+		t.Errorf("checkNamespaceQuota(c1) = %v", err)
+	}
+	// A bandwidth quota requires the bandwidth limit.
+	err = s.checkNamespaceQuota(snap, quotaTestJob("b1", "team-b", 1, 0), models.QuotaUsage{})
+	if _, ok := err.(*quotaExceededError); err == nil || ok {
+		t.Errorf("expect an error for the missing BandwidthLimitMBps, got %v", err)
+	}
+	if _, ok := s.checkNamespaceQuota(snap, quotaTestJob("b1", "team-b", 1, 200), models.QuotaUsage{}).(*quotaExceededError); !ok {
+		t.Errorf("expect the bandwidth exceeded")
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestRegisterUnchanged(t *testing.T) {
+	newJob := func(status, gtid string) *models.Job {
+		src := models.NewTask()
+		src.Type = models.TaskTypeSrc
+		src.Config = map[string]interface{}{"ReplicateDoDb": "db1"}
+		if gtid != "" {
+			src.Config["Gtid"] = gtid
+		}
+		return &models.Job{ID: "job1", Name: "job1", Status: status, Tasks: []*models.Task{src}}
+	}
+	const gtid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10"
+	const gtid2 = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-20"
+
+	tests := []struct {
+		name     string
+		existing *models.Job
+		job      *models.Job
+		want     bool
+	}{
+		{"new job", nil, newJob("", ""), false},
+		{"running, same spec", newJob(models.JobStatusRunning, gtid1), newJob("", ""), true},
+		{"pending, same spec", newJob(models.JobStatusPending, ""), newJob("", ""), true},
+		{"dead, same spec", newJob(models.JobStatusDead, ""), newJob("", ""), false},
+		{"complete, same spec", newJob(models.JobStatusComplete, ""), newJob("", ""), false},
+		{"same gtid", newJob(models.JobStatusRunning, gtid1), newJob("", gtid1), true},
+		{"gtid changed", newJob(models.JobStatusRunning, gtid1), newJob("", gtid2), false},
+		{"gtid set", newJob(models.JobStatusPending, ""), newJob("", gtid1), false},
+	}
+	for _, tt := range tests {
+		if got := registerUnchanged(tt.existing, tt.job); got != tt.want {
+			t.Errorf("%v: registerUnchanged() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
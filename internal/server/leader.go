@@ -149,6 +149,19 @@ func (s *Server) establishLeadership(stopCh chan struct{}) error {
 	// Periodically unblock failed allocations
 	go s.periodicUnblockFailedEvals(stopCh)
 
+	// Periodically garbage collect the terminal objects
+	go s.periodicGC(stopCh)
+
+	// Periodically schedule the jobs waiting for other jobs or for their quota
+	go s.periodicScheduleWaitingJobs(stopCh)
+
+	// Create the root of the CA issuing the certificates of the RPC, if there
+	// is none yet
+	if err := s.initializeCA(); err != nil {
+		s.logger.Errorf("manager: CA setup failed: %v", err)
+		return err
+	}
+
 	// Setup the heartbeat timers. This is done both when starting up or when
 	// a leader fail over happens. Since the timers are maintained by the leader
 	// node, effectively this means all the timers are renewed at the time of failover.
@@ -274,6 +274,11 @@ func (n *Node) UpdateStatus(args *models.NodeUpdateStatusRequest, reply *models.
 	// Check if we need to setup a heartbeat
 	switch args.Status {
 	case models.NodeStatusDown:
+		// The node has left gracefully, or its heartbeat has expired.
+		if err := n.srv.clearHeartbeatTimer(args.NodeID); err != nil {
+			n.srv.logger.Errorf("server.agent: heartbeat clear failed: %v", err)
+			return err
+		}
 
 	default:
 		ttl, err := n.srv.resetHeartbeatTimer(args.NodeID)
@@ -694,6 +699,7 @@ func (n *Node) createNodeEvals(nodeID string, nodeIndex uint64) ([]string, uint6
 		// Create a new eval
 		eval := &models.Evaluation{
 			ID:              models.GenerateUUID(),
+			Priority:        alloc.Job.Priority,
 			Type:            alloc.Job.Type,
 			TriggeredBy:     models.EvalTriggerNodeUpdate,
 			JobID:           alloc.JobID,
@@ -716,6 +722,7 @@ func (n *Node) createNodeEvals(nodeID string, nodeIndex uint64) ([]string, uint6
 		// Create a new eval
 		eval := &models.Evaluation{
 			ID:              models.GenerateUUID(),
+			Priority:        job.Priority,
 			Type:            job.Type,
 			TriggeredBy:     models.EvalTriggerNodeUpdate,
 			JobID:           job.ID,
@@ -7,6 +7,7 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 
@@ -160,3 +161,68 @@ REMOVE:
 	op.srv.logger.Printf("[WARN] udup.operator: Removed Raft peer with id %q", args.ID)
 	return nil
 }
+
+// SnapshotSave is used to take a snapshot of the server state, including the
+// jobs, allocations and their replication positions, as an archive to be
+// restored by SnapshotRestore.
+func (op *Operator) SnapshotSave(args *models.GenericRequest, reply *models.SnapshotResponse) error {
+	if done, err := op.srv.forward("Operator.SnapshotSave", args, args, reply); done {
+		return err
+	}
+
+	future := op.srv.raft.Snapshot()
+	if err := future.Error(); err != nil {
+		return err
+	}
+	meta, state, err := future.Open()
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	var buf bytes.Buffer
+	if err := writeSnapshotArchive(&buf, meta, state); err != nil {
+		return err
+	}
+	reply.Snapshot = buf.Bytes()
+	reply.Index = meta.Index
+	return nil
+}
+
+// SnapshotRestore is used to replace the server state with an archive saved by
+// SnapshotSave, e.g. to recover into a new cluster. The Raft configuration of
+// the cluster is kept.
+func (op *Operator) SnapshotRestore(args *models.SnapshotRestoreRequest, reply *models.GenericResponse) error {
+	if done, err := op.srv.forward("Operator.SnapshotRestore", args, args, reply); done {
+		return err
+	}
+
+	meta, state, err := readSnapshotArchive(bytes.NewReader(args.Snapshot))
+	if err != nil {
+		return err
+	}
+	if err := op.srv.raft.Restore(meta, bytes.NewReader(state), 0); err != nil {
+		op.srv.logger.Errorf("server.operator: Failed to restore snapshot: %v", err)
+		return err
+	}
+
+	// The eval broker, the blocked evals and the heartbeat timers of the
+	// leader still track the replaced state.
+	op.srv.evalBroker.SetEnabled(false)
+	op.srv.evalBroker.SetEnabled(true)
+	op.srv.blockedEvals.SetEnabled(false)
+	op.srv.blockedEvals.SetEnabled(true)
+	if err := op.srv.restoreEvals(); err != nil {
+		return err
+	}
+	if err := op.srv.clearAllHeartbeatTimers(); err != nil {
+		return err
+	}
+	if err := op.srv.initializeHeartbeatTimers(); err != nil {
+		return err
+	}
+
+	reply.Index = op.srv.raft.LastIndex()
+	op.srv.logger.Warnf("server.operator: Restored snapshot taken at index %v", meta.Index)
+	return nil
+}
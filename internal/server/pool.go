@@ -8,6 +8,7 @@ package server
 
 import (
 	"container/list"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -18,6 +19,8 @@ import (
 
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 	"github.com/hashicorp/yamux"
+
+	"github.com/actiontech/dtle/internal/tlsutil"
 )
 
 // streamClient is used to wrap a stream with an RPC client
@@ -132,6 +135,9 @@ type ConnPool struct {
 	// on to close.
 	limiter map[string]chan struct{}
 
+	// tlsConfigurator dials the servers with TLS once it has a certificate
+	tlsConfigurator *tlsutil.Configurator
+
 	// Used to indicate the pool is shutdown
 	shutdown   bool
 	shutdownCh chan struct{}
@@ -156,6 +162,26 @@ func NewPool(logOutput io.Writer, maxTime time.Duration, maxStreams int) *ConnPo
 	return pool
 }
 
+// SetTLSConfigurator makes the new connections use TLS, with the certificate of
+// the configurator, once it has one.
+func (p *ConnPool) SetTLSConfigurator(c *tlsutil.Configurator) {
+	p.Lock()
+	defer p.Unlock()
+	p.tlsConfigurator = c
+}
+
+// Reset closes the pooled connections, for the next RPC to dial again, e.g.
+// with TLS once the agent got a certificate.
+func (p *ConnPool) Reset() {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, conn := range p.pool {
+		conn.Close()
+	}
+	p.pool = make(map[string]*Conn)
+}
+
 // Shutdown is used to close the connection pool
 func (p *ConnPool) Shutdown() error {
 	p.Lock()
@@ -251,6 +277,17 @@ func (p *ConnPool) getNewConn(region string, addr net.Addr) (*Conn, error) {
 		tcp.SetNoDelay(true)
 	}
 
+	// Wrap the connection with TLS if we have a certificate
+	p.Lock()
+	configurator := p.tlsConfigurator
+	p.Unlock()
+	if configurator != nil && configurator.Ready() {
+		conn, err = dialTLS(conn, configurator.OutgoingConfig())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Write the multiplex byte to set the mode
 	if _, err := conn.Write([]byte{byte(rpcMultiplex)}); err != nil {
 		conn.Close()
@@ -280,6 +317,44 @@ func (p *ConnPool) getNewConn(region string, addr net.Addr) (*Conn, error) {
 	return c, nil
 }
 
+// dialTLS switches the connection to TLS and completes the handshake.
+func dialTLS(conn net.Conn, config *tls.Config) (net.Conn, error) {
+	if _, err := conn.Write([]byte{byte(rpcTLS)}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %v", err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// BootstrapRPC makes an RPC on a new TLS connection, with the configuration
+// given instead of the certificate of the pool. It is used by the agents to
+// get a certificate.
+func (p *ConnPool) BootstrapRPC(addr net.Addr, config *tls.Config, method string, args interface{}, reply interface{}) error {
+	conn, err := net.DialTimeout("tcp", addr.String(), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	conn, err = dialTLS(conn, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{byte(rpcUdup)}); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	codec := NewClientCodec(conn)
+	return msgpackrpc.CallWithCodec(codec, method, args, reply)
+}
+
 // clearConn is used to clear any cached connection, potentially in response to an erro
 func (p *ConnPool) clearConn(conn *Conn) {
 	// Ensure returned streams are closed
@@ -7,6 +7,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
@@ -32,6 +33,7 @@ const (
 	rpcUdup      RPCType = 0x01
 	rpcRaft              = 0x02
 	rpcMultiplex         = 0x03
+	rpcTLS               = 0x04
 )
 
 const (
@@ -86,7 +88,16 @@ func (s *Server) listen() {
 			continue
 		}
 
-		go s.handleConn(conn)
+		limited, err := s.rpcConnLimiter.Accept(conn)
+		if err != nil {
+			s.logger.Warnf("server.rpc: rejected RPC conn from %v: %v (see rpc_max_conns_per_client)",
+				conn.RemoteAddr(), err)
+			metrics.IncrCounter([]string{"server", "rpc", "rejected_conns"}, 1)
+			conn.Close()
+			continue
+		}
+
+		go s.handleConn(limited)
 		metrics.IncrCounter([]string{"server", "rpc", "accept_conn"}, 1)
 	}
 }
@@ -94,6 +105,12 @@ func (s *Server) listen() {
 // handleConn is used to determine if this is a Raft or
 // Udup type RPC connection and invoke the correct handler
 func (s *Server) handleConn(conn net.Conn) {
+	// A connection sending nothing is closed after the handshake timeout. It
+	// covers the TLS handshake too.
+	if s.config.RPCHandshakeTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.config.RPCHandshakeTimeout))
+	}
+
 	// Read a single byte
 	buf := make([]byte, 1)
 	if _, err := conn.Read(buf); err != nil {
@@ -103,10 +120,16 @@ func (s *Server) handleConn(conn net.Conn) {
 		conn.Close()
 		return
 	}
+	if RPCType(buf[0]) != rpcTLS {
+		conn.SetReadDeadline(time.Time{})
+	}
 
 	// Switch on the byte
 	switch RPCType(buf[0]) {
 	case rpcUdup:
+		if s.rejectPlaintext(conn) {
+			return
+		}
 		s.handleUdupConn(conn)
 
 	case rpcRaft:
@@ -114,8 +137,14 @@ func (s *Server) handleConn(conn net.Conn) {
 		s.raftLayer.Handoff(conn)
 
 	case rpcMultiplex:
+		if s.rejectPlaintext(conn) {
+			return
+		}
 		s.handleMultiplex(conn)
 
+	case rpcTLS:
+		s.handleTLSConn(conn)
+
 	default:
 		s.logger.Errorf("server.rpc: unrecognized RPC byte: %v", buf[0])
 		conn.Close()
@@ -123,6 +152,66 @@ func (s *Server) handleConn(conn net.Conn) {
 	}
 }
 
+// rejectPlaintext closes the RPC connections without TLS when the incoming
+// connections are verified. Raft is not affected.
+func (s *Server) rejectPlaintext(conn net.Conn) bool {
+	if s.tlsConfigurator == nil || !s.config.TLSConfig.VerifyIncoming {
+		return false
+	}
+	s.logger.Warnf("server.rpc: rejected RPC conn without TLS from %v", conn.RemoteAddr())
+	metrics.IncrCounter([]string{"server", "rpc", "tls_rejected"}, 1)
+	conn.Close()
+	return true
+}
+
+// handleTLSConn completes the TLS handshake, and serves the connection. The
+// peers without a certificate are only allowed to get one.
+func (s *Server) handleTLSConn(conn net.Conn) {
+	if s.tlsConfigurator == nil || !s.tlsConfigurator.Ready() {
+		s.logger.Warnf("server.rpc: TLS conn from %v while no certificate", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(conn, s.tlsConfigurator.IncomingConfig())
+	if s.config.RPCHandshakeTimeout > 0 {
+		tlsConn.SetWriteDeadline(time.Now().Add(s.config.RPCHandshakeTimeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		s.logger.Errorf("server.rpc: TLS handshake with %v failed: %v", conn.RemoteAddr(), err)
+		metrics.IncrCounter([]string{"server", "rpc", "tls_rejected"}, 1)
+		conn.Close()
+		return
+	}
+	tlsConn.SetWriteDeadline(time.Time{})
+
+	buf := make([]byte, 1)
+	if _, err := tlsConn.Read(buf); err != nil {
+		if err != io.EOF {
+			s.logger.Errorf("server.rpc: failed to read byte: %v", err)
+		}
+		tlsConn.Close()
+		return
+	}
+	tlsConn.SetReadDeadline(time.Time{})
+
+	verified := len(tlsConn.ConnectionState().PeerCertificates) > 0
+	switch {
+	case RPCType(buf[0]) == rpcUdup && !verified:
+		s.serveUdupConn(tlsConn, s.bootstrapRPCServer)
+
+	case RPCType(buf[0]) == rpcUdup:
+		s.handleUdupConn(tlsConn)
+
+	case RPCType(buf[0]) == rpcMultiplex && verified:
+		s.handleMultiplex(tlsConn)
+
+	default:
+		s.logger.Errorf("server.rpc: unrecognized RPC byte over TLS: %v (certificate %v)", buf[0], verified)
+		tlsConn.Close()
+	}
+}
+
 // handleMultiplex is used to multiplex a single incoming connection
 // using the Yamux multiplexer
 func (s *Server) handleMultiplex(conn net.Conn) {
@@ -144,6 +233,12 @@ func (s *Server) handleMultiplex(conn net.Conn) {
 
 // handleUdupConn is used to service a single Udup RPC connection
 func (s *Server) handleUdupConn(conn net.Conn) {
+	s.serveUdupConn(conn, s.rpcServer)
+}
+
+// serveUdupConn services a single Udup RPC connection with the endpoints of the
+// RPC server given
+func (s *Server) serveUdupConn(conn net.Conn, rpcServer *rpc.Server) {
 	defer conn.Close()
 	rpcCodec := NewServerCodec(conn)
 	for {
@@ -153,7 +248,7 @@ func (s *Server) handleUdupConn(conn net.Conn) {
 		default:
 		}
 
-		if err := s.rpcServer.ServeRequest(rpcCodec); err != nil {
+		if err := rpcServer.ServeRequest(rpcCodec); err != nil {
 			if err != io.EOF && !strings.Contains(err.Error(), "closed") {
 				s.logger.Errorf("server.rpc: RPC error: %v (%v)", err, conn)
 				metrics.IncrCounter([]string{"server", "rpc", "request_error"}, 1)
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net"
 	"net/rpc"
+	"os"
 	"reflect"
 	"sync"
 	"testing"
@@ -1147,3 +1148,23 @@ func TestServer_blockingRPC(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_handleConn_HandshakeTimeout(t *testing.T) {
+	s := &Server{
+		config: &uconf.ServerConfig{RPCHandshakeTimeout: 50 * time.Millisecond},
+		logger: ulog.New(os.Stderr, ulog.InfoLevel),
+	}
+	server, client := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(server)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the conn sending nothing was not closed")
+	}
+}
@@ -8,9 +8,6 @@ package scheduler
 
 import (
 	"fmt"
-	"math/rand"
-
-	//"math/rand"
 
 	memdb "github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/go-multierror"
@@ -72,6 +69,9 @@ type GenericScheduler struct {
 	blocked        *models.Evaluation
 	failedTGAllocs map[string]*models.AllocMetric
 	queuedAllocs   map[string]int
+
+	// preemptedJobs are the jobs having allocations evicted by the plan
+	preemptedJobs map[string]struct{}
 }
 
 // NewGenericScheduler is a factory function to instantiate a new synchronous scheduler
@@ -94,7 +94,7 @@ func (s *GenericScheduler) Process(eval *models.Evaluation) error {
 	case models.EvalTriggerJobRegister, models.EvalTriggerNodeUpdate,
 		models.EvalTriggerJobDeregister, models.EvalTriggerRollingUpdate,
 		models.EvalTriggerJobPause, models.EvalTriggerJobResume,
-		models.EvalTriggerMaxPlans:
+		models.EvalTriggerMaxPlans, models.EvalTriggerPreemption:
 	default:
 		desc := fmt.Sprintf("scheduler cannot handle '%s' evaluation reason",
 			eval.TriggeredBy)
@@ -187,6 +187,7 @@ func (s *GenericScheduler) process() (bool, error) {
 
 	// Reset the failed allocations
 	s.failedTGAllocs = nil
+	s.preemptedJobs = nil
 
 	// Create an evaluation context
 	s.ctx = NewEvalContext(s.state, s.plan, s.logger)
@@ -243,6 +244,12 @@ func (s *GenericScheduler) process() (bool, error) {
 		return false, nil
 	}
 
+	// Let the preempted jobs wait for capacity
+	if err := s.createPreemptionEvals(); err != nil {
+		s.logger.Errorf("sched: %#v failed to make preemption evals: %v", s.eval, err)
+		return false, err
+	}
+
 	// Success!
 	return true, nil
 }
@@ -386,17 +393,16 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 			return err
 		}
 
-		if preferredNode != nil {
-			// do nothing
-		} else {
-			nodeId := nodes[rand.Intn(len(nodes))].ID
-			s.logger.Debugf("sched: no preferred node. Auto selected node %v for task %v", nodeId, missing.Name)
-
-			ws := memdb.NewWatchSet() // TODO what is ws used for?
-			preferredNode, err = s.state.NodeByID(ws, nodeId)
-			if err != nil {
-				return err
-			}
+		autoSelected := preferredNode == nil
+		preferredNode, victim, err := s.selectNode(nodes, preferredNode)
+		if err != nil {
+			return err
+		}
+		if autoSelected && preferredNode != nil {
+			s.logger.Debugf("sched: no preferred node. Auto selected node %v for task %v", preferredNode.ID, missing.Name)
+		}
+		if victim != nil {
+			s.preempt(victim)
 		}
 
 		// Store the available nodes by datacenter
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// allocPreempted is the status used when an allocation is evicted for a
+	// higher priority job
+	allocPreempted = "alloc preempted by higher priority job %v"
+
+	// dimensionAllocs is the exhausted dimension when a node reaches MaxAllocs
+	dimensionAllocs = "allocations"
+)
+
+// nodeHasCapacity returns whether one more allocation could be placed on the node.
+func (s *GenericScheduler) nodeHasCapacity(node *models.Node) (bool, error) {
+	if node.MaxAllocs <= 0 {
+		return true, nil
+	}
+	proposed, err := s.ctx.ProposedAllocs(node.ID)
+	if err != nil {
+		return false, err
+	}
+	return len(proposed) < node.MaxAllocs, nil
+}
+
+// findPreemptible finds the allocation on the node to be evicted in favor of
+// the job. It is of the lowest priority job which is lower than the job, and the
+// most recently created one among that job. Returns nil if there is none.
+func (s *GenericScheduler) findPreemptible(node *models.Node) (*models.Allocation, int, error) {
+	proposed, err := s.ctx.ProposedAllocs(node.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var victim *models.Allocation
+	victimPriority := 0
+	ws := memdb.NewWatchSet()
+	for _, alloc := range proposed {
+		if alloc.JobID == s.job.ID {
+			continue
+		}
+		// Use the current job, the priority might have been changed since the alloc is placed.
+		job, err := s.state.JobByID(ws, alloc.JobID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if job == nil || job.Priority >= s.job.Priority {
+			continue
+		}
+		if victim == nil || job.Priority < victimPriority ||
+			(job.Priority == victimPriority && alloc.CreateIndex > victim.CreateIndex) {
+			victim = alloc
+			victimPriority = job.Priority
+		}
+	}
+	return victim, victimPriority, nil
+}
+
+// selectNode selects a node for a placement. If preferredNode is given, only it is
+// considered. Otherwise a random node with capacity is selected. If no node has
+// capacity, an allocation of a lower priority job is returned to be preempted.
+// Returns a nil node if the placement is not possible for now.
+func (s *GenericScheduler) selectNode(nodes []*models.Node, preferredNode *models.Node) (*models.Node, *models.Allocation, error) {
+	if preferredNode != nil {
+		nodes = []*models.Node{preferredNode}
+	}
+
+	var available []*models.Node
+	for _, node := range nodes {
+		ok, err := s.nodeHasCapacity(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			available = append(available, node)
+		} else {
+			s.ctx.Metrics().ExhaustedNode(node, dimensionAllocs)
+		}
+	}
+	if len(available) > 0 {
+		return available[rand.Intn(len(available))], nil, nil
+	}
+
+	var selected *models.Node
+	var victim *models.Allocation
+	victimPriority := 0
+	for _, node := range nodes {
+		alloc, priority, err := s.findPreemptible(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		if alloc != nil && (victim == nil || priority < victimPriority) {
+			selected, victim, victimPriority = node, alloc, priority
+		}
+	}
+	return selected, victim, nil
+}
+
+// preempt evicts the allocation. The job of it will be evaluated again after the
+// plan is committed, to be placed when there is capacity.
+func (s *GenericScheduler) preempt(alloc *models.Allocation) {
+	s.logger.Printf("sched: %#v: preempting alloc %v of job %v on node %v",
+		s.eval, alloc.ID, alloc.JobID, alloc.NodeID)
+	s.plan.AppendUpdate(alloc, models.AllocDesiredStatusEvict, fmt.Sprintf(allocPreempted, s.job.ID), "")
+	if s.preemptedJobs == nil {
+		s.preemptedJobs = make(map[string]struct{})
+	}
+	s.preemptedJobs[alloc.JobID] = struct{}{}
+}
+
+// createPreemptionEvals creates evaluations for the jobs preempted by the plan.
+func (s *GenericScheduler) createPreemptionEvals() error {
+	ws := memdb.NewWatchSet()
+	for jobID := range s.preemptedJobs {
+		job, err := s.state.JobByID(ws, jobID)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			continue
+		}
+		eval := &models.Evaluation{
+			ID:             models.GenerateUUID(),
+			Priority:       job.Priority,
+			Type:           job.Type,
+			TriggeredBy:    models.EvalTriggerPreemption,
+			JobID:          job.ID,
+			JobModifyIndex: job.JobModifyIndex,
+			Status:         models.EvalStatusPending,
+			PreviousEval:   s.eval.ID,
+		}
+		if err := s.planner.CreateEval(eval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
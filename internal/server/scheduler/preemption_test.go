@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package scheduler
+
+import (
+	"os"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+func preemptionTestJob(id string, priority int) *models.Job {
+	return &models.Job{
+		Region:      "global",
+		ID:          id,
+		Name:        id,
+		Type:        models.JobTypeSync,
+		Priority:    priority,
+		Datacenters: []string{"dc1"},
+		Status:      models.JobStatusPending,
+		Tasks: []*models.Task{
+			{Type: models.TaskTypeSrc, Config: map[string]interface{}{}},
+			{Type: models.TaskTypeDest, Config: map[string]interface{}{}},
+		},
+	}
+}
+
+func preemptionTestHarness(t *testing.T, lowPriority int) (*Harness, *models.Job) {
+	state, err := store.NewStateStore(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Harness{State: state, nextIndex: 1}
+
+	node := &models.Node{
+		ID:         models.GenerateUUID(),
+		Datacenter: "dc1",
+		Name:       "node1",
+		Status:     models.NodeStatusReady,
+		MaxAllocs:  2,
+	}
+	if err := state.UpsertNode(h.NextIndex(), node); err != nil {
+		t.Fatal(err)
+	}
+
+	low := preemptionTestJob("low", lowPriority)
+	if err := state.UpsertJob(h.NextIndex(), low); err != nil {
+		t.Fatal(err)
+	}
+	var allocs []*models.Allocation
+	for _, task := range low.Tasks {
+		allocs = append(allocs, &models.Allocation{
+			ID:            models.GenerateUUID(),
+			EvalID:        models.GenerateUUID(),
+			Name:          low.Name + "." + task.Type,
+			JobID:         low.ID,
+			Job:           low,
+			Task:          task.Type,
+			NodeID:        node.ID,
+			DesiredStatus: models.AllocDesiredStatusRun,
+			ClientStatus:  models.AllocClientStatusRunning,
+		})
+	}
+	if err := state.UpsertAllocs(h.NextIndex(), allocs); err != nil {
+		t.Fatal(err)
+	}
+
+	high := preemptionTestJob("high", 80)
+	if err := state.UpsertJob(h.NextIndex(), high); err != nil {
+		t.Fatal(err)
+	}
+	return h, high
+}
+
+func TestGenericScheduler_Preemption(t *testing.T) {
+	h, high := preemptionTestHarness(t, 10)
+	eval := &models.Evaluation{
+		ID:          models.GenerateUUID(),
+		Priority:    high.Priority,
+		Type:        high.Type,
+		TriggeredBy: models.EvalTriggerJobRegister,
+		JobID:       high.ID,
+		Status:      models.EvalStatusPending,
+	}
+	if err := h.Process(NewGenericScheduler, eval); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.Plans) != 1 {
+		t.Fatalf("expect 1 plan, got %v", len(h.Plans))
+	}
+	var placed, evicted int
+	for _, allocs := range h.Plans[0].NodeAllocation {
+		placed += len(allocs)
+	}
+	for _, allocs := range h.Plans[0].NodeUpdate {
+		for _, alloc := range allocs {
+			if alloc.JobID != "low" || alloc.DesiredStatus != models.AllocDesiredStatusEvict {
+				t.Errorf("unexpected update of alloc %v: %v", alloc.Name, alloc.DesiredStatus)
+			}
+			evicted++
+		}
+	}
+	if placed != 2 || evicted != 2 {
+		t.Errorf("expect 2 placed and 2 evicted, got %v and %v", placed, evicted)
+	}
+
+	if len(h.CreateEvals) != 1 {
+		t.Fatalf("expect 1 created eval, got %v", len(h.CreateEvals))
+	}
+	if e := h.CreateEvals[0]; e.JobID != "low" || e.TriggeredBy != models.EvalTriggerPreemption || e.Priority != 10 {
+		t.Errorf("unexpected preemption eval: %#v", e)
+	}
+}
+
+func TestGenericScheduler_NoPreemptionOfHigherPriority(t *testing.T) {
+	h, high := preemptionTestHarness(t, 90)
+	eval := &models.Evaluation{
+		ID:          models.GenerateUUID(),
+		Priority:    high.Priority,
+		Type:        high.Type,
+		TriggeredBy: models.EvalTriggerJobRegister,
+		JobID:       high.ID,
+		Status:      models.EvalStatusPending,
+	}
+	if err := h.Process(NewGenericScheduler, eval); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.Plans) != 0 {
+		t.Fatalf("expect no plan, got %#v", h.Plans[0])
+	}
+	if len(h.CreateEvals) != 1 || h.CreateEvals[0].Status != models.EvalStatusBlocked {
+		t.Fatalf("expect a blocked eval, got %#v", h.CreateEvals)
+	}
+	if h.CreateEvals[0].Priority != high.Priority {
+		t.Errorf("blocked eval priority = %v, want %v", h.CreateEvals[0].Priority, high.Priority)
+	}
+}
@@ -26,7 +26,9 @@ const (
 // BuiltinSchedulers contains the built in registered schedulers
 // which are available
 var BuiltinSchedulers = map[string]Factory{
-	models.JobTypeSync: NewGenericScheduler,
+	models.JobTypeSync:   NewGenericScheduler,
+	models.JobTypeBench:  NewGenericScheduler,
+	models.JobTypeVerify: NewGenericScheduler,
 }
 
 // NewScheduler is used to instantiate and return a new scheduler
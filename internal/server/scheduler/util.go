@@ -9,7 +9,6 @@ package scheduler
 import (
 	"fmt"
 	"math/rand"
-	"reflect"
 
 	memdb "github.com/hashicorp/go-memdb"
 
@@ -287,7 +286,8 @@ func tasksUpdated(jobA, jobB *models.Job, task string) bool {
 		return true
 	}
 
-	if !reflect.DeepEqual(a.Config, b.Config) {
+	// The tables are changed in place.
+	if a.ConfigChanged(b) {
 		return true
 	}
 
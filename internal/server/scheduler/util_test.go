@@ -255,12 +255,61 @@ func Test_tasksUpdated(t *testing.T) {
 		jobB *models.Job
 		task string
 	}
+	job := func(driver string, config map[string]interface{}) *models.Job {
+		task := models.NewTask()
+		task.Type = models.TaskTypeSrc
+		task.Driver = driver
+		task.Config = config
+		return &models.Job{Tasks: []*models.Task{task}}
+	}
+	tables := func(names ...string) []interface{} {
+		var tbs []interface{}
+		for _, name := range names {
+			tbs = append(tbs, map[string]interface{}{"TableName": name})
+		}
+		return []interface{}{map[string]interface{}{"TableSchema": "db1", "Tables": tbs}}
+	}
 	tests := []struct {
 		name string
 		args args
 		want bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "same",
+			args: args{
+				jobA: job("MySQL", map[string]interface{}{"Gtid": "", "ReplicateDoDb": tables("t1")}),
+				jobB: job("MySQL", map[string]interface{}{"Gtid": "", "ReplicateDoDb": tables("t1")}),
+				task: models.TaskTypeSrc,
+			},
+			want: false,
+		},
+		{
+			name: "tables",
+			args: args{
+				jobA: job("MySQL", map[string]interface{}{"Gtid": "", "ReplicateDoDb": tables("t1")}),
+				jobB: job("MySQL", map[string]interface{}{"Gtid": "", "ReplicateDoDb": tables("t1", "t2")}),
+				task: models.TaskTypeSrc,
+			},
+			want: false,
+		},
+		{
+			name: "config",
+			args: args{
+				jobA: job("MySQL", map[string]interface{}{"Gtid": "", "ReplicateDoDb": tables("t1")}),
+				jobB: job("MySQL", map[string]interface{}{"Gtid": "x", "ReplicateDoDb": tables("t1", "t2")}),
+				task: models.TaskTypeSrc,
+			},
+			want: true,
+		},
+		{
+			name: "driver",
+			args: args{
+				jobA: job("MySQL", nil),
+				jobB: job("Kafka", nil),
+				task: models.TaskTypeSrc,
+			},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -29,8 +29,10 @@ import (
 
 	"github.com/actiontech/dtle/internal"
 	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/connlimit"
 	ulog "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/server/store"
+	"github.com/actiontech/dtle/internal/tlsutil"
 )
 
 const (
@@ -84,6 +86,17 @@ type Server struct {
 	rpcServer    *rpc.Server
 	rpcAdvertise net.Addr
 
+	// rpcConnLimiter limits the RPC connections of each client IP.
+	rpcConnLimiter *connlimit.Limiter
+
+	// tlsConfigurator holds the certificate of the mutual TLS of the RPC, nil
+	// if TLS is disabled. bootstrapRPCServer serves the agents connecting
+	// without a certificate, to get one. caSealKey seals the private keys of
+	// the roots of the CA.
+	tlsConfigurator    *tlsutil.Configurator
+	bootstrapRPCServer *rpc.Server
+	caSealKey          []byte
+
 	// peers is used to track the known Udup servers. This is
 	// used for region forwarding and clustering.
 	peers      map[string][]*serverParts
@@ -131,13 +144,19 @@ type Server struct {
 
 // Holds the RPC endpoints
 type endpoints struct {
-	Status *Status
-	Node   *Node
-	Job    *Job
-	Order  *Order
-	Eval   *Eval
-	Plan   *Plan
-	Alloc  *Alloc
+	Status   *Status
+	Node     *Node
+	Job      *Job
+	Order    *Order
+	Eval     *Eval
+	Plan     *Plan
+	Alloc    *Alloc
+	Operator *Operator
+	System   *System
+	Audit    *Audit
+	CA       *CA
+
+	ConnectionProfile *ConnectionProfile
 }
 
 // NewServer is used to construct a new Udup server from the
@@ -203,6 +222,13 @@ func NewServer(config *uconf.ServerConfig, logger *ulog.Logger) (*Server, error)
 		}
 	}
 
+	// Initialize the mutual TLS of the RPC
+	if err := s.setupTLS(); err != nil {
+		s.Shutdown()
+		s.logger.Errorf("manager: failed to setup TLS: %s", err)
+		return nil, fmt.Errorf("Failed to setup TLS: %v", err)
+	}
+
 	// Initialize the wan Serf
 	s.serf, err = s.setupSerf(config.SerfConfig, s.eventCh, serfSnapshot)
 	if err != nil {
@@ -392,6 +418,11 @@ func (s *Server) setupRPC() error {
 	s.endpoints.Node = &Node{srv: s}
 	s.endpoints.Plan = &Plan{s}
 	s.endpoints.Status = &Status{s}
+	s.endpoints.Operator = &Operator{s}
+	s.endpoints.System = &System{s}
+	s.endpoints.Audit = &Audit{s}
+	s.endpoints.CA = &CA{s}
+	s.endpoints.ConnectionProfile = &ConnectionProfile{s}
 
 	// Register the handlers
 	s.rpcServer.Register(s.endpoints.Alloc)
@@ -401,12 +432,18 @@ func (s *Server) setupRPC() error {
 	s.rpcServer.Register(s.endpoints.Node)
 	s.rpcServer.Register(s.endpoints.Plan)
 	s.rpcServer.Register(s.endpoints.Status)
+	s.rpcServer.Register(s.endpoints.Operator)
+	s.rpcServer.Register(s.endpoints.System)
+	s.rpcServer.Register(s.endpoints.Audit)
+	s.rpcServer.Register(s.endpoints.CA)
+	s.rpcServer.Register(s.endpoints.ConnectionProfile)
 
 	list, err := net.ListenTCP("tcp", s.config.RPCAddr)
 	if err != nil {
 		return err
 	}
 	s.rpcListener = list
+	s.rpcConnLimiter = connlimit.NewLimiter(s.config.RPCMaxConnsPerClient)
 
 	if s.config.RPCAdvertise != nil {
 		s.rpcAdvertise = s.config.RPCAdvertise
@@ -602,7 +639,6 @@ func (s *Server) setupSerf(conf *serf.Config, ch chan serf.Event, path string) (
 	if err := ensurePath(conf.SnapshotPath, false); err != nil {
 		return nil, err
 	}
-	conf.RejoinAfterLeave = true
 
 	// Until Udup supports this fully, we disable automatic resolution.
 	// When enabled, the Serf gossip may just turn off if we are the minority
@@ -685,6 +721,14 @@ func (s *Server) State() *store.StateStore {
 	return s.fsm.State()
 }
 
+// StoreHealth checks the consul store is reachable, if it is used.
+func (s *Server) StoreHealth() error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Ping()
+}
+
 // Regions returns the known regions in the cluster.
 func (s *Server) Regions() []string {
 	s.peerLock.RLock()
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hashicorp/raft"
+	"github.com/ugorji/go/codec"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// snapshotArchiveVersion is the version of the snapshot archive format.
+const snapshotArchiveVersion = 1
+
+// snapshotArchiveHeader is the header of a snapshot archive. An archive is
+// gzipped, and holds the length of the msgpack encoded header as a big endian
+// uint32, the header, then the FSM snapshot.
+type snapshotArchiveHeader struct {
+	Version int
+	Meta    *raft.SnapshotMeta
+
+	// SHA256 is the checksum of the FSM snapshot.
+	SHA256 []byte
+}
+
+// writeSnapshotArchive writes an archive of the FSM snapshot read from state.
+func writeSnapshotArchive(w io.Writer, meta *raft.SnapshotMeta, state io.Reader) error {
+	data, err := ioutil.ReadAll(state)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	header := snapshotArchiveHeader{
+		Version: snapshotArchiveVersion,
+		Meta:    meta,
+		SHA256:  sum[:],
+	}
+	var headerBuf bytes.Buffer
+	if err := codec.NewEncoder(&headerBuf, models.MsgpackHandle).Encode(&header); err != nil {
+		return fmt.Errorf("failed to encode snapshot header: %v", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	if err := binary.Write(gz, binary.BigEndian, uint32(headerBuf.Len())); err != nil {
+		return err
+	}
+	if _, err := gz.Write(headerBuf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readSnapshotArchive reads an archive written by writeSnapshotArchive, and
+// verifies the checksum of the FSM snapshot.
+func readSnapshotArchive(r io.Reader) (*raft.SnapshotMeta, []byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a snapshot archive: %v", err)
+	}
+	defer gz.Close()
+
+	var headerLen uint32
+	if err := binary.Read(gz, binary.BigEndian, &headerLen); err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot header: %v", err)
+	}
+	headerBuf := make([]byte, headerLen)
+	if _, err := io.ReadFull(gz, headerBuf); err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot header: %v", err)
+	}
+	var header snapshotArchiveHeader
+	if err := models.Decode(headerBuf, &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode snapshot header: %v", err)
+	}
+	if header.Version != snapshotArchiveVersion {
+		return nil, nil, fmt.Errorf("unsupported snapshot archive version: %v", header.Version)
+	}
+	if header.Meta == nil {
+		return nil, nil, fmt.Errorf("snapshot meta is missing")
+	}
+
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], header.SHA256) {
+		return nil, nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+	header.Meta.Size = int64(len(data))
+	return header.Meta, data, nil
+}
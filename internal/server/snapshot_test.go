@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/raft"
+)
+
+func TestSnapshotArchive(t *testing.T) {
+	meta := &raft.SnapshotMeta{Version: 1, ID: "2-10-1", Index: 10, Term: 2}
+	state := []byte("fsm state")
+
+	var buf bytes.Buffer
+	if err := writeSnapshotArchive(&buf, meta, bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	gotMeta, gotState, err := readSnapshotArchive(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotMeta.Index != 10 || gotMeta.Term != 2 || gotMeta.Size != int64(len(state)) {
+		t.Errorf("meta = %+v", gotMeta)
+	}
+	if !bytes.Equal(gotState, state) {
+		t.Errorf("state = %q", gotState)
+	}
+
+	// Corrupt the state.
+	var corrupted bytes.Buffer
+	gr, _ := gzip.NewReader(bytes.NewReader(archive))
+	var raw bytes.Buffer
+	raw.ReadFrom(gr)
+	b := raw.Bytes()
+	b[len(b)-1] ^= 0xff
+	gw := gzip.NewWriter(&corrupted)
+	gw.Write(b)
+	gw.Close()
+	if _, _, err := readSnapshotArchive(&corrupted); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("readSnapshotArchive() of a corrupted archive = %v", err)
+	}
+
+	if _, _, err := readSnapshotArchive(strings.NewReader("not gzip")); err == nil {
+		t.Errorf("readSnapshotArchive() of garbage should fail")
+	}
+}
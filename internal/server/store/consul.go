@@ -48,6 +48,15 @@ func NewConsulStore(addrs []string, logger *ulog.Logger) (*Store, error) {
 	return s, nil
 }
 
+// Ping checks the store backend is reachable.
+func (s *Store) Ping() error {
+	_, err := s.Client.List(keyspace)
+	if err != store.ErrKeyNotFound && err != nil {
+		return fmt.Errorf("store backend not reachable: %v", err)
+	}
+	return nil
+}
+
 // Retrieve the leader from the store
 func (s *Store) GetLeader() []byte {
 	res, err := s.Client.Get(s.LeaderKey())
@@ -29,6 +29,9 @@ func stateStoreSchema() *memdb.DBSchema {
 		orderTableSchema,
 		evalTableSchema,
 		allocTableSchema,
+		auditTableSchema,
+		caRootTableSchema,
+		connectionProfileTableSchema,
 	}
 
 	// Add each of the tables
@@ -240,3 +243,56 @@ func allocTableSchema() *memdb.TableSchema {
 		},
 	}
 }
+
+// auditTableSchema returns the MemDB schema for the audit table, which stores
+// the changes requested through the HTTP API.
+func auditTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "audit",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": {
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.UUIDFieldIndex{
+					Field: "ID",
+				},
+			},
+		},
+	}
+}
+
+// caRootTableSchema returns the MemDB schema for the roots of the built-in CA.
+func caRootTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "ca_roots",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": {
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.UUIDFieldIndex{
+					Field: "ID",
+				},
+			},
+		},
+	}
+}
+
+// connectionProfileTableSchema returns the MemDB schema for the connection
+// profiles, by name.
+func connectionProfileTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "connection_profiles",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": {
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "Name",
+				},
+			},
+		},
+	}
+}
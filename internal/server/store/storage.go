@@ -366,18 +366,32 @@ func (s *StateStore) UpsertJob(index uint64, job *models.Job) error {
 
 	// Setup the indexes correctly
 	if existing != nil {
+		// The spec of a running job is kept, but for its tables, which the
+		// tasks change while running.
+		tablesChanged := false
 		if existing.(*models.Job).Status == models.JobStatusRunning {
-			return nil
+			if !existing.(*models.Job).OnlyTablesChanged(job) {
+				return nil
+			}
+			tablesChanged = true
 		}
 		job.CreateIndex = existing.(*models.Job).CreateIndex
 		job.ModifyIndex = index
 		job.JobModifyIndex = index
+		// A job of another spec copies its data again.
+		if tablesChanged || !existing.(*models.Job).SpecChanged(job) {
+			job.FullCopyComplete = existing.(*models.Job).FullCopyComplete
+		}
 		for _, t1 := range existing.(*models.Job).Tasks {
 			for i, t2 := range job.Tasks {
 				if t1.Type == t2.Type && t2.Config["NatsAddr"] == nil {
 					t2.Config["NatsAddr"] = t1.Config["NatsAddr"]
 					job.Tasks[i] = t2
 				}
+				// The tasks go on from where they are.
+				if tablesChanged && t1.Type == t2.Type {
+					t2.Config["Gtid"] = t1.Config["Gtid"]
+				}
 			}
 		}
 
@@ -749,6 +763,206 @@ func (s *StateStore) Orders(ws memdb.WatchSet) (memdb.ResultIterator, error) {
 
 //order end
 
+// UpsertAuditEvents is used to record audit events
+func (s *StateStore) UpsertAuditEvents(index uint64, events []*models.AuditEvent) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	for _, event := range events {
+		event.CreateIndex = index
+		if err := txn.Insert("audit", event); err != nil {
+			return fmt.Errorf("audit event insert failed: %v", err)
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"audit", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// PruneAuditEvents deletes the audit events older than before, in UnixNano
+func (s *StateStore) PruneAuditEvents(index uint64, before int64) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	iter, err := txn.Get("audit", "id")
+	if err != nil {
+		return fmt.Errorf("audit event lookup failed: %v", err)
+	}
+	var expired []interface{}
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		if raw.(*models.AuditEvent).Time < before {
+			expired = append(expired, raw)
+		}
+	}
+	for _, event := range expired {
+		if err := txn.Delete("audit", event); err != nil {
+			return fmt.Errorf("audit event delete failed: %v", err)
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"audit", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// AuditEvents returns an iterator over all the audit events
+func (s *StateStore) AuditEvents(ws memdb.WatchSet) (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("audit", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	return iter, nil
+}
+
+// SetCARoots is used to replace the roots of the CA by the roots given
+func (s *StateStore) SetCARoots(index uint64, roots []*models.CARoot) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	existing := make(map[string]*models.CARoot)
+	iter, err := txn.Get("ca_roots", "id")
+	if err != nil {
+		return fmt.Errorf("CA root lookup failed: %v", err)
+	}
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		root := raw.(*models.CARoot)
+		existing[root.ID] = root
+	}
+	if _, err := txn.DeleteAll("ca_roots", "id"); err != nil {
+		return fmt.Errorf("CA root delete failed: %v", err)
+	}
+
+	for _, root := range roots {
+		if old, ok := existing[root.ID]; ok {
+			root.CreateIndex = old.CreateIndex
+		} else {
+			root.CreateIndex = index
+		}
+		root.ModifyIndex = index
+		if err := txn.Insert("ca_roots", root); err != nil {
+			return fmt.Errorf("CA root insert failed: %v", err)
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"ca_roots", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// CARoots returns all the roots of the CA
+func (s *StateStore) CARoots(ws memdb.WatchSet) ([]*models.CARoot, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("ca_roots", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	var roots []*models.CARoot
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		roots = append(roots, raw.(*models.CARoot))
+	}
+	return roots, nil
+}
+
+// UpsertConnectionProfile is used to create or replace a connection profile
+func (s *StateStore) UpsertConnectionProfile(index uint64, profile *models.ConnectionProfile) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	existing, err := txn.First("connection_profiles", "id", profile.Name)
+	if err != nil {
+		return fmt.Errorf("connection profile lookup failed: %v", err)
+	}
+	if existing != nil {
+		profile.CreateIndex = existing.(*models.ConnectionProfile).CreateIndex
+	} else {
+		profile.CreateIndex = index
+	}
+	profile.ModifyIndex = index
+	if err := txn.Insert("connection_profiles", profile); err != nil {
+		return fmt.Errorf("connection profile insert failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"connection_profiles", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// DeleteConnectionProfile is used to delete a connection profile
+func (s *StateStore) DeleteConnectionProfile(index uint64, name string) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	existing, err := txn.First("connection_profiles", "id", name)
+	if err != nil {
+		return fmt.Errorf("connection profile lookup failed: %v", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("connection profile not found")
+	}
+	if err := txn.Delete("connection_profiles", existing); err != nil {
+		return fmt.Errorf("connection profile delete failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"connection_profiles", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// ConnectionProfileByName is used to lookup a connection profile by name
+func (s *StateStore) ConnectionProfileByName(ws memdb.WatchSet, name string) (*models.ConnectionProfile, error) {
+	txn := s.db.Txn(false)
+
+	watchCh, existing, err := txn.FirstWatch("connection_profiles", "id", name)
+	if err != nil {
+		return nil, fmt.Errorf("connection profile lookup failed: %v", err)
+	}
+
+	ws.Add(watchCh)
+
+	if existing != nil {
+		return existing.(*models.ConnectionProfile), nil
+	}
+	return nil, nil
+}
+
+// ConnectionProfiles returns all the connection profiles, by name
+func (s *StateStore) ConnectionProfiles(ws memdb.WatchSet) ([]*models.ConnectionProfile, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("connection_profiles", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	var profiles []*models.ConnectionProfile
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		profiles = append(profiles, raw.(*models.ConnectionProfile))
+	}
+	return profiles, nil
+}
+
 // UpsertEvals is used to upsert a set of evaluations
 func (s *StateStore) UpsertEvals(index uint64, evals []*models.Evaluation) error {
 	txn := s.db.Txn(true)
@@ -760,6 +974,9 @@ func (s *StateStore) UpsertEvals(index uint64, evals []*models.Evaluation) error
 		if err := s.nestedUpsertEval(txn, index, eval); err != nil {
 			return err
 		}
+		if err := s.clearJobWaiting(index, txn, eval); err != nil {
+			return err
+		}
 
 		jobs[eval.JobID] = ""
 	}
@@ -1030,6 +1247,12 @@ func (s *StateStore) nestedUpdateAllocFromClient(txn *memdb.Txn, index uint64, a
 		return fmt.Errorf("alloc insert failed: %v", err)
 	}
 
+	if copyAlloc.Task == models.TaskTypeDest && fullCopyCompleteReported(copyAlloc) {
+		if err := s.setJobFullCopyComplete(index, txn, exist.JobID); err != nil {
+			return err
+		}
+	}
+
 	// Set the job's status
 	forceStatus := ""
 	if !copyAlloc.ClientTerminalStatus() {
@@ -1049,6 +1272,56 @@ func (s *StateStore) nestedUpdateAllocFromClient(txn *memdb.Txn, index uint64, a
 	return nil
 }
 
+// fullCopyCompleteReported returns whether the task of the alloc has reported
+// the full copy complete in its last events.
+func fullCopyCompleteReported(alloc *models.Allocation) bool {
+	for _, state := range alloc.TaskStates {
+		for _, event := range state.Events {
+			if event.Type == models.TaskFullCopyComplete {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setJobFullCopyComplete marks the full copy of the job complete.
+func (s *StateStore) setJobFullCopyComplete(index uint64, txn *memdb.Txn, jobID string) error {
+	existing, err := txn.First("jobs", "id", jobID)
+	if err != nil {
+		return fmt.Errorf("job lookup failed: %v", err)
+	}
+	if existing == nil || existing.(*models.Job).FullCopyComplete {
+		return nil
+	}
+	updated := existing.(*models.Job).Copy()
+	updated.FullCopyComplete = true
+	updated.ModifyIndex = index
+	if err := txn.Insert("jobs", updated); err != nil {
+		return fmt.Errorf("job insert failed: %v", err)
+	}
+	return txn.Insert("index", &IndexEntry{"jobs", index})
+}
+
+// clearJobWaiting unmarks the waiting job once it is evaluated.
+func (s *StateStore) clearJobWaiting(index uint64, txn *memdb.Txn, eval *models.Evaluation) error {
+	existing, err := txn.First("jobs", "id", eval.JobID)
+	if err != nil {
+		return fmt.Errorf("job lookup failed: %v", err)
+	}
+	if existing == nil || !existing.(*models.Job).Waiting ||
+		eval.JobModifyIndex < existing.(*models.Job).JobModifyIndex {
+		return nil
+	}
+	updated := existing.(*models.Job).Copy()
+	updated.Waiting = false
+	updated.ModifyIndex = index
+	if err := txn.Insert("jobs", updated); err != nil {
+		return fmt.Errorf("job insert failed: %v", err)
+	}
+	return txn.Insert("index", &IndexEntry{"jobs", index})
+}
+
 // UpsertAllocs is used to evict a set of allocations
 // and allocate new ones at the same time.
 func (s *StateStore) UpsertAllocs(index uint64, allocs []*models.Allocation) error {
@@ -1568,6 +1841,38 @@ func (r *StateRestore) AllocRestore(alloc *models.Allocation) error {
 	return nil
 }
 
+// OrderRestore is used to restore an order
+func (r *StateRestore) OrderRestore(order *models.Order) error {
+	if err := r.txn.Insert("orders", order); err != nil {
+		return fmt.Errorf("order insert failed: %v", err)
+	}
+	return nil
+}
+
+// AuditEventRestore is used to restore an audit event
+func (r *StateRestore) AuditEventRestore(event *models.AuditEvent) error {
+	if err := r.txn.Insert("audit", event); err != nil {
+		return fmt.Errorf("audit event insert failed: %v", err)
+	}
+	return nil
+}
+
+// CARootRestore is used to restore a root of the CA
+func (r *StateRestore) CARootRestore(root *models.CARoot) error {
+	if err := r.txn.Insert("ca_roots", root); err != nil {
+		return fmt.Errorf("CA root insert failed: %v", err)
+	}
+	return nil
+}
+
+// ConnectionProfileRestore is used to restore a connection profile
+func (r *StateRestore) ConnectionProfileRestore(profile *models.ConnectionProfile) error {
+	if err := r.txn.Insert("connection_profiles", profile); err != nil {
+		return fmt.Errorf("connection profile insert failed: %v", err)
+	}
+	return nil
+}
+
 // IndexRestore is used to restore an index
 func (r *StateRestore) IndexRestore(idx *IndexEntry) error {
 	if err := r.txn.Insert("index", idx); err != nil {
@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// System endpoint is used to call the system-wide operations.
+type System struct {
+	srv *Server
+}
+
+// GarbageCollect is used to collect all the terminal evaluations, allocations,
+// jobs and down nodes immediately, regardless of the GC thresholds.
+func (s *System) GarbageCollect(args *models.GenericRequest, reply *models.GarbageCollectResponse) error {
+	if done, err := s.srv.forward("System.GarbageCollect", args, args, reply); done {
+		return err
+	}
+
+	stats, err := s.srv.garbageCollect(true)
+	if err != nil {
+		return err
+	}
+	reply.Evals = stats.Evals
+	reply.Allocations = stats.Allocs
+	reply.Jobs = stats.Jobs
+	reply.Nodes = stats.Nodes
+	reply.Index = s.srv.raft.AppliedIndex()
+	return nil
+}
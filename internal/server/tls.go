@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/rpc"
+	"time"
+
+	memdb "github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/tlsutil"
+)
+
+const (
+	// serverCertRetryInterval is how long to wait before issuing the
+	// certificate of the server again after a failure.
+	serverCertRetryInterval = 10 * time.Second
+)
+
+// setupTLS prepares the mutual TLS of the RPC if it is enabled. The certificate
+// of the server is issued with the built-in CA, whose roots are replicated by
+// Raft with their private keys sealed by the tls ca_key, and renewed at half
+// of its life.
+func (s *Server) setupTLS() error {
+	conf := s.config.TLSConfig
+	if conf == nil || (!conf.EnableRPC && !conf.VerifyIncoming) {
+		return nil
+	}
+	if s.fsm == nil {
+		return fmt.Errorf("the built-in CA needs the Raft store, not Consul")
+	}
+	if conf.CertTTL < time.Minute {
+		return fmt.Errorf("tls cert_ttl must be at least 1m, got %v", conf.CertTTL)
+	}
+	sealKey, err := conf.CASealKey()
+	if err != nil {
+		return fmt.Errorf("tls %v", err)
+	}
+	if sealKey == nil {
+		return fmt.Errorf("tls ca_key is needed to seal the keys of the CA")
+	}
+	s.caSealKey = sealKey
+
+	s.tlsConfigurator = tlsutil.NewConfigurator(s.config.Region)
+	s.bootstrapRPCServer = rpc.NewServer()
+	if err := s.bootstrapRPCServer.RegisterName("CA", &caBootstrap{s.endpoints.CA}); err != nil {
+		return err
+	}
+	if conf.EnableRPC {
+		s.connPool.SetTLSConfigurator(s.tlsConfigurator)
+	}
+
+	go s.monitorServerCert()
+	return nil
+}
+
+// initializeCA creates the first root of the CA, when the leader finds none.
+// The root is rotated if its key cannot be opened, e.g. it was sealed with
+// another ca_key, or stored in clear by a previous version.
+func (s *Server) initializeCA() error {
+	if s.tlsConfigurator == nil {
+		return nil
+	}
+	roots, err := s.State().CARoots(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+	if active := activeCARoot(roots); active != nil {
+		_, err := s.caRootKey(active)
+		if err == nil {
+			return nil
+		}
+		s.logger.Warnf("manager: rotating the root of the CA: %v", err)
+	}
+
+	roots, err = rotateCARoots(roots, s.config.Region, s.caSealKey, s.config.TLSConfig.CertTTL, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to create the CA root: %v", err)
+	}
+	req := &models.CARootsSetRequest{Roots: roots}
+	if _, _, err := s.raftApply(models.CARootsSetRequestType|models.IgnoreUnknownTypeFlag, req); err != nil {
+		return fmt.Errorf("failed to store the CA root: %v", err)
+	}
+	s.logger.Printf("manager: initialized the CA, root %v", roots[0].ID)
+	return nil
+}
+
+// monitorServerCert issues the certificate of the server with the active root,
+// renews it at half of its life, and keeps the roots trusted up to date.
+//
+// A server keeps its certificate when the root is rotated, until renewing it:
+// the agents have then learnt the new root.
+func (s *Server) monitorServerCert() {
+	var rootsIndex uint64
+	for {
+		ws := memdb.NewWatchSet()
+		ws.Add(s.shutdownCh)
+		state := s.State()
+		roots, err := state.CARoots(ws)
+		if err != nil {
+			s.logger.Errorf("manager: failed to get the CA roots: %v", err)
+		}
+		index, _ := state.Index("ca_roots")
+
+		wait := serverCertRetryInterval
+		if active := activeCARoot(roots); active != nil && err == nil {
+			switch {
+			case !s.tlsConfigurator.Ready() || !time.Now().Before(s.tlsConfigurator.RenewTime()):
+				if err := s.issueServerCert(active, roots); err != nil {
+					s.logger.Errorf("manager: failed to issue the server certificate: %v", err)
+					break
+				}
+				rootsIndex = index
+				s.logger.Printf("manager: issued the server certificate, renewing at %v",
+					s.tlsConfigurator.RenewTime().Format(time.RFC3339))
+
+			case index != rootsIndex:
+				if err := s.tlsConfigurator.UpdateRoots(caRootCertificates(roots)); err != nil {
+					s.logger.Errorf("manager: failed to update the CA roots: %v", err)
+					break
+				}
+				rootsIndex = index
+			}
+			if s.tlsConfigurator.Ready() && rootsIndex == index {
+				wait = s.tlsConfigurator.RenewTime().Sub(time.Now())
+			}
+		}
+
+		ws.Watch(time.After(wait))
+		if s.IsShutdown() {
+			return
+		}
+	}
+}
+
+// issueServerCert signs a certificate for the server with the root.
+func (s *Server) issueServerCert(root *models.CARoot, roots []*models.CARoot) error {
+	key, keyPEM, err := tlsutil.GenerateKey()
+	if err != nil {
+		return err
+	}
+	rootKey, err := s.caRootKey(root)
+	if err != nil {
+		return err
+	}
+	name := tlsutil.ServerName(s.config.Region)
+	cert, err := tlsutil.Sign(root.Certificate, rootKey, key.Public(), s.config.NodeName+"."+name,
+		name, s.config.TLSConfig.CertTTL)
+	if err != nil {
+		return err
+	}
+	return s.tlsConfigurator.Update(cert, keyPEM, caRootCertificates(roots))
+}
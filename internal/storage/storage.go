@@ -0,0 +1,233 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package storage keeps objects in the object storage configured by the
+// `storage` block of the agent: S3, Alibaba OSS, MinIO, or a directory.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// defaultRegion is the region of the buckets if not configured. MinIO and OSS
+// sign the requests with it too.
+const defaultRegion = "us-east-1"
+
+// Store is an object storage. The keys are relative to the prefix of the
+// storage, with "/" as the separator.
+type Store interface {
+	// Put stores the object, replacing the one of the key if any.
+	Put(key string, r io.ReadSeeker) error
+	// Get returns the content of the object, to close.
+	Get(key string) (io.ReadCloser, error)
+	// List returns the keys with the prefix, sorted.
+	List(prefix string) ([]string, error)
+	Delete(key string) error
+	// URL tells where the object of the key is, for the logs.
+	URL(key string) string
+}
+
+// New returns the storage of the configuration.
+func New(cfg *config.StorageConfig) (Store, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("no storage configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	if cfg.Type == config.StorageTypeFile {
+		return &fileStore{dir: cfg.Path, prefix: cfg.Prefix}, nil
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	awsConfig := aws.NewConfig().WithRegion(region)
+	if cfg.Endpoint != "" {
+		awsConfig.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.Type == config.StorageTypeMinIO {
+		// MinIO has no virtual-hosted buckets by default.
+		awsConfig.WithS3ForcePathStyle(true)
+	}
+	if cfg.AccessKey != "" {
+		awsConfig.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""))
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	return &s3Store{
+		client: s3.New(sess),
+		cfg:    cfg,
+	}, nil
+}
+
+// s3Store is a bucket of S3, or of a service with its API: OSS and MinIO.
+type s3Store struct {
+	client *s3.S3
+	cfg    *config.StorageConfig
+}
+
+func (s *s3Store) Put(key string, r io.ReadSeeker) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.cfg.Prefix + key),
+		Body:   r,
+	})
+	return err
+}
+
+func (s *s3Store) Get(key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.cfg.Prefix + key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Store) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.Bucket),
+		Prefix: aws.String(s.cfg.Prefix + prefix),
+	}, func(out *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range out.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.StringValue(object.Key), s.cfg.Prefix))
+		}
+		return true
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s *s3Store) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.cfg.Prefix + key),
+	})
+	return err
+}
+
+func (s *s3Store) URL(key string) string {
+	return fmt.Sprintf("%v://%v/%v%v", s.cfg.Type, s.cfg.Bucket, s.cfg.Prefix, key)
+}
+
+// fileStore keeps the objects as files under dir.
+type fileStore struct {
+	dir    string
+	prefix string
+}
+
+func (s *fileStore) path(key string) (string, error) {
+	key = s.prefix + key
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *fileStore) Put(key string, r io.ReadSeeker) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+		return err
+	}
+	// Written aside, so that the object is replaced at once.
+	f, err := ioutil.TempFile(filepath.Dir(p), ".put-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *fileStore) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *fileStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, s.prefix+prefix) {
+			keys = append(keys, strings.TrimPrefix(key, s.prefix))
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s *fileStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (s *fileStore) URL(key string) string {
+	p, err := s.path(key)
+	if err != nil {
+		return key
+	}
+	return "file://" + p
+}
+
+// PutFile stores the file as the object of the key.
+func PutFile(store Store, key string, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.Put(key, f)
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(&config.StorageConfig{Type: config.StorageTypeFile, Path: dir, Prefix: "dtle/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"dumps/job1/a.sql", "dumps/job1/manifest.json", "snapshots/global.snap"} {
+		if err := store.Put(key, strings.NewReader(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Replaced.
+	if err := store.Put("dumps/job1/a.sql", strings.NewReader("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := store.Get("dumps/job1/a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != "b" {
+		t.Errorf("Get() = %q, %v", content, err)
+	}
+
+	keys, err := store.List("dumps/")
+	if err != nil || !reflect.DeepEqual(keys, []string{"dumps/job1/a.sql", "dumps/job1/manifest.json"}) {
+		t.Errorf("List() = %v, %v", keys, err)
+	}
+
+	if err := store.Delete("dumps/job1/a.sql"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("dumps/job1/a.sql"); err == nil {
+		t.Errorf("Get() of a deleted object succeeded")
+	}
+	if keys, _ := store.List(""); len(keys) != 2 {
+		t.Errorf("List() after Delete() = %v", keys)
+	}
+
+	for _, key := range []string{"../x", "a/../../x", "a//b"} {
+		if err := store.Put(key, strings.NewReader("")); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil); err == nil {
+		t.Errorf("New(nil) succeeded")
+	}
+	if _, err := New(&config.StorageConfig{Type: config.StorageTypeS3}); err == nil {
+		t.Errorf("New() without a bucket succeeded")
+	}
+	store, err := New(&config.StorageConfig{Type: config.StorageTypeMinIO, Endpoint: "http://127.0.0.1:9000", Bucket: "dtle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := store.URL("snapshots/a.snap"); url != "minio://dtle/snapshots/a.snap" {
+		t.Errorf("URL() = %v", url)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package tlsutil issues the certificates of the built-in CA, and sets up the
+// mutual TLS of the RPC with them.
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// caTTL is the lifetime of a root of the CA. A root is rotated well before.
+	caTTL = 10 * 365 * 24 * time.Hour
+
+	// clockSkew backdates the certificates, for the agents whose clock is
+	// behind.
+	clockSkew = time.Minute
+)
+
+// ServerName returns the name of the servers of the region in their
+// certificates, which the agents verify.
+func ServerName(region string) string {
+	return "server." + region + ".dtle"
+}
+
+// ClientName returns the name of the clients of the region in their
+// certificates.
+func ClientName(region string) string {
+	return "client." + region + ".dtle"
+}
+
+// GenerateKey returns a new private key and its PEM.
+func GenerateKey() (*ecdsa.PrivateKey, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+// GenerateCA returns the certificate and the private key, in PEM, of a new root.
+func GenerateCA(region string) (string, string, error) {
+	key, keyPEM, err := GenerateKey()
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("dtle CA %v %v", region, serial.Text(16)[:8])},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(caTTL),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return "", "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), keyPEM, nil
+}
+
+// CreateCSR returns the certificate request, in PEM, of the key.
+func CreateCSR(key crypto.Signer, commonName string) (string, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
+
+// SignCSR returns the certificate, in PEM, of the request signed by the root for
+// the DNS name, valid for the ttl.
+func SignCSR(rootPEM, rootKeyPEM, csrPEM, dnsName string, ttl time.Duration) (string, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return "", fmt.Errorf("bad certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return "", err
+	}
+	return Sign(rootPEM, rootKeyPEM, csr.PublicKey, csr.Subject.CommonName, dnsName, ttl)
+}
+
+// Sign returns the certificate, in PEM, of the public key signed by the root for
+// the DNS name, valid for the ttl.
+func Sign(rootPEM, rootKeyPEM string, pub crypto.PublicKey, commonName, dnsName string, ttl time.Duration) (string, error) {
+	root, err := ParseCertificate(rootPEM)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode([]byte(rootKeyPEM))
+	if block == nil {
+		return "", fmt.Errorf("bad CA key")
+	}
+	rootKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    now.Add(-clockSkew),
+		NotAfter:     now.Add(ttl),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root, pub, rootKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), nil
+}
+
+// ParseCertificate parses the first certificate of the PEM.
+func ParseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("bad certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// SplitCertificates returns the certificates of the PEM, e.g. of a file of
+// roots, each in PEM.
+func SplitCertificates(data string) []string {
+	var certs []string
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, string(pem.EncodeToMemory(block)))
+		}
+	}
+}
+
+// Fingerprint returns the hex SHA-256 of the DER of the certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// SealKey encrypts the private key of the root with AES-GCM and the seal key,
+// authenticating the ID of the root along with it. The nonce is prepended.
+func SealKey(sealKey []byte, rootID, keyPEM string) ([]byte, error) {
+	aead, err := newKeySealer(sealKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(keyPEM), []byte(rootID)), nil
+}
+
+// OpenKey decrypts the private key of the root sealed by SealKey.
+func OpenKey(sealKey []byte, rootID string, sealed []byte) (string, error) {
+	aead, err := newKeySealer(sealKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("the key of the CA root %v is not sealed", rootID)
+	}
+	nonce := sealed[:aead.NonceSize()]
+	keyPEM, err := aead.Open(nil, nonce, sealed[len(nonce):], []byte(rootID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open the key of the CA root %v: %v", rootID, err)
+	}
+	return string(keyPEM), nil
+}
+
+func newKeySealer(sealKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sealKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
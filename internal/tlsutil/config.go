@@ -0,0 +1,212 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Configurator holds the certificate of an agent and the roots of the CA, and
+// returns the TLS configurations of the RPC with them. The certificate and the
+// roots are replaced when they are rotated, the connections made after using
+// the new ones.
+type Configurator struct {
+	region string
+
+	l     sync.RWMutex
+	cert  *tls.Certificate
+	leaf  *x509.Certificate
+	roots *x509.CertPool
+	// rootPEMs are the roots, as given to Update.
+	rootPEMs []string
+}
+
+// NewConfigurator returns a configurator without a certificate, for the agents
+// of the region.
+func NewConfigurator(region string) *Configurator {
+	return &Configurator{region: region}
+}
+
+// Update replaces the certificate, its key and the roots, all in PEM. The root
+// of the certificate is sent along with it, for the agents bootstrapping to
+// verify it against the fingerprint of the CA.
+func (c *Configurator) Update(certPEM, keyPEM string, rootPEMs []string) error {
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	roots, parsed, err := parseRoots(rootPEMs)
+	if err != nil {
+		return err
+	}
+	issued := false
+	for _, root := range parsed {
+		if leaf.CheckSignatureFrom(root) == nil {
+			cert.Certificate = append(cert.Certificate, root.Raw)
+			issued = true
+			break
+		}
+	}
+	if !issued {
+		return fmt.Errorf("certificate not signed by a root of the CA")
+	}
+	cert.Leaf = leaf
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.cert = &cert
+	c.leaf = leaf
+	c.roots = roots
+	c.rootPEMs = rootPEMs
+	return nil
+}
+
+// UpdateRoots replaces the roots, keeping the certificate.
+func (c *Configurator) UpdateRoots(rootPEMs []string) error {
+	roots, _, err := parseRoots(rootPEMs)
+	if err != nil {
+		return err
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.roots = roots
+	c.rootPEMs = rootPEMs
+	return nil
+}
+
+// Ready returns whether the agent has a certificate.
+func (c *Configurator) Ready() bool {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return c.cert != nil
+}
+
+// Roots returns the roots, in PEM.
+func (c *Configurator) Roots() []string {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return c.rootPEMs
+}
+
+// RenewTime returns when the certificate is to be renewed: at half of its life,
+// or now if there is none.
+func (c *Configurator) RenewTime() time.Time {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	if c.leaf == nil {
+		return time.Now()
+	}
+	return c.leaf.NotBefore.Add(c.leaf.NotAfter.Sub(c.leaf.NotBefore) / 2)
+}
+
+// IncomingConfig returns the configuration of the server side of a connection.
+// The peer certificate is verified if there is one: the peers without one are
+// only allowed to get a certificate, which the caller enforces.
+func (c *Configurator) IncomingConfig() *tls.Config {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  c.roots,
+	}
+	if c.cert != nil {
+		config.Certificates = []tls.Certificate{*c.cert}
+	}
+	return config
+}
+
+// OutgoingConfig returns the configuration of the client side of a connection
+// to a server of the region.
+func (c *Configurator) OutgoingConfig() *tls.Config {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: ServerName(c.region),
+		RootCAs:    c.roots,
+	}
+	if c.cert != nil {
+		config.Certificates = []tls.Certificate{*c.cert}
+	}
+	return config
+}
+
+// BootstrapConfig returns the configuration of the connection of an agent
+// without a certificate to a server, to get one. The server is verified against
+// the root of the fingerprint given, which is required.
+func BootstrapConfig(region, fingerprint string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The chain is verified below, against the root sent by the server.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyBootstrapChain(region, fingerprint, rawCerts)
+		},
+	}
+}
+
+func verifyBootstrapChain(region, fingerprint string, rawCerts [][]byte) error {
+	var chain []*x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) < 2 {
+		return fmt.Errorf("server sent no root of the CA")
+	}
+	root := chain[len(chain)-1]
+	if fingerprint == "" {
+		return fmt.Errorf("no CA fingerprint to verify the server against")
+	}
+	if Fingerprint(root) != normalizeFingerprint(fingerprint) {
+		return fmt.Errorf("root of the server %v does not match the CA fingerprint", Fingerprint(root))
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:   ServerName(region),
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
+
+// normalizeFingerprint accepts the fingerprints printed with colons, or in upper
+// case, e.g. by openssl.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.Replace(fingerprint, ":", "", -1))
+}
+
+func parseRoots(rootPEMs []string) (*x509.CertPool, []*x509.Certificate, error) {
+	pool := x509.NewCertPool()
+	var roots []*x509.Certificate
+	for _, rootPEM := range rootPEMs {
+		root, err := ParseCertificate(rootPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool.AddCert(root)
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		return nil, nil, fmt.Errorf("no root of the CA")
+	}
+	return pool, roots, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAgent returns a configurator with a certificate of the name signed by the
+// root, trusting the roots.
+func testAgent(t *testing.T, rootCert, rootKey, name string, roots []string) *Configurator {
+	key, keyPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := CreateCSR(key, "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := SignCSR(rootCert, rootKey, csr, name, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfigurator("global")
+	if err := c.Update(cert, keyPEM, roots); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// handshake returns the errors of the TLS handshake between the configurations.
+func handshake(t *testing.T, server, client *tls.Config) (error, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		conn := tls.Server(c, server)
+		serverErr <- conn.Handshake()
+		conn.Close()
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := tls.Client(c, client)
+	err = conn.Handshake()
+	conn.Close()
+	return <-serverErr, err
+}
+
+func TestConfiguratorMutualTLS(t *testing.T) {
+	rootCert, rootKey, err := GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []string{rootCert}
+	server := testAgent(t, rootCert, rootKey, ServerName("global"), roots)
+	client := testAgent(t, rootCert, rootKey, ClientName("global"), roots)
+
+	if serverErr, err := handshake(t, server.IncomingConfig(), client.OutgoingConfig()); serverErr != nil || err != nil {
+		t.Errorf("handshake = %v, %v", serverErr, err)
+	}
+
+	// A client certificate does not pass for a server.
+	impostor := testAgent(t, rootCert, rootKey, ClientName("global"), roots)
+	if _, err := handshake(t, impostor.IncomingConfig(), client.OutgoingConfig()); err == nil {
+		t.Errorf("handshake with a client as the server succeeded")
+	}
+
+	// Neither does a certificate of another CA.
+	otherCert, otherKey, err := GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testAgent(t, otherCert, otherKey, ClientName("global"), []string{otherCert})
+	if serverErr, _ := handshake(t, server.IncomingConfig(), other.OutgoingConfig()); serverErr == nil {
+		t.Errorf("handshake with a client of another CA succeeded")
+	}
+
+	// The agents without a certificate verify the server against the
+	// fingerprint of the CA.
+	root, err := ParseCertificate(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := strings.ToUpper(Fingerprint(root))
+	if _, err := handshake(t, server.IncomingConfig(), BootstrapConfig("global", fingerprint)); err != nil {
+		t.Errorf("bootstrap handshake = %v", err)
+	}
+	if _, err := handshake(t, server.IncomingConfig(), BootstrapConfig("global", strings.Repeat("0", 64))); err == nil {
+		t.Errorf("bootstrap handshake with a bad fingerprint succeeded")
+	}
+	if _, err := handshake(t, server.IncomingConfig(), BootstrapConfig("global", "")); err == nil {
+		t.Errorf("bootstrap handshake without a fingerprint succeeded")
+	}
+	if _, err := handshake(t, impostor.IncomingConfig(), BootstrapConfig("global", "")); err == nil {
+		t.Errorf("bootstrap handshake with a client as the server succeeded")
+	}
+}
+
+func TestConfiguratorRotation(t *testing.T) {
+	oldCert, oldKey, err := GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCert, newKey, err := GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server renewed its certificate with the new root, the client still
+	// has one of the old root, and learnt the new root.
+	roots := []string{newCert, oldCert}
+	server := testAgent(t, newCert, newKey, ServerName("global"), roots)
+	client := testAgent(t, oldCert, oldKey, ClientName("global"), []string{oldCert})
+	if _, err := handshake(t, server.IncomingConfig(), client.OutgoingConfig()); err == nil {
+		t.Errorf("handshake before the client learnt the new root succeeded")
+	}
+	if err := client.UpdateRoots(roots); err != nil {
+		t.Fatal(err)
+	}
+	if serverErr, err := handshake(t, server.IncomingConfig(), client.OutgoingConfig()); serverErr != nil || err != nil {
+		t.Errorf("handshake = %v, %v", serverErr, err)
+	}
+
+	// A certificate is not accepted with roots which did not sign it.
+	key, keyPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := Sign(oldCert, oldKey, key.Public(), "agent", ClientName("global"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewConfigurator("global").Update(cert, keyPEM, []string{newCert}); err == nil {
+		t.Errorf("Update() with roots not signing the certificate succeeded")
+	}
+
+	if renew := client.RenewTime(); renew.Before(time.Now().Add(25*time.Minute)) || renew.After(time.Now().Add(31*time.Minute)) {
+		t.Errorf("RenewTime() = %v, expect in about half an hour", renew)
+	}
+	if split := SplitCertificates(strings.Join(roots, "")); len(split) != 2 || split[0] != newCert || split[1] != oldCert {
+		t.Errorf("SplitCertificates() = %v", split)
+	}
+}
+
+func TestSealKey(t *testing.T) {
+	_, rootKey, err := GenerateCA("global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealKey := []byte(strings.Repeat("k", 32))
+	sealed, err := SealKey(sealKey, "root1", rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "PRIVATE KEY") {
+		t.Fatalf("SealKey() left the key in clear")
+	}
+	if key, err := OpenKey(sealKey, "root1", sealed); err != nil || key != rootKey {
+		t.Errorf("OpenKey() = %v", err)
+	}
+	if _, err := OpenKey(sealKey, "root2", sealed); err == nil {
+		t.Errorf("OpenKey() of another root succeeded")
+	}
+	if _, err := OpenKey([]byte(strings.Repeat("x", 32)), "root1", sealed); err == nil {
+		t.Errorf("OpenKey() with another seal key succeeded")
+	}
+	if _, err := OpenKey(sealKey, "root1", nil); err == nil {
+		t.Errorf("OpenKey() of a key not sealed succeeded")
+	}
+}
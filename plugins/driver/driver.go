@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package driver is the interface of the driver plugins of dtle. A driver
+// plugin is a separate binary named "dtle-driver-<name>" in the plugin_dir of
+// the agent, which serves a Driver with Serve. Jobs use it by setting the
+// Driver of a task to <name>.
+//
+// A plugin binary depends only on this package, not on dtle internals.
+package driver
+
+import (
+	"encoding/json"
+
+	plugin "github.com/hashicorp/go-plugin"
+)
+
+const (
+	// PluginName is the name of the driver in the plugin map.
+	PluginName = "driver"
+
+	// BinaryPrefix is the prefix of the names of the plugin binaries.
+	BinaryPrefix = "dtle-driver-"
+)
+
+// Handshake is the handshake between dtle and the driver plugins. The
+// protocol version is increased on incompatible changes of the interface.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "DTLE_DRIVER_PLUGIN",
+	MagicCookieValue: "7c1d4bd4-2b7f-4e57-a3c1-c5ba05b1c9f4",
+}
+
+// PluginMap is the plugins served and dispensed by dtle.
+var PluginMap = map[string]plugin.Plugin{
+	PluginName: &Plugin{},
+}
+
+// ExecContext is the context of the execution of a task.
+type ExecContext struct {
+	// Subject is the ID of the job, which names its NATS subjects.
+	Subject string
+	// Tp is the type of the job.
+	Tp         string
+	MaxPayload int
+	// TaskDir is the working dir of the task, removed with the allocation.
+	TaskDir string
+	// Builtin is the context of the builtin drivers of dtle run as
+	// executors, encoded as JSON. The other plugins ignore it.
+	Builtin json.RawMessage `json:",omitempty"`
+}
+
+// Task is a task of a job.
+type Task struct {
+	// Type is "Src" or "Dest".
+	Type   string
+	Driver string
+	// Config is the free-form configuration of the task in the job.
+	Config map[string]interface{}
+}
+
+// WaitResult is the result of a task which has exited.
+type WaitResult struct {
+	ExitCode int
+	Err      string
+}
+
+// Driver is implemented by the driver plugins.
+type Driver interface {
+	// Start starts the task, which runs until it exits or Shutdown is called
+	// on the returned handle.
+	Start(ctx *ExecContext, task *Task) (Handle, error)
+
+	// Validate checks the configuration of the task. The result is encoded
+	// as JSON, in the format of TaskValidateResponse of the dtle API.
+	Validate(task *Task) (interface{}, error)
+}
+
+// Handle is a running task.
+type Handle interface {
+	// ID returns an opaque ID of the task. It is encoded as JSON with a
+	// "DriverConfig" object which has the "Gtid" and "NatsAddr" of the task
+	// if it has them, to be kept by dtle across restarts.
+	ID() string
+
+	// Wait blocks until the task exits.
+	Wait() *WaitResult
+
+	// Shutdown stops the task.
+	Shutdown() error
+
+	// Stats returns the statistics of the task, encoded as JSON in the format
+	// of TaskStatistics of the dtle API.
+	Stats() (interface{}, error)
+}
+
+// Serve serves the driver as a plugin. It is called by the main function of a
+// plugin binary, and does not return.
+func Serve(d Driver) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins: map[string]plugin.Plugin{
+			PluginName: &Plugin{Impl: d},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	plugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+
+	"github.com/actiontech/dtle/plugins/driver/proto"
+)
+
+// Plugin is the go-plugin of a Driver over gRPC.
+type Plugin struct {
+	plugin.NetRPCUnsupportedPlugin
+
+	// Impl is the served driver, only set in the plugin binary.
+	Impl Driver
+}
+
+func (p *Plugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterDriverServer(s, &grpcServer{
+		impl:    p.Impl,
+		handles: make(map[string]Handle),
+	})
+	return nil
+}
+
+func (p *Plugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &grpcClient{client: proto.NewDriverClient(c)}, nil
+}
+
+// grpcServer serves the driver in the plugin binary. The handles of the
+// started tasks are kept until they are shut down.
+type grpcServer struct {
+	impl Driver
+
+	handles  map[string]Handle
+	lastID   int
+	handlesL sync.Mutex
+}
+
+func (s *grpcServer) handle(id string) (Handle, error) {
+	s.handlesL.Lock()
+	defer s.handlesL.Unlock()
+	h, ok := s.handles[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown handle %q", id)
+	}
+	return h, nil
+}
+
+func (s *grpcServer) Start(ctx context.Context, req *proto.StartRequest) (*proto.StartResponse, error) {
+	var execCtx ExecContext
+	if err := json.Unmarshal(req.ExecContext, &execCtx); err != nil {
+		return nil, err
+	}
+	var task Task
+	if err := json.Unmarshal(req.Task, &task); err != nil {
+		return nil, err
+	}
+	h, err := s.impl.Start(&execCtx, &task)
+	if err != nil {
+		return nil, err
+	}
+
+	s.handlesL.Lock()
+	defer s.handlesL.Unlock()
+	s.lastID++
+	id := strconv.Itoa(s.lastID)
+	s.handles[id] = h
+	return &proto.StartResponse{HandleId: id}, nil
+}
+
+func (s *grpcServer) Validate(ctx context.Context, req *proto.ValidateRequest) (*proto.ValidateResponse, error) {
+	var task Task
+	if err := json.Unmarshal(req.Task, &task); err != nil {
+		return nil, err
+	}
+	resp, err := s.impl.Validate(&task)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ValidateResponse{Response: buf}, nil
+}
+
+func (s *grpcServer) ID(ctx context.Context, req *proto.HandleRequest) (*proto.IDResponse, error) {
+	h, err := s.handle(req.HandleId)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.IDResponse{Id: h.ID()}, nil
+}
+
+func (s *grpcServer) Wait(ctx context.Context, req *proto.HandleRequest) (*proto.WaitResponse, error) {
+	h, err := s.handle(req.HandleId)
+	if err != nil {
+		return nil, err
+	}
+	res := h.Wait()
+	return &proto.WaitResponse{ExitCode: int32(res.ExitCode), Err: res.Err}, nil
+}
+
+func (s *grpcServer) Shutdown(ctx context.Context, req *proto.HandleRequest) (*proto.Empty, error) {
+	h, err := s.handle(req.HandleId)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Shutdown(); err != nil {
+		return nil, err
+	}
+	s.handlesL.Lock()
+	delete(s.handles, req.HandleId)
+	s.handlesL.Unlock()
+	return &proto.Empty{}, nil
+}
+
+func (s *grpcServer) Stats(ctx context.Context, req *proto.HandleRequest) (*proto.StatsResponse, error) {
+	h, err := s.handle(req.HandleId)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.Stats()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.StatsResponse{Stats: buf}, nil
+}
+
+// grpcClient is the Driver dispensed to dtle. The JSON results of Validate
+// and Stats are returned as json.RawMessage, to be decoded by the caller.
+type grpcClient struct {
+	client proto.DriverClient
+}
+
+func (c *grpcClient) Start(ctx *ExecContext, task *Task) (Handle, error) {
+	execCtx, err := json.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Start(context.Background(), &proto.StartRequest{ExecContext: execCtx, Task: t})
+	if err != nil {
+		return nil, err
+	}
+	return &grpcHandle{client: c.client, id: resp.HandleId}, nil
+}
+
+func (c *grpcClient) Validate(task *Task) (interface{}, error) {
+	t, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Validate(context.Background(), &proto.ValidateRequest{Task: t})
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(resp.Response), nil
+}
+
+// grpcHandle is a handle of a task started by a plugin.
+type grpcHandle struct {
+	client proto.DriverClient
+	id     string
+}
+
+func (h *grpcHandle) req() *proto.HandleRequest {
+	return &proto.HandleRequest{HandleId: h.id}
+}
+
+func (h *grpcHandle) ID() string {
+	resp, err := h.client.ID(context.Background(), h.req())
+	if err != nil {
+		return `{"DriverConfig":{}}`
+	}
+	return resp.Id
+}
+
+func (h *grpcHandle) Wait() *WaitResult {
+	resp, err := h.client.Wait(context.Background(), h.req())
+	if err != nil {
+		return &WaitResult{ExitCode: 1, Err: fmt.Sprintf("plugin error: %v", err)}
+	}
+	return &WaitResult{ExitCode: int(resp.ExitCode), Err: resp.Err}
+}
+
+func (h *grpcHandle) Shutdown() error {
+	_, err := h.client.Shutdown(context.Background(), h.req())
+	return err
+}
+
+func (h *grpcHandle) Stats() (interface{}, error) {
+	resp, err := h.client.Stats(context.Background(), h.req())
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(resp.Stats), nil
+}
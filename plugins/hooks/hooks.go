@@ -0,0 +1,224 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package hooks is the extension point of dtle for custom Go code, e.g. to
+// invalidate a cache on the rows applied. A hook implements one or more of
+// RowHook, DDLHook and LifecycleHook, and is either
+//
+//   - compiled in: registered with MustRegister in the init of a package
+//     imported in cmd/dtle/hooks.go, or
+//   - a Go plugin: a file named "dtle-hook-<name>.so" in the plugin_dir of the
+//     agent, built with -buildmode=plugin against the same sources of dtle,
+//     which exports a variable or a function named Hook returning the hook.
+//
+// The hooks are called synchronously by the tasks of the jobs: a slow hook
+// slows the replication down. An error or a panic of a hook is logged and does
+// not fail the job.
+package hooks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hook is a named extension.
+type Hook interface {
+	Name() string
+}
+
+// RowHook is called on the row events of the incremental replication, once
+// their transaction is committed on the target.
+type RowHook interface {
+	Hook
+	OnRow(event *RowEvent) error
+}
+
+// DDLHook is called on the DDL statements of the incremental replication, once
+// their transaction is committed on the target.
+type DDLHook interface {
+	Hook
+	OnDDL(event *DDLEvent) error
+}
+
+// LifecycleHook is called on the events of the tasks of the jobs.
+type LifecycleHook interface {
+	Hook
+	OnLifecycle(event *LifecycleEvent)
+}
+
+const (
+	RowInsert = "Insert"
+	RowUpdate = "Update"
+	RowDelete = "Delete"
+)
+
+// RowEvent is a row inserted, updated or deleted on the target.
+type RowEvent struct {
+	JobID string
+	// Gtid is the gtid of the transaction on the source.
+	Gtid   string
+	Schema string
+	Table  string
+	// Type is RowInsert, RowUpdate or RowDelete.
+	Type string
+	// Columns are the names of the columns of the values, if known.
+	Columns []string
+	// Before is the row before an update or a delete, After the row after an
+	// insert or an update.
+	Before []interface{}
+	After  []interface{}
+}
+
+// DDLEvent is a DDL statement executed on the target.
+type DDLEvent struct {
+	JobID  string
+	Gtid   string
+	Schema string
+	// Table is empty for the statements on a schema.
+	Table string
+	Query string
+}
+
+// LifecycleEvent is an event of a task of a job, e.g. "Started", "Terminated"
+// or "Full Copy Complete".
+type LifecycleEvent struct {
+	JobID   string
+	JobName string
+	AllocID string
+	// Task is "Src" or "Dest".
+	Task string
+	Type string
+	// Message is the message or the error of the event, if any.
+	Message string
+	Time    time.Time
+}
+
+var (
+	lock           sync.RWMutex
+	names          = map[string]bool{}
+	rowHooks       []RowHook
+	ddlHooks       []DDLHook
+	lifecycleHooks []LifecycleHook
+)
+
+// Register registers the hook. It is an error to register 2 hooks of the same
+// name, or a hook implementing none of the hook interfaces.
+func Register(h Hook) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	name := h.Name()
+	if names[name] {
+		return fmt.Errorf("hook %q is already registered", name)
+	}
+	rh, isRow := h.(RowHook)
+	dh, isDDL := h.(DDLHook)
+	lh, isLifecycle := h.(LifecycleHook)
+	if !isRow && !isDDL && !isLifecycle {
+		return fmt.Errorf("hook %q implements none of RowHook, DDLHook and LifecycleHook", name)
+	}
+
+	names[name] = true
+	if isRow {
+		rowHooks = append(rowHooks, rh)
+	}
+	if isDDL {
+		ddlHooks = append(ddlHooks, dh)
+	}
+	if isLifecycle {
+		lifecycleHooks = append(lifecycleHooks, lh)
+	}
+	return nil
+}
+
+// MustRegister is Register for the init of the packages of compiled in hooks.
+func MustRegister(h Hook) {
+	if err := Register(h); err != nil {
+		panic(err)
+	}
+}
+
+// Names returns the names of the hooks registered.
+func Names() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var result []string
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// HasRowHooks returns whether a RowHook is registered, so the callers build
+// the row events only if needed.
+func HasRowHooks() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return len(rowHooks) > 0
+}
+
+// OnRow calls the row hooks with the event, and returns their errors.
+func OnRow(event *RowEvent) []error {
+	lock.RLock()
+	hs := rowHooks
+	lock.RUnlock()
+
+	var errs []error
+	for _, h := range hs {
+		if err := call(h, func() error { return h.OnRow(event) }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// OnDDL calls the DDL hooks with the event, and returns their errors.
+func OnDDL(event *DDLEvent) []error {
+	lock.RLock()
+	hs := ddlHooks
+	lock.RUnlock()
+
+	var errs []error
+	for _, h := range hs {
+		if err := call(h, func() error { return h.OnDDL(event) }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// OnLifecycle calls the lifecycle hooks with the event, and returns their
+// panics as errors.
+func OnLifecycle(event *LifecycleEvent) []error {
+	lock.RLock()
+	hs := lifecycleHooks
+	lock.RUnlock()
+
+	var errs []error
+	for _, h := range hs {
+		if err := call(h, func() error { h.OnLifecycle(event); return nil }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// call calls f of the hook, turning a panic into an error.
+func call(h Hook, f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hook %v panicked: %v", h.Name(), r)
+		}
+	}()
+	if err := f(); err != nil {
+		return fmt.Errorf("hook %v: %v", h.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package hooks
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testRowHook struct {
+	name string
+	rows []*RowEvent
+	err  error
+}
+
+func (h *testRowHook) Name() string { return h.name }
+
+func (h *testRowHook) OnRow(event *RowEvent) error {
+	h.rows = append(h.rows, event)
+	return h.err
+}
+
+type testLifecycleHook struct {
+	events []string
+}
+
+func (h *testLifecycleHook) Name() string { return "lifecycle" }
+
+func (h *testLifecycleHook) OnLifecycle(event *LifecycleEvent) {
+	if event.Type == "panic" {
+		panic("boom")
+	}
+	h.events = append(h.events, event.Type)
+}
+
+type testNoHook struct{}
+
+func (testNoHook) Name() string { return "none" }
+
+func resetHooks() {
+	names = map[string]bool{}
+	rowHooks, ddlHooks, lifecycleHooks = nil, nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	defer resetHooks()
+
+	if HasRowHooks() {
+		t.Fatalf("row hooks registered")
+	}
+	rh := &testRowHook{name: "row"}
+	if err := Register(rh); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(&testRowHook{name: "row"}); err == nil {
+		t.Errorf("registered a duplicate")
+	}
+	if err := Register(testNoHook{}); err == nil {
+		t.Errorf("registered a hook implementing no hook interface")
+	}
+	if err := Register(&testLifecycleHook{}); err != nil {
+		t.Fatal(err)
+	}
+	if !HasRowHooks() {
+		t.Errorf("no row hook registered")
+	}
+	if names := Names(); !reflect.DeepEqual(names, []string{"lifecycle", "row"}) {
+		t.Errorf("Names() = %v", names)
+	}
+}
+
+func TestOnRow(t *testing.T) {
+	defer resetHooks()
+
+	ok := &testRowHook{name: "ok"}
+	failing := &testRowHook{name: "failing", err: errors.New("failed")}
+	MustRegister(ok)
+	MustRegister(failing)
+
+	event := &RowEvent{Schema: "db1", Table: "tb1", Type: RowInsert, After: []interface{}{1}}
+	errs := OnRow(event)
+	if len(errs) != 1 || errs[0].Error() != "hook failing: failed" {
+		t.Errorf("errs = %v", errs)
+	}
+	if len(ok.rows) != 1 || ok.rows[0] != event || len(failing.rows) != 1 {
+		t.Errorf("rows = %v, %v", ok.rows, failing.rows)
+	}
+	if errs := OnDDL(&DDLEvent{Query: "drop table tb1"}); len(errs) != 0 {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestOnLifecycle_Panic(t *testing.T) {
+	defer resetHooks()
+
+	h := &testLifecycleHook{}
+	MustRegister(h)
+
+	if errs := OnLifecycle(&LifecycleEvent{Type: "panic"}); len(errs) != 1 {
+		t.Errorf("errs = %v", errs)
+	}
+	if errs := OnLifecycle(&LifecycleEvent{Type: "Started"}); len(errs) != 0 {
+		t.Errorf("errs = %v", errs)
+	}
+	if !reflect.DeepEqual(h.events, []string{"Started"}) {
+		t.Errorf("events = %v", h.events)
+	}
+}
+
+func TestPluginHook(t *testing.T) {
+	var h Hook = &testLifecycleHook{}
+	for _, sym := range []interface{}{h, &h, func() Hook { return h }} {
+		got, err := pluginHook(sym)
+		if err != nil || got != h {
+			t.Errorf("%T: got %v, %v", sym, got, err)
+		}
+	}
+	if _, err := pluginHook(42); err == nil {
+		t.Errorf("no error for a symbol not a hook")
+	}
+}
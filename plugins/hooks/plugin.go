@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package hooks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+)
+
+const (
+	// PluginPrefix and PluginSuffix surround the names of the hook plugins.
+	PluginPrefix = "dtle-hook-"
+	PluginSuffix = ".so"
+
+	// PluginSymbol is the symbol of the hook exported by a plugin.
+	PluginSymbol = "Hook"
+)
+
+// LoadPlugins opens the Go plugins named "dtle-hook-<name>.so" in the dir and
+// registers their hooks. It returns the names of the hooks. A missing dir has
+// no plugin.
+func LoadPlugins(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var loaded []string
+	for _, f := range files {
+		if !f.Mode().IsRegular() ||
+			!strings.HasPrefix(f.Name(), PluginPrefix) || !strings.HasSuffix(f.Name(), PluginSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		h, err := loadPlugin(path)
+		if err != nil {
+			return loaded, err
+		}
+		if err := Register(h); err != nil {
+			return loaded, fmt.Errorf("hook plugin %v: %v", path, err)
+		}
+		loaded = append(loaded, h.Name())
+	}
+	sort.Strings(loaded)
+	return loaded, nil
+}
+
+// loadPlugin opens the plugin and looks up its hook, which is exported as a
+// variable of a type implementing Hook, or as a function returning the Hook.
+func loadPlugin(path string) (Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hook plugin %v: %v", path, err)
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("hook plugin %v: %v", path, err)
+	}
+	return pluginHook(sym)
+}
+
+func pluginHook(sym plugin.Symbol) (Hook, error) {
+	switch h := sym.(type) {
+	case func() Hook:
+		return h(), nil
+	case *Hook:
+		return *h, nil
+	case Hook:
+		return h, nil
+	}
+	return nil, fmt.Errorf("symbol %v is a %T, not a Hook", PluginSymbol, sym)
+}